@@ -0,0 +1,65 @@
+package cooldown
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func NewCooldownCommand() *cobra.Command {
+	var statePath string
+
+	cmd := &cobra.Command{
+		Use:   "cooldown",
+		Short: "Inspect or clear provider cooldowns",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cooldownListCmd(statePath)
+			return nil
+		},
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			statePath = providers.CooldownStatePath(cfg.WorkspacePath())
+			return nil
+		},
+	}
+
+	cmd.AddCommand(newClearCommand(func() string { return statePath }))
+
+	return cmd
+}
+
+func newClearCommand(statePath func() string) *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "clear [provider]",
+		Short: "Clear a provider's cooldown",
+		Long: "Clear a provider's cooldown.\n\n" +
+			"A running gateway keeps its own copy of the state; use /cooldown clear " +
+			"in chat to clear it live, or restart the gateway after clearing here.",
+		Args:    cobra.MaximumNArgs(1),
+		Example: "picoclaw cooldown clear anthropic\npicoclaw cooldown clear --all",
+		RunE: func(_ *cobra.Command, args []string) error {
+			if all == (len(args) == 1) {
+				return fmt.Errorf("specify either a provider or --all")
+			}
+			provider := ""
+			if len(args) == 1 {
+				provider = args[0]
+			}
+			cooldownClearCmd(statePath(), provider)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Clear every provider")
+
+	return cmd
+}
@@ -0,0 +1,56 @@
+package cooldown
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestNewCooldownCommand(t *testing.T) {
+	cmd := NewCooldownCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "cooldown", cmd.Use)
+	assert.Equal(t, "Inspect or clear provider cooldowns", cmd.Short)
+
+	assert.True(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.RunE)
+	assert.NotNil(t, cmd.PersistentPreRunE)
+}
+
+func TestClearSubcommand(t *testing.T) {
+	cmd := newClearCommand(func() string { return "" })
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "clear [provider]", cmd.Use)
+	assert.True(t, cmd.HasExample())
+	assert.NotNil(t, cmd.Flags().Lookup("all"))
+}
+
+func TestClearSubcommandRequiresTarget(t *testing.T) {
+	cmd := newClearCommand(func() string { return "" })
+	cmd.SetArgs([]string{})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	assert.Error(t, cmd.Execute())
+}
+
+func TestCooldownClearCmd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cooldowns.json")
+	ct := providers.NewPersistentCooldownTracker(path)
+	ct.MarkFailure("anthropic", providers.FailoverBilling)
+	ct.MarkFailure("openai", providers.FailoverRateLimit)
+
+	cooldownClearCmd(path, "anthropic")
+
+	restored := providers.NewPersistentCooldownTracker(path)
+	assert.True(t, restored.IsAvailable("anthropic"))
+	assert.False(t, restored.IsAvailable("openai"))
+}
@@ -0,0 +1,27 @@
+package cooldown
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func cooldownListCmd(statePath string) {
+	ct := providers.NewPersistentCooldownTracker(statePath)
+	fmt.Println(commands.FormatCooldowns(ct.Statuses(), time.Now()))
+}
+
+func cooldownClearCmd(statePath, provider string) {
+	ct := providers.NewPersistentCooldownTracker(statePath)
+	n := ct.Clear(provider)
+	switch {
+	case provider == "":
+		fmt.Printf("✓ Cleared cooldown for %d provider(s)\n", n)
+	case n == 0:
+		fmt.Printf("Provider %s has no cooldown\n", provider)
+	default:
+		fmt.Printf("✓ Cleared cooldown for %s\n", provider)
+	}
+}
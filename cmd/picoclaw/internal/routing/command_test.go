@@ -0,0 +1,25 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRoutingCommand(t *testing.T) {
+	cmd := NewRoutingCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "routing", cmd.Use)
+	assert.Equal(t, "Inspect agent and model routing", cmd.Short)
+
+	assert.True(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.PersistentPreRunE)
+
+	allowedCommands := []string{"replay", "dry-run"}
+	for _, subcmd := range cmd.Commands() {
+		assert.Contains(t, allowedCommands, subcmd.Name())
+	}
+}
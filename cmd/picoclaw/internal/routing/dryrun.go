@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/routing"
+)
+
+func newDryRunCommand(loadConfig func() *config.Config) *cobra.Command {
+	var (
+		channel    string
+		account    string
+		peer       string
+		parentPeer string
+		guild      string
+		team       string
+		senders    []string
+		roles      []string
+		media      string
+		at         string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dry-run [message]",
+		Short: "Show which binding would handle a sample message",
+		Args:  cobra.ArbitraryArgs,
+		Example: "picoclaw routing dry-run --channel telegram --peer direct:12345 \"@coder fix the build\"\n" +
+			"picoclaw routing dry-run --channel slack --media voice --at 23:30",
+		RunE: func(_ *cobra.Command, args []string) error {
+			input, err := buildRouteInput(routeFlags{
+				channel:    channel,
+				account:    account,
+				peer:       peer,
+				parentPeer: parentPeer,
+				guild:      guild,
+				team:       team,
+				senders:    senders,
+				roles:      roles,
+				media:      media,
+				at:         at,
+				message:    strings.Join(args, " "),
+			}, time.Now())
+			if err != nil {
+				return err
+			}
+
+			cfg := loadConfig()
+			route := routing.NewRouteResolver(cfg).ResolveRoute(input)
+			printRoute(os.Stdout, cfg, input, route)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&channel, "channel", "", "Channel the message arrives on (required)")
+	cmd.Flags().StringVar(&account, "account", "", "Channel account ID")
+	cmd.Flags().StringVar(&peer, "peer", "", "Peer as kind:id, e.g. direct:12345 or group:-100123")
+	cmd.Flags().StringVar(&parentPeer, "parent-peer", "", "Parent peer (thread or reply) as kind:id")
+	cmd.Flags().StringVar(&guild, "guild", "", "Guild ID")
+	cmd.Flags().StringVar(&team, "team", "", "Team ID")
+	cmd.Flags().StringArrayVar(&senders, "sender", nil, "Sender identity matched against roles (repeatable)")
+	cmd.Flags().StringArrayVar(&roles, "role", nil, "Role reported by the channel (repeatable)")
+	cmd.Flags().StringVar(&media, "media", routing.MediaText, "Media type: text, voice, image or file")
+	cmd.Flags().StringVar(&at, "at", "", "Arrival time as RFC 3339 or HH:MM today (default: now)")
+	_ = cmd.MarkFlagRequired("channel")
+
+	return cmd
+}
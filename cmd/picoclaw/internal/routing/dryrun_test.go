@@ -0,0 +1,77 @@
+package routing
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/routing"
+)
+
+func TestNewDryRunCommand(t *testing.T) {
+	cmd := newDryRunCommand(func() *config.Config { return nil })
+
+	require.NotNil(t, cmd)
+	assert.Equal(t, "dry-run [message]", cmd.Use)
+	for _, name := range []string{"channel", "account", "peer", "parent-peer", "guild", "team", "sender", "role", "media", "at"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), name)
+	}
+}
+
+func TestBuildRouteInput(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	input, err := buildRouteInput(routeFlags{
+		channel: "telegram",
+		peer:    "direct:42",
+		media:   "Voice",
+		at:      "23:15",
+		message: "hello",
+	}, now)
+	require.NoError(t, err)
+	assert.Equal(t, &routing.RoutePeer{Kind: "direct", ID: "42"}, input.Peer)
+	assert.Nil(t, input.ParentPeer)
+	assert.Equal(t, routing.MediaVoice, input.MediaType)
+	assert.Equal(t, time.Date(2026, 3, 2, 23, 15, 0, 0, time.UTC), input.Time)
+
+	_, err = buildRouteInput(routeFlags{peer: "42", media: "text"}, now)
+	assert.Error(t, err)
+	_, err = buildRouteInput(routeFlags{media: "video"}, now)
+	assert.Error(t, err)
+	_, err = buildRouteInput(routeFlags{media: "text", at: "late"}, now)
+	assert.Error(t, err)
+}
+
+func TestPrintRoute(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{List: []config.AgentConfig{{ID: "main", Default: true}, {ID: "coder"}}},
+		Bindings: []config.AgentBinding{
+			{AgentID: "main", Match: config.BindingMatch{Channel: "telegram", AccountID: "*"}},
+			{AgentID: "coder", Match: config.BindingMatch{
+				Channel: "telegram", AccountID: "*",
+				Content: &config.ContentMatch{Command: "@coder"},
+			}},
+		},
+	}
+	input, err := buildRouteInput(routeFlags{
+		channel: "telegram",
+		peer:    "direct:42",
+		media:   "text",
+		message: "@coder fix the build",
+	}, time.Now())
+	require.NoError(t, err)
+
+	route := routing.NewRouteResolver(cfg).ResolveRoute(input)
+	var buf bytes.Buffer
+	printRoute(&buf, cfg, input, route)
+	out := buf.String()
+
+	assert.Contains(t, out, "Agent:       coder")
+	assert.Contains(t, out, "Matched by:  binding.content")
+	assert.Contains(t, out, "Binding:     #2 ")
+	assert.Contains(t, out, "Agent sees:  fix the build")
+}
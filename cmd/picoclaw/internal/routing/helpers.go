@@ -0,0 +1,159 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// configuredThreshold returns the light tier cutoff from the config, or 0 to
+// let the replay fall back to the default.
+func configuredThreshold(rc *config.RoutingConfig) float64 {
+	if rc == nil {
+		return 0
+	}
+	if len(rc.Tiers) == 0 {
+		return rc.Threshold
+	}
+	lowest := rc.Tiers[0].MaxScore
+	for _, t := range rc.Tiers[1:] {
+		lowest = min(lowest, t.MaxScore)
+	}
+	return lowest
+}
+
+func priceFunc(models []config.ModelConfig) routing.CostFunc {
+	prices := usage.NewPriceTable(models)
+	return func(model string, promptTokens, completionTokens int) float64 {
+		return prices.Cost(model, &providers.UsageInfo{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+		})
+	}
+}
+
+func printReplay(w io.Writer, r routing.ReplayReport, classifier string, days int) {
+	fmt.Fprintf(w, "\nRouting replay (%d turns, last %d days, classifier %s, threshold %.2f):\n\n",
+		r.Turns, days, classifier, r.Threshold)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tLIGHT SHARE\tCOST\tRETRY/COMPLAINT")
+	for _, row := range []struct {
+		name string
+		o    routing.ReplayOutcome
+	}{
+		{"logged", r.Baseline},
+		{"replay", r.Replay},
+	} {
+		fmt.Fprintf(tw, "%s\t%.0f%%\t%.4f\t%.1f%%\n",
+			row.name, row.o.LightShare(r.Turns)*100, row.o.Cost, row.o.UnhappyRate(r.Turns)*100)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nChanged turns: %d moved to primary, %d moved to light\n", r.Promoted, r.Demoted)
+	fmt.Fprintf(w, "Observed retry/complaint rate: light %.1f%%, primary %.1f%%\n",
+		r.LightUnhappyRate*100, r.PrimaryUnhappyRate*100)
+	if delta := r.Replay.Cost - r.Baseline.Cost; delta != 0 {
+		fmt.Fprintf(w, "Cost change: %+.4f (%+.0f%%)\n", delta, pct(delta, r.Baseline.Cost))
+	}
+}
+
+func pct(delta, base float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return delta / base * 100
+}
+
+type routeFlags struct {
+	channel, account, peer, parentPeer, guild, team string
+	senders, roles                                  []string
+	media, at, message                              string
+}
+
+func buildRouteInput(f routeFlags, now time.Time) (routing.RouteInput, error) {
+	peer, err := parsePeer(f.peer)
+	if err != nil {
+		return routing.RouteInput{}, fmt.Errorf("--peer: %w", err)
+	}
+	parentPeer, err := parsePeer(f.parentPeer)
+	if err != nil {
+		return routing.RouteInput{}, fmt.Errorf("--parent-peer: %w", err)
+	}
+	media := strings.ToLower(f.media)
+	if !slices.Contains([]string{routing.MediaText, routing.MediaVoice, routing.MediaImage, routing.MediaFile}, media) {
+		return routing.RouteInput{}, fmt.Errorf("invalid --media %q (use text, voice, image or file)", f.media)
+	}
+	at, err := parseAt(f.at, now)
+	if err != nil {
+		return routing.RouteInput{}, err
+	}
+
+	return routing.RouteInput{
+		Channel:     f.channel,
+		AccountID:   f.account,
+		Peer:        peer,
+		ParentPeer:  parentPeer,
+		GuildID:     f.guild,
+		TeamID:      f.team,
+		Content:     f.message,
+		SenderIDs:   f.senders,
+		SenderRoles: f.roles,
+		MediaType:   media,
+		Time:        at,
+	}, nil
+}
+
+func parsePeer(v string) (*routing.RoutePeer, error) {
+	if v == "" {
+		return nil, nil
+	}
+	kind, id, ok := strings.Cut(v, ":")
+	if !ok || kind == "" || id == "" {
+		return nil, fmt.Errorf("expected kind:id, got %q", v)
+	}
+	return &routing.RoutePeer{Kind: kind, ID: id}, nil
+}
+
+func parseAt(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	clock, err := time.ParseInLocation("15:04", v, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --at %q (use RFC 3339 or HH:MM)", v)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location()), nil
+}
+
+func printRoute(w io.Writer, cfg *config.Config, input routing.RouteInput, route routing.ResolvedRoute) {
+	fmt.Fprintf(w, "Agent:       %s\n", route.AgentID)
+	fmt.Fprintf(w, "Matched by:  %s\n", route.MatchedBy)
+	if route.Binding != nil {
+		index := -1
+		for i := range cfg.Bindings {
+			if &cfg.Bindings[i] == route.Binding {
+				index = i
+			}
+		}
+		data, _ := json.Marshal(route.Binding)
+		fmt.Fprintf(w, "Binding:     #%d %s\n", index+1, data)
+	}
+	fmt.Fprintf(w, "Session key: %s\n", route.SessionKey)
+	fmt.Fprintf(w, "Evaluated:   %s, media %s\n", input.Time.Format("Mon 2006-01-02 15:04 MST"), input.MediaType)
+	if route.Content != "" {
+		fmt.Fprintf(w, "Agent sees:  %s\n", route.Content)
+	}
+}
@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/routing"
+)
+
+func newReplayCommand(loadConfig func() *config.Config) *cobra.Command {
+	var (
+		threshold  float64
+		classifier string
+		days       int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Re-score logged routing decisions with another threshold or classifier",
+		Long: "Re-score the routing decisions logged by the gateway and compare the\n" +
+			"result with what actually happened: share of light-model turns, cost,\n" +
+			"and the rate of turns the user retried or complained about.\n\n" +
+			"Turns that change model are priced from model_list pricing and take the\n" +
+			"observed retry/complaint rate of the side they move to.",
+		Args:    cobra.NoArgs,
+		Example: "picoclaw routing replay --threshold 0.5\npicoclaw routing replay --classifier recorded --threshold 0.6",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if days <= 0 {
+				return fmt.Errorf("--days must be positive")
+			}
+			cfg := loadConfig()
+			if threshold <= 0 {
+				threshold = configuredThreshold(cfg.Agents.Defaults.Routing)
+			}
+			return replayCmd(cfg, threshold, classifier, days)
+		},
+	}
+
+	cmd.Flags().Float64VarP(&threshold, "threshold", "t", 0, "Light/primary score cutoff (default: configured threshold)")
+	cmd.Flags().StringVarP(&classifier, "classifier", "c", "rules",
+		"Classifier to replay: rules (re-score features) or recorded (reuse logged scores)")
+	cmd.Flags().IntVarP(&days, "days", "d", 30, "Number of days of traffic to replay")
+
+	return cmd
+}
+
+func replayCmd(cfg *config.Config, threshold float64, classifier string, days int) error {
+	since := time.Now().AddDate(0, 0, -days)
+	records, err := routing.ReadDecisions(filepath.Join(cfg.WorkspacePath(), "routing"), since)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Printf("No routing decisions logged in the last %d days.\n", days)
+		return nil
+	}
+
+	report, err := routing.Replay(records, routing.ReplayOptions{
+		Threshold:  threshold,
+		Classifier: classifier,
+		Cost:       priceFunc(cfg.ModelList),
+	})
+	if err != nil {
+		return err
+	}
+	printReplay(os.Stdout, report, classifier, days)
+	return nil
+}
@@ -0,0 +1,61 @@
+package routing
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/routing"
+)
+
+func TestNewReplayCommand(t *testing.T) {
+	cmd := newReplayCommand(func() *config.Config { return nil })
+
+	require.NotNil(t, cmd)
+	assert.Equal(t, "replay", cmd.Use)
+	assert.NotNil(t, cmd.Flags().Lookup("threshold"))
+	assert.NotNil(t, cmd.Flags().Lookup("classifier"))
+	assert.NotNil(t, cmd.Flags().Lookup("days"))
+}
+
+func TestConfiguredThreshold(t *testing.T) {
+	assert.Zero(t, configuredThreshold(nil))
+	assert.Equal(t, 0.4, configuredThreshold(&config.RoutingConfig{Threshold: 0.4}))
+	assert.Equal(t, 0.2, configuredThreshold(&config.RoutingConfig{
+		Threshold: 0.4,
+		Tiers:     []config.RoutingTier{{MaxScore: 0.6}, {MaxScore: 0.2}},
+	}))
+}
+
+func TestPriceFunc(t *testing.T) {
+	cost := priceFunc([]config.ModelConfig{
+		{ModelName: "big", Model: "openai/gpt-big", Pricing: &config.ModelPricing{Input: 2, Output: 10}},
+	})
+	assert.InDelta(t, 0.003, cost("big", 1000, 100), 1e-9)
+	assert.Zero(t, cost("unpriced", 1000, 100))
+}
+
+func TestPrintReplay(t *testing.T) {
+	report := routing.ReplayReport{
+		Turns:              4,
+		Threshold:          0.5,
+		Baseline:           routing.ReplayOutcome{LightTurns: 2, Cost: 0.02, Unhappy: 1},
+		Replay:             routing.ReplayOutcome{LightTurns: 3, Cost: 0.01, Unhappy: 1.5},
+		Demoted:            1,
+		LightUnhappyRate:   0.5,
+		PrimaryUnhappyRate: 0,
+	}
+
+	var buf bytes.Buffer
+	printReplay(&buf, report, "rules", 7)
+	out := buf.String()
+
+	assert.Contains(t, out, "4 turns, last 7 days, classifier rules, threshold 0.50")
+	assert.Contains(t, out, "75%")
+	assert.Contains(t, out, "37.5%")
+	assert.Contains(t, out, "0 moved to primary, 1 moved to light")
+	assert.Contains(t, out, "Cost change: -0.0100 (-50%)")
+}
@@ -1,10 +1,14 @@
 package status
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 func TestNewStatusCommand(t *testing.T) {
@@ -27,3 +31,29 @@ func TestNewStatusCommand(t *testing.T) {
 	assert.Nil(t, cmd.PersistentPreRun)
 	assert.Nil(t, cmd.PersistentPostRun)
 }
+
+func TestPrintModelHealth(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	snap := &providers.HealthSnapshot{
+		UpdatedAt: now.Add(-90 * time.Second),
+		Models: []providers.ModelHealth{
+			{Provider: "openai", Model: "gpt-4", Samples: 4, SuccessRate: 0.75, P50Ms: 420},
+			{Provider: "ollama", Model: "llama3", Samples: 2, LastError: "connection refused"},
+		},
+	}
+
+	var buf bytes.Buffer
+	printModelHealth(&buf, snap, now)
+	out := buf.String()
+
+	assert.Contains(t, out, "Model Health (probed 1m30s ago):")
+	assert.Contains(t, out, "openai/gpt-4: p50 420ms, 75% ok (4 samples)")
+	assert.Contains(t, out, "ollama/llama3: unreachable (2 samples)")
+	assert.Contains(t, out, "last error: connection refused")
+}
+
+func TestPrintModelHealth_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	printModelHealth(&buf, &providers.HealthSnapshot{}, time.Now())
+	assert.Empty(t, buf.String())
+}
@@ -2,11 +2,14 @@ package status
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
 	"github.com/sipeed/picoclaw/pkg/auth"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 func statusCmd() {
@@ -97,5 +100,27 @@ func statusCmd() {
 				fmt.Printf("  %s (%s): %s\n", provider, cred.AuthMethod, status)
 			}
 		}
+
+		if snap, err := providers.LoadHealthSnapshot(providers.HealthSnapshotPath(workspace)); err == nil {
+			printModelHealth(os.Stdout, snap, time.Now())
+		}
+	}
+}
+
+// printModelHealth prints the probe results written by a running gateway.
+func printModelHealth(w io.Writer, snap *providers.HealthSnapshot, now time.Time) {
+	if len(snap.Models) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nModel Health (probed %s ago):\n", now.Sub(snap.UpdatedAt).Round(time.Second))
+	for _, m := range snap.Models {
+		state := fmt.Sprintf("p50 %dms, %.0f%% ok", m.P50Ms, m.SuccessRate*100)
+		if m.SuccessRate == 0 {
+			state = "unreachable"
+		}
+		fmt.Fprintf(w, "  %s/%s: %s (%d samples)\n", m.Provider, m.Model, state, m.Samples)
+		if m.LastError != "" {
+			fmt.Fprintf(w, "    last error: %s\n", m.LastError)
+		}
 	}
 }
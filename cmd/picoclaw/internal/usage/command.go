@@ -0,0 +1,44 @@
+package usage
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func NewUsageCommand() *cobra.Command {
+	var (
+		period string
+		days   int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Show token usage and cost report",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if period != usage.PeriodDaily && period != usage.PeriodWeekly {
+				return fmt.Errorf("invalid --period %q (use %s or %s)", period, usage.PeriodDaily, usage.PeriodWeekly)
+			}
+			if days <= 0 {
+				return fmt.Errorf("--days must be positive")
+			}
+
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+
+			return usageReportCmd(filepath.Join(cfg.WorkspacePath(), "usage"), period, days, cfg.Usage.MonthlyBudget)
+		},
+	}
+
+	cmd.Flags().StringVarP(&period, "period", "p", usage.PeriodDaily, "Group by period: daily or weekly")
+	cmd.Flags().IntVarP(&days, "days", "d", 30, "Number of days to include")
+
+	return cmd
+}
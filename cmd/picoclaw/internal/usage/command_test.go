@@ -0,0 +1,53 @@
+package usage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func TestNewUsageCommand(t *testing.T) {
+	cmd := NewUsageCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "usage", cmd.Use)
+	assert.Equal(t, "Show token usage and cost report", cmd.Short)
+
+	assert.False(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.RunE)
+
+	assert.NotNil(t, cmd.Flags().Lookup("period"))
+	assert.NotNil(t, cmd.Flags().Lookup("days"))
+}
+
+func TestPrintReport(t *testing.T) {
+	now := time.Now()
+	records := []usage.Record{
+		{Time: now, Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 10, Cost: 0.5},
+		{Time: now, Model: "gpt-4o", PromptTokens: 50, CompletionTokens: 5, Cost: 0.25},
+	}
+
+	var buf bytes.Buffer
+	printReport(&buf, records, records, usage.PeriodDaily, 7, 3)
+	out := buf.String()
+
+	assert.Contains(t, out, "gpt-4o")
+	assert.Contains(t, out, now.Format("2006-01-02"))
+	assert.Contains(t, out, "0.7500")
+	assert.True(t, strings.Contains(out, "of 3.00 budget (25%)"), out)
+}
+
+func TestPrintReport_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	printReport(&buf, nil, nil, usage.PeriodWeekly, 14, 0)
+
+	assert.Contains(t, buf.String(), "No usage recorded in the last 14 days.")
+	assert.NotContains(t, buf.String(), "budget")
+}
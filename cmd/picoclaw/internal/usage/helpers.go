@@ -0,0 +1,62 @@
+package usage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func usageReportCmd(ledgerDir, period string, days int, monthlyBudget float64) error {
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -(days - 1))
+
+	records, err := usage.ReadRecords(ledgerDir, since, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	monthRecords, err := usage.ReadRecords(ledgerDir, monthStart, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	printReport(os.Stdout, records, monthRecords, period, days, monthlyBudget)
+	return nil
+}
+
+func printReport(w io.Writer, records, monthRecords []usage.Record, period string, days int, monthlyBudget float64) {
+	if len(records) == 0 {
+		fmt.Fprintf(w, "No usage recorded in the last %d days.\n", days)
+	} else {
+		fmt.Fprintf(w, "\nUsage (%s, last %d days):\n\n", period, days)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PERIOD\tMODEL\tREQUESTS\tPROMPT\tCOMPLETION\tCACHE READ\tCOST")
+		var total usage.Totals
+		for _, row := range usage.Aggregate(records, period) {
+			t := row.Totals
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%.4f\n",
+				row.Period, row.Model, t.Requests, t.PromptTokens, t.CompletionTokens, t.CacheReadTokens, t.Cost)
+		}
+		for _, r := range records {
+			total.Add(r)
+		}
+		fmt.Fprintf(tw, "TOTAL\t\t%d\t%d\t%d\t%d\t%.4f\n",
+			total.Requests, total.PromptTokens, total.CompletionTokens, total.CacheReadTokens, total.Cost)
+		tw.Flush()
+	}
+
+	var month usage.Totals
+	for _, r := range monthRecords {
+		month.Add(r)
+	}
+	fmt.Fprintf(w, "\nThis month: %d requests, cost %.4f", month.Requests, month.Cost)
+	if monthlyBudget > 0 {
+		fmt.Fprintf(w, " of %.2f budget (%.0f%%)", monthlyBudget, month.Cost/monthlyBudget*100)
+	}
+	fmt.Fprintln(w)
+}
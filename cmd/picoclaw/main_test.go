@@ -36,13 +36,16 @@ func TestNewPicoclawCommand(t *testing.T) {
 	allowedCommands := []string{
 		"agent",
 		"auth",
+		"cooldown",
 		"cron",
 		"gateway",
 		"migrate",
 		"model",
 		"onboard",
+		"routing",
 		"skills",
 		"status",
+		"usage",
 		"version",
 	}
 
@@ -26,6 +26,10 @@ type ContextBuilder struct {
 	toolDiscoveryBM25  bool
 	toolDiscoveryRegex bool
 
+	// prompt holds the agent's persona and instruction layers; nil keeps
+	// the workspace-only defaults.
+	prompt *config.AgentPromptConfig
+
 	// Cache for system prompt to avoid rebuilding on every call.
 	// This fixes issue #607: repeated reprocessing of the entire context.
 	// The cache auto-invalidates when workspace source files change (mtime check).
@@ -51,6 +55,12 @@ func (cb *ContextBuilder) WithToolDiscovery(useBM25, useRegex bool) *ContextBuil
 	return cb
 }
 
+// WithPrompt layers an agent's persona and instructions over the workspace.
+func (cb *ContextBuilder) WithPrompt(prompt *config.AgentPromptConfig) *ContextBuilder {
+	cb.prompt = prompt
+	return cb
+}
+
 func getGlobalConfigDir() string {
 	if home := os.Getenv("PICOCLAW_HOME"); home != "" {
 		return home
@@ -83,11 +93,15 @@ func (cb *ContextBuilder) getIdentity() string {
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
 	toolDiscovery := cb.getDiscoveryRule()
 	version := config.FormatVersion()
+	intro := "You are picoclaw, a helpful AI assistant."
+	if cb.prompt != nil && strings.TrimSpace(cb.prompt.Persona) != "" {
+		intro = strings.TrimSpace(cb.prompt.Persona)
+	}
 
 	return fmt.Sprintf(
 		`# picoclaw 🦞 (%s)
 
-You are picoclaw, a helpful AI assistant.
+%s
 
 ## Workspace
 Your workspace is at: %s
@@ -106,7 +120,7 @@ Your workspace is at: %s
 4. **Context summaries** - Conversation summaries provided as context are approximate references only. They may be incomplete or outdated. Always defer to explicit user instructions over summary content.
 
 %s`,
-		version, workspacePath, workspacePath, workspacePath, workspacePath, workspacePath, toolDiscovery)
+		version, intro, workspacePath, workspacePath, workspacePath, workspacePath, workspacePath, toolDiscovery)
 }
 
 func (cb *ContextBuilder) getDiscoveryRule() string {
@@ -134,6 +148,11 @@ func (cb *ContextBuilder) BuildSystemPrompt() string {
 	// Core identity section
 	parts = append(parts, cb.getIdentity())
 
+	// Agent-specific instructions
+	if instructions := cb.agentInstructions(); instructions != "" {
+		parts = append(parts, instructions)
+	}
+
 	// Bootstrap files
 	bootstrapContent := cb.LoadBootstrapFiles()
 	if bootstrapContent != "" {
@@ -222,13 +241,23 @@ func (cb *ContextBuilder) InvalidateCache() {
 // invalidation (bootstrap files + memory). Skill roots are handled separately
 // because they require both directory-level and recursive file-level checks.
 func (cb *ContextBuilder) sourcePaths() []string {
-	return []string{
-		filepath.Join(cb.workspace, "AGENTS.md"),
-		filepath.Join(cb.workspace, "SOUL.md"),
-		filepath.Join(cb.workspace, "USER.md"),
-		filepath.Join(cb.workspace, "IDENTITY.md"),
-		filepath.Join(cb.workspace, "memory", "MEMORY.md"),
+	var paths []string
+	for _, name := range cb.bootstrapFiles() {
+		paths = append(paths, cb.resolvePath(name))
 	}
+	if cb.prompt != nil && strings.TrimSpace(cb.prompt.SystemPromptFile) != "" {
+		paths = append(paths, cb.resolvePath(cb.prompt.SystemPromptFile))
+	}
+	return append(paths, filepath.Join(cb.workspace, "memory", "MEMORY.md"))
+}
+
+// resolvePath resolves a configured prompt file against the workspace.
+func (cb *ContextBuilder) resolvePath(name string) string {
+	name = strings.TrimSpace(name)
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(cb.workspace, name)
 }
 
 // skillRoots returns all skill root directories that can affect
@@ -431,25 +460,72 @@ func skillFilesChangedSince(skillRoots []string, filesAtCache map[string]time.Ti
 	return false
 }
 
-func (cb *ContextBuilder) LoadBootstrapFiles() string {
-	bootstrapFiles := []string{
-		"AGENTS.md",
-		"SOUL.md",
-		"USER.md",
-		"IDENTITY.md",
+var defaultBootstrapFiles = []string{
+	"AGENTS.md",
+	"SOUL.md",
+	"USER.md",
+	"IDENTITY.md",
+}
+
+// bootstrapFiles returns the bootstrap files selected for this agent.
+func (cb *ContextBuilder) bootstrapFiles() []string {
+	if cb.prompt != nil && cb.prompt.BootstrapFiles != nil {
+		return cb.prompt.BootstrapFiles
 	}
+	return defaultBootstrapFiles
+}
 
+func (cb *ContextBuilder) LoadBootstrapFiles() string {
 	var sb strings.Builder
-	for _, filename := range bootstrapFiles {
-		filePath := filepath.Join(cb.workspace, filename)
+	for _, filename := range cb.bootstrapFiles() {
+		filePath := cb.resolvePath(filename)
 		if data, err := os.ReadFile(filePath); err == nil {
-			fmt.Fprintf(&sb, "## %s\n\n%s\n\n", filename, data)
+			fmt.Fprintf(&sb, "## %s\n\n%s\n\n", strings.TrimSpace(filename), data)
 		}
 	}
 
 	return sb.String()
 }
 
+// agentInstructions renders the agent's own system prompt, language and
+// response style. It returns "" when the agent configures none of them.
+func (cb *ContextBuilder) agentInstructions() string {
+	if cb.prompt == nil {
+		return ""
+	}
+
+	var blocks []string
+	if text := strings.TrimSpace(cb.prompt.SystemPrompt); text != "" {
+		blocks = append(blocks, text)
+	}
+	if file := strings.TrimSpace(cb.prompt.SystemPromptFile); file != "" {
+		data, err := os.ReadFile(cb.resolvePath(file))
+		if err != nil {
+			logger.WarnCF("agent", "Cannot read agent system prompt file",
+				map[string]any{"file": file, "error": err.Error()})
+		} else if text := strings.TrimSpace(string(data)); text != "" {
+			blocks = append(blocks, text)
+		}
+	}
+
+	var style []string
+	if lang := strings.TrimSpace(cb.prompt.Language); lang != "" {
+		style = append(style, fmt.Sprintf(
+			"- Always reply in %s unless the user explicitly asks for another language.", lang))
+	}
+	if rs := strings.TrimSpace(cb.prompt.ResponseStyle); rs != "" {
+		style = append(style, "- Response style: "+rs)
+	}
+	if len(style) > 0 {
+		blocks = append(blocks, "## Response Style\n\n"+strings.Join(style, "\n"))
+	}
+
+	if len(blocks) == 0 {
+		return ""
+	}
+	return "# Agent Instructions\n\n" + strings.Join(blocks, "\n\n")
+}
+
 // buildDynamicContext returns a short dynamic context string with per-request info.
 // This changes every request (time, session) so it is NOT part of the cached prompt.
 // LLM-side KV cache reuse is achieved by each provider adapter's native mechanism:
@@ -556,8 +632,14 @@ func (cb *ContextBuilder) BuildMessages(
 		SystemParts: contentBlocks,
 	})
 
-	// Add conversation history
+	// Add conversation history. The last history message closes the stable
+	// prefix (system + prior turns) shared with the next request, so mark it
+	// as a cache breakpoint for cache-aware adapters. Only the slice copy is
+	// marked; CacheControl on Message is never persisted.
 	messages = append(messages, history...)
+	if len(history) > 0 {
+		messages[len(messages)-1].CacheControl = &providers.CacheControl{Type: "ephemeral"}
+	}
 
 	// Add current user message
 	if strings.TrimSpace(currentMessage) != "" {
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -667,3 +668,123 @@ func BenchmarkBuildMessagesWithCache(b *testing.B) {
 		_ = cb.BuildMessages(history, "summary", "new message", nil, "cli", "test")
 	}
 }
+
+// TestHistoryCacheBreakpoint verifies that BuildMessages marks the last history
+// message as the end of the cacheable prefix without touching the caller's
+// history slice or the new user message.
+func TestHistoryCacheBreakpoint(t *testing.T) {
+	tmpDir := setupWorkspace(t, nil)
+	defer os.RemoveAll(tmpDir)
+
+	cb := NewContextBuilder(tmpDir)
+	history := []providers.Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "reply"},
+	}
+
+	msgs := cb.BuildMessages(history, "", "second", nil, "cli", "direct")
+	if len(msgs) != 4 {
+		t.Fatalf("len(msgs) = %d, want 4", len(msgs))
+	}
+	if msgs[2].CacheControl == nil || msgs[2].CacheControl.Type != "ephemeral" {
+		t.Errorf("last history message not marked: %+v", msgs[2].CacheControl)
+	}
+	if msgs[1].CacheControl != nil || msgs[3].CacheControl != nil {
+		t.Error("only the last history message should be marked")
+	}
+	if history[1].CacheControl != nil {
+		t.Error("BuildMessages must not mutate the caller's history")
+	}
+
+	noHistory := cb.BuildMessages(nil, "", "hello", nil, "cli", "direct")
+	for _, m := range noHistory {
+		if m.CacheControl != nil {
+			t.Errorf("unexpected breakpoint without history on %s message", m.Role)
+		}
+	}
+}
+
+// TestAgentPromptLayers verifies that two agents sharing a workspace get their
+// own persona, instructions and bootstrap files.
+func TestAgentPromptLayers(t *testing.T) {
+	tmpDir := setupWorkspace(t, map[string]string{
+		"SOUL.md":                 "shared soul",
+		"USER.md":                 "shared user",
+		"agents/family/SOUL.md":   "family soul",
+		"agents/family/PROMPT.md": "Keep answers suitable for children.",
+	})
+	defer os.RemoveAll(tmpDir)
+
+	family := NewContextBuilder(tmpDir).WithPrompt(&config.AgentPromptConfig{
+		Persona:          "You are Pip, the family helper.",
+		SystemPromptFile: "agents/family/PROMPT.md",
+		Language:         "German",
+		ResponseStyle:    "short and friendly",
+		BootstrapFiles:   []string{"USER.md", "agents/family/SOUL.md"},
+	})
+	sp := family.BuildSystemPromptWithCache()
+	for _, want := range []string{
+		"You are Pip, the family helper.",
+		"Keep answers suitable for children.",
+		"Always reply in German",
+		"Response style: short and friendly",
+		"shared user",
+		"family soul",
+	} {
+		if !strings.Contains(sp, want) {
+			t.Errorf("family prompt missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"You are picoclaw", "shared soul"} {
+		if strings.Contains(sp, unwanted) {
+			t.Errorf("family prompt should not contain %q", unwanted)
+		}
+	}
+
+	none := NewContextBuilder(tmpDir).WithPrompt(&config.AgentPromptConfig{
+		SystemPrompt:   "You only handle ops alerts.",
+		BootstrapFiles: []string{},
+	})
+	sp = none.BuildSystemPromptWithCache()
+	if !strings.Contains(sp, "You only handle ops alerts.") || strings.Contains(sp, "shared user") {
+		t.Errorf("empty bootstrap list should load no files, got:\n%s", sp)
+	}
+
+	def := NewContextBuilder(tmpDir).BuildSystemPromptWithCache()
+	if !strings.Contains(def, "shared soul") || strings.Contains(def, "Agent Instructions") {
+		t.Error("default builder should load the shared bootstrap files only")
+	}
+}
+
+// TestAgentPromptFilesInvalidateCache verifies that the configured prompt
+// file and custom bootstrap files are tracked by the cache.
+func TestAgentPromptFilesInvalidateCache(t *testing.T) {
+	for _, file := range []string{"agents/ops/PROMPT.md", "agents/ops/SOUL.md"} {
+		t.Run(file, func(t *testing.T) {
+			tmpDir := setupWorkspace(t, nil)
+			defer os.RemoveAll(tmpDir)
+
+			cb := NewContextBuilder(tmpDir).WithPrompt(&config.AgentPromptConfig{
+				SystemPromptFile: "agents/ops/PROMPT.md",
+				BootstrapFiles:   []string{"agents/ops/SOUL.md"},
+			})
+			sp1 := cb.BuildSystemPromptWithCache()
+
+			// Creating the file must invalidate the cache.
+			fullPath := filepath.Join(tmpDir, file)
+			os.MkdirAll(filepath.Dir(fullPath), 0o755)
+			os.WriteFile(fullPath, []byte("v1 marker"), 0o644)
+			sp2 := cb.BuildSystemPromptWithCache()
+			if sp1 == sp2 || !strings.Contains(sp2, "v1 marker") {
+				t.Fatalf("cache not rebuilt after creating %s", file)
+			}
+
+			os.WriteFile(fullPath, []byte("v2 marker"), 0o644)
+			future := time.Now().Add(2 * time.Second)
+			os.Chtimes(fullPath, future, future)
+			if sp3 := cb.BuildSystemPromptWithCache(); !strings.Contains(sp3, "v2 marker") {
+				t.Errorf("cache not rebuilt after modifying %s", file)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// compactSession shrinks a session that reached its summarize thresholds
+// with the agent's context strategy.
+func (al *AgentLoop) compactSession(agent *AgentInstance, sessionKey, channel string) {
+	switch agent.Context.GetStrategy() {
+	case config.ContextStrategySlidingWindow:
+		al.slideWindow(agent, sessionKey)
+	case config.ContextStrategySummaryRecent:
+		al.summarizeBeforeRecentTurns(agent, sessionKey, channel)
+	case config.ContextStrategyToolElision:
+		// Eliding keeps every message; summarize when the session is still
+		// over either threshold, or compaction would re-run every turn.
+		al.elideToolResults(agent, sessionKey, agent.Context.GetKeepTurns())
+		history := agent.Sessions.GetHistory(sessionKey)
+		if len(history) > agent.SummarizeMessageThreshold ||
+			al.estimateTokens(agent, history) > agent.ContextWindow*agent.SummarizeTokenPercent/100 {
+			al.summarizeBeforeRecentTurns(agent, sessionKey, channel)
+		}
+	case config.ContextStrategyHierarchical:
+		al.summarizeHierarchically(agent, sessionKey, channel)
+	default:
+		al.summarizeSession(agent, sessionKey, channel)
+	}
+}
+
+// recentTurnsStart returns the index of the first message of the last n
+// turns, where a turn starts at a user message. It returns 0 when the
+// history has n turns or fewer.
+func recentTurnsStart(history []providers.Message, n int) int {
+	for i := len(history) - 1; i > 0; i-- {
+		if history[i].Role != "user" {
+			continue
+		}
+		if n--; n == 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// dropOldest removes the first n messages of the session's current history.
+// Messages added while the older ones were being summarized are kept.
+func dropOldest(agent *AgentInstance, sessionKey string, n int) {
+	history := agent.Sessions.GetHistory(sessionKey)
+	if n > len(history) {
+		n = len(history)
+	}
+	agent.Sessions.SetHistory(sessionKey, history[n:])
+}
+
+// slideWindow drops every message before the last keep_turns turns.
+func (al *AgentLoop) slideWindow(agent *AgentInstance, sessionKey string) {
+	history := agent.Sessions.GetHistory(sessionKey)
+	cut := recentTurnsStart(history, agent.Context.GetKeepTurns())
+	if cut == 0 {
+		return
+	}
+	agent.Sessions.SetHistory(sessionKey, history[cut:])
+	agent.Sessions.Save(sessionKey)
+
+	logger.InfoCF("agent", "Context window slid", map[string]any{
+		"agent_id":     agent.ID,
+		"session_key":  sessionKey,
+		"dropped_msgs": cut,
+	})
+}
+
+// summarizeBeforeRecentTurns folds everything before the last keep_turns
+// turns into the session summary and keeps those turns verbatim.
+func (al *AgentLoop) summarizeBeforeRecentTurns(agent *AgentInstance, sessionKey, channel string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	history := agent.Sessions.GetHistory(sessionKey)
+	cut := recentTurnsStart(history, agent.Context.GetKeepTurns())
+	if cut == 0 {
+		return
+	}
+
+	summary := al.summarizeMessages(ctx, agent, sessionKey, channel, history[:cut], agent.Sessions.GetSummary(sessionKey))
+	if summary == "" {
+		return
+	}
+	agent.Sessions.SetSummary(sessionKey, summary)
+	dropOldest(agent, sessionKey, cut)
+	agent.Sessions.Save(sessionKey)
+}
+
+const elisionPrefix = "[Tool result elided"
+
+// elideToolResults replaces large tool results before the last keepTurns
+// turns with stubs and keeps their full text in the workspace, where
+// fetch_tool_result can read it back. It returns the number of results
+// elided.
+func (al *AgentLoop) elideToolResults(agent *AgentInstance, sessionKey string, keepTurns int) int {
+	history := agent.Sessions.GetHistory(sessionKey)
+	cut := recentTurnsStart(history, keepTurns)
+	minChars := agent.Context.GetElideMinChars()
+	_, canFetch := agent.Tools.Get("fetch_tool_result")
+
+	elided := 0
+	for i := range history[:cut] {
+		m := &history[i]
+		if m.Role != "tool" || strings.HasPrefix(m.Content, elisionPrefix) {
+			continue
+		}
+		chars := utf8.RuneCountInString(m.Content)
+		if chars <= minChars {
+			continue
+		}
+
+		id := elidedResultID(sessionKey, *m)
+		path := tools.ElidedResultPath(agent.Workspace, id)
+		if err := fileutil.WriteFileAtomic(path, []byte(m.Content), 0o600); err != nil {
+			logger.WarnCF("agent", "Failed to store elided tool result",
+				map[string]any{"agent_id": agent.ID, "error": err.Error()})
+			continue
+		}
+		m.Content = elisionStub(id, m.Content, chars, canFetch)
+		elided++
+	}
+	if elided == 0 {
+		return 0
+	}
+
+	// Keep messages added since the history was read.
+	if current := agent.Sessions.GetHistory(sessionKey); len(current) > len(history) {
+		history = append(history, current[len(history):]...)
+	}
+	agent.Sessions.SetHistory(sessionKey, history)
+	agent.Sessions.Save(sessionKey)
+	logger.InfoCF("agent", "Tool results elided", map[string]any{
+		"agent_id":    agent.ID,
+		"session_key": sessionKey,
+		"elided":      elided,
+	})
+	return elided
+}
+
+// elidedResultID derives a stable ID for a tool result so eliding the same
+// result twice reuses one file.
+func elidedResultID(sessionKey string, m providers.Message) string {
+	sum := sha256.Sum256([]byte(sessionKey + "\x00" + m.ToolCallID + "\x00" + m.Content))
+	return "tr-" + hex.EncodeToString(sum[:8])
+}
+
+func elisionStub(id, content string, chars int, canFetch bool) string {
+	const previewRunes = 200
+	preview := []rune(content)
+	if len(preview) > previewRunes {
+		preview = preview[:previewRunes]
+	}
+	how := "It is no longer available."
+	if canFetch {
+		how = fmt.Sprintf("Call fetch_tool_result with id %q to read it again.", id)
+	}
+	return fmt.Sprintf("%s to save context: %d characters. %s It began:\n%s...]",
+		elisionPrefix, chars, how, string(preview))
+}
+
+// summaryTree holds hierarchical summaries. Levels[0] has summaries of
+// history chunks; once a level holds hierarchy_fanout summaries they are
+// merged into one summary on the next level.
+type summaryTree struct {
+	Levels [][]string `json:"levels"`
+}
+
+func summaryTreePath(workspace, sessionKey string) string {
+	name := strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(sessionKey)
+	return filepath.Join(workspace, "state", "summaries", name+".json")
+}
+
+// resetSummaryTree forgets a session's hierarchical summaries once its
+// history was cleared or replaced, so the next compaction starts from the
+// session's current summary instead of the old conversation.
+func resetSummaryTree(workspace, sessionKey string) {
+	path := summaryTreePath(workspace, sessionKey)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.WarnCF("agent", "Failed to remove summary tree",
+			map[string]any{"path": path, "error": err.Error()})
+	}
+}
+
+func loadSummaryTree(path string) summaryTree {
+	var tree summaryTree
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &tree)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.WarnCF("agent", "Failed to load summary tree",
+			map[string]any{"path": path, "error": err.Error()})
+	}
+	return tree
+}
+
+// summarizeHierarchically summarizes the history before the last keep_turns
+// turns into a new chunk summary and merges full levels upwards, so the
+// summary grows with the log of the conversation length.
+func (al *AgentLoop) summarizeHierarchically(agent *AgentInstance, sessionKey, channel string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	history := agent.Sessions.GetHistory(sessionKey)
+	cut := recentTurnsStart(history, agent.Context.GetKeepTurns())
+	if cut == 0 {
+		return
+	}
+	chunk := al.summarizeMessages(ctx, agent, sessionKey, channel, history[:cut], "")
+	if chunk == "" {
+		return
+	}
+
+	path := summaryTreePath(agent.Workspace, sessionKey)
+	tree := loadSummaryTree(path)
+	if len(tree.Levels) == 0 {
+		tree.Levels = [][]string{nil}
+		// Keep a summary written by another strategy as the oldest chunk.
+		if existing := agent.Sessions.GetSummary(sessionKey); existing != "" {
+			tree.Levels[0] = append(tree.Levels[0], existing)
+		}
+	}
+	tree.Levels[0] = append(tree.Levels[0], chunk)
+
+	fanout := agent.Context.GetHierarchyFanout()
+	for level := 0; level < len(tree.Levels); level++ {
+		if len(tree.Levels[level]) < fanout {
+			continue
+		}
+		merged := al.mergeSummaries(ctx, agent, sessionKey, channel, tree.Levels[level])
+		tree.Levels[level] = nil
+		if level+1 == len(tree.Levels) {
+			tree.Levels = append(tree.Levels, nil)
+		}
+		tree.Levels[level+1] = append(tree.Levels[level+1], merged)
+	}
+
+	data, err := json.MarshalIndent(tree, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(path, data, 0o600)
+	}
+	if err != nil {
+		logger.WarnCF("agent", "Failed to save summary tree",
+			map[string]any{"path": path, "error": err.Error()})
+	}
+
+	agent.Sessions.SetSummary(sessionKey, tree.render())
+	dropOldest(agent, sessionKey, cut)
+	agent.Sessions.Save(sessionKey)
+}
+
+// mergeSummaries merges consecutive summaries, oldest first, into one.
+func (al *AgentLoop) mergeSummaries(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey, channel string,
+	summaries []string,
+) string {
+	var sb strings.Builder
+	sb.WriteString("Merge these consecutive conversation summaries, oldest first, into one concise summary. " +
+		"Keep decisions, facts about the user and open tasks; drop details that were superseded.\n")
+	for i, s := range summaries {
+		fmt.Fprintf(&sb, "\n%d: %s\n", i+1, s)
+	}
+	resp, err := al.retryLLMCall(ctx, agent, sessionKey, channel, sb.String(), 3)
+	if err == nil && resp != nil && resp.Content != "" {
+		return strings.TrimSpace(resp.Content)
+	}
+	return strings.Join(summaries, " ")
+}
+
+// render writes the tree as one summary, oldest and most condensed first.
+func (t summaryTree) render() string {
+	var parts []string
+	for level := len(t.Levels) - 1; level >= 0; level-- {
+		for _, s := range t.Levels[level] {
+			label := "Recent"
+			if level > 0 {
+				label = "Earlier (condensed)"
+			}
+			parts = append(parts, fmt.Sprintf("[%s] %s", label, s))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// calibrateTokenizer corrects the agent's token counts with the prompt
+// tokens the provider reported, when the request went to the model the
+// tokenizer was chosen for.
+func (al *AgentLoop) calibrateTokenizer(
+	agent *AgentInstance,
+	usedModel string,
+	messages []providers.Message,
+	toolDefs []providers.ToolDefinition,
+	u *providers.UsageInfo,
+) {
+	if agent.Tokenizer == nil || u == nil || u.PromptTokens <= 0 {
+		return
+	}
+	primary := usedModel == agent.Model
+	if len(agent.Candidates) > 0 && usedModel == agent.Candidates[0].Model {
+		primary = true
+	}
+	if primary {
+		agent.Tokenizer.Observe(messages, toolDefs, u.PromptTokens)
+	}
+}
@@ -0,0 +1,243 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func newContextTestAgent(t *testing.T, contextCfg *config.ContextConfig) (*AgentLoop, *AgentInstance) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "gpt",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Context:           contextCfg,
+			},
+		},
+		Tools: config.ToolsConfig{
+			FetchToolResult: config.ToolConfig{Enabled: true},
+		},
+		ModelList: []config.ModelConfig{{ModelName: "gpt", Model: "openai/gpt-4o"}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	return al, al.registry.GetDefaultAgent()
+}
+
+// contextTestHistory builds n turns, each with a large tool result.
+func contextTestHistory(n int) []providers.Message {
+	var history []providers.Message
+	for i := range n {
+		callID := fmt.Sprintf("call_%d", i)
+		history = append(history,
+			providers.Message{Role: "user", Content: fmt.Sprintf("question %d", i)},
+			providers.Message{Role: "assistant", ToolCalls: []providers.ToolCall{{
+				ID: callID, Type: "function",
+				Function: &providers.FunctionCall{Name: "read_file", Arguments: `{"path":"f"}`},
+			}}},
+			providers.Message{Role: "tool", ToolCallID: callID, Content: strings.Repeat(fmt.Sprint(i), 3000)},
+			providers.Message{Role: "assistant", Content: fmt.Sprintf("answer %d", i)},
+		)
+	}
+	return history
+}
+
+func TestRecentTurnsStart(t *testing.T) {
+	history := contextTestHistory(5)
+	if got := recentTurnsStart(history, 2); got != 12 {
+		t.Errorf("recentTurnsStart(2) = %d, want 12", got)
+	}
+	if got := recentTurnsStart(history, 5); got != 0 {
+		t.Errorf("keeping every turn should not cut, got %d", got)
+	}
+}
+
+func TestCompactSession_SlidingWindow(t *testing.T) {
+	al, agent := newContextTestAgent(t, &config.ContextConfig{
+		Strategy: config.ContextStrategySlidingWindow, KeepTurns: 2,
+	})
+	agent.Sessions.SetHistory("s", contextTestHistory(5))
+
+	al.compactSession(agent, "s", "cli")
+
+	history := agent.Sessions.GetHistory("s")
+	if len(history) != 8 || history[0].Content != "question 3" {
+		t.Fatalf("history after sliding = %d messages starting %q", len(history), history[0].Content)
+	}
+	if agent.Sessions.GetSummary("s") != "" {
+		t.Error("sliding window should not summarize")
+	}
+}
+
+func TestCompactSession_SummaryRecent(t *testing.T) {
+	al, agent := newContextTestAgent(t, &config.ContextConfig{
+		Strategy: config.ContextStrategySummaryRecent, KeepTurns: 1,
+	})
+	agent.Sessions.SetHistory("s", contextTestHistory(3))
+
+	al.compactSession(agent, "s", "cli")
+
+	history := agent.Sessions.GetHistory("s")
+	if len(history) != 4 || history[0].Content != "question 2" {
+		t.Fatalf("history = %d messages starting %q, want the last turn", len(history), history[0].Content)
+	}
+	if agent.Sessions.GetSummary("s") != "Mock response" {
+		t.Errorf("summary = %q", agent.Sessions.GetSummary("s"))
+	}
+}
+
+func TestCompactSession_ToolElision(t *testing.T) {
+	al, agent := newContextTestAgent(t, &config.ContextConfig{
+		Strategy: config.ContextStrategyToolElision, KeepTurns: 1, ElideMinChars: 1000,
+	})
+	if _, ok := agent.Tools.Get("fetch_tool_result"); !ok {
+		t.Fatal("fetch_tool_result should be registered for tool_elision")
+	}
+	agent.Sessions.SetHistory("s", contextTestHistory(3))
+
+	if n := al.elideToolResults(agent, "s", 1); n != 2 {
+		t.Fatalf("elided %d results, want 2", n)
+	}
+	history := agent.Sessions.GetHistory("s")
+	if len(history) != 12 {
+		t.Fatalf("elision must keep every message, got %d", len(history))
+	}
+	if history[10].Content != strings.Repeat("2", 3000) {
+		t.Error("the current turn's tool result must not be elided")
+	}
+	stub := history[2].Content
+	if !strings.HasPrefix(stub, elisionPrefix) {
+		t.Fatalf("old tool result not replaced: %.40q", stub)
+	}
+	if n := al.elideToolResults(agent, "s", 1); n != 0 {
+		t.Errorf("stubs must not be elided again, got %d", n)
+	}
+
+	id := regexp.MustCompile(`id "(tr-[0-9a-f]+)"`).FindStringSubmatch(stub)
+	if id == nil {
+		t.Fatalf("stub has no fetch id: %s", stub)
+	}
+	fetch, _ := agent.Tools.Get("fetch_tool_result")
+	res := fetch.Execute(context.Background(), map[string]any{"id": id[1]})
+	if res.IsError || res.ForLLM != strings.Repeat("0", 3000) {
+		t.Errorf("fetched result = %.40q (error %v)", res.ForLLM, res.IsError)
+	}
+	if res := fetch.Execute(context.Background(), map[string]any{"id": "../sessions/x"}); !res.IsError {
+		t.Error("fetch must reject IDs outside the results directory")
+	}
+}
+
+func TestCompactSession_ToolElisionOverMessageThreshold(t *testing.T) {
+	al, agent := newContextTestAgent(t, &config.ContextConfig{
+		Strategy: config.ContextStrategyToolElision, KeepTurns: 1, ElideMinChars: 1000,
+	})
+	agent.SummarizeMessageThreshold = 8
+	agent.Sessions.SetHistory("s", contextTestHistory(3))
+
+	al.compactSession(agent, "s", "cli")
+
+	// Elision alone keeps all 12 messages, which would trigger compaction
+	// again on the next turn.
+	if got := len(agent.Sessions.GetHistory("s")); got != 4 {
+		t.Fatalf("history keeps %d messages, want one turn", got)
+	}
+	if agent.Sessions.GetSummary("s") != "Mock response" {
+		t.Errorf("summary = %q", agent.Sessions.GetSummary("s"))
+	}
+}
+
+func TestCompactSession_Hierarchical(t *testing.T) {
+	al, agent := newContextTestAgent(t, &config.ContextConfig{
+		Strategy: config.ContextStrategyHierarchical, KeepTurns: 1, HierarchyFanout: 2,
+	})
+
+	for range 4 {
+		history := agent.Sessions.GetHistory("s")
+		agent.Sessions.SetHistory("s", append(history, contextTestHistory(2)...))
+		al.compactSession(agent, "s", "cli")
+	}
+
+	tree := loadSummaryTree(summaryTreePath(agent.Workspace, "s"))
+	// Four chunks with fanout 2: two merges on level 0, one on level 1.
+	if len(tree.Levels) != 3 || len(tree.Levels[0]) != 0 || len(tree.Levels[1]) != 0 || len(tree.Levels[2]) != 1 {
+		t.Fatalf("tree levels = %v", tree.Levels)
+	}
+	if got := agent.Sessions.GetSummary("s"); got != "[Earlier (condensed)] Mock response" {
+		t.Errorf("summary = %q", got)
+	}
+	if got := len(agent.Sessions.GetHistory("s")); got != 4 {
+		t.Errorf("history keeps %d messages, want one turn", got)
+	}
+}
+
+func TestCompactSession_HierarchicalAfterClearAndCheckout(t *testing.T) {
+	al, agent := newContextTestAgent(t, &config.ContextConfig{
+		Strategy: config.ContextStrategyHierarchical, KeepTurns: 1, HierarchyFanout: 3,
+	})
+	// Every summary is distinct so stale chunks are recognisable.
+	agent.Provider = &modelEchoProvider{}
+	helper := testHelper{al: al}
+	msg := bus.InboundMessage{
+		Channel: "telegram", SenderID: "u1", ChatID: "c1",
+		Peer: bus.Peer{Kind: "direct", ID: "u1"},
+	}
+	route, _, err := al.resolveMessageRoute(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := resolveScopeKey(route, "")
+	send := func(text string) string {
+		m := msg
+		m.Content = text
+		return helper.executeAndGetResponse(t, context.Background(), m)
+	}
+
+	agent.Sessions.SetHistory(key, contextTestHistory(2))
+	al.compactSession(agent, key, "telegram")
+	old := agent.Sessions.GetSummary(key)
+	if old == "" {
+		t.Fatal("expected a summary before /clear")
+	}
+
+	send("/clear")
+	agent.Sessions.SetHistory(key, contextTestHistory(2))
+	al.compactSession(agent, key, "telegram")
+	summary := agent.Sessions.GetSummary(key)
+	if summary == "" || strings.Contains(summary, old) {
+		t.Fatalf("summary after /clear = %q, must not contain the cleared %q", summary, old)
+	}
+
+	send("/branch idea")
+	send("/checkout main")
+	if tree := loadSummaryTree(summaryTreePath(agent.Workspace, key)); len(tree.Levels) != 0 {
+		t.Errorf("checkout kept the previous branch's summary tree: %v", tree.Levels)
+	}
+}
+
+func TestEstimateTokens_UsesModelTokenizer(t *testing.T) {
+	al, agent := newContextTestAgent(t, &config.ContextConfig{Tokenizer: "heuristic"})
+	msgs := []providers.Message{{Role: "user", Content: strings.Repeat("a", 1000)}}
+	heuristic := al.estimateTokens(agent, msgs)
+
+	_, agent = newContextTestAgent(t, nil)
+	if agent.Tokenizer.Name() != "o200k" {
+		t.Fatalf("tokenizer = %s, want the gpt-4o tokenizer", agent.Tokenizer.Name())
+	}
+	if auto := al.estimateTokens(agent, msgs); auto >= heuristic {
+		t.Errorf("gpt-4o estimate %d should be below the conservative heuristic %d", auto, heuristic)
+	}
+
+	al.calibrateTokenizer(agent, "gpt-4o", msgs, nil, &providers.UsageInfo{PromptTokens: 1000})
+	if agent.Tokenizer.Correction() <= 1 {
+		t.Error("provider usage should calibrate the tokenizer")
+	}
+}
@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// handoff is the state of one chat that an agent handed to another.
+type handoff struct {
+	// AgentID receives the chat's messages. It is empty after a hand-back,
+	// when only the note for the usual agent is left.
+	AgentID string    `json:"agent_id,omitempty"`
+	From    string    `json:"from"`
+	Summary string    `json:"summary"`
+	Pending bool      `json:"pending"` // summary not yet shown to the receiving agent
+	Time    time.Time `json:"time"`
+}
+
+// handoffStore tracks handed-off chats by channel and chat ID. It is
+// persisted so a restart does not silently return a chat to the usual agent.
+// A nil store has no handoffs.
+type handoffStore struct {
+	path  string
+	mu    sync.Mutex
+	chats map[string]*handoff
+}
+
+func handoffStatePath(workspace string) string {
+	return filepath.Join(workspace, "state", "handoffs.json")
+}
+
+func newHandoffStore(path string) *handoffStore {
+	s := &handoffStore{path: path, chats: make(map[string]*handoff)}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &s.chats)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.WarnCF("agent", "Failed to restore handoff state",
+			map[string]any{"path": path, "error": err.Error()})
+	}
+	if s.chats == nil {
+		s.chats = make(map[string]*handoff)
+	}
+	return s
+}
+
+func handoffKey(channel, chatID string) string {
+	return strings.ToLower(channel) + ":" + chatID
+}
+
+// agentFor returns the agent a chat was handed to, or "".
+func (s *handoffStore) agentFor(channel, chatID string) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h := s.chats[handoffKey(channel, chatID)]; h != nil {
+		return h.AgentID
+	}
+	return ""
+}
+
+func (s *handoffStore) set(channel, chatID string, h handoff) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats[handoffKey(channel, chatID)] = &h
+	s.saveLocked()
+}
+
+// clear ends a handoff and returns it.
+func (s *handoffStore) clear(channel, chatID string) (handoff, bool) {
+	if s == nil {
+		return handoff{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := handoffKey(channel, chatID)
+	h := s.chats[key]
+	if h == nil {
+		return handoff{}, false
+	}
+	delete(s.chats, key)
+	s.saveLocked()
+	return *h, true
+}
+
+// takeNote returns the pending handoff note for agentID once. A note left by
+// a hand-back is delivered to whichever agent handles the chat next.
+func (s *handoffStore) takeNote(channel, chatID, agentID string) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := handoffKey(channel, chatID)
+	h := s.chats[key]
+	if h == nil || !h.Pending || (h.AgentID != "" && h.AgentID != agentID) {
+		return ""
+	}
+	if h.AgentID == "" {
+		delete(s.chats, key)
+	} else {
+		h.Pending = false
+	}
+	s.saveLocked()
+	return fmt.Sprintf("[Handoff from agent %s] Summary of the conversation so far:\n%s", h.From, h.Summary)
+}
+
+// saveLocked persists the store. Caller must hold s.mu.
+func (s *handoffStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.chats, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(s.path, data, 0o600)
+	}
+	if err != nil {
+		logger.WarnCF("agent", "Failed to save handoff state",
+			map[string]any{"path": s.path, "error": err.Error()})
+	}
+}
+
+// registerHandoffTool gives every agent the handoff tool when there is more
+// than one agent. Targets are limited by the same allowlist as spawn.
+func (al *AgentLoop) registerHandoffTool(cfg *config.Config, registry *AgentRegistry) {
+	if !cfg.Tools.IsToolEnabled("handoff") {
+		return
+	}
+	agentIDs := registry.ListAgentIDs()
+	if len(agentIDs) < 2 {
+		return
+	}
+	for _, agentID := range agentIDs {
+		agent, ok := registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		fromAgentID := agentID
+		tool := tools.NewHandoffTool(func(_ context.Context, channel, chatID, target, summary string) (string, error) {
+			return al.handoffConversation(fromAgentID, channel, chatID, target, summary)
+		})
+		tool.SetAllowlistChecker(func(targetAgentID string) bool {
+			return registry.CanSpawnSubagent(fromAgentID, targetAgentID)
+		})
+		agent.Tools.Register(tool)
+	}
+}
+
+// handoffConversation hands the chat from one agent to another, or back to
+// the chat's usual agent when target is tools.HandoffBack. It returns a
+// description of the receiving agent.
+func (al *AgentLoop) handoffConversation(from, channel, chatID, target, summary string) (string, error) {
+	if al.handoffs == nil {
+		return "", fmt.Errorf("handoffs are not available")
+	}
+
+	if target == tools.HandoffBack {
+		if al.handoffs.agentFor(channel, chatID) == "" {
+			return "", fmt.Errorf("this conversation was not handed off")
+		}
+		al.handoffs.set(channel, chatID, handoff{
+			From: from, Summary: summary, Pending: true, Time: time.Now(),
+		})
+		logger.InfoCF("agent", "Conversation handed back",
+			map[string]any{"from": from, "channel": channel, "chat_id": chatID})
+		return "the usual agent of this chat", nil
+	}
+
+	agent, ok := al.GetRegistry().GetAgent(target)
+	if !ok {
+		return "", fmt.Errorf("agent %q not found", target)
+	}
+	if agent.ID == routing.NormalizeAgentID(from) {
+		return "", fmt.Errorf("the conversation is already handled by %s", agent.ID)
+	}
+	al.handoffs.set(channel, chatID, handoff{
+		AgentID: agent.ID, From: from, Summary: summary, Pending: true, Time: time.Now(),
+	})
+	logger.InfoCF("agent", "Conversation handed off",
+		map[string]any{"from": from, "to": agent.ID, "channel": channel, "chat_id": chatID})
+
+	if agent.Name != "" {
+		return fmt.Sprintf("agent %s (%s)", agent.ID, agent.Name), nil
+	}
+	return "agent " + agent.ID, nil
+}
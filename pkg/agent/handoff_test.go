@@ -0,0 +1,148 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// recordingProvider remembers the last user message it was sent.
+type recordingProvider struct {
+	lastUser string
+}
+
+func (p *recordingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	for _, m := range messages {
+		if m.Role == "user" {
+			p.lastUser = m.Content
+		}
+	}
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (p *recordingProvider) GetDefaultModel() string {
+	return "recording-model"
+}
+
+func newHandoffTestLoop(t *testing.T) (*AgentLoop, *recordingProvider) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true, Subagents: &config.SubagentsConfig{AllowAgents: []string{"billing"}}},
+				{ID: "billing"},
+				{ID: "ops"},
+			},
+		},
+		Tools: config.ToolsConfig{Handoff: config.ToolConfig{Enabled: true}},
+	}
+	provider := &recordingProvider{}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), provider), provider
+}
+
+func TestHandoff_RoutesChatUntilBack(t *testing.T) {
+	al, provider := newHandoffTestLoop(t)
+	helper := testHelper{al: al}
+	msg := func(content string) bus.InboundMessage {
+		return bus.InboundMessage{
+			Channel: "telegram", SenderID: "user1", ChatID: "chat1", Content: content,
+			Peer: bus.Peer{Kind: "direct", ID: "user1"},
+		}
+	}
+	handoff := func(from, target string) string {
+		agent, _ := al.registry.GetAgent(from)
+		res := agent.Tools.ExecuteWithContext(context.Background(), "handoff", map[string]any{
+			"agent_id": target,
+			"summary":  "User wants a refund for order 42.",
+		}, "telegram", "chat1", nil)
+		if res.IsError {
+			return res.ForLLM
+		}
+		return ""
+	}
+
+	if errMsg := handoff("main", "ops"); !strings.Contains(errMsg, "not allowed") {
+		t.Fatalf("handoff outside the allowlist: %q", errMsg)
+	}
+	if errMsg := handoff("main", "billing"); errMsg != "" {
+		t.Fatalf("handoff failed: %s", errMsg)
+	}
+
+	_, agent, err := al.resolveMessageRoute(msg("hi"))
+	if err != nil || agent.ID != "billing" {
+		t.Fatalf("handed-off chat routed to %v (err %v), want billing", agent, err)
+	}
+
+	helper.executeAndGetResponse(t, context.Background(), msg("where is my money?"))
+	if !strings.Contains(provider.lastUser, "[Handoff from agent main]") ||
+		!strings.Contains(provider.lastUser, "order 42") ||
+		!strings.HasSuffix(provider.lastUser, "where is my money?") {
+		t.Fatalf("first message after handoff = %q", provider.lastUser)
+	}
+	helper.executeAndGetResponse(t, context.Background(), msg("thanks"))
+	if provider.lastUser != "thanks" {
+		t.Fatalf("summary should be delivered once, got %q", provider.lastUser)
+	}
+
+	// The state survives a restart.
+	reloaded := newHandoffStore(handoffStatePath(al.cfg.WorkspacePath()))
+	if got := reloaded.agentFor("telegram", "chat1"); got != "billing" {
+		t.Fatalf("restored handoff agent = %q, want billing", got)
+	}
+
+	if reply := helper.executeAndGetResponse(t, context.Background(), msg("/back")); !strings.Contains(reply, "billing") {
+		t.Fatalf("unexpected /back reply: %q", reply)
+	}
+	if _, agent, _ := al.resolveMessageRoute(msg("hi")); agent.ID != "main" {
+		t.Fatalf("after /back chat routed to %s, want main", agent.ID)
+	}
+	if reply := helper.executeAndGetResponse(t, context.Background(), msg("/back")); !strings.Contains(reply, "not been handed off") {
+		t.Fatalf("unexpected second /back reply: %q", reply)
+	}
+}
+
+func TestHandoff_HandBackCarriesSummary(t *testing.T) {
+	al, provider := newHandoffTestLoop(t)
+	billing, _ := al.registry.GetAgent("billing")
+	args := map[string]any{"agent_id": "back", "summary": "Refund issued."}
+
+	if res := billing.Tools.ExecuteWithContext(context.Background(), "handoff", args, "telegram", "chat1", nil); !res.IsError {
+		t.Fatal("hand-back of a chat that was never handed off should fail")
+	}
+
+	if _, err := al.handoffConversation("main", "telegram", "chat1", "billing", "refund"); err != nil {
+		t.Fatal(err)
+	}
+	if res := billing.Tools.ExecuteWithContext(context.Background(), "handoff", args, "telegram", "chat1", nil); res.IsError {
+		t.Fatalf("hand-back failed: %s", res.ForLLM)
+	}
+
+	helper := testHelper{al: al}
+	helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel: "telegram", SenderID: "user1", ChatID: "chat1", Content: "anything else?",
+		Peer: bus.Peer{Kind: "direct", ID: "user1"},
+	})
+	if !strings.Contains(provider.lastUser, "[Handoff from agent billing]") ||
+		!strings.Contains(provider.lastUser, "Refund issued.") {
+		t.Fatalf("usual agent did not get the hand-back summary: %q", provider.lastUser)
+	}
+	if got := al.handoffs.agentFor("telegram", "chat1"); got != "" {
+		t.Fatalf("chat still handed to %q", got)
+	}
+}
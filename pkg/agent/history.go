@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// lastTurnStart returns the index of the last user message, or -1.
+func lastTurnStart(history []providers.Message) int {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			return i
+		}
+	}
+	return -1
+}
+
+// addHistoryCommands wires /undo, /retry, /branch and /checkout to the
+// session of opts.
+func (al *AgentLoop) addHistoryCommands(rt *commands.Runtime, agent *AgentInstance, opts processOptions) {
+	if agent.Sessions == nil {
+		return
+	}
+	rt.UndoTurn = func() (int, error) {
+		return al.undoTurn(agent, opts.SessionKey), nil
+	}
+	rt.RetryTurn = func(ctx context.Context, model string) (string, error) {
+		return al.retryTurn(ctx, agent, opts, model)
+	}
+	if agent.Branches == nil {
+		return
+	}
+	rt.ListBranches = func() ([]session.BranchInfo, error) {
+		return agent.Branches.List(agent.Sessions, opts.SessionKey)
+	}
+	rt.CreateBranch = func(name string) error {
+		return agent.Branches.Create(agent.Sessions, opts.SessionKey, name)
+	}
+	rt.CheckoutBranch = func(name string) error {
+		if err := agent.Branches.Checkout(agent.Sessions, opts.SessionKey, name); err != nil {
+			return err
+		}
+		// The summary tree described the branch that was checked out of.
+		resetSummaryTree(agent.Workspace, opts.SessionKey)
+		return nil
+	}
+}
+
+// undoTurn drops the last user message and everything after it: tool
+// calls, tool results and the answer. It returns the number of messages
+// removed.
+func (al *AgentLoop) undoTurn(agent *AgentInstance, sessionKey string) int {
+	history := agent.Sessions.GetHistory(sessionKey)
+	start := lastTurnStart(history)
+	if start < 0 {
+		return 0
+	}
+	agent.Sessions.SetHistory(sessionKey, history[:start])
+	agent.Sessions.Save(sessionKey)
+	logger.InfoCF("agent", "Turn undone", map[string]any{
+		"agent_id":    agent.ID,
+		"session_key": sessionKey,
+		"removed":     len(history) - start,
+	})
+	return len(history) - start
+}
+
+// retryTurn removes the last turn and runs its user message again, on model
+// when given. It returns the new answer.
+func (al *AgentLoop) retryTurn(ctx context.Context, agent *AgentInstance, opts processOptions, model string) (string, error) {
+	history := agent.Sessions.GetHistory(opts.SessionKey)
+	start := lastTurnStart(history)
+	if start < 0 {
+		return "", fmt.Errorf("there is no message to retry")
+	}
+	userMessage := history[start].Content
+	if strings.TrimSpace(userMessage) == "" {
+		return "", fmt.Errorf("the last message has no text to retry")
+	}
+
+	if model != "" {
+		var candidates []providers.FallbackCandidate
+		ok := false
+		if agent.ResolveModel != nil {
+			candidates, ok = agent.ResolveModel(model)
+		}
+		if !ok {
+			return "", fmt.Errorf("unknown model %q (use a model_name from model_list)", model)
+		}
+		// Send the resolved model ID, not the alias, and keep the agent's
+		// fallbacks behind it.
+		opts.Model, opts.Candidates = candidates[0].Model, candidates
+	}
+
+	agent.Sessions.SetHistory(opts.SessionKey, history[:start])
+	logger.InfoCF("agent", "Retrying turn", map[string]any{
+		"agent_id":    agent.ID,
+		"session_key": opts.SessionKey,
+		"model":       model,
+	})
+
+	opts.UserMessage = userMessage
+	opts.Media = nil
+	answer, err := al.runAgentLoop(ctx, agent, opts)
+	if err != nil {
+		// Put the turn back so a failed retry loses nothing.
+		agent.Sessions.SetHistory(opts.SessionKey, history)
+		agent.Sessions.Save(opts.SessionKey)
+		return "", err
+	}
+	return answer, nil
+}
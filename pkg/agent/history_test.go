@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// modelEchoProvider answers with a counter and the model it was called with.
+type modelEchoProvider struct {
+	calls int
+}
+
+func (m *modelEchoProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.calls++
+	return &providers.LLMResponse{Content: fmt.Sprintf("answer %d from %s", m.calls, model)}, nil
+}
+
+func (m *modelEchoProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func newHistoryTestLoop(t *testing.T) (*AgentLoop, *AgentInstance, func(text string) string, func() []providers.Message) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				ModelFallbacks:    []string{"test-model"},
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: []config.ModelConfig{{ModelName: "other", Model: "openai/other-model"}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &modelEchoProvider{})
+	agent := al.registry.GetDefaultAgent()
+	helper := testHelper{al: al}
+	send := func(text string) string {
+		return helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+			Channel: "telegram", SenderID: "u1", ChatID: "c1", Content: text,
+			Peer: bus.Peer{Kind: "direct", ID: "u1"},
+		})
+	}
+	history := func() []providers.Message {
+		route, _, err := al.resolveMessageRoute(bus.InboundMessage{
+			Channel: "telegram", SenderID: "u1", ChatID: "c1",
+			Peer: bus.Peer{Kind: "direct", ID: "u1"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return agent.Sessions.GetHistory(resolveScopeKey(route, ""))
+	}
+	return al, agent, send, history
+}
+
+func TestUndoCommand(t *testing.T) {
+	_, _, send, history := newHistoryTestLoop(t)
+
+	send("first")
+	send("second")
+	if got := send("/undo"); !strings.Contains(got, "2 messages") {
+		t.Fatalf("/undo reply = %q", got)
+	}
+	h := history()
+	if len(h) != 2 || h[0].Content != "first" {
+		t.Fatalf("history after undo = %+v", h)
+	}
+	send("/undo")
+	if got := send("/undo"); got != "Nothing to undo." {
+		t.Errorf("undo on empty history = %q", got)
+	}
+}
+
+func TestRetryCommand(t *testing.T) {
+	_, agent, send, history := newHistoryTestLoop(t)
+
+	if got := send("/retry"); !strings.Contains(got, "no message to retry") {
+		t.Errorf("retry on empty history = %q", got)
+	}
+	send("question")
+	if got := send("/retry"); got != "answer 2 from test-model" {
+		t.Errorf("retry = %q", got)
+	}
+	// The model_list alias is resolved to its model ID.
+	if got := send("/retry other"); got != "answer 3 from other-model" {
+		t.Errorf("retry with model = %q", got)
+	}
+	if got := send("/retry nope"); !strings.Contains(got, `unknown model "nope"`) {
+		t.Errorf("retry with unknown model = %q", got)
+	}
+	// The agent's fallbacks stay behind the requested model.
+	candidates, ok := agent.ResolveModel("other")
+	if !ok || len(candidates) != 2 || candidates[0].Model != "other-model" || candidates[1].Model != "test-model" {
+		t.Errorf("ResolveModel(other) = %+v, %v", candidates, ok)
+	}
+
+	h := history()
+	if len(h) != 2 || h[0].Content != "question" || h[1].Content != "answer 3 from other-model" {
+		t.Fatalf("history after retries = %+v", h)
+	}
+	// The model override applies to the retried turn only.
+	if got := send("next"); got != "answer 4 from test-model" {
+		t.Errorf("next turn = %q", got)
+	}
+}
+
+func TestBranchAndCheckoutCommands(t *testing.T) {
+	al, agent, send, history := newHistoryTestLoop(t)
+
+	send("shared")
+	if got := send("/branch idea"); !strings.Contains(got, "new branch idea") {
+		t.Fatalf("/branch reply = %q", got)
+	}
+	send("only on idea")
+	if len(history()) != 4 {
+		t.Fatalf("idea branch should continue from the shared history")
+	}
+
+	if got := send("/checkout main"); got != "Switched to branch main." {
+		t.Fatalf("/checkout reply = %q", got)
+	}
+	if h := history(); len(h) != 2 || h[0].Content != "shared" {
+		t.Fatalf("main history = %+v", h)
+	}
+
+	listing := send("/branch")
+	if !strings.Contains(listing, "* main (2 messages)") || !strings.Contains(listing, "  idea (4 messages)") {
+		t.Errorf("branch listing = %q", listing)
+	}
+	if got := send("/branch idea"); !strings.Contains(got, "already exists") {
+		t.Errorf("duplicate branch = %q", got)
+	}
+	if got := send("/checkout nope"); !strings.Contains(got, "not found") {
+		t.Errorf("unknown branch = %q", got)
+	}
+
+	send("/checkout idea")
+	if h := history(); len(h) != 4 || h[2].Content != "only on idea" {
+		t.Fatalf("idea history = %+v", h)
+	}
+
+	// Branch metadata is persisted next to the session files.
+	reopened := session.NewBranchStore(filepath.Join(agent.Workspace, "sessions"))
+	route, _, _ := al.resolveMessageRoute(bus.InboundMessage{
+		Channel: "telegram", SenderID: "u1", ChatID: "c1",
+		Peer: bus.Peer{Kind: "direct", ID: "u1"},
+	})
+	infos, err := reopened.List(agent.Sessions, resolveScopeKey(route, ""))
+	if err != nil || len(infos) != 2 || !infos[0].Current || infos[0].Name != "idea" {
+		t.Errorf("reopened branches = %+v, %v", infos, err)
+	}
+}
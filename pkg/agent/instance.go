@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -15,6 +16,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
@@ -35,12 +37,25 @@ type AgentInstance struct {
 	SummarizeTokenPercent     int
 	Provider                  providers.LLMProvider
 	Sessions                  session.SessionStore
+	Branches                  *session.BranchStore
 	ContextBuilder            *ContextBuilder
 	Tools                     *tools.ToolRegistry
 	Subagents                 *config.SubagentsConfig
 	SkillsFilter              []string
+	ToolsConfig               *config.AgentToolsConfig
 	Candidates                []providers.FallbackCandidate
 
+	// Context selects the context window strategy; Tokenizer counts tokens
+	// for the agent's model and is calibrated by the provider's usage.
+	Context   *config.ContextConfig
+	Tokenizer *tokenizer.Counter
+
+	// SubagentModel and SubagentCandidates come from subagents.model. When
+	// set, subagents spawned by this agent run on them instead of the
+	// target agent's own model.
+	SubagentModel      string
+	SubagentCandidates []providers.FallbackCandidate
+
 	// Router is non-nil when model routing is configured and the light model
 	// was successfully resolved. It scores each incoming message and decides
 	// whether to route to LightCandidates or stay with Candidates.
@@ -48,6 +63,14 @@ type AgentInstance struct {
 	// LightCandidates holds the resolved provider candidates for the light model.
 	// Pre-computed at agent creation to avoid repeated model_list lookups at runtime.
 	LightCandidates []providers.FallbackCandidate
+	// TierCandidates maps each routing tier's model_name to its resolved
+	// candidates. LightCandidates is the entry for the cheapest tier.
+	TierCandidates map[string][]providers.FallbackCandidate
+
+	// ResolveModel resolves a model named at runtime (e.g. by /retry)
+	// through model_list, with the agent's fallbacks behind it. It reports
+	// false for models that are not in model_list.
+	ResolveModel func(name string) ([]providers.FallbackCandidate, bool)
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -63,8 +86,14 @@ func NewAgentInstance(
 	model := resolveAgentModel(agentCfg, defaults)
 	fallbacks := resolveAgentFallbacks(agentCfg, defaults)
 
+	var toolsCfg *config.AgentToolsConfig
+	if agentCfg != nil {
+		toolsCfg = agentCfg.Tools
+	}
 	restrict := defaults.RestrictToWorkspace
-	readRestrict := restrict && !defaults.AllowReadOutsideWorkspace
+	readRestrict := func(tool string) bool {
+		return toolRestrict(toolsCfg, restrict, tool) && !defaults.AllowReadOutsideWorkspace
+	}
 
 	// Compile path whitelist patterns from config.
 	allowReadPaths := buildAllowReadPatterns(cfg)
@@ -74,16 +103,18 @@ func NewAgentInstance(
 
 	if cfg.Tools.IsToolEnabled("read_file") {
 		maxReadFileSize := cfg.Tools.ReadFile.MaxReadFileSize
-		toolsRegistry.Register(tools.NewReadFileTool(workspace, readRestrict, maxReadFileSize, allowReadPaths))
+		toolsRegistry.Register(tools.NewReadFileTool(workspace, readRestrict("read_file"), maxReadFileSize, allowReadPaths))
 	}
 	if cfg.Tools.IsToolEnabled("write_file") {
-		toolsRegistry.Register(tools.NewWriteFileTool(workspace, restrict, allowWritePaths))
+		toolsRegistry.Register(tools.NewWriteFileTool(
+			workspace, toolRestrict(toolsCfg, restrict, "write_file"), allowWritePaths))
 	}
 	if cfg.Tools.IsToolEnabled("list_dir") {
-		toolsRegistry.Register(tools.NewListDirTool(workspace, readRestrict, allowReadPaths))
+		toolsRegistry.Register(tools.NewListDirTool(workspace, readRestrict("list_dir"), allowReadPaths))
 	}
 	if cfg.Tools.IsToolEnabled("exec") {
-		execTool, err := tools.NewExecToolWithConfig(workspace, restrict, cfg, allowReadPaths)
+		execTool, err := tools.NewExecToolWithConfig(
+			workspace, toolRestrict(toolsCfg, restrict, "exec"), cfg, allowReadPaths)
 		if err != nil {
 			log.Fatalf("Critical error: unable to initialize exec tool: %v", err)
 		}
@@ -91,10 +122,30 @@ func NewAgentInstance(
 	}
 
 	if cfg.Tools.IsToolEnabled("edit_file") {
-		toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict, allowWritePaths))
+		toolsRegistry.Register(tools.NewEditFileTool(
+			workspace, toolRestrict(toolsCfg, restrict, "edit_file"), allowWritePaths))
 	}
 	if cfg.Tools.IsToolEnabled("append_file") {
-		toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict, allowWritePaths))
+		toolsRegistry.Register(tools.NewAppendFileTool(
+			workspace, toolRestrict(toolsCfg, restrict, "append_file"), allowWritePaths))
+	}
+
+	// Tools registered later (shared, MCP) are filtered by the same policy.
+	if toolsCfg != nil {
+		policy, err := tools.NewToolPolicy(toolsCfg)
+		if err != nil {
+			log.Printf("agent %q: invalid tools policy, all tools disabled: %v", agentCfg.ID, err)
+			policy = tools.DenyAllPolicy()
+		}
+		toolsRegistry.SetPolicy(policy)
+	}
+
+	contextCfg := defaults.Context
+	if agentCfg != nil && agentCfg.Context != nil {
+		contextCfg = agentCfg.Context
+	}
+	if contextCfg.GetStrategy() == config.ContextStrategyToolElision && cfg.Tools.IsToolEnabled("fetch_tool_result") {
+		toolsRegistry.Register(tools.NewFetchToolResultTool(workspace))
 	}
 
 	sessionsDir := filepath.Join(workspace, "sessions")
@@ -105,6 +156,9 @@ func NewAgentInstance(
 		mcpDiscoveryActive && cfg.Tools.MCP.Discovery.UseBM25,
 		mcpDiscoveryActive && cfg.Tools.MCP.Discovery.UseRegex,
 	)
+	if agentCfg != nil {
+		contextBuilder.WithPrompt(agentCfg.Prompt)
+	}
 
 	agentID := routing.DefaultAgentID
 	agentName := ""
@@ -195,23 +249,40 @@ func NewAgentInstance(
 	}
 
 	candidates := providers.ResolveCandidatesWithLookup(modelCfg, defaults.Provider, resolveFromModelList)
+	resolveModel := func(name string) ([]providers.FallbackCandidate, bool) {
+		if _, ok := resolveFromModelList(name); !ok {
+			return nil, false
+		}
+		resolved := providers.ResolveCandidatesWithLookup(providers.ModelConfig{
+			Primary:   name,
+			Fallbacks: fallbacks,
+		}, defaults.Provider, resolveFromModelList)
+		return resolved, len(resolved) > 0
+	}
 
-	// Model routing setup: pre-resolve light model candidates at creation time
+	var subagentModel string
+	var subagentCandidates []providers.FallbackCandidate
+	if subagents != nil && subagents.Model != nil && strings.TrimSpace(subagents.Model.Primary) != "" {
+		subagentModel = strings.TrimSpace(subagents.Model.Primary)
+		subagentCandidates = providers.ResolveCandidatesWithLookup(providers.ModelConfig{
+			Primary:   subagentModel,
+			Fallbacks: subagents.Model.Fallbacks,
+		}, defaults.Provider, resolveFromModelList)
+	}
+
+	// Model routing setup: pre-resolve tier candidates at creation time
 	// to avoid repeated model_list lookups on every incoming message.
 	var router *routing.Router
 	var lightCandidates []providers.FallbackCandidate
-	if rc := defaults.Routing; rc != nil && rc.Enabled && rc.LightModel != "" {
-		lightModelCfg := providers.ModelConfig{Primary: rc.LightModel}
-		resolved := providers.ResolveCandidatesWithLookup(lightModelCfg, defaults.Provider, resolveFromModelList)
-		if len(resolved) > 0 {
-			router = routing.New(routing.RouterConfig{
-				LightModel: rc.LightModel,
-				Threshold:  rc.Threshold,
-			})
-			lightCandidates = resolved
-		} else {
-			log.Printf("routing: light_model %q not found in model_list — routing disabled for agent %q",
-				rc.LightModel, agentID)
+	var tierCandidates map[string][]providers.FallbackCandidate
+	if rc := defaults.Routing; rc != nil && rc.Enabled {
+		resolve := func(name string) []providers.FallbackCandidate {
+			return providers.ResolveCandidatesWithLookup(
+				providers.ModelConfig{Primary: name}, defaults.Provider, resolveFromModelList)
+		}
+		router, tierCandidates = buildRouter(rc, agentID, provider, resolve)
+		if router != nil {
+			lightCandidates = tierCandidates[router.LightModel()]
 		}
 	}
 
@@ -230,16 +301,37 @@ func NewAgentInstance(
 		SummarizeTokenPercent:     summarizeTokenPercent,
 		Provider:                  provider,
 		Sessions:                  sessions,
+		Branches:                  session.NewBranchStore(sessionsDir),
 		ContextBuilder:            contextBuilder,
 		Tools:                     toolsRegistry,
 		Subagents:                 subagents,
 		SkillsFilter:              skillsFilter,
+		ToolsConfig:               toolsCfg,
 		Candidates:                candidates,
+		Context:                   contextCfg,
+		Tokenizer:                 newAgentTokenizer(contextCfg, model, candidates),
+		SubagentModel:             subagentModel,
+		SubagentCandidates:        subagentCandidates,
 		Router:                    router,
 		LightCandidates:           lightCandidates,
+		TierCandidates:            tierCandidates,
+		ResolveModel:              resolveModel,
 	}
 }
 
+// newAgentTokenizer picks the tokenizer for the agent's primary model,
+// using the resolved model ID rather than the model_list alias.
+func newAgentTokenizer(
+	contextCfg *config.ContextConfig,
+	model string,
+	candidates []providers.FallbackCandidate,
+) *tokenizer.Counter {
+	if len(candidates) > 0 {
+		model = candidates[0].Model
+	}
+	return tokenizer.NewCounter(tokenizer.New(contextCfg.GetTokenizer(), model))
+}
+
 // resolveAgentWorkspace determines the workspace directory for an agent.
 func resolveAgentWorkspace(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) string {
 	if agentCfg != nil && strings.TrimSpace(agentCfg.Workspace) != "" {
@@ -351,3 +443,13 @@ func expandHome(path string) string {
 	}
 	return path
 }
+
+// toolRestrict returns whether tool is confined to the workspace for this
+// agent: restrict_to_workspace, unless the agent lists the tool in
+// tools.unrestricted.
+func toolRestrict(toolsCfg *config.AgentToolsConfig, restrict bool, tool string) bool {
+	if !restrict || toolsCfg == nil {
+		return restrict
+	}
+	return !slices.Contains(toolsCfg.Unrestricted, tool)
+}
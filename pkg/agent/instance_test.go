@@ -9,6 +9,7 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
 func TestNewAgentInstance_UsesDefaultsTemperatureAndMaxTokens(t *testing.T) {
@@ -246,3 +247,55 @@ func TestNewAgentInstance_AllowsMediaTempDirForReadListAndExec(t *testing.T) {
 		t.Fatalf("exec output missing media content: %s", execResult.ForLLM)
 	}
 }
+
+func TestNewAgentInstance_ToolPolicies(t *testing.T) {
+	outside := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:           t.TempDir(),
+				ModelName:           "test-model",
+				RestrictToWorkspace: true,
+			},
+		},
+		Tools: config.ToolsConfig{
+			ReadFile: config.ReadFileToolConfig{Enabled: true},
+			Exec: config.ExecConfig{
+				ToolConfig:  config.ToolConfig{Enabled: true},
+				AllowRemote: true,
+			},
+		},
+	}
+	family := &config.AgentConfig{ID: "family", Tools: &config.AgentToolsConfig{Deny: []string{"exec"}}}
+	ops := &config.AgentConfig{ID: "ops", Tools: &config.AgentToolsConfig{Unrestricted: []string{"exec"}}}
+	broken := &config.AgentConfig{ID: "broken", Tools: &config.AgentToolsConfig{Allow: []string{"[read"}}}
+
+	runExec := func(agent *AgentInstance) *tools.ToolResult {
+		return agent.Tools.Execute(context.Background(), "exec", map[string]any{
+			"command":     "pwd",
+			"working_dir": outside,
+		})
+	}
+
+	familyAgent := NewAgentInstance(family, &cfg.Agents.Defaults, cfg, &mockProvider{})
+	for _, def := range familyAgent.Tools.ToProviderDefs() {
+		if def.Function.Name == "exec" {
+			t.Error("family agent is offered exec")
+		}
+	}
+	if res := runExec(familyAgent); !res.IsError || !strings.Contains(res.ForLLM, "permission denied") {
+		t.Errorf("family exec = %q, want permission denied", res.ForLLM)
+	}
+
+	if res := runExec(NewAgentInstance(ops, &cfg.Agents.Defaults, cfg, &mockProvider{})); res.IsError {
+		t.Errorf("ops exec outside workspace failed: %s", res.ForLLM)
+	}
+	if res := runExec(NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, &mockProvider{})); !res.IsError {
+		t.Error("default agent ran exec outside the workspace")
+	}
+
+	brokenAgent := NewAgentInstance(broken, &cfg.Agents.Defaults, cfg, &mockProvider{})
+	if defs := brokenAgent.Tools.ToProviderDefs(); len(defs) != 0 {
+		t.Errorf("invalid policy should disable all tools, got %d", len(defs))
+	}
+}
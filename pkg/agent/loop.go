@@ -17,7 +17,6 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
-	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
@@ -28,9 +27,12 @@ import (
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/state"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/usage"
 	"github.com/sipeed/picoclaw/pkg/utils"
 	"github.com/sipeed/picoclaw/pkg/voice"
 )
@@ -48,6 +50,12 @@ type AgentLoop struct {
 	transcriber    voice.Transcriber
 	cmdRegistry    *commands.Registry
 	mcp            mcpRuntime
+	usage          *usage.Ledger
+	decisions      *routing.DecisionLog
+	handoffs       *handoffStore
+	health         *providers.HealthStats
+	cooldown       *providers.CooldownTracker
+	prober         *providers.HealthProber
 	mu             sync.RWMutex
 	// Track active requests for safe provider cleanup
 	activeRequests sync.WaitGroup
@@ -58,12 +66,17 @@ type processOptions struct {
 	SessionKey      string   // Session identifier for history/context
 	Channel         string   // Target channel for tool execution
 	ChatID          string   // Target chat ID for tool execution
+	SenderID        string   // User whose message started the turn
 	UserMessage     string   // User message content (may include prefix)
 	Media           []string // media:// refs from inbound message
 	DefaultResponse string   // Response when LLM returns empty
 	EnableSummary   bool     // Whether to trigger summarization
 	SendResponse    bool     // Whether to send response via bus
 	NoHistory       bool     // If true, don't load session history (for heartbeat)
+	Model           string   // If set, use this model instead of the agent's and skip routing (for /retry)
+
+	// Candidates are Model's resolved candidates, tried in order.
+	Candidates []providers.FallbackCandidate
 }
 
 const (
@@ -74,6 +87,7 @@ const (
 	metadataKeyTeamID         = "team_id"
 	metadataKeyParentPeerKind = "parent_peer_kind"
 	metadataKeyParentPeerID   = "parent_peer_id"
+	metadataKeySenderRoles    = "sender_roles"
 )
 
 func NewAgentLoop(
@@ -84,17 +98,27 @@ func NewAgentLoop(
 	registry := NewAgentRegistry(cfg, provider)
 
 	// Register shared tools to all agents
-	registerSharedTools(cfg, msgBus, registry, provider)
+	registerSharedTools(cfg, msgBus, registry)
 
-	// Set up shared fallback chain
-	cooldown := providers.NewCooldownTracker()
+	// Set up shared fallback chain. Cooldowns are persisted so a restart
+	// does not immediately retry a provider that just failed with billing
+	// or auth errors.
+	cooldown := providers.NewPersistentCooldownTracker(providers.CooldownStatePath(cfg.WorkspacePath()))
 	fallbackChain := providers.NewFallbackChain(cooldown)
+	health := providers.NewHealthStats()
+	fallbackChain.SetSelection(cfg.Agents.Defaults.ModelSelection.GetStrategy(), health)
 
 	// Create state manager using default agent's workspace for channel recording
 	defaultAgent := registry.GetDefaultAgent()
 	var stateManager *state.Manager
+	var ledger *usage.Ledger
+	var decisions *routing.DecisionLog
 	if defaultAgent != nil {
 		stateManager = state.NewManager(defaultAgent.Workspace)
+		// One ledger for all agents; records carry the agent ID. It lives in
+		// the default workspace so `picoclaw usage` can find it.
+		ledger = usage.NewLedger(filepath.Join(cfg.WorkspacePath(), "usage"), usage.NewPriceTable(cfg.ModelList))
+		decisions = routing.NewDecisionLog(filepath.Join(cfg.WorkspacePath(), "routing"))
 	}
 
 	al := &AgentLoop{
@@ -105,17 +129,25 @@ func NewAgentLoop(
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
 		cmdRegistry: commands.NewRegistry(commands.BuiltinDefinitions()),
+		usage:       ledger,
+		decisions:   decisions,
+		handoffs:    newHandoffStore(handoffStatePath(cfg.WorkspacePath())),
+		health:      health,
+		cooldown:    cooldown,
 	}
+	al.registerSubagentTools(cfg, registry)
+	al.registerUsageHooks(registry)
+	al.registerHandoffTool(cfg, registry)
 
 	return al
 }
 
-// registerSharedTools registers tools that are shared across all agents (web, message, spawn).
+// registerSharedTools registers tools that are shared across all agents (web, message, skills).
+// Tools that need the loop itself (spawn, handoff) are registered by AgentLoop methods.
 func registerSharedTools(
 	cfg *config.Config,
 	msgBus *bus.MessageBus,
 	registry *AgentRegistry,
-	provider providers.LLMProvider,
 ) {
 	allowReadPaths := buildAllowReadPatterns(cfg)
 
@@ -190,11 +222,17 @@ func registerSharedTools(
 			agent.Tools.Register(messageTool)
 		}
 
+		// Ask user tool (blocks until the user answers through the bus)
+		if cfg.Tools.IsToolEnabled("ask_user") {
+			timeout := time.Duration(cfg.Tools.AskUser.TimeoutSeconds) * time.Second
+			agent.Tools.Register(tools.NewAskUserTool(msgBus, timeout))
+		}
+
 		// Send file tool (outbound media via MediaStore — store injected later by SetMediaStore)
 		if cfg.Tools.IsToolEnabled("send_file") {
 			sendFileTool := tools.NewSendFileTool(
 				agent.Workspace,
-				cfg.Agents.Defaults.RestrictToWorkspace,
+				toolRestrict(agent.ToolsConfig, cfg.Agents.Defaults.RestrictToWorkspace, "send_file"),
 				cfg.Agents.Defaults.GetMaxMediaSize(),
 				nil,
 				allowReadPaths,
@@ -224,28 +262,6 @@ func registerSharedTools(
 				agent.Tools.Register(tools.NewInstallSkillTool(registryMgr, agent.Workspace))
 			}
 		}
-
-		// Spawn and spawn_status tools share a SubagentManager.
-		// Construct it when either tool is enabled (both require subagent).
-		spawnEnabled := cfg.Tools.IsToolEnabled("spawn")
-		spawnStatusEnabled := cfg.Tools.IsToolEnabled("spawn_status")
-		if (spawnEnabled || spawnStatusEnabled) && cfg.Tools.IsToolEnabled("subagent") {
-			subagentManager := tools.NewSubagentManager(provider, agent.Model, agent.Workspace)
-			subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
-			if spawnEnabled {
-				spawnTool := tools.NewSpawnTool(subagentManager)
-				currentAgentID := agentID
-				spawnTool.SetAllowlistChecker(func(targetAgentID string) bool {
-					return registry.CanSpawnSubagent(currentAgentID, targetAgentID)
-				})
-				agent.Tools.Register(spawnTool)
-			}
-			if spawnStatusEnabled {
-				agent.Tools.Register(tools.NewSpawnStatusTool(subagentManager))
-			}
-		} else if (spawnEnabled || spawnStatusEnabled) && !cfg.Tools.IsToolEnabled("subagent") {
-			logger.WarnCF("agent", "spawn/spawn_status tools require subagent to be enabled", nil)
-		}
 	}
 }
 
@@ -256,6 +272,9 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 		return err
 	}
 
+	al.startHealthProber()
+	defer al.stopHealthProber()
+
 	for al.running.Load() {
 		select {
 		case <-ctx.Done():
@@ -329,6 +348,7 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 
 func (al *AgentLoop) Stop() {
 	al.running.Store(false)
+	al.stopHealthProber()
 }
 
 // Close releases resources held by agent session stores. Call after Stop.
@@ -414,7 +434,10 @@ func (al *AgentLoop) ReloadProviderAndConfig(
 	}
 
 	// Ensure shared tools are re-registered on the new registry
-	registerSharedTools(cfg, al.bus, registry, provider)
+	registerSharedTools(cfg, al.bus, registry)
+	al.registerSubagentTools(cfg, registry)
+	al.registerUsageHooks(registry)
+	al.registerHandoffTool(cfg, registry)
 
 	// Atomically swap the config and registry under write lock
 	// This ensures readers see a consistent pair
@@ -425,11 +448,23 @@ func (al *AgentLoop) ReloadProviderAndConfig(
 	al.cfg = cfg
 	al.registry = registry
 
-	// Also update fallback chain with new config
-	al.fallback = providers.NewFallbackChain(providers.NewCooldownTracker())
+	// Also update fallback chain with new config. Cooldown state is kept
+	// across reloads.
+	al.fallback = providers.NewFallbackChain(al.cooldown)
+	al.fallback.SetSelection(cfg.Agents.Defaults.ModelSelection.GetStrategy(), al.health)
 
 	al.mu.Unlock()
 
+	// Restart the prober so it picks up the new candidates and settings.
+	if al.running.Load() {
+		al.stopHealthProber()
+		al.startHealthProber()
+	}
+
+	if al.usage != nil {
+		al.usage.SetPrices(usage.NewPriceTable(cfg.ModelList))
+	}
+
 	// Close old provider after releasing the lock
 	// This prevents blocking readers while closing
 	if oldProvider, ok := extractProvider(oldRegistry); ok {
@@ -718,6 +753,11 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	if routeErr != nil {
 		return "", routeErr
 	}
+	if route.Content != "" {
+		// A content binding such as "@coder ..." addressed the agent; it
+		// sees the message without the command.
+		msg.Content = route.Content
+	}
 
 	// Reset message-tool state for this round so we don't skip publishing due to a previous round.
 	if tool, ok := agent.Tools.Get("message"); ok {
@@ -744,6 +784,7 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		SessionKey:      sessionKey,
 		Channel:         msg.Channel,
 		ChatID:          msg.ChatID,
+		SenderID:        msg.SenderID,
 		UserMessage:     msg.Content,
 		Media:           msg.Media,
 		DefaultResponse: defaultResponse,
@@ -757,18 +798,35 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		return response, nil
 	}
 
+	// The first message after a handoff carries the previous agent's summary.
+	if note := al.handoffs.takeNote(msg.Channel, msg.ChatID, agent.ID); note != "" {
+		opts.UserMessage = note + "\n\n[User message]\n" + opts.UserMessage
+	}
+
 	return al.runAgentLoop(ctx, agent, opts)
 }
 
 func (al *AgentLoop) resolveMessageRoute(msg bus.InboundMessage) (routing.ResolvedRoute, *AgentInstance, error) {
 	registry := al.GetRegistry()
+	accountID := msg.AccountID
+	if accountID == "" {
+		accountID = inboundMetadata(msg, metadataKeyAccountID)
+	}
 	route := registry.ResolveRoute(routing.RouteInput{
-		Channel:    msg.Channel,
-		AccountID:  inboundMetadata(msg, metadataKeyAccountID),
+		Channel:    channels.ChannelKind(msg.Channel, msg.AccountID),
+		AccountID:  accountID,
 		Peer:       extractPeer(msg),
 		ParentPeer: extractParentPeer(msg),
+		ThreadID:   msg.ThreadID,
 		GuildID:    inboundMetadata(msg, metadataKeyGuildID),
 		TeamID:     inboundMetadata(msg, metadataKeyTeamID),
+
+		Content:     msg.Content,
+		SenderIDs:   senderIdentities(msg),
+		SenderRoles: splitMetadataList(inboundMetadata(msg, metadataKeySenderRoles)),
+		MediaType:   al.inboundMediaType(msg),
+
+		HandoffAgentID: al.handoffs.agentFor(msg.Channel, msg.ChatID),
 	})
 
 	agent, ok := registry.GetAgent(route.AgentID)
@@ -1013,7 +1071,20 @@ func (al *AgentLoop) runLLMIteration(
 	// selectCandidates evaluates routing once and the decision is sticky for
 	// all tool-follow-up iterations within the same turn so that a multi-step
 	// tool chain doesn't switch models mid-way through.
-	activeCandidates, activeModel := al.selectCandidates(agent, opts.UserMessage, messages)
+	// An explicit model (from /retry) bypasses routing.
+	activeCandidates, activeModel := opts.Candidates, opts.Model
+	var decision *routing.Decision
+	if activeModel == "" {
+		activeCandidates, activeModel, decision = al.selectCandidates(
+			withUsageScope(ctx, opts.SessionKey, opts.Channel), agent, opts.SessionKey, opts.UserMessage, messages,
+		)
+	}
+	activeCandidates, activeModel, refused := al.applyBudget(agent, activeCandidates, activeModel)
+	if refused {
+		return budgetExceededReply, 0, nil
+	}
+	turn := al.beginRoutingTurn(agent, opts.SessionKey, opts.UserMessage, decision)
+	defer al.logRoutingTurn(turn)
 
 	for iteration < agent.MaxIterations {
 		iteration++
@@ -1069,6 +1140,7 @@ func (al *AgentLoop) runLLMIteration(
 			}
 		}
 
+		usedModel := activeModel
 		callLLM := func() (*providers.LLMResponse, error) {
 			al.activeRequests.Add(1)
 			defer al.activeRequests.Done()
@@ -1084,6 +1156,7 @@ func (al *AgentLoop) runLLMIteration(
 				if fbErr != nil {
 					return nil, fbErr
 				}
+				usedModel = fbResult.Model
 				if fbResult.Provider != "" && len(fbResult.Attempts) > 0 {
 					logger.InfoCF(
 						"agent",
@@ -1177,6 +1250,14 @@ func (al *AgentLoop) runLLMIteration(
 			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
 
+		kind := usage.KindChat
+		if iteration > 1 {
+			kind = usage.KindToolIteration
+		}
+		cost := al.recordUsage(agent, opts.SessionKey, opts.Channel, usedModel, kind, response.Usage)
+		turn.addUsage(response.Usage, cost)
+		al.calibrateTokenizer(agent, usedModel, messages, providerToolDefs, response.Usage)
+
 		go al.handleReasoning(
 			ctx,
 			response.Reasoning,
@@ -1329,7 +1410,7 @@ func (al *AgentLoop) runLLMIteration(
 				}
 
 				toolResult := agent.Tools.ExecuteWithContext(
-					ctx,
+					tools.WithToolSender(ctx, opts.SenderID),
 					tc.Name,
 					tc.Arguments,
 					opts.Channel,
@@ -1410,48 +1491,145 @@ func (al *AgentLoop) runLLMIteration(
 	return finalContent, iteration, nil
 }
 
+// recordUsage logs the token usage of one LLM response, appends it to the
+// usage ledger and, for conversation calls, adds it to the session's
+// prompt-cache totals when the session store supports it. It returns the
+// priced cost of the response.
+func (al *AgentLoop) recordUsage(
+	agent *AgentInstance,
+	sessionKey, channel, model, kind string,
+	u *providers.UsageInfo,
+) float64 {
+	if u == nil {
+		return 0
+	}
+	logger.DebugCF("agent", "LLM usage",
+		map[string]any{
+			"agent_id":           agent.ID,
+			"model":              model,
+			"kind":               kind,
+			"prompt_tokens":      u.PromptTokens,
+			"completion_tokens":  u.CompletionTokens,
+			"cache_read_tokens":  u.CacheReadTokens,
+			"cache_write_tokens": u.CacheWriteTokens,
+		})
+	var cost float64
+	if al.usage != nil {
+		cost = al.usage.Record(usage.Record{
+			AgentID:    agent.ID,
+			SessionKey: sessionKey,
+			Channel:    channel,
+			Model:      model,
+			Kind:       kind,
+		}, u).Cost
+	}
+	if sessionKey == "" || (kind != usage.KindChat && kind != usage.KindToolIteration) {
+		return cost
+	}
+	if rec, ok := agent.Sessions.(session.CacheStatsRecorder); ok {
+		rec.RecordCacheUsage(sessionKey, u)
+	}
+	return cost
+}
+
+// budgetExceededReply is sent instead of an LLM answer when the monthly
+// usage budget is exhausted and no cheaper model is available.
+const budgetExceededReply = "The monthly usage budget has been reached. " +
+	"Please try again next month or ask the administrator to raise usage.monthly_budget."
+
+// applyBudget enforces usage.monthly_budget. Once the month's cost reaches
+// the cap, the turn is moved to the agent's light model or, when the action
+// is "refuse" or no light model exists, refused entirely.
+func (al *AgentLoop) applyBudget(
+	agent *AgentInstance,
+	candidates []providers.FallbackCandidate,
+	model string,
+) ([]providers.FallbackCandidate, string, bool) {
+	cfg := al.GetConfig()
+	if al.usage == nil || cfg == nil || !al.usage.OverBudget(cfg.Usage.MonthlyBudget) {
+		return candidates, model, false
+	}
+
+	if cfg.Usage.GetBudgetAction() == config.BudgetActionDowngrade &&
+		agent.Router != nil && len(agent.LightCandidates) > 0 {
+		logger.WarnCF("agent", "Monthly budget exceeded, using light model",
+			map[string]any{
+				"agent_id":    agent.ID,
+				"light_model": agent.Router.LightModel(),
+				"budget":      cfg.Usage.MonthlyBudget,
+			})
+		return agent.LightCandidates, agent.Router.LightModel(), false
+	}
+
+	logger.WarnCF("agent", "Monthly budget exceeded, refusing LLM call",
+		map[string]any{"agent_id": agent.ID, "budget": cfg.Usage.MonthlyBudget})
+	return candidates, model, true
+}
+
 // selectCandidates returns the model candidates and resolved model name to use
-// for a conversation turn. When model routing is configured and the incoming
-// message scores below the complexity threshold, it returns the light model
-// candidates instead of the primary ones.
+// for a conversation turn. When model routing is configured and a lighter
+// tier accepts the incoming message (score within its band, capabilities
+// covered), it returns that tier's candidates instead of the primary ones.
+// The decision is recorded in the session metadata for later tuning and
+// returned so the turn can be added to the decision log; it is nil when the
+// agent has no router.
 //
 // The returned (candidates, model) pair is used for all LLM calls within one
 // turn — tool follow-up iterations use the same tier as the initial call so
 // that a multi-step tool chain doesn't switch models mid-way.
 func (al *AgentLoop) selectCandidates(
+	ctx context.Context,
 	agent *AgentInstance,
+	sessionKey string,
 	userMsg string,
 	history []providers.Message,
-) (candidates []providers.FallbackCandidate, model string) {
-	if agent.Router == nil || len(agent.LightCandidates) == 0 {
-		return agent.Candidates, agent.Model
+) (candidates []providers.FallbackCandidate, model string, decision *routing.Decision) {
+	if agent.Router == nil || len(agent.TierCandidates) == 0 {
+		return agent.Candidates, agent.Model, nil
+	}
+	if al.decisions != nil {
+		al.decisions.Observe(sessionKey, userMsg)
+	}
+
+	d := agent.Router.Route(ctx, userMsg, history, agent.Model)
+	if rec, ok := agent.Sessions.(session.RoutingRecorder); ok && sessionKey != "" {
+		rec.RecordRouting(sessionKey, session.RoutingDecision{
+			Time:       time.Now(),
+			Tier:       d.Tier,
+			Model:      d.Model,
+			Score:      d.Score,
+			Classifier: d.Classifier,
+			Required:   d.Required,
+		})
 	}
 
-	_, usedLight, score := agent.Router.SelectModel(userMsg, history, agent.Model)
-	if !usedLight {
+	tierCandidates, ok := agent.TierCandidates[d.Model]
+	if d.Primary || !ok {
 		logger.DebugCF("agent", "Model routing: primary model selected",
 			map[string]any{
-				"agent_id":  agent.ID,
-				"score":     score,
-				"threshold": agent.Router.Threshold(),
+				"agent_id":   agent.ID,
+				"score":      d.Score,
+				"classifier": d.Classifier,
+				"required":   d.Required,
 			})
-		return agent.Candidates, agent.Model
+		return agent.Candidates, agent.Model, &d
 	}
 
-	logger.InfoCF("agent", "Model routing: light model selected",
+	logger.InfoCF("agent", "Model routing: tier selected",
 		map[string]any{
-			"agent_id":    agent.ID,
-			"light_model": agent.Router.LightModel(),
-			"score":       score,
-			"threshold":   agent.Router.Threshold(),
+			"agent_id":   agent.ID,
+			"tier":       d.Tier,
+			"model":      d.Model,
+			"score":      d.Score,
+			"classifier": d.Classifier,
 		})
-	return agent.LightCandidates, agent.Router.LightModel()
+	return tierCandidates, d.Model, &d
 }
 
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
 func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, chatID string) {
 	newHistory := agent.Sessions.GetHistory(sessionKey)
-	tokenEstimate := al.estimateTokens(newHistory)
+	tokenEstimate := al.estimateTokens(agent, newHistory)
 	threshold := agent.ContextWindow * agent.SummarizeTokenPercent / 100
 
 	if len(newHistory) > agent.SummarizeMessageThreshold || tokenEstimate > threshold {
@@ -1460,7 +1638,7 @@ func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, c
 			go func() {
 				defer al.summarizing.Delete(summarizeKey)
 				logger.Debug("Memory threshold reached. Optimizing conversation history...")
-				al.summarizeSession(agent, sessionKey)
+				al.compactSession(agent, sessionKey, channel)
 			}()
 		}
 	}
@@ -1469,6 +1647,13 @@ func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, c
 // forceCompression aggressively reduces context when the limit is hit.
 // It drops the oldest 50% of messages (keeping system prompt and last user message).
 func (al *AgentLoop) forceCompression(agent *AgentInstance, sessionKey string) {
+	// With tool elision, first try eliding every large tool result outside
+	// the current turn; that usually frees enough without losing messages.
+	if agent.Context.GetStrategy() == config.ContextStrategyToolElision &&
+		al.elideToolResults(agent, sessionKey, 1) > 0 {
+		return
+	}
+
 	history := agent.Sessions.GetHistory(sessionKey)
 	if len(history) <= 4 {
 		return
@@ -1608,7 +1793,7 @@ func formatToolsForLog(toolDefs []providers.ToolDefinition) string {
 }
 
 // summarizeSession summarizes the conversation history for a session.
-func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
+func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey, channel string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
 
@@ -1621,7 +1806,25 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	}
 
 	toSummarize := history[:len(history)-4]
+	finalSummary := al.summarizeMessages(ctx, agent, sessionKey, channel, toSummarize, summary)
+
+	if finalSummary != "" {
+		agent.Sessions.SetSummary(sessionKey, finalSummary)
+		agent.Sessions.TruncateHistory(sessionKey, 4)
+		agent.Sessions.Save(sessionKey)
+	}
+}
 
+// summarizeMessages summarizes the user and assistant messages of a history
+// segment, folding in an existing summary. It returns "" when there is
+// nothing to summarize.
+func (al *AgentLoop) summarizeMessages(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey, channel string,
+	toSummarize []providers.Message,
+	summary string,
+) string {
 	// Oversized Message Guard
 	maxMessageTokens := agent.ContextWindow / 2
 	validMessages := make([]providers.Message, 0)
@@ -1631,7 +1834,7 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 		if m.Role != "user" && m.Role != "assistant" {
 			continue
 		}
-		msgTokens := len(m.Content) / 2
+		msgTokens := al.countTokens(agent, m.Content)
 		if msgTokens > maxMessageTokens {
 			omitted = true
 			continue
@@ -1640,14 +1843,12 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	}
 
 	if len(validMessages) == 0 {
-		return
+		return ""
 	}
 
 	const (
 		maxSummarizationMessages = 10
 		llmMaxRetries            = 3
-		llmTemperature           = 0.3
-		fallbackMaxContentLength = 200
 	)
 
 	// Multi-Part Summarization
@@ -1660,8 +1861,8 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 		part1 := validMessages[:mid]
 		part2 := validMessages[mid:]
 
-		s1, _ := al.summarizeBatch(ctx, agent, part1, "")
-		s2, _ := al.summarizeBatch(ctx, agent, part2, "")
+		s1, _ := al.summarizeBatch(ctx, agent, sessionKey, channel, part1, "")
+		s2, _ := al.summarizeBatch(ctx, agent, sessionKey, channel, part2, "")
 
 		mergePrompt := fmt.Sprintf(
 			"Merge these two conversation summaries into one cohesive summary:\n\n1: %s\n\n2: %s",
@@ -1669,25 +1870,20 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 			s2,
 		)
 
-		resp, err := al.retryLLMCall(ctx, agent, mergePrompt, llmMaxRetries)
+		resp, err := al.retryLLMCall(ctx, agent, sessionKey, channel, mergePrompt, llmMaxRetries)
 		if err == nil && resp.Content != "" {
 			finalSummary = resp.Content
 		} else {
 			finalSummary = s1 + " " + s2
 		}
 	} else {
-		finalSummary, _ = al.summarizeBatch(ctx, agent, validMessages, summary)
+		finalSummary, _ = al.summarizeBatch(ctx, agent, sessionKey, channel, validMessages, summary)
 	}
 
 	if omitted && finalSummary != "" {
 		finalSummary += "\n[Note: Some oversized messages were omitted from this summary for efficiency.]"
 	}
-
-	if finalSummary != "" {
-		agent.Sessions.SetSummary(sessionKey, finalSummary)
-		agent.Sessions.TruncateHistory(sessionKey, 4)
-		agent.Sessions.Save(sessionKey)
-	}
+	return finalSummary
 }
 
 // findNearestUserMessage finds the nearest user message to the given index.
@@ -1715,10 +1911,12 @@ func (al *AgentLoop) findNearestUserMessage(messages []providers.Message, mid in
 	return originalMid
 }
 
-// retryLLMCall calls the LLM with retry logic.
+// retryLLMCall calls the LLM with retry logic. Usage is recorded as
+// summarization for sessionKey on channel.
 func (al *AgentLoop) retryLLMCall(
 	ctx context.Context,
 	agent *AgentInstance,
+	sessionKey, channel string,
 	prompt string,
 	maxRetries int,
 ) (*providers.LLMResponse, error) {
@@ -1745,6 +1943,9 @@ func (al *AgentLoop) retryLLMCall(
 				},
 			)
 		}()
+		if err == nil && resp != nil {
+			al.recordUsage(agent, sessionKey, channel, agent.Model, usage.KindSummarization, resp.Usage)
+		}
 
 		if err == nil && resp != nil && resp.Content != "" {
 			return resp, nil
@@ -1761,6 +1962,7 @@ func (al *AgentLoop) retryLLMCall(
 func (al *AgentLoop) summarizeBatch(
 	ctx context.Context,
 	agent *AgentInstance,
+	sessionKey, channel string,
 	batch []providers.Message,
 	existingSummary string,
 ) (string, error) {
@@ -1786,7 +1988,7 @@ func (al *AgentLoop) summarizeBatch(
 	}
 	prompt := sb.String()
 
-	response, err := al.retryLLMCall(ctx, agent, prompt, llmMaxRetries)
+	response, err := al.retryLLMCall(ctx, agent, sessionKey, channel, prompt, llmMaxRetries)
 	if err == nil && response.Content != "" {
 		return strings.TrimSpace(response.Content), nil
 	}
@@ -1822,16 +2024,21 @@ func (al *AgentLoop) summarizeBatch(
 	return fallback.String(), nil
 }
 
-// estimateTokens estimates the number of tokens in a message list.
-// Uses a safe heuristic of 2.5 characters per token to account for CJK and other
-// overheads better than the previous 3 chars/token.
-func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
-	totalChars := 0
-	for _, m := range messages {
-		totalChars += utf8.RuneCountInString(m.Content)
+// estimateTokens counts the tokens of a message list with the agent's
+// tokenizer.
+func (al *AgentLoop) estimateTokens(agent *AgentInstance, messages []providers.Message) int {
+	if agent.Tokenizer == nil {
+		return tokenizer.CountMessages(tokenizer.New(tokenizer.Heuristic, ""), messages)
 	}
-	// 2.5 chars per token = totalChars * 2 / 5
-	return totalChars * 2 / 5
+	return agent.Tokenizer.CountMessages(messages)
+}
+
+// countTokens counts the tokens of one text with the agent's tokenizer.
+func (al *AgentLoop) countTokens(agent *AgentInstance, text string) int {
+	if agent.Tokenizer == nil {
+		return tokenizer.New(tokenizer.Heuristic, "").Count(text)
+	}
+	return agent.Tokenizer.Count(text)
 }
 
 func (al *AgentLoop) handleCommand(
@@ -1900,6 +2107,19 @@ func (al *AgentLoop) buildCommandsRuntime(agent *AgentInstance, opts *processOpt
 			return nil
 		},
 	}
+	if al.cooldown != nil {
+		rt.ListCooldowns = al.cooldown.Statuses
+		rt.ClearCooldown = al.cooldown.Clear
+	}
+	if al.handoffs != nil && opts != nil {
+		rt.HandBack = func() (string, bool) {
+			h, ok := al.handoffs.clear(opts.Channel, opts.ChatID)
+			if !ok || h.AgentID == "" {
+				return "", false
+			}
+			return h.AgentID, true
+		}
+	}
 	if agent != nil {
 		rt.GetModelInfo = func() (string, string) {
 			return agent.Model, cfg.Agents.Defaults.Provider
@@ -1910,6 +2130,36 @@ func (al *AgentLoop) buildCommandsRuntime(agent *AgentInstance, opts *processOpt
 			return oldModel, nil
 		}
 
+		rt.GetCacheStats = func() (session.CacheStats, bool) {
+			if opts == nil {
+				return session.CacheStats{}, false
+			}
+			rec, ok := agent.Sessions.(session.CacheStatsRecorder)
+			if !ok {
+				return session.CacheStats{}, false
+			}
+			return rec.GetCacheStats(opts.SessionKey), true
+		}
+
+		rt.GetUsage = func() (usage.Totals, usage.Totals, error) {
+			if al.usage == nil {
+				return usage.Totals{}, usage.Totals{}, fmt.Errorf("usage ledger not initialized")
+			}
+			var sessionTotals usage.Totals
+			if opts != nil && opts.SessionKey != "" {
+				t, err := al.usage.SessionTotals(opts.SessionKey)
+				if err != nil {
+					return usage.Totals{}, usage.Totals{}, err
+				}
+				sessionTotals = t
+			}
+			return sessionTotals, al.usage.MonthTotals(), nil
+		}
+
+		if opts != nil {
+			al.addHistoryCommands(rt, agent, *opts)
+		}
+
 		rt.ClearHistory = func() error {
 			if opts == nil {
 				return fmt.Errorf("process options not available")
@@ -1921,6 +2171,7 @@ func (al *AgentLoop) buildCommandsRuntime(agent *AgentInstance, opts *processOpt
 			agent.Sessions.SetHistory(opts.SessionKey, make([]providers.Message, 0))
 			agent.Sessions.SetSummary(opts.SessionKey, "")
 			agent.Sessions.Save(opts.SessionKey)
+			resetSummaryTree(agent.Workspace, opts.SessionKey)
 			return nil
 		}
 	}
@@ -1957,6 +2208,55 @@ func inboundMetadata(msg bus.InboundMessage, key string) string {
 	return msg.Metadata[key]
 }
 
+// senderIdentities lists every identity of the sender that a roles entry
+// may name.
+func senderIdentities(msg bus.InboundMessage) []string {
+	ids := []string{msg.SenderID, msg.Sender.CanonicalID, msg.Sender.PlatformID}
+	if name := strings.TrimPrefix(msg.Sender.Username, "@"); name != "" {
+		ids = append(ids, name, "@"+name)
+	}
+	return ids
+}
+
+// splitMetadataList splits a comma-separated metadata value.
+func splitMetadataList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// inboundMediaType classifies a message for binding media conditions.
+// Voice wins over other attachments; unresolvable attachments count as files.
+func (al *AgentLoop) inboundMediaType(msg bus.InboundMessage) string {
+	if audioAnnotationRe.MatchString(msg.Content) {
+		return routing.MediaVoice
+	}
+	if len(msg.Media) == 0 {
+		return routing.MediaText
+	}
+	mediaType := routing.MediaFile
+	if al.mediaStore == nil {
+		return mediaType
+	}
+	for _, ref := range msg.Media {
+		_, meta, err := al.mediaStore.ResolveWithMeta(ref)
+		if err != nil {
+			continue
+		}
+		switch {
+		case utils.IsAudioFile(meta.Filename, meta.ContentType):
+			return routing.MediaVoice
+		case strings.HasPrefix(meta.ContentType, "image/"):
+			mediaType = routing.MediaImage
+		}
+	}
+	return mediaType
+}
+
 // extractParentPeer extracts the parent peer (reply-to) from inbound message metadata.
 func extractParentPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	parentKind := inboundMetadata(msg, metadataKeyParentPeerKind)
@@ -1318,3 +1318,47 @@ func TestResolveMediaRefs_MixedImageAndFile(t *testing.T) {
 		t.Fatalf("expected content %q, got %q", expectedContent, result[0].Content)
 	}
 }
+
+func TestResolveMessageRoute_NamedAccount(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{{ID: "main", Default: true}, {ID: "team"}},
+		},
+		Bindings: []config.AgentBinding{
+			{AgentID: "team", Match: config.BindingMatch{Channel: "telegram", AccountID: "team"}},
+		},
+		Session: config.SessionConfig{DMScope: "per-account-channel-peer"},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "ok"})
+
+	msg := bus.InboundMessage{
+		Channel:  "telegram_team",
+		SenderID: "user1",
+		ChatID:   "chat1",
+		Peer:     bus.Peer{Kind: "direct", ID: "user1"},
+	}
+	personal, _, err := al.resolveMessageRoute(bus.InboundMessage{
+		Channel: "telegram", SenderID: "user1", ChatID: "chat1", Peer: msg.Peer,
+	})
+	if err != nil || personal.AgentID != "main" {
+		t.Fatalf("primary account routed to %q (%v)", personal.AgentID, err)
+	}
+
+	msg.AccountID = "team"
+	route, agent, err := al.resolveMessageRoute(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent.ID != "team" || route.Channel != "telegram" || route.AccountID != "team" {
+		t.Errorf("route = %+v", route)
+	}
+	if route.SessionKey == personal.SessionKey {
+		t.Errorf("accounts share session key %q", route.SessionKey)
+	}
+}
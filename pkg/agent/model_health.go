@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// probeMaxTokens keeps health probes as cheap as possible.
+const probeMaxTokens = 8
+
+// startHealthProber starts background probing of every agent's model
+// candidates when agents.defaults.model_selection.probe is enabled.
+func (al *AgentLoop) startHealthProber() {
+	cfg := al.GetConfig()
+	sel := cfg.Agents.Defaults.ModelSelection
+	if sel == nil || !sel.Probe.Enabled || al.health == nil {
+		return
+	}
+
+	// Candidates are probed through the provider of the first agent that
+	// uses them, mirroring how the fallback chain calls them.
+	targets := make(map[string]*AgentInstance)
+	var candidates []providers.FallbackCandidate
+	registry := al.GetRegistry()
+	for _, agentID := range registry.ListAgentIDs() {
+		agent, ok := registry.GetAgent(agentID)
+		if !ok || agent.Provider == nil {
+			continue
+		}
+		all := append([]providers.FallbackCandidate(nil), agent.Candidates...)
+		if agent.Router != nil {
+			for _, tier := range agent.Router.Tiers() {
+				all = append(all, agent.TierCandidates[tier.Model]...)
+			}
+		}
+		for _, c := range all {
+			key := providers.ModelKey(c.Provider, c.Model)
+			if _, seen := targets[key]; seen {
+				continue
+			}
+			targets[key] = agent
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	probe := func(ctx context.Context, provider, model string) error {
+		agent := targets[providers.ModelKey(provider, model)]
+		resp, err := agent.Provider.Chat(ctx,
+			[]providers.Message{{Role: "user", Content: "ping"}},
+			nil, model,
+			map[string]any{"max_tokens": probeMaxTokens},
+		)
+		if err == nil && resp != nil {
+			al.recordUsage(agent, "", "", model, usage.KindHealthProbe, resp.Usage)
+		}
+		return err
+	}
+
+	prober := providers.NewHealthProber(al.health, probe, sel.Probe.GetInterval(), sel.Probe.GetTimeout())
+	prober.SetCandidates(candidates)
+	prober.SetStatePath(providers.HealthSnapshotPath(cfg.WorkspacePath()))
+
+	al.mu.Lock()
+	if al.prober != nil {
+		al.mu.Unlock()
+		return
+	}
+	al.prober = prober
+	al.mu.Unlock()
+
+	prober.Start()
+	logger.InfoCF("agent", "Model health probing enabled",
+		map[string]any{
+			"candidates": len(candidates),
+			"interval":   sel.Probe.GetInterval().String(),
+			"strategy":   sel.GetStrategy(),
+		})
+}
+
+// stopHealthProber stops the background prober, if running.
+func (al *AgentLoop) stopHealthProber() {
+	al.mu.Lock()
+	prober := al.prober
+	al.prober = nil
+	al.mu.Unlock()
+
+	if prober != nil {
+		prober.Stop()
+	}
+}
+
+// ModelHealth returns the latest probe results for all model candidates.
+// It is empty unless probing is enabled.
+func (al *AgentLoop) ModelHealth() []providers.ModelHealth {
+	al.mu.RLock()
+	fc := al.fallback
+	al.mu.RUnlock()
+	if fc == nil {
+		return nil
+	}
+	return fc.HealthSnapshot()
+}
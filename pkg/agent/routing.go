@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"log"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+)
+
+// buildRouter turns the routing config into a Router plus the resolved
+// candidates of every tier. Tiers whose model is not in model_list are
+// dropped; when none remain, routing is disabled and nil is returned.
+func buildRouter(
+	rc *config.RoutingConfig,
+	agentID string,
+	provider providers.LLMProvider,
+	resolve func(modelName string) []providers.FallbackCandidate,
+) (*routing.Router, map[string][]providers.FallbackCandidate) {
+	tiers := make([]routing.Tier, 0, len(rc.Tiers))
+	for _, t := range rc.Tiers {
+		tiers = append(tiers, routing.Tier{
+			Name:         t.Name,
+			Model:        t.Model,
+			MaxScore:     t.MaxScore,
+			Capabilities: t.Capabilities,
+		})
+	}
+	if len(tiers) == 0 && rc.LightModel != "" {
+		tiers = append(tiers, routing.LightTier(rc.LightModel, rc.Threshold))
+	}
+
+	candidates := make(map[string][]providers.FallbackCandidate, len(tiers))
+	kept := tiers[:0]
+	for _, t := range tiers {
+		resolved := resolve(t.Model)
+		if len(resolved) == 0 {
+			log.Printf("routing: tier %q model %q not found in model_list — tier disabled for agent %q",
+				t.Name, t.Model, agentID)
+			continue
+		}
+		candidates[t.Model] = resolved
+		kept = append(kept, t)
+	}
+	if len(kept) == 0 {
+		return nil, nil
+	}
+
+	routerCfg := routing.RouterConfig{
+		Tiers:             kept,
+		LongContextTokens: rc.LongContextTokens,
+	}
+
+	var classifier routing.Classifier = &routing.RuleClassifier{}
+	if rc.Classifier == config.RoutingClassifierModel {
+		if resolved := resolve(rc.ClassifierModel); len(resolved) > 0 {
+			timeout := time.Duration(rc.ClassifierTimeout) * time.Millisecond
+			classifier = routing.NewModelClassifier(provider, resolved[0].Model, timeout)
+		} else {
+			log.Printf("routing: classifier_model %q not found in model_list — using rule classifier for agent %q",
+				rc.ClassifierModel, agentID)
+		}
+	}
+
+	return routing.NewWithClassifier(routerCfg, classifier), candidates
+}
+
+// routingTurn accumulates the outcome of one routed turn for the decision
+// log. A nil turn ignores all calls, so unrouted turns need no checks.
+type routingTurn struct {
+	record routing.DecisionRecord
+	msg    string
+	start  time.Time
+}
+
+// beginRoutingTurn starts tracking a turn routed by d. It returns nil when
+// the turn was not routed or decision logging is unavailable.
+func (al *AgentLoop) beginRoutingTurn(
+	agent *AgentInstance,
+	sessionKey, userMsg string,
+	d *routing.Decision,
+) *routingTurn {
+	if d == nil || al.decisions == nil {
+		return nil
+	}
+	return &routingTurn{
+		record: routing.DecisionRecord{
+			AgentID:      agent.ID,
+			SessionKey:   sessionKey,
+			Features:     d.Features,
+			Score:        d.Score,
+			Classifier:   d.Classifier,
+			Tier:         d.Tier,
+			Model:        d.Model,
+			LightModel:   agent.Router.LightModel(),
+			PrimaryModel: agent.Model,
+			Required:     d.Required,
+		},
+		msg:   userMsg,
+		start: time.Now(),
+	}
+}
+
+// addUsage folds one LLM response of the turn into its totals.
+func (t *routingTurn) addUsage(u *providers.UsageInfo, cost float64) {
+	if t == nil || u == nil {
+		return
+	}
+	t.record.PromptTokens += u.PromptTokens
+	t.record.CompletionTokens += u.CompletionTokens
+	t.record.Cost += cost
+}
+
+// logRoutingTurn appends the finished turn to the decision log.
+func (al *AgentLoop) logRoutingTurn(t *routingTurn) {
+	if t == nil {
+		return
+	}
+	t.record.LatencyMs = time.Since(t.start).Milliseconds()
+	al.decisions.Record(t.record, t.msg)
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func newTieredRoutingConfig(t *testing.T) *config.Config {
+	t.Helper()
+	return &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "frontier",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Routing: &config.RoutingConfig{
+					Enabled: true,
+					Tiers: []config.RoutingTier{
+						{Name: "mid", Model: "mid", MaxScore: 0.6, Capabilities: []string{routing.CapVision}},
+						{Name: "local", Model: "local", MaxScore: 0.3},
+					},
+				},
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "frontier", Model: "openai/frontier-model"},
+			{ModelName: "mid", Model: "openai/mid-model"},
+			{ModelName: "local", Model: "ollama/llama3.2:3b"},
+		},
+	}
+}
+
+func TestNewAgentInstance_RoutingTiers(t *testing.T) {
+	cfg := newTieredRoutingConfig(t)
+	agent := NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, &mockProvider{})
+
+	if agent.Router == nil {
+		t.Fatal("expected router")
+	}
+	tiers := agent.Router.Tiers()
+	if len(tiers) != 2 || tiers[0].Name != "local" || tiers[1].Name != "mid" {
+		t.Fatalf("tiers = %+v, want local then mid", tiers)
+	}
+	if len(agent.LightCandidates) != 1 || agent.LightCandidates[0].Provider != "ollama" {
+		t.Errorf("LightCandidates = %+v, want the local tier", agent.LightCandidates)
+	}
+	if len(agent.TierCandidates["mid"]) != 1 {
+		t.Errorf("TierCandidates[mid] = %+v", agent.TierCandidates["mid"])
+	}
+}
+
+func TestNewAgentInstance_RoutingLegacyLightModel(t *testing.T) {
+	cfg := newTieredRoutingConfig(t)
+	cfg.Agents.Defaults.Routing = &config.RoutingConfig{Enabled: true, LightModel: "local", Threshold: 0.4}
+	agent := NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, &mockProvider{})
+
+	if agent.Router == nil || agent.Router.LightModel() != "local" || agent.Router.Threshold() != 0.4 {
+		t.Fatalf("router = %+v", agent.Router)
+	}
+}
+
+func TestSelectCandidates_RecordsDecisionInSession(t *testing.T) {
+	cfg := newTieredRoutingConfig(t)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	agent := al.GetRegistry().GetDefaultAgent()
+
+	candidates, model, _ := al.selectCandidates(context.Background(), agent, "routing-session", "hi", nil)
+	if model != "local" || len(candidates) != 1 || candidates[0].Model != "llama3.2:3b" {
+		t.Fatalf("selected %q %+v, want local tier", model, candidates)
+	}
+
+	_, model, _ = al.selectCandidates(context.Background(), agent, "routing-session", "look at cat.png", nil)
+	if model != "mid" {
+		t.Errorf("vision request selected %q, want mid", model)
+	}
+
+	rec, ok := agent.Sessions.(session.RoutingRecorder)
+	if !ok {
+		t.Fatal("session store does not record routing")
+	}
+	stats := rec.GetRoutingStats("routing-session")
+	if stats.Tiers["local"] != 1 || stats.Tiers["mid"] != 1 {
+		t.Errorf("routing stats = %+v", stats.Tiers)
+	}
+	if last := stats.Recent[len(stats.Recent)-1]; last.Classifier != "rules" || len(last.Required) != 1 {
+		t.Errorf("last decision = %+v", last)
+	}
+}
+
+func TestRoutingTurn_LogsDecisionAndRetry(t *testing.T) {
+	cfg := newTieredRoutingConfig(t)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	agent := al.GetRegistry().GetDefaultAgent()
+
+	_, _, d := al.selectCandidates(context.Background(), agent, "s1", "hi", nil)
+	if d == nil {
+		t.Fatal("expected a routing decision")
+	}
+	turn := al.beginRoutingTurn(agent, "s1", "hi", d)
+	turn.addUsage(&providers.UsageInfo{PromptTokens: 100, CompletionTokens: 20}, 0.01)
+	turn.addUsage(&providers.UsageInfo{PromptTokens: 50, CompletionTokens: 5}, 0.005)
+	al.logRoutingTurn(turn)
+
+	// Sending the same message again marks the previous turn as retried.
+	al.selectCandidates(context.Background(), agent, "s1", "Hi ", nil)
+
+	records, err := routing.ReadDecisions(filepath.Join(cfg.WorkspacePath(), "routing"), time.Time{})
+	if err != nil {
+		t.Fatalf("ReadDecisions: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("records = %d, want 1", len(records))
+	}
+	r := records[0]
+	if r.Tier != "local" || r.PrimaryModel != "frontier" || r.LightModel != "local" {
+		t.Errorf("record = %+v", r)
+	}
+	if r.PromptTokens != 150 || r.CompletionTokens != 25 || r.Cost < 0.0149 || r.Cost > 0.0151 {
+		t.Errorf("usage = %d/%d cost %v", r.PromptTokens, r.CompletionTokens, r.Cost)
+	}
+	if !r.Retried {
+		t.Error("expected the turn to be marked as retried")
+	}
+}
+
+func TestInboundMediaType(t *testing.T) {
+	al := &AgentLoop{}
+	cases := []struct {
+		msg  bus.InboundMessage
+		want string
+	}{
+		{bus.InboundMessage{Content: "hello"}, routing.MediaText},
+		{bus.InboundMessage{Content: "[voice: turn on the lights]"}, routing.MediaVoice},
+		{bus.InboundMessage{Content: "see attached", Media: []string{"media://1"}}, routing.MediaFile},
+	}
+	for _, tc := range cases {
+		if got := al.inboundMediaType(tc.msg); got != tc.want {
+			t.Errorf("inboundMediaType(%q) = %q, want %q", tc.msg.Content, got, tc.want)
+		}
+	}
+}
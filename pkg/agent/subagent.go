@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// delegationTools are left out of a subagent's tools so a subagent cannot
+// delegate again or take over the parent's conversation.
+var delegationTools = []string{"spawn", "spawn_status", "spawn_parallel", "subagent", "handoff"}
+
+// registerSubagentTools registers spawn, spawn_status and spawn_parallel.
+// They share one SubagentManager per agent and require the subagent tool to
+// be enabled.
+func (al *AgentLoop) registerSubagentTools(cfg *config.Config, registry *AgentRegistry) {
+	spawnEnabled := cfg.Tools.IsToolEnabled("spawn")
+	spawnStatusEnabled := cfg.Tools.IsToolEnabled("spawn_status")
+	parallelEnabled := cfg.Tools.IsToolEnabled("spawn_parallel")
+	if !spawnEnabled && !spawnStatusEnabled && !parallelEnabled {
+		return
+	}
+	if !cfg.Tools.IsToolEnabled("subagent") {
+		logger.WarnCF("agent", "spawn/spawn_status/spawn_parallel tools require subagent to be enabled", nil)
+		return
+	}
+
+	for _, agentID := range registry.ListAgentIDs() {
+		agent, ok := registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		subagentManager := tools.NewSubagentManager(agent.Provider, agent.Model, agent.Workspace)
+		subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
+		if agent.ContextBuilder != nil {
+			subagentManager.SetSkillsLoader(agent.ContextBuilder.skillsLoader)
+		}
+		subagentManager.SetTargetResolver(func(targetAgentID string) (*tools.SubagentTarget, error) {
+			return al.subagentTarget(registry, agent, targetAgentID)
+		})
+		allowlist := func(targetAgentID string) bool {
+			return registry.CanSpawnSubagent(agentID, targetAgentID)
+		}
+		if spawnEnabled {
+			spawnTool := tools.NewSpawnTool(subagentManager)
+			spawnTool.SetAllowlistChecker(allowlist)
+			agent.Tools.Register(spawnTool)
+		}
+		if parallelEnabled {
+			pc := cfg.Tools.SpawnParallel
+			parallelTool := tools.NewSpawnParallelTool(subagentManager, tools.SpawnParallelOptions{
+				MaxConcurrency: pc.MaxConcurrency,
+				MaxTasks:       pc.MaxTasks,
+				Timeout:        time.Duration(pc.TimeoutSeconds) * time.Second,
+			})
+			parallelTool.SetAllowlistChecker(allowlist)
+			agent.Tools.Register(parallelTool)
+		}
+		if spawnStatusEnabled {
+			agent.Tools.Register(tools.NewSpawnStatusTool(subagentManager))
+		}
+	}
+}
+
+// subagentTarget builds what a subagent spawned by parent runs as: the
+// named agent (or parent itself) with its system prompt, skills, tools and
+// candidates. The parent's subagents.model, when set, replaces the model.
+func (al *AgentLoop) subagentTarget(
+	registry *AgentRegistry,
+	parent *AgentInstance,
+	agentID string,
+) (*tools.SubagentTarget, error) {
+	target := parent
+	if agentID != "" {
+		a, ok := registry.GetAgent(agentID)
+		if !ok {
+			return nil, fmt.Errorf("agent %q not found", agentID)
+		}
+		target = a
+	}
+
+	model, candidates := target.Model, target.Candidates
+	if len(parent.SubagentCandidates) > 0 {
+		model, candidates = parent.SubagentModel, parent.SubagentCandidates
+	}
+	// Subagent runs count against usage.monthly_budget like any turn.
+	candidates, model, refused := al.applyBudget(target, candidates, model)
+	if refused {
+		return nil, errors.New("the monthly usage budget has been reached")
+	}
+
+	return &tools.SubagentTarget{
+		AgentID:       target.ID,
+		SystemPrompt:  target.ContextBuilder.BuildSystemPromptWithCache(),
+		Provider:      target.Provider,
+		Model:         model,
+		Candidates:    candidates,
+		Fallback:      al.fallback,
+		Tools:         target.Tools.CloneWithout(delegationTools...),
+		MaxIterations: target.MaxIterations,
+		LLMOptions: map[string]any{
+			"max_tokens":  target.MaxTokens,
+			"temperature": target.Temperature,
+		},
+		OnUsage: func(channel, model string, u *providers.UsageInfo) {
+			al.recordUsage(target, "", channel, model, usage.KindSubagent, u)
+		},
+		Save: func(runID string, messages []providers.Message) string {
+			if target.Sessions == nil {
+				return ""
+			}
+			sessionKey := routing.BuildSubagentSessionKey(target.ID, runID)
+			target.Sessions.SetHistory(sessionKey, messages)
+			if err := target.Sessions.Save(sessionKey); err != nil {
+				logger.WarnCF("agent", "Failed to save subagent session",
+					map[string]any{"session_key": sessionKey, "error": err.Error()})
+			}
+			logger.InfoCF("agent", "Subagent run saved",
+				map[string]any{
+					"parent":      parent.ID,
+					"agent_id":    target.ID,
+					"session_key": sessionKey,
+				})
+			return sessionKey
+		},
+	}, nil
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestSubagentTarget_UsesTargetAgent(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "frontier",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{
+					ID:      "main",
+					Default: true,
+					Subagents: &config.SubagentsConfig{
+						AllowAgents: []string{"coder"},
+					},
+				},
+				{
+					ID:     "coder",
+					Model:  &config.AgentModelConfig{Primary: "mid", Fallbacks: []string{"local"}},
+					Prompt: &config.AgentPromptConfig{Persona: "You are Cody, the coding agent."},
+					Subagents: &config.SubagentsConfig{
+						Model: &config.AgentModelConfig{Primary: "local"},
+					},
+				},
+			},
+		},
+		Tools: config.ToolsConfig{
+			ReadFile: config.ReadFileToolConfig{Enabled: true},
+			Spawn:    config.ToolConfig{Enabled: true},
+			Subagent: config.ToolConfig{Enabled: true},
+			Handoff:  config.ToolConfig{Enabled: true},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "frontier", Model: "openai/frontier-model"},
+			{ModelName: "mid", Model: "openai/mid-model"},
+			{ModelName: "local", Model: "ollama/llama3.2:3b"},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	main, _ := al.registry.GetAgent("main")
+	coder, _ := al.registry.GetAgent("coder")
+
+	target, err := al.subagentTarget(al.registry, main, "coder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(target.SystemPrompt, "You are Cody, the coding agent.") {
+		t.Error("subagent should use the target agent's system prompt")
+	}
+	if target.Model != "mid" || len(target.Candidates) != 2 || target.Fallback == nil {
+		t.Errorf("model = %q with %d candidates, want mid with fallback", target.Model, len(target.Candidates))
+	}
+	if _, ok := coder.Tools.Get("spawn"); !ok {
+		t.Fatal("coder should have the spawn tool")
+	}
+	for _, name := range delegationTools {
+		if _, ok := target.Tools.Get(name); ok {
+			t.Errorf("subagent tools include %s", name)
+		}
+	}
+	if _, ok := target.Tools.Get("read_file"); !ok {
+		t.Error("subagent should keep the target agent's workspace tools")
+	}
+
+	// The spawning agent's subagents.model overrides the target's model.
+	target, err = al.subagentTarget(al.registry, coder, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Model != "local" || len(target.Candidates) != 1 || target.Candidates[0].Model != "llama3.2:3b" {
+		t.Errorf("override model = %q %+v, want local", target.Model, target.Candidates)
+	}
+
+	if _, err := al.subagentTarget(al.registry, main, "ghost"); err == nil {
+		t.Error("unknown target agent should fail")
+	}
+
+	// Runs are saved as sessions of the target agent.
+	key := target.Save("subagent-1-123", []providers.Message{
+		{Role: "user", Content: "task"},
+		{Role: "assistant", Content: "done"},
+	})
+	if key != "agent:coder:subagent:subagent-1-123" {
+		t.Errorf("session key = %q", key)
+	}
+	if history := coder.Sessions.GetHistory(key); len(history) != 2 {
+		t.Errorf("saved history has %d messages, want 2", len(history))
+	}
+}
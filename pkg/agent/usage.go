@@ -0,0 +1,41 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// usageScope is the session and channel an LLM call is billed to when the
+// code making it cannot be told directly, as with routing classifiers.
+type usageScope struct {
+	sessionKey string
+	channel    string
+}
+
+type usageScopeKey struct{}
+
+func withUsageScope(ctx context.Context, sessionKey, channel string) context.Context {
+	return context.WithValue(ctx, usageScopeKey{}, usageScope{sessionKey: sessionKey, channel: channel})
+}
+
+func usageScopeFrom(ctx context.Context) usageScope {
+	scope, _ := ctx.Value(usageScopeKey{}).(usageScope)
+	return scope
+}
+
+// registerUsageHooks makes every agent's model classifier record its
+// grading calls in the usage ledger.
+func (al *AgentLoop) registerUsageHooks(registry *AgentRegistry) {
+	for _, agentID := range registry.ListAgentIDs() {
+		agent, ok := registry.GetAgent(agentID)
+		if !ok || agent.Router == nil {
+			continue
+		}
+		agent.Router.SetUsageHook(func(ctx context.Context, model string, u *providers.UsageInfo) {
+			scope := usageScopeFrom(ctx)
+			al.recordUsage(agent, scope.sessionKey, scope.channel, model, usage.KindClassifier, u)
+		})
+	}
+}
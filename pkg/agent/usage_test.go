@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// usageProvider returns a fixed answer with token usage attached.
+type usageProvider struct {
+	calls int
+}
+
+func (p *usageProvider) Chat(
+	_ context.Context,
+	_ []providers.Message,
+	_ []providers.ToolDefinition,
+	_ string,
+	_ map[string]any,
+) (*providers.LLMResponse, error) {
+	p.calls++
+	return &providers.LLMResponse{
+		Content: "priced answer",
+		Usage:   &providers.UsageInfo{PromptTokens: 1_000_000, CompletionTokens: 100_000, TotalTokens: 1_100_000},
+	}, nil
+}
+
+func (p *usageProvider) GetDefaultModel() string { return "priced-model" }
+
+func newUsageTestConfig(t *testing.T, budget float64) *config.Config {
+	t.Helper()
+	tmpDir := t.TempDir()
+	return &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				ModelName:         "priced",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: []config.ModelConfig{{
+			ModelName: "priced",
+			Model:     "openai/priced-model",
+			Pricing:   &config.ModelPricing{Input: 1, Output: 10},
+		}},
+		Usage: config.UsageConfig{MonthlyBudget: budget, BudgetAction: config.BudgetActionRefuse},
+	}
+}
+
+func TestUsageLedger_RecordsChatCalls(t *testing.T) {
+	cfg := newUsageTestConfig(t, 0)
+	provider := &usageProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	resp, err := al.ProcessDirectWithChannel(context.Background(), "hello", "usage-session", "telegram", "chat-1")
+	if err != nil {
+		t.Fatalf("ProcessDirectWithChannel: %v", err)
+	}
+	if resp != "priced answer" {
+		t.Fatalf("response = %q", resp)
+	}
+
+	records, err := usage.ReadRecords(filepath.Join(cfg.WorkspacePath(), "usage"), time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadRecords: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("len(records) = %d, want 1", len(records))
+	}
+	r := records[0]
+	if r.AgentID != "main" || r.SessionKey == "" || r.Model != "priced" || r.Channel != "telegram" || r.Kind != usage.KindChat {
+		t.Errorf("record = %+v", r)
+	}
+	if r.Cost != 2.0 {
+		t.Errorf("Cost = %v, want 2.0 (1M in * 1 + 100k out * 10)", r.Cost)
+	}
+}
+
+func TestUsageLedger_RefusesOverBudget(t *testing.T) {
+	cfg := newUsageTestConfig(t, 1.5)
+	provider := &usageProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "first", "s", "cli", "direct"); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	resp, err := al.ProcessDirectWithChannel(context.Background(), "second", "s", "cli", "direct")
+	if err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+	if resp != budgetExceededReply {
+		t.Errorf("response = %q, want budget refusal", resp)
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider calls = %d, want 1 (second call refused)", provider.calls)
+	}
+}
+
+func TestUsageLedger_TagsSummarizationWithChannel(t *testing.T) {
+	cfg := newUsageTestConfig(t, 0)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &usageProvider{})
+	agent := al.registry.GetDefaultAgent()
+	var history []providers.Message
+	for range 3 {
+		history = append(history,
+			providers.Message{Role: "user", Content: "question"},
+			providers.Message{Role: "assistant", Content: "answer"},
+		)
+	}
+	agent.Sessions.SetHistory("s", history)
+
+	al.summarizeSession(agent, "s", "telegram")
+
+	records, err := usage.ReadRecords(filepath.Join(cfg.WorkspacePath(), "usage"), time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadRecords: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("len(records) = %d, want 1", len(records))
+	}
+	if r := records[0]; r.Kind != usage.KindSummarization || r.Channel != "telegram" {
+		t.Errorf("record = %+v, want summarization on telegram", r)
+	}
+}
+
+func TestUsageLedger_SubagentRunsCountAgainstBudget(t *testing.T) {
+	cfg := newUsageTestConfig(t, 1.5)
+	cfg.Tools.Subagent = config.ToolConfig{Enabled: true}
+	cfg.Tools.SpawnParallel = config.SpawnParallelConfig{ToolConfig: config.ToolConfig{Enabled: true}}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &usageProvider{})
+	agent := al.registry.GetDefaultAgent()
+	tool, ok := agent.Tools.Get("spawn_parallel")
+	if !ok {
+		t.Fatal("spawn_parallel should be registered")
+	}
+	ctx := tools.WithToolContext(context.Background(), "telegram", "chat-1")
+	args := map[string]any{"tasks": []any{map[string]any{"task": "look around"}}}
+
+	if r := tool.Execute(ctx, args); r.IsError || !strings.Contains(r.ForLLM, `"completed": 1`) {
+		t.Fatalf("first run: %+v", r)
+	}
+	records, err := usage.ReadRecords(filepath.Join(cfg.WorkspacePath(), "usage"), time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadRecords: %v", err)
+	}
+	if len(records) != 1 || records[0].Kind != usage.KindSubagent || records[0].Channel != "telegram" {
+		t.Fatalf("records = %+v, want one subagent call on telegram", records)
+	}
+
+	// The first run spent 2.0 of the 1.5 budget.
+	if r := tool.Execute(ctx, args); !strings.Contains(r.ForLLM, "budget") {
+		t.Errorf("second run should be refused over budget: %+v", r)
+	}
+}
+
+func TestUsageLedger_RecordsClassifierCalls(t *testing.T) {
+	cfg := newUsageTestConfig(t, 0)
+	cfg.Agents.Defaults.Routing = &config.RoutingConfig{
+		Enabled:         true,
+		LightModel:      "priced",
+		Classifier:      config.RoutingClassifierModel,
+		ClassifierModel: "priced",
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &usageProvider{})
+
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "hello", "s", "telegram", "chat-1"); err != nil {
+		t.Fatalf("ProcessDirectWithChannel: %v", err)
+	}
+
+	records, err := usage.ReadRecords(filepath.Join(cfg.WorkspacePath(), "usage"), time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadRecords: %v", err)
+	}
+	var graded bool
+	for _, r := range records {
+		if r.Kind == usage.KindClassifier {
+			graded = r.Channel == "telegram" && r.SessionKey != ""
+		}
+	}
+	if !graded {
+		t.Errorf("records = %+v, want a classifier call tagged with the session and channel", records)
+	}
+}
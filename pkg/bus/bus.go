@@ -3,6 +3,8 @@ package bus
 import (
 	"context"
 	"errors"
+	"slices"
+	"sync"
 	"sync/atomic"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
@@ -19,6 +21,14 @@ type MessageBus struct {
 	outboundMedia chan OutboundMediaMessage
 	done          chan struct{}
 	closed        atomic.Bool
+
+	interceptMu  sync.Mutex
+	interceptors []*interceptor
+}
+
+type interceptor struct {
+	match func(InboundMessage) bool
+	ch    chan InboundMessage
 }
 
 func NewMessageBus() *MessageBus {
@@ -37,6 +47,9 @@ func (mb *MessageBus) PublishInbound(ctx context.Context, msg InboundMessage) er
 	if err := ctx.Err(); err != nil {
 		return err
 	}
+	if mb.intercept(msg) {
+		return nil
+	}
 	select {
 	case mb.inbound <- msg:
 		return nil
@@ -58,6 +71,38 @@ func (mb *MessageBus) ConsumeInbound(ctx context.Context) (InboundMessage, bool)
 	}
 }
 
+// InterceptInbound diverts the next inbound message that satisfies match
+// to the returned channel instead of the inbound queue. It lets a tool wait
+// for the user's answer while the agent loop is busy running that tool.
+// The returned function removes the interceptor; call it when done.
+func (mb *MessageBus) InterceptInbound(match func(InboundMessage) bool) (<-chan InboundMessage, func()) {
+	ic := &interceptor{match: match, ch: make(chan InboundMessage, 1)}
+	mb.interceptMu.Lock()
+	mb.interceptors = append(mb.interceptors, ic)
+	mb.interceptMu.Unlock()
+	return ic.ch, func() {
+		mb.interceptMu.Lock()
+		defer mb.interceptMu.Unlock()
+		mb.interceptors = slices.DeleteFunc(mb.interceptors, func(other *interceptor) bool { return other == ic })
+	}
+}
+
+// intercept hands msg to the first matching interceptor and retires it, so
+// each interceptor receives at most one message.
+func (mb *MessageBus) intercept(msg InboundMessage) bool {
+	mb.interceptMu.Lock()
+	defer mb.interceptMu.Unlock()
+	for i, ic := range mb.interceptors {
+		if !ic.match(msg) {
+			continue
+		}
+		ic.ch <- msg // buffered for exactly this one message
+		mb.interceptors = slices.Delete(mb.interceptors, i, i+1)
+		return true
+	}
+	return false
+}
+
 func (mb *MessageBus) PublishOutbound(ctx context.Context, msg OutboundMessage) error {
 	if mb.closed.Load() {
 		return ErrBusClosed
@@ -227,3 +227,42 @@ func TestCloseIdempotent(t *testing.T) {
 		t.Fatalf("expected ErrBusClosed after multiple closes, got %v", err)
 	}
 }
+
+func TestInterceptInbound(t *testing.T) {
+	mb := NewMessageBus()
+	defer mb.Close()
+
+	ctx := context.Background()
+	replies, cancel := mb.InterceptInbound(func(msg InboundMessage) bool {
+		return msg.ChatID == "chat1"
+	})
+
+	// Messages for other chats still reach the consumer.
+	if err := mb.PublishInbound(ctx, InboundMessage{ChatID: "chat2", Content: "other"}); err != nil {
+		t.Fatalf("PublishInbound failed: %v", err)
+	}
+	if msg, ok := mb.ConsumeInbound(ctx); !ok || msg.Content != "other" {
+		t.Fatalf("expected unmatched message to be consumed, got %+v", msg)
+	}
+
+	if err := mb.PublishInbound(ctx, InboundMessage{ChatID: "chat1", Content: "answer"}); err != nil {
+		t.Fatalf("PublishInbound failed: %v", err)
+	}
+	select {
+	case msg := <-replies:
+		if msg.Content != "answer" {
+			t.Fatalf("expected intercepted answer, got %q", msg.Content)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message was not intercepted")
+	}
+
+	// An interceptor takes one message; later ones go to the consumer.
+	if err := mb.PublishInbound(ctx, InboundMessage{ChatID: "chat1", Content: "second"}); err != nil {
+		t.Fatalf("PublishInbound failed: %v", err)
+	}
+	cancel()
+	if msg, ok := mb.ConsumeInbound(ctx); !ok || msg.Content != "second" {
+		t.Fatalf("expected second message to be consumed, got %+v", msg)
+	}
+}
@@ -17,9 +17,11 @@ type SenderInfo struct {
 
 type InboundMessage struct {
 	Channel    string            `json:"channel"`
+	AccountID  string            `json:"account_id,omitempty"` // named account of the channel type; empty for the primary one
 	SenderID   string            `json:"sender_id"`
 	Sender     SenderInfo        `json:"sender"`
 	ChatID     string            `json:"chat_id"`
+	ThreadID   string            `json:"thread_id,omitempty"` // thread or forum topic within the chat; empty outside threads
 	Content    string            `json:"content"`
 	Media      []string          `json:"media,omitempty"`
 	Peer       Peer              `json:"peer"`                  // routing peer
@@ -27,13 +29,30 @@ type InboundMessage struct {
 	MediaScope string            `json:"media_scope,omitempty"` // media lifecycle scope
 	SessionKey string            `json:"session_key"`
 	Metadata   map[string]string `json:"metadata,omitempty"`
+	Choice     *ChoiceReply      `json:"choice,omitempty"` // set when the user pressed a prompt button
 }
 
 type OutboundMessage struct {
-	Channel          string `json:"channel"`
-	ChatID           string `json:"chat_id"`
-	Content          string `json:"content"`
-	ReplyToMessageID string `json:"reply_to_message_id,omitempty"`
+	Channel          string        `json:"channel"`
+	ChatID           string        `json:"chat_id"`
+	Content          string        `json:"content"`
+	ReplyToMessageID string        `json:"reply_to_message_id,omitempty"`
+	Prompt           *ChoicePrompt `json:"prompt,omitempty"` // options to offer with Content
+}
+
+// ChoicePrompt asks the user to pick one of several options. Channels show
+// the options as buttons or quick replies where they can, and as a
+// numbered menu otherwise.
+type ChoicePrompt struct {
+	ID      string   `json:"id"`
+	Options []string `json:"options"`
+}
+
+// ChoiceReply is a press of a prompt button. Option is 1-based, matching
+// the numbered menu.
+type ChoiceReply struct {
+	PromptID string `json:"prompt_id"`
+	Option   int    `json:"option"`
 }
 
 // MediaPart describes a single media attachment to send.
@@ -65,6 +65,13 @@ func WithMaxMessageLength(n int) BaseChannelOption {
 	return func(c *BaseChannel) { c.maxMessageLength = n }
 }
 
+// WithTextFormat declares the markup dialect the channel sends. The Manager
+// then splits outbound Markdown by its rendered length in that format, and
+// the channel renders each chunk with RenderMarkdown.
+func WithTextFormat(f TextFormat) BaseChannelOption {
+	return func(c *BaseChannel) { c.textFormat = f }
+}
+
 // WithGroupTrigger sets the group trigger configuration for a channel.
 func WithGroupTrigger(gt config.GroupTriggerConfig) BaseChannelOption {
 	return func(c *BaseChannel) { c.groupTrigger = gt }
@@ -82,13 +89,21 @@ type MessageLengthProvider interface {
 	MaxMessageLength() int
 }
 
+// TextFormatProvider is an opt-in interface that channels implement to
+// advertise the TextFormat they render outbound Markdown into.
+type TextFormatProvider interface {
+	TextFormat() TextFormat
+}
+
 type BaseChannel struct {
 	config              any
 	bus                 *bus.MessageBus
 	running             atomic.Bool
 	name                string
+	accountID           string
 	allowList           []string
 	maxMessageLength    int
+	textFormat          TextFormat
 	groupTrigger        config.GroupTriggerConfig
 	mediaStore          media.MediaStore
 	placeholderRecorder PlaceholderRecorder
@@ -121,6 +136,12 @@ func (c *BaseChannel) MaxMessageLength() int {
 	return c.maxMessageLength
 }
 
+// TextFormat returns the markup dialect this channel renders Markdown into.
+// FormatNone means the channel sends content as-is.
+func (c *BaseChannel) TextFormat() TextFormat {
+	return c.textFormat
+}
+
 // ShouldRespondInGroup determines whether the bot should respond in a group chat.
 // Each channel is responsible for:
 //  1. Detecting isMentioned (platform-specific)
@@ -165,6 +186,19 @@ func (c *BaseChannel) Name() string {
 	return c.name
 }
 
+// AccountID returns the named account the channel runs as, or "" for the
+// primary account of its type.
+func (c *BaseChannel) AccountID() string {
+	return c.accountID
+}
+
+// SetAccount makes the channel a named account of its type. The Manager
+// calls it before Start; the channel then runs under name.
+func (c *BaseChannel) SetAccount(name, accountID string) {
+	c.name = name
+	c.accountID = accountID
+}
+
 func (c *BaseChannel) ReasoningChannelID() string {
 	return c.reasoningChannelID
 }
@@ -229,6 +263,11 @@ func (c *BaseChannel) IsAllowedSender(sender bus.SenderInfo) bool {
 	return false
 }
 
+// MetadataThreadID is the inbound metadata key under which channels report
+// the thread or forum topic a message was posted in. HandleMessage lifts it
+// into bus.InboundMessage.ThreadID, which scopes sessions per thread.
+const MetadataThreadID = "thread_id"
+
 func (c *BaseChannel) HandleMessage(
 	ctx context.Context,
 	peer bus.Peer,
@@ -237,11 +276,48 @@ func (c *BaseChannel) HandleMessage(
 	metadata map[string]string,
 	senderOpts ...bus.SenderInfo,
 ) {
-	// Use SenderInfo-based allow check when available, else fall back to string
 	var sender bus.SenderInfo
 	if len(senderOpts) > 0 {
 		sender = senderOpts[0]
 	}
+	c.handleInbound(ctx, peer, messageID, senderID, chatID, content, media, metadata, nil, sender)
+}
+
+// HandleChoice publishes a press of a prompt button whose callback data was
+// made by ChoiceData. label is the text of the pressed button and becomes
+// the message content, so that a press arriving after the prompt timed out
+// still reads naturally. metadata carries the same keys as for messages,
+// MetadataThreadID in particular, so the press reaches the thread's session.
+// It returns false, publishing nothing, when data is not choice data.
+func (c *BaseChannel) HandleChoice(
+	ctx context.Context,
+	peer bus.Peer,
+	messageID, senderID, chatID, data, label string,
+	metadata map[string]string,
+	sender bus.SenderInfo,
+) bool {
+	promptID, option, ok := ParseChoiceData(data)
+	if !ok {
+		return false
+	}
+	if label == "" {
+		label = strconv.Itoa(option)
+	}
+	choice := &bus.ChoiceReply{PromptID: promptID, Option: option}
+	c.handleInbound(ctx, peer, messageID, senderID, chatID, label, nil, metadata, choice, sender)
+	return true
+}
+
+func (c *BaseChannel) handleInbound(
+	ctx context.Context,
+	peer bus.Peer,
+	messageID, senderID, chatID, content string,
+	media []string,
+	metadata map[string]string,
+	choice *bus.ChoiceReply,
+	sender bus.SenderInfo,
+) {
+	// Use SenderInfo-based allow check when available, else fall back to string
 	if sender.CanonicalID != "" || sender.PlatformID != "" {
 		if !c.IsAllowedSender(sender) {
 			return
@@ -262,15 +338,18 @@ func (c *BaseChannel) HandleMessage(
 
 	msg := bus.InboundMessage{
 		Channel:    c.name,
+		AccountID:  c.accountID,
 		SenderID:   resolvedSenderID,
 		Sender:     sender,
 		ChatID:     chatID,
+		ThreadID:   metadata[MetadataThreadID],
 		Content:    content,
 		Media:      media,
 		Peer:       peer,
 		MessageID:  messageID,
 		MediaScope: scope,
 		Metadata:   metadata,
+		Choice:     choice,
 	}
 
 	// Auto-trigger typing indicator, message reaction, and placeholder before publishing.
@@ -1,7 +1,9 @@
 package channels
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -263,3 +265,44 @@ func TestIsAllowedSender(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleMessage_LiftsThreadID(t *testing.T) {
+	messageBus := bus.NewMessageBus()
+	ch := NewBaseChannel("test", nil, messageBus, nil)
+
+	ch.HandleMessage(context.Background(), bus.Peer{Kind: "group", ID: "g1"}, "m1", "u1", "g1/t9",
+		"hi", nil, map[string]string{MetadataThreadID: "t9"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := messageBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected an inbound message")
+	}
+	if msg.ThreadID != "t9" {
+		t.Errorf("ThreadID = %q, want t9", msg.ThreadID)
+	}
+}
+
+func TestHandleChoice_LiftsThreadID(t *testing.T) {
+	messageBus := bus.NewMessageBus()
+	ch := NewBaseChannel("test", nil, messageBus, nil)
+
+	if !ch.HandleChoice(context.Background(), bus.Peer{Kind: "group", ID: "g1"}, "m1", "u1", "g1/t9",
+		ChoiceData("p1", 1), "Yes", map[string]string{MetadataThreadID: "t9"}, bus.SenderInfo{}) {
+		t.Fatal("expected choice data to be handled")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := messageBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected an inbound message")
+	}
+	if msg.ThreadID != "t9" {
+		t.Errorf("ThreadID = %q, want t9", msg.ThreadID)
+	}
+	if msg.Choice == nil || msg.Choice.PromptID != "p1" || msg.Choice.Option != 1 {
+		t.Errorf("Choice = %+v, want prompt p1 option 1", msg.Choice)
+	}
+}
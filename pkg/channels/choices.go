@@ -0,0 +1,44 @@
+package channels
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+const choiceDataPrefix = "choice:"
+
+// ChoiceData encodes a prompt option as button callback data, e.g.
+// "choice:3f9a1c2e:2". It stays well below Telegram's 64-byte limit.
+func ChoiceData(promptID string, option int) string {
+	return choiceDataPrefix + promptID + ":" + strconv.Itoa(option)
+}
+
+// ParseChoiceData decodes callback data made by ChoiceData.
+func ParseChoiceData(data string) (promptID string, option int, ok bool) {
+	rest, found := strings.CutPrefix(data, choiceDataPrefix)
+	if !found {
+		return "", 0, false
+	}
+	promptID, num, found := strings.Cut(rest, ":")
+	option, err := strconv.Atoi(num)
+	if !found || promptID == "" || err != nil || option < 1 {
+		return "", 0, false
+	}
+	return promptID, option, true
+}
+
+// FormatChoiceMenu renders a prompt as text with a numbered list of
+// options, for channels without buttons.
+func FormatChoiceMenu(content string, prompt *bus.ChoicePrompt) string {
+	var b strings.Builder
+	b.WriteString(content)
+	b.WriteString("\n")
+	for i, option := range prompt.Options {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, option)
+	}
+	b.WriteString("\n\nReply with the number of your choice.")
+	return b.String()
+}
@@ -0,0 +1,30 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestChoiceData_RoundTrip(t *testing.T) {
+	promptID, option, ok := ParseChoiceData(ChoiceData("3f9a1c2e", 2))
+	if !ok || promptID != "3f9a1c2e" || option != 2 {
+		t.Fatalf("ParseChoiceData = %q, %d, %v", promptID, option, ok)
+	}
+
+	for _, data := range []string{"", "choice:", "choice:p1", "choice::1", "choice:p1:0", "choice:p1:x", "other:p1:1"} {
+		if _, _, ok := ParseChoiceData(data); ok {
+			t.Errorf("ParseChoiceData(%q) should fail", data)
+		}
+	}
+}
+
+func TestFormatChoiceMenu(t *testing.T) {
+	got := FormatChoiceMenu("Deploy now?", &bus.ChoicePrompt{ID: "p1", Options: []string{"Yes", "No"}})
+	for _, want := range []string{"Deploy now?\n", "\n1. Yes", "\n2. No"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("menu %q does not contain %q", got, want)
+		}
+	}
+}
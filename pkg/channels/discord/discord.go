@@ -63,6 +63,7 @@ func NewDiscordChannel(cfg config.DiscordConfig, bus *bus.MessageBus) (*DiscordC
 	}
 	base := channels.NewBaseChannel("discord", cfg, bus, cfg.AllowFrom,
 		channels.WithMaxMessageLength(2000),
+		channels.WithTextFormat(channels.FormatDiscord),
 		channels.WithGroupTrigger(cfg.GroupTrigger),
 		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
 	)
@@ -89,6 +90,7 @@ func (c *DiscordChannel) Start(ctx context.Context) error {
 	c.botUserID = botUser.ID
 
 	c.session.AddHandler(c.handleMessage)
+	c.session.AddHandler(c.handleInteraction)
 
 	if err := c.session.Open(); err != nil {
 		return fmt.Errorf("failed to open discord session: %w", err)
@@ -142,7 +144,7 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 		return nil
 	}
 
-	return c.sendChunk(ctx, channelID, msg.Content, msg.ReplyToMessageID)
+	return c.sendChunk(ctx, channelID, channels.RenderMarkdown(msg.Content, channels.FormatDiscord), msg.ReplyToMessageID)
 }
 
 // SendMedia implements the channels.MediaSender interface.
@@ -242,7 +244,7 @@ func (c *DiscordChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMes
 
 // EditMessage implements channels.MessageEditor.
 func (c *DiscordChannel) EditMessage(ctx context.Context, chatID string, messageID string, content string) error {
-	_, err := c.session.ChannelMessageEdit(chatID, messageID, content)
+	_, err := c.session.ChannelMessageEdit(chatID, messageID, channels.RenderMarkdown(content, channels.FormatDiscord))
 	return err
 }
 
@@ -304,6 +306,126 @@ func (c *DiscordChannel) sendChunk(ctx context.Context, channelID, content, repl
 	}
 }
 
+// SendChoices implements channels.ChoiceCapable with one button per option,
+// five to a row as Discord allows.
+func (c *DiscordChannel) SendChoices(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	if msg.ChatID == "" {
+		return fmt.Errorf("channel ID is empty")
+	}
+
+	send := &discordgo.MessageSend{
+		Content:    channels.RenderMarkdown(msg.Content, channels.FormatDiscord),
+		Components: choiceComponents(msg.Prompt),
+	}
+	if msg.ReplyToMessageID != "" {
+		send.Reference = &discordgo.MessageReference{MessageID: msg.ReplyToMessageID, ChannelID: msg.ChatID}
+	}
+	if _, err := c.session.ChannelMessageSendComplex(msg.ChatID, send, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("discord send: %w", channels.ErrTemporary)
+	}
+	return nil
+}
+
+func choiceComponents(prompt *bus.ChoicePrompt) []discordgo.MessageComponent {
+	const perRow = 5
+	var rows []discordgo.MessageComponent
+	for start := 0; start < len(prompt.Options); start += perRow {
+		row := discordgo.ActionsRow{}
+		for i := start; i < min(start+perRow, len(prompt.Options)); i++ {
+			row.Components = append(row.Components, discordgo.Button{
+				Label:    utils.Truncate(prompt.Options[i], 80),
+				Style:    discordgo.SecondaryButton,
+				CustomID: channels.ChoiceData(prompt.ID, i+1),
+			})
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// handleInteraction handles presses of buttons sent by SendChoices. The
+// buttons are removed afterwards so an option cannot be picked twice.
+func (c *DiscordChannel) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i == nil || i.Type != discordgo.InteractionMessageComponent || i.Message == nil {
+		return
+	}
+	data := i.MessageComponentData()
+	if _, _, ok := channels.ParseChoiceData(data.CustomID); !ok {
+		return
+	}
+
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		logger.DebugCF("discord", "Failed to acknowledge interaction", map[string]any{
+			"error": err.Error(),
+		})
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "discord",
+		PlatformID:  user.ID,
+		CanonicalID: identity.BuildCanonicalID("discord", user.ID),
+		Username:    user.Username,
+		DisplayName: user.Username,
+	}
+	if !c.IsAllowedSender(sender) {
+		return
+	}
+
+	peer := bus.Peer{Kind: "channel", ID: i.ChannelID}
+	if i.GuildID == "" {
+		peer = bus.Peer{Kind: "direct", ID: user.ID}
+	}
+	var metadata map[string]string
+	if i.GuildID != "" {
+		if parentID := threadParentID(s, i.ChannelID); parentID != "" {
+			metadata = map[string]string{
+				channels.MetadataThreadID: i.ChannelID,
+				"parent_peer_kind":        "channel",
+				"parent_peer_id":          parentID,
+			}
+		}
+	}
+	label := choiceButtonLabel(i.Message.Components, data.CustomID)
+	c.HandleChoice(c.ctx, peer, i.Message.ID, user.ID, i.ChannelID, data.CustomID, label, metadata, sender)
+
+	edit := discordgo.NewMessageEdit(i.ChannelID, i.Message.ID)
+	edit.Components = &[]discordgo.MessageComponent{}
+	if _, err := s.ChannelMessageEditComplex(edit); err != nil {
+		logger.DebugCF("discord", "Failed to remove choice buttons", map[string]any{
+			"error": err.Error(),
+		})
+	}
+}
+
+// choiceButtonLabel returns the label of the button whose custom ID is id.
+func choiceButtonLabel(components []discordgo.MessageComponent, id string) string {
+	for _, component := range components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, inner := range row.Components {
+			if button, ok := inner.(*discordgo.Button); ok && button.CustomID == id {
+				return button.Label
+			}
+		}
+	}
+	return ""
+}
+
 // appendContent safely appends content to existing text
 func appendContent(content, suffix string) string {
 	if content == "" {
@@ -449,6 +571,15 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		peerID = senderID
 	}
 
+	// A thread is a channel of its own and stays its own peer, as it always
+	// has been, so its sessions and bindings on its ID carry on. Its parent
+	// channel goes along so that parent bindings apply and a shared thread
+	// scope can fold it into the parent's session.
+	parentID := ""
+	if m.GuildID != "" {
+		parentID = threadParentID(s, m.ChannelID)
+	}
+
 	peer := bus.Peer{Kind: peerKind, ID: peerID}
 
 	metadata := map[string]string{
@@ -459,10 +590,41 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		"channel_id":   m.ChannelID,
 		"is_dm":        fmt.Sprintf("%t", m.GuildID == ""),
 	}
+	if parentID != "" {
+		metadata[channels.MetadataThreadID] = m.ChannelID
+		metadata["parent_peer_kind"] = peerKind
+		metadata["parent_peer_id"] = parentID
+	}
 
 	c.HandleMessage(c.ctx, peer, m.ID, senderID, m.ChannelID, content, mediaPaths, metadata, sender)
 }
 
+// threadParentID returns the parent channel of channelID if it is a thread,
+// or "" otherwise. Threads missing from the state cache are fetched once.
+func threadParentID(s *discordgo.Session, channelID string) string {
+	if s == nil {
+		return ""
+	}
+	var ch *discordgo.Channel
+	if s.State != nil {
+		ch, _ = s.State.Channel(channelID)
+	}
+	if ch == nil {
+		fetched, err := s.Channel(channelID)
+		if err != nil {
+			return ""
+		}
+		ch = fetched
+		if s.State != nil {
+			_ = s.State.ChannelAdd(ch)
+		}
+	}
+	if ch.IsThread() {
+		return ch.ParentID
+	}
+	return ""
+}
+
 // startTyping starts a continuous typing indicator loop for the given chatID.
 // It stops any existing typing loop for that chatID before starting a new one.
 func (c *DiscordChannel) startTyping(chatID string) {
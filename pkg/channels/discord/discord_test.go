@@ -1,11 +1,15 @@
 package discord
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"testing"
 
 	"github.com/bwmarrin/discordgo"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
 )
 
 func TestApplyDiscordProxy_CustomProxy(t *testing.T) {
@@ -89,3 +93,48 @@ func TestApplyDiscordProxy_InvalidProxyURL(t *testing.T) {
 		t.Fatal("applyDiscordProxy() expected error for invalid proxy URL, got nil")
 	}
 }
+
+func TestChoiceComponents_RoundTrip(t *testing.T) {
+	prompt := &bus.ChoicePrompt{ID: "p1", Options: []string{"a", "b", "c", "d", "e", "f"}}
+	components := choiceComponents(prompt)
+	if len(components) != 2 {
+		t.Fatalf("rows = %d, want 2", len(components))
+	}
+
+	raw, err := json.Marshal(discordgo.MessageSend{Components: components})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var msg discordgo.Message
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got := choiceButtonLabel(msg.Components, channels.ChoiceData("p1", 6)); got != "f" {
+		t.Fatalf("label = %q, want f", got)
+	}
+	if got := choiceButtonLabel(msg.Components, channels.ChoiceData("p2", 1)); got != "" {
+		t.Fatalf("label for unknown prompt = %q, want empty", got)
+	}
+}
+
+func TestThreadParentID(t *testing.T) {
+	session := &discordgo.Session{State: discordgo.NewState()}
+	if err := session.State.GuildAdd(&discordgo.Guild{ID: "g1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range []*discordgo.Channel{
+		{ID: "c1", GuildID: "g1", Type: discordgo.ChannelTypeGuildText},
+		{ID: "t1", GuildID: "g1", Type: discordgo.ChannelTypeGuildPublicThread, ParentID: "c1"},
+	} {
+		if err := session.State.ChannelAdd(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := threadParentID(session, "t1"); got != "c1" {
+		t.Errorf("threadParentID(thread) = %q, want c1", got)
+	}
+	if got := threadParentID(session, "c1"); got != "" {
+		t.Errorf("threadParentID(channel) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,538 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+const (
+	defaultMailbox      = "INBOX"
+	defaultPollInterval = 60 * time.Second
+	commandTimeout      = 60 * time.Second
+	// idleTimeout re-issues IDLE before servers' 30-minute inactivity limit.
+	idleTimeout = 25 * time.Minute
+	maxBackoff  = 5 * time.Minute
+)
+
+// thread is what a reply to a chat needs: the counterpart, the subject and
+// the IDs for In-Reply-To and References.
+type thread struct {
+	To         string
+	Subject    string
+	LastID     string
+	References []string
+}
+
+// EmailChannel reads mail over IMAP (IDLE, or polling when the server lacks
+// it) and replies over SMTP. Each mail thread is one chat whose ID is the
+// Message-ID of the thread's first message.
+type EmailChannel struct {
+	*channels.BaseChannel
+	config      config.EmailConfig
+	address     string
+	allowFrom   []string
+	idleTimeout time.Duration
+
+	threadsMu sync.Mutex
+	threads   map[string]*thread
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewEmailChannel creates a new email channel.
+func NewEmailChannel(cfg config.EmailConfig, messageBus *bus.MessageBus) (*EmailChannel, error) {
+	if cfg.IMAPServer == "" {
+		return nil, fmt.Errorf("email imap_server is required")
+	}
+	if cfg.SMTPServer == "" {
+		return nil, fmt.Errorf("email smtp_server is required")
+	}
+	if cfg.Username == "" {
+		return nil, fmt.Errorf("email username is required")
+	}
+	address := cfg.Address
+	if address == "" {
+		address = cfg.Username
+	}
+	if _, err := mail.ParseAddress(address); err != nil {
+		return nil, fmt.Errorf("email address %q: %w", address, err)
+	}
+
+	// Addresses are matched by isAllowedAddress, so BaseChannel gets no
+	// allow-list of its own.
+	base := channels.NewBaseChannel("email", cfg, messageBus, nil,
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+	return &EmailChannel{
+		BaseChannel: base,
+		config:      cfg,
+		address:     strings.ToLower(address),
+		allowFrom:   cfg.AllowFrom,
+		idleTimeout: idleTimeout,
+		threads:     make(map[string]*thread),
+	}, nil
+}
+
+// Start begins receiving mail in the background.
+func (c *EmailChannel) Start(ctx context.Context) error {
+	logger.InfoC("email", "Starting email channel")
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	go c.receive(c.ctx)
+
+	c.SetRunning(true)
+	logger.InfoCF("email", "Email channel started", map[string]any{
+		"imap_server": c.config.IMAPServer,
+		"address":     c.address,
+	})
+	return nil
+}
+
+// Stop stops receiving mail.
+func (c *EmailChannel) Stop(ctx context.Context) error {
+	logger.InfoC("email", "Stopping email channel")
+	c.SetRunning(false)
+	if c.cancel != nil {
+		c.cancel()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+		}
+	}
+	logger.InfoC("email", "Email channel stopped")
+	return nil
+}
+
+// IsAllowed reports whether mail from address may reach the agent.
+func (c *EmailChannel) IsAllowed(senderID string) bool {
+	return c.isAllowedAddress(senderID)
+}
+
+// IsAllowedSender reports whether mail from sender may reach the agent.
+func (c *EmailChannel) IsAllowedSender(sender bus.SenderInfo) bool {
+	return c.isAllowedAddress(sender.PlatformID)
+}
+
+// isAllowedAddress matches address case-insensitively against allow_from
+// entries: full addresses, "@domain" or "*@domain", and "*".
+func (c *EmailChannel) isAllowedAddress(address string) bool {
+	if len(c.allowFrom) == 0 {
+		return true
+	}
+	address = strings.ToLower(strings.TrimSpace(address))
+	for _, entry := range c.allowFrom {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		entry = strings.TrimPrefix(entry, "email:")
+		entry = strings.TrimPrefix(entry, "*")
+		switch {
+		case entry == "":
+			return true
+		case strings.HasPrefix(entry, "@"):
+			if strings.HasSuffix(address, entry) {
+				return true
+			}
+		case entry == address:
+			return true
+		}
+	}
+	return false
+}
+
+// receive keeps an IMAP session open until ctx is done, reconnecting with
+// backoff after errors.
+func (c *EmailChannel) receive(ctx context.Context) {
+	defer close(c.done)
+	backoff := time.Second
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := c.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > maxBackoff {
+			backoff = time.Second
+		}
+		logger.WarnCF("email", "IMAP session ended, reconnecting", map[string]any{
+			"error":   fmt.Sprint(err),
+			"backoff": backoff.String(),
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c *EmailChannel) session(ctx context.Context) error {
+	cl, err := dialIMAP(c.config.IMAPServer, c.config.IMAPTLS, commandTimeout)
+	if err != nil {
+		return err
+	}
+	defer cl.close()
+	// Closing the connection unblocks a pending read, e.g. in IDLE.
+	stop := context.AfterFunc(ctx, func() { cl.close() })
+	defer stop()
+
+	if err := cl.capability(); err != nil {
+		return err
+	}
+	if !cl.secure && cl.caps["STARTTLS"] {
+		if err := cl.startTLS(hostOf(c.config.IMAPServer)); err != nil {
+			return err
+		}
+		if err := cl.capability(); err != nil {
+			return err
+		}
+	}
+	if err := cl.login(c.config.Username, c.config.Password); err != nil {
+		return err
+	}
+	// Servers may announce more capabilities once logged in.
+	if err := cl.capability(); err != nil {
+		return err
+	}
+	mailbox := c.config.Mailbox
+	if mailbox == "" {
+		mailbox = defaultMailbox
+	}
+	if err := cl.selectMailbox(mailbox); err != nil {
+		return err
+	}
+	defer cl.logout()
+
+	poll := defaultPollInterval
+	if c.config.PollInterval > 0 {
+		poll = time.Duration(c.config.PollInterval) * time.Second
+	}
+	useIdle := cl.caps["IDLE"]
+	logger.InfoCF("email", "IMAP session ready", map[string]any{"mailbox": mailbox, "idle": useIdle})
+
+	for {
+		if err := c.fetchUnseen(ctx, cl); err != nil {
+			return err
+		}
+		if useIdle {
+			if err := cl.idle(c.idleTimeout); err != nil {
+				return err
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+		if err := cl.noop(); err != nil {
+			return err
+		}
+	}
+}
+
+// fetchUnseen delivers every unseen message and marks it seen.
+func (c *EmailChannel) fetchUnseen(ctx context.Context, cl *imapClient) error {
+	uids, err := cl.searchUnseen()
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		raw, err := cl.fetch(uid)
+		if err != nil {
+			return err
+		}
+		c.handleMail(ctx, raw)
+		if err := cl.markSeen(uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *EmailChannel) handleMail(ctx context.Context, raw []byte) {
+	m, err := parseMail(raw)
+	if err != nil {
+		logger.WarnCF("email", "Skipping unparsable mail", map[string]any{"error": err.Error()})
+		return
+	}
+	if m.From == "" || m.From == c.address || m.Automated {
+		return
+	}
+	if !c.isAllowedAddress(m.From) {
+		logger.DebugCF("email", "Mail from sender not in allow_from", map[string]any{"from": m.From})
+		return
+	}
+	if m.MessageID == "" {
+		m.MessageID = newMessageID(m.From)
+	}
+	chatID := m.threadID()
+
+	refs := append(append([]string(nil), m.References...), m.MessageID)
+	if len(m.References) == 0 && m.InReplyTo != "" {
+		refs = []string{m.InReplyTo, m.MessageID}
+	}
+	c.threadsMu.Lock()
+	c.threads[chatID] = &thread{
+		To:         m.From,
+		Subject:    m.Subject,
+		LastID:     m.MessageID,
+		References: trimReferences(refs),
+	}
+	c.threadsMu.Unlock()
+
+	content := m.Text
+	if m.InReplyTo == "" && m.Subject != "" {
+		content = "Subject: " + m.Subject + "\n\n" + content
+	}
+	scope := channels.BuildMediaScope("email", chatID, m.MessageID)
+	var mediaRefs []string
+	for _, a := range m.Attachments {
+		if ref := c.storeAttachment(a, scope); ref != "" {
+			mediaRefs = append(mediaRefs, ref)
+		}
+		content += fmt.Sprintf("\n[attachment: %s]", a.Filename)
+	}
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "email",
+		PlatformID:  m.From,
+		CanonicalID: identity.BuildCanonicalID("email", m.From),
+		Username:    m.From,
+		DisplayName: m.FromName,
+	}
+	metadata := map[string]string{
+		"platform":   "email",
+		"subject":    m.Subject,
+		"message_id": m.MessageID,
+	}
+	c.HandleMessage(ctx, bus.Peer{Kind: "direct", ID: m.From},
+		m.MessageID, m.From, chatID, strings.TrimSpace(content), mediaRefs, metadata, sender)
+}
+
+// storeAttachment writes an attachment to the media temp dir and registers
+// it in the MediaStore. It returns the media ref, or "" on failure.
+func (c *EmailChannel) storeAttachment(a attachment, scope string) string {
+	store := c.GetMediaStore()
+	if store == nil {
+		return ""
+	}
+	dir := media.TempDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		logger.WarnCF("email", "Failed to create media directory", map[string]any{"error": err.Error()})
+		return ""
+	}
+	tmp, err := os.CreateTemp(dir, "email-*"+filepath.Ext(a.Filename))
+	if err == nil {
+		_, err = tmp.Write(a.Data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		logger.WarnCF("email", "Failed to save attachment", map[string]any{
+			"filename": a.Filename,
+			"error":    err.Error(),
+		})
+		return ""
+	}
+	ref, err := store.Store(tmp.Name(), media.MediaMeta{
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		Source:      "email",
+	}, scope)
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		logger.WarnCF("email", "Failed to store attachment", map[string]any{
+			"filename": a.Filename,
+			"error":    err.Error(),
+		})
+		return ""
+	}
+	return ref
+}
+
+// Send replies in the thread chatID. A chat ID without a known thread that
+// is a mail address starts a new thread with that address.
+func (c *EmailChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+	return c.reply(msg.ChatID, msg.Content, nil)
+}
+
+// SendMedia replies in the thread with the parts as attachments.
+func (c *EmailChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		return fmt.Errorf("no media store available: %w", channels.ErrSendFailed)
+	}
+
+	var text []string
+	var attachments []attachment
+	for _, part := range msg.Parts {
+		localPath, meta, err := store.ResolveWithMeta(part.Ref)
+		if err != nil {
+			logger.ErrorCF("email", "Failed to resolve media ref", map[string]any{
+				"ref":   part.Ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return fmt.Errorf("read attachment: %v: %w", err, channels.ErrSendFailed)
+		}
+		filename := part.Filename
+		if filename == "" {
+			filename = meta.Filename
+		}
+		if filename == "" {
+			filename = filepath.Base(localPath)
+		}
+		contentType := part.ContentType
+		if contentType == "" {
+			contentType = meta.ContentType
+		}
+		attachments = append(attachments, attachment{Filename: filename, ContentType: contentType, Data: data})
+		if part.Caption != "" {
+			text = append(text, part.Caption)
+		}
+	}
+	if len(attachments) == 0 {
+		return fmt.Errorf("no attachments to send: %w", channels.ErrSendFailed)
+	}
+	return c.reply(msg.ChatID, strings.Join(text, "\n\n"), attachments)
+}
+
+func (c *EmailChannel) reply(chatID, text string, attachments []attachment) error {
+	if chatID == "" {
+		return fmt.Errorf("chat ID is empty: %w", channels.ErrSendFailed)
+	}
+
+	c.threadsMu.Lock()
+	t, ok := c.threads[chatID]
+	if !ok {
+		addr, err := mail.ParseAddress(chatID)
+		if err != nil {
+			c.threadsMu.Unlock()
+			return fmt.Errorf("unknown email thread %q: %w", chatID, channels.ErrSendFailed)
+		}
+		t = &thread{To: strings.ToLower(addr.Address)}
+		c.threads[chatID] = t
+	}
+	out := outboundMail{
+		From:        c.address,
+		To:          t.To,
+		Subject:     replySubject(t.Subject),
+		MessageID:   newMessageID(c.address),
+		InReplyTo:   t.LastID,
+		References:  t.References,
+		Text:        text,
+		Attachments: attachments,
+		Date:        time.Now(),
+	}
+	if !ok {
+		out.Subject = "Message from your assistant"
+	}
+	t.LastID = out.MessageID
+	t.References = trimReferences(append(append([]string(nil), t.References...), out.MessageID))
+	c.threadsMu.Unlock()
+
+	data, err := buildMail(out)
+	if err != nil {
+		return fmt.Errorf("build mail: %v: %w", err, channels.ErrSendFailed)
+	}
+	if err := c.deliver(out.To, data); err != nil {
+		return fmt.Errorf("smtp: %v: %w", err, channels.ErrTemporary)
+	}
+	logger.DebugCF("email", "Mail sent", map[string]any{
+		"to":          out.To,
+		"chat_id":     chatID,
+		"attachments": len(attachments),
+	})
+	return nil
+}
+
+// deliver sends data to rcpt over SMTP, with implicit TLS when smtp_tls is
+// set and STARTTLS when the server offers it otherwise.
+func (c *EmailChannel) deliver(rcpt string, data []byte) error {
+	host := hostOf(c.config.SMTPServer)
+	tlsConfig := &tls.Config{ServerName: host}
+	dialer := &net.Dialer{Timeout: commandTimeout}
+
+	var conn net.Conn
+	var err error
+	if c.config.SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.config.SMTPServer, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", c.config.SMTPServer)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(2 * commandTimeout))
+
+	cl, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer cl.Close()
+
+	if !c.config.SMTPTLS {
+		if ok, _ := cl.Extension("STARTTLS"); ok {
+			if err := cl.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if ok, _ := cl.Extension("AUTH"); ok && c.config.Password != "" {
+		if err := cl.Auth(smtp.PlainAuth("", c.config.Username, c.config.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := cl.Mail(c.address); err != nil {
+		return err
+	}
+	if err := cl.Rcpt(rcpt); err != nil {
+		return err
+	}
+	w, err := cl.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return cl.Quit()
+}
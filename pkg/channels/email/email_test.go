@@ -0,0 +1,516 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+// fakeIMAP is an in-process IMAP server with one mailbox.
+type fakeIMAP struct {
+	ln     net.Listener
+	idle   bool
+	mu     sync.Mutex
+	msgs   []*fakeMessage
+	nextID uint32
+	notify chan struct{}
+}
+
+type fakeMessage struct {
+	uid  uint32
+	raw  string
+	seen bool
+}
+
+func newFakeIMAP(t *testing.T, idle bool) *fakeIMAP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeIMAP{ln: ln, idle: idle, nextID: 1, notify: make(chan struct{}, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeIMAP) deliver(raw string) {
+	s.mu.Lock()
+	s.msgs = append(s.msgs, &fakeMessage{uid: s.nextID, raw: strings.ReplaceAll(raw, "\n", "\r\n")})
+	s.nextID++
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *fakeIMAP) unseen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, m := range s.msgs {
+		if !m.seen {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *fakeIMAP) serve(conn net.Conn) {
+	defer conn.Close()
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+	w := func(format string, args ...any) { fmt.Fprintf(conn, format+"\r\n", args...) }
+
+	w("* OK fake IMAP ready")
+	for line := range lines {
+		tag, cmd, _ := strings.Cut(line, " ")
+		upper := strings.ToUpper(cmd)
+		switch {
+		case upper == "CAPABILITY":
+			if s.idle {
+				w("* CAPABILITY IMAP4rev1 IDLE")
+			} else {
+				w("* CAPABILITY IMAP4rev1")
+			}
+			w("%s OK done", tag)
+		case strings.HasPrefix(upper, "LOGIN "):
+			if cmd[len("LOGIN "):] != `"bot@example.com" "secret"` {
+				w("%s NO bad credentials", tag)
+				continue
+			}
+			w("%s OK logged in", tag)
+		case strings.HasPrefix(upper, "SELECT "):
+			s.mu.Lock()
+			w("* %d EXISTS", len(s.msgs))
+			s.mu.Unlock()
+			w("%s OK [READ-WRITE] selected", tag)
+		case upper == "UID SEARCH UNSEEN":
+			s.mu.Lock()
+			var uids []string
+			for _, m := range s.msgs {
+				if !m.seen {
+					uids = append(uids, fmt.Sprint(m.uid))
+				}
+			}
+			s.mu.Unlock()
+			w("* SEARCH %s", strings.Join(uids, " "))
+			w("%s OK search done", tag)
+		case strings.HasPrefix(upper, "UID FETCH "):
+			var uid uint32
+			fmt.Sscanf(cmd, "UID FETCH %d", &uid)
+			s.mu.Lock()
+			for i, m := range s.msgs {
+				if m.uid == uid {
+					fmt.Fprintf(conn, "* %d FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", i+1, uid, len(m.raw), m.raw)
+				}
+			}
+			s.mu.Unlock()
+			w("%s OK fetch done", tag)
+		case strings.HasPrefix(upper, "UID STORE "):
+			var uid uint32
+			fmt.Sscanf(cmd, "UID STORE %d", &uid)
+			s.mu.Lock()
+			for _, m := range s.msgs {
+				if m.uid == uid {
+					m.seen = true
+				}
+			}
+			s.mu.Unlock()
+			w("%s OK store done", tag)
+		case upper == "NOOP":
+			w("%s OK noop", tag)
+		case upper == "IDLE":
+			w("+ idling")
+			select {
+			case <-s.notify:
+				s.mu.Lock()
+				w("* %d EXISTS", len(s.msgs))
+				s.mu.Unlock()
+				<-lines // DONE
+			case <-lines: // DONE after the client's timeout
+			}
+			w("%s OK idle done", tag)
+		case upper == "LOGOUT":
+			w("* BYE")
+			w("%s OK bye", tag)
+			return
+		default:
+			w("%s BAD unknown command", tag)
+		}
+	}
+}
+
+// fakeSMTP is an in-process SMTP server that records delivered messages.
+type fakeSMTP struct {
+	ln   net.Listener
+	sent chan smtpDelivery
+}
+
+type smtpDelivery struct {
+	rcpt string
+	data string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, sent: make(chan smtpDelivery, 10)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	var d smtpDelivery
+
+	w("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			w("250-fake")
+			w("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			w("235 authenticated")
+		case strings.HasPrefix(cmd, "MAIL FROM"), strings.HasPrefix(cmd, "RSET"):
+			w("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			d.rcpt = strings.Trim(strings.TrimSpace(line[len("RCPT TO:"):]), "<>")
+			w("250 ok")
+		case cmd == "DATA":
+			w("354 go ahead")
+			var sb strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				sb.WriteString(l)
+			}
+			d.data = sb.String()
+			s.sent <- d
+			w("250 queued")
+		case cmd == "QUIT":
+			w("221 bye")
+			return
+		default:
+			w("502 unknown")
+		}
+	}
+}
+
+func (s *fakeSMTP) next(t *testing.T) smtpDelivery {
+	t.Helper()
+	select {
+	case d := <-s.sent:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail delivered")
+		return smtpDelivery{}
+	}
+}
+
+const newThreadMail = `From: Alice <Alice@Example.com>
+To: bot@example.com
+Subject: Hello
+Message-ID: <root-1@example.com>
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="b1"
+
+--b1
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Please summarize the attached notes=2E
+--b1
+Content-Type: text/plain; name="notes.txt"
+Content-Disposition: attachment; filename="notes.txt"
+Content-Transfer-Encoding: base64
+
+c29tZSBub3Rlcw==
+--b1--
+`
+
+const replyMail = `From: alice@example.com
+To: bot@example.com
+Subject: Re: Hello
+Message-ID: <reply-2@example.com>
+In-Reply-To: <bot-1@example.com>
+References: <root-1@example.com> <bot-1@example.com>
+Content-Type: text/plain
+
+Thanks, and one more thing.
+
+On Mon, 1 Jan 2026 Bot wrote:
+> earlier answer
+`
+
+func newTestChannel(t *testing.T, imapAddr, smtpAddr string) (*EmailChannel, *bus.MessageBus) {
+	t.Helper()
+	mb := bus.NewMessageBus()
+	ch, err := NewEmailChannel(config.EmailConfig{
+		Enabled:      true,
+		IMAPServer:   imapAddr,
+		SMTPServer:   smtpAddr,
+		Username:     "bot@example.com",
+		Password:     "secret",
+		PollInterval: 1,
+		AllowFrom:    config.FlexibleStringSlice{"@example.com"},
+	}, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.SetMediaStore(media.NewFileMediaStore())
+	return ch, mb
+}
+
+func nextInbound(t *testing.T, mb *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func TestEmailChannel_ThreadsAndReplies(t *testing.T) {
+	imapSrv := newFakeIMAP(t, true)
+	smtpSrv := newFakeSMTP(t)
+	ch, mb := newTestChannel(t, imapSrv.ln.Addr().String(), smtpSrv.ln.Addr().String())
+
+	imapSrv.deliver(newThreadMail)
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Stop(context.Background())
+
+	first := nextInbound(t, mb)
+	if first.ChatID != "root-1@example.com" {
+		t.Errorf("ChatID = %q, want the thread root", first.ChatID)
+	}
+	if first.SenderID != "email:alice@example.com" {
+		t.Errorf("SenderID = %q", first.SenderID)
+	}
+	if !strings.Contains(first.Content, "Subject: Hello") ||
+		!strings.Contains(first.Content, "Please summarize the attached notes.") {
+		t.Errorf("unexpected content %q", first.Content)
+	}
+	if len(first.Media) != 1 {
+		t.Fatalf("Media = %v, want one attachment", first.Media)
+	}
+	path, meta, err := ch.GetMediaStore().ResolveWithMeta(first.Media[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "some notes" || meta.Filename != "notes.txt" {
+		t.Errorf("attachment = %q (%s), want notes.txt with its content", data, meta.Filename)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: first.ChatID, Content: "Here is the summary."}); err != nil {
+		t.Fatal(err)
+	}
+	sent := smtpSrv.next(t)
+	out, err := parseMail([]byte(sent.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.rcpt != "alice@example.com" || out.Subject != "Re: Hello" ||
+		out.InReplyTo != "root-1@example.com" || out.threadID() != "root-1@example.com" {
+		t.Errorf("reply to %s: subject %q, in-reply-to %q, references %v",
+			sent.rcpt, out.Subject, out.InReplyTo, out.References)
+	}
+	if out.Text != "Here is the summary." {
+		t.Errorf("reply text = %q", out.Text)
+	}
+
+	// Mail from outside allow_from and auto-replies are dropped; the reply
+	// lands in the same chat with the quoted text stripped.
+	imapSrv.deliver("From: mallory@evil.test\nSubject: hi\nMessage-ID: <x@evil.test>\n\nlet me in\n")
+	imapSrv.deliver("From: alice@example.com\nSubject: Out of office\nAuto-Submitted: auto-replied\n" +
+		"Message-ID: <ooo@example.com>\nIn-Reply-To: <root-1@example.com>\n\nI am away.\n")
+	imapSrv.deliver(replyMail)
+
+	second := nextInbound(t, mb)
+	if second.ChatID != first.ChatID {
+		t.Errorf("reply ChatID = %q, want %q", second.ChatID, first.ChatID)
+	}
+	if second.Content != "Thanks, and one more thing." {
+		t.Errorf("reply content = %q", second.Content)
+	}
+	for deadline := time.Now().Add(5 * time.Second); imapSrv.unseen() > 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d messages left unseen", imapSrv.unseen())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: first.ChatID, Content: "Noted."}); err != nil {
+		t.Fatal(err)
+	}
+	out, err = parseMail([]byte(smtpSrv.next(t).data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.InReplyTo != "reply-2@example.com" {
+		t.Errorf("In-Reply-To = %q, want the latest message", out.InReplyTo)
+	}
+	if len(out.References) < 2 || out.References[0] != "root-1@example.com" ||
+		out.References[len(out.References)-1] != "reply-2@example.com" {
+		t.Errorf("References = %v", out.References)
+	}
+}
+
+func TestEmailChannel_PollingAndSendMedia(t *testing.T) {
+	imapSrv := newFakeIMAP(t, false)
+	smtpSrv := newFakeSMTP(t)
+	ch, mb := newTestChannel(t, imapSrv.ln.Addr().String(), smtpSrv.ln.Addr().String())
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Stop(context.Background())
+
+	imapSrv.deliver("From: bob@example.com\nSubject: Chart\nMessage-ID: <chart@example.com>\n\nSend me the chart.\n")
+	in := nextInbound(t, mb)
+
+	chart := filepath.Join(t.TempDir(), "chart.png")
+	if err := os.WriteFile(chart, []byte("\x89PNG fake"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := ch.GetMediaStore().Store(chart, media.MediaMeta{Filename: "chart.png", ContentType: "image/png"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ch.SendMedia(context.Background(), bus.OutboundMediaMessage{
+		Channel: "email",
+		ChatID:  in.ChatID,
+		Parts:   []bus.MediaPart{{Type: "image", Ref: ref, Caption: "Your chart"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := parseMail([]byte(smtpSrv.next(t).data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.InReplyTo != "chart@example.com" || out.Text != "Your chart" {
+		t.Errorf("in-reply-to %q, text %q", out.InReplyTo, out.Text)
+	}
+	if len(out.Attachments) != 1 || out.Attachments[0].Filename != "chart.png" ||
+		out.Attachments[0].ContentType != "image/png" || string(out.Attachments[0].Data) != "\x89PNG fake" {
+		t.Errorf("attachments = %+v", out.Attachments)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "no-such-thread", Content: "hi"}); err == nil {
+		t.Error("expected an error for an unknown thread")
+	}
+}
+
+func TestIsAllowedAddress(t *testing.T) {
+	ch, err := NewEmailChannel(config.EmailConfig{
+		IMAPServer: "imap.example.com:993",
+		SMTPServer: "smtp.example.com:587",
+		Username:   "bot@example.com",
+		AllowFrom:  config.FlexibleStringSlice{"Boss@Corp.com", "*@partner.org", "email:carol@home.net"},
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]bool{
+		"boss@corp.com":      true,
+		"BOSS@CORP.COM":      true,
+		"intern@corp.com":    false,
+		"anyone@partner.org": true,
+		"x@notpartner.org":   false,
+		"carol@home.net":     true,
+	} {
+		if got := ch.IsAllowedSender(bus.SenderInfo{PlatformID: addr}); got != want {
+			t.Errorf("IsAllowedSender(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestParseMail_ThreadIDAndHTML(t *testing.T) {
+	m, err := parseMail([]byte("From: a@example.com\r\nMessage-ID: <m3@x>\r\nIn-Reply-To: <m2@x>\r\n" +
+		"Content-Type: text/html\r\n\r\n<p>Hello&nbsp;<b>there</b></p><blockquote>old</blockquote>\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.threadID() != "m2@x" {
+		t.Errorf("threadID = %q, want the In-Reply-To ID without References", m.threadID())
+	}
+	if !strings.HasPrefix(m.Text, "Hello there") {
+		t.Errorf("Text = %q", m.Text)
+	}
+}
+
+func TestIMAPLogin_RefusesInsecureConnections(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	// A pipe is neither loopback TCP nor encrypted.
+	cl := &imapClient{conn: clientConn, timeout: time.Second, caps: map[string]bool{}}
+	if err := cl.login("bot", "secret"); err == nil || !strings.Contains(err.Error(), "unencrypted") {
+		t.Errorf("login over a remote plaintext connection: err = %v", err)
+	}
+
+	cl.secure = true
+	cl.caps["LOGINDISABLED"] = true
+	if err := cl.login("bot", "secret"); err == nil || !strings.Contains(err.Error(), "disabled LOGIN") {
+		t.Errorf("login with LOGINDISABLED: err = %v", err)
+	}
+}
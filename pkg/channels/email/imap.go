@@ -0,0 +1,312 @@
+package email
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapClient is a minimal IMAP4rev1 client covering what the channel needs:
+// STARTTLS, LOGIN, SELECT, UID SEARCH/FETCH/STORE and IDLE (RFC 2177).
+type imapClient struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	tag     int
+	caps    map[string]bool
+	timeout time.Duration // per-command deadline
+	secure  bool          // the connection is encrypted
+}
+
+// imapResponse is one untagged response line with its literals.
+type imapResponse struct {
+	text     string
+	literals [][]byte
+}
+
+func dialIMAP(server string, useTLS bool, timeout time.Duration) (*imapClient, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", server, &tls.Config{ServerName: hostOf(server)})
+	} else {
+		conn, err = dialer.Dial("tcp", server)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("imap dial: %w", err)
+	}
+
+	c := &imapClient{conn: conn, r: bufio.NewReader(conn), timeout: timeout, caps: map[string]bool{}, secure: useTLS}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("imap greeting: %s", greeting)
+	}
+	return c, nil
+}
+
+func (c *imapClient) close() error {
+	return c.conn.Close()
+}
+
+// readLine reads one CRLF-terminated line without the line ending.
+func (c *imapClient) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readResponse reads a response line and the literals ({n}) it announces.
+func (c *imapClient) readResponse() (imapResponse, error) {
+	var resp imapResponse
+	var sb strings.Builder
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return resp, err
+		}
+		sb.WriteString(line)
+		n, ok := literalSize(line)
+		if !ok {
+			break
+		}
+		lit := make([]byte, n)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			return resp, err
+		}
+		resp.literals = append(resp.literals, lit)
+	}
+	resp.text = sb.String()
+	return resp, nil
+}
+
+// literalSize reports the size of a literal announced at the end of line.
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	i := strings.LastIndexByte(line, '{')
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(line[i+1:len(line)-1], "+"))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+func (c *imapClient) nextTag() string {
+	c.tag++
+	return fmt.Sprintf("A%03d", c.tag)
+}
+
+// command sends a command and returns its untagged responses. A tagged NO
+// or BAD is returned as an error.
+func (c *imapClient) command(format string, args ...any) ([]imapResponse, error) {
+	tag := c.nextTag()
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+	return c.readUntilTagged(tag)
+}
+
+func (c *imapClient) readUntilTagged(tag string) ([]imapResponse, error) {
+	var untagged []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		rest, ok := strings.CutPrefix(resp.text, tag+" ")
+		if !ok {
+			untagged = append(untagged, resp)
+			continue
+		}
+		if !strings.HasPrefix(strings.ToUpper(rest), "OK") {
+			return nil, fmt.Errorf("imap: %s", rest)
+		}
+		return untagged, nil
+	}
+}
+
+func (c *imapClient) capability() error {
+	resps, err := c.command("CAPABILITY")
+	if err != nil {
+		return err
+	}
+	for _, r := range resps {
+		if rest, ok := strings.CutPrefix(r.text, "* CAPABILITY "); ok {
+			for _, capName := range strings.Fields(rest) {
+				c.caps[strings.ToUpper(capName)] = true
+			}
+		}
+	}
+	return nil
+}
+
+// startTLS upgrades a plaintext connection (RFC 3501 6.2.1). Capabilities
+// must be requested again afterwards.
+func (c *imapClient) startTLS(host string) error {
+	if _, err := c.command("STARTTLS"); err != nil {
+		return fmt.Errorf("imap starttls: %w", err)
+	}
+	tlsConn := tls.Client(c.conn, &tls.Config{ServerName: host})
+	_ = tlsConn.SetDeadline(time.Now().Add(c.timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("imap starttls: %w", err)
+	}
+	c.conn = tlsConn
+	c.r = bufio.NewReader(tlsConn)
+	c.caps = map[string]bool{}
+	c.secure = true
+	return nil
+}
+
+// login sends LOGIN. The password only goes out in the clear to a server
+// on the same host.
+func (c *imapClient) login(username, password string) error {
+	if !c.secure && !isLoopback(c.conn.RemoteAddr()) {
+		return errors.New("imap login: refusing to send the password unencrypted; " +
+			"enable imap_tls or use a server that offers STARTTLS")
+	}
+	if c.caps["LOGINDISABLED"] {
+		return errors.New("imap login: the server has disabled LOGIN on this connection")
+	}
+	_, err := c.command("LOGIN %s %s", quote(username), quote(password))
+	if err != nil {
+		return fmt.Errorf("imap login: %w", err)
+	}
+	return nil
+}
+
+func (c *imapClient) selectMailbox(name string) error {
+	_, err := c.command("SELECT %s", quote(name))
+	if err != nil {
+		return fmt.Errorf("imap select %s: %w", name, err)
+	}
+	return nil
+}
+
+// searchUnseen returns the UIDs of unseen messages.
+func (c *imapClient) searchUnseen() ([]uint32, error) {
+	resps, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, r := range resps {
+		rest, ok := strings.CutPrefix(r.text, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for _, f := range strings.Fields(rest) {
+			if uid, err := strconv.ParseUint(f, 10, 32); err == nil {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// fetch returns the raw RFC 5322 message with the given UID without
+// setting \Seen.
+func (c *imapClient) fetch(uid uint32) ([]byte, error) {
+	resps, err := c.command("UID FETCH %d (BODY.PEEK[])", uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resps {
+		if strings.Contains(r.text, "FETCH") && len(r.literals) > 0 {
+			return r.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap: message %d not returned", uid)
+}
+
+func (c *imapClient) markSeen(uid uint32) error {
+	_, err := c.command(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid)
+	return err
+}
+
+func (c *imapClient) noop() error {
+	_, err := c.command("NOOP")
+	return err
+}
+
+func (c *imapClient) logout() {
+	_, _ = c.command("LOGOUT")
+}
+
+// idle waits in IDLE until the server reports new mail or timeout elapses.
+// Servers drop idle clients after 30 minutes, so timeout should be lower.
+func (c *imapClient) idle(timeout time.Duration) error {
+	tag := c.nextTag()
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := fmt.Fprintf(c.conn, "%s IDLE\r\n", tag); err != nil {
+		return err
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+") {
+		return fmt.Errorf("imap idle: %s", line)
+	}
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		line, err = c.readLine()
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				return err
+			}
+			break
+		}
+		if strings.HasPrefix(line, "* ") && strings.HasSuffix(strings.ToUpper(line), " EXISTS") {
+			break
+		}
+	}
+
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := io.WriteString(c.conn, "DONE\r\n"); err != nil {
+		return err
+	}
+	_, err = c.readUntilTagged(tag)
+	return err
+}
+
+// quote returns s as an IMAP quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// isLoopback reports whether addr is a loopback TCP address.
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// hostOf returns the host part of a host:port address.
+func hostOf(server string) string {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		return server
+	}
+	return host
+}
@@ -0,0 +1,365 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxReferences bounds the References header of replies: the thread root
+// plus the most recent messages.
+const maxReferences = 10
+
+// inboundMail is a parsed incoming message.
+type inboundMail struct {
+	MessageID   string
+	InReplyTo   string
+	References  []string
+	From        string // lower-cased address
+	FromName    string
+	Subject     string
+	Text        string
+	Attachments []attachment
+	Automated   bool // auto-reply, bulk or list mail
+}
+
+type attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// threadID returns the chat ID of the thread a message belongs to: the
+// root of its References chain, else the message it replies to, else the
+// message itself.
+func (m *inboundMail) threadID() string {
+	switch {
+	case len(m.References) > 0:
+		return m.References[0]
+	case m.InReplyTo != "":
+		return m.InReplyTo
+	default:
+		return m.MessageID
+	}
+}
+
+var headerDecoder = &mime.WordDecoder{}
+
+func parseMail(raw []byte) (*inboundMail, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse mail: %w", err)
+	}
+	h := msg.Header
+	m := &inboundMail{
+		MessageID:  firstID(h.Get("Message-ID")),
+		InReplyTo:  firstID(h.Get("In-Reply-To")),
+		References: msgIDs(h.Get("References")),
+	}
+	if subject, err := headerDecoder.DecodeHeader(h.Get("Subject")); err == nil {
+		m.Subject = strings.TrimSpace(subject)
+	} else {
+		m.Subject = strings.TrimSpace(h.Get("Subject"))
+	}
+	if from, err := h.AddressList("From"); err == nil && len(from) > 0 {
+		m.From = strings.ToLower(from[0].Address)
+		m.FromName = from[0].Name
+	}
+	auto := strings.ToLower(h.Get("Auto-Submitted"))
+	precedence := strings.ToLower(h.Get("Precedence"))
+	m.Automated = auto != "" && auto != "no" ||
+		precedence == "bulk" || precedence == "list" || precedence == "junk" ||
+		h.Get("List-Id") != ""
+
+	var plain, htmlBody string
+	err = walkPart(textproto.MIMEHeader(h), msg.Body, func(ct string, disposition, filename string, body []byte) {
+		switch {
+		case disposition != "attachment" && filename == "" && ct == "text/plain":
+			if plain == "" {
+				plain = string(body)
+			}
+		case disposition != "attachment" && filename == "" && ct == "text/html":
+			if htmlBody == "" {
+				htmlBody = string(body)
+			}
+		case strings.HasPrefix(ct, "multipart/"):
+		default:
+			if filename == "" {
+				filename = "attachment" + extensionFor(ct)
+			}
+			m.Attachments = append(m.Attachments, attachment{Filename: filename, ContentType: ct, Data: body})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if plain == "" && htmlBody != "" {
+		plain = htmlToText(htmlBody)
+	}
+	m.Text = stripQuoted(plain)
+	return m, nil
+}
+
+// walkPart decodes a MIME entity and calls visit for every leaf part.
+func walkPart(
+	h textproto.MIMEHeader,
+	body io.Reader,
+	visit func(contentType, disposition, filename string, body []byte),
+) error {
+	ct, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		ct, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(ct, "multipart/") && params["boundary"] != "" {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			// NextRawPart leaves Content-Transfer-Encoding to decodeBody.
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("parse multipart: %w", err)
+			}
+			if err := walkPart(part.Header, part, visit); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeBody(h.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("decode %s part: %w", ct, err)
+	}
+	disposition, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	filename := dparams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	if decoded, err := headerDecoder.DecodeHeader(filename); err == nil {
+		filename = decoded
+	}
+	if filename != "" {
+		filename = filepath.Base(filename)
+	}
+	visit(ct, disposition, filename, data)
+	return nil
+}
+
+func decodeBody(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+func extensionFor(contentType string) string {
+	if exts, err := mime.ExtensionsByType(contentType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+var (
+	idPattern        = regexp.MustCompile(`<([^<>\s]+)>`)
+	htmlBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>|</tr>`)
+	htmlDropPattern  = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+	attributionLine  = regexp.MustCompile(`^On .+wrote:\s*$`)
+)
+
+// msgIDs returns the message IDs in a References-style header without
+// angle brackets.
+func msgIDs(header string) []string {
+	var ids []string
+	for _, m := range idPattern.FindAllStringSubmatch(header, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+func firstID(header string) string {
+	if ids := msgIDs(header); len(ids) > 0 {
+		return ids[0]
+	}
+	return strings.Trim(strings.TrimSpace(header), "<>")
+}
+
+func htmlToText(s string) string {
+	s = htmlDropPattern.ReplaceAllString(s, "")
+	s = htmlBreakPattern.ReplaceAllString(s, "\n")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	return html.UnescapeString(s)
+}
+
+// stripQuoted removes the quoted previous message and the signature a mail
+// client appends to a reply, which the session history already has.
+func stripQuoted(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var kept []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if line == "-- " || trimmed == "-----Original Message-----" || attributionLine.MatchString(trimmed) {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// outboundMail is a reply to build with buildMail.
+type outboundMail struct {
+	From        string
+	To          string
+	Subject     string
+	MessageID   string
+	InReplyTo   string
+	References  []string
+	Text        string
+	Attachments []attachment
+	Date        time.Time
+}
+
+// buildMail renders m as an RFC 5322 message. Text goes out as
+// quoted-printable UTF-8; attachments make it multipart/mixed.
+func buildMail(m outboundMail) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", m.From)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", m.Date.Format(time.RFC1123Z))
+	header("Message-ID", "<"+m.MessageID+">")
+	if m.InReplyTo != "" {
+		header("In-Reply-To", "<"+m.InReplyTo+">")
+	}
+	if len(m.References) > 0 {
+		header("References", "<"+strings.Join(m.References, "> <")+">")
+	}
+	header("MIME-Version", "1.0")
+	// RFC 3834: lets other automated responders skip our replies.
+	header("Auto-Submitted", "auto-replied")
+
+	if len(m.Attachments) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, m.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+	buf.WriteString("\r\n")
+	if m.Text != "" {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, m.Text); err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range m.Attachments {
+		ct := a.ContentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {ct},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition": {
+				mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(w, a.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, strings.ReplaceAll(text, "\n", "\r\n")); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes data base64-encoded in 76-character lines.
+func writeBase64(w io.Writer, data []byte) error {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		if _, err := io.WriteString(w, enc[:76]+"\r\n"); err != nil {
+			return err
+		}
+		enc = enc[76:]
+	}
+	_, err := io.WriteString(w, enc+"\r\n")
+	return err
+}
+
+// newMessageID returns a unique Message-ID (without angle brackets) in the
+// domain of address.
+func newMessageID(address string) string {
+	domain := "picoclaw.local"
+	if _, d, ok := strings.Cut(address, "@"); ok && d != "" {
+		domain = d
+	}
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), hex.EncodeToString(b[:]), domain)
+}
+
+// replySubject prefixes subject with "Re: " unless it already has it.
+func replySubject(subject string) string {
+	if subject == "" {
+		return "Re: your message"
+	}
+	if len(subject) >= 3 && strings.EqualFold(subject[:3], "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// trimReferences keeps the thread root and the most recent IDs.
+func trimReferences(refs []string) []string {
+	if len(refs) <= maxReferences {
+		return refs
+	}
+	out := append([]string{refs[0]}, refs[len(refs)-maxReferences+1:]...)
+	return out
+}
@@ -4,8 +4,13 @@ import (
 	"encoding/json"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
 )
 
 // mentionPlaceholderRegex matches @_user_N placeholders inserted by Feishu for mentions.
@@ -19,27 +24,174 @@ func stringValue(v *string) string {
 	return *v
 }
 
+const (
+	threadAnchorTTL        = 24 * time.Hour
+	threadAnchorMaxEntries = 1024
+)
+
+type threadAnchor struct {
+	messageID string
+	expiresAt time.Time
+	touchedAt time.Time
+}
+
+// threadAnchors remembers the latest message seen in each thread, the one
+// replies to the thread are attached to. It holds at most maxEntries
+// threads, evicting the least recently touched, and forgets a thread after
+// ttl without messages.
+type threadAnchors struct {
+	mu         sync.Mutex
+	entries    map[string]threadAnchor
+	maxEntries int
+	ttl        time.Duration
+}
+
+func newThreadAnchors(maxEntries int, ttl time.Duration) *threadAnchors {
+	if maxEntries <= 0 {
+		maxEntries = threadAnchorMaxEntries
+	}
+	if ttl <= 0 {
+		ttl = threadAnchorTTL
+	}
+
+	return &threadAnchors{
+		entries:    make(map[string]threadAnchor),
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+}
+
+func (a *threadAnchors) get(threadID string, now time.Time) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, ok := a.entries[threadID]
+	if !ok {
+		return "", false
+	}
+	if !entry.expiresAt.After(now) {
+		delete(a.entries, threadID)
+		return "", false
+	}
+
+	entry.touchedAt = now
+	a.entries[threadID] = entry
+	return entry.messageID, true
+}
+
+func (a *threadAnchors) set(threadID, messageID string, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.entries[threadID]; !ok {
+		a.cleanupExpiredLocked(now)
+		for len(a.entries) >= a.maxEntries {
+			a.evictOldestLocked()
+		}
+	}
+
+	a.entries[threadID] = threadAnchor{
+		messageID: messageID,
+		expiresAt: now.Add(a.ttl),
+		touchedAt: now,
+	}
+}
+
+func (a *threadAnchors) cleanupExpiredLocked(now time.Time) {
+	for id, entry := range a.entries {
+		if !entry.expiresAt.After(now) {
+			delete(a.entries, id)
+		}
+	}
+}
+
+func (a *threadAnchors) evictOldestLocked() {
+	oldestID := ""
+	var oldest time.Time
+	for id, entry := range a.entries {
+		if oldestID == "" || entry.touchedAt.Before(oldest) {
+			oldestID, oldest = id, entry.touchedAt
+		}
+	}
+	delete(a.entries, oldestID)
+}
+
+// feishuChatID returns the chat ID of a thread (topic) in a chat,
+// "<chat>/<thread>", so replies can be posted back into the thread.
+func feishuChatID(chatID, threadID string) string {
+	return chatID + "/" + threadID
+}
+
+// parseFeishuChatID splits a chat ID made by feishuChatID; a plain chat ID
+// has no thread.
+func parseFeishuChatID(chatID string) (chat, threadID string) {
+	chat, threadID, _ = strings.Cut(chatID, "/")
+	return chat, threadID
+}
+
 // buildMarkdownCard builds a Feishu Interactive Card JSON 2.0 string with markdown content.
 // JSON 2.0 cards support full CommonMark standard markdown syntax.
 func buildMarkdownCard(content string) (string, error) {
-	card := map[string]any{
+	data, err := json.Marshal(markdownCard(content))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func markdownCard(content string, extra ...map[string]any) map[string]any {
+	elements := append([]map[string]any{
+		{
+			"tag":     "markdown",
+			"content": content,
+		},
+	}, extra...)
+	return map[string]any{
 		"schema": "2.0",
 		"body": map[string]any{
-			"elements": []map[string]any{
+			"elements": elements,
+		},
+	}
+}
+
+// buildChoiceCard builds a markdown card followed by one callback button per
+// prompt option. The button value carries the choice data and the label.
+func buildChoiceCard(content string, prompt *bus.ChoicePrompt) (string, error) {
+	buttons := make([]map[string]any, 0, len(prompt.Options))
+	for i, option := range prompt.Options {
+		buttons = append(buttons, map[string]any{
+			"tag":  "button",
+			"type": "default",
+			"text": map[string]any{
+				"tag":     "plain_text",
+				"content": option,
+			},
+			"behaviors": []map[string]any{
 				{
-					"tag":     "markdown",
-					"content": content,
+					"type": "callback",
+					"value": map[string]string{
+						"choice": channels.ChoiceData(prompt.ID, i+1),
+						"label":  option,
+					},
 				},
 			},
-		},
+		})
 	}
-	data, err := json.Marshal(card)
+	data, err := json.Marshal(markdownCard(content, buttons...))
 	if err != nil {
 		return "", err
 	}
 	return string(data), nil
 }
 
+// choiceCardValue extracts the choice data and label from the value of a
+// button built by buildChoiceCard.
+func choiceCardValue(value map[string]any) (data, label string) {
+	data, _ = value["choice"].(string)
+	label, _ = value["label"].(string)
+	return data, label
+}
+
 // extractJSONStringField unmarshals content as JSON and returns the value of the given string field.
 // Returns "" if the content is invalid JSON or the field is missing/empty.
 func extractJSONStringField(content, field string) string {
@@ -3,8 +3,12 @@ package feishu
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
 )
 
 func TestExtractJSONStringField(t *testing.T) {
@@ -227,6 +231,36 @@ func TestBuildMarkdownCard(t *testing.T) {
 	}
 }
 
+func TestBuildChoiceCard(t *testing.T) {
+	result, err := buildChoiceCard("Deploy now?", &bus.ChoicePrompt{ID: "p1", Options: []string{"Yes", "No"}})
+	if err != nil {
+		t.Fatalf("buildChoiceCard unexpected error: %v", err)
+	}
+
+	var parsed struct {
+		Body struct {
+			Elements []struct {
+				Tag       string `json:"tag"`
+				Behaviors []struct {
+					Value map[string]any `json:"value"`
+				} `json:"behaviors"`
+			} `json:"elements"`
+		} `json:"body"`
+	}
+	if err := json.Unmarshal([]byte(result), &parsed); err != nil {
+		t.Fatalf("buildChoiceCard produced invalid JSON: %v", err)
+	}
+	elements := parsed.Body.Elements
+	if len(elements) != 3 || elements[0].Tag != "markdown" || elements[2].Tag != "button" {
+		t.Fatalf("elements = %+v, want markdown and two buttons", elements)
+	}
+
+	data, label := choiceCardValue(elements[2].Behaviors[0].Value)
+	if data != channels.ChoiceData("p1", 2) || label != "No" {
+		t.Errorf("button value = %q/%q, want choice 2 labelled No", data, label)
+	}
+}
+
 func TestStripMentionPlaceholders(t *testing.T) {
 	strPtr := func(s string) *string { return &s }
 
@@ -290,3 +324,41 @@ func TestStripMentionPlaceholders(t *testing.T) {
 		})
 	}
 }
+
+func TestFeishuChatID_Thread(t *testing.T) {
+	chat, thread := parseFeishuChatID(feishuChatID("oc_abc", "omt_123"))
+	if chat != "oc_abc" || thread != "omt_123" {
+		t.Errorf("parsed %q, %q", chat, thread)
+	}
+	chat, thread = parseFeishuChatID("oc_abc")
+	if chat != "oc_abc" || thread != "" {
+		t.Errorf("plain chat parsed as %q, %q", chat, thread)
+	}
+}
+
+func TestThreadAnchors_EvictsOldestAndExpired(t *testing.T) {
+	now := time.Now()
+	anchors := newThreadAnchors(2, time.Hour)
+
+	anchors.set("t1", "m1", now)
+	anchors.set("t2", "m2", now.Add(time.Second))
+	if _, ok := anchors.get("t1", now.Add(2*time.Second)); !ok {
+		t.Fatal("t1 should be cached")
+	}
+	anchors.set("t3", "m3", now.Add(3*time.Second))
+
+	if _, ok := anchors.get("t2", now.Add(4*time.Second)); ok {
+		t.Error("t2 was least recently used and should have been evicted")
+	}
+	if got, ok := anchors.get("t1", now.Add(4*time.Second)); !ok || got != "m1" {
+		t.Errorf("t1 = %q, %v; want m1, true", got, ok)
+	}
+
+	anchors.set("t3", "m4", now.Add(5*time.Second))
+	if got, _ := anchors.get("t3", now.Add(6*time.Second)); got != "m4" {
+		t.Errorf("t3 = %q, want the latest message m4", got)
+	}
+	if _, ok := anchors.get("t3", now.Add(2*time.Hour)); ok {
+		t.Error("t3 should have expired")
+	}
+}
@@ -59,3 +59,8 @@ func (c *FeishuChannel) ReactToMessage(ctx context.Context, chatID, messageID st
 func (c *FeishuChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
 	return errUnsupported
 }
+
+// SendChoices is a stub method to satisfy ChoiceCapable
+func (c *FeishuChannel) SendChoices(ctx context.Context, msg bus.OutboundMessage) error {
+	return errUnsupported
+}
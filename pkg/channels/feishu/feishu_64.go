@@ -13,10 +13,12 @@ import (
 	"path/filepath"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
 	larkdispatcher "github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+	larkcallback "github.com/larksuite/oapi-sdk-go/v3/event/dispatcher/callback"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
 	larkws "github.com/larksuite/oapi-sdk-go/v3/ws"
 
@@ -35,7 +37,11 @@ type FeishuChannel struct {
 	client   *lark.Client
 	wsClient *larkws.Client
 
-	botOpenID atomic.Value // stores string; populated lazily for @mention detection
+	botOpenID atomic.Value   // stores string; populated lazily for @mention detection
+	chatTypes sync.Map       // chatID -> chat type ("p2p", "group"), for card callbacks
+	threads   *threadAnchors // thread ID -> latest message ID in it, replied to in-thread
+	questions sync.Map       // prompt ID -> question, to redraw the card after a press
+	asked     sync.Map       // prompt ID -> chat ID it was sent to, threaded ones included
 
 	mu     sync.Mutex
 	cancel context.CancelFunc
@@ -51,6 +57,7 @@ func NewFeishuChannel(cfg config.FeishuConfig, bus *bus.MessageBus) (*FeishuChan
 		BaseChannel: base,
 		config:      cfg,
 		client:      lark.NewClient(cfg.AppID, cfg.AppSecret),
+		threads:     newThreadAnchors(threadAnchorMaxEntries, threadAnchorTTL),
 	}
 	ch.SetOwner(ch)
 	return ch, nil
@@ -69,7 +76,8 @@ func (c *FeishuChannel) Start(ctx context.Context) error {
 	}
 
 	dispatcher := larkdispatcher.NewEventDispatcher(c.config.VerificationToken, c.config.EncryptKey).
-		OnP2MessageReceiveV1(c.handleMessageReceive)
+		OnP2MessageReceiveV1(c.handleMessageReceive).
+		OnP2CardActionTrigger(c.handleCardAction)
 
 	runCtx, cancel := context.WithCancel(ctx)
 
@@ -129,6 +137,89 @@ func (c *FeishuChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	return c.sendCard(ctx, msg.ChatID, cardContent)
 }
 
+// SendChoices implements channels.ChoiceCapable with a card holding one
+// button per option.
+func (c *FeishuChannel) SendChoices(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+
+	if msg.ChatID == "" {
+		return fmt.Errorf("chat ID is empty: %w", channels.ErrSendFailed)
+	}
+
+	cardContent, err := buildChoiceCard(msg.Content, msg.Prompt)
+	if err != nil {
+		return fmt.Errorf("feishu send: card build failed: %w", err)
+	}
+	if err := c.sendCard(ctx, msg.ChatID, cardContent); err != nil {
+		return err
+	}
+	c.questions.Store(msg.Prompt.ID, msg.Content)
+	c.asked.Store(msg.Prompt.ID, msg.ChatID)
+	return nil
+}
+
+// handleCardAction handles presses of buttons sent by SendChoices. The card
+// is replaced by one without buttons that notes the chosen option.
+func (c *FeishuChannel) handleCardAction(
+	ctx context.Context,
+	event *larkcallback.CardActionTriggerEvent,
+) (*larkcallback.CardActionTriggerResponse, error) {
+	if event == nil || event.Event == nil || event.Event.Action == nil ||
+		event.Event.Operator == nil || event.Event.Context == nil {
+		return nil, nil
+	}
+
+	data, label := choiceCardValue(event.Event.Action.Value)
+	promptID, _, ok := channels.ParseChoiceData(data)
+	if !ok {
+		return nil, nil
+	}
+
+	operator := event.Event.Operator
+	senderID := operator.OpenID
+	if operator.UserID != nil && *operator.UserID != "" {
+		senderID = *operator.UserID
+	}
+	senderInfo := bus.SenderInfo{
+		Platform:    "feishu",
+		PlatformID:  senderID,
+		CanonicalID: identity.BuildCanonicalID("feishu", senderID),
+	}
+	if !c.IsAllowedSender(senderInfo) {
+		return nil, nil
+	}
+
+	chatID := event.Event.Context.OpenChatID
+	peer := bus.Peer{Kind: "group", ID: chatID}
+	if chatType, _ := c.chatTypes.Load(chatID); chatType == "p2p" {
+		peer = bus.Peer{Kind: "direct", ID: senderID}
+	}
+	// A prompt asked in a thread is answered in the thread's session.
+	replyChatID := chatID
+	var metadata map[string]string
+	if askedIn, ok := c.asked.LoadAndDelete(promptID); ok {
+		if _, threadID := parseFeishuChatID(askedIn.(string)); threadID != "" {
+			replyChatID = askedIn.(string)
+			metadata = map[string]string{channels.MetadataThreadID: threadID}
+		}
+	}
+	c.HandleChoice(ctx, peer, event.Event.Context.OpenMessageID, senderID, replyChatID, data, label, metadata,
+		senderInfo)
+
+	content := fmt.Sprintf("**Selected:** %s", label)
+	if question, ok := c.questions.LoadAndDelete(promptID); ok {
+		content = question.(string) + "\n\n" + content
+	}
+	return &larkcallback.CardActionTriggerResponse{
+		Card: &larkcallback.Card{
+			Type: "raw",
+			Data: markdownCard(content),
+		},
+	}, nil
+}
+
 // EditMessage implements channels.MessageEditor.
 // Uses Message.Patch to update an interactive card message.
 func (c *FeishuChannel) EditMessage(ctx context.Context, chatID, messageID, content string) error {
@@ -172,27 +263,11 @@ func (c *FeishuChannel) SendPlaceholder(ctx context.Context, chatID string) (str
 		return "", fmt.Errorf("feishu placeholder: card build failed: %w", err)
 	}
 
-	req := larkim.NewCreateMessageReqBuilder().
-		ReceiveIdType(larkim.ReceiveIdTypeChatId).
-		Body(larkim.NewCreateMessageReqBodyBuilder().
-			ReceiveId(chatID).
-			MsgType(larkim.MsgTypeInteractive).
-			Content(cardContent).
-			Build()).
-		Build()
-
-	resp, err := c.client.Im.V1.Message.Create(ctx, req)
+	messageID, err := c.postMessage(ctx, chatID, larkim.MsgTypeInteractive, cardContent)
 	if err != nil {
 		return "", fmt.Errorf("feishu placeholder send: %w", err)
 	}
-	if !resp.Success() {
-		return "", fmt.Errorf("feishu placeholder api error (code=%d msg=%s)", resp.Code, resp.Msg)
-	}
-
-	if resp.Data != nil && resp.Data.MessageId != nil {
-		return *resp.Data.MessageId, nil
-	}
-	return "", nil
+	return messageID, nil
 }
 
 // ReactToMessage implements channels.ReactionCapable.
@@ -390,6 +465,15 @@ func (c *FeishuChannel) handleMessageReceive(ctx context.Context, event *larkim.
 	chatType := stringValue(message.ChatType)
 	if chatType != "" {
 		metadata["chat_type"] = chatType
+		c.chatTypes.Store(chatID, chatType)
+	}
+	replyChatID := chatID
+	if threadID := stringValue(message.ThreadId); threadID != "" {
+		metadata[channels.MetadataThreadID] = threadID
+		replyChatID = feishuChatID(chatID, threadID)
+		if messageID != "" {
+			c.threads.set(threadID, messageID, time.Now())
+		}
 	}
 	if sender != nil && sender.TenantKey != nil {
 		metadata["tenant_key"] = *sender.TenantKey
@@ -424,7 +508,7 @@ func (c *FeishuChannel) handleMessageReceive(ctx context.Context, event *larkim.
 		"preview":    utils.Truncate(content, 80),
 	})
 
-	c.HandleMessage(ctx, peer, messageID, senderID, chatID, content, mediaRefs, metadata, senderInfo)
+	c.HandleMessage(ctx, peer, messageID, senderID, replyChatID, content, mediaRefs, metadata, senderInfo)
 	return nil
 }
 
@@ -690,22 +774,8 @@ func appendMediaTags(content, messageType string, mediaRefs []string) string {
 
 // sendCard sends an interactive card message to a chat.
 func (c *FeishuChannel) sendCard(ctx context.Context, chatID, cardContent string) error {
-	req := larkim.NewCreateMessageReqBuilder().
-		ReceiveIdType(larkim.ReceiveIdTypeChatId).
-		Body(larkim.NewCreateMessageReqBodyBuilder().
-			ReceiveId(chatID).
-			MsgType(larkim.MsgTypeInteractive).
-			Content(cardContent).
-			Build()).
-		Build()
-
-	resp, err := c.client.Im.V1.Message.Create(ctx, req)
-	if err != nil {
-		return fmt.Errorf("feishu send card: %w", channels.ErrTemporary)
-	}
-
-	if !resp.Success() {
-		return fmt.Errorf("feishu api error (code=%d msg=%s): %w", resp.Code, resp.Msg, channels.ErrTemporary)
+	if _, err := c.postMessage(ctx, chatID, larkim.MsgTypeInteractive, cardContent); err != nil {
+		return fmt.Errorf("feishu send card: %v: %w", err, channels.ErrTemporary)
 	}
 
 	logger.DebugCF("feishu", "Feishu card message sent", map[string]any{
@@ -740,22 +810,9 @@ func (c *FeishuChannel) sendImage(ctx context.Context, chatID string, file *os.F
 
 	// Send image message
 	content, _ := json.Marshal(map[string]string{"image_key": imageKey})
-	req := larkim.NewCreateMessageReqBuilder().
-		ReceiveIdType(larkim.ReceiveIdTypeChatId).
-		Body(larkim.NewCreateMessageReqBodyBuilder().
-			ReceiveId(chatID).
-			MsgType(larkim.MsgTypeImage).
-			Content(string(content)).
-			Build()).
-		Build()
-
-	resp, err := c.client.Im.V1.Message.Create(ctx, req)
-	if err != nil {
+	if _, err := c.postMessage(ctx, chatID, larkim.MsgTypeImage, string(content)); err != nil {
 		return fmt.Errorf("feishu image send: %w", err)
 	}
-	if !resp.Success() {
-		return fmt.Errorf("feishu image send api error (code=%d msg=%s)", resp.Code, resp.Msg)
-	}
 	return nil
 }
 
@@ -794,23 +851,96 @@ func (c *FeishuChannel) sendFile(ctx context.Context, chatID string, file *os.Fi
 
 	// Send file message
 	content, _ := json.Marshal(map[string]string{"file_key": fileKey})
+	if _, err := c.postMessage(ctx, chatID, larkim.MsgTypeFile, string(content)); err != nil {
+		return fmt.Errorf("feishu file send: %w", err)
+	}
+	return nil
+}
+
+// postMessage sends a message and returns its ID. A thread chat ID (see
+// feishuChatID) is answered in the thread by replying to the latest message
+// there; anything else is posted to the chat.
+func (c *FeishuChannel) postMessage(ctx context.Context, chatID, msgType, content string) (string, error) {
+	chatID, threadID := parseFeishuChatID(chatID)
+	if threadID != "" {
+		anchor, err := c.threadAnchor(ctx, threadID)
+		if err != nil {
+			return "", fmt.Errorf("feishu thread %s: %w", threadID, err)
+		}
+
+		req := larkim.NewReplyMessageReqBuilder().
+			MessageId(anchor).
+			Body(larkim.NewReplyMessageReqBodyBuilder().
+				MsgType(msgType).
+				Content(content).
+				ReplyInThread(true).
+				Build()).
+			Build()
+
+		resp, err := c.client.Im.V1.Message.Reply(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		if !resp.Success() {
+			return "", fmt.Errorf("api error (code=%d msg=%s)", resp.Code, resp.Msg)
+		}
+		if resp.Data != nil && resp.Data.MessageId != nil {
+			return *resp.Data.MessageId, nil
+		}
+		return "", nil
+	}
+
 	req := larkim.NewCreateMessageReqBuilder().
 		ReceiveIdType(larkim.ReceiveIdTypeChatId).
 		Body(larkim.NewCreateMessageReqBodyBuilder().
 			ReceiveId(chatID).
-			MsgType(larkim.MsgTypeFile).
-			Content(string(content)).
+			MsgType(msgType).
+			Content(content).
 			Build()).
 		Build()
 
 	resp, err := c.client.Im.V1.Message.Create(ctx, req)
 	if err != nil {
-		return fmt.Errorf("feishu file send: %w", err)
+		return "", err
 	}
 	if !resp.Success() {
-		return fmt.Errorf("feishu file send api error (code=%d msg=%s)", resp.Code, resp.Msg)
+		return "", fmt.Errorf("api error (code=%d msg=%s)", resp.Code, resp.Msg)
 	}
-	return nil
+	if resp.Data != nil && resp.Data.MessageId != nil {
+		return *resp.Data.MessageId, nil
+	}
+	return "", nil
+}
+
+// threadAnchor returns the message to reply to in a thread: the latest one
+// seen, or, for a thread not seen since startup or evicted, the newest one
+// the API lists for it.
+func (c *FeishuChannel) threadAnchor(ctx context.Context, threadID string) (string, error) {
+	if anchor, ok := c.threads.get(threadID, time.Now()); ok {
+		return anchor, nil
+	}
+
+	req := larkim.NewListMessageReqBuilder().
+		ContainerIdType("thread").
+		ContainerId(threadID).
+		SortType(larkim.SortTypeListMessageByCreateTimeDesc).
+		PageSize(1).
+		Build()
+
+	resp, err := c.client.Im.V1.Message.List(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if !resp.Success() {
+		return "", fmt.Errorf("list messages api error (code=%d msg=%s)", resp.Code, resp.Msg)
+	}
+	if resp.Data == nil || len(resp.Data.Items) == 0 || resp.Data.Items[0].MessageId == nil {
+		return "", fmt.Errorf("no message to reply to")
+	}
+
+	anchor := *resp.Data.Items[0].MessageId
+	c.threads.set(threadID, anchor, time.Now())
+	return anchor, nil
 }
 
 func extractFeishuSenderID(sender *larkim.EventSender) string {
@@ -3,6 +3,7 @@ package channels
 import (
 	"context"
 
+	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/commands"
 )
 
@@ -35,6 +36,14 @@ type PlaceholderCapable interface {
 	SendPlaceholder(ctx context.Context, chatID string) (messageID string, err error)
 }
 
+// ChoiceCapable — channels that can show the options of msg.Prompt as
+// buttons or quick replies along with msg.Content. Presses are reported
+// through BaseChannel.HandleChoice. For other channels the Manager appends
+// the options to the text as a numbered menu.
+type ChoiceCapable interface {
+	SendChoices(ctx context.Context, msg bus.OutboundMessage) error
+}
+
 // PlaceholderRecorder is injected into channels by Manager.
 // Channels call these methods on inbound to register typing/placeholder state.
 // Manager uses the registered state on outbound to stop typing and edit placeholders.
@@ -35,6 +35,7 @@ func NewIRCChannel(cfg config.IRCConfig, messageBus *bus.MessageBus) (*IRCChanne
 
 	base := channels.NewBaseChannel("irc", cfg, messageBus, cfg.AllowFrom,
 		channels.WithMaxMessageLength(400),
+		channels.WithTextFormat(channels.FormatPlain),
 		channels.WithGroupTrigger(cfg.GroupTrigger),
 		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
 	)
@@ -145,7 +146,7 @@ func (c *IRCChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
 	}
 
 	// Send each line separately (IRC is line-oriented)
-	lines := strings.Split(msg.Content, "\n")
+	lines := strings.Split(channels.RenderMarkdown(msg.Content, channels.FormatPlain), "\n")
 	for _, line := range lines {
 		line = strings.TrimRight(line, "\r")
 		if line == "" {
@@ -56,6 +56,7 @@ type LINEChannel struct {
 	botDisplayName string       // Bot's display name for text-based mention detection
 	replyTokens    sync.Map     // chatID -> replyTokenEntry
 	quoteTokens    sync.Map     // chatID -> quoteToken (string)
+	promptOptions  sync.Map     // prompt ID -> option labels ([]string)
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
@@ -229,9 +230,14 @@ type lineEvent struct {
 	ReplyToken string          `json:"replyToken"`
 	Source     lineSource      `json:"source"`
 	Message    json.RawMessage `json:"message"`
+	Postback   *linePostback   `json:"postback"`
 	Timestamp  int64           `json:"timestamp"`
 }
 
+type linePostback struct {
+	Data string `json:"data"`
+}
+
 type lineSource struct {
 	Type    string `json:"type"` // "user", "group", "room"
 	UserID  string `json:"userId"`
@@ -260,6 +266,10 @@ type lineMentionee struct {
 }
 
 func (c *LINEChannel) processEvent(event lineEvent) {
+	if event.Type == "postback" {
+		c.processPostback(event)
+		return
+	}
 	if event.Type != "message" {
 		logger.DebugCF("line", "Ignoring non-message event", map[string]any{
 			"type": event.Type,
@@ -394,6 +404,49 @@ func (c *LINEChannel) processEvent(event lineEvent) {
 	c.HandleMessage(c.ctx, peer, msg.ID, senderID, chatID, content, mediaPaths, metadata, sender)
 }
 
+// processPostback handles a quick reply pressed on a prompt sent by
+// SendChoices.
+func (c *LINEChannel) processPostback(event lineEvent) {
+	if event.Postback == nil {
+		return
+	}
+	promptID, option, ok := channels.ParseChoiceData(event.Postback.Data)
+	if !ok {
+		return
+	}
+
+	senderID := event.Source.UserID
+	chatID := c.resolveChatID(event.Source)
+	sender := bus.SenderInfo{
+		Platform:    "line",
+		PlatformID:  senderID,
+		CanonicalID: identity.BuildCanonicalID("line", senderID),
+	}
+	if !c.IsAllowedSender(sender) {
+		return
+	}
+
+	if event.ReplyToken != "" {
+		c.replyTokens.Store(chatID, replyTokenEntry{
+			token:     event.ReplyToken,
+			timestamp: time.Now(),
+		})
+	}
+
+	label := ""
+	if v, ok := c.promptOptions.LoadAndDelete(promptID); ok {
+		if options := v.([]string); option <= len(options) {
+			label = options[option-1]
+		}
+	}
+
+	peer := bus.Peer{Kind: "direct", ID: senderID}
+	if event.Source.Type == "group" || event.Source.Type == "room" {
+		peer = bus.Peer{Kind: "group", ID: chatID}
+	}
+	c.HandleChoice(c.ctx, peer, "", senderID, chatID, event.Postback.Data, label, nil, sender)
+}
+
 // isBotMentioned checks if the bot is mentioned in the message.
 // It first checks the mention metadata (userId match), then falls back
 // to text-based detection using the bot's display name, since LINE may
@@ -506,14 +559,55 @@ func (c *LINEChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
 		quoteToken = qt.(string)
 	}
 
+	return c.sendMessage(ctx, msg.ChatID, buildTextMessage(msg.Content, quoteToken))
+}
+
+// SendChoices implements channels.ChoiceCapable with one quick reply per
+// option. Pressing one posts back the choice and shows the label as the
+// user's message.
+func (c *LINEChannel) SendChoices(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+
+	var quoteToken string
+	if qt, ok := c.quoteTokens.LoadAndDelete(msg.ChatID); ok {
+		quoteToken = qt.(string)
+	}
+
+	message := buildTextMessage(msg.Content, quoteToken)
+	message["quickReply"] = buildQuickReply(msg.Prompt)
+	c.promptOptions.Store(msg.Prompt.ID, msg.Prompt.Options)
+	return c.sendMessage(ctx, msg.ChatID, message)
+}
+
+func buildQuickReply(prompt *bus.ChoicePrompt) map[string]any {
+	items := make([]map[string]any, 0, len(prompt.Options))
+	for i, option := range prompt.Options {
+		items = append(items, map[string]any{
+			"type": "action",
+			"action": map[string]string{
+				"type":        "postback",
+				"label":       utils.Truncate(option, 20),
+				"data":        channels.ChoiceData(prompt.ID, i+1),
+				"displayText": utils.Truncate(option, 300),
+			},
+		})
+	}
+	return map[string]any{"items": items}
+}
+
+// sendMessage first tries the Reply API using a cached reply token, then
+// falls back to the Push API.
+func (c *LINEChannel) sendMessage(ctx context.Context, chatID string, message map[string]any) error {
 	// Try reply token first (free, valid for ~25 seconds)
-	if entry, ok := c.replyTokens.LoadAndDelete(msg.ChatID); ok {
+	if entry, ok := c.replyTokens.LoadAndDelete(chatID); ok {
 		tokenEntry := entry.(replyTokenEntry)
 		if time.Since(tokenEntry.timestamp) < lineReplyTokenMaxAge {
-			if err := c.sendReply(ctx, tokenEntry.token, msg.Content, quoteToken); err == nil {
+			if err := c.sendReply(ctx, tokenEntry.token, message); err == nil {
 				logger.DebugCF("line", "Message sent via Reply API", map[string]any{
-					"chat_id": msg.ChatID,
-					"quoted":  quoteToken != "",
+					"chat_id": chatID,
+					"quoted":  message["quoteToken"] != nil,
 				})
 				return nil
 			}
@@ -522,7 +616,7 @@ func (c *LINEChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
 	}
 
 	// Fall back to Push API
-	return c.sendPush(ctx, msg.ChatID, msg.Content, quoteToken)
+	return c.sendPush(ctx, chatID, message)
 }
 
 // SendMedia implements the channels.MediaSender interface.
@@ -547,7 +641,7 @@ func (c *LINEChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessag
 			caption = fmt.Sprintf("[%s: %s]", part.Type, part.Filename)
 		}
 
-		if err := c.sendPush(ctx, msg.ChatID, caption, ""); err != nil {
+		if err := c.sendPush(ctx, msg.ChatID, buildTextMessage(caption, "")); err != nil {
 			return err
 		}
 	}
@@ -556,8 +650,8 @@ func (c *LINEChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessag
 }
 
 // buildTextMessage creates a text message object, optionally with quoteToken.
-func buildTextMessage(content, quoteToken string) map[string]string {
-	msg := map[string]string{
+func buildTextMessage(content, quoteToken string) map[string]any {
+	msg := map[string]any{
 		"type": "text",
 		"text": content,
 	}
@@ -568,20 +662,20 @@ func buildTextMessage(content, quoteToken string) map[string]string {
 }
 
 // sendReply sends a message using the LINE Reply API.
-func (c *LINEChannel) sendReply(ctx context.Context, replyToken, content, quoteToken string) error {
+func (c *LINEChannel) sendReply(ctx context.Context, replyToken string, message map[string]any) error {
 	payload := map[string]any{
 		"replyToken": replyToken,
-		"messages":   []map[string]string{buildTextMessage(content, quoteToken)},
+		"messages":   []map[string]any{message},
 	}
 
 	return c.callAPI(ctx, lineReplyEndpoint, payload)
 }
 
 // sendPush sends a message using the LINE Push API.
-func (c *LINEChannel) sendPush(ctx context.Context, to, content, quoteToken string) error {
+func (c *LINEChannel) sendPush(ctx context.Context, to string, message map[string]any) error {
 	payload := map[string]any{
 		"to":       to,
-		"messages": []map[string]string{buildTextMessage(content, quoteToken)},
+		"messages": []map[string]any{message},
 	}
 
 	return c.callAPI(ctx, linePushEndpoint, payload)
@@ -2,10 +2,15 @@ package line
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
 )
 
 func TestWebhookRejectsOversizedBody(t *testing.T) {
@@ -79,3 +84,47 @@ func TestWebhookRejectsInvalidSignature(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusForbidden, rec.Code)
 	}
 }
+
+func TestProcessPostback_PublishesChoice(t *testing.T) {
+	messageBus := bus.NewMessageBus()
+	ch := &LINEChannel{
+		BaseChannel: channels.NewBaseChannel("line", nil, messageBus, nil),
+		ctx:         context.Background(),
+	}
+	ch.promptOptions.Store("p1", []string{"Yes", "No"})
+
+	ch.processEvent(lineEvent{
+		Type:       "postback",
+		ReplyToken: "token",
+		Source:     lineSource{Type: "user", UserID: "U1"},
+		Postback:   &linePostback{Data: channels.ChoiceData("p1", 1)},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	inbound, ok := messageBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected an inbound message")
+	}
+	if inbound.Content != "Yes" || inbound.ChatID != "U1" {
+		t.Errorf("inbound = %q in %q, want Yes in U1", inbound.Content, inbound.ChatID)
+	}
+	if inbound.Choice == nil || inbound.Choice.PromptID != "p1" || inbound.Choice.Option != 1 {
+		t.Errorf("choice = %+v, want p1 option 1", inbound.Choice)
+	}
+	if _, ok := ch.replyTokens.Load("U1"); !ok {
+		t.Error("postback reply token should be kept for the answer")
+	}
+}
+
+func TestBuildQuickReply(t *testing.T) {
+	qr := buildQuickReply(&bus.ChoicePrompt{ID: "p1", Options: []string{"Yes", "No"}})
+	items := qr["items"].([]map[string]any)
+	if len(items) != 2 {
+		t.Fatalf("items = %d, want 2", len(items))
+	}
+	action := items[1]["action"].(map[string]string)
+	if action["type"] != "postback" || action["data"] != channels.ChoiceData("p1", 2) || action["label"] != "No" {
+		t.Errorf("action = %v", action)
+	}
+}
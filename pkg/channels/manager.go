@@ -10,8 +10,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"math"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 
@@ -58,13 +60,16 @@ type placeholderEntry struct {
 
 // channelRateConfig maps channel name to per-second rate limit.
 var channelRateConfig = map[string]float64{
-	"telegram": 20,
-	"discord":  1,
-	"slack":    1,
-	"matrix":   2,
-	"line":     10,
-	"qq":       5,
-	"irc":      2,
+	"telegram":   20,
+	"discord":    1,
+	"slack":      1,
+	"matrix":     2,
+	"line":       10,
+	"qq":         5,
+	"irc":        2,
+	"mattermost": 10,
+	"rocketchat": 5,
+	"xmpp":       5,
 }
 
 type channelWorker struct {
@@ -161,8 +166,9 @@ func (m *Manager) preSend(ctx context.Context, name string, msg bus.OutboundMess
 		}
 	}
 
-	// 3. Try editing placeholder
-	if v, loaded := m.placeholders.LoadAndDelete(key); loaded {
+	// 3. Try editing placeholder. A prompt carries buttons that an edit
+	// cannot add, so it is always sent as a new message.
+	if v, loaded := m.placeholders.LoadAndDelete(key); loaded && msg.Prompt == nil {
 		if entry, ok := v.(placeholderEntry); ok && entry.id != "" {
 			if editor, ok := ch.(MessageEditor); ok {
 				if err := editor.EditMessage(ctx, msg.ChatID, entry.id, msg.Content); err == nil {
@@ -205,6 +211,35 @@ func (m *Manager) initChannel(name, displayName string) {
 		"channel": displayName,
 	})
 	ch, err := f(m.config, m.bus)
+	m.addChannel(name, displayName, ch, err)
+}
+
+// initChannelInstance creates one named instance of a multi-instance
+// channel type, e.g. a "webhook" instance called "tickets".
+func (m *Manager) initChannelInstance(kind, instance, displayName string) {
+	f, ok := getInstanceFactory(kind)
+	if !ok {
+		logger.WarnCF("channels", "Factory not registered", map[string]any{
+			"channel": displayName,
+		})
+		return
+	}
+	name := InstanceName(kind, instance)
+	if _, exists := m.channels[name]; exists {
+		logger.ErrorCF("channels", "Duplicate channel instance", map[string]any{
+			"channel": name,
+		})
+		return
+	}
+	logger.DebugCF("channels", "Attempting to initialize channel", map[string]any{
+		"channel": displayName,
+	})
+	ch, err := f(m.config, m.bus, instance)
+	m.addChannel(name, displayName, ch, err)
+}
+
+// addChannel wires up a newly created channel, or logs why it failed.
+func (m *Manager) addChannel(name, displayName string, ch Channel, err error) {
 	if err != nil {
 		logger.ErrorCF("channels", "Failed to initialize channel", map[string]any{
 			"channel": displayName,
@@ -232,79 +267,104 @@ func (m *Manager) initChannel(name, displayName string) {
 	}
 }
 
+// channelSpec describes a channel type configured by one section of
+// config.ChannelsConfig.
+type channelSpec struct {
+	name        string // factory and channel name
+	displayName string
+	section     string // JSON key of the config section
+	enabled     func(c *config.ChannelsConfig) bool
+}
+
+var channelSpecs = []channelSpec{
+	{"telegram", "Telegram", "telegram", func(c *config.ChannelsConfig) bool {
+		return c.Telegram.Enabled && c.Telegram.Token != ""
+	}},
+	{"whatsapp_native", "WhatsApp Native", "whatsapp", func(c *config.ChannelsConfig) bool {
+		return c.WhatsApp.Enabled && c.WhatsApp.UseNative
+	}},
+	{"whatsapp", "WhatsApp", "whatsapp", func(c *config.ChannelsConfig) bool {
+		return c.WhatsApp.Enabled && !c.WhatsApp.UseNative && c.WhatsApp.BridgeURL != ""
+	}},
+	{"feishu", "Feishu", "feishu", func(c *config.ChannelsConfig) bool {
+		return c.Feishu.Enabled
+	}},
+	{"discord", "Discord", "discord", func(c *config.ChannelsConfig) bool {
+		return c.Discord.Enabled && c.Discord.Token != ""
+	}},
+	{"maixcam", "MaixCam", "maixcam", func(c *config.ChannelsConfig) bool {
+		return c.MaixCam.Enabled
+	}},
+	{"qq", "QQ", "qq", func(c *config.ChannelsConfig) bool {
+		return c.QQ.Enabled
+	}},
+	{"dingtalk", "DingTalk", "dingtalk", func(c *config.ChannelsConfig) bool {
+		return c.DingTalk.Enabled && c.DingTalk.ClientID != ""
+	}},
+	{"slack", "Slack", "slack", func(c *config.ChannelsConfig) bool {
+		return c.Slack.Enabled && c.Slack.BotToken != ""
+	}},
+	{"matrix", "Matrix", "matrix", func(c *config.ChannelsConfig) bool {
+		return c.Matrix.Enabled && c.Matrix.Homeserver != "" && c.Matrix.UserID != "" && c.Matrix.AccessToken != ""
+	}},
+	{"line", "LINE", "line", func(c *config.ChannelsConfig) bool {
+		return c.LINE.Enabled && c.LINE.ChannelAccessToken != ""
+	}},
+	{"onebot", "OneBot", "onebot", func(c *config.ChannelsConfig) bool {
+		return c.OneBot.Enabled && c.OneBot.WSUrl != ""
+	}},
+	{"wecom", "WeCom", "wecom", func(c *config.ChannelsConfig) bool {
+		return c.WeCom.Enabled && c.WeCom.Token != ""
+	}},
+	{"wecom_aibot", "WeCom AI Bot", "wecom_aibot", func(c *config.ChannelsConfig) bool {
+		return c.WeComAIBot.Enabled && c.WeComAIBot.Token != ""
+	}},
+	{"wecom_app", "WeCom App", "wecom_app", func(c *config.ChannelsConfig) bool {
+		return c.WeComApp.Enabled && c.WeComApp.CorpID != ""
+	}},
+	{"pico", "Pico", "pico", func(c *config.ChannelsConfig) bool {
+		return c.Pico.Enabled && c.Pico.Token != ""
+	}},
+	{"irc", "IRC", "irc", func(c *config.ChannelsConfig) bool {
+		return c.IRC.Enabled && c.IRC.Server != ""
+	}},
+	{"email", "Email", "email", func(c *config.ChannelsConfig) bool {
+		return c.Email.Enabled && c.Email.IMAPServer != ""
+	}},
+	{"signal", "Signal", "signal", func(c *config.ChannelsConfig) bool {
+		return c.Signal.Enabled && c.Signal.Account != ""
+	}},
+	{"mqtt", "MQTT", "mqtt", func(c *config.ChannelsConfig) bool {
+		return c.MQTT.Enabled && c.MQTT.Broker != ""
+	}},
+	{"mattermost", "Mattermost", "mattermost", func(c *config.ChannelsConfig) bool {
+		return c.Mattermost.Enabled && c.Mattermost.URL != ""
+	}},
+	{"rocketchat", "Rocket.Chat", "rocketchat", func(c *config.ChannelsConfig) bool {
+		return c.RocketChat.Enabled && c.RocketChat.URL != ""
+	}},
+	{"xmpp", "XMPP", "xmpp", func(c *config.ChannelsConfig) bool {
+		return c.XMPP.Enabled && c.XMPP.JID != ""
+	}},
+}
+
 func (m *Manager) initChannels() error {
 	logger.InfoC("channels", "Initializing channel manager")
 
-	if m.config.Channels.Telegram.Enabled && m.config.Channels.Telegram.Token != "" {
-		m.initChannel("telegram", "Telegram")
-	}
-
-	if m.config.Channels.WhatsApp.Enabled {
-		waCfg := m.config.Channels.WhatsApp
-		if waCfg.UseNative {
-			m.initChannel("whatsapp_native", "WhatsApp Native")
-		} else if waCfg.BridgeURL != "" {
-			m.initChannel("whatsapp", "WhatsApp")
+	for _, spec := range channelSpecs {
+		if spec.enabled(&m.config.Channels) {
+			m.initChannel(spec.name, spec.displayName)
 		}
 	}
 
-	if m.config.Channels.Feishu.Enabled {
-		m.initChannel("feishu", "Feishu")
-	}
-
-	if m.config.Channels.Discord.Enabled && m.config.Channels.Discord.Token != "" {
-		m.initChannel("discord", "Discord")
-	}
-
-	if m.config.Channels.MaixCam.Enabled {
-		m.initChannel("maixcam", "MaixCam")
-	}
-
-	if m.config.Channels.QQ.Enabled {
-		m.initChannel("qq", "QQ")
-	}
-
-	if m.config.Channels.DingTalk.Enabled && m.config.Channels.DingTalk.ClientID != "" {
-		m.initChannel("dingtalk", "DingTalk")
-	}
-
-	if m.config.Channels.Slack.Enabled && m.config.Channels.Slack.BotToken != "" {
-		m.initChannel("slack", "Slack")
-	}
-
-	if m.config.Channels.Matrix.Enabled &&
-		m.config.Channels.Matrix.Homeserver != "" &&
-		m.config.Channels.Matrix.UserID != "" &&
-		m.config.Channels.Matrix.AccessToken != "" {
-		m.initChannel("matrix", "Matrix")
-	}
-
-	if m.config.Channels.LINE.Enabled && m.config.Channels.LINE.ChannelAccessToken != "" {
-		m.initChannel("line", "LINE")
-	}
-
-	if m.config.Channels.OneBot.Enabled && m.config.Channels.OneBot.WSUrl != "" {
-		m.initChannel("onebot", "OneBot")
-	}
-
-	if m.config.Channels.WeCom.Enabled && m.config.Channels.WeCom.Token != "" {
-		m.initChannel("wecom", "WeCom")
-	}
-
-	if m.config.Channels.WeComAIBot.Enabled && m.config.Channels.WeComAIBot.Token != "" {
-		m.initChannel("wecom_aibot", "WeCom AI Bot")
-	}
-
-	if m.config.Channels.WeComApp.Enabled && m.config.Channels.WeComApp.CorpID != "" {
-		m.initChannel("wecom_app", "WeCom App")
-	}
-
-	if m.config.Channels.Pico.Enabled && m.config.Channels.Pico.Token != "" {
-		m.initChannel("pico", "Pico")
+	if m.config.Channels.Webhook.Enabled {
+		for _, inst := range m.config.Channels.Webhook.Instances {
+			m.initChannelInstance("webhook", inst.Name, "Webhook "+InstanceName("webhook", inst.Name))
+		}
 	}
 
-	if m.config.Channels.IRC.Enabled && m.config.Channels.IRC.Server != "" {
-		m.initChannel("irc", "IRC")
+	for _, acc := range m.config.Channels.Accounts {
+		m.initChannelAccount(acc)
 	}
 
 	logger.InfoCF("channels", "Channel initialization completed", map[string]any{
@@ -314,6 +374,56 @@ func (m *Manager) initChannels() error {
 	return nil
 }
 
+// initChannelAccount creates a named account of a channel type, e.g. a
+// second Telegram bot. It runs as channel "kind_id" with its own worker.
+func (m *Manager) initChannelAccount(acc config.ChannelAccountConfig) {
+	chCfg, err := m.config.Channels.ForAccount(acc)
+	if err != nil {
+		logger.ErrorCF("channels", "Invalid channel account", map[string]any{
+			"channel": acc.Channel,
+			"account": acc.ID,
+			"error":   err.Error(),
+		})
+		return
+	}
+	for _, spec := range channelSpecs {
+		if spec.section != acc.Channel || !spec.enabled(chCfg) {
+			continue
+		}
+		displayName := spec.displayName + " account " + acc.ID
+		f, ok := getFactory(spec.name)
+		if !ok {
+			logger.WarnCF("channels", "Factory not registered", map[string]any{
+				"channel": displayName,
+			})
+			return
+		}
+		name := InstanceName(spec.name, acc.ID)
+		if _, exists := m.channels[name]; exists {
+			logger.ErrorCF("channels", "Duplicate channel instance", map[string]any{
+				"channel": name,
+			})
+			return
+		}
+		cfg := *m.config
+		cfg.Channels = *chCfg
+		ch, err := f(&cfg, m.bus)
+		if err == nil {
+			if setter, ok := ch.(interface{ SetAccount(name, accountID string) }); ok {
+				setter.SetAccount(name, acc.ID)
+			} else {
+				err = fmt.Errorf("channel type %s does not support accounts", spec.name)
+			}
+		}
+		m.addChannel(name, displayName, ch, err)
+		return
+	}
+	logger.WarnCF("channels", "Channel account is disabled or incomplete", map[string]any{
+		"channel": acc.Channel,
+		"account": acc.ID,
+	})
+}
+
 // SetupHTTPServer creates a shared HTTP server with the given listen address.
 // It registers health endpoints from the health server and discovers channels
 // that implement WebhookHandler and/or HealthChecker to register their handlers.
@@ -325,16 +435,32 @@ func (m *Manager) SetupHTTPServer(addr string, healthServer *health.Server) {
 		healthServer.RegisterOnMux(m.mux)
 	}
 
-	// Discover and register webhook handlers and health checkers
-	for name, ch := range m.channels {
-		if wh, ok := ch.(WebhookHandler); ok {
+	// Discover and register webhook handlers and health checkers.
+	// Accounts of the same channel type default to the same paths, and
+	// ServeMux panics on duplicates.
+	registered := make(map[string]string) // path → channel
+	claim := func(path, name string) bool {
+		if other, taken := registered[path]; taken {
+			logger.ErrorCF("channels", "HTTP path already in use; configure a distinct path", map[string]any{
+				"channel": name,
+				"other":   other,
+				"path":    path,
+			})
+			return false
+		}
+		registered[path] = name
+		return true
+	}
+	for _, name := range slices.Sorted(maps.Keys(m.channels)) {
+		ch := m.channels[name]
+		if wh, ok := ch.(WebhookHandler); ok && claim(wh.WebhookPath(), name) {
 			m.mux.Handle(wh.WebhookPath(), wh)
 			logger.InfoCF("channels", "Webhook handler registered", map[string]any{
 				"channel": name,
 				"path":    wh.WebhookPath(),
 			})
 		}
-		if hc, ok := ch.(HealthChecker); ok {
+		if hc, ok := ch.(HealthChecker); ok && claim(hc.HealthPath(), name) {
 			m.mux.HandleFunc(hc.HealthPath(), hc.HealthHandler)
 			logger.InfoCF("channels", "Health endpoint registered", map[string]any{
 				"channel": name,
@@ -475,7 +601,11 @@ func (m *Manager) StopAll(ctx context.Context) error {
 // for the given channel name.
 func newChannelWorker(name string, ch Channel) *channelWorker {
 	rateVal := float64(defaultRateLimit)
-	if r, ok := channelRateConfig[name]; ok {
+	kind := name
+	if a, ok := ch.(interface{ AccountID() string }); ok {
+		kind = ChannelKind(name, a.AccountID())
+	}
+	if r, ok := channelRateConfig[kind]; ok {
 		rateVal = r
 	}
 	burst := int(math.Max(1, math.Ceil(rateVal/2)))
@@ -500,26 +630,53 @@ func (m *Manager) runWorker(ctx context.Context, name string, w *channelWorker)
 			if !ok {
 				return
 			}
-			maxLen := 0
-			if mlp, ok := w.ch.(MessageLengthProvider); ok {
-				maxLen = mlp.MaxMessageLength()
-			}
-			if maxLen > 0 && len([]rune(msg.Content)) > maxLen {
-				chunks := SplitMessage(msg.Content, maxLen)
-				for _, chunk := range chunks {
-					chunkMsg := msg
-					chunkMsg.Content = chunk
-					m.sendWithRetry(ctx, name, w, chunkMsg)
-				}
-			} else {
-				m.sendWithRetry(ctx, name, w, msg)
-			}
+			m.deliver(ctx, name, w, msg)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// deliver sends msg through the worker, splitting it when it exceeds the
+// channel's maximum message length. A choice prompt is rendered as a
+// numbered menu for channels that are not ChoiceCapable; otherwise it is
+// attached to the last chunk only.
+func (m *Manager) deliver(ctx context.Context, name string, w *channelWorker, msg bus.OutboundMessage) {
+	prompt := msg.Prompt
+	if prompt != nil {
+		if _, ok := w.ch.(ChoiceCapable); !ok {
+			msg.Content = FormatChoiceMenu(msg.Content, prompt)
+			msg.Prompt = nil
+			prompt = nil
+		}
+	}
+
+	maxLen := 0
+	if mlp, ok := w.ch.(MessageLengthProvider); ok {
+		maxLen = mlp.MaxMessageLength()
+	}
+	format := FormatNone
+	if tfp, ok := w.ch.(TextFormatProvider); ok {
+		format = tfp.TextFormat()
+	}
+	// Rendering can lengthen text (HTML tags, escapes), so formatted channels
+	// are always measured after rendering rather than by source length.
+	if maxLen > 0 && (format != FormatNone || len([]rune(msg.Content)) > maxLen) {
+		chunks := SplitMessageFormat(msg.Content, maxLen, format)
+		for i, chunk := range chunks {
+			chunkMsg := msg
+			chunkMsg.Content = chunk
+			chunkMsg.Prompt = nil
+			if i == len(chunks)-1 {
+				chunkMsg.Prompt = prompt
+			}
+			m.sendWithRetry(ctx, name, w, chunkMsg)
+		}
+	} else {
+		m.sendWithRetry(ctx, name, w, msg)
+	}
+}
+
 // sendWithRetry sends a message through the channel with rate limiting and
 // retry logic. It classifies errors to determine the retry strategy:
 //   - ErrNotRunning / ErrSendFailed: permanent, no retry
@@ -537,9 +694,14 @@ func (m *Manager) sendWithRetry(ctx context.Context, name string, w *channelWork
 		return // placeholder was edited successfully, skip Send
 	}
 
+	send := w.ch.Send
+	if cc, ok := w.ch.(ChoiceCapable); ok && msg.Prompt != nil {
+		send = cc.SendChoices
+	}
+
 	var lastErr error
 	for attempt := 0; attempt <= maxRetries; attempt++ {
-		lastErr = w.ch.Send(ctx, msg)
+		lastErr = send(ctx, msg)
 		if lastErr == nil {
 			return
 		}
@@ -855,19 +1017,7 @@ func (m *Manager) SendMessage(ctx context.Context, msg bus.OutboundMessage) erro
 		return fmt.Errorf("channel %s has no active worker", msg.Channel)
 	}
 
-	maxLen := 0
-	if mlp, ok := w.ch.(MessageLengthProvider); ok {
-		maxLen = mlp.MaxMessageLength()
-	}
-	if maxLen > 0 && len([]rune(msg.Content)) > maxLen {
-		for _, chunk := range SplitMessage(msg.Content, maxLen) {
-			chunkMsg := msg
-			chunkMsg.Content = chunk
-			m.sendWithRetry(ctx, msg.Channel, w, chunkMsg)
-		}
-	} else {
-		m.sendWithRetry(ctx, msg.Channel, w, msg)
-	}
+	m.deliver(ctx, msg.Channel, w, msg)
 	return nil
 }
 
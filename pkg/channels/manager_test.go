@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -12,6 +13,7 @@ import (
 	"golang.org/x/time/rate"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 // mockChannel is a test double that delegates Send to a configurable function.
@@ -1190,3 +1192,169 @@ func TestManager_SendPlaceholder(t *testing.T) {
 		t.Error("expected SendPlaceholder to fail for unknown channel")
 	}
 }
+
+func TestInitChannels_NamedAccounts(t *testing.T) {
+	var tokens []string
+	RegisterFactory("telegram", func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+		tokens = append(tokens, cfg.Channels.Telegram.Token)
+		return &mockChannel{BaseChannel: BaseChannel{name: "telegram", bus: b}}, nil
+	})
+
+	cfg := &config.Config{}
+	cfg.Channels.Telegram = config.TelegramConfig{Enabled: true, Token: "personal", AllowFrom: []string{"alice"}}
+	cfg.Channels.Accounts = []config.ChannelAccountConfig{
+		{ID: "team", Channel: "telegram", Settings: []byte(`{"token":"team"}`)},
+		{ID: "off", Channel: "telegram", Settings: []byte(`{"token":"x","enabled":false}`)},
+		{ID: "Bad ID", Channel: "telegram"},
+	}
+	mb := bus.NewMessageBus()
+	m, err := NewManager(cfg, mb, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.channels) != 2 || len(tokens) != 2 || tokens[0] != "personal" || tokens[1] != "team" {
+		t.Fatalf("channels %v, tokens %v", m.GetEnabledChannels(), tokens)
+	}
+	team, ok := m.channels["telegram_team"].(*mockChannel)
+	if !ok || team.Name() != "telegram_team" || team.AccountID() != "team" {
+		t.Fatalf("team account channel = %+v", m.channels["telegram_team"])
+	}
+	if w := newChannelWorker("telegram_team", team); w.limiter.Limit() != rate.Limit(channelRateConfig["telegram"]) {
+		t.Errorf("account worker rate = %v", w.limiter.Limit())
+	}
+
+	team.HandleMessage(context.Background(), bus.Peer{Kind: "direct", ID: "7"}, "1", "7", "7", "hi", nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok || msg.Channel != "telegram_team" || msg.AccountID != "team" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	if ChannelKind(msg.Channel, msg.AccountID) != "telegram" {
+		t.Errorf("ChannelKind(%q, %q) = %q", msg.Channel, msg.AccountID, ChannelKind(msg.Channel, msg.AccountID))
+	}
+}
+
+// mockChoiceChannel implements ChoiceCapable and MessageLengthProvider.
+type mockChoiceChannel struct {
+	mockChannelWithLength
+	choices []bus.OutboundMessage
+}
+
+func (m *mockChoiceChannel) SendChoices(ctx context.Context, msg bus.OutboundMessage) error {
+	m.choices = append(m.choices, msg)
+	return nil
+}
+
+func TestDeliver_PromptFallsBackToMenu(t *testing.T) {
+	m := newTestManager()
+	ch := &mockChannel{sendFn: func(context.Context, bus.OutboundMessage) error { return nil }}
+	w := &channelWorker{ch: ch, limiter: rate.NewLimiter(rate.Inf, 1)}
+
+	m.deliver(context.Background(), "test", w, bus.OutboundMessage{
+		Channel: "test",
+		ChatID:  "1",
+		Content: "Deploy now?",
+		Prompt:  &bus.ChoicePrompt{ID: "p1", Options: []string{"Yes", "No"}},
+	})
+
+	if len(ch.sentMessages) != 1 {
+		t.Fatalf("expected 1 Send call, got %d", len(ch.sentMessages))
+	}
+	sent := ch.sentMessages[0]
+	if sent.Prompt != nil {
+		t.Error("prompt should be removed once rendered as text")
+	}
+	if !strings.Contains(sent.Content, "1. Yes") || !strings.Contains(sent.Content, "2. No") {
+		t.Errorf("expected numbered menu, got %q", sent.Content)
+	}
+}
+
+func TestDeliver_PromptOnLastChunk(t *testing.T) {
+	m := newTestManager()
+	ch := &mockChoiceChannel{
+		mockChannelWithLength: mockChannelWithLength{
+			mockChannel: mockChannel{sendFn: func(context.Context, bus.OutboundMessage) error { return nil }},
+			maxLen:      5,
+		},
+	}
+	w := &channelWorker{ch: ch, limiter: rate.NewLimiter(rate.Inf, 1)}
+
+	m.deliver(context.Background(), "test", w, bus.OutboundMessage{
+		Channel: "test",
+		ChatID:  "1",
+		Content: "hello world",
+		Prompt:  &bus.ChoicePrompt{ID: "p1", Options: []string{"Yes", "No"}},
+	})
+
+	if len(ch.sentMessages) == 0 {
+		t.Fatal("expected leading chunks to go through Send")
+	}
+	for _, msg := range ch.sentMessages {
+		if msg.Prompt != nil {
+			t.Errorf("chunk %q should not carry the prompt", msg.Content)
+		}
+	}
+	if len(ch.choices) != 1 || ch.choices[0].Prompt == nil {
+		t.Fatalf("expected the last chunk via SendChoices, got %+v", ch.choices)
+	}
+}
+
+func TestPreSend_PromptSkipsPlaceholderEdit(t *testing.T) {
+	m := newTestManager()
+	ch := &mockChannel{sendFn: func(context.Context, bus.OutboundMessage) error { return nil }}
+	m.RecordPlaceholder("test", "1", "ph-1")
+
+	msg := bus.OutboundMessage{
+		Channel: "test",
+		ChatID:  "1",
+		Content: "Deploy now?",
+		Prompt:  &bus.ChoicePrompt{ID: "p1", Options: []string{"Yes", "No"}},
+	}
+	if m.preSend(context.Background(), "test", msg, ch) {
+		t.Fatal("preSend should not skip sending a prompt")
+	}
+	if ch.editedMessages != 0 {
+		t.Error("a prompt must not be edited into a placeholder")
+	}
+	if _, ok := m.placeholders.Load("test:1"); ok {
+		t.Error("placeholder should be consumed by the prompt")
+	}
+}
+
+type mockFormattedChannel struct {
+	mockChannelWithLength
+	format TextFormat
+}
+
+func (m *mockFormattedChannel) TextFormat() TextFormat {
+	return m.format
+}
+
+func TestDeliver_SplitsByRenderedLength(t *testing.T) {
+	m := newTestManager()
+	ch := &mockFormattedChannel{
+		mockChannelWithLength: mockChannelWithLength{
+			mockChannel: mockChannel{sendFn: func(context.Context, bus.OutboundMessage) error { return nil }},
+			maxLen:      100,
+		},
+		format: FormatTelegramHTML,
+	}
+	w := &channelWorker{ch: ch, limiter: rate.NewLimiter(rate.Inf, 1)}
+
+	// 90 runes of Markdown, but each "**a**" renders as "<b>a</b>".
+	content := strings.TrimSpace(strings.Repeat("**a** ", 15))
+	m.deliver(context.Background(), "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1", Content: content})
+
+	if len(ch.sentMessages) < 2 {
+		t.Fatalf("expected the rendered message to be split, got %d chunk(s)", len(ch.sentMessages))
+	}
+	for _, msg := range ch.sentMessages {
+		if n := len([]rune(RenderMarkdown(msg.Content, FormatTelegramHTML))); n > 100 {
+			t.Errorf("chunk renders to %d runes, want <= 100", n)
+		}
+		if strings.Count(msg.Content, "**")%2 != 0 {
+			t.Errorf("chunk %q cuts through bold markup", msg.Content)
+		}
+	}
+}
@@ -14,9 +14,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/gomarkdown/markdown"
-	mdhtml "github.com/gomarkdown/markdown/html"
-	"github.com/gomarkdown/markdown/parser"
 	"maunium.net/go/mautrix"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
@@ -216,6 +213,7 @@ func NewMatrixChannel(cfg config.MatrixConfig, messageBus *bus.MessageBus) (*Mat
 		messageBus,
 		cfg.AllowFrom,
 		channels.WithMaxMessageLength(65536),
+		channels.WithTextFormat(matrixTextFormat(cfg.MessageFormat)),
 		channels.WithGroupTrigger(cfg.GroupTrigger),
 		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
 	)
@@ -270,10 +268,42 @@ func (c *MatrixChannel) Stop(ctx context.Context) error {
 	return nil
 }
 
+// matrixChatID returns the chat ID of a thread in a room, "<room>/<root>".
+// Room and event IDs never contain "/", so the split is unambiguous.
+func matrixChatID(roomID id.RoomID, threadRoot id.EventID) string {
+	return roomID.String() + "/" + threadRoot.String()
+}
+
+// parseMatrixChatID splits a chat ID made by matrixChatID; a plain room ID
+// has no thread root.
+func parseMatrixChatID(chatID string) (id.RoomID, id.EventID) {
+	roomID, threadRoot, _ := strings.Cut(strings.TrimSpace(chatID), "/")
+	return id.RoomID(roomID), id.EventID(threadRoot)
+}
+
+// setMatrixThread posts content into the thread rooted at threadRoot, with
+// the reply fallback for clients that don't render threads.
+func setMatrixThread(content *event.MessageEventContent, threadRoot id.EventID) {
+	if threadRoot == "" {
+		return
+	}
+	if content.RelatesTo == nil {
+		content.RelatesTo = &event.RelatesTo{}
+	}
+	content.RelatesTo.SetThread(threadRoot, threadRoot)
+}
+
+// matrixTextFormat maps the message_format setting onto the format the
+// Manager measures chunks in; "plain" sends Markdown source untouched.
+func matrixTextFormat(messageFormat string) channels.TextFormat {
+	if messageFormat == "plain" {
+		return channels.FormatNone
+	}
+	return channels.FormatHTML
+}
+
 func markdownToHTML(md string) string {
-	p := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs)
-	renderer := mdhtml.NewRenderer(mdhtml.RendererOptions{Flags: mdhtml.CommonFlags})
-	return strings.TrimSpace(string(markdown.ToHTML([]byte(md), p, renderer)))
+	return channels.RenderMarkdown(md, channels.FormatHTML)
 }
 
 func (c *MatrixChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
@@ -281,7 +311,7 @@ func (c *MatrixChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return channels.ErrNotRunning
 	}
 
-	roomID := id.RoomID(strings.TrimSpace(msg.ChatID))
+	roomID, threadRoot := parseMatrixChatID(msg.ChatID)
 	if roomID == "" {
 		return fmt.Errorf("matrix room ID is empty: %w", channels.ErrSendFailed)
 	}
@@ -291,7 +321,9 @@ func (c *MatrixChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return nil
 	}
 
-	_, err := c.client.SendMessageEvent(ctx, roomID, event.EventMessage, c.messageContent(content))
+	mc := c.messageContent(content)
+	setMatrixThread(mc, threadRoot)
+	_, err := c.client.SendMessageEvent(ctx, roomID, event.EventMessage, mc)
 	if err != nil {
 		return fmt.Errorf("matrix send: %w", channels.ErrTemporary)
 	}
@@ -317,7 +349,7 @@ func (c *MatrixChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMess
 		sendCtx = context.Background()
 	}
 
-	roomID := id.RoomID(strings.TrimSpace(msg.ChatID))
+	roomID, threadRoot := parseMatrixChatID(msg.ChatID)
 	if roomID == "" {
 		return fmt.Errorf("matrix room ID is empty: %w", channels.ErrSendFailed)
 	}
@@ -406,6 +438,7 @@ func (c *MatrixChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMess
 			fileInfo.Size(),
 			uploadResp.ContentURI.CUString(),
 		)
+		setMatrixThread(content, threadRoot)
 
 		if _, err := c.client.SendMessageEvent(sendCtx, roomID, event.EventMessage, content); err != nil {
 			logger.ErrorCF("matrix", "Failed to send media message", map[string]any{
@@ -426,7 +459,7 @@ func (c *MatrixChannel) StartTyping(ctx context.Context, chatID string) (func(),
 		return func() {}, nil
 	}
 
-	roomID := id.RoomID(strings.TrimSpace(chatID))
+	roomID, _ := parseMatrixChatID(chatID)
 	if roomID == "" {
 		return func() {}, fmt.Errorf("matrix room ID is empty")
 	}
@@ -465,7 +498,7 @@ func (c *MatrixChannel) SendPlaceholder(ctx context.Context, chatID string) (str
 		return "", nil
 	}
 
-	roomID := id.RoomID(strings.TrimSpace(chatID))
+	roomID, threadRoot := parseMatrixChatID(chatID)
 	if roomID == "" {
 		return "", fmt.Errorf("matrix room ID is empty")
 	}
@@ -475,10 +508,12 @@ func (c *MatrixChannel) SendPlaceholder(ctx context.Context, chatID string) (str
 		text = "Thinking... 💭"
 	}
 
-	resp, err := c.client.SendMessageEvent(ctx, roomID, event.EventMessage, &event.MessageEventContent{
+	placeholder := &event.MessageEventContent{
 		MsgType: event.MsgNotice,
 		Body:    text,
-	})
+	}
+	setMatrixThread(placeholder, threadRoot)
+	resp, err := c.client.SendMessageEvent(ctx, roomID, event.EventMessage, placeholder)
 	if err != nil {
 		return "", err
 	}
@@ -488,7 +523,7 @@ func (c *MatrixChannel) SendPlaceholder(ctx context.Context, chatID string) (str
 
 // EditMessage implements channels.MessageEditor.
 func (c *MatrixChannel) EditMessage(ctx context.Context, chatID string, messageID string, content string) error {
-	roomID := id.RoomID(strings.TrimSpace(chatID))
+	roomID, _ := parseMatrixChatID(chatID)
 	if roomID == "" {
 		return fmt.Errorf("matrix room ID is empty")
 	}
@@ -629,12 +664,20 @@ func (c *MatrixChannel) handleMessageEvent(ctx context.Context, evt *event.Event
 		metadata["reply_to_msg_id"] = replyTo.String()
 	}
 
+	// Messages in an m.thread carry the thread root; encode it in the chat ID
+	// so replies stay in the thread.
+	chatID := roomID
+	if threadRoot := msgEvt.GetRelatesTo().GetThreadParent(); threadRoot != "" {
+		chatID = matrixChatID(evt.RoomID, threadRoot)
+		metadata[channels.MetadataThreadID] = threadRoot.String()
+	}
+
 	c.HandleMessage(
 		c.baseContext(),
 		bus.Peer{Kind: peerKind, ID: peerID},
 		evt.ID.String(),
 		senderID,
-		roomID,
+		chatID,
 		content,
 		mediaPaths,
 		metadata,
@@ -1071,9 +1114,10 @@ func (c *MatrixChannel) stopTypingSessions(ctx context.Context) {
 	if stopCtx == nil {
 		stopCtx = context.Background()
 	}
-	for roomID, session := range sessions {
+	for chatID, session := range sessions {
 		session.stop()
-		_, _ = c.client.UserTyping(stopCtx, id.RoomID(roomID), false, 0)
+		roomID, _ := parseMatrixChatID(chatID)
+		_, _ = c.client.UserTyping(stopCtx, roomID, false, 0)
 	}
 }
 
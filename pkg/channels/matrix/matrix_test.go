@@ -386,3 +386,25 @@ func TestMessageContent(t *testing.T) {
 		t.Errorf("plain: expected no formatting, got format=%q formattedBody=%q", mc.Format, mc.FormattedBody)
 	}
 }
+
+func TestMatrixChatID_Thread(t *testing.T) {
+	chatID := matrixChatID("!room:matrix.org", "$Root_Event")
+	roomID, root := parseMatrixChatID(chatID)
+	if roomID != "!room:matrix.org" || root != "$Root_Event" {
+		t.Fatalf("parseMatrixChatID(%q) = %q, %q", chatID, roomID, root)
+	}
+
+	roomID, root = parseMatrixChatID("!room:matrix.org")
+	if roomID != "!room:matrix.org" || root != "" {
+		t.Fatalf("plain room parsed as %q, %q", roomID, root)
+	}
+
+	content := &event.MessageEventContent{MsgType: event.MsgText, Body: "hi"}
+	setMatrixThread(content, "$Root_Event")
+	if content.RelatesTo.GetThreadParent() != "$Root_Event" {
+		t.Errorf("thread parent = %q", content.RelatesTo.GetThreadParent())
+	}
+	if !content.RelatesTo.IsFallingBack {
+		t.Error("thread reply should carry the reply fallback")
+	}
+}
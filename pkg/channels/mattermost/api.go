@@ -0,0 +1,190 @@
+package mattermost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/channels"
+)
+
+// apiClient is a small client for the Mattermost REST API v4.
+type apiClient struct {
+	base   string // server URL without trailing slash
+	token  string
+	client *http.Client
+}
+
+func newAPIClient(base, token string) *apiClient {
+	return &apiClient{
+		base:   strings.TrimRight(base, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type user struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type post struct {
+	ID        string         `json:"id,omitempty"`
+	UserID    string         `json:"user_id,omitempty"`
+	ChannelID string         `json:"channel_id"`
+	RootID    string         `json:"root_id,omitempty"`
+	Message   string         `json:"message"`
+	Type      string         `json:"type,omitempty"`
+	FileIDs   []string       `json:"file_ids,omitempty"`
+	Props     map[string]any `json:"props,omitempty"`
+}
+
+type fileInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+}
+
+// do sends a JSON request and decodes the JSON response into out, if non-nil.
+func (a *apiClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.base+"/api/v4"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return a.send(req, out)
+}
+
+func (a *apiClient) send(req *http.Request, out any) error {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return channels.ClassifyNetError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return channels.ClassifySendError(resp.StatusCode,
+			fmt.Errorf("mattermost %s %s: HTTP %d: %s", req.Method, req.URL.Path, resp.StatusCode,
+				strings.TrimSpace(string(data))))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (a *apiClient) me(ctx context.Context) (user, error) {
+	var u user
+	err := a.do(ctx, http.MethodGet, "/users/me", nil, &u)
+	return u, err
+}
+
+func (a *apiClient) createPost(ctx context.Context, p post) (post, error) {
+	var created post
+	err := a.do(ctx, http.MethodPost, "/posts", p, &created)
+	return created, err
+}
+
+func (a *apiClient) patchPost(ctx context.Context, postID, message string) error {
+	return a.do(ctx, http.MethodPut, "/posts/"+postID+"/patch", map[string]string{"message": message}, nil)
+}
+
+func (a *apiClient) fileInfo(ctx context.Context, fileID string) (fileInfo, error) {
+	var info fileInfo
+	err := a.do(ctx, http.MethodGet, "/files/"+fileID+"/info", nil, &info)
+	return info, err
+}
+
+// downloadFile copies the content of a file into w.
+func (a *apiClient) downloadFile(ctx context.Context, fileID string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.base+"/api/v4/files/"+fileID, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("mattermost file %s: HTTP %d", fileID, resp.StatusCode)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// uploadFile uploads a local file to a channel and returns its file ID.
+func (a *apiClient) uploadFile(ctx context.Context, channelID, localPath, filename string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if filename == "" {
+		filename = filepath.Base(localPath)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.WriteField("channel_id", channelID); err != nil {
+		return "", err
+	}
+	part, err := mw.CreateFormFile("files", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.base+"/api/v4/files", &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	var result struct {
+		FileInfos []fileInfo `json:"file_infos"`
+	}
+	if err := a.send(req, &result); err != nil {
+		return "", err
+	}
+	if len(result.FileInfos) == 0 {
+		return "", fmt.Errorf("mattermost upload %s: no file info returned", filename)
+	}
+	return result.FileInfos[0].ID, nil
+}
+
+// websocketURL returns the event stream URL for the server.
+func (a *apiClient) websocketURL() string {
+	u := a.base + "/api/v4/websocket"
+	if rest, ok := strings.CutPrefix(u, "https://"); ok {
+		return "wss://" + rest
+	}
+	if rest, ok := strings.CutPrefix(u, "http://"); ok {
+		return "ws://" + rest
+	}
+	return u
+}
@@ -0,0 +1,436 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	maxBackoff         = time.Minute
+	maxMessageLength   = 16383 // server default for post messages
+	defaultPlaceholder = "Thinking... 💭"
+)
+
+// MattermostChannel connects to a Mattermost server as a bot: posts arrive
+// over the websocket event stream and replies go through the REST API.
+//
+// Chat IDs are the channel ID, or "channelID/rootID" inside a thread.
+type MattermostChannel struct {
+	*channels.BaseChannel
+	config config.MattermostConfig
+	api    *apiClient
+
+	botID       string
+	botUsername string
+	mentionRe   *regexp.Regexp // @botUsername, case-insensitive
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewMattermostChannel creates a Mattermost channel from its config.
+func NewMattermostChannel(cfg config.MattermostConfig, messageBus *bus.MessageBus) (*MattermostChannel, error) {
+	if cfg.URL == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("mattermost url and token are required")
+	}
+	if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+		return nil, fmt.Errorf("mattermost url %q must start with http:// or https://", cfg.URL)
+	}
+
+	base := channels.NewBaseChannel("mattermost", cfg, messageBus, cfg.AllowFrom,
+		channels.WithMaxMessageLength(maxMessageLength),
+		channels.WithGroupTrigger(cfg.GroupTrigger),
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+
+	return &MattermostChannel{
+		BaseChannel: base,
+		config:      cfg,
+		api:         newAPIClient(cfg.URL, cfg.Token),
+	}, nil
+}
+
+// Start looks up the bot user and begins listening for events.
+func (c *MattermostChannel) Start(ctx context.Context) error {
+	logger.InfoC("mattermost", "Starting Mattermost channel")
+
+	meCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	me, err := c.api.me(meCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("mattermost auth: %w", err)
+	}
+	c.botID, c.botUsername = me.ID, me.Username
+	if me.Username != "" {
+		c.mentionRe = regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(me.Username) + `\b`)
+	}
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.wg.Add(1)
+	go c.listen()
+
+	c.SetRunning(true)
+	logger.InfoCF("mattermost", "Mattermost channel started", map[string]any{
+		"url":      c.config.URL,
+		"username": c.botUsername,
+	})
+	return nil
+}
+
+// Stop closes the event stream.
+func (c *MattermostChannel) Stop(ctx context.Context) error {
+	logger.InfoC("mattermost", "Stopping Mattermost channel")
+	c.SetRunning(false)
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+	logger.InfoC("mattermost", "Mattermost channel stopped")
+	return nil
+}
+
+// listen keeps the websocket connected, reconnecting with backoff.
+func (c *MattermostChannel) listen() {
+	defer c.wg.Done()
+	backoff := time.Second
+	for c.ctx.Err() == nil {
+		started := time.Now()
+		err := c.readEvents()
+		if c.ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > maxBackoff {
+			backoff = time.Second
+		}
+		logger.WarnCF("mattermost", "Websocket connection lost, reconnecting", map[string]any{
+			"error":   fmt.Sprint(err),
+			"backoff": backoff.String(),
+		})
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+type wsEvent struct {
+	Event string         `json:"event"`
+	Data  map[string]any `json:"data"`
+}
+
+func (c *MattermostChannel) readEvents() error {
+	header := http.Header{"Authorization": {"Bearer " + c.config.Token}}
+	conn, _, err := websocket.DefaultDialer.DialContext(c.ctx, c.api.websocketURL(), header)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(c.ctx, func() { conn.Close() })
+	defer func() {
+		stop()
+		conn.Close()
+	}()
+
+	// The Authorization header is enough for current servers; the challenge
+	// covers proxies that strip it.
+	err = conn.WriteJSON(map[string]any{
+		"seq":    1,
+		"action": "authentication_challenge",
+		"data":   map[string]string{"token": c.config.Token},
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var ev wsEvent
+		if json.Unmarshal(data, &ev) != nil || ev.Event != "posted" {
+			continue // replies to our actions and other events
+		}
+		// The fields of interest are all strings; post and mentions are
+		// JSON-encoded.
+		fields := make(map[string]string, len(ev.Data))
+		for k, v := range ev.Data {
+			if s, ok := v.(string); ok {
+				fields[k] = s
+			}
+		}
+		c.handlePosted(fields)
+	}
+}
+
+func (c *MattermostChannel) handlePosted(data map[string]string) {
+	var p post
+	if err := json.Unmarshal([]byte(data["post"]), &p); err != nil {
+		logger.DebugCF("mattermost", "Ignoring undecodable post", map[string]any{"error": err.Error()})
+		return
+	}
+	if p.UserID == "" || p.UserID == c.botID || p.Type != "" {
+		return // own posts and system messages
+	}
+	if fromBot, _ := p.Props["from_bot"].(string); fromBot == "true" {
+		return
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "mattermost",
+		PlatformID:  p.UserID,
+		CanonicalID: identity.BuildCanonicalID("mattermost", p.UserID),
+		Username:    strings.TrimPrefix(data["sender_name"], "@"),
+	}
+	if !c.IsAllowedSender(sender) {
+		logger.DebugCF("mattermost", "Message rejected by allowlist", map[string]any{"user_id": p.UserID})
+		return
+	}
+
+	chatID := p.ChannelID
+	if p.RootID != "" {
+		chatID = p.ChannelID + "/" + p.RootID
+	}
+
+	content := p.Message
+	peer := bus.Peer{Kind: "direct", ID: p.UserID}
+	if data["channel_type"] != "D" {
+		peer = bus.Peer{Kind: "channel", ID: p.ChannelID}
+		if data["channel_type"] == "G" {
+			peer.Kind = "group"
+		}
+		isMentioned := c.isMentioned(data["mentions"], content)
+		respond, cleaned := c.ShouldRespondInGroup(isMentioned, c.stripBotMention(content))
+		if !respond {
+			return
+		}
+		content = cleaned
+	}
+
+	scope := channels.BuildMediaScope("mattermost", chatID, p.ID)
+	var mediaRefs []string
+	for _, fileID := range p.FileIDs {
+		ref, annotation := c.downloadFile(fileID, scope)
+		if ref != "" {
+			mediaRefs = append(mediaRefs, ref)
+		}
+		content = appendContent(content, annotation)
+	}
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	metadata := map[string]string{
+		"platform":     "mattermost",
+		"channel_id":   p.ChannelID,
+		"root_id":      p.RootID,
+		"channel_type": data["channel_type"],
+		"team_id":      data["team_id"],
+	}
+
+	logger.DebugCF("mattermost", "Received message", map[string]any{
+		"sender_id": p.UserID,
+		"chat_id":   chatID,
+		"preview":   utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(c.ctx, peer, p.ID, p.UserID, chatID, content, mediaRefs, metadata, sender)
+}
+
+// isMentioned reports whether the bot is among the mentioned user IDs of a
+// post event, or its @username appears in the text.
+func (c *MattermostChannel) isMentioned(mentions, text string) bool {
+	var ids []string
+	if mentions != "" && json.Unmarshal([]byte(mentions), &ids) == nil {
+		for _, id := range ids {
+			if id == c.botID {
+				return true
+			}
+		}
+	}
+	return c.mentionRe != nil && c.mentionRe.MatchString(text)
+}
+
+func (c *MattermostChannel) stripBotMention(text string) string {
+	if c.mentionRe == nil {
+		return text
+	}
+	return strings.TrimSpace(c.mentionRe.ReplaceAllString(text, ""))
+}
+
+// downloadFile stores an attached file in the MediaStore. It returns the
+// media ref ("" on failure) and an annotation for the message text.
+func (c *MattermostChannel) downloadFile(fileID, scope string) (string, string) {
+	ctx, cancel := context.WithTimeout(c.ctx, 2*time.Minute)
+	defer cancel()
+
+	info, err := c.api.fileInfo(ctx, fileID)
+	if err != nil {
+		logger.WarnCF("mattermost", "Failed to get file info", map[string]any{"file_id": fileID, "error": err.Error()})
+		return "", "[file]"
+	}
+	annotation := fmt.Sprintf("[%s: %s]", mediaKind(info.MimeType), info.Name)
+
+	store := c.GetMediaStore()
+	if store == nil {
+		return "", annotation
+	}
+	dir := media.TempDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", annotation
+	}
+	tmp, err := os.CreateTemp(dir, "mattermost-*"+filepath.Ext(info.Name))
+	if err != nil {
+		return "", annotation
+	}
+	err = c.api.downloadFile(ctx, fileID, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		var ref string
+		ref, err = store.Store(tmp.Name(), media.MediaMeta{
+			Filename:    info.Name,
+			ContentType: info.MimeType,
+			Source:      "mattermost",
+		}, scope)
+		if err == nil {
+			return ref, annotation
+		}
+	}
+	_ = os.Remove(tmp.Name())
+	logger.WarnCF("mattermost", "Failed to download file", map[string]any{"file_id": fileID, "error": err.Error()})
+	return "", annotation
+}
+
+func mediaKind(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return "image"
+	case strings.HasPrefix(contentType, "audio/"):
+		return "audio"
+	case strings.HasPrefix(contentType, "video/"):
+		return "video"
+	default:
+		return "file"
+	}
+}
+
+func appendContent(content, suffix string) string {
+	if content == "" {
+		return suffix
+	}
+	return content + "\n" + suffix
+}
+
+// parseChatID splits a chat ID into channel ID and thread root post ID.
+func parseChatID(chatID string) (channelID, rootID string) {
+	channelID, rootID, _ = strings.Cut(chatID, "/")
+	return channelID, rootID
+}
+
+// target returns the channel and thread root for a reply. A reply to a
+// top-level message starts a thread under it, as on Slack.
+func target(chatID, replyTo string) (channelID, rootID string) {
+	channelID, rootID = parseChatID(chatID)
+	if rootID == "" {
+		rootID = replyTo
+	}
+	return channelID, rootID
+}
+
+// Send posts a text message.
+func (c *MattermostChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	channelID, rootID := target(msg.ChatID, msg.ReplyToMessageID)
+	if channelID == "" {
+		return fmt.Errorf("invalid mattermost chat ID %q: %w", msg.ChatID, channels.ErrSendFailed)
+	}
+	_, err := c.api.createPost(ctx, post{ChannelID: channelID, RootID: rootID, Message: msg.Content})
+	return err
+}
+
+// SendMedia implements channels.MediaSender. Each part is uploaded and
+// posted with its caption.
+func (c *MattermostChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		return fmt.Errorf("no media store available: %w", channels.ErrSendFailed)
+	}
+	channelID, rootID := parseChatID(msg.ChatID)
+
+	for _, part := range msg.Parts {
+		localPath, meta, err := store.ResolveWithMeta(part.Ref)
+		if err != nil {
+			logger.ErrorCF("mattermost", "Failed to resolve media ref", map[string]any{
+				"ref":   part.Ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+		filename := part.Filename
+		if filename == "" {
+			filename = meta.Filename
+		}
+		fileID, err := c.api.uploadFile(ctx, channelID, localPath, filename)
+		if err != nil {
+			return fmt.Errorf("mattermost upload: %w", err)
+		}
+		if _, err := c.api.createPost(ctx, post{
+			ChannelID: channelID,
+			RootID:    rootID,
+			Message:   part.Caption,
+			FileIDs:   []string{fileID},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EditMessage implements channels.MessageEditor.
+func (c *MattermostChannel) EditMessage(ctx context.Context, chatID, messageID, content string) error {
+	return c.api.patchPost(ctx, messageID, content)
+}
+
+// SendPlaceholder implements channels.PlaceholderCapable.
+func (c *MattermostChannel) SendPlaceholder(ctx context.Context, chatID string) (string, error) {
+	if !c.config.Placeholder.Enabled {
+		return "", nil
+	}
+	text := c.config.Placeholder.Text
+	if text == "" {
+		text = defaultPlaceholder
+	}
+	channelID, rootID := parseChatID(chatID)
+	created, err := c.api.createPost(ctx, post{ChannelID: channelID, RootID: rootID, Message: text})
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
@@ -0,0 +1,298 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+const testToken = "tok"
+
+type apiCall struct {
+	Method string
+	Path   string
+	Body   map[string]any
+}
+
+// fakeServer implements the parts of the Mattermost API the channel uses.
+type fakeServer struct {
+	calls  chan apiCall
+	events chan string // websocket frames
+
+	mu     sync.Mutex
+	nextID int
+	auth   map[string]any
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, string) {
+	t.Helper()
+	f := &fakeServer{calls: make(chan apiCall, 32), events: make(chan string, 8)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/users/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"id":"bot1","username":"picobot"}`)
+	})
+	mux.HandleFunc("GET /api/v4/websocket", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var challenge map[string]any
+		if conn.ReadJSON(&challenge) != nil {
+			return
+		}
+		f.mu.Lock()
+		f.auth = challenge
+		f.mu.Unlock()
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"status":"OK","seq_reply":1}`))
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev := <-f.events:
+				if conn.WriteMessage(websocket.TextMessage, []byte(ev)) != nil {
+					return
+				}
+			}
+		}
+	})
+	mux.HandleFunc("POST /api/v4/posts", func(w http.ResponseWriter, r *http.Request) {
+		body := f.record(r)
+		f.mu.Lock()
+		f.nextID++
+		id := fmt.Sprintf("new%d", f.nextID)
+		f.mu.Unlock()
+		body["id"] = id
+		json.NewEncoder(w).Encode(body)
+	})
+	mux.HandleFunc("PUT /api/v4/posts/{id}/patch", func(w http.ResponseWriter, r *http.Request) {
+		f.record(r)
+		fmt.Fprint(w, `{}`)
+	})
+	mux.HandleFunc("POST /api/v4/files", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("files")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		f.calls <- apiCall{Method: r.Method, Path: r.URL.Path, Body: map[string]any{
+			"channel_id": r.FormValue("channel_id"),
+			"filename":   header.Filename,
+			"data":       string(data),
+		}}
+		fmt.Fprint(w, `{"file_infos":[{"id":"up1"}]}`)
+	})
+	mux.HandleFunc("GET /api/v4/files/{id}/info", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":%q,"name":"cat.png","mime_type":"image/png"}`, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /api/v4/files/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "png bytes")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func (f *fakeServer) record(r *http.Request) map[string]any {
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	f.calls <- apiCall{Method: r.Method, Path: r.URL.Path, Body: body}
+	return body
+}
+
+func (f *fakeServer) next(t *testing.T) apiCall {
+	t.Helper()
+	select {
+	case c := <-f.calls:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("no API call")
+		return apiCall{}
+	}
+}
+
+// pushPost sends a "posted" event, encoding the post the way the server does.
+func (f *fakeServer) pushPost(p post, channelType string, mentions ...string) {
+	postJSON, _ := json.Marshal(p)
+	data := map[string]any{
+		"post":         string(postJSON),
+		"channel_type": channelType,
+		"sender_name":  "@alice",
+		"set_online":   true,
+	}
+	if len(mentions) > 0 {
+		m, _ := json.Marshal(mentions)
+		data["mentions"] = string(m)
+	}
+	ev, _ := json.Marshal(map[string]any{"event": "posted", "data": data})
+	f.events <- string(ev)
+}
+
+func startChannel(t *testing.T, url string, cfg config.MattermostConfig) (*MattermostChannel, *bus.MessageBus) {
+	t.Helper()
+	mb := bus.NewMessageBus()
+	cfg.URL, cfg.Token = url, testToken
+	ch, err := NewMattermostChannel(cfg, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.SetMediaStore(media.NewFileMediaStore())
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ch.Stop(context.Background()) })
+	return ch, mb
+}
+
+func nextInbound(t *testing.T, mb *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func TestMattermostChannel_InboundAndThreads(t *testing.T) {
+	f, url := newFakeServer(t)
+	ch, mb := startChannel(t, url, config.MattermostConfig{
+		GroupTrigger: config.GroupTriggerConfig{MentionOnly: true},
+	})
+
+	f.pushPost(post{ID: "own", UserID: "bot1", ChannelID: "dm1", Message: "echo"}, "D")
+	f.pushPost(post{ID: "sys", UserID: "u1", ChannelID: "town", Message: "joined", Type: "system_join_channel"}, "O")
+	f.pushPost(post{ID: "p0", UserID: "u1", ChannelID: "town", Message: "no mention"}, "O")
+	f.pushPost(post{ID: "p1", UserID: "u1", ChannelID: "dm1", Message: "look", FileIDs: []string{"f1"}}, "D")
+
+	dm := nextInbound(t, mb)
+	if dm.ChatID != "dm1" || dm.Peer.Kind != "direct" || dm.MessageID != "p1" || dm.SenderID != "mattermost:u1" {
+		t.Errorf("direct message: chat %q, peer %+v, id %q, sender %q", dm.ChatID, dm.Peer, dm.MessageID, dm.SenderID)
+	}
+	if dm.Content != "look\n[image: cat.png]" || len(dm.Media) != 1 {
+		t.Fatalf("content %q, media %v", dm.Content, dm.Media)
+	}
+	path, meta, err := ch.GetMediaStore().ResolveWithMeta(dm.Media[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "png bytes" || meta.ContentType != "image/png" {
+		t.Errorf("file %q (%s)", data, meta.ContentType)
+	}
+
+	f.pushPost(post{ID: "p2", UserID: "u1", ChannelID: "town", RootID: "root1", Message: "@PicoBot summarize"},
+		"O", "bot1")
+	threaded := nextInbound(t, mb)
+	if threaded.ChatID != "town/root1" || threaded.Peer.Kind != "channel" || threaded.Content != "summarize" {
+		t.Errorf("thread message: chat %q, peer %+v, content %q", threaded.ChatID, threaded.Peer, threaded.Content)
+	}
+
+	f.mu.Lock()
+	auth := f.auth
+	f.mu.Unlock()
+	if auth["action"] != "authentication_challenge" {
+		t.Errorf("websocket auth = %v", auth)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: threaded.ChatID, Content: "sure"}); err != nil {
+		t.Fatal(err)
+	}
+	if c := f.next(t); c.Path != "/api/v4/posts" || c.Body["channel_id"] != "town" || c.Body["root_id"] != "root1" {
+		t.Errorf("thread reply = %+v", c)
+	}
+	err = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "town", Content: "ok", ReplyToMessageID: "p9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := f.next(t); c.Body["channel_id"] != "town" || c.Body["root_id"] != "p9" {
+		t.Errorf("reply starting a thread = %+v", c)
+	}
+}
+
+func TestMattermostChannel_PlaceholderAndMedia(t *testing.T) {
+	f, url := newFakeServer(t)
+	ch, _ := startChannel(t, url, config.MattermostConfig{
+		Placeholder: config.PlaceholderConfig{Enabled: true, Text: "hmm"},
+	})
+
+	id, err := ch.SendPlaceholder(context.Background(), "town/root1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := f.next(t); c.Body["message"] != "hmm" || c.Body["root_id"] != "root1" || id == "" {
+		t.Errorf("placeholder = %+v, id %q", c, id)
+	}
+	if err := ch.EditMessage(context.Background(), "town/root1", id, "done"); err != nil {
+		t.Fatal(err)
+	}
+	if c := f.next(t); c.Method != http.MethodPut || c.Path != "/api/v4/posts/"+id+"/patch" || c.Body["message"] != "done" {
+		t.Errorf("edit = %+v", c)
+	}
+
+	file := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(file, []byte("%PDF"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := ch.GetMediaStore().Store(file, media.MediaMeta{Filename: "report.pdf"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ch.SendMedia(context.Background(), bus.OutboundMediaMessage{
+		ChatID: "town/root1",
+		Parts:  []bus.MediaPart{{Type: "file", Ref: ref, Caption: "Your report"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := f.next(t); c.Path != "/api/v4/files" || c.Body["channel_id"] != "town" ||
+		c.Body["filename"] != "report.pdf" || c.Body["data"] != "%PDF" {
+		t.Errorf("upload = %+v", c)
+	}
+	c := f.next(t)
+	fileIDs, _ := c.Body["file_ids"].([]any)
+	if c.Body["message"] != "Your report" || c.Body["root_id"] != "root1" || len(fileIDs) != 1 || fileIDs[0] != "up1" {
+		t.Errorf("media post = %+v", c)
+	}
+}
+
+func TestNewMattermostChannel_Validation(t *testing.T) {
+	if _, err := NewMattermostChannel(config.MattermostConfig{URL: "https://x"}, bus.NewMessageBus()); err == nil {
+		t.Error("expected an error without token")
+	}
+	if _, err := NewMattermostChannel(config.MattermostConfig{URL: "x.com", Token: "t"}, bus.NewMessageBus()); err == nil {
+		t.Error("expected an error for a URL without scheme")
+	}
+	_, url := newFakeServer(t)
+	ch, err := NewMattermostChannel(config.MattermostConfig{URL: url, Token: "wrong"}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.Start(context.Background()); err == nil {
+		ch.Stop(context.Background())
+		t.Error("expected Start to fail with a bad token")
+	}
+}
@@ -0,0 +1,385 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultClientID      = "picoclaw"
+	defaultTopic         = "picoclaw/in/+"
+	defaultResponseTopic = "picoclaw/out/{chat_id}"
+	defaultStatusTopic   = "picoclaw/status"
+	statusOnline         = "online"
+	statusOffline        = "offline"
+	publishTimeout       = 10 * time.Second
+)
+
+// inboundPayload is the JSON form of an inbound message. Plain-text
+// payloads are taken as Text.
+type inboundPayload struct {
+	Text          string `json:"text"`
+	ClientID      string `json:"client_id,omitempty"`
+	ResponseTopic string `json:"response_topic,omitempty"`
+}
+
+// outboundPayload is published on a chat's response topic in the JSON
+// payload format.
+type outboundPayload struct {
+	ChatID  string       `json:"chat_id"`
+	Text    string       `json:"text,omitempty"`
+	ReplyTo string       `json:"reply_to,omitempty"`
+	Media   []mediaEntry `json:"media,omitempty"`
+}
+
+type mediaEntry struct {
+	Type        string `json:"type"`
+	Ref         string `json:"ref"`
+	Caption     string `json:"caption,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// MQTTChannel exchanges messages with devices over an MQTT broker. It
+// keeps a retained online/offline status, with the broker publishing
+// "offline" as last will when the gateway disappears.
+//
+// MQTT payloads carry no verified identity: the client_id a device reports
+// is what allow_from matches, so anyone able to publish on a subscribed
+// topic can claim it. Restrict who may publish there with broker ACLs.
+type MQTTChannel struct {
+	*channels.BaseChannel
+	config config.MQTTConfig
+	client paho.Client
+	topics []config.MQTTTopicConfig
+	qos    byte
+
+	responseMu     sync.Mutex
+	responseTopics map[string]string // chat ID -> response topic requested by the device
+}
+
+// NewMQTTChannel creates a new MQTT channel.
+func NewMQTTChannel(cfg config.MQTTConfig, messageBus *bus.MessageBus) (*MQTTChannel, error) {
+	if cfg.Broker == "" {
+		return nil, fmt.Errorf("mqtt broker is required")
+	}
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		return nil, fmt.Errorf("mqtt qos must be 0, 1 or 2, got %d", cfg.QoS)
+	}
+	switch cfg.PayloadFormat {
+	case "", "json", "text":
+	default:
+		return nil, fmt.Errorf("mqtt payload_format must be \"json\" or \"text\", got %q", cfg.PayloadFormat)
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = defaultClientID
+	}
+	if cfg.ResponseTopic == "" {
+		cfg.ResponseTopic = defaultResponseTopic
+	}
+	if cfg.StatusTopic == "" {
+		cfg.StatusTopic = defaultStatusTopic
+	}
+	topics := cfg.Topics
+	if len(topics) == 0 {
+		topics = []config.MQTTTopicConfig{{Topic: defaultTopic}}
+	}
+
+	c := &MQTTChannel{
+		config:         cfg,
+		topics:         topics,
+		qos:            byte(cfg.QoS),
+		responseTopics: make(map[string]string),
+	}
+	c.BaseChannel = channels.NewBaseChannel("mqtt", cfg, messageBus, cfg.AllowFrom,
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5*time.Second).
+		SetWill(cfg.StatusTopic, statusOffline, c.qos, true).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			logger.WarnCF("mqtt", "Connection lost, reconnecting", map[string]any{"error": err.Error()})
+		})
+	if cfg.CAFile != "" || cfg.CertFile != "" || cfg.InsecureSkipVerify {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+	c.client = paho.NewClient(opts)
+	return c, nil
+}
+
+func newTLSConfig(cfg config.MQTTConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify} //nolint:gosec // opt-in for self-signed brokers
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mqtt ca_file %s: no certificates found", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Start connects to the broker. The client keeps retrying in the
+// background when the broker is not reachable yet.
+func (c *MQTTChannel) Start(ctx context.Context) error {
+	logger.InfoC("mqtt", "Starting MQTT channel")
+	token := c.client.Connect()
+	if !token.WaitTimeout(publishTimeout) {
+		logger.WarnCF("mqtt", "Broker not reachable yet, retrying in the background",
+			map[string]any{"broker": c.config.Broker})
+	} else if err := token.Error(); err != nil {
+		return fmt.Errorf("mqtt connect: %w", err)
+	}
+
+	c.SetRunning(true)
+	logger.InfoCF("mqtt", "MQTT channel started", map[string]any{
+		"broker":    c.config.Broker,
+		"client_id": c.config.ClientID,
+	})
+	return nil
+}
+
+// Stop marks the gateway offline and disconnects.
+func (c *MQTTChannel) Stop(ctx context.Context) error {
+	logger.InfoC("mqtt", "Stopping MQTT channel")
+	c.SetRunning(false)
+	if c.client.IsConnected() {
+		c.client.Publish(c.config.StatusTopic, c.qos, true, statusOffline).WaitTimeout(publishTimeout)
+	}
+	c.client.Disconnect(250)
+	logger.InfoC("mqtt", "MQTT channel stopped")
+	return nil
+}
+
+// onConnect subscribes and publishes the online status after every
+// (re)connect.
+func (c *MQTTChannel) onConnect(client paho.Client) {
+	for _, t := range c.topics {
+		topic := t
+		token := client.Subscribe(topic.Topic, c.qos, func(_ paho.Client, m paho.Message) {
+			c.handleMessage(topic, m)
+		})
+		if token.WaitTimeout(publishTimeout) && token.Error() != nil {
+			logger.ErrorCF("mqtt", "Subscribe failed", map[string]any{
+				"topic": topic.Topic,
+				"error": token.Error().Error(),
+			})
+		}
+	}
+	client.Publish(c.config.StatusTopic, c.qos, true, statusOnline)
+	logger.InfoCF("mqtt", "Connected to broker", map[string]any{"topics": len(c.topics)})
+}
+
+func (c *MQTTChannel) handleMessage(sub config.MQTTTopicConfig, m paho.Message) {
+	payload := parsePayload(m.Payload())
+	if strings.TrimSpace(payload.Text) == "" {
+		return
+	}
+
+	chatID := sub.ChatID
+	if chatID == "" {
+		chatID = payload.ClientID
+	}
+	if chatID == "" {
+		if captured, ok := matchTopic(sub.Topic, m.Topic()); ok && len(captured) > 0 && captured[0] != "" {
+			chatID = captured[0]
+		} else {
+			chatID = m.Topic()
+		}
+	}
+	senderID := payload.ClientID
+	if senderID == "" {
+		senderID = chatID
+	}
+	if payload.ResponseTopic != "" {
+		if c.allowedResponseTopic(payload.ResponseTopic) {
+			c.responseMu.Lock()
+			c.responseTopics[chatID] = payload.ResponseTopic
+			c.responseMu.Unlock()
+		} else {
+			logger.WarnCF("mqtt", "Ignoring response_topic outside the configured response topic", map[string]any{
+				"chat_id":        chatID,
+				"response_topic": payload.ResponseTopic,
+			})
+		}
+	}
+
+	messageID := fmt.Sprintf("%d", time.Now().UnixNano())
+	if m.MessageID() != 0 {
+		messageID = fmt.Sprintf("%d-%d", m.MessageID(), time.Now().UnixNano())
+	}
+	sender := bus.SenderInfo{
+		Platform:    "mqtt",
+		PlatformID:  senderID,
+		CanonicalID: identity.BuildCanonicalID("mqtt", senderID),
+		Username:    senderID,
+		DisplayName: senderID,
+	}
+	metadata := map[string]string{
+		"platform": "mqtt",
+		"topic":    m.Topic(),
+	}
+	c.HandleMessage(context.Background(), bus.Peer{Kind: "direct", ID: chatID},
+		messageID, senderID, chatID, strings.TrimSpace(payload.Text), nil, metadata, sender)
+}
+
+// parsePayload reads a JSON inboundPayload, or plain text.
+func parsePayload(data []byte) inboundPayload {
+	var p inboundPayload
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "{") && json.Unmarshal(data, &p) == nil {
+		return p
+	}
+	return inboundPayload{Text: trimmed}
+}
+
+// matchTopic matches topic against an MQTT topic filter and returns the
+// levels matched by its wildcards; "#" captures the remaining levels.
+func matchTopic(filter, topic string) ([]string, bool) {
+	fl := strings.Split(filter, "/")
+	tl := strings.Split(topic, "/")
+	var captured []string
+	for i, f := range fl {
+		if f == "#" {
+			return append(captured, strings.Join(tl[i:], "/")), true
+		}
+		if i >= len(tl) {
+			return nil, false
+		}
+		switch f {
+		case "+":
+			captured = append(captured, tl[i])
+		case tl[i]:
+		default:
+			return nil, false
+		}
+	}
+	return captured, len(fl) == len(tl)
+}
+
+// allowedResponseTopic reports whether a device may have replies sent to
+// topic: it must stay under the configured response topic, up to its
+// {chat_id} placeholder, so a publisher cannot point replies at arbitrary
+// topics such as retained command topics.
+func (c *MQTTChannel) allowedResponseTopic(topic string) bool {
+	if strings.ContainsAny(topic, "+#") {
+		return false
+	}
+	prefix, _, templated := strings.Cut(c.config.ResponseTopic, "{chat_id}")
+	if !templated {
+		return topic == prefix || strings.HasPrefix(topic, prefix+"/")
+	}
+	return len(topic) > len(prefix) && strings.HasPrefix(topic, prefix)
+}
+
+// responseTopic returns where replies for chatID are published.
+func (c *MQTTChannel) responseTopic(chatID string) string {
+	c.responseMu.Lock()
+	topic, ok := c.responseTopics[chatID]
+	c.responseMu.Unlock()
+	if ok {
+		return topic
+	}
+	return strings.ReplaceAll(c.config.ResponseTopic, "{chat_id}", chatID)
+}
+
+// Send publishes a reply on the chat's response topic.
+func (c *MQTTChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	if msg.ChatID == "" {
+		return fmt.Errorf("chat ID is empty: %w", channels.ErrSendFailed)
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+	if c.config.PayloadFormat == "text" {
+		return c.publish(msg.ChatID, []byte(msg.Content))
+	}
+	return c.publishJSON(msg.ChatID, outboundPayload{
+		ChatID:  msg.ChatID,
+		Text:    msg.Content,
+		ReplyTo: msg.ReplyToMessageID,
+	})
+}
+
+// SendMedia publishes the media references of msg; devices fetch the files
+// through whatever path the deployment exposes for them.
+func (c *MQTTChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	if msg.ChatID == "" {
+		return fmt.Errorf("chat ID is empty: %w", channels.ErrSendFailed)
+	}
+	entries := make([]mediaEntry, 0, len(msg.Parts))
+	for _, p := range msg.Parts {
+		entries = append(entries, mediaEntry{
+			Type:        p.Type,
+			Ref:         p.Ref,
+			Caption:     p.Caption,
+			Filename:    p.Filename,
+			ContentType: p.ContentType,
+		})
+	}
+	return c.publishJSON(msg.ChatID, outboundPayload{ChatID: msg.ChatID, Media: entries})
+}
+
+func (c *MQTTChannel) publishJSON(chatID string, p outboundPayload) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("encode mqtt payload: %v: %w", err, channels.ErrSendFailed)
+	}
+	return c.publish(chatID, data)
+}
+
+func (c *MQTTChannel) publish(chatID string, payload []byte) error {
+	topic := c.responseTopic(chatID)
+	token := c.client.Publish(topic, c.qos, false, payload)
+	if !token.WaitTimeout(publishTimeout) {
+		return fmt.Errorf("mqtt publish to %s timed out: %w", topic, channels.ErrTemporary)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("mqtt publish to %s: %v: %w", topic, err, channels.ErrTemporary)
+	}
+	return nil
+}
@@ -0,0 +1,444 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// testBroker is an embedded MQTT 3.1.1 broker with just enough of the
+// protocol for the channel and a test client: QoS 0-2 publishes, retained
+// messages, subscriptions with wildcards and last will.
+type testBroker struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	subs     map[*brokerConn][]string
+	retained map[string][]byte
+	wills    map[string]will // client ID -> will
+}
+
+type will struct {
+	Topic   string
+	Payload string
+	Retain  bool
+}
+
+type brokerConn struct {
+	conn net.Conn
+	wmu  sync.Mutex
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &testBroker{
+		ln:       ln,
+		subs:     map[*brokerConn][]string{},
+		retained: map[string][]byte{},
+		wills:    map[string]will{},
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(&brokerConn{conn: conn})
+		}
+	}()
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (c *brokerConn) write(header byte, body []byte) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	pkt := []byte{header}
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		pkt = append(pkt, d)
+		if n == 0 {
+			break
+		}
+	}
+	c.conn.Write(append(pkt, body...))
+}
+
+func readString(buf []byte) (string, []byte) {
+	n := binary.BigEndian.Uint16(buf)
+	return string(buf[2 : 2+n]), buf[2+n:]
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+func (b *testBroker) publish(topic string, payload []byte, retain bool) {
+	b.mu.Lock()
+	if retain {
+		b.retained[topic] = payload
+	}
+	var targets []*brokerConn
+	for c, filters := range b.subs {
+		for _, f := range filters {
+			if _, ok := matchTopic(f, topic); ok {
+				targets = append(targets, c)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+	for _, c := range targets {
+		c.write(0x30, append(appendString(nil, topic), payload...))
+	}
+}
+
+func (b *testBroker) serve(c *brokerConn) {
+	defer c.conn.Close()
+	r := bufio.NewReader(c.conn)
+	var clientID string
+	clean := false
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, c)
+		w, hasWill := b.wills[clientID]
+		b.mu.Unlock()
+		if hasWill && !clean {
+			b.publish(w.Topic, []byte(w.Payload), w.Retain)
+		}
+	}()
+
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		length, mult := 0, 1
+		for {
+			d, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			length += int(d&0x7f) * mult
+			mult *= 128
+			if d&0x80 == 0 {
+				break
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			_, rest := readString(body) // protocol name
+			flags := rest[1]
+			rest = rest[4:]
+			clientID, rest = readString(rest)
+			if flags&0x04 != 0 {
+				var topic, payload string
+				topic, rest = readString(rest)
+				payload, _ = readString(rest)
+				b.mu.Lock()
+				b.wills[clientID] = will{Topic: topic, Payload: payload, Retain: flags&0x20 != 0}
+				b.mu.Unlock()
+			}
+			c.write(0x20, []byte{0, 0})
+		case 3: // PUBLISH
+			qos := (header >> 1) & 3
+			topic, rest := readString(body)
+			if qos > 0 {
+				id := rest[:2]
+				rest = rest[2:]
+				if qos == 1 {
+					c.write(0x40, id)
+				} else {
+					c.write(0x50, id)
+				}
+			}
+			b.publish(topic, rest, header&1 != 0)
+		case 6: // PUBREL
+			c.write(0x70, body[:2])
+		case 8: // SUBSCRIBE
+			id, rest := body[:2], body[2:]
+			ack := append([]byte(nil), id...)
+			var filters []string
+			for len(rest) > 0 {
+				var f string
+				f, rest = readString(rest)
+				rest = rest[1:]
+				filters = append(filters, f)
+				ack = append(ack, 0)
+			}
+			b.mu.Lock()
+			b.subs[c] = append(b.subs[c], filters...)
+			var retained [][2][]byte
+			for topic, payload := range b.retained {
+				for _, f := range filters {
+					if _, ok := matchTopic(f, topic); ok {
+						retained = append(retained, [2][]byte{[]byte(topic), payload})
+					}
+				}
+			}
+			b.mu.Unlock()
+			c.write(0x90, ack)
+			for _, m := range retained {
+				c.write(0x31, append(appendString(nil, string(m[0])), m[1]...))
+			}
+		case 12: // PINGREQ
+			c.write(0xd0, nil)
+		case 14: // DISCONNECT
+			clean = true
+			return
+		}
+	}
+}
+
+// subscriber connects a device-side client collecting messages by topic.
+func subscriber(t *testing.T, broker string, filters ...string) chan paho.Message {
+	t.Helper()
+	got := make(chan paho.Message, 16)
+	client := paho.NewClient(paho.NewClientOptions().AddBroker(broker).SetClientID("device"))
+	if tok := client.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("device connect: %v", tok.Error())
+	}
+	t.Cleanup(func() { client.Disconnect(0) })
+	for _, f := range filters {
+		tok := client.Subscribe(f, 1, func(_ paho.Client, m paho.Message) { got <- m })
+		if !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+			t.Fatalf("device subscribe: %v", tok.Error())
+		}
+	}
+	return got
+}
+
+func publishFrom(t *testing.T, broker, topic, payload string) {
+	t.Helper()
+	client := paho.NewClient(paho.NewClientOptions().AddBroker(broker).SetClientID("sensor"))
+	if tok := client.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("sensor connect: %v", tok.Error())
+	}
+	defer client.Disconnect(0)
+	if tok := client.Publish(topic, 1, false, payload); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("sensor publish: %v", tok.Error())
+	}
+}
+
+func nextMessage(t *testing.T, ch chan paho.Message) paho.Message {
+	t.Helper()
+	select {
+	case m := <-ch:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no MQTT message")
+		return nil
+	}
+}
+
+func nextInbound(t *testing.T, mb *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func TestMQTTChannel_RoundTrip(t *testing.T) {
+	broker := newTestBroker(t)
+	mb := bus.NewMessageBus()
+	ch, err := NewMQTTChannel(config.MQTTConfig{
+		Broker: broker.url(),
+		Topics: []config.MQTTTopicConfig{
+			{Topic: "home/+/ask"},
+			{Topic: "alerts/#", ChatID: "alerts"},
+		},
+		ResponseTopic: "home/{chat_id}/reply",
+		QoS:           1,
+		AllowFrom:     config.FlexibleStringSlice{"kitchen", "sensor-7", "alerts"},
+	}, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stopped := false
+	defer func() {
+		if !stopped {
+			ch.Stop(context.Background())
+		}
+	}()
+
+	// The channel publishes its status after subscribing; subscribe once it
+	// is retained, so it arrives as retained.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		broker.mu.Lock()
+		_, ok := broker.retained["picoclaw/status"]
+		broker.mu.Unlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("status was not retained")
+		}
+	}
+	status := subscriber(t, broker.url(), "picoclaw/status")
+	if m := nextMessage(t, status); string(m.Payload()) != "online" || !m.Retained() {
+		t.Errorf("status = %q (retained %v), want retained online", m.Payload(), m.Retained())
+	}
+	broker.mu.Lock()
+	w := broker.wills["picoclaw"]
+	broker.mu.Unlock()
+	if w != (will{Topic: "picoclaw/status", Payload: "offline", Retain: true}) {
+		t.Errorf("last will = %+v", w)
+	}
+
+	replies := subscriber(t, broker.url(), "home/+/reply", "home/+/rx")
+
+	publishFrom(t, broker.url(), "home/attic/ask", "not allowed")
+	publishFrom(t, broker.url(), "home/kitchen/ask", "turn on the light")
+	in := nextInbound(t, mb)
+	if in.ChatID != "kitchen" || in.Content != "turn on the light" || in.SenderID != "mqtt:kitchen" {
+		t.Errorf("inbound = chat %q, content %q, sender %q", in.ChatID, in.Content, in.SenderID)
+	}
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: in.ChatID, Content: "Light is on."}); err != nil {
+		t.Fatal(err)
+	}
+	m := nextMessage(t, replies)
+	var out outboundPayload
+	if err := json.Unmarshal(m.Payload(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if m.Topic() != "home/kitchen/reply" || out.ChatID != "kitchen" || out.Text != "Light is on." {
+		t.Errorf("reply on %s: %+v", m.Topic(), out)
+	}
+
+	publishFrom(t, broker.url(), "home/garage/ask",
+		`{"text":"door state?","client_id":"sensor-7","response_topic":"home/sensor-7/rx"}`)
+	in = nextInbound(t, mb)
+	if in.ChatID != "sensor-7" || in.Content != "door state?" {
+		t.Errorf("inbound = chat %q, content %q", in.ChatID, in.Content)
+	}
+	err = ch.SendMedia(context.Background(), bus.OutboundMediaMessage{
+		ChatID: in.ChatID,
+		Parts:  []bus.MediaPart{{Type: "image", Ref: "media://abc", Filename: "door.jpg"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = nextMessage(t, replies)
+	out = outboundPayload{}
+	if err := json.Unmarshal(m.Payload(), &out); err != nil {
+		t.Fatal(err)
+	}
+	wantMedia := []mediaEntry{{Type: "image", Ref: "media://abc", Filename: "door.jpg"}}
+	if m.Topic() != "home/sensor-7/rx" || !reflect.DeepEqual(out.Media, wantMedia) {
+		t.Errorf("media on %s: %+v", m.Topic(), out)
+	}
+
+	// A response topic outside the configured one is ignored.
+	publishFrom(t, broker.url(), "home/garage/ask",
+		`{"text":"open it","client_id":"sensor-7","response_topic":"devices/garage/cmd"}`)
+	nextInbound(t, mb)
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "sensor-7", Content: "No."}); err != nil {
+		t.Fatal(err)
+	}
+	if m = nextMessage(t, replies); m.Topic() != "home/sensor-7/rx" {
+		t.Errorf("reply on %s, want the earlier allowed topic", m.Topic())
+	}
+
+	publishFrom(t, broker.url(), "alerts/smoke/hall", "smoke detected")
+	if in = nextInbound(t, mb); in.ChatID != "alerts" {
+		t.Errorf("alert chat = %q, want the topic's fixed chat", in.ChatID)
+	}
+
+	ch.Stop(context.Background())
+	stopped = true
+	if m := nextMessage(t, status); string(m.Payload()) != "offline" {
+		t.Errorf("status after stop = %q, want offline", m.Payload())
+	}
+}
+
+func TestAllowedResponseTopic(t *testing.T) {
+	ch := &MQTTChannel{config: config.MQTTConfig{ResponseTopic: "picoclaw/out/{chat_id}"}}
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{"picoclaw/out/kitchen", true},
+		{"picoclaw/out/kitchen/rx", true},
+		{"picoclaw/out/", false},
+		{"picoclaw/outside", false},
+		{"home/garage/door/set", false},
+		{"picoclaw/out/+", false},
+		{"picoclaw/out/#", false},
+	}
+	for _, tt := range tests {
+		if got := ch.allowedResponseTopic(tt.topic); got != tt.want {
+			t.Errorf("allowedResponseTopic(%q) = %v, want %v", tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          []string
+		ok            bool
+	}{
+		{"home/+/ask", "home/kitchen/ask", []string{"kitchen"}, true},
+		{"home/+/ask", "home/kitchen/tell", nil, false},
+		{"home/+/ask", "home/kitchen", nil, false},
+		{"dev/#", "dev/a/b", []string{"a/b"}, true},
+		{"+/+", "a/b", []string{"a", "b"}, true},
+		{"exact", "exact", nil, true},
+	}
+	for _, tt := range tests {
+		got, ok := matchTopic(tt.filter, tt.topic)
+		if ok != tt.ok || ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("matchTopic(%q, %q) = %v, %v; want %v, %v", tt.filter, tt.topic, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNewMQTTChannel_Validation(t *testing.T) {
+	mb := bus.NewMessageBus()
+	for _, cfg := range []config.MQTTConfig{
+		{},
+		{Broker: "tcp://127.0.0.1:1883", QoS: 3},
+		{Broker: "tcp://127.0.0.1:1883", PayloadFormat: "xml"},
+	} {
+		if _, err := NewMQTTChannel(cfg, mb); err == nil {
+			t.Errorf("NewMQTTChannel(%+v) succeeded", cfg)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package channels
 
 import (
+	"strings"
 	"sync"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
@@ -11,9 +12,14 @@ import (
 // Each channel subpackage registers one or more factories via init().
 type ChannelFactory func(cfg *config.Config, bus *bus.MessageBus) (Channel, error)
 
+// InstanceFactory creates one named instance of a channel type that may be
+// configured several times. instance is the name from the config.
+type InstanceFactory func(cfg *config.Config, bus *bus.MessageBus, instance string) (Channel, error)
+
 var (
-	factoriesMu sync.RWMutex
-	factories   = map[string]ChannelFactory{}
+	factoriesMu       sync.RWMutex
+	factories         = map[string]ChannelFactory{}
+	instanceFactories = map[string]InstanceFactory{}
 )
 
 // RegisterFactory registers a named channel factory. Called from subpackage init() functions.
@@ -30,3 +36,36 @@ func getFactory(name string) (ChannelFactory, bool) {
 	f, ok := factories[name]
 	return f, ok
 }
+
+// RegisterInstanceFactory registers a factory for a multi-instance channel type.
+func RegisterInstanceFactory(name string, f InstanceFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	instanceFactories[name] = f
+}
+
+// getInstanceFactory looks up a multi-instance channel factory by type name.
+func getInstanceFactory(name string) (InstanceFactory, bool) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	f, ok := instanceFactories[name]
+	return f, ok
+}
+
+// InstanceName returns the channel name of a multi-instance channel:
+// kind itself for the unnamed instance, "kind_instance" otherwise.
+func InstanceName(kind, instance string) string {
+	if instance == "" {
+		return kind
+	}
+	return kind + "_" + instance
+}
+
+// ChannelKind returns the channel type behind the name of a channel that
+// runs as a named account, undoing InstanceName.
+func ChannelKind(name, accountID string) string {
+	if accountID == "" {
+		return name
+	}
+	return strings.TrimSuffix(name, "_"+accountID)
+}
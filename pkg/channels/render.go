@@ -0,0 +1,394 @@
+package channels
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	mdhtml "github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// TextFormat identifies the markup dialect a channel expects in outbound text.
+// Agents always produce Markdown; channels that declare a TextFormat get it
+// rendered into their own dialect and split by rendered length.
+type TextFormat string
+
+const (
+	// FormatNone leaves content untouched; the channel handles formatting itself.
+	FormatNone TextFormat = ""
+	// FormatPlain strips all markup (IRC, SMS-like transports).
+	FormatPlain TextFormat = "plain"
+	// FormatHTML is full HTML as produced by a CommonMark renderer (Matrix).
+	FormatHTML TextFormat = "html"
+	// FormatTelegramHTML is the HTML subset accepted by Telegram's parse_mode=HTML.
+	FormatTelegramHTML TextFormat = "telegram_html"
+	// FormatTelegramMarkdownV2 is Telegram's MarkdownV2 with its escaping rules.
+	FormatTelegramMarkdownV2 TextFormat = "telegram_markdown_v2"
+	// FormatSlack is Slack mrkdwn.
+	FormatSlack TextFormat = "slack"
+	// FormatDiscord is Discord's Markdown flavour.
+	FormatDiscord TextFormat = "discord"
+)
+
+// Document is Markdown parsed once so it can be rendered into several formats.
+type Document struct {
+	root ast.Node
+}
+
+// ParseMarkdown parses Markdown with the same extensions the channels have
+// always used (tables, fenced code, strikethrough, autolinks).
+func ParseMarkdown(md string) *Document {
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs)
+	return &Document{root: p.Parse([]byte(md))}
+}
+
+// RenderMarkdown parses md and renders it into format. FormatNone returns md as-is.
+func RenderMarkdown(md string, format TextFormat) string {
+	if format == FormatNone {
+		return md
+	}
+	return ParseMarkdown(md).Render(format)
+}
+
+// Render renders the document into format. Unknown formats render as plain text.
+func (d *Document) Render(format TextFormat) string {
+	if format == FormatHTML {
+		renderer := mdhtml.NewRenderer(mdhtml.RendererOptions{Flags: mdhtml.CommonFlags})
+		return strings.TrimSpace(string(markdown.Render(d.root, renderer)))
+	}
+	style, ok := textStyles[format]
+	if !ok {
+		style = textStyles[FormatPlain]
+	}
+	r := &textRenderer{style: style}
+	return strings.TrimSpace(r.blocks(d.root, 0))
+}
+
+// textStyle describes how a line-oriented markup dialect spells each construct.
+// Text passed to the wrapping functions is already rendered; code and URLs are raw.
+type textStyle struct {
+	escape    func(string) string
+	strong    func(string) string
+	emph      func(string) string
+	del       func(string) string
+	code      func(code string) string
+	codeBlock func(lang, code string) string
+	link      func(text, url string) string
+	heading   func(level int, text string) string
+	quote     func(string) string
+	bullet    string
+	ordered   func(n int) string
+	rule      string
+}
+
+func verbatim(s string) string { return s }
+
+func wrap(marker string) func(string) string {
+	return func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return marker + s + marker
+	}
+}
+
+func prefixLines(prefix string) func(string) string {
+	return func(s string) string {
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			lines[i] = prefix + line
+		}
+		return strings.Join(lines, "\n")
+	}
+}
+
+func plainLink(text, url string) string {
+	if text == "" || text == url {
+		return url
+	}
+	return text + " (" + url + ")"
+}
+
+func orderedMarker(n int) string { return fmt.Sprintf("%d. ", n) }
+
+func fencedBlock(escapeCode func(string) string) func(lang, code string) string {
+	return func(lang, code string) string {
+		return "```" + lang + "\n" + escapeCode(code) + "\n```"
+	}
+}
+
+var markdownV2Escaper = newEscaper("_*[]()~`>#+-=|{}.!\\")
+
+func newEscaper(special string) *strings.Replacer {
+	pairs := make([]string, 0, len(special)*2)
+	for _, r := range special {
+		pairs = append(pairs, string(r), "\\"+string(r))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+var (
+	markdownV2CodeEscaper = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+	markdownV2URLEscaper  = strings.NewReplacer("\\", "\\\\", ")", "\\)")
+	discordEscaper        = newEscaper("\\*_~`|")
+	slackEscaper          = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+var textStyles = map[TextFormat]textStyle{
+	FormatPlain: {
+		escape:    verbatim,
+		strong:    verbatim,
+		emph:      verbatim,
+		del:       verbatim,
+		code:      verbatim,
+		codeBlock: func(_, code string) string { return code },
+		link:      plainLink,
+		heading:   func(_ int, text string) string { return text },
+		quote:     prefixLines("> "),
+		bullet:    "- ",
+		ordered:   orderedMarker,
+		rule:      "———",
+	},
+	FormatTelegramHTML: {
+		escape: html.EscapeString,
+		strong: func(s string) string { return "<b>" + s + "</b>" },
+		emph:   func(s string) string { return "<i>" + s + "</i>" },
+		del:    func(s string) string { return "<s>" + s + "</s>" },
+		code:   func(c string) string { return "<code>" + html.EscapeString(c) + "</code>" },
+		codeBlock: func(lang, code string) string {
+			if lang != "" {
+				return `<pre><code class="language-` + html.EscapeString(lang) + `">` + html.EscapeString(code) + "</code></pre>"
+			}
+			return "<pre><code>" + html.EscapeString(code) + "</code></pre>"
+		},
+		link: func(text, url string) string {
+			if text == "" {
+				text = html.EscapeString(url)
+			}
+			return `<a href="` + html.EscapeString(url) + `">` + text + "</a>"
+		},
+		heading: func(_ int, text string) string { return "<b>" + text + "</b>" },
+		quote:   func(s string) string { return "<blockquote>" + s + "</blockquote>" },
+		bullet:  "• ",
+		ordered: orderedMarker,
+		rule:    "———",
+	},
+	FormatTelegramMarkdownV2: {
+		escape:    markdownV2Escaper.Replace,
+		strong:    wrap("*"),
+		emph:      wrap("_"),
+		del:       wrap("~"),
+		code:      func(c string) string { return "`" + markdownV2CodeEscaper.Replace(c) + "`" },
+		codeBlock: fencedBlock(markdownV2CodeEscaper.Replace),
+		link: func(text, url string) string {
+			if text == "" {
+				text = markdownV2Escaper.Replace(url)
+			}
+			return "[" + text + "](" + markdownV2URLEscaper.Replace(url) + ")"
+		},
+		heading: func(_ int, text string) string { return "*" + text + "*" },
+		quote:   prefixLines(">"),
+		bullet:  "• ",
+		ordered: func(n int) string { return fmt.Sprintf("%d\\. ", n) },
+		rule:    "———",
+	},
+	FormatSlack: {
+		escape:    slackEscaper.Replace,
+		strong:    wrap("*"),
+		emph:      wrap("_"),
+		del:       wrap("~"),
+		code:      func(c string) string { return "`" + slackEscaper.Replace(c) + "`" },
+		codeBlock: func(_, code string) string { return "```\n" + slackEscaper.Replace(code) + "\n```" },
+		link: func(text, url string) string {
+			if text == "" || text == slackEscaper.Replace(url) {
+				return "<" + url + ">"
+			}
+			return "<" + url + "|" + text + ">"
+		},
+		heading: func(_ int, text string) string { return "*" + text + "*" },
+		quote:   prefixLines("> "),
+		bullet:  "• ",
+		ordered: orderedMarker,
+		rule:    "———",
+	},
+	FormatDiscord: {
+		escape:    discordEscaper.Replace,
+		strong:    wrap("**"),
+		emph:      wrap("*"),
+		del:       wrap("~~"),
+		code:      func(c string) string { return "`" + c + "`" },
+		codeBlock: fencedBlock(verbatim),
+		link: func(text, url string) string {
+			if text == "" || text == discordEscaper.Replace(url) {
+				return url
+			}
+			return "[" + text + "](" + url + ")"
+		},
+		heading: func(level int, text string) string {
+			if level > 3 {
+				return "**" + text + "**"
+			}
+			return strings.Repeat("#", level) + " " + text
+		},
+		quote:   prefixLines("> "),
+		bullet:  "- ",
+		ordered: orderedMarker,
+		rule:    "———",
+	},
+}
+
+type textRenderer struct {
+	style textStyle
+}
+
+// blocks renders the block children of parent separated by blank lines.
+func (r *textRenderer) blocks(parent ast.Node, depth int) string {
+	var parts []string
+	for _, child := range parent.GetChildren() {
+		if s := r.block(child, depth); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (r *textRenderer) block(n ast.Node, depth int) string {
+	switch n := n.(type) {
+	case *ast.Paragraph:
+		return r.inlines(n)
+	case *ast.Heading:
+		return r.style.heading(n.Level, r.inlines(n))
+	case *ast.CodeBlock:
+		return r.style.codeBlock(string(n.Info), strings.TrimSuffix(string(n.Literal), "\n"))
+	case *ast.BlockQuote:
+		return r.style.quote(r.blocks(n, depth))
+	case *ast.List:
+		return r.list(n, depth)
+	case *ast.HorizontalRule:
+		return r.style.rule
+	case *ast.Table:
+		return r.style.codeBlock("", tableText(n))
+	case *ast.HTMLBlock:
+		return r.style.escape(strings.TrimSpace(string(n.Literal)))
+	}
+	if leaf := n.AsLeaf(); leaf != nil {
+		return r.style.escape(string(leaf.Literal))
+	}
+	return r.blocks(n, depth)
+}
+
+// list renders one list level; nested lists are indented two spaces per level.
+func (r *textRenderer) list(l *ast.List, depth int) string {
+	indent := strings.Repeat("  ", depth)
+	num := max(l.Start, 1)
+	var items []string
+	for _, child := range l.Children {
+		item, ok := child.(*ast.ListItem)
+		if !ok {
+			continue
+		}
+		marker := r.style.bullet
+		if l.ListFlags&ast.ListTypeOrdered != 0 {
+			marker = r.style.ordered(num)
+			num++
+		}
+
+		var lines []string
+		for _, c := range item.Children {
+			if nested, ok := c.(*ast.List); ok {
+				lines = append(lines, r.list(nested, depth+1))
+				continue
+			}
+			body := r.block(c, depth+1)
+			if body == "" {
+				continue
+			}
+			for _, line := range strings.Split(body, "\n") {
+				if len(lines) == 0 {
+					lines = append(lines, indent+marker+line)
+				} else {
+					lines = append(lines, indent+"  "+line)
+				}
+			}
+		}
+		if len(lines) == 0 {
+			lines = append(lines, indent+strings.TrimSpace(marker))
+		}
+		items = append(items, strings.Join(lines, "\n"))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (r *textRenderer) inlines(n ast.Node) string {
+	var b strings.Builder
+	for _, child := range n.GetChildren() {
+		b.WriteString(r.inline(child))
+	}
+	return b.String()
+}
+
+func (r *textRenderer) inline(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		return r.style.escape(string(n.Literal))
+	case *ast.Strong:
+		return r.style.strong(r.inlines(n))
+	case *ast.Emph:
+		return r.style.emph(r.inlines(n))
+	case *ast.Del:
+		return r.style.del(r.inlines(n))
+	case *ast.Code:
+		return r.style.code(string(n.Literal))
+	case *ast.Link:
+		return r.style.link(r.inlines(n), string(n.Destination))
+	case *ast.Image:
+		return r.style.link(r.inlines(n), string(n.Destination))
+	case *ast.Hardbreak, *ast.Softbreak:
+		return "\n"
+	case *ast.HTMLSpan:
+		return r.style.escape(string(n.Literal))
+	}
+	if leaf := n.AsLeaf(); leaf != nil {
+		return r.style.escape(string(leaf.Literal))
+	}
+	return r.inlines(n)
+}
+
+// tableText lays a table out as aligned plain-text columns, which every
+// channel can show faithfully inside a code block.
+func tableText(table *ast.Table) string {
+	plain := &textRenderer{style: textStyles[FormatPlain]}
+	var rows [][]string
+	var widths []int
+	ast.WalkFunc(table, func(node ast.Node, entering bool) ast.WalkStatus {
+		row, ok := node.(*ast.TableRow)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+		var cells []string
+		for i, c := range row.Children {
+			cell := strings.ReplaceAll(plain.inlines(c), "\n", " ")
+			cells = append(cells, cell)
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+		rows = append(rows, cells)
+		return ast.SkipChildren
+	})
+
+	lines := make([]string, 0, len(rows))
+	for _, cells := range rows {
+		for i, cell := range cells {
+			if i < len(cells)-1 {
+				cells[i] = cell + strings.Repeat(" ", widths[i]-len([]rune(cell)))
+			}
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, " | "), " "))
+	}
+	return strings.Join(lines, "\n")
+}
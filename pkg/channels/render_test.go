@@ -0,0 +1,131 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		format TextFormat
+		input  string
+		want   string
+	}{
+		{"none keeps source", FormatNone, "**a** & b", "**a** & b"},
+		{"plain strips markup", FormatPlain, "# Title\n\n**bold** _it_ `code` [site](https://x.io)", "Title\n\nbold it code site (https://x.io)"},
+		{"plain bare link", FormatPlain, "<https://x.io>", "https://x.io"},
+		{"telegram html escapes", FormatTelegramHTML, "**a** < b & `c<d>`", "<b>a</b> &lt; b &amp; <code>c&lt;d&gt;</code>"},
+		{"telegram html link", FormatTelegramHTML, "[x](https://a.io/?q=1&r=2)", `<a href="https://a.io/?q=1&amp;r=2">x</a>`},
+		{"telegram html code block", FormatTelegramHTML, "```go\nx := <-ch\n```", `<pre><code class="language-go">x := &lt;-ch</code></pre>`},
+		{"telegram html quote", FormatTelegramHTML, "> quoted", "<blockquote>quoted</blockquote>"},
+		{"markdownv2 escapes text", FormatTelegramMarkdownV2, "Version 1.2 (beta) - done!", `Version 1\.2 \(beta\) \- done\!`},
+		{"markdownv2 styles", FormatTelegramMarkdownV2, "**b** _i_ ~~s~~", "*b* _i_ ~s~"},
+		{"markdownv2 code escapes only backslash", FormatTelegramMarkdownV2, "`a.b\\c`", "`a.b\\\\c`"},
+		{"markdownv2 link", FormatTelegramMarkdownV2, "[a.b](https://x.io/(1))", `[a\.b](https://x.io/(1\))`},
+		{"markdownv2 ordered list", FormatTelegramMarkdownV2, "1. one\n2. two", "1\\. one\n2\\. two"},
+		{"slack styles", FormatSlack, "**b** _i_ ~~s~~ # not heading", "*b* _i_ ~s~ # not heading"},
+		{"slack heading and link", FormatSlack, "## Plan\n\nSee [docs](https://d.io) <now>", "*Plan*\n\nSee <https://d.io|docs> &lt;now&gt;"},
+		{"slack list", FormatSlack, "- a\n- b\n  - c", "• a\n• b\n  • c"},
+		{"discord keeps markdown", FormatDiscord, "## Plan\n\n**b** *i* ~~s~~", "## Plan\n\n**b** *i* ~~s~~"},
+		{"discord deep heading", FormatDiscord, "#### Small", "**Small**"},
+		{"discord escapes literals", FormatDiscord, `snake\_case`, `snake\_case`},
+		{"html", FormatHTML, "**b**", "<p><strong>b</strong></p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderMarkdown(tt.input, tt.format); got != tt.want {
+				t.Errorf("RenderMarkdown(%q, %q) =\n%q\nwant\n%q", tt.input, tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown_Table(t *testing.T) {
+	md := "| Name | Qty |\n|---|---|\n| apple | 3 |"
+	want := "```\nName  | Qty\napple | 3\n```"
+	if got := RenderMarkdown(md, FormatSlack); got != want {
+		t.Errorf("table =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDocument_RenderManyFormats(t *testing.T) {
+	doc := ParseMarkdown("**hi**")
+	if got := doc.Render(FormatSlack); got != "*hi*" {
+		t.Errorf("slack = %q", got)
+	}
+	if got := doc.Render(FormatTelegramHTML); got != "<b>hi</b>" {
+		t.Errorf("telegram = %q", got)
+	}
+	if got := doc.Render("unknown"); got != "hi" {
+		t.Errorf("unknown format should render plain, got %q", got)
+	}
+}
+
+func TestSplitMessageFormat(t *testing.T) {
+	t.Run("fits", func(t *testing.T) {
+		chunks := SplitMessageFormat("**short**", 100, FormatTelegramHTML)
+		if len(chunks) != 1 || chunks[0] != "**short**" {
+			t.Errorf("chunks = %q", chunks)
+		}
+	})
+
+	t.Run("none falls back to SplitMessage", func(t *testing.T) {
+		content := strings.Repeat("word ", 100)
+		got := SplitMessageFormat(content, 120, FormatNone)
+		want := SplitMessage(content, 120)
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("FormatNone should match SplitMessage")
+		}
+	})
+
+	t.Run("packs paragraphs by rendered length", func(t *testing.T) {
+		para := strings.TrimSpace(strings.Repeat("a & b ", 5)) // 29 runes, 45 rendered
+		content := strings.Join([]string{para, para, para}, "\n\n")
+		chunks := SplitMessageFormat(content, 100, FormatTelegramHTML)
+		if len(chunks) != 2 {
+			t.Fatalf("got %d chunks %q, want 2", len(chunks), chunks)
+		}
+		assertChunksFit(t, chunks, 100, FormatTelegramHTML)
+	})
+
+	t.Run("keeps inline markup intact", func(t *testing.T) {
+		content := strings.Repeat("plain words then [a link with text](https://example.com) and **bold words here** ", 6)
+		chunks := SplitMessageFormat(content, 120, FormatSlack)
+		assertChunksFit(t, chunks, 120, FormatSlack)
+		for _, c := range chunks {
+			if strings.Count(c, "**")%2 != 0 || strings.Count(c, "[") != strings.Count(c, "](") {
+				t.Errorf("chunk %q cuts through inline markup", c)
+			}
+		}
+	})
+
+	t.Run("refences code blocks", func(t *testing.T) {
+		content := "Intro\n\n```go\n" + strings.Repeat("fmt.Println(\"<x>\")\n", 20) + "```"
+		chunks := SplitMessageFormat(content, 200, FormatTelegramHTML)
+		assertChunksFit(t, chunks, 200, FormatTelegramHTML)
+		for _, c := range chunks[1:] {
+			if !strings.HasPrefix(c, "```go\n") || !strings.HasSuffix(c, "\n```") {
+				t.Errorf("code chunk not fenced: %q", c)
+			}
+		}
+	})
+
+	t.Run("hard cuts unbreakable text", func(t *testing.T) {
+		chunks := SplitMessageFormat(strings.Repeat("x", 250), 100, FormatPlain)
+		if len(chunks) != 3 {
+			t.Fatalf("got %d chunks, want 3", len(chunks))
+		}
+		assertChunksFit(t, chunks, 100, FormatPlain)
+	})
+}
+
+func assertChunksFit(t *testing.T, chunks []string, maxLen int, format TextFormat) {
+	t.Helper()
+	for _, c := range chunks {
+		if n := len([]rune(RenderMarkdown(c, format))); n > maxLen {
+			t.Errorf("chunk renders to %d runes (limit %d): %q", n, maxLen, c)
+		}
+	}
+}
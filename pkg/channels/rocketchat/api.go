@@ -0,0 +1,280 @@
+package rocketchat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/channels"
+)
+
+// apiClient is a small client for the Rocket.Chat REST API v1.
+type apiClient struct {
+	base   string // server URL without trailing slash
+	userID string
+	token  string
+	client *http.Client
+}
+
+func newAPIClient(base string) *apiClient {
+	return &apiClient{
+		base:   strings.TrimRight(base, "/"),
+		client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// errNotFound marks a 404, which tells apart servers without an endpoint.
+var errNotFound = errors.New("not found")
+
+type user struct {
+	ID       string `json:"_id"`
+	Username string `json:"username"`
+	Name     string `json:"name,omitempty"`
+}
+
+type message struct {
+	ID          string       `json:"_id"`
+	RoomID      string       `json:"rid"`
+	Text        string       `json:"msg"`
+	ThreadID    string       `json:"tmid,omitempty"`
+	Type        string       `json:"t,omitempty"` // system message type
+	User        user         `json:"u"`
+	Mentions    []user       `json:"mentions,omitempty"`
+	Attachments []attachment `json:"attachments,omitempty"`
+	EditedAt    any          `json:"editedAt,omitempty"`
+	Bot         any          `json:"bot,omitempty"`
+}
+
+type attachment struct {
+	Title     string `json:"title"`
+	TitleLink string `json:"title_link"`
+	Type      string `json:"type"`
+	ImageType string `json:"image_type,omitempty"`
+	AudioType string `json:"audio_type,omitempty"`
+	VideoType string `json:"video_type,omitempty"`
+}
+
+func (a attachment) contentType() string {
+	switch {
+	case a.ImageType != "":
+		return a.ImageType
+	case a.AudioType != "":
+		return a.AudioType
+	case a.VideoType != "":
+		return a.VideoType
+	}
+	return ""
+}
+
+func (a *apiClient) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, a.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if a.token != "" {
+		req.Header.Set("X-Auth-Token", a.token)
+		req.Header.Set("X-User-Id", a.userID)
+	}
+	return req, nil
+}
+
+// do sends a JSON request to /api/v1 and decodes the response into out,
+// if non-nil.
+func (a *apiClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := a.newRequest(ctx, method, "/api/v1"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return a.send(req, out)
+}
+
+func (a *apiClient) send(req *http.Request, out any) error {
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return channels.ClassifyNetError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := channels.ClassifySendError(resp.StatusCode,
+			fmt.Errorf("rocketchat %s: HTTP %d: %s", req.URL.Path, resp.StatusCode, strings.TrimSpace(string(data))))
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %w", errNotFound, err)
+		}
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// login exchanges a username and password for an auth token.
+func (a *apiClient) login(ctx context.Context, username, password string) error {
+	var result struct {
+		Data struct {
+			AuthToken string `json:"authToken"`
+			UserID    string `json:"userId"`
+		} `json:"data"`
+	}
+	err := a.do(ctx, http.MethodPost, "/login", map[string]string{"user": username, "password": password}, &result)
+	if err != nil {
+		return err
+	}
+	if result.Data.AuthToken == "" {
+		return fmt.Errorf("rocketchat login: no auth token returned")
+	}
+	a.userID, a.token = result.Data.UserID, result.Data.AuthToken
+	return nil
+}
+
+func (a *apiClient) me(ctx context.Context) (user, error) {
+	var u user
+	err := a.do(ctx, http.MethodGet, "/me", nil, &u)
+	return u, err
+}
+
+func (a *apiClient) sendMessage(ctx context.Context, roomID, threadID, text string) (message, error) {
+	msg := map[string]string{"rid": roomID, "msg": text}
+	if threadID != "" {
+		msg["tmid"] = threadID
+	}
+	var result struct {
+		Message message `json:"message"`
+	}
+	err := a.do(ctx, http.MethodPost, "/chat.sendMessage", map[string]any{"message": msg}, &result)
+	return result.Message, err
+}
+
+func (a *apiClient) updateMessage(ctx context.Context, roomID, messageID, text string) error {
+	return a.do(ctx, http.MethodPost, "/chat.update",
+		map[string]string{"roomId": roomID, "msgId": messageID, "text": text}, nil)
+}
+
+// download copies a file served by the server, e.g. an attachment's
+// title_link, into w.
+func (a *apiClient) download(ctx context.Context, link string, w io.Writer) error {
+	target := link
+	if !strings.Contains(link, "://") {
+		target = a.base + "/" + strings.TrimPrefix(link, "/")
+	} else if !strings.HasPrefix(link, a.base+"/") {
+		// Never send the auth headers to another host.
+		return fmt.Errorf("rocketchat download: %q is not on the server", link)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", a.token)
+	req.Header.Set("X-User-Id", a.userID)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rocketchat download %s: HTTP %d", link, resp.StatusCode)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// uploadFile posts a local file to a room with a caption. It uses
+// rooms.media and rooms.mediaConfirm, falling back to the rooms.upload
+// endpoint of servers older than 6.8.
+func (a *apiClient) uploadFile(ctx context.Context, roomID, threadID, localPath, filename, caption string) error {
+	if filename == "" {
+		filename = filepath.Base(localPath)
+	}
+	var result struct {
+		File struct {
+			ID string `json:"_id"`
+		} `json:"file"`
+	}
+	err := a.postMultipart(ctx, "/api/v1/rooms.media/"+roomID, localPath, filename, nil, &result)
+	if errors.Is(err, errNotFound) {
+		fields := map[string]string{"msg": caption}
+		if threadID != "" {
+			fields["tmid"] = threadID
+		}
+		return a.postMultipart(ctx, "/api/v1/rooms.upload/"+roomID, localPath, filename, fields, nil)
+	}
+	if err != nil {
+		return err
+	}
+	if result.File.ID == "" {
+		return fmt.Errorf("rocketchat upload %s: no file id returned", filename)
+	}
+	confirm := map[string]string{"msg": caption}
+	if threadID != "" {
+		confirm["tmid"] = threadID
+	}
+	return a.do(ctx, http.MethodPost, "/rooms.mediaConfirm/"+roomID+"/"+result.File.ID, confirm, nil)
+}
+
+func (a *apiClient) postMultipart(
+	ctx context.Context, path, localPath, filename string, fields map[string]string, out any,
+) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return err
+	}
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	req, err := a.newRequest(ctx, http.MethodPost, path, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return a.send(req, out)
+}
+
+// websocketURL returns the DDP endpoint of the server.
+func (a *apiClient) websocketURL() string {
+	u := a.base + "/websocket"
+	if rest, ok := strings.CutPrefix(u, "https://"); ok {
+		return "wss://" + rest
+	}
+	if rest, ok := strings.CutPrefix(u, "http://"); ok {
+		return "ws://" + rest
+	}
+	return u
+}
@@ -0,0 +1,13 @@
+package rocketchat
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func init() {
+	channels.RegisterFactory("rocketchat", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
+		return NewRocketChatChannel(cfg.Channels.RocketChat, b)
+	})
+}
@@ -0,0 +1,475 @@
+package rocketchat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	maxBackoff         = time.Minute
+	maxMessageLength   = 5000 // server default Message_MaxAllowedSize
+	defaultPlaceholder = "Thinking... 💭"
+	seenCapacity       = 1024
+)
+
+// RocketChatChannel connects to a Rocket.Chat server as a user: messages
+// arrive over the DDP websocket (stream-room-messages) and replies go
+// through the REST API.
+//
+// Chat IDs are the room ID, or "roomID/threadID" inside a thread.
+type RocketChatChannel struct {
+	*channels.BaseChannel
+	config config.RocketChatConfig
+	api    *apiClient
+
+	botID       string
+	botUsername string
+	mentionRe   *regexp.Regexp // @botUsername, case-insensitive
+
+	// The stream repeats a message whenever it changes (reactions, read
+	// receipts, thread counters), so recently handled IDs are remembered.
+	seenMu    sync.Mutex
+	seen      map[string]struct{}
+	seenOrder []string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRocketChatChannel creates a Rocket.Chat channel from its config.
+func NewRocketChatChannel(cfg config.RocketChatConfig, messageBus *bus.MessageBus) (*RocketChatChannel, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("rocketchat url is required")
+	}
+	if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+		return nil, fmt.Errorf("rocketchat url %q must start with http:// or https://", cfg.URL)
+	}
+	if (cfg.UserID == "" || cfg.Token == "") && (cfg.Username == "" || cfg.Password == "") {
+		return nil, fmt.Errorf("rocketchat needs user_id and token, or username and password")
+	}
+
+	base := channels.NewBaseChannel("rocketchat", cfg, messageBus, cfg.AllowFrom,
+		channels.WithMaxMessageLength(maxMessageLength),
+		channels.WithGroupTrigger(cfg.GroupTrigger),
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+
+	api := newAPIClient(cfg.URL)
+	api.userID, api.token = cfg.UserID, cfg.Token
+	return &RocketChatChannel{
+		BaseChannel: base,
+		config:      cfg,
+		api:         api,
+		seen:        make(map[string]struct{}),
+	}, nil
+}
+
+// Start authenticates and begins listening for messages.
+func (c *RocketChatChannel) Start(ctx context.Context) error {
+	logger.InfoC("rocketchat", "Starting Rocket.Chat channel")
+
+	authCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if c.config.Token == "" || c.config.UserID == "" {
+		if err := c.api.login(authCtx, c.config.Username, c.config.Password); err != nil {
+			return fmt.Errorf("rocketchat login: %w", err)
+		}
+	}
+	me, err := c.api.me(authCtx)
+	if err != nil {
+		return fmt.Errorf("rocketchat auth: %w", err)
+	}
+	c.botID, c.botUsername = me.ID, me.Username
+	if me.Username != "" {
+		c.mentionRe = regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(me.Username) + `\b`)
+	}
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.wg.Add(1)
+	go c.listen()
+
+	c.SetRunning(true)
+	logger.InfoCF("rocketchat", "Rocket.Chat channel started", map[string]any{
+		"url":      c.config.URL,
+		"username": c.botUsername,
+	})
+	return nil
+}
+
+// Stop closes the realtime connection.
+func (c *RocketChatChannel) Stop(ctx context.Context) error {
+	logger.InfoC("rocketchat", "Stopping Rocket.Chat channel")
+	c.SetRunning(false)
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+	logger.InfoC("rocketchat", "Rocket.Chat channel stopped")
+	return nil
+}
+
+// listen keeps the DDP connection up, reconnecting with backoff.
+func (c *RocketChatChannel) listen() {
+	defer c.wg.Done()
+	backoff := time.Second
+	for c.ctx.Err() == nil {
+		started := time.Now()
+		err := c.readStream()
+		if c.ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > maxBackoff {
+			backoff = time.Second
+		}
+		logger.WarnCF("rocketchat", "Realtime connection lost, reconnecting", map[string]any{
+			"error":   fmt.Sprint(err),
+			"backoff": backoff.String(),
+		})
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// ddpMessage is a frame of Meteor's DDP protocol.
+type ddpMessage struct {
+	Msg        string          `json:"msg"`
+	ID         string          `json:"id,omitempty"`
+	Collection string          `json:"collection,omitempty"`
+	Error      json.RawMessage `json:"error,omitempty"`
+	Fields     struct {
+		EventName string            `json:"eventName"`
+		Args      []json.RawMessage `json:"args"`
+	} `json:"fields"`
+}
+
+// readStream connects, logs in with the REST token and subscribes to the
+// messages of every room the user is in. It returns when the connection
+// fails or the channel stops.
+func (c *RocketChatChannel) readStream() error {
+	conn, _, err := websocket.DefaultDialer.DialContext(c.ctx, c.api.websocketURL(), nil)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(c.ctx, func() { conn.Close() })
+	defer func() {
+		stop()
+		conn.Close()
+	}()
+
+	// Frames are only ever written from this goroutine.
+	for _, frame := range []any{
+		map[string]any{"msg": "connect", "version": "1", "support": []string{"1"}},
+		map[string]any{"msg": "method", "method": "login", "id": "login",
+			"params": []any{map[string]string{"resume": c.api.token}}},
+		map[string]any{"msg": "sub", "id": "messages", "name": "stream-room-messages",
+			"params": []any{"__my_messages__", false}},
+	} {
+		if err := conn.WriteJSON(frame); err != nil {
+			return err
+		}
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var frame ddpMessage
+		if json.Unmarshal(data, &frame) != nil {
+			continue
+		}
+		switch frame.Msg {
+		case "ping":
+			if err := conn.WriteJSON(map[string]string{"msg": "pong"}); err != nil {
+				return err
+			}
+		case "result":
+			if frame.ID == "login" && len(frame.Error) > 0 {
+				return fmt.Errorf("rocketchat realtime login: %s", frame.Error)
+			}
+		case "nosub":
+			return fmt.Errorf("rocketchat subscription refused: %s", frame.Error)
+		case "changed":
+			if frame.Collection == "stream-room-messages" && len(frame.Fields.Args) > 0 {
+				c.handleStreamMessage(frame.Fields.Args)
+			}
+		}
+	}
+}
+
+func (c *RocketChatChannel) handleStreamMessage(args []json.RawMessage) {
+	var msg message
+	if err := json.Unmarshal(args[0], &msg); err != nil {
+		logger.DebugCF("rocketchat", "Ignoring undecodable message", map[string]any{"error": err.Error()})
+		return
+	}
+	var room struct {
+		RoomType string `json:"roomType"`
+	}
+	if len(args) > 1 {
+		_ = json.Unmarshal(args[1], &room)
+	}
+	if room.RoomType == "" && c.botID != "" && strings.Contains(msg.RoomID, c.botID) {
+		room.RoomType = "d" // direct room IDs join the two user IDs
+	}
+
+	if msg.ID == "" || msg.User.ID == "" || msg.User.ID == c.botID || msg.Type != "" ||
+		msg.EditedAt != nil || msg.Bot != nil {
+		return // own, system, edited and bot messages
+	}
+	if !c.markSeen(msg.ID) {
+		return
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "rocketchat",
+		PlatformID:  msg.User.ID,
+		CanonicalID: identity.BuildCanonicalID("rocketchat", msg.User.ID),
+		Username:    msg.User.Username,
+		DisplayName: msg.User.Name,
+	}
+	if !c.IsAllowedSender(sender) {
+		logger.DebugCF("rocketchat", "Message rejected by allowlist", map[string]any{"user_id": msg.User.ID})
+		return
+	}
+
+	chatID := msg.RoomID
+	if msg.ThreadID != "" {
+		chatID = msg.RoomID + "/" + msg.ThreadID
+	}
+
+	content := msg.Text
+	peer := bus.Peer{Kind: "direct", ID: msg.User.ID}
+	if room.RoomType != "d" {
+		peer = bus.Peer{Kind: "channel", ID: msg.RoomID}
+		if room.RoomType == "p" {
+			peer.Kind = "group"
+		}
+		respond, cleaned := c.ShouldRespondInGroup(c.isMentioned(msg), c.stripBotMention(content))
+		if !respond {
+			return
+		}
+		content = cleaned
+	}
+
+	scope := channels.BuildMediaScope("rocketchat", chatID, msg.ID)
+	var mediaRefs []string
+	for _, a := range msg.Attachments {
+		if a.TitleLink == "" {
+			continue // quotes and link previews
+		}
+		if ref := c.downloadAttachment(a, scope); ref != "" {
+			mediaRefs = append(mediaRefs, ref)
+		}
+		content = appendContent(content, fmt.Sprintf("[%s: %s]", mediaKind(a.contentType()), a.Title))
+	}
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	metadata := map[string]string{
+		"platform":  "rocketchat",
+		"room_id":   msg.RoomID,
+		"thread_id": msg.ThreadID,
+		"room_type": room.RoomType,
+	}
+
+	logger.DebugCF("rocketchat", "Received message", map[string]any{
+		"sender_id": msg.User.ID,
+		"chat_id":   chatID,
+		"preview":   utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(c.ctx, peer, msg.ID, msg.User.ID, chatID, content, mediaRefs, metadata, sender)
+}
+
+// markSeen records a message ID and reports whether it was new.
+func (c *RocketChatChannel) markSeen(id string) bool {
+	c.seenMu.Lock()
+	defer c.seenMu.Unlock()
+	if _, ok := c.seen[id]; ok {
+		return false
+	}
+	c.seen[id] = struct{}{}
+	c.seenOrder = append(c.seenOrder, id)
+	if len(c.seenOrder) > seenCapacity {
+		delete(c.seen, c.seenOrder[0])
+		c.seenOrder = c.seenOrder[1:]
+	}
+	return true
+}
+
+func (c *RocketChatChannel) isMentioned(msg message) bool {
+	for _, m := range msg.Mentions {
+		if m.ID == c.botID {
+			return true
+		}
+	}
+	return c.mentionRe != nil && c.mentionRe.MatchString(msg.Text)
+}
+
+func (c *RocketChatChannel) stripBotMention(text string) string {
+	if c.mentionRe == nil {
+		return text
+	}
+	return strings.TrimSpace(c.mentionRe.ReplaceAllString(text, ""))
+}
+
+// downloadAttachment stores an uploaded file in the MediaStore and returns
+// its media ref, or "" on failure.
+func (c *RocketChatChannel) downloadAttachment(a attachment, scope string) string {
+	store := c.GetMediaStore()
+	if store == nil {
+		return ""
+	}
+	dir := media.TempDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return ""
+	}
+	tmp, err := os.CreateTemp(dir, "rocketchat-*"+filepath.Ext(a.Title))
+	if err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, 2*time.Minute)
+	defer cancel()
+	err = c.api.download(ctx, a.TitleLink, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		var ref string
+		ref, err = store.Store(tmp.Name(), media.MediaMeta{
+			Filename:    a.Title,
+			ContentType: a.contentType(),
+			Source:      "rocketchat",
+		}, scope)
+		if err == nil {
+			return ref
+		}
+	}
+	_ = os.Remove(tmp.Name())
+	logger.WarnCF("rocketchat", "Failed to download attachment", map[string]any{
+		"link":  a.TitleLink,
+		"error": err.Error(),
+	})
+	return ""
+}
+
+func mediaKind(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return "image"
+	case strings.HasPrefix(contentType, "audio/"):
+		return "audio"
+	case strings.HasPrefix(contentType, "video/"):
+		return "video"
+	default:
+		return "file"
+	}
+}
+
+func appendContent(content, suffix string) string {
+	if content == "" {
+		return suffix
+	}
+	return content + "\n" + suffix
+}
+
+// parseChatID splits a chat ID into room ID and thread ID.
+func parseChatID(chatID string) (roomID, threadID string) {
+	roomID, threadID, _ = strings.Cut(chatID, "/")
+	return roomID, threadID
+}
+
+// Send posts a text message. A reply to a top-level message starts a
+// thread under it, as on Slack.
+func (c *RocketChatChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	roomID, threadID := parseChatID(msg.ChatID)
+	if roomID == "" {
+		return fmt.Errorf("invalid rocketchat chat ID %q: %w", msg.ChatID, channels.ErrSendFailed)
+	}
+	if threadID == "" {
+		threadID = msg.ReplyToMessageID
+	}
+	_, err := c.api.sendMessage(ctx, roomID, threadID, msg.Content)
+	return err
+}
+
+// SendMedia implements channels.MediaSender.
+func (c *RocketChatChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		return fmt.Errorf("no media store available: %w", channels.ErrSendFailed)
+	}
+	roomID, threadID := parseChatID(msg.ChatID)
+
+	for _, part := range msg.Parts {
+		localPath, meta, err := store.ResolveWithMeta(part.Ref)
+		if err != nil {
+			logger.ErrorCF("rocketchat", "Failed to resolve media ref", map[string]any{
+				"ref":   part.Ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+		filename := part.Filename
+		if filename == "" {
+			filename = meta.Filename
+		}
+		if err := c.api.uploadFile(ctx, roomID, threadID, localPath, filename, part.Caption); err != nil {
+			return fmt.Errorf("rocketchat upload: %w", err)
+		}
+	}
+	return nil
+}
+
+// EditMessage implements channels.MessageEditor.
+func (c *RocketChatChannel) EditMessage(ctx context.Context, chatID, messageID, content string) error {
+	roomID, _ := parseChatID(chatID)
+	return c.api.updateMessage(ctx, roomID, messageID, content)
+}
+
+// SendPlaceholder implements channels.PlaceholderCapable.
+func (c *RocketChatChannel) SendPlaceholder(ctx context.Context, chatID string) (string, error) {
+	if !c.config.Placeholder.Enabled {
+		return "", nil
+	}
+	text := c.config.Placeholder.Text
+	if text == "" {
+		text = defaultPlaceholder
+	}
+	roomID, threadID := parseChatID(chatID)
+	sent, err := c.api.sendMessage(ctx, roomID, threadID, text)
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
+}
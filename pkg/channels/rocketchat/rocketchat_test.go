@@ -0,0 +1,372 @@
+package rocketchat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+type apiCall struct {
+	Path string
+	Body map[string]any
+}
+
+// fakeServer implements the parts of the Rocket.Chat REST and DDP APIs the
+// channel uses.
+type fakeServer struct {
+	calls  chan apiCall
+	frames chan string // DDP frames pushed to the client
+	pongs  chan struct{}
+
+	mu        sync.Mutex
+	nextID    int
+	ddpLogin  string
+	legacyAPI bool // 404 on rooms.media, like servers before 6.8
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, string) {
+	t.Helper()
+	f := &fakeServer{calls: make(chan apiCall, 32), frames: make(chan string, 8), pongs: make(chan struct{}, 1)}
+	authed := func(r *http.Request) bool {
+		return r.Header.Get("X-Auth-Token") == "tok" && r.Header.Get("X-User-Id") == "bot1"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/login", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["user"] != "picobot" || body["password"] != "pw" {
+			http.Error(w, `{"status":"error"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","data":{"authToken":"tok","userId":"bot1"}}`)
+	})
+	mux.HandleFunc("GET /api/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		if !authed(r) {
+			http.Error(w, `{"success":false}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"_id":"bot1","username":"picobot","success":true}`)
+	})
+	mux.HandleFunc("GET /websocket", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go func() {
+			for {
+				var frame map[string]any
+				if conn.ReadJSON(&frame) != nil {
+					return
+				}
+				switch frame["msg"] {
+				case "method":
+					params, _ := frame["params"].([]any)
+					if len(params) == 1 {
+						f.mu.Lock()
+						f.ddpLogin, _ = params[0].(map[string]any)["resume"].(string)
+						f.mu.Unlock()
+					}
+				case "pong":
+					f.pongs <- struct{}{}
+				}
+			}
+		}()
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"msg":"connected","session":"s1"}`))
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case frame := <-f.frames:
+				if conn.WriteMessage(websocket.TextMessage, []byte(frame)) != nil {
+					return
+				}
+			}
+		}
+	})
+	mux.HandleFunc("POST /api/v1/chat.sendMessage", func(w http.ResponseWriter, r *http.Request) {
+		f.record(r)
+		f.mu.Lock()
+		f.nextID++
+		id := fmt.Sprintf("new%d", f.nextID)
+		f.mu.Unlock()
+		fmt.Fprintf(w, `{"message":{"_id":%q},"success":true}`, id)
+	})
+	mux.HandleFunc("POST /api/v1/chat.update", func(w http.ResponseWriter, r *http.Request) {
+		f.record(r)
+		fmt.Fprint(w, `{"success":true}`)
+	})
+	upload := func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		legacy := f.legacyAPI
+		f.mu.Unlock()
+		if legacy && r.PathValue("kind") == "rooms.media" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil || !authed(r) {
+			http.Error(w, "bad upload", http.StatusBadRequest)
+			return
+		}
+		file, header, _ := r.FormFile("file")
+		data, _ := io.ReadAll(file)
+		f.calls <- apiCall{Path: r.URL.Path, Body: map[string]any{
+			"filename": header.Filename, "data": string(data), "msg": r.FormValue("msg"), "tmid": r.FormValue("tmid"),
+		}}
+		fmt.Fprint(w, `{"file":{"_id":"file1"},"success":true}`)
+	}
+	mux.HandleFunc("POST /api/v1/{kind}/{rid}", upload)
+	mux.HandleFunc("POST /api/v1/rooms.mediaConfirm/{rid}/{fid}", func(w http.ResponseWriter, r *http.Request) {
+		f.record(r)
+		fmt.Fprint(w, `{"success":true}`)
+	})
+	mux.HandleFunc("GET /file-upload/{id}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if !authed(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, "png bytes")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func (f *fakeServer) record(r *http.Request) {
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	f.calls <- apiCall{Path: r.URL.Path, Body: body}
+}
+
+func (f *fakeServer) next(t *testing.T) apiCall {
+	t.Helper()
+	select {
+	case c := <-f.calls:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("no API call")
+		return apiCall{}
+	}
+}
+
+// push sends a stream-room-messages event for msg in a room of roomType.
+func (f *fakeServer) push(msg map[string]any, roomType string) {
+	args := []any{msg}
+	if roomType != "" {
+		args = append(args, map[string]any{"roomType": roomType, "roomParticipant": true})
+	}
+	frame, _ := json.Marshal(map[string]any{
+		"msg": "changed", "collection": "stream-room-messages", "id": "id",
+		"fields": map[string]any{"eventName": "__my_messages__", "args": args},
+	})
+	f.frames <- string(frame)
+}
+
+func startChannel(t *testing.T, url string, cfg config.RocketChatConfig) (*RocketChatChannel, *bus.MessageBus) {
+	t.Helper()
+	mb := bus.NewMessageBus()
+	cfg.URL = url
+	ch, err := NewRocketChatChannel(cfg, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.SetMediaStore(media.NewFileMediaStore())
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ch.Stop(context.Background()) })
+	return ch, mb
+}
+
+func nextInbound(t *testing.T, mb *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+var alice = map[string]any{"_id": "u1", "username": "alice", "name": "Alice"}
+
+func TestRocketChatChannel_InboundAndThreads(t *testing.T) {
+	f, url := newFakeServer(t)
+	ch, mb := startChannel(t, url, config.RocketChatConfig{
+		Username:     "picobot",
+		Password:     "pw",
+		GroupTrigger: config.GroupTriggerConfig{MentionOnly: true},
+	})
+
+	f.frames <- `{"msg":"ping"}`
+	select {
+	case <-f.pongs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pong")
+	}
+	f.mu.Lock()
+	login := f.ddpLogin
+	f.mu.Unlock()
+	if login != "tok" {
+		t.Errorf("DDP login resume token = %q", login)
+	}
+
+	f.push(map[string]any{"_id": "own", "rid": "bot1u1", "msg": "echo", "u": map[string]any{"_id": "bot1"}}, "d")
+	f.push(map[string]any{"_id": "sys", "rid": "general", "msg": "", "t": "uj", "u": alice}, "c")
+	f.push(map[string]any{"_id": "m0", "rid": "general", "msg": "no mention", "u": alice}, "c")
+	dmMsg := map[string]any{
+		"_id": "m1", "rid": "bot1u1", "msg": "look", "u": alice,
+		"attachments": []any{map[string]any{
+			"title": "cat.png", "title_link": "/file-upload/f1/cat.png", "type": "file", "image_type": "image/png",
+		}},
+	}
+	// The second event is the same message re-sent after a reaction, and
+	// has no room info; the room type is inferred from the DM room ID.
+	f.push(dmMsg, "")
+	f.push(dmMsg, "d")
+
+	dm := nextInbound(t, mb)
+	if dm.ChatID != "bot1u1" || dm.Peer.Kind != "direct" || dm.MessageID != "m1" || dm.SenderID != "rocketchat:u1" {
+		t.Errorf("direct message: chat %q, peer %+v, id %q, sender %q", dm.ChatID, dm.Peer, dm.MessageID, dm.SenderID)
+	}
+	if dm.Content != "look\n[image: cat.png]" || len(dm.Media) != 1 {
+		t.Fatalf("content %q, media %v", dm.Content, dm.Media)
+	}
+	path, meta, err := ch.GetMediaStore().ResolveWithMeta(dm.Media[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "png bytes" || meta.ContentType != "image/png" {
+		t.Errorf("file %q (%s)", data, meta.ContentType)
+	}
+
+	f.push(map[string]any{
+		"_id": "m2", "rid": "general", "tmid": "root1", "msg": "@PicoBot summarize", "u": alice,
+		"mentions": []any{map[string]any{"_id": "bot1", "username": "picobot"}},
+	}, "c")
+	threaded := nextInbound(t, mb)
+	if threaded.ChatID != "general/root1" || threaded.Peer.Kind != "channel" || threaded.Content != "summarize" {
+		t.Errorf("thread message: chat %q, peer %+v, content %q", threaded.ChatID, threaded.Peer, threaded.Content)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: threaded.ChatID, Content: "sure"}); err != nil {
+		t.Fatal(err)
+	}
+	c := f.next(t)
+	sent, _ := c.Body["message"].(map[string]any)
+	if c.Path != "/api/v1/chat.sendMessage" || sent["rid"] != "general" || sent["tmid"] != "root1" || sent["msg"] != "sure" {
+		t.Errorf("thread reply = %+v", c)
+	}
+	err = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "general", Content: "ok", ReplyToMessageID: "m9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = f.next(t)
+	if sent, _ := c.Body["message"].(map[string]any); sent["rid"] != "general" || sent["tmid"] != "m9" {
+		t.Errorf("reply starting a thread = %+v", c)
+	}
+}
+
+func TestRocketChatChannel_PlaceholderAndMedia(t *testing.T) {
+	f, url := newFakeServer(t)
+	ch, _ := startChannel(t, url, config.RocketChatConfig{
+		UserID:      "bot1",
+		Token:       "tok",
+		Placeholder: config.PlaceholderConfig{Enabled: true},
+	})
+
+	id, err := ch.SendPlaceholder(context.Background(), "general/root1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := f.next(t)
+	if sent, _ := c.Body["message"].(map[string]any); sent["msg"] != defaultPlaceholder || sent["tmid"] != "root1" || id == "" {
+		t.Errorf("placeholder = %+v, id %q", c, id)
+	}
+	if err := ch.EditMessage(context.Background(), "general/root1", id, "done"); err != nil {
+		t.Fatal(err)
+	}
+	if c := f.next(t); c.Path != "/api/v1/chat.update" || c.Body["roomId"] != "general" ||
+		c.Body["msgId"] != id || c.Body["text"] != "done" {
+		t.Errorf("edit = %+v", c)
+	}
+
+	file := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(file, []byte("%PDF"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := ch.GetMediaStore().Store(file, media.MediaMeta{Filename: "report.pdf"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendReport := func() {
+		t.Helper()
+		err := ch.SendMedia(context.Background(), bus.OutboundMediaMessage{
+			ChatID: "general/root1",
+			Parts:  []bus.MediaPart{{Type: "file", Ref: ref, Caption: "Your report"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sendReport()
+	if c := f.next(t); c.Path != "/api/v1/rooms.media/general" || c.Body["filename"] != "report.pdf" ||
+		c.Body["data"] != "%PDF" {
+		t.Errorf("upload = %+v", c)
+	}
+	if c := f.next(t); c.Path != "/api/v1/rooms.mediaConfirm/general/file1" || c.Body["msg"] != "Your report" ||
+		c.Body["tmid"] != "root1" {
+		t.Errorf("confirm = %+v", c)
+	}
+
+	f.mu.Lock()
+	f.legacyAPI = true
+	f.mu.Unlock()
+	sendReport()
+	if c := f.next(t); c.Path != "/api/v1/rooms.upload/general" || c.Body["msg"] != "Your report" ||
+		c.Body["tmid"] != "root1" {
+		t.Errorf("legacy upload = %+v", c)
+	}
+}
+
+func TestNewRocketChatChannel_Validation(t *testing.T) {
+	mb := bus.NewMessageBus()
+	if _, err := NewRocketChatChannel(config.RocketChatConfig{URL: "https://x"}, mb); err == nil {
+		t.Error("expected an error without credentials")
+	}
+	if _, err := NewRocketChatChannel(config.RocketChatConfig{URL: "x", UserID: "a", Token: "b"}, mb); err == nil {
+		t.Error("expected an error for a URL without scheme")
+	}
+	_, url := newFakeServer(t)
+	ch, err := NewRocketChatChannel(config.RocketChatConfig{URL: url, Username: "picobot", Password: "nope"}, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.Start(context.Background()); err == nil {
+		ch.Stop(context.Background())
+		t.Error("expected Start to fail with a bad password")
+	}
+}
+
+func TestAPIClient_DownloadStaysOnServer(t *testing.T) {
+	a := newAPIClient("https://chat.example.com")
+	if err := a.download(context.Background(), "https://evil.example.net/x", io.Discard); err == nil {
+		t.Error("expected an error for a link to another host")
+	}
+}
@@ -0,0 +1,324 @@
+package signal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rpcClient is a JSON-RPC 2.0 connection to a signal-cli daemon.
+type rpcClient interface {
+	// call invokes method and decodes its result into result, if non-nil.
+	call(ctx context.Context, method string, params, result any) error
+	// listen passes the params of every "receive" notification to handle
+	// until ctx is done or the connection fails.
+	listen(ctx context.Context, handle func(json.RawMessage)) error
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+	ID      string `json:"id"`
+}
+
+// rpcMessage is a response or a notification.
+type rpcMessage struct {
+	ID     string          `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("signal-cli error %d: %s", e.Code, e.Message)
+}
+
+var rpcIDs atomic.Int64
+
+func nextRPCID() string {
+	return fmt.Sprint(rpcIDs.Add(1))
+}
+
+// newRPCClient returns the client for a daemon URL: http(s):// for the
+// daemon's --http interface, tcp:// or unix:// for its JSON-RPC socket.
+func newRPCClient(rawURL string) (rpcClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("signal url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &httpRPC{base: strings.TrimRight(rawURL, "/"), client: &http.Client{Timeout: 60 * time.Second}}, nil
+	case "tcp":
+		return &socketRPC{network: "tcp", address: u.Host}, nil
+	case "unix":
+		return &socketRPC{network: "unix", address: u.Path}, nil
+	default:
+		return nil, fmt.Errorf("signal url %q: scheme must be http, https, tcp or unix", rawURL)
+	}
+}
+
+func decodeResult(msg rpcMessage, result any) error {
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result == nil || len(msg.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(msg.Result, result)
+}
+
+// httpRPC uses signal-cli's HTTP interface: POST /api/v1/rpc for calls and
+// the server-sent events of GET /api/v1/events for incoming messages.
+type httpRPC struct {
+	base   string
+	client *http.Client
+}
+
+func (h *httpRPC) call(ctx context.Context, method string, params, result any) error {
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: nextRPCID()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.base+"/api/v1/rpc", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("signal-cli %s: HTTP %d: %s", method, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var msg rpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return fmt.Errorf("signal-cli %s: decode response: %w", method, err)
+	}
+	return decodeResult(msg, result)
+}
+
+func (h *httpRPC) listen(ctx context.Context, handle func(json.RawMessage)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.base+"/api/v1/events", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	// The event stream stays open; only the request context ends it.
+	resp, err := (&http.Client{Transport: h.client.Transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("signal-cli events: HTTP %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if rest, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(rest, " "))
+			continue
+		}
+		if line == "" && data.Len() > 0 {
+			handle(unwrapNotification([]byte(data.String())))
+			data.Reset()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// unwrapNotification returns the params of a JSON-RPC notification, or
+// data itself when it already is the params object.
+func unwrapNotification(data []byte) json.RawMessage {
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err == nil && msg.Method != "" && len(msg.Params) > 0 {
+		return msg.Params
+	}
+	return data
+}
+
+// socketRPC speaks newline-delimited JSON-RPC over the daemon's --tcp or
+// --socket interface. Calls share the connection opened by listen.
+type socketRPC struct {
+	network string
+	address string
+
+	mu      sync.Mutex
+	conn    net.Conn
+	pending map[string]chan rpcMessage
+}
+
+var errNotConnected = errors.New("signal-cli: not connected")
+
+func (s *socketRPC) call(ctx context.Context, method string, params, result any) error {
+	id := nextRPCID()
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: id})
+	if err != nil {
+		return err
+	}
+
+	reply := make(chan rpcMessage, 1)
+	s.mu.Lock()
+	conn := s.conn
+	if conn == nil {
+		s.mu.Unlock()
+		return errNotConnected
+	}
+	s.pending[id] = reply
+	_ = conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err = conn.Write(append(body, '\n'))
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(60 * time.Second)
+	defer timer.Stop()
+	select {
+	case msg, ok := <-reply:
+		if !ok {
+			return errNotConnected
+		}
+		return decodeResult(msg, result)
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return fmt.Errorf("signal-cli %s: timed out", method)
+	}
+}
+
+func (s *socketRPC) listen(ctx context.Context, handle func(json.RawMessage)) error {
+	conn, err := (&net.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.conn = conn
+	s.pending = make(map[string]chan rpcMessage)
+	s.mu.Unlock()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer func() {
+		stop()
+		conn.Close()
+		s.mu.Lock()
+		s.conn = nil
+		for id, ch := range s.pending {
+			close(ch)
+			delete(s.pending, id)
+		}
+		s.mu.Unlock()
+	}()
+
+	// Handlers make calls whose responses arrive on this connection, so
+	// they run on their own goroutine, in order.
+	q := newNotificationQueue(handle)
+	defer q.close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg rpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.Method != "" {
+			if msg.Method == "receive" {
+				q.push(msg.Params)
+			}
+			continue
+		}
+		s.mu.Lock()
+		ch := s.pending[msg.ID]
+		delete(s.pending, msg.ID)
+		s.mu.Unlock()
+		if ch != nil {
+			ch <- msg
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// notificationQueue runs a handler for queued notifications one at a time
+// without ever blocking push.
+type notificationQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  []json.RawMessage
+	closed bool
+}
+
+func newNotificationQueue(handle func(json.RawMessage)) *notificationQueue {
+	q := &notificationQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	go func() {
+		for {
+			q.mu.Lock()
+			for len(q.items) == 0 && !q.closed {
+				q.cond.Wait()
+			}
+			if len(q.items) == 0 {
+				q.mu.Unlock()
+				return
+			}
+			item := q.items[0]
+			q.items = q.items[1:]
+			q.mu.Unlock()
+			handle(item)
+		}
+	}()
+	return q
+}
+
+func (q *notificationQueue) push(item json.RawMessage) {
+	q.mu.Lock()
+	q.items = append(q.items, item)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// close lets the handler finish the queued notifications and stop.
+func (q *notificationQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Signal()
+}
@@ -0,0 +1,524 @@
+package signal
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+const (
+	defaultURL           = "http://127.0.0.1:8080"
+	defaultReactionEmoji = "👀"
+	groupPrefix          = "group:"
+	// Signal clients hide a typing indicator after 15 seconds.
+	typingInterval = 10 * time.Second
+	maxBackoff     = time.Minute
+	// mentionPlaceholder stands in for a mention in a message's text.
+	mentionPlaceholder = "\uFFFC"
+)
+
+// SignalChannel connects to a signal-cli daemon. Direct chats use the
+// sender's number as chat ID, groups "group:<groupId>".
+type SignalChannel struct {
+	*channels.BaseChannel
+	config config.SignalConfig
+	rpc    rpcClient
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// envelope is the part of a signal-cli "receive" notification we use.
+type envelope struct {
+	Source       string       `json:"source"`
+	SourceNumber string       `json:"sourceNumber"`
+	SourceUUID   string       `json:"sourceUuid"`
+	SourceName   string       `json:"sourceName"`
+	Timestamp    int64        `json:"timestamp"`
+	DataMessage  *dataMessage `json:"dataMessage"`
+}
+
+type dataMessage struct {
+	Timestamp   int64            `json:"timestamp"`
+	Message     string           `json:"message"`
+	GroupInfo   *groupInfo       `json:"groupInfo"`
+	Attachments []attachmentInfo `json:"attachments"`
+	Mentions    []mention        `json:"mentions"`
+}
+
+type groupInfo struct {
+	GroupID string `json:"groupId"`
+}
+
+type attachmentInfo struct {
+	ID          string `json:"id"`
+	ContentType string `json:"contentType"`
+	Filename    string `json:"filename"`
+}
+
+type mention struct {
+	Number string `json:"number"`
+	UUID   string `json:"uuid"`
+}
+
+// NewSignalChannel creates a new Signal channel.
+func NewSignalChannel(cfg config.SignalConfig, messageBus *bus.MessageBus) (*SignalChannel, error) {
+	if cfg.Account == "" {
+		return nil, fmt.Errorf("signal account is required")
+	}
+	if cfg.URL == "" {
+		cfg.URL = defaultURL
+	}
+	rpc, err := newRPCClient(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	base := channels.NewBaseChannel("signal", cfg, messageBus, cfg.AllowFrom,
+		channels.WithGroupTrigger(cfg.GroupTrigger),
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+	return &SignalChannel{
+		BaseChannel: base,
+		config:      cfg,
+		rpc:         rpc,
+	}, nil
+}
+
+// Start begins receiving messages from signal-cli.
+func (c *SignalChannel) Start(ctx context.Context) error {
+	logger.InfoC("signal", "Starting Signal channel")
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	c.wg.Add(1)
+	go c.receive()
+
+	c.SetRunning(true)
+	logger.InfoCF("signal", "Signal channel started", map[string]any{
+		"url":     c.config.URL,
+		"account": c.config.Account,
+	})
+	return nil
+}
+
+// Stop stops receiving messages.
+func (c *SignalChannel) Stop(ctx context.Context) error {
+	logger.InfoC("signal", "Stopping Signal channel")
+	c.SetRunning(false)
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+	logger.InfoC("signal", "Signal channel stopped")
+	return nil
+}
+
+// receive listens for messages, reconnecting with backoff.
+func (c *SignalChannel) receive() {
+	defer c.wg.Done()
+	backoff := time.Second
+	for c.ctx.Err() == nil {
+		started := time.Now()
+		err := c.rpc.listen(c.ctx, c.handleNotification)
+		if c.ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > maxBackoff {
+			backoff = time.Second
+		}
+		logger.WarnCF("signal", "signal-cli connection lost, reconnecting", map[string]any{
+			"error":   fmt.Sprint(err),
+			"backoff": backoff.String(),
+		})
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c *SignalChannel) handleNotification(params json.RawMessage) {
+	var note struct {
+		Envelope envelope `json:"envelope"`
+	}
+	if err := json.Unmarshal(params, &note); err != nil {
+		logger.DebugCF("signal", "Ignoring undecodable notification", map[string]any{"error": err.Error()})
+		return
+	}
+	c.handleEnvelope(note.Envelope)
+}
+
+func (c *SignalChannel) handleEnvelope(env envelope) {
+	dm := env.DataMessage
+	if dm == nil {
+		return // receipts, typing, sync messages
+	}
+	senderID := env.SourceNumber
+	if senderID == "" {
+		senderID = env.Source
+	}
+	if senderID == "" {
+		senderID = env.SourceUUID
+	}
+	if senderID == "" || senderID == c.config.Account {
+		return
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "signal",
+		PlatformID:  senderID,
+		CanonicalID: identity.BuildCanonicalID("signal", senderID),
+		Username:    senderID,
+		DisplayName: env.SourceName,
+	}
+	if !c.IsAllowedSender(sender) {
+		return
+	}
+
+	chatID, peer := senderID, bus.Peer{Kind: "direct", ID: senderID}
+	content := dm.Message
+	if dm.GroupInfo != nil && dm.GroupInfo.GroupID != "" {
+		chatID = groupPrefix + dm.GroupInfo.GroupID
+		peer = bus.Peer{Kind: "group", ID: dm.GroupInfo.GroupID}
+
+		isMentioned := false
+		for _, m := range dm.Mentions {
+			if m.Number == c.config.Account {
+				isMentioned = true
+			}
+		}
+		respond, cleaned := c.ShouldRespondInGroup(isMentioned, strings.ReplaceAll(content, mentionPlaceholder, ""))
+		if !respond {
+			return
+		}
+		content = cleaned
+	}
+
+	timestamp := dm.Timestamp
+	if timestamp == 0 {
+		timestamp = env.Timestamp
+	}
+	messageID := formatMessageID(senderID, timestamp)
+	scope := channels.BuildMediaScope("signal", chatID, messageID)
+
+	var mediaRefs []string
+	for _, a := range dm.Attachments {
+		if ref := c.downloadAttachment(chatID, a, scope); ref != "" {
+			mediaRefs = append(mediaRefs, ref)
+		}
+		content = appendContent(content, attachmentAnnotation(a))
+	}
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	metadata := map[string]string{
+		"platform": "signal",
+		"account":  c.config.Account,
+	}
+	c.HandleMessage(c.ctx, peer, messageID, senderID, chatID, content, mediaRefs, metadata, sender)
+}
+
+// formatMessageID encodes the author and timestamp that identify a Signal
+// message, as quotes and reactions need both.
+func formatMessageID(author string, timestamp int64) string {
+	return strconv.FormatInt(timestamp, 10) + ":" + author
+}
+
+func parseMessageID(id string) (author string, timestamp int64, ok bool) {
+	ts, author, found := strings.Cut(id, ":")
+	if !found || author == "" {
+		return "", 0, false
+	}
+	timestamp, err := strconv.ParseInt(ts, 10, 64)
+	return author, timestamp, err == nil
+}
+
+func attachmentAnnotation(a attachmentInfo) string {
+	kind := "file"
+	switch {
+	case strings.HasPrefix(a.ContentType, "image/"):
+		kind = "image"
+	case strings.HasPrefix(a.ContentType, "audio/"):
+		kind = "audio"
+	case strings.HasPrefix(a.ContentType, "video/"):
+		kind = "video"
+	}
+	if a.Filename == "" {
+		return "[" + kind + "]"
+	}
+	return fmt.Sprintf("[%s: %s]", kind, a.Filename)
+}
+
+func appendContent(content, suffix string) string {
+	if content == "" {
+		return suffix
+	}
+	return content + "\n" + suffix
+}
+
+// downloadAttachment fetches an attachment from signal-cli and stores it in
+// the MediaStore. It returns the media ref, or "" on failure.
+func (c *SignalChannel) downloadAttachment(chatID string, a attachmentInfo, scope string) string {
+	store := c.GetMediaStore()
+	if store == nil || a.ID == "" {
+		return ""
+	}
+	params := c.target(chatID)
+	params["id"] = a.ID
+	var result struct {
+		Data string `json:"data"`
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, 60*time.Second)
+	defer cancel()
+	err := c.rpc.call(ctx, "getAttachment", params, &result)
+	var data []byte
+	if err == nil {
+		data, err = base64.StdEncoding.DecodeString(result.Data)
+	}
+	var localPath string
+	if err == nil {
+		localPath, err = writeTemp(a, data)
+	}
+	if err != nil {
+		logger.WarnCF("signal", "Failed to download attachment", map[string]any{
+			"id":    a.ID,
+			"error": err.Error(),
+		})
+		return ""
+	}
+
+	filename := a.Filename
+	if filename == "" {
+		filename = filepath.Base(localPath)
+	}
+	ref, err := store.Store(localPath, media.MediaMeta{
+		Filename:    filename,
+		ContentType: a.ContentType,
+		Source:      "signal",
+	}, scope)
+	if err != nil {
+		_ = os.Remove(localPath)
+		logger.WarnCF("signal", "Failed to store attachment", map[string]any{"id": a.ID, "error": err.Error()})
+		return ""
+	}
+	return ref
+}
+
+func writeTemp(a attachmentInfo, data []byte) (string, error) {
+	dir := media.TempDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, "signal-*"+filepath.Ext(a.Filename))
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// target returns the recipient params for a chat ID.
+func (c *SignalChannel) target(chatID string) map[string]any {
+	params := map[string]any{"account": c.config.Account}
+	if groupID, ok := strings.CutPrefix(chatID, groupPrefix); ok {
+		params["groupId"] = groupID
+	} else {
+		params["recipient"] = []string{chatID}
+	}
+	return params
+}
+
+// Send sends a text message, quoting ReplyToMessageID when set.
+func (c *SignalChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	if msg.ChatID == "" {
+		return fmt.Errorf("chat ID is empty: %w", channels.ErrSendFailed)
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+
+	params := c.target(msg.ChatID)
+	params["message"] = msg.Content
+	if author, ts, ok := parseMessageID(msg.ReplyToMessageID); ok {
+		params["quoteTimestamp"] = ts
+		params["quoteAuthor"] = author
+	}
+	return c.send(ctx, params)
+}
+
+// SendMedia sends the parts as attachments of one message, with their
+// captions as its text.
+func (c *SignalChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		return fmt.Errorf("no media store available: %w", channels.ErrSendFailed)
+	}
+
+	var captions, attachments []string
+	for _, part := range msg.Parts {
+		localPath, meta, err := store.ResolveWithMeta(part.Ref)
+		if err != nil {
+			logger.ErrorCF("signal", "Failed to resolve media ref", map[string]any{
+				"ref":   part.Ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return fmt.Errorf("read attachment: %v: %w", err, channels.ErrSendFailed)
+		}
+		filename := part.Filename
+		if filename == "" {
+			filename = meta.Filename
+		}
+		if filename == "" {
+			filename = filepath.Base(localPath)
+		}
+		contentType := part.ContentType
+		if contentType == "" {
+			contentType = meta.ContentType
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		// Data URIs work when signal-cli cannot read our files, e.g. in
+		// another container.
+		attachments = append(attachments, fmt.Sprintf("data:%s;filename=%s;base64,%s",
+			contentType, filename, base64.StdEncoding.EncodeToString(data)))
+		if part.Caption != "" {
+			captions = append(captions, part.Caption)
+		}
+	}
+	if len(attachments) == 0 {
+		return fmt.Errorf("no attachments to send: %w", channels.ErrSendFailed)
+	}
+
+	params := c.target(msg.ChatID)
+	params["attachments"] = attachments
+	if len(captions) > 0 {
+		params["message"] = strings.Join(captions, "\n")
+	}
+	return c.send(ctx, params)
+}
+
+func (c *SignalChannel) send(ctx context.Context, params map[string]any) error {
+	if err := c.rpc.call(ctx, "send", params, nil); err != nil {
+		if _, isRPC := err.(*rpcError); isRPC {
+			return fmt.Errorf("signal send: %v: %w", err, channels.ErrSendFailed)
+		}
+		return fmt.Errorf("signal send: %v: %w", err, channels.ErrTemporary)
+	}
+	return nil
+}
+
+// StartTyping implements channels.TypingCapable. It repeats the indicator
+// until stopped, as clients hide it after 15 seconds.
+func (c *SignalChannel) StartTyping(ctx context.Context, chatID string) (func(), error) {
+	if !c.config.Typing.Enabled || !c.IsRunning() {
+		return func() {}, nil
+	}
+	sendTyping := func(stop bool) {
+		params := c.target(chatID)
+		if stop {
+			params["stop"] = true
+		}
+		callCtx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
+		defer cancel()
+		if err := c.rpc.call(callCtx, "sendTyping", params, nil); err != nil {
+			logger.DebugCF("signal", "sendTyping failed", map[string]any{"error": err.Error()})
+		}
+	}
+	sendTyping(false)
+
+	typingCtx, cancel := context.WithCancel(c.ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(typingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-typingCtx.Done():
+				return
+			case <-ticker.C:
+				sendTyping(false)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+			sendTyping(true)
+		})
+	}, nil
+}
+
+// ReactToMessage implements channels.ReactionCapable.
+func (c *SignalChannel) ReactToMessage(ctx context.Context, chatID, messageID string) (func(), error) {
+	author, ts, ok := parseMessageID(messageID)
+	if !ok || !c.IsRunning() {
+		return func() {}, nil
+	}
+	emoji := c.config.ReactionEmoji
+	if emoji == "" {
+		emoji = defaultReactionEmoji
+	}
+	react := func(remove bool) error {
+		params := c.target(chatID)
+		params["emoji"] = emoji
+		params["targetAuthor"] = author
+		params["targetTimestamp"] = ts
+		if remove {
+			params["remove"] = true
+		}
+		callCtx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
+		defer cancel()
+		return c.rpc.call(callCtx, "sendReaction", params, nil)
+	}
+	if err := react(false); err != nil {
+		return func() {}, fmt.Errorf("signal react: %w", err)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if err := react(true); err != nil {
+				logger.DebugCF("signal", "Failed to remove reaction", map[string]any{"error": err.Error()})
+			}
+		})
+	}, nil
+}
@@ -0,0 +1,317 @@
+package signal
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+const botNumber = "+15550000000"
+
+type rpcCall struct {
+	Method string
+	Params map[string]any
+}
+
+// fakeDaemon answers signal-cli JSON-RPC calls and pushes "receive"
+// notifications, over TCP or HTTP.
+type fakeDaemon struct {
+	calls  chan rpcCall
+	events chan string // envelope JSON
+}
+
+func newFakeDaemon() *fakeDaemon {
+	return &fakeDaemon{calls: make(chan rpcCall, 32), events: make(chan string, 8)}
+}
+
+func (d *fakeDaemon) answer(line []byte) []byte {
+	var req struct {
+		ID     string         `json:"id"`
+		Method string         `json:"method"`
+		Params map[string]any `json:"params"`
+	}
+	if err := json.Unmarshal(line, &req); err != nil {
+		return nil
+	}
+	d.calls <- rpcCall{Method: req.Method, Params: req.Params}
+
+	var result any = map[string]any{}
+	switch req.Method {
+	case "getAttachment":
+		result = map[string]any{"data": base64.StdEncoding.EncodeToString([]byte("photo bytes"))}
+	case "send":
+		if req.Params["recipient"] != nil && req.Params["recipient"].([]any)[0] == "+19999999999" {
+			resp, _ := json.Marshal(map[string]any{
+				"jsonrpc": "2.0", "id": req.ID,
+				"error": map[string]any{"code": -1, "message": "Unregistered user"},
+			})
+			return resp
+		}
+		result = map[string]any{"timestamp": 1700000009999}
+	}
+	resp, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	return resp
+}
+
+func (d *fakeDaemon) push(envelope string) {
+	d.events <- fmt.Sprintf(`{"envelope":%s,"account":%q}`, envelope, botNumber)
+}
+
+// next returns the next call of method, skipping others.
+func (d *fakeDaemon) next(t *testing.T, method string) rpcCall {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case c := <-d.calls:
+			if c.Method == method {
+				return c
+			}
+		case <-timeout:
+			t.Fatalf("no %s call", method)
+			return rpcCall{}
+		}
+	}
+}
+
+func (d *fakeDaemon) serveTCP(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var mu sync.Mutex
+		write := func(b []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			conn.Write(append(b, '\n'))
+		}
+		go func() {
+			for params := range d.events {
+				write([]byte(`{"jsonrpc":"2.0","method":"receive","params":` + params + `}`))
+			}
+		}()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			if resp := d.answer(scanner.Bytes()); resp != nil {
+				write(resp)
+			}
+		}
+	}()
+	return "tcp://" + ln.Addr().String()
+}
+
+func (d *fakeDaemon) serveHTTP(t *testing.T) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/rpc", func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(d.answer(body))
+	})
+	mux.HandleFunc("GET /api/v1/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case params := <-d.events:
+				fmt.Fprintf(w, "event:receive\ndata:%s\n\n", params)
+				w.(http.Flusher).Flush()
+			}
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func startChannel(t *testing.T, cfg config.SignalConfig) (*SignalChannel, *bus.MessageBus) {
+	t.Helper()
+	mb := bus.NewMessageBus()
+	cfg.Account = botNumber
+	ch, err := NewSignalChannel(cfg, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.SetMediaStore(media.NewFileMediaStore())
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ch.Stop(context.Background()) })
+	return ch, mb
+}
+
+func nextInbound(t *testing.T, mb *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func TestSignalChannel_SocketDirectAndGroup(t *testing.T) {
+	d := newFakeDaemon()
+	ch, mb := startChannel(t, config.SignalConfig{
+		URL:          d.serveTCP(t),
+		AllowFrom:    config.FlexibleStringSlice{"+15551111111"},
+		GroupTrigger: config.GroupTriggerConfig{MentionOnly: true},
+	})
+
+	d.push(`{"sourceNumber":"+15552222222","timestamp":1,"dataMessage":{"timestamp":1,"message":"not allowed"}}`)
+	d.push(`{"sourceNumber":"+15551111111","timestamp":2,"dataMessage":{"timestamp":2,` +
+		`"message":"no mention","groupInfo":{"groupId":"R3JvdXA="}}}`)
+	d.push(`{"sourceNumber":"+15551111111","sourceName":"Alice","timestamp":3,"dataMessage":{"timestamp":3,` +
+		`"message":"look at this","attachments":[{"id":"att1","contentType":"image/jpeg","filename":"cat.jpg"}]}}`)
+
+	direct := nextInbound(t, mb)
+	if direct.ChatID != "+15551111111" || direct.Peer.Kind != "direct" || direct.MessageID != "3:+15551111111" {
+		t.Errorf("direct message: chat %q, peer %+v, id %q", direct.ChatID, direct.Peer, direct.MessageID)
+	}
+	if direct.Content != "look at this\n[image: cat.jpg]" || len(direct.Media) != 1 {
+		t.Fatalf("content %q, media %v", direct.Content, direct.Media)
+	}
+	path, meta, err := ch.GetMediaStore().ResolveWithMeta(direct.Media[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "photo bytes" || meta.Filename != "cat.jpg" {
+		t.Errorf("attachment %q (%s)", data, meta.Filename)
+	}
+	if got := d.next(t, "getAttachment").Params["id"]; got != "att1" {
+		t.Errorf("getAttachment id = %v", got)
+	}
+
+	d.push(`{"sourceNumber":"+15551111111","timestamp":4,"dataMessage":{"timestamp":4,` +
+		`"message":"\uFFFC what's up","groupInfo":{"groupId":"R3JvdXA="},` +
+		`"mentions":[{"number":"` + botNumber + `","start":0,"length":1}]}}`)
+	group := nextInbound(t, mb)
+	if group.ChatID != "group:R3JvdXA=" || group.Peer.Kind != "group" || group.Content != "what's up" {
+		t.Errorf("group message: chat %q, peer %+v, content %q", group.ChatID, group.Peer, group.Content)
+	}
+
+	err = ch.Send(context.Background(), bus.OutboundMessage{
+		ChatID: group.ChatID, Content: "not much", ReplyToMessageID: group.MessageID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := d.next(t, "send").Params
+	if send["groupId"] != "R3JvdXA=" || send["message"] != "not much" || send["account"] != botNumber ||
+		send["quoteAuthor"] != "+15551111111" || send["quoteTimestamp"] != float64(4) {
+		t.Errorf("send params = %v", send)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "+19999999999", Content: "hi"}); err == nil {
+		t.Error("expected an error for a failed send")
+	}
+}
+
+func TestSignalChannel_HTTPMediaTypingAndReactions(t *testing.T) {
+	d := newFakeDaemon()
+	ch, mb := startChannel(t, config.SignalConfig{
+		URL:    d.serveHTTP(t),
+		Typing: config.TypingConfig{Enabled: true},
+	})
+
+	d.push(`{"sourceNumber":"+15553333333","timestamp":7,"dataMessage":{"timestamp":7,"message":"hello"}}`)
+	in := nextInbound(t, mb)
+	if in.Content != "hello" || in.SenderID != "signal:+15553333333" {
+		t.Fatalf("inbound %+v", in)
+	}
+
+	stop, err := ch.StartTyping(context.Background(), in.ChatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := d.next(t, "sendTyping").Params; p["stop"] != nil || p["recipient"].([]any)[0] != "+15553333333" {
+		t.Errorf("sendTyping params = %v", p)
+	}
+	stop()
+	stop()
+	if p := d.next(t, "sendTyping").Params; p["stop"] != true {
+		t.Errorf("stop typing params = %v", p)
+	}
+
+	undo, err := ch.ReactToMessage(context.Background(), in.ChatID, in.MessageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := d.next(t, "sendReaction").Params
+	if p["emoji"] != defaultReactionEmoji || p["targetAuthor"] != "+15553333333" || p["targetTimestamp"] != float64(7) {
+		t.Errorf("sendReaction params = %v", p)
+	}
+	undo()
+	if p := d.next(t, "sendReaction").Params; p["remove"] != true {
+		t.Errorf("remove reaction params = %v", p)
+	}
+
+	file := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(file, []byte("%PDF"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := ch.GetMediaStore().Store(file, media.MediaMeta{Filename: "report.pdf", ContentType: "application/pdf"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ch.SendMedia(context.Background(), bus.OutboundMediaMessage{
+		ChatID: in.ChatID,
+		Parts:  []bus.MediaPart{{Type: "file", Ref: ref, Caption: "Your report"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := d.next(t, "send").Params
+	attachments, _ := send["attachments"].([]any)
+	want := "data:application/pdf;filename=report.pdf;base64," + base64.StdEncoding.EncodeToString([]byte("%PDF"))
+	if send["message"] != "Your report" || len(attachments) != 1 || attachments[0] != want {
+		t.Errorf("send params = %v", send)
+	}
+}
+
+func TestMessageIDRoundTrip(t *testing.T) {
+	author, ts, ok := parseMessageID(formatMessageID("+15551111111", 1700000000123))
+	if !ok || author != "+15551111111" || ts != 1700000000123 {
+		t.Errorf("parseMessageID = %q, %d, %v", author, ts, ok)
+	}
+	for _, bad := range []string{"", "123", "abc:+1", ":+1"} {
+		if _, _, ok := parseMessageID(bad); ok {
+			t.Errorf("parseMessageID(%q) accepted", bad)
+		}
+	}
+}
+
+func TestNewSignalChannel_Validation(t *testing.T) {
+	if _, err := NewSignalChannel(config.SignalConfig{}, bus.NewMessageBus()); err == nil {
+		t.Error("expected an error without account")
+	}
+	if _, err := NewSignalChannel(config.SignalConfig{Account: botNumber, URL: "ftp://x"}, bus.NewMessageBus()); err == nil {
+		t.Error("expected an error for an unsupported URL scheme")
+	}
+}
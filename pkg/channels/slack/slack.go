@@ -50,6 +50,7 @@ func NewSlackChannel(cfg config.SlackConfig, messageBus *bus.MessageBus) (*Slack
 
 	base := channels.NewBaseChannel("slack", cfg, messageBus, cfg.AllowFrom,
 		channels.WithMaxMessageLength(40000),
+		channels.WithTextFormat(channels.FormatSlack),
 		channels.WithGroupTrigger(cfg.GroupTrigger),
 		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
 	)
@@ -119,16 +120,9 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	}
 
 	opts := []slack.MsgOption{
-		slack.MsgOptionText(msg.Content, false),
-	}
-
-	if msg.ReplyToMessageID != "" && threadTS == "" {
-		// Answer to the message by creating a Thread under it
-		opts = append(opts, slack.MsgOptionTS(msg.ReplyToMessageID))
-	} else if threadTS != "" {
-		// If we are already in a thread, continue in the thread
-		opts = append(opts, slack.MsgOptionTS(threadTS))
+		slack.MsgOptionText(channels.RenderMarkdown(msg.Content, channels.FormatSlack), false),
 	}
+	opts = append(opts, threadOptions(msg, threadTS)...)
 
 	_, _, err := c.api.PostMessageContext(ctx, channelID, opts...)
 	if err != nil {
@@ -151,6 +145,119 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	return nil
 }
 
+// threadOptions keeps a reply in the thread it belongs to.
+func threadOptions(msg bus.OutboundMessage, threadTS string) []slack.MsgOption {
+	if msg.ReplyToMessageID != "" && threadTS == "" {
+		// Answer to the message by creating a Thread under it
+		return []slack.MsgOption{slack.MsgOptionTS(msg.ReplyToMessageID)}
+	} else if threadTS != "" {
+		// If we are already in a thread, continue in the thread
+		return []slack.MsgOption{slack.MsgOptionTS(threadTS)}
+	}
+	return nil
+}
+
+// SendChoices implements channels.ChoiceCapable with a Block Kit section
+// followed by one button per option.
+func (c *SlackChannel) SendChoices(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+
+	channelID, threadTS := parseSlackChatID(msg.ChatID)
+	if channelID == "" {
+		return fmt.Errorf("invalid slack chat ID: %s", msg.ChatID)
+	}
+
+	text := channels.RenderMarkdown(msg.Content, channels.FormatSlack)
+	opts := []slack.MsgOption{
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(choiceBlocks(text, msg.Prompt)...),
+	}
+	opts = append(opts, threadOptions(msg, threadTS)...)
+
+	if _, _, err := c.api.PostMessageContext(ctx, channelID, opts...); err != nil {
+		return fmt.Errorf("slack send: %w", channels.ErrTemporary)
+	}
+	return nil
+}
+
+// slackSectionLimit is the maximum length of a section block's text.
+const slackSectionLimit = 3000
+
+func choiceBlocks(content string, prompt *bus.ChoicePrompt) []slack.Block {
+	buttons := make([]slack.BlockElement, 0, len(prompt.Options))
+	for i, option := range prompt.Options {
+		data := channels.ChoiceData(prompt.ID, i+1)
+		buttons = append(buttons, slack.NewButtonBlockElement(data, data,
+			slack.NewTextBlockObject(slack.PlainTextType, utils.Truncate(option, 75), false, false)))
+	}
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			utils.Truncate(content, slackSectionLimit), false, false), nil, nil),
+		slack.NewActionBlock(prompt.ID, buttons...),
+	}
+}
+
+// handleInteractive handles presses of buttons sent by SendChoices. The
+// buttons are replaced with a note of the chosen option afterwards.
+func (c *SlackChannel) handleInteractive(event socketmode.Event) {
+	if event.Request != nil {
+		c.socketClient.Ack(*event.Request)
+	}
+
+	callback, ok := event.Data.(slack.InteractionCallback)
+	if !ok || callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "slack",
+		PlatformID:  callback.User.ID,
+		CanonicalID: identity.BuildCanonicalID("slack", callback.User.ID),
+		Username:    callback.User.Name,
+	}
+	if !c.IsAllowedSender(sender) {
+		return
+	}
+
+	channelID := callback.Container.ChannelID
+	chatID := channelID
+	var metadata map[string]string
+	if callback.Container.ThreadTs != "" {
+		chatID = channelID + "/" + callback.Container.ThreadTs
+		metadata = map[string]string{channels.MetadataThreadID: callback.Container.ThreadTs}
+	}
+	peer := bus.Peer{Kind: "channel", ID: channelID}
+	if strings.HasPrefix(channelID, "D") {
+		peer = bus.Peer{Kind: "direct", ID: callback.User.ID}
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		label := action.Text.Text
+		if !c.HandleChoice(c.ctx, peer, callback.Container.MessageTs, callback.User.ID, chatID,
+			action.Value, label, metadata, sender) {
+			continue
+		}
+
+		text := callback.Message.Text
+		_, _, _, err := c.api.UpdateMessageContext(c.ctx, channelID, callback.Container.MessageTs,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionBlocks(
+				slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+					utils.Truncate(text, slackSectionLimit), false, false), nil, nil),
+				slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType,
+					fmt.Sprintf("Selected: *%s*", label), false, false)),
+			))
+		if err != nil {
+			logger.DebugCF("slack", "Failed to remove choice buttons", map[string]any{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+}
+
 // SendMedia implements the channels.MediaSender interface.
 func (c *SlackChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
 	if !c.IsRunning() {
@@ -242,9 +349,7 @@ func (c *SlackChannel) eventLoop() {
 			case socketmode.EventTypeSlashCommand:
 				c.handleSlashCommand(event)
 			case socketmode.EventTypeInteractive:
-				if event.Request != nil {
-					c.socketClient.Ack(*event.Request)
-				}
+				c.handleInteractive(event)
 			}
 		}
 	}
@@ -368,6 +473,9 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 		"platform":   "slack",
 		"team_id":    c.teamID,
 	}
+	if threadTS != "" {
+		metadata[channels.MetadataThreadID] = threadTS
+	}
 
 	logger.DebugCF("slack", "Received message", map[string]any{
 		"sender_id":  senderID,
@@ -405,12 +513,13 @@ func (c *SlackChannel) handleAppMention(ev *slackevents.AppMentionEvent) {
 	threadTS := ev.ThreadTimeStamp
 	messageTS := ev.TimeStamp
 
-	var chatID string
-	if threadTS != "" {
-		chatID = channelID + "/" + threadTS
-	} else {
-		chatID = channelID + "/" + messageTS
+	// A top-level mention is answered in a new thread under it, so that
+	// thread is the conversation from the start.
+	threadID := threadTS
+	if threadID == "" {
+		threadID = messageTS
 	}
+	chatID := channelID + "/" + threadID
 
 	c.pendingAcks.Store(chatID, slackMessageRef{
 		ChannelID: channelID,
@@ -440,6 +549,7 @@ func (c *SlackChannel) handleAppMention(ev *slackevents.AppMentionEvent) {
 		"is_mention": "true",
 		"team_id":    c.teamID,
 	}
+	metadata[channels.MetadataThreadID] = threadID
 
 	c.HandleMessage(c.ctx, mentionPeer, messageTS, senderID, chatID, content, nil, metadata, mentionSender)
 }
@@ -3,7 +3,10 @@ package slack
 import (
 	"testing"
 
+	"github.com/slack-go/slack"
+
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 )
 
@@ -172,3 +175,21 @@ func TestSlackChannelIsAllowed(t *testing.T) {
 		}
 	})
 }
+
+func TestChoiceBlocks(t *testing.T) {
+	blocks := choiceBlocks("Deploy now?", &bus.ChoicePrompt{ID: "p1", Options: []string{"Yes", "No"}})
+	if len(blocks) != 2 {
+		t.Fatalf("blocks = %d, want 2", len(blocks))
+	}
+	actions, ok := blocks[1].(*slack.ActionBlock)
+	if !ok {
+		t.Fatalf("second block = %T, want *slack.ActionBlock", blocks[1])
+	}
+	if len(actions.Elements.ElementSet) != 2 {
+		t.Fatalf("buttons = %d, want 2", len(actions.Elements.ElementSet))
+	}
+	button := actions.Elements.ElementSet[1].(*slack.ButtonBlockElement)
+	if button.Value != channels.ChoiceData("p1", 2) || button.Text.Text != "No" {
+		t.Errorf("button = %q/%q, want choice 2 labelled No", button.Value, button.Text.Text)
+	}
+}
@@ -2,6 +2,7 @@ package channels
 
 import (
 	"strings"
+	"unicode/utf8"
 )
 
 // SplitMessage splits long messages into chunks, preserving code block integrity.
@@ -206,3 +207,235 @@ func findLastSpaceInRange(runes []rune, start, end, searchWindow int) int {
 	}
 	return start - 1
 }
+
+// SplitMessageFormat splits Markdown content into chunks whose rendering in
+// format fits maxLen runes. Chunks are still Markdown; the channel renders
+// each one itself. Splits prefer block boundaries (paragraphs, lists, fenced
+// code), then line breaks, then spaces, and avoid cutting through emphasis,
+// links or code spans so every chunk renders on its own. Oversized code
+// blocks are re-fenced on both sides of a cut. FormatNone falls back to
+// SplitMessage.
+func SplitMessageFormat(content string, maxLen int, format TextFormat) []string {
+	if format == FormatNone || maxLen <= 0 {
+		return SplitMessage(content, maxLen)
+	}
+	if renderedLen(content, format) <= maxLen {
+		return []string{content}
+	}
+
+	var chunks []string
+	var current []string
+	currentLen := 0
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, "\n\n"))
+			current, currentLen = nil, 0
+		}
+	}
+
+	for _, block := range markdownBlocks(content) {
+		n := renderedLen(block, format)
+		if n > maxLen {
+			flush()
+			if fenceMarker(strings.TrimSpace(block)) != "" {
+				chunks = append(chunks, splitFencedBlock(block, maxLen, format)...)
+			} else {
+				chunks = append(chunks, splitTextBlock(block, maxLen, format)...)
+			}
+			continue
+		}
+		if len(current) > 0 && currentLen+2+n > maxLen {
+			flush()
+		}
+		if len(current) > 0 {
+			currentLen += 2
+		}
+		current = append(current, block)
+		currentLen += n
+	}
+	flush()
+	return chunks
+}
+
+func renderedLen(md string, format TextFormat) int {
+	return utf8.RuneCountInString(RenderMarkdown(md, format))
+}
+
+// markdownBlocks splits Markdown source at blank lines, keeping fenced code
+// blocks (which may contain blank lines) whole.
+func markdownBlocks(content string) []string {
+	var blocks, current []string
+	fence := ""
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			current = append(current, line)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+				flush()
+			}
+		case fenceMarker(trimmed) != "":
+			flush()
+			fence = fenceMarker(trimmed)
+			current = append(current, line)
+		case trimmed == "":
+			flush()
+		default:
+			current = append(current, line)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// fenceMarker returns the opening fence (``` or ~~~, possibly longer) that
+// line starts with, or "".
+func fenceMarker(line string) string {
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
+
+// splitFencedBlock splits a code block by lines, repeating its opening fence
+// (with the language tag) and closing fence on every chunk.
+func splitFencedBlock(block string, maxLen int, format TextFormat) []string {
+	lines := strings.Split(block, "\n")
+	header := strings.TrimSpace(lines[0])
+	fence := fenceMarker(header)
+	body := lines[1:]
+	if len(body) > 0 && strings.HasPrefix(strings.TrimSpace(body[len(body)-1]), fence) {
+		body = body[:len(body)-1]
+	}
+	build := func(ls []string) string {
+		return header + "\n" + strings.Join(ls, "\n") + "\n" + fence
+	}
+	fits := func(ls []string) bool { return renderedLen(build(ls), format) <= maxLen }
+
+	var chunks, current []string
+	for _, line := range body {
+		if len(current) > 0 && !fits(append(current[:len(current):len(current)], line)) {
+			chunks = append(chunks, build(current))
+			current = nil
+		}
+		if !fits([]string{line}) {
+			for _, part := range cutToFit(line, func(s string) bool { return fits([]string{s}) }) {
+				chunks = append(chunks, build([]string{part}))
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, build(current))
+	}
+	return chunks
+}
+
+// splitTextBlock splits a non-code block at the last line break or space
+// that fits, skipping cuts that would break inline markup apart.
+func splitTextBlock(block string, maxLen int, format TextFormat) []string {
+	fits := func(s string) bool { return renderedLen(s, format) <= maxLen }
+
+	var chunks []string
+	rest := block
+	for !fits(rest) {
+		runes := []rune(rest)
+		var cuts []int
+		for i, r := range runes {
+			if i > 0 && (r == '\n' || r == ' ') {
+				cuts = append(cuts, i)
+			}
+		}
+
+		// Largest cut whose left side fits; rendered length grows with the prefix.
+		lo, hi := 0, len(cuts)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if fits(string(runes[:cuts[mid]])) {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		if lo == 0 {
+			parts := cutToFit(rest, fits)
+			chunks = append(chunks, parts[0])
+			rest = strings.Join(parts[1:], "")
+			continue
+		}
+
+		cut := pickSafeCut(runes, cuts[:lo])
+		chunks = append(chunks, strings.TrimRight(string(runes[:cut]), " \n"))
+		rest = strings.TrimLeft(string(runes[cut:]), " \n")
+	}
+	if strings.TrimSpace(rest) != "" {
+		chunks = append(chunks, rest)
+	}
+	return chunks
+}
+
+// maxSafeCutProbes bounds how far back splitTextBlock searches for a cut
+// that leaves inline markup intact.
+const maxSafeCutProbes = 64
+
+// pickSafeCut chooses among candidate cut positions (ascending, all fitting),
+// preferring the latest line break, then the latest space, that does not
+// split inline markup. It falls back to the latest candidate.
+func pickSafeCut(runes []rune, cuts []int) int {
+	full := plainWords(string(runes))
+	safe := func(i int) bool {
+		left := plainWords(string(runes[:i]))
+		right := plainWords(string(runes[i:]))
+		return strings.TrimSpace(left+" "+right) == full
+	}
+
+	first := max(len(cuts)-maxSafeCutProbes, 0)
+	for _, wantNewline := range []bool{true, false} {
+		for j := len(cuts) - 1; j >= first; j-- {
+			if (runes[cuts[j]] == '\n') == wantNewline && safe(cuts[j]) {
+				return cuts[j]
+			}
+		}
+	}
+	return cuts[len(cuts)-1]
+}
+
+func plainWords(md string) string {
+	return strings.Join(strings.Fields(RenderMarkdown(md, FormatPlain)), " ")
+}
+
+// cutToFit hard-cuts s into consecutive pieces, each the longest rune prefix
+// accepted by fits (at least one rune).
+func cutToFit(s string, fits func(string) bool) []string {
+	runes := []rune(s)
+	var parts []string
+	for len(runes) > 0 {
+		lo, hi := 1, len(runes)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if fits(string(runes[:mid])) {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		parts = append(parts, string(runes[:lo]))
+		runes = runes[lo:]
+	}
+	return parts
+}
@@ -25,18 +25,9 @@ import (
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
-var (
-	reHeading    = regexp.MustCompile(`^#{1,6}\s+(.+)$`)
-	reBlockquote = regexp.MustCompile(`^>\s*(.*)$`)
-	reLink       = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
-	reBoldStar   = regexp.MustCompile(`\*\*(.+?)\*\*`)
-	reBoldUnder  = regexp.MustCompile(`__(.+?)__`)
-	reItalic     = regexp.MustCompile(`_([^_]+)_`)
-	reStrike     = regexp.MustCompile(`~~(.+?)~~`)
-	reListItem   = regexp.MustCompile(`^[-*]\s+`)
-	reCodeBlock  = regexp.MustCompile("```[\\w]*\\n?([\\s\\S]*?)```")
-	reInlineCode = regexp.MustCompile("`([^`]+)`")
-)
+// telegramMaxMessageLength is Telegram's sendMessage text limit. Rendered
+// HTML is measured against it, which is conservative since tags don't count.
+const telegramMaxMessageLength = 4096
 
 type TelegramChannel struct {
 	*channels.BaseChannel
@@ -89,7 +80,8 @@ func NewTelegramChannel(cfg *config.Config, bus *bus.MessageBus) (*TelegramChann
 		telegramCfg,
 		bus,
 		telegramCfg.AllowFrom,
-		channels.WithMaxMessageLength(4000),
+		channels.WithMaxMessageLength(telegramMaxMessageLength),
+		channels.WithTextFormat(channels.FormatTelegramHTML),
 		channels.WithGroupTrigger(telegramCfg.GroupTrigger),
 		channels.WithReasoningChannelID(telegramCfg.ReasoningChannelID),
 	)
@@ -126,6 +118,10 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 		return c.handleMessage(ctx, &message)
 	}, th.AnyMessage())
 
+	bh.HandleCallbackQuery(func(ctx *th.Context, query telego.CallbackQuery) error {
+		return c.handleCallbackQuery(ctx, &query)
+	}, th.AnyCallbackQueryWithMessage())
+
 	c.SetRunning(true)
 	logger.InfoCF("telegram", "Telegram bot connected", map[string]any{
 		"username": c.bot.Username(),
@@ -178,31 +174,14 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		return nil
 	}
 
-	// The Manager already splits messages to ≤4000 chars (WithMaxMessageLength),
-	// so msg.Content is guaranteed to be within that limit. We still need to
-	// check if HTML expansion pushes it beyond Telegram's 4096-char API limit.
+	// The Manager already splits by rendered length (WithTextFormat), but
+	// Send may also be called directly, so re-split against Telegram's
+	// 4096-char API limit as a safety net.
 	replyToID := msg.ReplyToMessageID
-	queue := []string{msg.Content}
-	for len(queue) > 0 {
-		chunk := queue[0]
-		queue = queue[1:]
-
+	for _, chunk := range channels.SplitMessageFormat(msg.Content, telegramMaxMessageLength, channels.FormatTelegramHTML) {
 		htmlContent := markdownToTelegramHTML(chunk)
 
-		if len([]rune(htmlContent)) > 4096 {
-			ratio := float64(len([]rune(chunk))) / float64(len([]rune(htmlContent)))
-			smallerLen := int(float64(4096) * ratio * 0.95) // 5% safety margin
-			if smallerLen < 100 {
-				smallerLen = 100
-			}
-			// Push sub-chunks back to the front of the queue for
-			// re-validation instead of sending them blindly.
-			subChunks := channels.SplitMessage(chunk, smallerLen)
-			queue = append(subChunks, queue...)
-			continue
-		}
-
-		if err := c.sendHTMLChunk(ctx, chatID, threadID, htmlContent, chunk, replyToID); err != nil {
+		if err := c.sendHTMLChunk(ctx, chatID, threadID, htmlContent, chunk, replyToID, nil); err != nil {
 			return err
 		}
 		// Only the first chunk should be a reply; subsequent chunks are normal messages.
@@ -212,14 +191,41 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	return nil
 }
 
+// SendChoices implements channels.ChoiceCapable by attaching an inline
+// keyboard with one button per option.
+func (c *TelegramChannel) SendChoices(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+
+	chatID, threadID, err := parseTelegramChatID(msg.ChatID)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID %s: %w", msg.ChatID, channels.ErrSendFailed)
+	}
+
+	rows := make([][]telego.InlineKeyboardButton, 0, len(msg.Prompt.Options))
+	for i, option := range msg.Prompt.Options {
+		button := tu.InlineKeyboardButton(option).WithCallbackData(channels.ChoiceData(msg.Prompt.ID, i+1))
+		rows = append(rows, tu.InlineKeyboardRow(button))
+	}
+
+	return c.sendHTMLChunk(ctx, chatID, threadID, markdownToTelegramHTML(msg.Content), msg.Content,
+		msg.ReplyToMessageID, tu.InlineKeyboard(rows...))
+}
+
 // sendHTMLChunk sends a single HTML message, falling back to the original
 // markdown as plain text on parse failure so users never see raw HTML tags.
+// keyboard is optional.
 func (c *TelegramChannel) sendHTMLChunk(
 	ctx context.Context, chatID int64, threadID int, htmlContent, mdFallback string, replyToID string,
+	keyboard *telego.InlineKeyboardMarkup,
 ) error {
 	tgMsg := tu.Message(tu.ID(chatID), htmlContent)
 	tgMsg.ParseMode = telego.ModeHTML
 	tgMsg.MessageThreadID = threadID
+	if keyboard != nil {
+		tgMsg.ReplyMarkup = keyboard
+	}
 
 	if replyToID != "" {
 		if mid, parseErr := strconv.Atoi(replyToID); parseErr == nil {
@@ -533,9 +539,9 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 	}
 
 	// For forum topics, embed the thread ID as "chatID/threadID" so replies
-	// route to the correct topic and each topic gets its own session.
-	// Only forum groups (IsForum) are handled; regular group reply threads
-	// must share one session per group.
+	// route to the correct topic, and report it as the message's thread so
+	// the session scope can isolate topics. Only forum groups (IsForum) are
+	// handled; regular group reply threads must share one session per group.
 	compositeChatID := fmt.Sprintf("%d", chatID)
 	threadID := message.MessageThreadID
 	if message.Chat.IsForum && threadID != 0 {
@@ -553,7 +559,7 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 	peerID := fmt.Sprintf("%d", user.ID)
 	if message.Chat.Type != "private" {
 		peerKind = "group"
-		peerID = compositeChatID
+		peerID = fmt.Sprintf("%d", chatID)
 	}
 
 	peer := bus.Peer{Kind: peerKind, ID: peerID}
@@ -568,6 +574,7 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 
 	// Set parent_peer metadata for per-topic agent binding.
 	if message.Chat.IsForum && threadID != 0 {
+		metadata[channels.MetadataThreadID] = fmt.Sprintf("%d", threadID)
 		metadata["parent_peer_kind"] = "topic"
 		metadata["parent_peer_id"] = fmt.Sprintf("%d", threadID)
 	}
@@ -585,6 +592,79 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 	return nil
 }
 
+// handleCallbackQuery handles a press of a button sent by SendChoices. The
+// keyboard is removed afterwards so an option cannot be picked twice.
+func (c *TelegramChannel) handleCallbackQuery(ctx context.Context, query *telego.CallbackQuery) error {
+	// Always answer, otherwise the client keeps showing a spinner.
+	defer func() {
+		_ = c.bot.AnswerCallbackQuery(ctx, tu.CallbackQuery(query.ID))
+	}()
+
+	platformID := fmt.Sprintf("%d", query.From.ID)
+	sender := bus.SenderInfo{
+		Platform:    "telegram",
+		PlatformID:  platformID,
+		CanonicalID: identity.BuildCanonicalID("telegram", platformID),
+		Username:    query.From.Username,
+		DisplayName: query.From.FirstName,
+	}
+	if !c.IsAllowedSender(sender) {
+		return nil
+	}
+
+	chat := query.Message.GetChat()
+	messageID := query.Message.GetMessageID()
+	compositeChatID := fmt.Sprintf("%d", chat.ID)
+	label := ""
+	var metadata map[string]string
+	if message := query.Message.Message(); message != nil {
+		if chat.IsForum && message.MessageThreadID != 0 {
+			compositeChatID = fmt.Sprintf("%d/%d", chat.ID, message.MessageThreadID)
+			metadata = map[string]string{
+				channels.MetadataThreadID: fmt.Sprintf("%d", message.MessageThreadID),
+				"parent_peer_kind":        "topic",
+				"parent_peer_id":          fmt.Sprintf("%d", message.MessageThreadID),
+			}
+		}
+		label = telegramButtonLabel(message.ReplyMarkup, query.Data)
+	}
+
+	peer := bus.Peer{Kind: "direct", ID: platformID}
+	if chat.Type != "private" {
+		peer = bus.Peer{Kind: "group", ID: fmt.Sprintf("%d", chat.ID)}
+	}
+
+	if !c.HandleChoice(c.ctx, peer, fmt.Sprintf("%d", messageID), platformID, compositeChatID,
+		query.Data, label, metadata, sender) {
+		return nil
+	}
+
+	if _, err := c.bot.EditMessageReplyMarkup(ctx, &telego.EditMessageReplyMarkupParams{
+		ChatID:    tu.ID(chat.ID),
+		MessageID: messageID,
+	}); err != nil {
+		logger.DebugCF("telegram", "Failed to remove choice keyboard", map[string]any{
+			"error": err.Error(),
+		})
+	}
+	return nil
+}
+
+// telegramButtonLabel returns the text of the inline button carrying data.
+func telegramButtonLabel(markup *telego.InlineKeyboardMarkup, data string) string {
+	if markup == nil {
+		return ""
+	}
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData == data {
+				return button.Text
+			}
+		}
+	}
+	return ""
+}
+
 func (c *TelegramChannel) downloadPhoto(ctx context.Context, fileID string) string {
 	file, err := c.bot.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
 	if err != nil {
@@ -643,109 +723,10 @@ func parseTelegramChatID(chatID string) (int64, int, error) {
 	return cid, tid, nil
 }
 
+// markdownToTelegramHTML renders Markdown into the HTML subset Telegram
+// accepts with parse_mode=HTML.
 func markdownToTelegramHTML(text string) string {
-	if text == "" {
-		return ""
-	}
-
-	codeBlocks := extractCodeBlocks(text)
-	text = codeBlocks.text
-
-	inlineCodes := extractInlineCodes(text)
-	text = inlineCodes.text
-
-	text = reHeading.ReplaceAllString(text, "$1")
-
-	text = reBlockquote.ReplaceAllString(text, "$1")
-
-	text = escapeHTML(text)
-
-	text = reLink.ReplaceAllString(text, `<a href="$2">$1</a>`)
-
-	text = reBoldStar.ReplaceAllString(text, "<b>$1</b>")
-
-	text = reBoldUnder.ReplaceAllString(text, "<b>$1</b>")
-
-	text = reItalic.ReplaceAllStringFunc(text, func(s string) string {
-		match := reItalic.FindStringSubmatch(s)
-		if len(match) < 2 {
-			return s
-		}
-		return "<i>" + match[1] + "</i>"
-	})
-
-	text = reStrike.ReplaceAllString(text, "<s>$1</s>")
-
-	text = reListItem.ReplaceAllString(text, "• ")
-
-	for i, code := range inlineCodes.codes {
-		escaped := escapeHTML(code)
-		text = strings.ReplaceAll(text, fmt.Sprintf("\x00IC%d\x00", i), fmt.Sprintf("<code>%s</code>", escaped))
-	}
-
-	for i, code := range codeBlocks.codes {
-		escaped := escapeHTML(code)
-		text = strings.ReplaceAll(
-			text,
-			fmt.Sprintf("\x00CB%d\x00", i),
-			fmt.Sprintf("<pre><code>%s</code></pre>", escaped),
-		)
-	}
-
-	return text
-}
-
-type codeBlockMatch struct {
-	text  string
-	codes []string
-}
-
-func extractCodeBlocks(text string) codeBlockMatch {
-	matches := reCodeBlock.FindAllStringSubmatch(text, -1)
-
-	codes := make([]string, 0, len(matches))
-	for _, match := range matches {
-		codes = append(codes, match[1])
-	}
-
-	i := 0
-	text = reCodeBlock.ReplaceAllStringFunc(text, func(m string) string {
-		placeholder := fmt.Sprintf("\x00CB%d\x00", i)
-		i++
-		return placeholder
-	})
-
-	return codeBlockMatch{text: text, codes: codes}
-}
-
-type inlineCodeMatch struct {
-	text  string
-	codes []string
-}
-
-func extractInlineCodes(text string) inlineCodeMatch {
-	matches := reInlineCode.FindAllStringSubmatch(text, -1)
-
-	codes := make([]string, 0, len(matches))
-	for _, match := range matches {
-		codes = append(codes, match[1])
-	}
-
-	i := 0
-	text = reInlineCode.ReplaceAllStringFunc(text, func(m string) string {
-		placeholder := fmt.Sprintf("\x00IC%d\x00", i)
-		i++
-		return placeholder
-	})
-
-	return inlineCodeMatch{text: text, codes: codes}
-}
-
-func escapeHTML(text string) string {
-	text = strings.ReplaceAll(text, "&", "&amp;")
-	text = strings.ReplaceAll(text, "<", "&lt;")
-	text = strings.ReplaceAll(text, ">", "&gt;")
-	return text
+	return channels.RenderMarkdown(text, channels.FormatTelegramHTML)
 }
 
 // isBotMentioned checks if the bot is mentioned in the message via entities.
@@ -364,9 +364,10 @@ func TestHandleMessage_ForumTopic_SetsMetadata(t *testing.T) {
 	// Composite chatID should include thread ID
 	assert.Equal(t, "-1001234567890/42", inbound.ChatID)
 
-	// Peer ID should include thread ID for session key isolation
+	// Peer is the group; the topic is carried as the thread for session scoping
 	assert.Equal(t, "group", inbound.Peer.Kind)
-	assert.Equal(t, "-1001234567890/42", inbound.Peer.ID)
+	assert.Equal(t, "-1001234567890", inbound.Peer.ID)
+	assert.Equal(t, "42", inbound.ThreadID)
 
 	// Parent peer metadata should be set for agent binding
 	assert.Equal(t, "topic", inbound.Metadata["parent_peer_kind"])
@@ -409,6 +410,7 @@ func TestHandleMessage_NoForum_NoThreadMetadata(t *testing.T) {
 	// Peer ID should be raw chat ID (no thread suffix)
 	assert.Equal(t, "group", inbound.Peer.Kind)
 	assert.Equal(t, "-100999", inbound.Peer.ID)
+	assert.Empty(t, inbound.ThreadID)
 
 	// No parent peer metadata
 	assert.Empty(t, inbound.Metadata["parent_peer_kind"])
@@ -460,3 +462,65 @@ func TestHandleMessage_ReplyThread_NonForum_NoIsolation(t *testing.T) {
 	assert.Empty(t, inbound.Metadata["parent_peer_kind"])
 	assert.Empty(t, inbound.Metadata["parent_peer_id"])
 }
+
+func TestSendChoices_AttachesKeyboard(t *testing.T) {
+	caller := &stubCaller{
+		callFn: func(ctx context.Context, url string, data *ta.RequestData) (*ta.Response, error) {
+			return successResponse(t), nil
+		},
+	}
+	ch := newTestChannel(t, caller)
+
+	err := ch.SendChoices(context.Background(), bus.OutboundMessage{
+		ChatID:  "12345",
+		Content: "Deploy now?",
+		Prompt:  &bus.ChoicePrompt{ID: "p1", Options: []string{"Yes", "No"}},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, caller.calls, 1)
+	assert.Contains(t, caller.calls[0].URL, "sendMessage")
+}
+
+func TestHandleCallbackQuery_PublishesChoice(t *testing.T) {
+	caller := &stubCaller{
+		callFn: func(ctx context.Context, url string, data *ta.RequestData) (*ta.Response, error) {
+			return &ta.Response{Ok: true, Result: json.RawMessage("true")}, nil
+		},
+	}
+	ch := newTestChannel(t, caller)
+	messageBus := bus.NewMessageBus()
+	ch.BaseChannel = channels.NewBaseChannel("telegram", nil, messageBus, nil)
+	ch.ctx = context.Background()
+
+	query := &telego.CallbackQuery{
+		ID:   "q1",
+		From: telego.User{ID: 7, FirstName: "Alice"},
+		Data: channels.ChoiceData("p1", 2),
+		Message: &telego.Message{
+			MessageID: 40,
+			Chat:      telego.Chat{ID: 12345, Type: "private"},
+			ReplyMarkup: &telego.InlineKeyboardMarkup{InlineKeyboard: [][]telego.InlineKeyboardButton{
+				{{Text: "Yes", CallbackData: channels.ChoiceData("p1", 1)}},
+				{{Text: "No", CallbackData: channels.ChoiceData("p1", 2)}},
+			}},
+		},
+	}
+	require.NoError(t, ch.handleCallbackQuery(context.Background(), query))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	inbound, ok := messageBus.ConsumeInbound(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "12345", inbound.ChatID)
+	assert.Equal(t, "No", inbound.Content)
+	require.NotNil(t, inbound.Choice)
+	assert.Equal(t, bus.ChoiceReply{PromptID: "p1", Option: 2}, *inbound.Choice)
+
+	var urls []string
+	for _, call := range caller.calls {
+		urls = append(urls, call.URL)
+	}
+	assert.Contains(t, strings.Join(urls, " "), "editMessageReplyMarkup")
+	assert.Contains(t, strings.Join(urls, " "), "answerCallbackQuery")
+}
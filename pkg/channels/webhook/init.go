@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func init() {
+	channels.RegisterInstanceFactory("webhook",
+		func(cfg *config.Config, b *bus.MessageBus, instance string) (channels.Channel, error) {
+			return newFromConfig(cfg, b, instance)
+		})
+}
+
+// newFromConfig creates the webhook instance with the given name.
+func newFromConfig(cfg *config.Config, b *bus.MessageBus, instance string) (*WebhookChannel, error) {
+	instances := cfg.Channels.Webhook.Instances
+	idx := -1
+	for i, inst := range instances {
+		if inst.Name == instance {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("webhook instance %q not configured", instance)
+	}
+	found := instances[idx]
+	// The shared mux panics on duplicate patterns, so the later of two
+	// instances sharing a path is refused.
+	for _, inst := range instances[:idx] {
+		if webhookPath(inst) == webhookPath(found) {
+			return nil, fmt.Errorf("webhook %q: path %s is already used by %q",
+				instance, webhookPath(found), inst.Name)
+		}
+	}
+	return NewWebhookChannel(found, b)
+}
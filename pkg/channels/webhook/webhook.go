@@ -0,0 +1,387 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultSignatureHeader = "X-Signature-256"
+	defaultReplyTimeout    = 30 // seconds
+
+	replyModeSync     = "sync"
+	replyModeCallback = "callback"
+
+	maxWebhookBodySize = 1 << 20 // 1 MiB
+)
+
+// WebhookChannel is a generic HTTP channel. Other systems POST JSON to it,
+// and the fields holding sender, chat and content are configurable. Requests
+// are authenticated with an HMAC-SHA256 signature of the body. The reply is
+// either written as the HTTP response (sync mode, bounded by ReplyTimeout)
+// or POSTed, signed the same way, to the callback URL of the request.
+type WebhookChannel struct {
+	*channels.BaseChannel
+	config config.WebhookInstanceConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	waiters   map[string][]chan string // chatID -> open sync requests, oldest first
+	callbacks map[string]string        // chatID -> callback URL of the latest request
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewWebhookChannel creates the webhook instance described by cfg.
+func NewWebhookChannel(cfg config.WebhookInstanceConfig, messageBus *bus.MessageBus) (*WebhookChannel, error) {
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("webhook %q: secret is required", cfg.Name)
+	}
+	switch cfg.ReplyMode {
+	case "":
+		cfg.ReplyMode = replyModeSync
+	case replyModeSync, replyModeCallback:
+	default:
+		return nil, fmt.Errorf("webhook %q: reply_mode must be %q or %q", cfg.Name, replyModeSync, replyModeCallback)
+	}
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = defaultSignatureHeader
+	}
+	if cfg.SenderField == "" {
+		cfg.SenderField = "sender"
+	}
+	if cfg.ChatField == "" {
+		cfg.ChatField = "chat_id"
+	}
+	if cfg.ContentField == "" {
+		cfg.ContentField = "text"
+	}
+	if cfg.MessageIDField == "" {
+		cfg.MessageIDField = "message_id"
+	}
+	if cfg.CallbackField == "" {
+		cfg.CallbackField = "callback_url"
+	}
+	if cfg.ReplyTimeout <= 0 {
+		cfg.ReplyTimeout = defaultReplyTimeout
+	}
+
+	base := channels.NewBaseChannel(channels.InstanceName("webhook", cfg.Name), cfg, messageBus, cfg.AllowFrom,
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+
+	return &WebhookChannel{
+		BaseChannel: base,
+		config:      cfg,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		waiters:     make(map[string][]chan string),
+		callbacks:   make(map[string]string),
+	}, nil
+}
+
+// webhookPath returns the mount path of an instance.
+func webhookPath(cfg config.WebhookInstanceConfig) string {
+	if cfg.Path != "" {
+		return cfg.Path
+	}
+	if cfg.Name == "" {
+		return "/webhook/custom"
+	}
+	return "/webhook/custom/" + cfg.Name
+}
+
+func (c *WebhookChannel) Start(ctx context.Context) error {
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.SetRunning(true)
+	logger.InfoCF("webhook", "Webhook channel started", map[string]any{
+		"channel":    c.Name(),
+		"path":       c.WebhookPath(),
+		"reply_mode": c.config.ReplyMode,
+	})
+	return nil
+}
+
+func (c *WebhookChannel) Stop(ctx context.Context) error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.SetRunning(false)
+	logger.InfoCF("webhook", "Webhook channel stopped", map[string]any{"channel": c.Name()})
+	return nil
+}
+
+// WebhookPath returns the path for registering on the shared HTTP server.
+func (c *WebhookChannel) WebhookPath() string {
+	return webhookPath(c.config)
+}
+
+// ServeHTTP implements http.Handler for the shared HTTP server.
+func (c *WebhookChannel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.IsRunning() {
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize+1))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxWebhookBodySize {
+		http.Error(w, "Request entity too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !verifySignature(c.config.Secret, body, r.Header.Get(c.config.SignatureHeader)) {
+		logger.WarnCF("webhook", "Invalid webhook signature", map[string]any{"channel": c.Name()})
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var payload map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		http.Error(w, "Bad request: body must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	senderID := lookupField(payload, c.config.SenderField)
+	content := lookupField(payload, c.config.ContentField)
+	if senderID == "" || strings.TrimSpace(content) == "" {
+		http.Error(w, fmt.Sprintf("Bad request: %q and %q are required", c.config.SenderField, c.config.ContentField),
+			http.StatusBadRequest)
+		return
+	}
+	chatID := lookupField(payload, c.config.ChatField)
+	if chatID == "" {
+		chatID = senderID
+	}
+	messageID := lookupField(payload, c.config.MessageIDField)
+	if messageID == "" {
+		messageID = newMessageID()
+	}
+	callbackURL := lookupField(payload, c.config.CallbackField)
+	if c.config.ReplyMode == replyModeCallback && callbackURL == "" {
+		http.Error(w, fmt.Sprintf("Bad request: %q is required", c.config.CallbackField), http.StatusBadRequest)
+		return
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "webhook",
+		PlatformID:  senderID,
+		CanonicalID: identity.BuildCanonicalID("webhook", senderID),
+	}
+	// Checked here rather than left to HandleMessage so the caller gets an
+	// answer instead of waiting for a reply that never comes.
+	if !c.IsAllowedSender(sender) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if callbackURL != "" {
+		c.mu.Lock()
+		c.callbacks[chatID] = callbackURL
+		c.mu.Unlock()
+	}
+
+	peer := bus.Peer{Kind: "direct", ID: senderID}
+	if chatID != senderID {
+		peer = bus.Peer{Kind: "group", ID: chatID}
+	}
+
+	if c.config.ReplyMode == replyModeCallback {
+		c.HandleMessage(c.ctx, peer, messageID, senderID, chatID, content, nil, nil, sender)
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "accepted", "message_id": messageID})
+		return
+	}
+
+	// Register before publishing so a fast reply cannot miss the request.
+	reply := make(chan string, 1)
+	c.mu.Lock()
+	c.waiters[chatID] = append(c.waiters[chatID], reply)
+	c.mu.Unlock()
+
+	c.HandleMessage(c.ctx, peer, messageID, senderID, chatID, content, nil, nil, sender)
+
+	timer := time.NewTimer(time.Duration(c.config.ReplyTimeout) * time.Second)
+	defer timer.Stop()
+	select {
+	case text := <-reply:
+		c.writeReply(w, chatID, messageID, text)
+		return
+	case <-timer.C:
+	case <-r.Context().Done():
+	case <-c.ctx.Done():
+	}
+	if !c.removeWaiter(chatID, reply) {
+		// Send claimed the request just before the deadline.
+		c.writeReply(w, chatID, messageID, <-reply)
+		return
+	}
+	logger.DebugCF("webhook", "Reply timed out, answering 202", map[string]any{
+		"channel":  c.Name(),
+		"chat_id":  chatID,
+		"callback": callbackURL != "",
+	})
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "accepted", "message_id": messageID})
+}
+
+func (c *WebhookChannel) writeReply(w http.ResponseWriter, chatID, messageID, text string) {
+	writeJSON(w, http.StatusOK, map[string]string{"chat_id": chatID, "reply_to": messageID, "text": text})
+}
+
+// removeWaiter drops reply from the open requests of chatID. It reports
+// false when Send has already taken it.
+func (c *WebhookChannel) removeWaiter(chatID string, reply chan string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := c.waiters[chatID]
+	for i, ch := range list {
+		if ch == reply {
+			list = append(list[:i], list[i+1:]...)
+			if len(list) == 0 {
+				delete(c.waiters, chatID)
+			} else {
+				c.waiters[chatID] = list
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Send answers the oldest open sync request of the chat, or POSTs the reply
+// to the chat's callback URL when no request is waiting.
+func (c *WebhookChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+
+	c.mu.Lock()
+	var reply chan string
+	if list := c.waiters[msg.ChatID]; len(list) > 0 {
+		reply = list[0]
+		if len(list) == 1 {
+			delete(c.waiters, msg.ChatID)
+		} else {
+			c.waiters[msg.ChatID] = list[1:]
+		}
+	}
+	callbackURL := c.callbacks[msg.ChatID]
+	c.mu.Unlock()
+
+	if reply != nil {
+		reply <- msg.Content
+		return nil
+	}
+	if callbackURL == "" {
+		return fmt.Errorf("%w: no open request or callback url for chat %s", channels.ErrSendFailed, msg.ChatID)
+	}
+	return c.postCallback(ctx, callbackURL, msg)
+}
+
+// postCallback delivers a reply to a callback URL, signed with the secret.
+func (c *WebhookChannel) postCallback(ctx context.Context, callbackURL string, msg bus.OutboundMessage) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id":  msg.ChatID,
+		"reply_to": msg.ReplyToMessageID,
+		"text":     msg.Content,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", channels.ErrSendFailed, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(c.config.SignatureHeader, "sha256="+sign(c.config.Secret, body))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return channels.ClassifyNetError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return channels.ClassifySendError(resp.StatusCode,
+			fmt.Errorf("webhook callback: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data))))
+	}
+	return nil
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks a hex HMAC-SHA256 of body, with or without the
+// "sha256=" prefix used by GitHub-style webhooks.
+func verifySignature(secret string, body []byte, signature string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	want, _ := hex.DecodeString(sign(secret, body))
+	return hmac.Equal(got, want)
+}
+
+// lookupField resolves a dot-separated path such as "user.id" in a decoded
+// JSON object. Numbers and booleans are formatted; objects, arrays and
+// missing fields yield "".
+func lookupField(payload map[string]any, path string) string {
+	var v any = payload
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+		v = obj[key]
+	}
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return fmt.Sprint(val)
+	default:
+		return ""
+	}
+}
+
+func newMessageID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,201 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const testSecret = "s3cret"
+
+func startChannel(t *testing.T, cfg config.WebhookInstanceConfig) (*WebhookChannel, *bus.MessageBus, *httptest.Server) {
+	t.Helper()
+	mb := bus.NewMessageBus()
+	cfg.Secret = testSecret
+	ch, err := NewWebhookChannel(cfg, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ch.Stop(context.Background()) })
+	mux := http.NewServeMux()
+	mux.Handle(ch.WebhookPath(), ch)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return ch, mb, srv
+}
+
+func post(t *testing.T, url, body, signature string) (int, map[string]string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set(defaultSignatureHeader, signature)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out map[string]string
+	data, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(data, &out)
+	return resp.StatusCode, out
+}
+
+func nextInbound(t *testing.T, mb *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func TestWebhookChannel_SyncReply(t *testing.T) {
+	ch, mb, srv := startChannel(t, config.WebhookInstanceConfig{
+		Name:         "tickets",
+		SenderField:  "user.id",
+		ChatField:    "ticket",
+		ContentField: "body",
+		AllowFrom:    config.FlexibleStringSlice{"42"},
+	})
+	if ch.Name() != "webhook_tickets" || ch.WebhookPath() != "/webhook/custom/tickets" {
+		t.Fatalf("name %q, path %q", ch.Name(), ch.WebhookPath())
+	}
+	url := srv.URL + ch.WebhookPath()
+
+	body := `{"user":{"id":42},"ticket":"T-7","body":"printer is on fire","message_id":"m1"}`
+	if status, _ := post(t, url, body, "sha256="+sign("wrong", []byte(body))); status != http.StatusForbidden {
+		t.Errorf("bad signature: status %d", status)
+	}
+	denied := `{"user":{"id":7},"ticket":"T-7","body":"hi"}`
+	if status, _ := post(t, url, denied, sign(testSecret, []byte(denied))); status != http.StatusForbidden {
+		t.Errorf("sender not allowed: status %d", status)
+	}
+	missing := `{"user":{"id":42},"ticket":"T-7"}`
+	if status, _ := post(t, url, missing, sign(testSecret, []byte(missing))); status != http.StatusBadRequest {
+		t.Errorf("missing content: status %d", status)
+	}
+
+	go func() {
+		in := nextInbound(t, mb)
+		if in.Channel != "webhook_tickets" || in.ChatID != "T-7" || in.SenderID != "webhook:42" ||
+			in.Content != "printer is on fire" || in.MessageID != "m1" || in.Peer.Kind != "group" {
+			t.Errorf("inbound %+v", in)
+		}
+		ch.Send(context.Background(), bus.OutboundMessage{ChatID: in.ChatID, Content: "Have you tried water?"})
+	}()
+	status, reply := post(t, url, body, "sha256="+sign(testSecret, []byte(body)))
+	if status != http.StatusOK || reply["text"] != "Have you tried water?" || reply["chat_id"] != "T-7" ||
+		reply["reply_to"] != "m1" {
+		t.Errorf("sync reply: status %d, body %v", status, reply)
+	}
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "T-7", Content: "late"})
+	if err == nil || !errors.Is(err, channels.ErrSendFailed) {
+		t.Errorf("send without request or callback: %v", err)
+	}
+}
+
+func TestWebhookChannel_TimeoutFallsBackToCallback(t *testing.T) {
+	callbacks := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	cb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		callbacks <- r
+		bodies <- data
+	}))
+	defer cb.Close()
+
+	ch, mb, srv := startChannel(t, config.WebhookInstanceConfig{ReplyTimeout: 1})
+	body := `{"sender":"alice","text":"slow question","callback_url":"` + cb.URL + `"}`
+	status, resp := post(t, srv.URL+"/webhook/custom", body, sign(testSecret, []byte(body)))
+	if status != http.StatusAccepted || resp["message_id"] == "" {
+		t.Fatalf("timeout: status %d, body %v", status, resp)
+	}
+
+	in := nextInbound(t, mb)
+	if in.Channel != "webhook" || in.ChatID != "alice" || in.Peer.Kind != "direct" {
+		t.Errorf("inbound %+v", in)
+	}
+	if err := ch.Send(context.Background(), bus.OutboundMessage{
+		ChatID: in.ChatID, Content: "slow answer", ReplyToMessageID: in.MessageID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r := <-callbacks
+	data := <-bodies
+	if !verifySignature(testSecret, data, r.Header.Get(defaultSignatureHeader)) {
+		t.Errorf("callback signature %q does not verify", r.Header.Get(defaultSignatureHeader))
+	}
+	var got map[string]string
+	json.Unmarshal(data, &got)
+	if got["text"] != "slow answer" || got["chat_id"] != "alice" || got["reply_to"] != in.MessageID {
+		t.Errorf("callback body %v", got)
+	}
+}
+
+func TestWebhookChannel_CallbackMode(t *testing.T) {
+	_, _, srv := startChannel(t, config.WebhookInstanceConfig{Name: "ops", ReplyMode: "callback"})
+	url := srv.URL + "/webhook/custom/ops"
+
+	body := `{"sender":"bob","text":"hi"}`
+	if status, _ := post(t, url, body, sign(testSecret, []byte(body))); status != http.StatusBadRequest {
+		t.Errorf("callback mode without callback url: status %d", status)
+	}
+	body = `{"sender":"bob","text":"hi","callback_url":"http://127.0.0.1:1/cb"}`
+	if status, _ := post(t, url, body, sign(testSecret, []byte(body))); status != http.StatusAccepted {
+		t.Errorf("callback mode: status %d", status)
+	}
+}
+
+func TestLookupField(t *testing.T) {
+	payload := map[string]any{
+		"a":    map[string]any{"b": json.Number("12345678901234567890"), "c": true},
+		"s":    "x",
+		"list": []any{"y"},
+	}
+	cases := map[string]string{"a.b": "12345678901234567890", "a.c": "true", "s": "x", "list": "", "a.z": "", "s.x": ""}
+	for path, want := range cases {
+		if got := lookupField(payload, path); got != want {
+			t.Errorf("lookupField(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestWebhookFactory(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Channels.Webhook.Instances = []config.WebhookInstanceConfig{
+		{Name: "a", Secret: "x"},
+		{Name: "b", Secret: "x", Path: "/webhook/custom/a"},
+		{Name: "c", Secret: "x", ReplyMode: "push"},
+	}
+	mb := bus.NewMessageBus()
+	f := func(instance string) error {
+		_, err := newFromConfig(cfg, mb, instance)
+		return err
+	}
+	if err := f("a"); err != nil {
+		t.Errorf("instance a: %v", err)
+	}
+	for _, name := range []string{"b", "c", "missing"} {
+		if err := f(name); err == nil {
+			t.Errorf("instance %s: expected an error", name)
+		}
+	}
+	if _, err := NewWebhookChannel(config.WebhookInstanceConfig{}, mb); err == nil {
+		t.Error("expected an error without secret")
+	}
+}
@@ -0,0 +1,627 @@
+package xmpp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	nsStream     = "http://etherx.jabber.org/streams"
+	nsTLS        = "urn:ietf:params:xml:ns:xmpp-tls"
+	nsSASL       = "urn:ietf:params:xml:ns:xmpp-sasl"
+	nsBind       = "urn:ietf:params:xml:ns:xmpp-bind"
+	nsSession    = "urn:ietf:params:xml:ns:xmpp-session"
+	nsStanzas    = "urn:ietf:params:xml:ns:xmpp-stanzas"
+	nsMUC        = "http://jabber.org/protocol/muc"
+	nsMUCUser    = "http://jabber.org/protocol/muc#user"
+	nsChatStates = "http://jabber.org/protocol/chatstates"
+	nsCorrect    = "urn:xmpp:message-correct:0"
+	nsOOB        = "jabber:x:oob"
+	nsDelay      = "urn:xmpp:delay"
+	nsHints      = "urn:xmpp:hints"
+	nsUpload     = "urn:xmpp:http:upload:0"
+	nsDiscoInfo  = "http://jabber.org/protocol/disco#info"
+	nsDiscoItems = "http://jabber.org/protocol/disco#items"
+	nsPing       = "urn:xmpp:ping"
+)
+
+var errStreamClosed = errors.New("xmpp: stream closed by server")
+
+type streamFeatures struct {
+	StartTLS   *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-tls starttls"`
+	Mechanisms *struct {
+		Mechanism []string `xml:"mechanism"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-sasl mechanisms"`
+	Bind    *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+	Session *struct {
+		Optional *struct{} `xml:"optional"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-session session"`
+}
+
+type stanzaError struct {
+	Type  string `xml:"type,attr"`
+	Inner []byte `xml:",innerxml"`
+}
+
+func (e *stanzaError) Error() string {
+	return fmt.Sprintf("xmpp %s error: %s", e.Type, firstElementName(e.Inner))
+}
+
+type iqStanza struct {
+	ID    string       `xml:"id,attr"`
+	Type  string       `xml:"type,attr"`
+	From  string       `xml:"from,attr"`
+	To    string       `xml:"to,attr"`
+	Error *stanzaError `xml:"error"`
+	Inner []byte       `xml:",innerxml"`
+}
+
+type messageStanza struct {
+	ID      string    `xml:"id,attr"`
+	Type    string    `xml:"type,attr"`
+	From    string    `xml:"from,attr"`
+	To      string    `xml:"to,attr"`
+	Body    string    `xml:"body"`
+	Subject *struct{} `xml:"subject"`
+	Replace *struct {
+		ID string `xml:"id,attr"`
+	} `xml:"urn:xmpp:message-correct:0 replace"`
+	Delay *struct{} `xml:"urn:xmpp:delay delay"`
+	OOB   []struct {
+		URL string `xml:"url"`
+	} `xml:"jabber:x:oob x"`
+	Error *stanzaError `xml:"error"`
+}
+
+type presenceStanza struct {
+	Type    string `xml:"type,attr"`
+	From    string `xml:"from,attr"`
+	MUCUser *struct {
+		Items []struct {
+			JID string `xml:"jid,attr"`
+		} `xml:"item"`
+		Statuses []struct {
+			Code string `xml:"code,attr"`
+		} `xml:"status"`
+	} `xml:"http://jabber.org/protocol/muc#user x"`
+	Error *stanzaError `xml:"error"`
+}
+
+// session is an authenticated, resource-bound client stream.
+type session struct {
+	conn net.Conn
+	dec  *xml.Decoder
+	jid  string // full JID assigned by the server
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *iqStanza
+	closed  bool
+}
+
+type dialOptions struct {
+	jid       string
+	password  string
+	resource  string
+	server    string // host:port; empty to look up SRV records
+	directTLS bool
+	tls       *tls.Config
+}
+
+// dial connects, secures and authenticates a stream.
+func dial(ctx context.Context, opts dialOptions) (*session, error) {
+	local, domain, _ := splitJID(opts.jid)
+	addr := opts.server
+	if addr == "" {
+		addr = lookupServer(ctx, domain, opts.directTLS)
+	}
+	conn, err := (&net.Dialer{Timeout: 30 * time.Second}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := opts.tls.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = domain
+	}
+	s := &session{conn: conn, pending: make(map[string]chan *iqStanza)}
+	// Setup is bounded as a whole; the read loop clears the deadline.
+	_ = conn.SetDeadline(time.Now().Add(60 * time.Second))
+
+	fail := func(err error) (*session, error) {
+		conn.Close()
+		return nil, err
+	}
+	if opts.directTLS {
+		s.conn = tls.Client(conn, tlsConfig)
+	}
+	features, err := s.openStream(domain)
+	if err != nil {
+		return fail(err)
+	}
+	if !opts.directTLS {
+		if features.StartTLS == nil {
+			return fail(errors.New("xmpp: server does not offer STARTTLS"))
+		}
+		if err := s.write("<starttls xmlns='" + nsTLS + "'/>"); err != nil {
+			return fail(err)
+		}
+		el, err := s.nextElement()
+		if err != nil {
+			return fail(err)
+		}
+		if el.Name.Local != "proceed" {
+			return fail(fmt.Errorf("xmpp: STARTTLS refused (%s)", el.Name.Local))
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fail(err)
+		}
+		s.conn = tlsConn
+		if features, err = s.openStream(domain); err != nil {
+			return fail(err)
+		}
+	}
+
+	var mechanisms []string
+	if features.Mechanisms != nil {
+		mechanisms = features.Mechanisms.Mechanism
+	}
+	if err := s.authenticate(mechanisms, local, opts.password); err != nil {
+		return fail(err)
+	}
+	if features, err = s.openStream(domain); err != nil {
+		return fail(err)
+	}
+	if features.Bind == nil {
+		return fail(errors.New("xmpp: server does not offer resource binding"))
+	}
+	if err := s.bind(opts.resource); err != nil {
+		return fail(err)
+	}
+	if features.Session != nil && features.Session.Optional == nil {
+		// RFC 3921 servers still require a session.
+		if _, err := s.setupIQ("set", "", "<session xmlns='"+nsSession+"'/>"); err != nil {
+			return fail(err)
+		}
+	}
+	_ = s.conn.SetDeadline(time.Time{})
+	return s, nil
+}
+
+// lookupServer resolves the client SRV record of a domain, falling back to
+// the domain on the standard port.
+func lookupServer(ctx context.Context, domain string, directTLS bool) string {
+	service, port := "xmpp-client", "5222"
+	if directTLS {
+		service, port = "xmpps-client", "5223"
+	}
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, service, "tcp", domain)
+	if err == nil && len(records) > 0 && records[0].Target != "." {
+		return net.JoinHostPort(strings.TrimSuffix(records[0].Target, "."), strconv.Itoa(int(records[0].Port)))
+	}
+	return net.JoinHostPort(domain, port)
+}
+
+// openStream (re)starts the stream and returns the advertised features.
+func (s *session) openStream(domain string) (*streamFeatures, error) {
+	err := s.write("<?xml version='1.0'?><stream:stream to='" + escape(domain) +
+		"' xmlns='jabber:client' xmlns:stream='" + nsStream + "' version='1.0'>")
+	if err != nil {
+		return nil, err
+	}
+	s.dec = xml.NewDecoder(s.conn)
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			if se.Name.Space != nsStream || se.Name.Local != "stream" {
+				return nil, fmt.Errorf("xmpp: unexpected <%s> instead of stream header", se.Name.Local)
+			}
+			break
+		}
+	}
+	el, err := s.nextElement()
+	if err != nil {
+		return nil, err
+	}
+	if el.Name.Space != nsStream || el.Name.Local != "features" {
+		return nil, fmt.Errorf("xmpp: expected stream features, got <%s>", el.Name.Local)
+	}
+	var features streamFeatures
+	if err := s.dec.DecodeElement(&features, &el); err != nil {
+		return nil, err
+	}
+	return &features, nil
+}
+
+// nextElement returns the start of the next top-level element. Stream
+// errors and the end of the stream are returned as errors.
+func (s *session) nextElement() (xml.StartElement, error) {
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == nsStream && t.Name.Local == "error" {
+				var se struct {
+					Inner []byte `xml:",innerxml"`
+				}
+				_ = s.dec.DecodeElement(&se, &t)
+				return xml.StartElement{}, fmt.Errorf("xmpp stream error: %s", firstElementName(se.Inner))
+			}
+			return t, nil
+		case xml.EndElement:
+			if t.Name.Space == nsStream && t.Name.Local == "stream" {
+				return xml.StartElement{}, errStreamClosed
+			}
+		}
+	}
+}
+
+type saslElement struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+	Inner   []byte `xml:",innerxml"`
+}
+
+func (s *session) readSASL() (saslElement, error) {
+	el, err := s.nextElement()
+	if err != nil {
+		return saslElement{}, err
+	}
+	var resp saslElement
+	if err := s.dec.DecodeElement(&resp, &el); err != nil {
+		return saslElement{}, err
+	}
+	if resp.XMLName.Local == "failure" {
+		return resp, fmt.Errorf("xmpp authentication failed: %s", firstElementName(resp.Inner))
+	}
+	return resp, nil
+}
+
+// authenticate runs the strongest SASL mechanism both sides support.
+func (s *session) authenticate(mechanisms []string, username, password string) error {
+	offered := make(map[string]bool, len(mechanisms))
+	for _, m := range mechanisms {
+		offered[m] = true
+	}
+	for _, mech := range []string{"SCRAM-SHA-256", "SCRAM-SHA-1"} {
+		if offered[mech] {
+			return s.authSCRAM(mech, username, password)
+		}
+	}
+	if !offered["PLAIN"] {
+		return fmt.Errorf("xmpp: no supported SASL mechanism in %v", mechanisms)
+	}
+	// The stream is always encrypted by now.
+	creds := base64.StdEncoding.EncodeToString([]byte("\x00" + username + "\x00" + password))
+	if err := s.write("<auth xmlns='" + nsSASL + "' mechanism='PLAIN'>" + creds + "</auth>"); err != nil {
+		return err
+	}
+	resp, err := s.readSASL()
+	if err != nil {
+		return err
+	}
+	if resp.XMLName.Local != "success" {
+		return fmt.Errorf("xmpp: unexpected <%s> during authentication", resp.XMLName.Local)
+	}
+	return nil
+}
+
+func (s *session) authSCRAM(mechanism, username, password string) error {
+	sc, err := newSCRAM(mechanism, username, password)
+	if err != nil {
+		return err
+	}
+	first := base64.StdEncoding.EncodeToString([]byte(sc.clientFirst()))
+	if err := s.write("<auth xmlns='" + nsSASL + "' mechanism='" + mechanism + "'>" + first + "</auth>"); err != nil {
+		return err
+	}
+	resp, err := s.readSASL()
+	if err != nil {
+		return err
+	}
+	if resp.XMLName.Local != "challenge" {
+		return fmt.Errorf("xmpp: unexpected <%s> during authentication", resp.XMLName.Local)
+	}
+	serverFirst, err := base64.StdEncoding.DecodeString(strings.TrimSpace(resp.Text))
+	if err != nil {
+		return err
+	}
+	final, err := sc.clientFinal(string(serverFirst))
+	if err != nil {
+		return err
+	}
+	if err := s.write("<response xmlns='" + nsSASL + "'>" +
+		base64.StdEncoding.EncodeToString([]byte(final)) + "</response>"); err != nil {
+		return err
+	}
+	if resp, err = s.readSASL(); err != nil {
+		return err
+	}
+	if resp.XMLName.Local == "challenge" {
+		// Server-final sent as a challenge rather than success data.
+		if err := s.write("<response xmlns='" + nsSASL + "'/>"); err != nil {
+			return err
+		}
+		text := resp.Text
+		if resp, err = s.readSASL(); err != nil {
+			return err
+		}
+		resp.Text = text
+	}
+	if resp.XMLName.Local != "success" {
+		return fmt.Errorf("xmpp: unexpected <%s> during authentication", resp.XMLName.Local)
+	}
+	serverFinal, err := base64.StdEncoding.DecodeString(strings.TrimSpace(resp.Text))
+	if err != nil {
+		return err
+	}
+	return sc.verifyServerFinal(string(serverFinal))
+}
+
+func (s *session) bind(resource string) error {
+	payload := "<bind xmlns='" + nsBind + "'/>"
+	if resource != "" {
+		payload = "<bind xmlns='" + nsBind + "'><resource>" + escape(resource) + "</resource></bind>"
+	}
+	iq, err := s.setupIQ("set", "", payload)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		JID string `xml:"jid"`
+	}
+	if err := xml.Unmarshal(iq.Inner, &result); err != nil || result.JID == "" {
+		return fmt.Errorf("xmpp: bind returned no JID")
+	}
+	s.jid = result.JID
+	return nil
+}
+
+// setupIQ sends an IQ during stream setup, before the read loop runs, and
+// waits for its response.
+func (s *session) setupIQ(typ, to, payload string) (*iqStanza, error) {
+	id := newID()
+	if err := s.write(iqXML(id, typ, to, payload)); err != nil {
+		return nil, err
+	}
+	for {
+		el, err := s.nextElement()
+		if err != nil {
+			return nil, err
+		}
+		if el.Name.Local != "iq" {
+			_ = s.dec.Skip()
+			continue
+		}
+		var iq iqStanza
+		if err := s.dec.DecodeElement(&iq, &el); err != nil {
+			return nil, err
+		}
+		if iq.ID != id {
+			continue
+		}
+		if iq.Type == "error" {
+			return nil, iq.errorOrDefault()
+		}
+		return &iq, nil
+	}
+}
+
+func (iq *iqStanza) errorOrDefault() error {
+	if iq.Error != nil {
+		return iq.Error
+	}
+	return errors.New("xmpp: iq error")
+}
+
+func iqXML(id, typ, to, payload string) string {
+	var b strings.Builder
+	b.WriteString("<iq id='" + id + "' type='" + typ + "'")
+	if to != "" {
+		b.WriteString(" to='" + escape(to) + "'")
+	}
+	b.WriteString(">" + payload + "</iq>")
+	return b.String()
+}
+
+// write sends raw XML. It is safe for concurrent use.
+func (s *session) write(data string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_ = s.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := io.WriteString(s.conn, data)
+	return err
+}
+
+// iq sends an IQ request and waits for the response. Only valid while
+// readLoop runs.
+func (s *session) iq(ctx context.Context, typ, to, payload string) (*iqStanza, error) {
+	id := newID()
+	reply := make(chan *iqStanza, 1)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, errStreamClosed
+	}
+	s.pending[id] = reply
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	if err := s.write(iqXML(id, typ, to, payload)); err != nil {
+		return nil, err
+	}
+	select {
+	case iq, ok := <-reply:
+		if !ok {
+			return nil, errStreamClosed
+		}
+		if iq.Type == "error" {
+			return nil, iq.errorOrDefault()
+		}
+		return iq, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type stanzaHandlers struct {
+	message  func(*messageStanza)
+	presence func(*presenceStanza)
+	// request answers an IQ get or set with the result payload, or
+	// ok=false for service-unavailable.
+	request func(*iqStanza, string) (payload string, ok bool)
+}
+
+// readLoop dispatches incoming stanzas until the stream fails.
+func (s *session) readLoop(h stanzaHandlers) error {
+	defer s.shutdown()
+	for {
+		el, err := s.nextElement()
+		if err != nil {
+			return err
+		}
+		switch el.Name.Local {
+		case "message":
+			var m messageStanza
+			if err := s.dec.DecodeElement(&m, &el); err != nil {
+				return err
+			}
+			h.message(&m)
+		case "presence":
+			var p presenceStanza
+			if err := s.dec.DecodeElement(&p, &el); err != nil {
+				return err
+			}
+			h.presence(&p)
+		case "iq":
+			var iq iqStanza
+			if err := s.dec.DecodeElement(&iq, &el); err != nil {
+				return err
+			}
+			s.handleIQ(&iq, h)
+		default:
+			if err := s.dec.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *session) handleIQ(iq *iqStanza, h stanzaHandlers) {
+	switch iq.Type {
+	case "result", "error":
+		s.mu.Lock()
+		reply := s.pending[iq.ID]
+		delete(s.pending, iq.ID)
+		s.mu.Unlock()
+		if reply != nil {
+			reply <- iq
+		}
+	case "get", "set":
+		payload, ok := h.request(iq, firstElementNamespace(iq.Inner))
+		var resp string
+		if ok {
+			resp = "<iq id='" + escape(iq.ID) + "' type='result' to='" + escape(iq.From) + "'>" + payload + "</iq>"
+		} else {
+			resp = "<iq id='" + escape(iq.ID) + "' type='error' to='" + escape(iq.From) + "'>" +
+				"<error type='cancel'><service-unavailable xmlns='" + nsStanzas + "'/></error></iq>"
+		}
+		_ = s.write(resp)
+	}
+}
+
+// shutdown fails pending IQs and closes the connection.
+func (s *session) shutdown() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		for id, ch := range s.pending {
+			close(ch)
+			delete(s.pending, id)
+		}
+	}
+	s.mu.Unlock()
+	s.conn.Close()
+}
+
+// close ends the stream politely.
+func (s *session) close() {
+	_ = s.write("<presence type='unavailable'/></stream:stream>")
+	s.shutdown()
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// escape escapes text for use in XML character data and quoted attributes.
+func escape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// firstElementName returns the local name of the first element in an XML
+// fragment, e.g. the condition of an error.
+func firstElementName(fragment []byte) string {
+	name, _ := firstElement(fragment)
+	return name
+}
+
+func firstElementNamespace(fragment []byte) string {
+	_, ns := firstElement(fragment)
+	return ns
+}
+
+func firstElement(fragment []byte) (local, space string) {
+	dec := xml.NewDecoder(bytes.NewReader(fragment))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", ""
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local, se.Name.Space
+		}
+	}
+}
+
+// splitJID splits "local@domain/resource".
+func splitJID(jid string) (local, domain, resource string) {
+	bare, resource, _ := strings.Cut(jid, "/")
+	if at := strings.IndexByte(bare, '@'); at >= 0 {
+		return bare[:at], bare[at+1:], resource
+	}
+	return "", bare, resource
+}
+
+func bareJID(jid string) string {
+	bare, _, _ := strings.Cut(jid, "/")
+	return bare
+}
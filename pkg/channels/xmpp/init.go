@@ -0,0 +1,13 @@
+package xmpp
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func init() {
+	channels.RegisterFactory("xmpp", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
+		return NewXMPPChannel(cfg.Channels.XMPP, b)
+	})
+}
@@ -0,0 +1,122 @@
+package xmpp
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// scram is the client side of a SCRAM exchange (RFC 5802) without channel
+// binding.
+type scram struct {
+	newHash  func() hash.Hash
+	username string
+	password string
+	nonce    string
+
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newSCRAM(mechanism, username, password string) (*scram, error) {
+	s := &scram{username: username, password: password}
+	switch mechanism {
+	case "SCRAM-SHA-256":
+		s.newHash = sha256.New
+	case "SCRAM-SHA-1":
+		s.newHash = sha1.New
+	default:
+		return nil, fmt.Errorf("unsupported mechanism %s", mechanism)
+	}
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	s.nonce = base64.RawStdEncoding.EncodeToString(b)
+	return s, nil
+}
+
+// saslName escapes a username for the n= attribute.
+func saslName(name string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(name)
+}
+
+// clientFirst returns the initial client message.
+func (s *scram) clientFirst() string {
+	s.clientFirstBare = "n=" + saslName(s.username) + ",r=" + s.nonce
+	return "n,," + s.clientFirstBare
+}
+
+// clientFinal answers the server-first message.
+func (s *scram) clientFinal(serverFirst string) (string, error) {
+	attrs := parseSCRAMAttrs(serverFirst)
+	nonce, salt64, iterStr := attrs["r"], attrs["s"], attrs["i"]
+	if !strings.HasPrefix(nonce, s.nonce) || len(nonce) == len(s.nonce) {
+		return "", errors.New("scram: server nonce does not extend the client nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil {
+		return "", fmt.Errorf("scram: bad salt: %w", err)
+	}
+	iterations, err := strconv.Atoi(iterStr)
+	if err != nil || iterations < 1 {
+		return "", fmt.Errorf("scram: bad iteration count %q", iterStr)
+	}
+
+	salted, err := pbkdf2.Key(s.newHash, s.password, salt, iterations, s.newHash().Size())
+	if err != nil {
+		return "", err
+	}
+	clientKey := s.hmac(salted, "Client Key")
+	h := s.newHash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	withoutProof := "c=biws,r=" + nonce // biws = base64("n,,")
+	authMessage := s.clientFirstBare + "," + serverFirst + "," + withoutProof
+	signature := s.hmac(storedKey, authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ signature[i]
+	}
+	s.serverSignature = s.hmac(s.hmac(salted, "Server Key"), authMessage)
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verifyServerFinal checks the server's signature, which proves that the
+// server knows the password too.
+func (s *scram) verifyServerFinal(serverFinal string) error {
+	attrs := parseSCRAMAttrs(serverFinal)
+	if e := attrs["e"]; e != "" {
+		return fmt.Errorf("scram: server error %s", e)
+	}
+	got, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(got, s.serverSignature) {
+		return errors.New("scram: invalid server signature")
+	}
+	return nil
+}
+
+func (s *scram) hmac(key []byte, msg string) []byte {
+	m := hmac.New(s.newHash, key)
+	m.Write([]byte(msg))
+	return m.Sum(nil)
+}
+
+func parseSCRAMAttrs(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(msg, ",") {
+		if k, v, ok := strings.Cut(part, "="); ok && len(k) == 1 {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,61 @@
+package xmpp
+
+import "testing"
+
+// Test vectors from RFC 5802 (SHA-1) and RFC 7677 (SHA-256).
+func TestSCRAM_RFCVectors(t *testing.T) {
+	cases := []struct {
+		mechanism   string
+		nonce       string
+		serverFirst string
+		clientFinal string
+		serverFinal string
+	}{
+		{
+			mechanism:   "SCRAM-SHA-1",
+			nonce:       "fyko+d2lbbFgONRv9qkxdawL",
+			serverFirst: "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+			clientFinal: "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+			serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+		},
+		{
+			mechanism:   "SCRAM-SHA-256",
+			nonce:       "rOprNGfwEbeRWgbNEkqO",
+			serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0," +
+				"p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+			serverFinal: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+		},
+	}
+	for _, tc := range cases {
+		s, err := newSCRAM(tc.mechanism, "user", "pencil")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.nonce = tc.nonce
+		if got, want := s.clientFirst(), "n,,n=user,r="+tc.nonce; got != want {
+			t.Errorf("%s client-first = %q, want %q", tc.mechanism, got, want)
+		}
+		final, err := s.clientFinal(tc.serverFirst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if final != tc.clientFinal {
+			t.Errorf("%s client-final = %q, want %q", tc.mechanism, final, tc.clientFinal)
+		}
+		if err := s.verifyServerFinal(tc.serverFinal); err != nil {
+			t.Errorf("%s: %v", tc.mechanism, err)
+		}
+		if err := s.verifyServerFinal("v=AAAA"); err == nil {
+			t.Errorf("%s: forged server signature accepted", tc.mechanism)
+		}
+	}
+}
+
+func TestSCRAM_RejectsForeignNonce(t *testing.T) {
+	s, _ := newSCRAM("SCRAM-SHA-256", "user", "pencil")
+	s.clientFirst()
+	if _, err := s.clientFinal("r=somebodyelse,s=QSXCR+Q6sek8bf92,i=4096"); err == nil {
+		t.Error("expected an error for a nonce that does not extend ours")
+	}
+}
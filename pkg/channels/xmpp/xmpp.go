@@ -0,0 +1,747 @@
+package xmpp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	maxBackoff         = time.Minute
+	keepaliveInterval  = 60 * time.Second
+	defaultResource    = "picoclaw"
+	defaultPlaceholder = "Thinking... 💭"
+)
+
+// XMPPChannel connects to an XMPP server as a client. It answers 1:1 chats
+// and joins multi-user chat rooms (XEP-0045).
+//
+// Chat IDs are the bare JID of the contact, the bare JID of a room, or the
+// full occupant JID ("room@service/nick") for private messages inside a room.
+type XMPPChannel struct {
+	*channels.BaseChannel
+	config config.XMPPConfig
+	domain string
+	nick   string
+
+	tlsConfig  *tls.Config
+	httpClient *http.Client
+
+	mu        sync.RWMutex
+	sess      *session
+	rooms     map[string]string // room JID → our nick there
+	occupants map[string]string // occupant JID → real bare JID, where the room reveals it
+	uploadSvc string            // discovered XEP-0363 service
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewXMPPChannel creates an XMPP channel from its config.
+func NewXMPPChannel(cfg config.XMPPConfig, messageBus *bus.MessageBus) (*XMPPChannel, error) {
+	local, domain, _ := splitJID(cfg.JID)
+	if local == "" || domain == "" {
+		return nil, fmt.Errorf("xmpp jid %q must have the form user@domain", cfg.JID)
+	}
+	if cfg.Password == "" {
+		return nil, fmt.Errorf("xmpp password is required")
+	}
+
+	nick := cfg.Nick
+	if nick == "" {
+		nick = local
+	}
+	rooms := make(map[string]string, len(cfg.Rooms))
+	for _, r := range cfg.Rooms {
+		if r.JID == "" {
+			continue
+		}
+		roomNick := r.Nick
+		if roomNick == "" {
+			roomNick = nick
+		}
+		rooms[bareJID(r.JID)] = roomNick
+	}
+
+	base := channels.NewBaseChannel("xmpp", cfg, messageBus, cfg.AllowFrom,
+		channels.WithGroupTrigger(cfg.GroupTrigger),
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+
+	return &XMPPChannel{
+		BaseChannel: base,
+		config:      cfg,
+		domain:      domain,
+		nick:        nick,
+		tlsConfig:   &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify},
+		httpClient:  &http.Client{Timeout: 2 * time.Minute},
+		rooms:       rooms,
+		occupants:   make(map[string]string),
+		uploadSvc:   cfg.UploadService,
+	}, nil
+}
+
+// Start connects to the server. The first connection must succeed so that
+// bad credentials surface at startup; later drops reconnect with backoff.
+func (c *XMPPChannel) Start(ctx context.Context) error {
+	logger.InfoC("xmpp", "Starting XMPP channel")
+
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	sess, err := c.connect()
+	if err != nil {
+		c.cancel()
+		return fmt.Errorf("xmpp connect: %w", err)
+	}
+
+	c.wg.Add(1)
+	go c.run(sess)
+
+	c.SetRunning(true)
+	logger.InfoCF("xmpp", "XMPP channel started", map[string]any{
+		"jid":   sess.jid,
+		"rooms": len(c.rooms),
+	})
+	return nil
+}
+
+// Stop leaves the rooms and closes the stream.
+func (c *XMPPChannel) Stop(ctx context.Context) error {
+	logger.InfoC("xmpp", "Stopping XMPP channel")
+	c.SetRunning(false)
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+	logger.InfoC("xmpp", "XMPP channel stopped")
+	return nil
+}
+
+func (c *XMPPChannel) connect() (*session, error) {
+	dialCtx, cancel := context.WithTimeout(c.ctx, time.Minute)
+	defer cancel()
+	resource := c.config.Resource
+	if resource == "" {
+		resource = defaultResource
+	}
+	return dial(dialCtx, dialOptions{
+		jid:       bareJID(c.config.JID),
+		password:  c.config.Password,
+		resource:  resource,
+		server:    c.config.Server,
+		directTLS: c.config.DirectTLS,
+		tls:       c.tlsConfig,
+	})
+}
+
+// run serves sessions until the channel stops, reconnecting with backoff.
+func (c *XMPPChannel) run(sess *session) {
+	defer c.wg.Done()
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := c.serve(sess)
+		if c.ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > maxBackoff {
+			backoff = time.Second
+		}
+		for {
+			logger.WarnCF("xmpp", "Connection lost, reconnecting", map[string]any{
+				"error":   fmt.Sprint(err),
+				"backoff": backoff.String(),
+			})
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			if sess, err = c.connect(); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// serve announces presence, joins the rooms and reads the stream until it
+// fails or the channel stops.
+func (c *XMPPChannel) serve(sess *session) error {
+	c.mu.Lock()
+	c.sess = sess
+	clear(c.occupants)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.sess == sess {
+			c.sess = nil
+		}
+		c.mu.Unlock()
+	}()
+
+	stop := context.AfterFunc(c.ctx, sess.close)
+	defer stop()
+	go c.keepalive(sess)
+
+	if err := sess.write("<presence/>"); err != nil {
+		return err
+	}
+	for _, r := range c.config.Rooms {
+		if r.JID == "" {
+			continue
+		}
+		if err := sess.write(joinXML(bareJID(r.JID), c.roomNick(bareJID(r.JID)), r.Password)); err != nil {
+			return err
+		}
+	}
+
+	return sess.readLoop(stanzaHandlers{
+		message:  c.handleMessage,
+		presence: c.handlePresence,
+		request:  c.handleRequest,
+	})
+}
+
+// keepalive sends whitespace so that idle connections are not dropped by
+// NATs, and so that a dead connection is noticed.
+func (c *XMPPChannel) keepalive(sess *session) {
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := sess.write(" "); err != nil {
+			sess.shutdown()
+			return
+		}
+	}
+}
+
+func joinXML(room, nick, password string) string {
+	x := "<history maxstanzas='0'/>"
+	if password != "" {
+		x += "<password>" + escape(password) + "</password>"
+	}
+	return "<presence to='" + escape(room+"/"+nick) + "'><x xmlns='" + nsMUC + "'>" + x + "</x></presence>"
+}
+
+func (c *XMPPChannel) roomNick(room string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.rooms[room]
+}
+
+func (c *XMPPChannel) isRoom(jid string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.rooms[jid]
+	return ok
+}
+
+func (c *XMPPChannel) handlePresence(p *presenceStanza) {
+	room := bareJID(p.From)
+	if !c.isRoom(room) {
+		return
+	}
+	if p.Type == "error" {
+		logger.WarnCF("xmpp", "Failed to join room", map[string]any{
+			"room":  room,
+			"error": fmt.Sprint(p.Error),
+		})
+		return
+	}
+	if p.MUCUser == nil || len(p.MUCUser.Items) == 0 || p.MUCUser.Items[0].JID == "" {
+		return // anonymous room
+	}
+	c.mu.Lock()
+	if p.Type == "unavailable" {
+		delete(c.occupants, p.From)
+	} else {
+		c.occupants[p.From] = bareJID(p.MUCUser.Items[0].JID)
+	}
+	c.mu.Unlock()
+}
+
+// handleRequest answers pings and service discovery; everything else gets
+// service-unavailable.
+func (c *XMPPChannel) handleRequest(iq *iqStanza, namespace string) (string, bool) {
+	switch {
+	case iq.Type == "get" && namespace == nsPing:
+		return "", true
+	case iq.Type == "get" && namespace == nsDiscoInfo:
+		var b strings.Builder
+		b.WriteString("<query xmlns='" + nsDiscoInfo + "'><identity category='client' type='bot' name='picoclaw'/>")
+		for _, f := range []string{nsDiscoInfo, nsPing, nsMUC, nsChatStates, nsCorrect, nsOOB} {
+			b.WriteString("<feature var='" + f + "'/>")
+		}
+		b.WriteString("</query>")
+		return b.String(), true
+	}
+	return "", false
+}
+
+func (c *XMPPChannel) handleMessage(m *messageStanza) {
+	if m.Type == "error" || strings.TrimSpace(m.Body) == "" {
+		return // errors, chat states, receipts and subject changes
+	}
+	if m.Replace != nil {
+		return // corrections of messages already answered
+	}
+
+	local, _, resource := splitJID(m.From)
+	room := bareJID(m.From)
+	isRoom := c.isRoom(room)
+
+	var (
+		peer     bus.Peer
+		chatID   string
+		senderID string
+		nick     string
+	)
+	switch {
+	case m.Type == "groupchat":
+		if !isRoom || m.Delay != nil || resource == "" || resource == c.roomNick(room) {
+			return // unknown rooms, history replay, room notices and own echoes
+		}
+		peer = bus.Peer{Kind: "group", ID: room}
+		chatID, senderID, nick = room, c.occupantJID(m.From), resource
+	case isRoom:
+		// A private message from a room occupant.
+		peer = bus.Peer{Kind: "direct", ID: m.From}
+		chatID, senderID, nick = m.From, c.occupantJID(m.From), resource
+	default:
+		peer = bus.Peer{Kind: "direct", ID: room}
+		chatID, senderID, nick = room, room, local
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "xmpp",
+		PlatformID:  senderID,
+		CanonicalID: identity.BuildCanonicalID("xmpp", senderID),
+		Username:    nick,
+		DisplayName: nick,
+	}
+	if !c.IsAllowedSender(sender) {
+		logger.DebugCF("xmpp", "Message rejected by allowlist", map[string]any{"sender": senderID})
+		return
+	}
+
+	content := m.Body
+	if m.Type == "groupchat" {
+		own := c.roomNick(room)
+		respond, cleaned := c.ShouldRespondInGroup(isMentioned(content, own), stripMention(content, own))
+		if !respond {
+			return
+		}
+		content = cleaned
+	}
+
+	msgID := m.ID
+	if msgID == "" {
+		msgID = newID()
+	}
+	scope := channels.BuildMediaScope("xmpp", chatID, msgID)
+	var mediaRefs []string
+	for _, oob := range m.OOB {
+		if oob.URL == "" {
+			continue
+		}
+		// Uploads are sent with the URL as body; show the annotation instead.
+		content = strings.TrimSpace(strings.Replace(content, oob.URL, "", 1))
+		name := filenameFromURL(oob.URL)
+		if ref := c.downloadOOB(oob.URL, name, scope); ref != "" {
+			mediaRefs = append(mediaRefs, ref)
+		}
+		content = appendContent(content, fmt.Sprintf("[%s: %s]", mediaKind(mime.TypeByExtension(path.Ext(name))), name))
+	}
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	metadata := map[string]string{
+		"platform":     "xmpp",
+		"message_type": m.Type,
+	}
+	if isRoom {
+		metadata["room"] = room
+		metadata["nick"] = resource
+	}
+
+	logger.DebugCF("xmpp", "Received message", map[string]any{
+		"sender_id": senderID,
+		"chat_id":   chatID,
+		"preview":   utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(c.ctx, peer, msgID, senderID, chatID, content, mediaRefs, metadata, sender)
+}
+
+// occupantJID returns the real bare JID behind a room occupant, or the
+// occupant JID itself when the room is anonymous.
+func (c *XMPPChannel) occupantJID(occupant string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if real, ok := c.occupants[occupant]; ok {
+		return real
+	}
+	return occupant
+}
+
+// isMentioned reports whether a room message addresses nick.
+func isMentioned(body, nick string) bool {
+	if nick == "" {
+		return false
+	}
+	return regexp.MustCompile(`(?i)(^|[^\pL\pN_])@?` + regexp.QuoteMeta(nick) + `($|[^\pL\pN_])`).MatchString(body)
+}
+
+// stripMention removes the "nick: " prefix clients insert when addressing
+// an occupant.
+func stripMention(body, nick string) string {
+	if nick == "" {
+		return body
+	}
+	re := regexp.MustCompile(`(?i)^\s*@?` + regexp.QuoteMeta(nick) + `(?:[:,]\s*|\s+|$)`)
+	return strings.TrimSpace(re.ReplaceAllString(body, ""))
+}
+
+func (c *XMPPChannel) downloadOOB(rawURL, filename, scope string) string {
+	store := c.GetMediaStore()
+	if store == nil {
+		return ""
+	}
+	localPath := utils.DownloadFile(rawURL, filename, utils.DownloadOptions{
+		LoggerPrefix: "xmpp",
+	})
+	if localPath == "" {
+		return ""
+	}
+	ref, err := store.Store(localPath, media.MediaMeta{
+		Filename:    filename,
+		ContentType: mime.TypeByExtension(filepath.Ext(filename)),
+		Source:      "xmpp",
+	}, scope)
+	if err != nil {
+		_ = os.Remove(localPath)
+		logger.WarnCF("xmpp", "Failed to store attachment", map[string]any{"error": err.Error()})
+		return ""
+	}
+	return ref
+}
+
+func filenameFromURL(rawURL string) string {
+	name := "file"
+	if u, err := url.Parse(rawURL); err == nil {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			name = base
+		}
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return name
+}
+
+func mediaKind(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return "image"
+	case strings.HasPrefix(contentType, "audio/"):
+		return "audio"
+	case strings.HasPrefix(contentType, "video/"):
+		return "video"
+	default:
+		return "file"
+	}
+}
+
+func appendContent(content, suffix string) string {
+	if content == "" {
+		return suffix
+	}
+	return content + "\n" + suffix
+}
+
+func (c *XMPPChannel) session() (*session, error) {
+	if !c.IsRunning() {
+		return nil, channels.ErrNotRunning
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.sess == nil {
+		return nil, fmt.Errorf("xmpp not connected: %w", channels.ErrTemporary)
+	}
+	return c.sess, nil
+}
+
+// messageType picks groupchat for rooms and chat otherwise.
+func (c *XMPPChannel) messageType(chatID string) string {
+	if c.isRoom(chatID) {
+		return "groupchat"
+	}
+	return "chat"
+}
+
+// sendMessage writes a message stanza with the given body and extra
+// payload, and returns its id.
+func (c *XMPPChannel) sendMessage(chatID, body, extra string) (string, error) {
+	sess, err := c.session()
+	if err != nil {
+		return "", err
+	}
+	if chatID == "" {
+		return "", fmt.Errorf("empty xmpp chat ID: %w", channels.ErrSendFailed)
+	}
+	id := newID()
+	var b strings.Builder
+	b.WriteString("<message to='" + escape(chatID) + "' type='" + c.messageType(chatID) + "' id='" + id + "'>")
+	if body != "" {
+		b.WriteString("<body>" + escape(body) + "</body>")
+	}
+	if c.config.Typing.Enabled && body != "" {
+		b.WriteString("<active xmlns='" + nsChatStates + "'/>")
+	}
+	b.WriteString(extra)
+	b.WriteString("</message>")
+	if err := sess.write(b.String()); err != nil {
+		return "", fmt.Errorf("xmpp send: %v: %w", err, channels.ErrTemporary)
+	}
+	return id, nil
+}
+
+// Send sends a text message.
+func (c *XMPPChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	_, err := c.sendMessage(msg.ChatID, msg.Content, "")
+	return err
+}
+
+// EditMessage implements channels.MessageEditor with Last Message
+// Correction (XEP-0308).
+func (c *XMPPChannel) EditMessage(ctx context.Context, chatID, messageID, content string) error {
+	_, err := c.sendMessage(chatID, content, "<replace id='"+escape(messageID)+"' xmlns='"+nsCorrect+"'/>")
+	return err
+}
+
+// SendPlaceholder implements channels.PlaceholderCapable.
+func (c *XMPPChannel) SendPlaceholder(ctx context.Context, chatID string) (string, error) {
+	if !c.config.Placeholder.Enabled {
+		return "", nil
+	}
+	text := c.config.Placeholder.Text
+	if text == "" {
+		text = defaultPlaceholder
+	}
+	return c.sendMessage(chatID, text, "")
+}
+
+// StartTyping implements channels.TypingCapable with chat state
+// notifications (XEP-0085). Requires typing.enabled in config.
+func (c *XMPPChannel) StartTyping(ctx context.Context, chatID string) (func(), error) {
+	if !c.config.Typing.Enabled || !c.IsRunning() {
+		return func() {}, nil
+	}
+	hint := "<no-store xmlns='" + nsHints + "'/>"
+	if _, err := c.sendMessage(chatID, "", "<composing xmlns='"+nsChatStates+"'/>"+hint); err != nil {
+		return func() {}, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			_, _ = c.sendMessage(chatID, "", "<active xmlns='"+nsChatStates+"'/>"+hint)
+		})
+	}, nil
+}
+
+// SendMedia implements channels.MediaSender with HTTP File Upload
+// (XEP-0363). Each file is sent as its download URL with an out-of-band
+// data element, which clients render inline.
+func (c *XMPPChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
+	sess, err := c.session()
+	if err != nil {
+		return err
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		return fmt.Errorf("no media store available: %w", channels.ErrSendFailed)
+	}
+	service, err := c.uploadService(ctx, sess)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range msg.Parts {
+		localPath, meta, err := store.ResolveWithMeta(part.Ref)
+		if err != nil {
+			logger.ErrorCF("xmpp", "Failed to resolve media ref", map[string]any{
+				"ref":   part.Ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+		filename := part.Filename
+		if filename == "" {
+			filename = meta.Filename
+		}
+		if filename == "" {
+			filename = filepath.Base(localPath)
+		}
+		contentType := part.ContentType
+		if contentType == "" {
+			contentType = meta.ContentType
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		getURL, err := c.upload(ctx, sess, service, localPath, filename, contentType)
+		if err != nil {
+			return err
+		}
+		if part.Caption != "" {
+			if _, err := c.sendMessage(msg.ChatID, part.Caption, ""); err != nil {
+				return err
+			}
+		}
+		oob := "<x xmlns='" + nsOOB + "'><url>" + escape(getURL) + "</url></x>"
+		if _, err := c.sendMessage(msg.ChatID, getURL, oob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type discoInfo struct {
+	Features []struct {
+		Var string `xml:"var,attr"`
+	} `xml:"query>feature"`
+}
+
+type discoItems struct {
+	Items []struct {
+		JID string `xml:"jid,attr"`
+	} `xml:"query>item"`
+}
+
+// uploadService returns the configured upload service, or finds one among
+// the server's items.
+func (c *XMPPChannel) uploadService(ctx context.Context, sess *session) (string, error) {
+	c.mu.RLock()
+	service := c.uploadSvc
+	c.mu.RUnlock()
+	if service != "" {
+		return service, nil
+	}
+
+	candidates := []string{c.domain}
+	if iq, err := sess.iq(ctx, "get", c.domain, "<query xmlns='"+nsDiscoItems+"'/>"); err == nil {
+		var items discoItems
+		if xml.Unmarshal(wrapQuery(iq.Inner), &items) == nil {
+			for _, item := range items.Items {
+				candidates = append(candidates, item.JID)
+			}
+		}
+	}
+	for _, jid := range candidates {
+		iq, err := sess.iq(ctx, "get", jid, "<query xmlns='"+nsDiscoInfo+"'/>")
+		if err != nil {
+			continue
+		}
+		var info discoInfo
+		if xml.Unmarshal(wrapQuery(iq.Inner), &info) != nil {
+			continue
+		}
+		for _, f := range info.Features {
+			if f.Var == nsUpload {
+				c.mu.Lock()
+				c.uploadSvc = jid
+				c.mu.Unlock()
+				return jid, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("xmpp server offers no HTTP upload service: %w", channels.ErrSendFailed)
+}
+
+// wrapQuery gives an IQ payload a root element so that it can be
+// unmarshalled with "query>..." paths.
+func wrapQuery(inner []byte) []byte {
+	return append(append([]byte("<r>"), inner...), "</r>"...)
+}
+
+type uploadSlot struct {
+	Put struct {
+		URL     string `xml:"url,attr"`
+		Headers []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"header"`
+	} `xml:"put"`
+	Get struct {
+		URL string `xml:"url,attr"`
+	} `xml:"get"`
+}
+
+// upload requests a slot and PUTs the file into it, returning the GET URL.
+func (c *XMPPChannel) upload(ctx context.Context, sess *session, service, localPath, filename, contentType string) (string, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", fmt.Errorf("xmpp upload: %v: %w", err, channels.ErrSendFailed)
+	}
+	request := "<request xmlns='" + nsUpload + "' filename='" + escape(filename) +
+		"' size='" + strconv.FormatInt(info.Size(), 10) + "' content-type='" + escape(contentType) + "'/>"
+	iq, err := sess.iq(ctx, "get", service, request)
+	if err != nil {
+		return "", fmt.Errorf("xmpp upload slot: %v: %w", err, channels.ErrSendFailed)
+	}
+	var slot uploadSlot
+	if err := xml.Unmarshal(iq.Inner, &slot); err != nil || slot.Put.URL == "" || slot.Get.URL == "" {
+		return "", fmt.Errorf("xmpp upload: malformed slot: %w", channels.ErrSendFailed)
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("xmpp upload: %v: %w", err, channels.ErrSendFailed)
+	}
+	defer f.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, slot.Put.URL, f)
+	if err != nil {
+		return "", fmt.Errorf("xmpp upload: %v: %w", err, channels.ErrSendFailed)
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", contentType)
+	for _, h := range slot.Put.Headers {
+		// Only these headers may be passed on (XEP-0363 §5).
+		switch http.CanonicalHeaderKey(h.Name) {
+		case "Authorization", "Cookie", "Expires":
+			req.Header.Set(h.Name, strings.NewReplacer("\r", "", "\n", "").Replace(h.Value))
+		}
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", channels.ClassifyNetError(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", channels.ClassifySendError(resp.StatusCode, fmt.Errorf("xmpp upload PUT: %s", resp.Status))
+	}
+	return slot.Get.URL, nil
+}
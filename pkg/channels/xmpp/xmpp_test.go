@@ -0,0 +1,417 @@
+package xmpp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+type stanza struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+func (s stanza) attr(name string) string {
+	for _, a := range s.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// fakeServer is a minimal XMPP server: STARTTLS, SASL PLAIN, resource
+// binding, and an HTTP upload service at upload.example.com.
+type fakeServer struct {
+	addr     string
+	tls      *tls.Config
+	rootCAs  *tls.Config
+	uploads  *httptest.Server
+	put      chan *http.Request
+	putBody  chan string
+	received chan stanza
+	conns    chan net.Conn
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	certSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(certSrv.Close)
+
+	f := &fakeServer{
+		tls:      certSrv.TLS,
+		rootCAs:  certSrv.Client().Transport.(*http.Transport).TLSClientConfig,
+		put:      make(chan *http.Request, 4),
+		putBody:  make(chan string, 4),
+		received: make(chan stanza, 64),
+		conns:    make(chan net.Conn, 4),
+	}
+	f.uploads = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		f.put <- r
+		f.putBody <- string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(f.uploads.Close)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f.addr = ln.Addr().String()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+// readHeader reads a stream header and answers it with features.
+func readHeader(conn net.Conn, features string) (*xml.Decoder, error) {
+	dec := xml.NewDecoder(conn)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "stream" {
+			break
+		}
+	}
+	_, err := fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream xmlns='jabber:client' "+
+		"xmlns:stream='%s' from='example.com' id='s1' version='1.0'><stream:features>%s</stream:features>",
+		nsStream, features)
+	return dec, err
+}
+
+func readStanza(dec *xml.Decoder) (stanza, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return stanza{}, err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			var s stanza
+			err := dec.DecodeElement(&s, &se)
+			return s, err
+		}
+	}
+}
+
+func (f *fakeServer) serve(raw net.Conn) {
+	defer raw.Close()
+	dec, err := readHeader(raw, "<starttls xmlns='"+nsTLS+"'><required/></starttls>")
+	if err != nil {
+		return
+	}
+	if s, err := readStanza(dec); err != nil || s.XMLName.Local != "starttls" {
+		return
+	}
+	fmt.Fprintf(raw, "<proceed xmlns='%s'/>", nsTLS)
+	conn := tls.Server(raw, f.tls)
+	if dec, err = readHeader(conn, "<mechanisms xmlns='"+nsSASL+"'><mechanism>PLAIN</mechanism></mechanisms>"); err != nil {
+		return
+	}
+	auth, err := readStanza(dec)
+	if err != nil {
+		return
+	}
+	if creds, _ := base64.StdEncoding.DecodeString(auth.Inner); string(creds) != "\x00bot\x00secret" {
+		fmt.Fprintf(conn, "<failure xmlns='%s'><not-authorized/></failure>", nsSASL)
+		return
+	}
+	fmt.Fprintf(conn, "<success xmlns='%s'/>", nsSASL)
+	if dec, err = readHeader(conn, "<bind xmlns='"+nsBind+"'/>"); err != nil {
+		return
+	}
+	bind, err := readStanza(dec)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(conn, "<iq type='result' id='%s'><bind xmlns='%s'><jid>bot@example.com/picoclaw</jid></bind></iq>",
+		bind.attr("id"), nsBind)
+	f.conns <- conn
+
+	for {
+		s, err := readStanza(dec)
+		if err != nil {
+			return
+		}
+		if s.XMLName.Local == "iq" && s.attr("type") == "get" {
+			fmt.Fprint(conn, f.answer(s))
+			continue
+		}
+		f.received <- s
+	}
+}
+
+func (f *fakeServer) answer(iq stanza) string {
+	result := func(payload string) string {
+		return fmt.Sprintf("<iq type='result' id='%s' from='%s'>%s</iq>", iq.attr("id"), iq.attr("to"), payload)
+	}
+	switch {
+	case strings.Contains(iq.Inner, nsDiscoItems):
+		return result("<query xmlns='" + nsDiscoItems + "'><item jid='conference.example.com'/>" +
+			"<item jid='upload.example.com'/></query>")
+	case strings.Contains(iq.Inner, nsDiscoInfo) && iq.attr("to") == "upload.example.com":
+		return result("<query xmlns='" + nsDiscoInfo + "'><feature var='" + nsUpload + "'/></query>")
+	case strings.Contains(iq.Inner, nsDiscoInfo):
+		return result("<query xmlns='" + nsDiscoInfo + "'><feature var='" + nsMUC + "'/></query>")
+	case strings.Contains(iq.Inner, nsUpload):
+		return result("<slot xmlns='" + nsUpload + "'><put url='" + f.uploads.URL + "/put/report.pdf'>" +
+			"<header name='Authorization'>Basic abc</header><header name='X-Evil'>no</header></put>" +
+			"<get url='https://files.example.com/report.pdf'/></slot>")
+	}
+	return fmt.Sprintf("<iq type='error' id='%s'><error type='cancel'><feature-not-implemented xmlns='%s'/></error></iq>",
+		iq.attr("id"), nsStanzas)
+}
+
+func (f *fakeServer) next(t *testing.T) stanza {
+	t.Helper()
+	select {
+	case s := <-f.received:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatal("no stanza from client")
+		return stanza{}
+	}
+}
+
+func (f *fakeServer) conn(t *testing.T) net.Conn {
+	t.Helper()
+	select {
+	case c := <-f.conns:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not connect")
+		return nil
+	}
+}
+
+func startChannel(t *testing.T, f *fakeServer, cfg config.XMPPConfig) (*XMPPChannel, *bus.MessageBus, net.Conn) {
+	t.Helper()
+	mb := bus.NewMessageBus()
+	cfg.JID, cfg.Password, cfg.Server = "bot@example.com", "secret", f.addr
+	ch, err := NewXMPPChannel(cfg, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.tlsConfig = f.rootCAs.Clone()
+	ch.SetMediaStore(media.NewFileMediaStore())
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ch.Stop(context.Background()) })
+	return ch, mb, f.conn(t)
+}
+
+func nextInbound(t *testing.T, mb *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func TestXMPPChannel_DirectAndRoomMessages(t *testing.T) {
+	f := newFakeServer(t)
+	ch, mb, conn := startChannel(t, f, config.XMPPConfig{
+		Rooms:        []config.XMPPRoomConfig{{JID: "lobby@conference.example.com", Password: "door"}},
+		GroupTrigger: config.GroupTriggerConfig{MentionOnly: true},
+	})
+
+	if s := f.next(t); s.XMLName.Local != "presence" || s.attr("to") != "" {
+		t.Errorf("initial presence = %+v", s)
+	}
+	join := f.next(t)
+	if join.attr("to") != "lobby@conference.example.com/bot" || !strings.Contains(join.Inner, nsMUC) ||
+		!strings.Contains(join.Inner, "<password>door</password>") || !strings.Contains(join.Inner, "maxstanzas") {
+		t.Errorf("room join = %+v", join)
+	}
+
+	room := "lobby@conference.example.com"
+	fmt.Fprintf(conn, "<presence from='%s/alice'><x xmlns='%s'><item jid='alice@example.com/phone' role='participant'/></x></presence>",
+		room, nsMUCUser)
+	for _, m := range []string{
+		"<message from='" + room + "/bot' type='groupchat' id='echo'><body>bot: loop</body></message>",
+		"<message from='" + room + "/alice' type='groupchat' id='old'><body>bot: old</body><delay xmlns='urn:xmpp:delay'/></message>",
+		"<message from='" + room + "' type='groupchat'><subject>Welcome</subject></message>",
+		"<message from='" + room + "/alice' type='groupchat' id='g0'><body>robots are fun</body></message>",
+		"<message from='alice@example.com/phone' type='chat'><composing xmlns='" + nsChatStates + "'/></message>",
+		"<message from='alice@example.com/phone' type='chat' id='d1'><body>hi &amp; hello</body></message>",
+	} {
+		fmt.Fprint(conn, m)
+	}
+
+	dm := nextInbound(t, mb)
+	if dm.ChatID != "alice@example.com" || dm.Peer.Kind != "direct" || dm.MessageID != "d1" ||
+		dm.SenderID != "xmpp:alice@example.com" || dm.Content != "hi & hello" {
+		t.Errorf("direct message = %+v", dm)
+	}
+
+	fmt.Fprintf(conn, "<message from='%s/alice' type='groupchat' id='g1'><body>Bot: what time is it?</body></message>", room)
+	g := nextInbound(t, mb)
+	if g.ChatID != room || g.Peer.Kind != "group" || g.Content != "what time is it?" || g.SenderID != "xmpp:alice@example.com" {
+		t.Errorf("room message = %+v", g)
+	}
+
+	fmt.Fprintf(conn, "<message from='%s/carol' type='chat' id='p1'><body>psst</body></message>", room)
+	pm := nextInbound(t, mb)
+	if pm.ChatID != room+"/carol" || pm.Peer.Kind != "direct" || pm.SenderID != "xmpp:"+room+"/carol" {
+		t.Errorf("private room message = %+v", pm)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: room, Content: "noon <ish>"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := f.next(t); s.attr("to") != room || s.attr("type") != "groupchat" ||
+		!strings.Contains(s.Inner, "<body>noon &lt;ish&gt;</body>") {
+		t.Errorf("room reply = %+v", s)
+	}
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: dm.ChatID, Content: "hey"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := f.next(t); s.attr("to") != "alice@example.com" || s.attr("type") != "chat" {
+		t.Errorf("direct reply = %+v", s)
+	}
+
+	// Server pings are answered, unknown requests are refused.
+	fmt.Fprintf(conn, "<iq type='get' id='ping1' from='example.com'><ping xmlns='%s'/></iq>", nsPing)
+	if s := f.next(t); s.attr("id") != "ping1" || s.attr("type") != "result" {
+		t.Errorf("ping reply = %+v", s)
+	}
+	fmt.Fprint(conn, "<iq type='get' id='v1' from='example.com'><query xmlns='jabber:iq:version'/></iq>")
+	if s := f.next(t); s.attr("id") != "v1" || s.attr("type") != "error" || !strings.Contains(s.Inner, "service-unavailable") {
+		t.Errorf("unknown iq reply = %+v", s)
+	}
+}
+
+func TestXMPPChannel_EditTypingAndUpload(t *testing.T) {
+	f := newFakeServer(t)
+	ch, _, _ := startChannel(t, f, config.XMPPConfig{
+		Typing:      config.TypingConfig{Enabled: true},
+		Placeholder: config.PlaceholderConfig{Enabled: true},
+	})
+	f.next(t) // presence
+	chat := "alice@example.com"
+
+	stop, err := ch.StartTyping(context.Background(), chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := f.next(t); !strings.Contains(s.Inner, "composing") || strings.Contains(s.Inner, "<body>") {
+		t.Errorf("typing = %+v", s)
+	}
+	stop()
+	stop()
+	if s := f.next(t); !strings.Contains(s.Inner, "<active") {
+		t.Errorf("typing stop = %+v", s)
+	}
+
+	id, err := ch.SendPlaceholder(context.Background(), chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := f.next(t); s.attr("id") != id || !strings.Contains(s.Inner, defaultPlaceholder) {
+		t.Errorf("placeholder = %+v, id %q", s, id)
+	}
+	if err := ch.EditMessage(context.Background(), chat, id, "done"); err != nil {
+		t.Fatal(err)
+	}
+	edit := f.next(t)
+	if !strings.Contains(edit.Inner, "<body>done</body>") ||
+		!strings.Contains(edit.Inner, "<replace id='"+id+"' xmlns='"+nsCorrect+"'/>") {
+		t.Errorf("correction = %+v", edit)
+	}
+
+	file := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(file, []byte("%PDF"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := ch.GetMediaStore().Store(file, media.MediaMeta{Filename: "report.pdf", ContentType: "application/pdf"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ch.SendMedia(context.Background(), bus.OutboundMediaMessage{
+		ChatID: chat,
+		Parts:  []bus.MediaPart{{Type: "file", Ref: ref, Caption: "Your report"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := <-f.put
+	if body := <-f.putBody; body != "%PDF" || put.Header.Get("Authorization") != "Basic abc" ||
+		put.Header.Get("X-Evil") != "" || put.Header.Get("Content-Type") != "application/pdf" {
+		t.Errorf("PUT %s: body %q, headers %v", put.URL, body, put.Header)
+	}
+	if s := f.next(t); !strings.Contains(s.Inner, "<body>Your report</body>") {
+		t.Errorf("caption = %+v", s)
+	}
+	link := f.next(t)
+	if !strings.Contains(link.Inner, "<body>https://files.example.com/report.pdf</body>") ||
+		!strings.Contains(link.Inner, "<url>https://files.example.com/report.pdf</url>") {
+		t.Errorf("file message = %+v", link)
+	}
+	if ch.uploadSvc != "upload.example.com" {
+		t.Errorf("discovered upload service = %q", ch.uploadSvc)
+	}
+}
+
+func TestXMPPChannel_BadPassword(t *testing.T) {
+	f := newFakeServer(t)
+	ch, err := NewXMPPChannel(config.XMPPConfig{JID: "bot@example.com", Password: "wrong", Server: f.addr}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.tlsConfig = f.rootCAs.Clone()
+	if err := ch.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "not-authorized") {
+		t.Errorf("Start = %v, want not-authorized", err)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	cases := []struct {
+		body      string
+		mentioned bool
+		stripped  string
+	}{
+		{"bot: hello", true, "hello"},
+		{"@Bot, hello", true, "hello"},
+		{"ask bot about it", true, "ask bot about it"},
+		{"robots rule", false, "robots rule"},
+		{"botany", false, "botany"},
+	}
+	for _, tc := range cases {
+		if got := isMentioned(tc.body, "bot"); got != tc.mentioned {
+			t.Errorf("isMentioned(%q) = %v", tc.body, got)
+		}
+		if got := stripMention(tc.body, "bot"); got != tc.stripped {
+			t.Errorf("stripMention(%q) = %q, want %q", tc.body, got, tc.stripped)
+		}
+	}
+}
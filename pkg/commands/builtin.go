@@ -13,5 +13,12 @@ func BuiltinDefinitions() []Definition {
 		switchCommand(),
 		checkCommand(),
 		clearCommand(),
+		undoCommand(),
+		retryCommand(),
+		branchCommand(),
+		checkoutCommand(),
+		usageCommand(),
+		cooldownCommand(),
+		backCommand(),
 	}
 }
@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+)
+
+func backCommand() Definition {
+	return Definition{
+		Name:        "back",
+		Description: "Return a handed-off chat to its usual agent",
+		Usage:       "/back",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.HandBack == nil {
+				return req.Reply(unavailableMsg)
+			}
+			agentID, ok := rt.HandBack()
+			if !ok {
+				return req.Reply("This chat has not been handed off to another agent.")
+			}
+			return req.Reply(fmt.Sprintf("Left agent %s; the usual agent handles this chat again.", agentID))
+		},
+	}
+}
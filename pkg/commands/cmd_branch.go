@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func branchCommand() Definition {
+	return Definition{
+		Name:        "branch",
+		Description: "List branches or start a new branch of the chat",
+		Usage:       "/branch [name]",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			name := nthToken(req.Text, 1)
+			if name == "" {
+				if rt == nil || rt.ListBranches == nil {
+					return req.Reply(unavailableMsg)
+				}
+				branches, err := rt.ListBranches()
+				if err != nil {
+					return req.Reply("Failed to list branches: " + err.Error())
+				}
+				return req.Reply(FormatBranches(branches))
+			}
+			if rt == nil || rt.CreateBranch == nil {
+				return req.Reply(unavailableMsg)
+			}
+			if err := rt.CreateBranch(name); err != nil {
+				return req.Reply("Failed to create branch: " + err.Error())
+			}
+			return req.Reply(fmt.Sprintf("Switched to new branch %s. Use /checkout to go back.", name))
+		},
+	}
+}
+
+func checkoutCommand() Definition {
+	return Definition{
+		Name:        "checkout",
+		Description: "Switch the chat to another branch",
+		Usage:       "/checkout <name>",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.CheckoutBranch == nil {
+				return req.Reply(unavailableMsg)
+			}
+			name := nthToken(req.Text, 1)
+			if name == "" {
+				return req.Reply("Usage: /checkout <name>")
+			}
+			if err := rt.CheckoutBranch(name); err != nil {
+				return req.Reply("Failed to switch branch: " + err.Error())
+			}
+			return req.Reply(fmt.Sprintf("Switched to branch %s.", name))
+		},
+	}
+}
+
+// FormatBranches renders a session's branches as plain text.
+func FormatBranches(branches []session.BranchInfo) string {
+	var sb strings.Builder
+	sb.WriteString("Branches:")
+	for _, b := range branches {
+		marker := " "
+		if b.Current {
+			marker = "*"
+		}
+		fmt.Fprintf(&sb, "\n%s %s (%d messages)", marker, b.Name, b.Messages)
+	}
+	return sb.String()
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func cooldownCommand() Definition {
+	return Definition{
+		Name:        "cooldown",
+		Description: "Inspect or clear provider cooldowns",
+		SubCommands: []SubCommand{
+			{
+				Name:        "list",
+				Description: "Show providers in cooldown",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.ListCooldowns == nil {
+						return req.Reply(unavailableMsg)
+					}
+					return req.Reply(FormatCooldowns(rt.ListCooldowns(), time.Now()))
+				},
+			},
+			{
+				Name:        "clear",
+				Description: "Clear a provider's cooldown",
+				ArgsUsage:   "<provider|all>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.ClearCooldown == nil {
+						return req.Reply(unavailableMsg)
+					}
+					target := nthToken(req.Text, 2)
+					if target == "" {
+						return req.Reply("Usage: /cooldown clear <provider|all>")
+					}
+					if target == "all" {
+						n := rt.ClearCooldown("")
+						return req.Reply(fmt.Sprintf("Cleared cooldown for %d provider(s)", n))
+					}
+					if rt.ClearCooldown(target) == 0 {
+						return req.Reply(fmt.Sprintf("Provider %s has no cooldown", target))
+					}
+					return req.Reply(fmt.Sprintf("Cleared cooldown for %s", target))
+				},
+			},
+		},
+	}
+}
+
+// FormatCooldowns renders provider cooldown state as plain text. It is
+// shared by /cooldown and `picoclaw cooldown`.
+func FormatCooldowns(statuses []providers.CooldownStatus, now time.Time) string {
+	if len(statuses) == 0 {
+		return "No providers in cooldown"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Provider cooldowns:")
+	for _, st := range statuses {
+		state := "available"
+		if st.Until.After(now) {
+			state = fmt.Sprintf("cooling down for %s", st.Until.Sub(now).Round(time.Second))
+		}
+		fmt.Fprintf(&sb, "\n- %s: %s (%d errors", st.Provider, state, st.ErrorCount)
+		if st.Reason != "" {
+			fmt.Fprintf(&sb, ", reason %s", st.Reason)
+		}
+		sb.WriteString(")")
+	}
+	return sb.String()
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func runCooldown(t *testing.T, rt *Runtime, text string) string {
+	t.Helper()
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	res := ex.Execute(context.Background(), Request{
+		Text: text,
+		Reply: func(s string) error {
+			reply = s
+			return nil
+		},
+	})
+	if res.Outcome != OutcomeHandled {
+		t.Fatalf("%s outcome=%v, want=%v", text, res.Outcome, OutcomeHandled)
+	}
+	return reply
+}
+
+func TestCooldownCommand_List(t *testing.T) {
+	rt := &Runtime{
+		ListCooldowns: func() []providers.CooldownStatus {
+			return []providers.CooldownStatus{{
+				Provider:   "anthropic",
+				ErrorCount: 1,
+				Until:      time.Now().Add(5 * time.Hour),
+				Reason:     providers.FailoverBilling,
+			}}
+		},
+	}
+
+	reply := runCooldown(t, rt, "/cooldown list")
+	for _, want := range []string{"anthropic: cooling down for", "1 errors", "reason billing"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("reply missing %q, got %q", want, reply)
+		}
+	}
+}
+
+func TestCooldownCommand_Clear(t *testing.T) {
+	var cleared []string
+	rt := &Runtime{
+		ClearCooldown: func(provider string) int {
+			cleared = append(cleared, provider)
+			if provider == "unknown" {
+				return 0
+			}
+			return 2
+		},
+	}
+
+	if reply := runCooldown(t, rt, "/cooldown clear anthropic"); reply != "Cleared cooldown for anthropic" {
+		t.Errorf("reply = %q", reply)
+	}
+	if reply := runCooldown(t, rt, "/cooldown clear unknown"); reply != "Provider unknown has no cooldown" {
+		t.Errorf("reply = %q", reply)
+	}
+	if reply := runCooldown(t, rt, "/cooldown clear all"); reply != "Cleared cooldown for 2 provider(s)" {
+		t.Errorf("reply = %q", reply)
+	}
+	if reply := runCooldown(t, rt, "/cooldown clear"); !strings.HasPrefix(reply, "Usage:") {
+		t.Errorf("reply = %q, want usage", reply)
+	}
+	if strings.Join(cleared, ",") != "anthropic,unknown," {
+		t.Errorf("cleared = %v", cleared)
+	}
+}
+
+func TestFormatCooldowns_Empty(t *testing.T) {
+	if got := FormatCooldowns(nil, time.Now()); got != "No providers in cooldown" {
+		t.Errorf("got %q", got)
+	}
+}
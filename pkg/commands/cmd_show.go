@@ -33,6 +33,30 @@ func showCommand() Definition {
 				Description: "Registered agents",
 				Handler:     agentsHandler(),
 			},
+			{
+				Name:        "cache",
+				Description: "Prompt-cache hit rate for this session",
+				Handler:     cacheStatsHandler(),
+			},
 		},
 	}
 }
+
+func cacheStatsHandler() Handler {
+	return func(_ context.Context, req Request, rt *Runtime) error {
+		if rt == nil || rt.GetCacheStats == nil {
+			return req.Reply(unavailableMsg)
+		}
+		stats, ok := rt.GetCacheStats()
+		if !ok {
+			return req.Reply(unavailableMsg)
+		}
+		if stats.Requests == 0 {
+			return req.Reply("No prompt-cache usage recorded for this session yet.")
+		}
+		return req.Reply(fmt.Sprintf(
+			"Prompt cache (%d requests): %.1f%% hit rate\nPrompt tokens: %d\nCache read: %d\nCache write: %d",
+			stats.Requests, stats.HitRate()*100, stats.PromptTokens, stats.CacheReadTokens, stats.CacheWriteTokens,
+		))
+	}
+}
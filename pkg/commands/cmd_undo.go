@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+)
+
+func undoCommand() Definition {
+	return Definition{
+		Name:        "undo",
+		Description: "Remove the last turn from the chat history",
+		Usage:       "/undo",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.UndoTurn == nil {
+				return req.Reply(unavailableMsg)
+			}
+			removed, err := rt.UndoTurn()
+			if err != nil {
+				return req.Reply("Failed to undo: " + err.Error())
+			}
+			if removed == 0 {
+				return req.Reply("Nothing to undo.")
+			}
+			return req.Reply(fmt.Sprintf("Removed the last turn (%d messages).", removed))
+		},
+	}
+}
+
+func retryCommand() Definition {
+	return Definition{
+		Name:        "retry",
+		Description: "Regenerate the last answer",
+		Usage:       "/retry [model]",
+		Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.RetryTurn == nil {
+				return req.Reply(unavailableMsg)
+			}
+			answer, err := rt.RetryTurn(ctx, nthToken(req.Text, 1))
+			if err != nil {
+				return req.Reply("Failed to retry: " + err.Error())
+			}
+			return req.Reply(answer)
+		},
+	}
+}
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func usageCommand() Definition {
+	return Definition{
+		Name:        "usage",
+		Description: "Show token usage and cost",
+		Usage:       "/usage",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.GetUsage == nil {
+				return req.Reply(unavailableMsg)
+			}
+			sessionTotals, monthTotals, err := rt.GetUsage()
+			if err != nil {
+				return req.Reply("Failed to read usage: " + err.Error())
+			}
+
+			var sb strings.Builder
+			sb.WriteString("This session: ")
+			sb.WriteString(formatTotals(sessionTotals))
+			sb.WriteString("\nThis month: ")
+			sb.WriteString(formatTotals(monthTotals))
+			if rt.Config != nil && rt.Config.Usage.MonthlyBudget > 0 {
+				budget := rt.Config.Usage.MonthlyBudget
+				fmt.Fprintf(&sb, "\nMonthly budget: %.2f of %.2f used (%.0f%%)",
+					monthTotals.Cost, budget, monthTotals.Cost/budget*100)
+			}
+			return req.Reply(sb.String())
+		},
+	}
+}
+
+func formatTotals(t usage.Totals) string {
+	if t.Requests == 0 {
+		return "no usage recorded"
+	}
+	return fmt.Sprintf("%d requests, %d tokens (%d in / %d out), cost %.4f",
+		t.Requests, t.TotalTokens(), t.PromptTokens, t.CompletionTokens, t.Cost)
+}
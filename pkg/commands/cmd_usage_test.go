@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func TestUsageCommand_ReportsSessionMonthAndBudget(t *testing.T) {
+	cfg := &config.Config{Usage: config.UsageConfig{MonthlyBudget: 10}}
+	rt := &Runtime{
+		Config: cfg,
+		GetUsage: func() (usage.Totals, usage.Totals, error) {
+			return usage.Totals{Requests: 2, PromptTokens: 300, CompletionTokens: 50, Cost: 0.01},
+				usage.Totals{Requests: 40, PromptTokens: 9000, CompletionTokens: 1000, Cost: 2.5},
+				nil
+		},
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	res := ex.Execute(context.Background(), Request{
+		Text: "/usage",
+		Reply: func(text string) error {
+			reply = text
+			return nil
+		},
+	})
+	if res.Outcome != OutcomeHandled {
+		t.Fatalf("/usage outcome=%v, want=%v", res.Outcome, OutcomeHandled)
+	}
+	for _, want := range []string{
+		"This session: 2 requests, 350 tokens",
+		"This month: 40 requests, 10000 tokens",
+		"2.50 of 10.00 used (25%)",
+	} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("/usage reply missing %q, got %q", want, reply)
+		}
+	}
+}
+
+func TestUsageCommand_Unavailable(t *testing.T) {
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), &Runtime{})
+
+	var reply string
+	ex.Execute(context.Background(), Request{
+		Text: "/usage",
+		Reply: func(text string) error {
+			reply = text
+			return nil
+		},
+	})
+	if reply != unavailableMsg {
+		t.Fatalf("/usage reply=%q, want %q", reply, unavailableMsg)
+	}
+}
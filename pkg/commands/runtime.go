@@ -1,6 +1,13 @@
 package commands
 
-import "github.com/sipeed/picoclaw/pkg/config"
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
 
 // Runtime provides runtime dependencies to command handlers. It is constructed
 // per-request by the agent loop so that per-request state (like session scope)
@@ -14,4 +21,14 @@ type Runtime struct {
 	SwitchModel        func(value string) (oldModel string, err error)
 	SwitchChannel      func(value string) error
 	ClearHistory       func() error
+	GetCacheStats      func() (stats session.CacheStats, ok bool)
+	GetUsage           func() (sessionTotals, monthTotals usage.Totals, err error)
+	ListCooldowns      func() []providers.CooldownStatus
+	ClearCooldown      func(provider string) int // empty provider clears all
+	HandBack           func() (agentID string, ok bool)
+	UndoTurn           func() (removed int, err error)
+	RetryTurn          func(ctx context.Context, model string) (answer string, err error) // empty model keeps the current one
+	ListBranches       func() ([]session.BranchInfo, error)
+	CreateBranch       func(name string) error
+	CheckoutBranch     func(name string) error
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"strings"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/session"
 )
 
 func TestShowListHandlers_ChannelPolicy(t *testing.T) {
@@ -83,3 +85,47 @@ func TestShowListHandlers_ListHandledOnAllChannels(t *testing.T) {
 		t.Fatalf("whatsapp /list reply=%q, expected enabled channels content", reply)
 	}
 }
+
+func TestShowCache_ReportsHitRate(t *testing.T) {
+	rt := &Runtime{
+		GetCacheStats: func() (session.CacheStats, bool) {
+			return session.CacheStats{Requests: 3, PromptTokens: 4000, CacheReadTokens: 3000, CacheWriteTokens: 900}, true
+		},
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	res := ex.Execute(context.Background(), Request{
+		Channel: "telegram",
+		Text:    "/show cache",
+		Reply: func(text string) error {
+			reply = text
+			return nil
+		},
+	})
+	if res.Outcome != OutcomeHandled {
+		t.Fatalf("/show cache outcome=%v, want=%v", res.Outcome, OutcomeHandled)
+	}
+	if !strings.Contains(reply, "75.0% hit rate") || !strings.Contains(reply, "Cache write: 900") {
+		t.Fatalf("/show cache reply=%q", reply)
+	}
+}
+
+func TestShowCache_NoUsageYet(t *testing.T) {
+	rt := &Runtime{
+		GetCacheStats: func() (session.CacheStats, bool) { return session.CacheStats{}, true },
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	ex.Execute(context.Background(), Request{
+		Text: "/show cache",
+		Reply: func(text string) error {
+			reply = text
+			return nil
+		},
+	})
+	if !strings.Contains(reply, "No prompt-cache usage") {
+		t.Fatalf("/show cache reply=%q", reply)
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 
@@ -79,6 +82,7 @@ func (f *FlexibleStringSlice) UnmarshalText(text []byte) error {
 type Config struct {
 	Agents    AgentsConfig    `json:"agents"`
 	Bindings  []AgentBinding  `json:"bindings,omitempty"`
+	Roles     RolesConfig     `json:"roles,omitempty"`
 	Session   SessionConfig   `json:"session,omitempty"`
 	Channels  ChannelsConfig  `json:"channels"`
 	Providers ProvidersConfig `json:"providers,omitempty"`
@@ -88,6 +92,7 @@ type Config struct {
 	Heartbeat HeartbeatConfig `json:"heartbeat"`
 	Devices   DevicesConfig   `json:"devices"`
 	Voice     VoiceConfig     `json:"voice"`
+	Usage     UsageConfig     `json:"usage,omitempty"`
 	// BuildInfo contains build-time version information
 	BuildInfo BuildInfo `json:"build_info,omitempty"`
 }
@@ -118,7 +123,7 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	}
 
 	// Only include session if not empty
-	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 {
+	if c.Session.DMScope != "" || c.Session.ThreadScope != "" || len(c.Session.IdentityLinks) > 0 {
 		aux.Session = &c.Session
 	}
 
@@ -170,13 +175,59 @@ func (m AgentModelConfig) MarshalJSON() ([]byte, error) {
 }
 
 type AgentConfig struct {
-	ID        string            `json:"id"`
-	Default   bool              `json:"default,omitempty"`
-	Name      string            `json:"name,omitempty"`
-	Workspace string            `json:"workspace,omitempty"`
-	Model     *AgentModelConfig `json:"model,omitempty"`
-	Skills    []string          `json:"skills,omitempty"`
-	Subagents *SubagentsConfig  `json:"subagents,omitempty"`
+	ID        string             `json:"id"`
+	Default   bool               `json:"default,omitempty"`
+	Name      string             `json:"name,omitempty"`
+	Workspace string             `json:"workspace,omitempty"`
+	Model     *AgentModelConfig  `json:"model,omitempty"`
+	Skills    []string           `json:"skills,omitempty"`
+	Subagents *SubagentsConfig   `json:"subagents,omitempty"`
+	Tools     *AgentToolsConfig  `json:"tools,omitempty"`
+	Prompt    *AgentPromptConfig `json:"prompt,omitempty"`
+	Context   *ContextConfig     `json:"context,omitempty"` // replaces agents.defaults.context
+}
+
+// AgentPromptConfig layers an agent's own persona and instructions over the
+// workspace it shares with other agents. File paths are relative to the
+// agent's workspace unless absolute.
+type AgentPromptConfig struct {
+	// SystemPrompt and SystemPromptFile add agent-specific instructions after
+	// the identity section. Both may be set; the file comes second.
+	SystemPrompt     string `json:"system_prompt,omitempty"`
+	SystemPromptFile string `json:"system_prompt_file,omitempty"`
+	// Persona replaces the default "You are picoclaw" introduction.
+	Persona       string `json:"persona,omitempty"`
+	Language      string `json:"language,omitempty"`       // e.g. "German"
+	ResponseStyle string `json:"response_style,omitempty"` // e.g. "short and informal"
+	// BootstrapFiles selects the bootstrap files to load. Unset loads
+	// AGENTS.md, SOUL.md, USER.md and IDENTITY.md; an empty list loads none.
+	// No omitempty: a saved config must keep the difference.
+	BootstrapFiles []string `json:"bootstrap_files"`
+}
+
+// AgentToolsConfig restricts the tools of one agent. Patterns are globs on
+// the tool name ("exec", "web_*"); MCP tools can also be named by server and
+// original tool name as "mcp:server/tool" ("mcp:github/*").
+type AgentToolsConfig struct {
+	Allow    []string        `json:"allow,omitempty"`    // empty allows every tool
+	Deny     []string        `json:"deny,omitempty"`     // wins over allow
+	Policies []ToolArgPolicy `json:"policies,omitempty"` // argument checks per tool
+	// Unrestricted lists tools (exec, read_file, write_file, ...) that run
+	// without restrict_to_workspace for this agent.
+	Unrestricted []string `json:"unrestricted,omitempty"`
+}
+
+// ToolArgPolicy constrains the arguments of the tools matching Tool.
+type ToolArgPolicy struct {
+	Tool string               `json:"tool"`
+	Args map[string]ArgPolicy `json:"args"`
+}
+
+// ArgPolicy holds regular expressions for one argument. A present value
+// must match one Allow pattern (when any are set) and no Deny pattern.
+type ArgPolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
 }
 
 type SubagentsConfig struct {
@@ -195,15 +246,46 @@ type BindingMatch struct {
 	Peer      *PeerMatch `json:"peer,omitempty"`
 	GuildID   string     `json:"guild_id,omitempty"`
 	TeamID    string     `json:"team_id,omitempty"`
+
+	// Conditions. A binding with conditions only matches when all of them
+	// hold, and wins over unconditional bindings at the same level.
+	Content  *ContentMatch  `json:"content,omitempty"`
+	Roles    []string       `json:"roles,omitempty"` // sender has one of these roles
+	Media    []string       `json:"media,omitempty"` // text | voice | image | file
+	Schedule *ScheduleMatch `json:"schedule,omitempty"`
+}
+
+// ContentMatch matches the message text. Set fields must all match.
+// Content bindings are checked before every other binding.
+type ContentMatch struct {
+	Prefix  string `json:"prefix,omitempty"`  // case-insensitive text prefix
+	Regex   string `json:"regex,omitempty"`   // Go regular expression
+	Command string `json:"command,omitempty"` // leading token such as "@coder" or "/code", removed from the message
 }
 
+// ScheduleMatch matches the time the message arrives.
+type ScheduleMatch struct {
+	Days     []string `json:"days,omitempty"`     // mon..sun; empty means every day
+	From     string   `json:"from,omitempty"`     // "HH:MM", inclusive
+	To       string   `json:"to,omitempty"`       // "HH:MM", exclusive; To < From wraps past midnight
+	Timezone string   `json:"timezone,omitempty"` // IANA name; empty means local time
+}
+
+// RolesConfig maps a role name to the sender IDs that hold it. IDs may be
+// raw platform IDs, "platform:id" canonical IDs or usernames.
+type RolesConfig map[string][]string
+
 type AgentBinding struct {
 	AgentID string       `json:"agent_id"`
 	Match   BindingMatch `json:"match"`
 }
 
 type SessionConfig struct {
-	DMScope       string              `json:"dm_scope,omitempty"`
+	DMScope string `json:"dm_scope,omitempty"`
+	// ThreadScope is "per-thread" (default) to give each thread or forum
+	// topic of a group its own session, or "shared" to keep one session per
+	// group across its threads.
+	ThreadScope   string              `json:"thread_scope,omitempty"`
 	IdentityLinks map[string][]string `json:"identity_links,omitempty"`
 }
 
@@ -213,29 +295,177 @@ type SessionConfig struct {
 // Messages scoring below Threshold are sent to LightModel; all others use the
 // agent's primary model. This reduces cost and latency for simple tasks without
 // requiring any keyword matching — all scoring is language-agnostic.
+//
+// Tiers generalizes LightModel/Threshold to several score bands, each with
+// capability gates; when set, LightModel and Threshold are ignored.
 type RoutingConfig struct {
 	Enabled    bool    `json:"enabled"`
 	LightModel string  `json:"light_model"` // model_name from model_list to use for simple tasks
 	Threshold  float64 `json:"threshold"`   // complexity score in [0,1]; score >= threshold → primary model
+
+	Tiers             []RoutingTier `json:"tiers,omitempty"`
+	Classifier        string        `json:"classifier,omitempty"`            // rules (default) | model
+	ClassifierModel   string        `json:"classifier_model,omitempty"`      // model_name used by the model classifier
+	ClassifierTimeout int           `json:"classifier_timeout_ms,omitempty"` // default 1500
+	LongContextTokens int           `json:"long_context_tokens,omitempty"`   // default 16000
+}
+
+// Routing classifiers.
+const (
+	RoutingClassifierRules = "rules"
+	RoutingClassifierModel = "model"
+)
+
+// RoutingTier is one score band below the primary model. Requests scoring
+// below MaxScore go to the first tier whose capabilities cover the request
+// (vision, long_context, tools).
+type RoutingTier struct {
+	Name         string   `json:"name"`
+	Model        string   `json:"model"` // model_name from model_list
+	MaxScore     float64  `json:"max_score"`
+	Capabilities []string `json:"capabilities,omitempty"`
 }
 
 type AgentDefaults struct {
-	Workspace                 string         `json:"workspace"                       env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
-	RestrictToWorkspace       bool           `json:"restrict_to_workspace"           env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
-	AllowReadOutsideWorkspace bool           `json:"allow_read_outside_workspace"    env:"PICOCLAW_AGENTS_DEFAULTS_ALLOW_READ_OUTSIDE_WORKSPACE"`
-	Provider                  string         `json:"provider"                        env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
-	ModelName                 string         `json:"model_name"                      env:"PICOCLAW_AGENTS_DEFAULTS_MODEL_NAME"`
-	Model                     string         `json:"model,omitempty"                 env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"` // Deprecated: use model_name instead
-	ModelFallbacks            []string       `json:"model_fallbacks,omitempty"`
-	ImageModel                string         `json:"image_model,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
-	ImageModelFallbacks       []string       `json:"image_model_fallbacks,omitempty"`
-	MaxTokens                 int            `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
-	Temperature               *float64       `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
-	MaxToolIterations         int            `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
-	SummarizeMessageThreshold int            `json:"summarize_message_threshold"     env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZE_MESSAGE_THRESHOLD"`
-	SummarizeTokenPercent     int            `json:"summarize_token_percent"         env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZE_TOKEN_PERCENT"`
-	MaxMediaSize              int            `json:"max_media_size,omitempty"        env:"PICOCLAW_AGENTS_DEFAULTS_MAX_MEDIA_SIZE"`
-	Routing                   *RoutingConfig `json:"routing,omitempty"`
+	Workspace                 string                `json:"workspace"                       env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
+	RestrictToWorkspace       bool                  `json:"restrict_to_workspace"           env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
+	AllowReadOutsideWorkspace bool                  `json:"allow_read_outside_workspace"    env:"PICOCLAW_AGENTS_DEFAULTS_ALLOW_READ_OUTSIDE_WORKSPACE"`
+	Provider                  string                `json:"provider"                        env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
+	ModelName                 string                `json:"model_name"                      env:"PICOCLAW_AGENTS_DEFAULTS_MODEL_NAME"`
+	Model                     string                `json:"model,omitempty"                 env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"` // Deprecated: use model_name instead
+	ModelFallbacks            []string              `json:"model_fallbacks,omitempty"`
+	ImageModel                string                `json:"image_model,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
+	ImageModelFallbacks       []string              `json:"image_model_fallbacks,omitempty"`
+	MaxTokens                 int                   `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
+	Temperature               *float64              `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
+	MaxToolIterations         int                   `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	SummarizeMessageThreshold int                   `json:"summarize_message_threshold"     env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZE_MESSAGE_THRESHOLD"`
+	SummarizeTokenPercent     int                   `json:"summarize_token_percent"         env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZE_TOKEN_PERCENT"`
+	MaxMediaSize              int                   `json:"max_media_size,omitempty"        env:"PICOCLAW_AGENTS_DEFAULTS_MAX_MEDIA_SIZE"`
+	Routing                   *RoutingConfig        `json:"routing,omitempty"`
+	ModelSelection            *ModelSelectionConfig `json:"model_selection,omitempty"`
+	Context                   *ContextConfig        `json:"context,omitempty"`
+}
+
+// Context window strategies.
+const (
+	ContextStrategySummary       = "summary"        // summarize all but the last few messages (default)
+	ContextStrategySlidingWindow = "sliding_window" // drop everything before the last keep_turns turns
+	ContextStrategySummaryRecent = "summary_recent" // summarize everything before the last keep_turns turns
+	ContextStrategyToolElision   = "tool_elision"   // replace old large tool results with re-fetchable stubs
+	ContextStrategyHierarchical  = "hierarchical"   // summaries of summaries, merged level by level
+)
+
+// ContextConfig selects how an agent keeps its conversation within the
+// context window once summarize_message_threshold or summarize_token_percent
+// is reached, and how tokens are counted.
+type ContextConfig struct {
+	Strategy        string `json:"strategy,omitempty"`
+	KeepTurns       int    `json:"keep_turns,omitempty"`       // recent turns kept verbatim, default 4
+	ElideMinChars   int    `json:"elide_min_chars,omitempty"`  // tool_elision: results longer than this are elided, default 2000
+	HierarchyFanout int    `json:"hierarchy_fanout,omitempty"` // hierarchical: summaries merged per level, default 4
+	Tokenizer       string `json:"tokenizer,omitempty"`        // auto (default; exact for OpenAI models with -tags tiktoken) | heuristic
+}
+
+const (
+	defaultContextKeepTurns       = 4
+	defaultContextElideMinChars   = 2000
+	defaultContextHierarchyFanout = 4
+)
+
+// GetStrategy returns the configured strategy, defaulting to summary.
+func (c *ContextConfig) GetStrategy() string {
+	if c == nil {
+		return ContextStrategySummary
+	}
+	switch s := strings.ToLower(strings.TrimSpace(c.Strategy)); s {
+	case ContextStrategySlidingWindow, ContextStrategySummaryRecent,
+		ContextStrategyToolElision, ContextStrategyHierarchical:
+		return s
+	}
+	return ContextStrategySummary
+}
+
+// GetKeepTurns returns the number of recent turns to keep, applying the default when unset.
+func (c *ContextConfig) GetKeepTurns() int {
+	if c != nil && c.KeepTurns > 0 {
+		return c.KeepTurns
+	}
+	return defaultContextKeepTurns
+}
+
+// GetTokenizer returns the tokenizer setting, defaulting to auto.
+func (c *ContextConfig) GetTokenizer() string {
+	if c != nil && c.Tokenizer != "" {
+		return c.Tokenizer
+	}
+	return "auto"
+}
+
+// GetElideMinChars returns the tool result size above which results are elided.
+func (c *ContextConfig) GetElideMinChars() int {
+	if c != nil && c.ElideMinChars > 0 {
+		return c.ElideMinChars
+	}
+	return defaultContextElideMinChars
+}
+
+// GetHierarchyFanout returns how many summaries are merged into one at the
+// next level, applying the default when unset.
+func (c *ContextConfig) GetHierarchyFanout() int {
+	if c != nil && c.HierarchyFanout > 1 {
+		return c.HierarchyFanout
+	}
+	return defaultContextHierarchyFanout
+}
+
+// Model selection strategies for the fallback chain.
+const (
+	ModelStrategyOrdered = "ordered" // try candidates in configured order (default)
+	ModelStrategyLatency = "latency" // prefer candidates with the best observed latency and success rate
+)
+
+// ModelSelectionConfig controls how the fallback chain orders candidates and
+// whether they are health-probed in the background.
+type ModelSelectionConfig struct {
+	Strategy string      `json:"strategy,omitempty"` // ordered | latency
+	Probe    ProbeConfig `json:"probe"`
+}
+
+// ProbeConfig configures the background model health prober.
+type ProbeConfig struct {
+	Enabled  bool `json:"enabled"`
+	Interval int  `json:"interval,omitempty"` // seconds between probe rounds, default 300
+	Timeout  int  `json:"timeout,omitempty"`  // seconds per probe request, default 20
+}
+
+const (
+	defaultProbeInterval = 300
+	defaultProbeTimeout  = 20
+)
+
+// GetStrategy returns the configured strategy, defaulting to ordered.
+func (m *ModelSelectionConfig) GetStrategy() string {
+	if m != nil && m.Strategy == ModelStrategyLatency {
+		return ModelStrategyLatency
+	}
+	return ModelStrategyOrdered
+}
+
+// GetInterval returns the probe interval, applying the default when unset.
+func (p ProbeConfig) GetInterval() time.Duration {
+	if p.Interval > 0 {
+		return time.Duration(p.Interval) * time.Second
+	}
+	return defaultProbeInterval * time.Second
+}
+
+// GetTimeout returns the per-probe timeout, applying the default when unset.
+func (p ProbeConfig) GetTimeout() time.Duration {
+	if p.Timeout > 0 {
+		return time.Duration(p.Timeout) * time.Second
+	}
+	return defaultProbeTimeout * time.Second
 }
 
 const DefaultMaxMediaSize = 20 * 1024 * 1024 // 20 MB
@@ -273,6 +503,101 @@ type ChannelsConfig struct {
 	WeComAIBot WeComAIBotConfig `json:"wecom_aibot"`
 	Pico       PicoConfig       `json:"pico"`
 	IRC        IRCConfig        `json:"irc"`
+	Email      EmailConfig      `json:"email"`
+	Signal     SignalConfig     `json:"signal"`
+	MQTT       MQTTConfig       `json:"mqtt"`
+	Webhook    WebhookConfig    `json:"webhook"`
+	Mattermost MattermostConfig `json:"mattermost"`
+	RocketChat RocketChatConfig `json:"rocketchat"`
+	XMPP       XMPPConfig       `json:"xmpp"`
+
+	// Accounts are further named accounts of the channel types above,
+	// e.g. a second Telegram bot.
+	Accounts []ChannelAccountConfig `json:"accounts,omitempty"`
+}
+
+// ChannelAccountConfig declares a named account of a channel type. Its
+// settings are laid over a copy of the channel's own section, so shared
+// options such as allow_from need not be repeated; credentials must be
+// set per account (see accountIdentityFields).
+type ChannelAccountConfig struct {
+	ID       string          `json:"id"`      // e.g. "team"; used as account_id in bindings
+	Channel  string          `json:"channel"` // section name, e.g. "telegram"
+	Settings json.RawMessage `json:"settings,omitempty"`
+}
+
+var accountIDRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// accountIdentityFields are the settings, by JSON name, that tell one
+// account of a channel from another: credentials, and the login or endpoint
+// for channels without them. An account must differ from the primary section
+// in at least one of those its channel has, or it would run a second worker
+// on the primary bot (and, e.g., fight it over Telegram's getUpdates).
+var accountIdentityFields = map[string]bool{
+	"token": true, "bot_token": true, "app_token": true, "access_token": true,
+	"channel_access_token": true, "channel_secret": true,
+	"app_id": true, "app_secret": true, "client_id": true, "client_secret": true,
+	"corp_id": true, "corp_secret": true, "user_id": true, "username": true,
+	"password": true, "account": true, "jid": true, "nick": true, "address": true,
+	"bridge_url": true, "session_store_path": true, "host": true, "ws_url": true,
+	"webhook_url": true,
+}
+
+// sameIdentity reports whether an account section identifies the same
+// account as the primary one. Sections without identity fields never match.
+func sameIdentity(primary, account reflect.Value) bool {
+	found := false
+	for i := range primary.NumField() {
+		name, _, _ := strings.Cut(primary.Type().Field(i).Tag.Get("json"), ",")
+		if !accountIdentityFields[name] || primary.Field(i).Kind() != reflect.String {
+			continue
+		}
+		if primary.Field(i).String() != account.Field(i).String() {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// ForAccount returns a copy of the channels config in which the section of
+// acc.Channel holds the account's effective settings. The account is
+// enabled unless its settings say otherwise.
+func (c *ChannelsConfig) ForAccount(acc ChannelAccountConfig) (*ChannelsConfig, error) {
+	if !accountIDRe.MatchString(acc.ID) || acc.ID == "default" {
+		return nil, fmt.Errorf("invalid account id %q: use lowercase letters, digits, '-' and '_'", acc.ID)
+	}
+	out := *c
+	v := reflect.ValueOf(&out).Elem()
+	for i := range v.NumField() {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if name != acc.Channel || name == "accounts" {
+			continue
+		}
+		// Round-trip through JSON so that slices and maps are not shared
+		// with the primary section.
+		section := v.Field(i)
+		base, err := json.Marshal(section.Interface())
+		if err != nil {
+			return nil, err
+		}
+		merged := reflect.New(section.Type())
+		for _, layer := range [][]byte{base, []byte(`{"enabled":true}`), acc.Settings} {
+			if len(layer) == 0 {
+				continue
+			}
+			if err := json.Unmarshal(layer, merged.Interface()); err != nil {
+				return nil, fmt.Errorf("account %s/%s: %w", acc.Channel, acc.ID, err)
+			}
+		}
+		if sameIdentity(section, merged.Elem()) {
+			return nil, fmt.Errorf("account %s/%s: credentials are the same as the primary %s section; "+
+				"set the account's own in settings", acc.Channel, acc.ID, acc.Channel)
+		}
+		section.Set(merged.Elem())
+		return &out, nil
+	}
+	return nil, fmt.Errorf("account %q: unknown channel %q", acc.ID, acc.Channel)
 }
 
 // GroupTriggerConfig controls when the bot responds in group chats.
@@ -492,6 +817,140 @@ type IRCConfig struct {
 	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_IRC_REASONING_CHANNEL_ID"`
 }
 
+// EmailConfig configures the email channel: IMAP for inbound mail, SMTP
+// for replies. Each mail thread is one chat.
+type EmailConfig struct {
+	Enabled            bool                `json:"enabled"              env:"PICOCLAW_CHANNELS_EMAIL_ENABLED"`
+	IMAPServer         string              `json:"imap_server"          env:"PICOCLAW_CHANNELS_EMAIL_IMAP_SERVER"` // host:port
+	IMAPTLS            bool                `json:"imap_tls"             env:"PICOCLAW_CHANNELS_EMAIL_IMAP_TLS"`    // implicit TLS (port 993); otherwise STARTTLS, required off-host
+	SMTPServer         string              `json:"smtp_server"          env:"PICOCLAW_CHANNELS_EMAIL_SMTP_SERVER"` // host:port
+	SMTPTLS            bool                `json:"smtp_tls"             env:"PICOCLAW_CHANNELS_EMAIL_SMTP_TLS"`    // implicit TLS (port 465); otherwise STARTTLS when offered
+	Username           string              `json:"username"             env:"PICOCLAW_CHANNELS_EMAIL_USERNAME"`
+	Password           string              `json:"password"             env:"PICOCLAW_CHANNELS_EMAIL_PASSWORD"`
+	Address            string              `json:"address"              env:"PICOCLAW_CHANNELS_EMAIL_ADDRESS"`       // From address, defaults to username
+	Mailbox            string              `json:"mailbox,omitempty"    env:"PICOCLAW_CHANNELS_EMAIL_MAILBOX"`       // default INBOX
+	PollInterval       int                 `json:"poll_interval"        env:"PICOCLAW_CHANNELS_EMAIL_POLL_INTERVAL"` // seconds, used when the server lacks IDLE
+	AllowFrom          FlexibleStringSlice `json:"allow_from"           env:"PICOCLAW_CHANNELS_EMAIL_ALLOW_FROM"`    // addresses or @domain
+	ReasoningChannelID string              `json:"reasoning_channel_id" env:"PICOCLAW_CHANNELS_EMAIL_REASONING_CHANNEL_ID"`
+}
+
+// SignalConfig configures the Signal channel, which talks to a signal-cli
+// daemon over JSON-RPC.
+type SignalConfig struct {
+	Enabled            bool                `json:"enabled"                  env:"PICOCLAW_CHANNELS_SIGNAL_ENABLED"`
+	Account            string              `json:"account"                  env:"PICOCLAW_CHANNELS_SIGNAL_ACCOUNT"` // the bot's number, e.g. +15551234567
+	URL                string              `json:"url"                      env:"PICOCLAW_CHANNELS_SIGNAL_URL"`     // http://host:port, tcp://host:port or unix:///path
+	AllowFrom          FlexibleStringSlice `json:"allow_from"               env:"PICOCLAW_CHANNELS_SIGNAL_ALLOW_FROM"`
+	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
+	Typing             TypingConfig        `json:"typing,omitempty"`
+	ReactionEmoji      string              `json:"reaction_emoji,omitempty" env:"PICOCLAW_CHANNELS_SIGNAL_REACTION_EMOJI"`
+	ReasoningChannelID string              `json:"reasoning_channel_id"     env:"PICOCLAW_CHANNELS_SIGNAL_REASONING_CHANNEL_ID"`
+}
+
+// MQTTConfig configures the MQTT channel for devices on the local network.
+// AllowFrom matches the client_id devices report in their payloads; it does
+// not authenticate anyone, so the broker's ACLs must limit who can publish
+// on the subscribed topics. A payload's response_topic is only honored
+// under ResponseTopic's prefix.
+type MQTTConfig struct {
+	Enabled            bool                `json:"enabled"                        env:"PICOCLAW_CHANNELS_MQTT_ENABLED"`
+	Broker             string              `json:"broker"                         env:"PICOCLAW_CHANNELS_MQTT_BROKER"` // tcp://, ssl:// or ws:// URL
+	ClientID           string              `json:"client_id,omitempty"            env:"PICOCLAW_CHANNELS_MQTT_CLIENT_ID"`
+	Username           string              `json:"username,omitempty"             env:"PICOCLAW_CHANNELS_MQTT_USERNAME"`
+	Password           string              `json:"password,omitempty"             env:"PICOCLAW_CHANNELS_MQTT_PASSWORD"`
+	CAFile             string              `json:"ca_file,omitempty"              env:"PICOCLAW_CHANNELS_MQTT_CA_FILE"`
+	CertFile           string              `json:"cert_file,omitempty"            env:"PICOCLAW_CHANNELS_MQTT_CERT_FILE"`
+	KeyFile            string              `json:"key_file,omitempty"             env:"PICOCLAW_CHANNELS_MQTT_KEY_FILE"`
+	InsecureSkipVerify bool                `json:"insecure_skip_verify,omitempty" env:"PICOCLAW_CHANNELS_MQTT_INSECURE_SKIP_VERIFY"`
+	Topics             []MQTTTopicConfig   `json:"topics,omitempty"`
+	ResponseTopic      string              `json:"response_topic,omitempty"       env:"PICOCLAW_CHANNELS_MQTT_RESPONSE_TOPIC"` // {chat_id} is replaced
+	StatusTopic        string              `json:"status_topic,omitempty"         env:"PICOCLAW_CHANNELS_MQTT_STATUS_TOPIC"`
+	QoS                int                 `json:"qos"                            env:"PICOCLAW_CHANNELS_MQTT_QOS"`
+	PayloadFormat      string              `json:"payload_format,omitempty"       env:"PICOCLAW_CHANNELS_MQTT_PAYLOAD_FORMAT"` // "json" (default) or "text"
+	AllowFrom          FlexibleStringSlice `json:"allow_from"                     env:"PICOCLAW_CHANNELS_MQTT_ALLOW_FROM"`
+	ReasoningChannelID string              `json:"reasoning_channel_id"           env:"PICOCLAW_CHANNELS_MQTT_REASONING_CHANNEL_ID"`
+}
+
+// MQTTTopicConfig is a topic filter the MQTT channel subscribes to. Messages
+// go to ChatID when set; otherwise the chat is the payload's client_id, or
+// the topic level matched by the filter's first wildcard.
+type MQTTTopicConfig struct {
+	Topic  string `json:"topic"`
+	ChatID string `json:"chat_id,omitempty"`
+}
+
+// WebhookConfig configures the generic HTTP webhook channel. Each instance
+// is a separate channel named "webhook_<name>" ("webhook" when unnamed).
+type WebhookConfig struct {
+	Enabled   bool                    `json:"enabled"   env:"PICOCLAW_CHANNELS_WEBHOOK_ENABLED"`
+	Instances []WebhookInstanceConfig `json:"instances"`
+}
+
+type WebhookInstanceConfig struct {
+	Name               string              `json:"name,omitempty"`
+	Path               string              `json:"path,omitempty"`             // default /webhook/custom[/<name>]
+	Secret             string              `json:"secret"`                     // HMAC-SHA256 key for requests and callbacks
+	SignatureHeader    string              `json:"signature_header,omitempty"` // default X-Signature-256
+	SenderField        string              `json:"sender_field,omitempty"`     // dot path, default "sender"
+	ChatField          string              `json:"chat_field,omitempty"`       // dot path, default "chat_id"
+	ContentField       string              `json:"content_field,omitempty"`    // dot path, default "text"
+	MessageIDField     string              `json:"message_id_field,omitempty"` // dot path, default "message_id"
+	CallbackField      string              `json:"callback_field,omitempty"`   // dot path, default "callback_url"
+	ReplyMode          string              `json:"reply_mode,omitempty"`       // "sync" (default) or "callback"
+	ReplyTimeout       int                 `json:"reply_timeout,omitempty"`    // seconds, sync mode only
+	AllowFrom          FlexibleStringSlice `json:"allow_from"`
+	ReasoningChannelID string              `json:"reasoning_channel_id"`
+}
+
+type MattermostConfig struct {
+	Enabled            bool                `json:"enabled"                 env:"PICOCLAW_CHANNELS_MATTERMOST_ENABLED"`
+	URL                string              `json:"url"                     env:"PICOCLAW_CHANNELS_MATTERMOST_URL"`   // e.g. https://chat.example.com
+	Token              string              `json:"token"                   env:"PICOCLAW_CHANNELS_MATTERMOST_TOKEN"` // bot or personal access token
+	AllowFrom          FlexibleStringSlice `json:"allow_from"              env:"PICOCLAW_CHANNELS_MATTERMOST_ALLOW_FROM"`
+	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
+	Placeholder        PlaceholderConfig   `json:"placeholder,omitempty"`
+	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_MATTERMOST_REASONING_CHANNEL_ID"`
+}
+
+// RocketChatConfig authenticates with a personal access token (UserID and
+// Token) or, when those are empty, by logging in with Username and Password.
+type RocketChatConfig struct {
+	Enabled            bool                `json:"enabled"                 env:"PICOCLAW_CHANNELS_ROCKETCHAT_ENABLED"`
+	URL                string              `json:"url"                     env:"PICOCLAW_CHANNELS_ROCKETCHAT_URL"`
+	UserID             string              `json:"user_id"                 env:"PICOCLAW_CHANNELS_ROCKETCHAT_USER_ID"`
+	Token              string              `json:"token"                   env:"PICOCLAW_CHANNELS_ROCKETCHAT_TOKEN"`
+	Username           string              `json:"username,omitempty"      env:"PICOCLAW_CHANNELS_ROCKETCHAT_USERNAME"`
+	Password           string              `json:"password,omitempty"      env:"PICOCLAW_CHANNELS_ROCKETCHAT_PASSWORD"`
+	AllowFrom          FlexibleStringSlice `json:"allow_from"              env:"PICOCLAW_CHANNELS_ROCKETCHAT_ALLOW_FROM"`
+	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
+	Placeholder        PlaceholderConfig   `json:"placeholder,omitempty"`
+	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_ROCKETCHAT_REASONING_CHANNEL_ID"`
+}
+
+type XMPPConfig struct {
+	Enabled            bool                `json:"enabled"                        env:"PICOCLAW_CHANNELS_XMPP_ENABLED"`
+	JID                string              `json:"jid"                            env:"PICOCLAW_CHANNELS_XMPP_JID"`
+	Password           string              `json:"password"                       env:"PICOCLAW_CHANNELS_XMPP_PASSWORD"`
+	Server             string              `json:"server,omitempty"               env:"PICOCLAW_CHANNELS_XMPP_SERVER"` // host:port, default from SRV records
+	DirectTLS          bool                `json:"direct_tls,omitempty"           env:"PICOCLAW_CHANNELS_XMPP_DIRECT_TLS"`
+	InsecureSkipVerify bool                `json:"insecure_skip_verify,omitempty" env:"PICOCLAW_CHANNELS_XMPP_INSECURE_SKIP_VERIFY"`
+	Resource           string              `json:"resource,omitempty"             env:"PICOCLAW_CHANNELS_XMPP_RESOURCE"`
+	Nick               string              `json:"nick,omitempty"                 env:"PICOCLAW_CHANNELS_XMPP_NICK"` // MUC nickname, default the JID localpart
+	Rooms              []XMPPRoomConfig    `json:"rooms,omitempty"`
+	UploadService      string              `json:"upload_service,omitempty"       env:"PICOCLAW_CHANNELS_XMPP_UPLOAD_SERVICE"` // XEP-0363 service JID, default discovered
+	AllowFrom          FlexibleStringSlice `json:"allow_from"                     env:"PICOCLAW_CHANNELS_XMPP_ALLOW_FROM"`
+	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
+	Typing             TypingConfig        `json:"typing,omitempty"`
+	Placeholder        PlaceholderConfig   `json:"placeholder,omitempty"`
+	ReasoningChannelID string              `json:"reasoning_channel_id"           env:"PICOCLAW_CHANNELS_XMPP_REASONING_CHANNEL_ID"`
+}
+
+type XMPPRoomConfig struct {
+	JID      string `json:"jid"`
+	Nick     string `json:"nick,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
 type HeartbeatConfig struct {
 	Enabled  bool `json:"enabled"  env:"PICOCLAW_HEARTBEAT_ENABLED"`
 	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
@@ -611,6 +1070,42 @@ type ModelConfig struct {
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
 	RequestTimeout int    `json:"request_timeout,omitempty"`
 	ThinkingLevel  string `json:"thinking_level,omitempty"` // Extended thinking: off|low|medium|high|xhigh|adaptive
+	// StructuredOutput sends response_format natively to OpenAI-compatible
+	// APIs. Unset means only OpenAI's own API; others validate and retry.
+	StructuredOutput *bool `json:"structured_output,omitempty"`
+
+	// Cost accounting
+	Pricing *ModelPricing `json:"pricing,omitempty"` // Token prices used by the usage ledger
+}
+
+// ModelPricing holds token prices per one million tokens. The currency is
+// whatever the user configures; the usage ledger only sums the values.
+// CacheRead and CacheWrite default to Input when zero.
+type ModelPricing struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read,omitempty"`
+	CacheWrite float64 `json:"cache_write,omitempty"`
+}
+
+// Budget actions applied when the monthly usage budget is exhausted.
+const (
+	BudgetActionDowngrade = "downgrade" // switch to the agent's light model (default)
+	BudgetActionRefuse    = "refuse"    // reply with an error instead of calling the LLM
+)
+
+// UsageConfig controls token/cost accounting and optional spending caps.
+type UsageConfig struct {
+	MonthlyBudget float64 `json:"monthly_budget,omitempty" env:"PICOCLAW_USAGE_MONTHLY_BUDGET"` // 0 disables the cap
+	BudgetAction  string  `json:"budget_action,omitempty"  env:"PICOCLAW_USAGE_BUDGET_ACTION"`  // downgrade | refuse
+}
+
+// GetBudgetAction returns the configured budget action, defaulting to downgrade.
+func (u UsageConfig) GetBudgetAction() string {
+	if u.BudgetAction == BudgetActionRefuse {
+		return BudgetActionRefuse
+	}
+	return BudgetActionDowngrade
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -714,6 +1209,20 @@ type ExecConfig struct {
 	TimeoutSeconds      int      `                                 env:"PICOCLAW_TOOLS_EXEC_TIMEOUT_SECONDS"       json:"timeout_seconds"` // 0 means use default (60s)
 }
 
+// SpawnParallelConfig configures the spawn_parallel fan-out tool.
+type SpawnParallelConfig struct {
+	ToolConfig     `    envPrefix:"PICOCLAW_TOOLS_SPAWN_PARALLEL_"`
+	MaxConcurrency int `env:"PICOCLAW_TOOLS_SPAWN_PARALLEL_MAX_CONCURRENCY"  json:"max_concurrency"` // 0 means 4
+	MaxTasks       int `env:"PICOCLAW_TOOLS_SPAWN_PARALLEL_MAX_TASKS"        json:"max_tasks"`       // 0 means 10
+	TimeoutSeconds int `env:"PICOCLAW_TOOLS_SPAWN_PARALLEL_TIMEOUT_SECONDS"  json:"timeout_seconds"` // 0 means 300
+}
+
+// AskUserToolConfig configures the ask_user tool.
+type AskUserToolConfig struct {
+	ToolConfig     `    envPrefix:"PICOCLAW_TOOLS_ASK_USER_"`
+	TimeoutSeconds int `env:"PICOCLAW_TOOLS_ASK_USER_TIMEOUT_SECONDS"  json:"timeout_seconds"` // 0 means wait until the turn ends
+}
+
 type SkillsToolsConfig struct {
 	ToolConfig            `                       envPrefix:"PICOCLAW_TOOLS_SKILLS_"`
 	Registries            SkillsRegistriesConfig `                                   json:"registries"`
@@ -734,29 +1243,33 @@ type ReadFileToolConfig struct {
 }
 
 type ToolsConfig struct {
-	AllowReadPaths  []string           `json:"allow_read_paths"  env:"PICOCLAW_TOOLS_ALLOW_READ_PATHS"`
-	AllowWritePaths []string           `json:"allow_write_paths" env:"PICOCLAW_TOOLS_ALLOW_WRITE_PATHS"`
-	Web             WebToolsConfig     `json:"web"`
-	Cron            CronToolsConfig    `json:"cron"`
-	Exec            ExecConfig         `json:"exec"`
-	Skills          SkillsToolsConfig  `json:"skills"`
-	MediaCleanup    MediaCleanupConfig `json:"media_cleanup"`
-	MCP             MCPConfig          `json:"mcp"`
-	AppendFile      ToolConfig         `json:"append_file"                                              envPrefix:"PICOCLAW_TOOLS_APPEND_FILE_"`
-	EditFile        ToolConfig         `json:"edit_file"                                                envPrefix:"PICOCLAW_TOOLS_EDIT_FILE_"`
-	FindSkills      ToolConfig         `json:"find_skills"                                              envPrefix:"PICOCLAW_TOOLS_FIND_SKILLS_"`
-	I2C             ToolConfig         `json:"i2c"                                                      envPrefix:"PICOCLAW_TOOLS_I2C_"`
-	InstallSkill    ToolConfig         `json:"install_skill"                                            envPrefix:"PICOCLAW_TOOLS_INSTALL_SKILL_"`
-	ListDir         ToolConfig         `json:"list_dir"                                                 envPrefix:"PICOCLAW_TOOLS_LIST_DIR_"`
-	Message         ToolConfig         `json:"message"                                                  envPrefix:"PICOCLAW_TOOLS_MESSAGE_"`
-	ReadFile        ReadFileToolConfig `json:"read_file"                                                envPrefix:"PICOCLAW_TOOLS_READ_FILE_"`
-	SendFile        ToolConfig         `json:"send_file"                                                envPrefix:"PICOCLAW_TOOLS_SEND_FILE_"`
-	Spawn           ToolConfig         `json:"spawn"                                                    envPrefix:"PICOCLAW_TOOLS_SPAWN_"`
-	SpawnStatus     ToolConfig         `json:"spawn_status"                                             envPrefix:"PICOCLAW_TOOLS_SPAWN_STATUS_"`
-	SPI             ToolConfig         `json:"spi"                                                      envPrefix:"PICOCLAW_TOOLS_SPI_"`
-	Subagent        ToolConfig         `json:"subagent"                                                 envPrefix:"PICOCLAW_TOOLS_SUBAGENT_"`
-	WebFetch        ToolConfig         `json:"web_fetch"                                                envPrefix:"PICOCLAW_TOOLS_WEB_FETCH_"`
-	WriteFile       ToolConfig         `json:"write_file"                                               envPrefix:"PICOCLAW_TOOLS_WRITE_FILE_"`
+	AllowReadPaths  []string            `json:"allow_read_paths"  env:"PICOCLAW_TOOLS_ALLOW_READ_PATHS"`
+	AllowWritePaths []string            `json:"allow_write_paths" env:"PICOCLAW_TOOLS_ALLOW_WRITE_PATHS"`
+	Web             WebToolsConfig      `json:"web"`
+	Cron            CronToolsConfig     `json:"cron"`
+	Exec            ExecConfig          `json:"exec"`
+	Skills          SkillsToolsConfig   `json:"skills"`
+	MediaCleanup    MediaCleanupConfig  `json:"media_cleanup"`
+	MCP             MCPConfig           `json:"mcp"`
+	AppendFile      ToolConfig          `json:"append_file"                                              envPrefix:"PICOCLAW_TOOLS_APPEND_FILE_"`
+	AskUser         AskUserToolConfig   `json:"ask_user"`
+	EditFile        ToolConfig          `json:"edit_file"                                                envPrefix:"PICOCLAW_TOOLS_EDIT_FILE_"`
+	FetchToolResult ToolConfig          `json:"fetch_tool_result"                                        envPrefix:"PICOCLAW_TOOLS_FETCH_TOOL_RESULT_"`
+	FindSkills      ToolConfig          `json:"find_skills"                                              envPrefix:"PICOCLAW_TOOLS_FIND_SKILLS_"`
+	Handoff         ToolConfig          `json:"handoff"                                                  envPrefix:"PICOCLAW_TOOLS_HANDOFF_"`
+	I2C             ToolConfig          `json:"i2c"                                                      envPrefix:"PICOCLAW_TOOLS_I2C_"`
+	InstallSkill    ToolConfig          `json:"install_skill"                                            envPrefix:"PICOCLAW_TOOLS_INSTALL_SKILL_"`
+	ListDir         ToolConfig          `json:"list_dir"                                                 envPrefix:"PICOCLAW_TOOLS_LIST_DIR_"`
+	Message         ToolConfig          `json:"message"                                                  envPrefix:"PICOCLAW_TOOLS_MESSAGE_"`
+	ReadFile        ReadFileToolConfig  `json:"read_file"                                                envPrefix:"PICOCLAW_TOOLS_READ_FILE_"`
+	SendFile        ToolConfig          `json:"send_file"                                                envPrefix:"PICOCLAW_TOOLS_SEND_FILE_"`
+	Spawn           ToolConfig          `json:"spawn"                                                    envPrefix:"PICOCLAW_TOOLS_SPAWN_"`
+	SpawnStatus     ToolConfig          `json:"spawn_status"                                             envPrefix:"PICOCLAW_TOOLS_SPAWN_STATUS_"`
+	SpawnParallel   SpawnParallelConfig `json:"spawn_parallel"`
+	SPI             ToolConfig          `json:"spi"                                                      envPrefix:"PICOCLAW_TOOLS_SPI_"`
+	Subagent        ToolConfig          `json:"subagent"                                                 envPrefix:"PICOCLAW_TOOLS_SUBAGENT_"`
+	WebFetch        ToolConfig          `json:"web_fetch"                                                envPrefix:"PICOCLAW_TOOLS_WEB_FETCH_"`
+	WriteFile       ToolConfig          `json:"write_file"                                               envPrefix:"PICOCLAW_TOOLS_WRITE_FILE_"`
 }
 
 type SearchCacheConfig struct {
@@ -1098,10 +1611,16 @@ func (t *ToolsConfig) IsToolEnabled(name string) bool {
 		return t.MediaCleanup.Enabled
 	case "append_file":
 		return t.AppendFile.Enabled
+	case "ask_user":
+		return t.AskUser.Enabled
 	case "edit_file":
 		return t.EditFile.Enabled
+	case "fetch_tool_result":
+		return t.FetchToolResult.Enabled
 	case "find_skills":
 		return t.FindSkills.Enabled
+	case "handoff":
+		return t.Handoff.Enabled
 	case "i2c":
 		return t.I2C.Enabled
 	case "install_skill":
@@ -1116,6 +1635,8 @@ func (t *ToolsConfig) IsToolEnabled(name string) bool {
 		return t.Spawn.Enabled
 	case "spawn_status":
 		return t.SpawnStatus.Enabled
+	case "spawn_parallel":
+		return t.SpawnParallel.Enabled
 	case "spi":
 		return t.SPI.Enabled
 	case "subagent":
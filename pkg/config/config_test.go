@@ -1002,3 +1002,46 @@ func TestLoadConfig_UsesPassphraseProvider(t *testing.T) {
 		t.Errorf("api_key = %q, want %q", cfg.ModelList[0].APIKey, plainKey)
 	}
 }
+
+func TestChannelsConfig_ForAccount(t *testing.T) {
+	c := &ChannelsConfig{}
+	c.Telegram = TelegramConfig{Token: "personal", AllowFrom: FlexibleStringSlice{"alice", "bob"}}
+
+	got, err := c.ForAccount(ChannelAccountConfig{
+		ID:       "team",
+		Channel:  "telegram",
+		Settings: json.RawMessage(`{"token":"team","allow_from":["carol"]}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Telegram.Enabled || got.Telegram.Token != "team" || len(got.Telegram.AllowFrom) != 1 {
+		t.Errorf("account section = %+v", got.Telegram)
+	}
+	if c.Telegram.Enabled || c.Telegram.Token != "personal" || c.Telegram.AllowFrom[0] != "alice" {
+		t.Errorf("primary section modified: %+v", c.Telegram)
+	}
+
+	inherited, err := c.ForAccount(ChannelAccountConfig{
+		ID: "ops", Channel: "telegram", Settings: json.RawMessage(`{"token":"ops"}`),
+	})
+	if err != nil || len(inherited.Telegram.AllowFrom) != 2 {
+		t.Errorf("inherited allow_from = %v, %v", inherited, err)
+	}
+
+	for _, acc := range []ChannelAccountConfig{
+		{ID: "", Channel: "telegram"},
+		{ID: "default", Channel: "telegram"},
+		{ID: "Team A", Channel: "telegram"},
+		{ID: "team", Channel: "accounts"},
+		{ID: "team", Channel: "nope"},
+		{ID: "team", Channel: "telegram", Settings: json.RawMessage(`{"token":1}`)},
+		// Without its own token the account would poll as the primary bot.
+		{ID: "team", Channel: "telegram"},
+		{ID: "team", Channel: "telegram", Settings: json.RawMessage(`{"token":"personal"}`)},
+	} {
+		if _, err := c.ForAccount(acc); err == nil {
+			t.Errorf("ForAccount(%+v) succeeded", acc)
+		}
+	}
+}
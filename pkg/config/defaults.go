@@ -39,7 +39,8 @@ func DefaultConfig() *Config {
 		},
 		Bindings: []AgentBinding{},
 		Session: SessionConfig{
-			DMScope: "per-channel-peer",
+			DMScope:     "per-channel-peer",
+			ThreadScope: "per-thread",
 		},
 		Channels: ChannelsConfig{
 			WhatsApp: WhatsAppConfig{
@@ -498,12 +499,24 @@ func DefaultConfig() *Config {
 			AppendFile: ToolConfig{
 				Enabled: true,
 			},
+			AskUser: AskUserToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false,
+				},
+				TimeoutSeconds: 60,
+			},
 			EditFile: ToolConfig{
 				Enabled: true,
 			},
+			FetchToolResult: ToolConfig{
+				Enabled: true,
+			},
 			FindSkills: ToolConfig{
 				Enabled: true,
 			},
+			Handoff: ToolConfig{
+				Enabled: true,
+			},
 			I2C: ToolConfig{
 				Enabled: false, // Hardware tool - Linux only
 			},
@@ -526,6 +539,12 @@ func DefaultConfig() *Config {
 			SpawnStatus: ToolConfig{
 				Enabled: false,
 			},
+			SpawnParallel: SpawnParallelConfig{
+				ToolConfig:     ToolConfig{Enabled: true},
+				MaxConcurrency: 4,
+				MaxTasks:       10,
+				TimeoutSeconds: 300,
+			},
 			SPI: ToolConfig{
 				Enabled: false, // Hardware tool - Linux only
 			},
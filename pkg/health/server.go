@@ -17,6 +17,7 @@ type Server struct {
 	ready     bool
 	checks    map[string]Check
 	startTime time.Time
+	models    func() any
 }
 
 type Check struct {
@@ -31,6 +32,7 @@ type StatusResponse struct {
 	Uptime string           `json:"uptime"`
 	Checks map[string]Check `json:"checks,omitempty"`
 	Pid    int              `json:"pid"`
+	Models any              `json:"models,omitempty"`
 }
 
 func NewServer(host string, port int) *Server {
@@ -106,6 +108,14 @@ func (s *Server) RegisterCheck(name string, checkFn func() (bool, string)) {
 	}
 }
 
+// SetModelStatus registers a callback whose result is included as "models"
+// in /health responses, e.g. model health probe results.
+func (s *Server) SetModelStatus(fn func() any) {
+	s.mu.Lock()
+	s.models = fn
+	s.mu.Unlock()
+}
+
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -117,6 +127,13 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 		Pid:    os.Getpid(),
 	}
 
+	s.mu.RLock()
+	models := s.models
+	s.mu.RUnlock()
+	if models != nil {
+		resp.Models = models()
+	}
+
 	json.NewEncoder(w).Encode(resp)
 }
 
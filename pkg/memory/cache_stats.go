@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// CacheStats accumulates the prompt-cache usage reported by the provider
+// across all LLM calls made for one session.
+type CacheStats struct {
+	Requests         int `json:"requests"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens"`
+	CacheWriteTokens int `json:"cache_write_tokens"`
+}
+
+// Add folds a single response's usage into the totals.
+func (c *CacheStats) Add(usage *providers.UsageInfo) {
+	if usage == nil {
+		return
+	}
+	c.Requests++
+	c.PromptTokens += usage.PromptTokens
+	c.CacheReadTokens += usage.CacheReadTokens
+	c.CacheWriteTokens += usage.CacheWriteTokens
+}
+
+// HitRate returns the fraction of prompt tokens served from the cache.
+func (c CacheStats) HitRate() float64 {
+	if c.PromptTokens <= 0 {
+		return 0
+	}
+	return float64(c.CacheReadTokens) / float64(c.PromptTokens)
+}
+
+// CacheStatsStore is implemented by stores that persist per-session
+// prompt-cache statistics alongside the conversation.
+type CacheStatsStore interface {
+	// RecordCacheUsage adds the usage of one LLM response to the session totals.
+	RecordCacheUsage(ctx context.Context, sessionKey string, usage *providers.UsageInfo) error
+
+	// GetCacheStats returns the accumulated totals, or zero values if none.
+	GetCacheStats(ctx context.Context, sessionKey string) (CacheStats, error)
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestCacheStats_RecordAndPersist(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("NewJSONLStore: %v", err)
+	}
+	ctx := context.Background()
+	key := "agent:main:test"
+
+	if err := store.AddMessage(ctx, key, "user", "hi"); err != nil {
+		t.Fatalf("AddMessage: %v", err)
+	}
+	if err := store.RecordCacheUsage(ctx, key, &providers.UsageInfo{PromptTokens: 1000, CacheWriteTokens: 800}); err != nil {
+		t.Fatalf("RecordCacheUsage: %v", err)
+	}
+	if err := store.RecordCacheUsage(ctx, key, &providers.UsageInfo{PromptTokens: 1000, CacheReadTokens: 800}); err != nil {
+		t.Fatalf("RecordCacheUsage: %v", err)
+	}
+	if err := store.RecordCacheUsage(ctx, key, nil); err != nil {
+		t.Fatalf("RecordCacheUsage(nil): %v", err)
+	}
+	if err := store.SetSummary(ctx, key, "summary"); err != nil {
+		t.Fatalf("SetSummary: %v", err)
+	}
+
+	reopened, err := NewJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("NewJSONLStore: %v", err)
+	}
+	stats, err := reopened.GetCacheStats(ctx, key)
+	if err != nil {
+		t.Fatalf("GetCacheStats: %v", err)
+	}
+	want := CacheStats{Requests: 2, PromptTokens: 2000, CacheReadTokens: 800, CacheWriteTokens: 800}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	if got := stats.HitRate(); got != 0.4 {
+		t.Errorf("HitRate() = %v, want 0.4", got)
+	}
+}
+
+func TestCacheStats_UnknownSession(t *testing.T) {
+	store := newTestStore(t)
+	stats, err := store.GetCacheStats(context.Background(), "missing")
+	if err != nil {
+		t.Fatalf("GetCacheStats: %v", err)
+	}
+	if stats != (CacheStats{}) || stats.HitRate() != 0 {
+		t.Errorf("stats = %+v, want zero", stats)
+	}
+}
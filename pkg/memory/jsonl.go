@@ -40,6 +40,9 @@ type sessionMeta struct {
 	Count     int       `json:"count"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	Cache   *CacheStats   `json:"cache,omitempty"`
+	Routing *RoutingStats `json:"routing,omitempty"`
 }
 
 // JSONLStore implements Store using append-only JSONL files.
@@ -324,6 +327,87 @@ func (s *JSONLStore) SetSummary(
 	return s.writeMeta(sessionKey, meta)
 }
 
+// RecordCacheUsage implements CacheStatsStore. Totals live in the session's
+// meta file so they survive restarts and are visible to the web UI.
+func (s *JSONLStore) RecordCacheUsage(
+	_ context.Context, sessionKey string, usage *providers.UsageInfo,
+) error {
+	if usage == nil {
+		return nil
+	}
+
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	meta, err := s.readMeta(sessionKey)
+	if err != nil {
+		return err
+	}
+	if meta.Cache == nil {
+		meta.Cache = &CacheStats{}
+	}
+	meta.Cache.Add(usage)
+
+	return s.writeMeta(sessionKey, meta)
+}
+
+// GetCacheStats implements CacheStatsStore.
+func (s *JSONLStore) GetCacheStats(
+	_ context.Context, sessionKey string,
+) (CacheStats, error) {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	meta, err := s.readMeta(sessionKey)
+	if err != nil {
+		return CacheStats{}, err
+	}
+	if meta.Cache == nil {
+		return CacheStats{}, nil
+	}
+	return *meta.Cache, nil
+}
+
+// RecordRouting implements RoutingStatsStore.
+func (s *JSONLStore) RecordRouting(
+	_ context.Context, sessionKey string, d RoutingDecision,
+) error {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	meta, err := s.readMeta(sessionKey)
+	if err != nil {
+		return err
+	}
+	if meta.Routing == nil {
+		meta.Routing = &RoutingStats{}
+	}
+	meta.Routing.Add(d)
+
+	return s.writeMeta(sessionKey, meta)
+}
+
+// GetRoutingStats implements RoutingStatsStore.
+func (s *JSONLStore) GetRoutingStats(
+	_ context.Context, sessionKey string,
+) (RoutingStats, error) {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	meta, err := s.readMeta(sessionKey)
+	if err != nil {
+		return RoutingStats{}, err
+	}
+	if meta.Routing == nil {
+		return RoutingStats{}, nil
+	}
+	return *meta.Routing, nil
+}
+
 func (s *JSONLStore) TruncateHistory(
 	_ context.Context, sessionKey string, keepLast int,
 ) error {
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+// maxRecentRoutingDecisions caps how many individual decisions are kept per
+// session; per-tier counts keep growing.
+const maxRecentRoutingDecisions = 50
+
+// RoutingDecision is one model-routing outcome for a conversation turn.
+type RoutingDecision struct {
+	Time       time.Time `json:"time"`
+	Tier       string    `json:"tier"`
+	Model      string    `json:"model"`
+	Score      float64   `json:"score"`
+	Classifier string    `json:"classifier,omitempty"`
+	Required   []string  `json:"required,omitempty"`
+}
+
+// RoutingStats aggregates the routing decisions made for one session so
+// thresholds and tiers can be tuned from real traffic.
+type RoutingStats struct {
+	Tiers  map[string]int    `json:"tiers"`
+	Recent []RoutingDecision `json:"recent,omitempty"` // oldest first
+}
+
+// Add records one decision, dropping the oldest beyond the cap.
+func (r *RoutingStats) Add(d RoutingDecision) {
+	if r.Tiers == nil {
+		r.Tiers = make(map[string]int)
+	}
+	r.Tiers[d.Tier]++
+	r.Recent = append(r.Recent, d)
+	if n := len(r.Recent); n > maxRecentRoutingDecisions {
+		r.Recent = append([]RoutingDecision(nil), r.Recent[n-maxRecentRoutingDecisions:]...)
+	}
+}
+
+// RoutingStatsStore is implemented by stores that persist per-session
+// routing decisions alongside the conversation.
+type RoutingStatsStore interface {
+	// RecordRouting adds one decision to the session's routing stats.
+	RecordRouting(ctx context.Context, sessionKey string, d RoutingDecision) error
+
+	// GetRoutingStats returns the session's routing stats, or zero values.
+	GetRoutingStats(ctx context.Context, sessionKey string) (RoutingStats, error)
+}
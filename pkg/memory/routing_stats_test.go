@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRoutingStats_RecordAndPersist(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("NewJSONLStore: %v", err)
+	}
+	ctx := context.Background()
+	key := "agent:main:test"
+
+	for _, d := range []RoutingDecision{
+		{Tier: "local", Model: "llama-3b", Score: 0.1, Classifier: "rules"},
+		{Tier: "primary", Model: "gpt-5", Score: 0.8, Classifier: "model"},
+		{Tier: "local", Model: "llama-3b", Score: 0.15, Classifier: "rules"},
+	} {
+		if err := store.RecordRouting(ctx, key, d); err != nil {
+			t.Fatalf("RecordRouting: %v", err)
+		}
+	}
+
+	reopened, err := NewJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("NewJSONLStore: %v", err)
+	}
+	stats, err := reopened.GetRoutingStats(ctx, key)
+	if err != nil {
+		t.Fatalf("GetRoutingStats: %v", err)
+	}
+	if stats.Tiers["local"] != 2 || stats.Tiers["primary"] != 1 {
+		t.Errorf("Tiers = %v", stats.Tiers)
+	}
+	if len(stats.Recent) != 3 || stats.Recent[1].Classifier != "model" {
+		t.Errorf("Recent = %+v", stats.Recent)
+	}
+}
+
+func TestRoutingStats_RecentIsCapped(t *testing.T) {
+	var stats RoutingStats
+	for i := range maxRecentRoutingDecisions + 10 {
+		stats.Add(RoutingDecision{Tier: "local", Score: float64(i)})
+	}
+	if len(stats.Recent) != maxRecentRoutingDecisions {
+		t.Fatalf("len(Recent) = %d, want %d", len(stats.Recent), maxRecentRoutingDecisions)
+	}
+	if stats.Recent[0].Score != 10 {
+		t.Errorf("oldest kept score = %v, want 10", stats.Recent[0].Score)
+	}
+	if stats.Tiers["local"] != maxRecentRoutingDecisions+10 {
+		t.Errorf("tier count = %d", stats.Tiers["local"])
+	}
+}
@@ -94,9 +94,16 @@ func (p *Provider) Chat(
 		return nil, err
 	}
 
+	rf := protocoltypes.ResponseFormatFromOptions(options)
+
 	// OAuth/setup-tokens require streaming; API keys use non-streaming.
 	if p.tokenSource != nil {
-		return p.chatStreaming(ctx, params, opts)
+		out, err := p.chatStreaming(ctx, params, opts)
+		if err != nil {
+			return nil, err
+		}
+		protocoltypes.ExtractForcedToolOutput(out, rf)
+		return out, nil
 	}
 
 	resp, err := p.client.Messages.New(ctx, params, opts...)
@@ -104,7 +111,9 @@ func (p *Provider) Chat(
 		return nil, fmt.Errorf("claude API call: %w", err)
 	}
 
-	return parseResponse(resp), nil
+	out := parseResponse(resp)
+	protocoltypes.ExtractForcedToolOutput(out, rf)
+	return out, nil
 }
 
 func (p *Provider) chatStreaming(
@@ -129,6 +138,10 @@ func (p *Provider) chatStreaming(
 	return parseResponse(&msg), nil
 }
 
+// SupportsStructuredOutput implements providers.StructuredOutputCapable.
+// Anthropic has no response_format; it is emulated by forcing a tool call.
+func (p *Provider) SupportsStructuredOutput() bool { return true }
+
 func (p *Provider) GetDefaultModel() string {
 	return "claude-sonnet-4.6"
 }
@@ -202,6 +215,13 @@ func buildParams(
 				anthropic.NewUserMessage(anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)),
 			)
 		}
+
+		// History breakpoint: everything up to and including this message is
+		// a stable prefix that can be served from the prompt cache next turn.
+		if msg.Role != "system" && msg.CacheControl != nil && msg.CacheControl.Type == "ephemeral" &&
+			len(anthropicMessages) > 0 {
+			markCacheBreakpoint(&anthropicMessages[len(anthropicMessages)-1])
+		}
 	}
 
 	maxTokens := int64(4096)
@@ -239,9 +259,62 @@ func buildParams(
 		applyThinkingConfig(&params, level)
 	}
 
+	if rf := protocoltypes.ResponseFormatFromOptions(options); rf != nil {
+		applyResponseFormat(&params, rf, len(tools) > 0)
+	}
+
 	return params, nil
 }
 
+// markCacheBreakpoint sets an ephemeral cache_control on the last content
+// block of msg, which caches the whole prompt prefix ending there.
+func markCacheBreakpoint(msg *anthropic.MessageParam) {
+	if len(msg.Content) == 0 {
+		return
+	}
+	block := &msg.Content[len(msg.Content)-1]
+	cc := anthropic.NewCacheControlEphemeralParam()
+	switch {
+	case block.OfText != nil:
+		block.OfText.CacheControl = cc
+	case block.OfToolUse != nil:
+		block.OfToolUse.CacheControl = cc
+	case block.OfToolResult != nil:
+		block.OfToolResult.CacheControl = cc
+	}
+}
+
+// applyResponseFormat emulates structured output with tool forcing: the
+// schema becomes a synthetic tool the model must call. When other tools are
+// present tool_choice is "any" so the model may still use them first.
+//
+// Anthropic rejects forced tool use together with extended thinking, so
+// thinking is disabled for structured requests.
+func applyResponseFormat(params *anthropic.MessageNewParams, rf *protocoltypes.ResponseFormat, hasOtherTools bool) {
+	schema, _ := rf.ToolInputSchema()
+	outputTool := translateTools([]ToolDefinition{{
+		Type: "function",
+		Function: ToolFunctionDefinition{
+			Name:        rf.SchemaName(),
+			Description: "Return the final answer as structured data matching the input schema.",
+			Parameters:  schema,
+		},
+	}})
+	params.Tools = append(params.Tools, outputTool...)
+
+	if hasOtherTools {
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{OfAny: &anthropic.ToolChoiceAnyParam{}}
+	} else {
+		params.ToolChoice = anthropic.ToolChoiceParamOfTool(rf.SchemaName())
+	}
+
+	if params.Thinking.OfEnabled != nil || params.Thinking.OfAdaptive != nil {
+		log.Printf("anthropic: thinking disabled because structured output forces tool use")
+		params.Thinking = anthropic.ThinkingConfigParamUnion{}
+		params.OutputConfig = anthropic.OutputConfigParam{}
+	}
+}
+
 // applyThinkingConfig sets thinking parameters based on the level value.
 // "adaptive" uses the adaptive thinking API (Claude 4.6+).
 // All other levels use budget_tokens which is universally supported.
@@ -317,7 +390,8 @@ func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 		if desc := t.Function.Description; desc != "" {
 			tool.Description = anthropic.String(desc)
 		}
-		if req, ok := t.Function.Parameters["required"].([]any); ok {
+		switch req := t.Function.Parameters["required"].(type) {
+		case []any:
 			required := make([]string, 0, len(req))
 			for _, r := range req {
 				if s, ok := r.(string); ok {
@@ -325,6 +399,8 @@ func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 				}
 			}
 			tool.InputSchema.Required = required
+		case []string:
+			tool.InputSchema.Required = req
 		}
 		result = append(result, anthropic.ToolUnionParam{OfTool: &tool})
 	}
@@ -374,11 +450,21 @@ func parseResponse(resp *anthropic.Message) *LLMResponse {
 		Reasoning:    reasoning.String(),
 		ToolCalls:    toolCalls,
 		FinishReason: finishReason,
-		Usage: &UsageInfo{
-			PromptTokens:     int(resp.Usage.InputTokens),
-			CompletionTokens: int(resp.Usage.OutputTokens),
-			TotalTokens:      int(resp.Usage.InputTokens + resp.Usage.OutputTokens),
-		},
+		Usage:        parseUsage(resp.Usage),
+	}
+}
+
+// parseUsage converts Anthropic usage into UsageInfo. Anthropic reports
+// input_tokens excluding cached tokens, so cache reads and writes are added
+// back to get the full prompt size.
+func parseUsage(u anthropic.Usage) *UsageInfo {
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	return &UsageInfo{
+		PromptTokens:     int(prompt),
+		CompletionTokens: int(u.OutputTokens),
+		TotalTokens:      int(prompt + u.OutputTokens),
+		CacheReadTokens:  int(u.CacheReadInputTokens),
+		CacheWriteTokens: int(u.CacheCreationInputTokens),
 	}
 }
 
@@ -9,6 +9,8 @@ import (
 
 	"github.com/anthropics/anthropic-sdk-go"
 	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
 func TestBuildParams_BasicMessage(t *testing.T) {
@@ -123,6 +125,48 @@ func TestParseResponse_TextOnly(t *testing.T) {
 	}
 }
 
+func TestParseResponse_CacheUsage(t *testing.T) {
+	resp := &anthropic.Message{
+		Content: []anthropic.ContentBlockUnion{},
+		Usage: anthropic.Usage{
+			InputTokens:              50,
+			CacheReadInputTokens:     900,
+			CacheCreationInputTokens: 50,
+			OutputTokens:             20,
+		},
+	}
+	u := parseResponse(resp).Usage
+	if u.PromptTokens != 1000 || u.TotalTokens != 1020 {
+		t.Errorf("PromptTokens/TotalTokens = %d/%d, want 1000/1020", u.PromptTokens, u.TotalTokens)
+	}
+	if u.CacheReadTokens != 900 || u.CacheWriteTokens != 50 {
+		t.Errorf("CacheRead/CacheWrite = %d/%d, want 900/50", u.CacheReadTokens, u.CacheWriteTokens)
+	}
+}
+
+func TestBuildParams_HistoryCacheBreakpoint(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "earlier question"},
+		{Role: "assistant", Content: "earlier answer", CacheControl: &protocoltypes.CacheControl{Type: "ephemeral"}},
+		{Role: "user", Content: "new question"},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if len(params.Messages) != 3 {
+		t.Fatalf("len(Messages) = %d, want 3", len(params.Messages))
+	}
+	marked := params.Messages[1].Content[0].OfText
+	if marked == nil || marked.CacheControl.Type == "" {
+		t.Errorf("assistant history message missing cache_control: %+v", params.Messages[1].Content[0])
+	}
+	if params.Messages[2].Content[0].OfText.CacheControl.Type != "" {
+		t.Error("current user message should not carry cache_control")
+	}
+}
+
 func TestParseResponse_StopReasons(t *testing.T) {
 	tests := []struct {
 		stopReason anthropic.StopReason
@@ -328,3 +372,77 @@ func createAnthropicTestClient(baseURL, token string) *anthropic.Client {
 	)
 	return &c
 }
+
+func TestBuildParams_ResponseFormatForcesTool(t *testing.T) {
+	rf := &protocoltypes.ResponseFormat{
+		Type: protocoltypes.ResponseFormatJSONSchema,
+		Name: "verdict",
+		Schema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"ok": map[string]any{"type": "boolean"}},
+			"required":   []string{"ok"},
+		},
+	}
+	params, err := buildParams([]Message{{Role: "user", Content: "Hi"}}, nil, "claude-sonnet-4.6", map[string]any{
+		"thinking_level":                      "high",
+		"max_tokens":                          40000,
+		protocoltypes.ResponseFormatOptionKey: rf,
+	})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if len(params.Tools) != 1 || params.Tools[0].OfTool.Name != "verdict" {
+		t.Fatalf("expected synthetic verdict tool, got %+v", params.Tools)
+	}
+	if got := params.Tools[0].OfTool.InputSchema.Required; len(got) != 1 || got[0] != "ok" {
+		t.Errorf("Required = %v", got)
+	}
+	if params.ToolChoice.OfTool == nil || params.ToolChoice.OfTool.Name != "verdict" {
+		t.Errorf("expected tool_choice forcing verdict, got %+v", params.ToolChoice)
+	}
+	if params.Thinking.OfEnabled != nil {
+		t.Error("thinking must be disabled when forcing tool use")
+	}
+}
+
+func TestBuildParams_ResponseFormatWithToolsUsesAny(t *testing.T) {
+	tools := []ToolDefinition{{
+		Type:     "function",
+		Function: ToolFunctionDefinition{Name: "lookup", Parameters: map[string]any{"type": "object"}},
+	}}
+	params, err := buildParams([]Message{{Role: "user", Content: "Hi"}}, tools, "claude-sonnet-4.6", map[string]any{
+		protocoltypes.ResponseFormatOptionKey: &protocoltypes.ResponseFormat{Type: protocoltypes.ResponseFormatJSONObject},
+	})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if len(params.Tools) != 2 {
+		t.Fatalf("len(Tools) = %d, want 2", len(params.Tools))
+	}
+	if params.ToolChoice.OfAny == nil {
+		t.Errorf("expected tool_choice any, got %+v", params.ToolChoice)
+	}
+}
+
+func TestExtractForcedToolOutput(t *testing.T) {
+	rf := &protocoltypes.ResponseFormat{
+		Type:   protocoltypes.ResponseFormatJSONSchema,
+		Name:   "items",
+		Schema: map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+	}
+	resp := &LLMResponse{
+		FinishReason: "tool_calls",
+		ToolCalls: []ToolCall{{
+			ID:        "t1",
+			Name:      "items",
+			Arguments: map[string]any{"result": []any{"a", "b"}},
+		}},
+	}
+	protocoltypes.ExtractForcedToolOutput(resp, rf)
+	if resp.Content != `["a","b"]` {
+		t.Errorf("Content = %q, want unwrapped array", resp.Content)
+	}
+	if len(resp.ToolCalls) != 0 || resp.FinishReason != "stop" {
+		t.Errorf("tool call should be consumed, got %+v", resp)
+	}
+}
@@ -140,7 +140,18 @@ func (p *Provider) Chat(
 	}
 
 	// Parse response
-	return parseResponseBody(body)
+	out, err := parseResponseBody(body)
+	if err != nil {
+		return nil, err
+	}
+	protocoltypes.ExtractForcedToolOutput(out, protocoltypes.ResponseFormatFromOptions(options))
+	return out, nil
+}
+
+// SupportsStructuredOutput reports that response_format is emulated natively
+// by forcing a tool call whose input schema is the requested JSON Schema.
+func (p *Provider) SupportsStructuredOutput() bool {
+	return true
 }
 
 // GetDefaultModel returns the default model for this provider.
@@ -255,6 +266,14 @@ func buildRequestBody(
 				"content": content,
 			})
 		}
+
+		// History breakpoint: cache the prompt prefix ending at this message.
+		if msg.Role != "system" && msg.CacheControl != nil && msg.CacheControl.Type == "ephemeral" &&
+			len(apiMessages) > 0 {
+			if last, ok := apiMessages[len(apiMessages)-1].(map[string]any); ok {
+				markCacheBreakpoint(last, msg.CacheControl.Type)
+			}
+		}
 	}
 
 	result["messages"] = apiMessages
@@ -269,9 +288,51 @@ func buildRequestBody(
 		result["tools"] = buildTools(tools)
 	}
 
+	// Structured output via tool forcing (no native response_format).
+	// With other tools present use "any" so they remain callable.
+	if rf := protocoltypes.ResponseFormatFromOptions(options); rf != nil {
+		schema, _ := rf.ToolInputSchema()
+		toolDefs, _ := result["tools"].([]any)
+		result["tools"] = append(toolDefs, map[string]any{
+			"name":         rf.SchemaName(),
+			"description":  "Return the final answer as structured data matching the input schema.",
+			"input_schema": schema,
+		})
+		if len(tools) > 0 {
+			result["tool_choice"] = map[string]any{"type": "any"}
+		} else {
+			result["tool_choice"] = map[string]any{"type": "tool", "name": rf.SchemaName()}
+		}
+	}
+
 	return result, nil
 }
 
+// markCacheBreakpoint sets cache_control on the last content block of an API
+// message. Plain string content is promoted to a single text block first.
+func markCacheBreakpoint(apiMsg map[string]any, cacheType string) {
+	cc := map[string]any{"type": cacheType}
+	switch content := apiMsg["content"].(type) {
+	case string:
+		if content == "" {
+			return
+		}
+		apiMsg["content"] = []any{
+			map[string]any{"type": "text", "text": content, "cache_control": cc},
+		}
+	case []map[string]any:
+		if len(content) > 0 {
+			content[len(content)-1]["cache_control"] = cc
+		}
+	case []any:
+		if len(content) > 0 {
+			if block, ok := content[len(content)-1].(map[string]any); ok {
+				block["cache_control"] = cc
+			}
+		}
+	}
+}
+
 // buildTools converts tool definitions to Anthropic format.
 func buildTools(tools []ToolDefinition) []any {
 	result := make([]any, len(tools))
@@ -332,11 +393,7 @@ func parseResponseBody(body []byte) (*LLMResponse, error) {
 		Content:      content.String(),
 		ToolCalls:    toolCalls,
 		FinishReason: finishReason,
-		Usage: &UsageInfo{
-			PromptTokens:     int(resp.Usage.InputTokens),
-			CompletionTokens: int(resp.Usage.OutputTokens),
-			TotalTokens:      int(resp.Usage.InputTokens + resp.Usage.OutputTokens),
-		},
+		Usage:        resp.Usage.toUsageInfo(),
 	}, nil
 }
 
@@ -416,6 +473,21 @@ type contentBlock struct {
 }
 
 type usageInfo struct {
-	InputTokens  int64 `json:"input_tokens"`
-	OutputTokens int64 `json:"output_tokens"`
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+}
+
+// toUsageInfo converts Anthropic usage into UsageInfo. input_tokens excludes
+// cached tokens, so cache reads and writes are added back to PromptTokens.
+func (u usageInfo) toUsageInfo() *UsageInfo {
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	return &UsageInfo{
+		PromptTokens:     int(prompt),
+		CompletionTokens: int(u.OutputTokens),
+		TotalTokens:      int(prompt + u.OutputTokens),
+		CacheReadTokens:  int(u.CacheReadInputTokens),
+		CacheWriteTokens: int(u.CacheCreationInputTokens),
+	}
 }
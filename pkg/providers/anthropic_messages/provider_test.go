@@ -11,6 +11,8 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
 func TestBuildRequestBody(t *testing.T) {
@@ -372,6 +374,51 @@ func TestParseResponseBody(t *testing.T) {
 	}
 }
 
+func TestParseResponseBody_CacheUsage(t *testing.T) {
+	body := []byte(`{
+		"content": [{"type": "text", "text": "ok"}],
+		"stop_reason": "end_turn",
+		"usage": {
+			"input_tokens": 10,
+			"output_tokens": 5,
+			"cache_read_input_tokens": 80,
+			"cache_creation_input_tokens": 10
+		}
+	}`)
+	got, err := parseResponseBody(body)
+	if err != nil {
+		t.Fatalf("parseResponseBody() error = %v", err)
+	}
+	want := &UsageInfo{PromptTokens: 100, CompletionTokens: 5, TotalTokens: 105, CacheReadTokens: 80, CacheWriteTokens: 10}
+	if *got.Usage != *want {
+		t.Errorf("Usage = %+v, want %+v", *got.Usage, *want)
+	}
+}
+
+func TestBuildRequestBody_HistoryCacheBreakpoint(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "earlier question", CacheControl: &protocoltypes.CacheControl{Type: "ephemeral"}},
+		{Role: "user", Content: "new question"},
+	}
+	body, err := buildRequestBody(messages, nil, "test-model", map[string]any{"max_tokens": 100})
+	if err != nil {
+		t.Fatalf("buildRequestBody() error = %v", err)
+	}
+	apiMessages := body["messages"].([]any)
+	first := apiMessages[0].(map[string]any)
+	blocks, ok := first["content"].([]any)
+	if !ok || len(blocks) != 1 {
+		t.Fatalf("marked message content = %#v, want one block", first["content"])
+	}
+	block := blocks[0].(map[string]any)
+	if block["text"] != "earlier question" || block["cache_control"] == nil {
+		t.Errorf("block = %#v, want text with cache_control", block)
+	}
+	if second := apiMessages[1].(map[string]any); second["content"] != "new question" {
+		t.Errorf("unmarked message content = %#v, want plain string", second["content"])
+	}
+}
+
 func TestNormalizeBaseURL(t *testing.T) {
 	tests := []struct {
 		name     string
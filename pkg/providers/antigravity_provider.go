@@ -147,6 +147,12 @@ func (p *AntigravityProvider) Chat(
 	return llmResp, nil
 }
 
+// SupportsStructuredOutput implements StructuredOutputCapable via Gemini's
+// responseSchema generation config.
+func (p *AntigravityProvider) SupportsStructuredOutput() bool {
+	return true
+}
+
 // GetDefaultModel returns the default model identifier.
 func (p *AntigravityProvider) GetDefaultModel() string {
 	return antigravityDefaultModel
@@ -199,8 +205,10 @@ type antigravitySystemPrompt struct {
 }
 
 type antigravityGenConfig struct {
-	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
-	Temperature     float64 `json:"temperature,omitempty"`
+	MaxOutputTokens  int     `json:"maxOutputTokens,omitempty"`
+	Temperature      float64 `json:"temperature,omitempty"`
+	ResponseMimeType string  `json:"responseMimeType,omitempty"`
+	ResponseSchema   any     `json:"responseSchema,omitempty"`
 }
 
 func (p *AntigravityProvider) buildRequest(
@@ -212,6 +220,15 @@ func (p *AntigravityProvider) buildRequest(
 	req := antigravityRequest{}
 	toolCallNames := make(map[string]string)
 
+	// Gemini rejects a JSON response type combined with function calling.
+	// With tools the format is asked for in the system prompt instead, and
+	// ChatStructured validates the final answer either way.
+	rf := ResponseFormatFromOptions(options)
+	if rf != nil && len(tools) > 0 {
+		messages = injectFormatInstructions(messages, rf)
+		rf = nil
+	}
+
 	// Build contents from messages
 	for _, msg := range messages {
 		switch msg.Role {
@@ -321,7 +338,14 @@ func (p *AntigravityProvider) buildRequest(
 	if temp, ok := options["temperature"].(float64); ok {
 		config.Temperature = temp
 	}
-	if config.MaxOutputTokens > 0 || config.Temperature > 0 {
+	// Structured output maps to Gemini's responseMimeType + responseSchema.
+	if rf != nil {
+		config.ResponseMimeType = "application/json"
+		if rf.Type == ResponseFormatJSONSchema && len(rf.Schema) > 0 {
+			config.ResponseSchema = sanitizeSchemaForGemini(rf.Schema)
+		}
+	}
+	if config.MaxOutputTokens > 0 || config.Temperature > 0 || config.ResponseMimeType != "" {
 		req.Config = config
 	}
 
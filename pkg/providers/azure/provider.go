@@ -115,6 +115,10 @@ func (p *Provider) Chat(
 		requestBody["temperature"] = temperature
 	}
 
+	if rf := protocoltypes.ResponseFormatFromOptions(options); rf != nil {
+		requestBody["response_format"] = common.SerializeResponseFormat(rf)
+	}
+
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -144,6 +148,9 @@ func (p *Provider) Chat(
 	return common.ReadAndParseResponse(resp, p.apiBase)
 }
 
+// SupportsStructuredOutput reports that response_format is sent natively.
+func (p *Provider) SupportsStructuredOutput() bool { return true }
+
 // GetDefaultModel returns an empty string as Azure deployments are user-configured.
 func (p *Provider) GetDefaultModel() string {
 	return ""
@@ -51,6 +51,11 @@ func (p *ClaudeProvider) Chat(
 	return resp, nil
 }
 
+// SupportsStructuredOutput implements StructuredOutputCapable.
+func (p *ClaudeProvider) SupportsStructuredOutput() bool {
+	return p.delegate.SupportsStructuredOutput()
+}
+
 func (p *ClaudeProvider) GetDefaultModel() string {
 	return p.delegate.GetDefaultModel()
 }
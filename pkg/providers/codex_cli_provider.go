@@ -188,6 +188,7 @@ func (p *CodexCliProvider) parseJSONLEvents(output string) (*LLMResponse, error)
 					PromptTokens:     promptTokens,
 					CompletionTokens: event.Usage.OutputTokens,
 					TotalTokens:      promptTokens + event.Usage.OutputTokens,
+					CacheReadTokens:  event.Usage.CachedInputTokens,
 				}
 			}
 		case "error":
@@ -10,6 +10,7 @@ import (
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
 	"github.com/openai/openai-go/v3/responses"
+	"github.com/openai/openai-go/v3/shared"
 
 	"github.com/sipeed/picoclaw/pkg/auth"
 	"github.com/sipeed/picoclaw/pkg/logger"
@@ -153,6 +154,12 @@ func (p *CodexProvider) Chat(
 	return parseCodexResponse(resp), nil
 }
 
+// SupportsStructuredOutput implements StructuredOutputCapable via the
+// Responses API text.format field.
+func (p *CodexProvider) SupportsStructuredOutput() bool {
+	return true
+}
+
 func (p *CodexProvider) GetDefaultModel() string {
 	return codexDefaultModel
 }
@@ -305,6 +312,23 @@ func buildCodexParams(
 		params.Tools = translateToolsForCodex(tools, enableWebSearch)
 	}
 
+	// Structured output: the Responses API carries it as text.format.
+	if rf := ResponseFormatFromOptions(options); rf != nil {
+		if rf.Type == ResponseFormatJSONSchema && len(rf.Schema) > 0 {
+			params.Text.Format = responses.ResponseFormatTextConfigUnionParam{
+				OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
+					Name:   rf.SchemaName(),
+					Schema: rf.Schema,
+					Strict: openai.Opt(rf.Strict),
+				},
+			}
+		} else {
+			params.Text.Format = responses.ResponseFormatTextConfigUnionParam{
+				OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+			}
+		}
+	}
+
 	return params
 }
 
@@ -400,6 +424,7 @@ func parseCodexResponse(resp *responses.Response) *LLMResponse {
 			PromptTokens:     int(resp.Usage.InputTokens),
 			CompletionTokens: int(resp.Usage.OutputTokens),
 			TotalTokens:      int(resp.Usage.TotalTokens),
+			CacheReadTokens:  int(resp.Usage.InputTokensDetails.CachedTokens),
 		}
 	}
 
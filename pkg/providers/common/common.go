@@ -132,6 +132,27 @@ func SerializeMessages(messages []Message) []any {
 	return out
 }
 
+// --- Structured output ---
+
+// SerializeResponseFormat converts a ResponseFormat into the OpenAI
+// chat-completions "response_format" request field.
+func SerializeResponseFormat(rf *protocoltypes.ResponseFormat) map[string]any {
+	if rf.Type == protocoltypes.ResponseFormatJSONSchema && len(rf.Schema) > 0 {
+		jsonSchema := map[string]any{
+			"name":   rf.SchemaName(),
+			"schema": rf.Schema,
+		}
+		if rf.Strict {
+			jsonSchema["strict"] = true
+		}
+		return map[string]any{
+			"type":        protocoltypes.ResponseFormatJSONSchema,
+			"json_schema": jsonSchema,
+		}
+	}
+	return map[string]any{"type": protocoltypes.ResponseFormatJSONObject}
+}
+
 // --- Response parsing ---
 
 // ParseResponse parses a JSON chat completion response body into an LLMResponse.
@@ -159,7 +180,7 @@ func ParseResponse(body io.Reader) (*LLMResponse, error) {
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
-		Usage *UsageInfo `json:"usage"`
+		Usage *openAIUsage `json:"usage"`
 	}
 
 	if err := json.NewDecoder(body).Decode(&apiResponse); err != nil {
@@ -215,10 +236,45 @@ func ParseResponse(body io.Reader) (*LLMResponse, error) {
 		ReasoningDetails: choice.Message.ReasoningDetails,
 		ToolCalls:        toolCalls,
 		FinishReason:     choice.FinishReason,
-		Usage:            apiResponse.Usage,
+		Usage:            apiResponse.Usage.toUsageInfo(),
 	}, nil
 }
 
+// openAIUsage is the wire form of the OpenAI-compatible "usage" object,
+// including the vendor-specific prompt-cache fields.
+type openAIUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	// DeepSeek reports cache hits and misses as top-level fields.
+	PromptCacheHitTokens  int `json:"prompt_cache_hit_tokens"`
+	PromptCacheMissTokens int `json:"prompt_cache_miss_tokens"`
+}
+
+func (u *openAIUsage) toUsageInfo() *UsageInfo {
+	if u == nil {
+		return nil
+	}
+	usage := &UsageInfo{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	switch {
+	case u.PromptTokensDetails != nil && u.PromptTokensDetails.CachedTokens > 0:
+		usage.CacheReadTokens = u.PromptTokensDetails.CachedTokens
+	case u.PromptCacheHitTokens > 0:
+		usage.CacheReadTokens = u.PromptCacheHitTokens
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = u.PromptCacheHitTokens + u.PromptCacheMissTokens
+	}
+	return usage
+}
+
 // DecodeToolCallArguments decodes a tool call's arguments from raw JSON.
 func DecodeToolCallArguments(raw json.RawMessage, name string) map[string]any {
 	arguments := make(map[string]any)
@@ -154,6 +154,38 @@ func TestParseResponse_EmptyChoices(t *testing.T) {
 	}
 }
 
+func TestParseResponse_OpenAICachedTokens(t *testing.T) {
+	body := `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}],` +
+		`"usage":{"prompt_tokens":2000,"completion_tokens":10,"total_tokens":2010,` +
+		`"prompt_tokens_details":{"cached_tokens":1536}}}`
+	out, err := ParseResponse(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("ParseResponse() error = %v", err)
+	}
+	if out.Usage == nil {
+		t.Fatal("Usage is nil")
+	}
+	if out.Usage.PromptTokens != 2000 || out.Usage.CacheReadTokens != 1536 {
+		t.Errorf("Usage = %+v, want prompt=2000 cache_read=1536", *out.Usage)
+	}
+}
+
+func TestParseResponse_DeepSeekCacheHitTokens(t *testing.T) {
+	body := `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}],` +
+		`"usage":{"prompt_tokens":100,"completion_tokens":5,"total_tokens":105,` +
+		`"prompt_cache_hit_tokens":64,"prompt_cache_miss_tokens":36}}`
+	out, err := ParseResponse(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("ParseResponse() error = %v", err)
+	}
+	if out.Usage == nil || out.Usage.CacheReadTokens != 64 {
+		t.Fatalf("Usage = %+v, want cache_read=64", out.Usage)
+	}
+	if got := out.Usage.CacheHitRate(); got != 0.64 {
+		t.Errorf("CacheHitRate() = %v, want 0.64", got)
+	}
+}
+
 func TestParseResponse_WithToolCalls(t *testing.T) {
 	body := `{"choices":[{"message":{"content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"SF\"}"}}]},"finish_reason":"tool_calls"}]}`
 	out, err := ParseResponse(strings.NewReader(body))
@@ -1,9 +1,17 @@
 package providers
 
 import (
+	"encoding/json"
+	"errors"
 	"math"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 const (
@@ -11,21 +19,38 @@ const (
 )
 
 // CooldownTracker manages per-provider cooldown state for the fallback chain.
-// Thread-safe via sync.RWMutex. In-memory only (resets on restart).
+// Thread-safe via sync.RWMutex. In-memory unless created with
+// NewPersistentCooldownTracker, which saves state to disk on every change.
 type CooldownTracker struct {
 	mu            sync.RWMutex
 	entries       map[string]*cooldownEntry
 	failureWindow time.Duration
 	nowFunc       func() time.Time // for testing
+	statePath     string           // empty = in-memory only
 }
 
 type cooldownEntry struct {
-	ErrorCount     int
-	FailureCounts  map[FailoverReason]int
-	CooldownEnd    time.Time      // standard cooldown expiry
-	DisabledUntil  time.Time      // billing-specific disable expiry
-	DisabledReason FailoverReason // reason for disable (billing)
-	LastFailure    time.Time
+	ErrorCount     int                    `json:"error_count"`
+	FailureCounts  map[FailoverReason]int `json:"failure_counts,omitempty"`
+	CooldownEnd    time.Time              `json:"cooldown_end"`              // standard cooldown expiry
+	DisabledUntil  time.Time              `json:"disabled_until"`            // billing-specific disable expiry
+	DisabledReason FailoverReason         `json:"disabled_reason,omitempty"` // reason for disable (billing)
+	LastFailure    time.Time              `json:"last_failure"`
+}
+
+// CooldownStatus describes one provider's cooldown state for display.
+type CooldownStatus struct {
+	Provider      string
+	ErrorCount    int
+	FailureCounts map[FailoverReason]int
+	Until         time.Time      // when the provider becomes available; zero if available
+	Reason        FailoverReason // billing when disabled, otherwise the most frequent reason
+	LastFailure   time.Time
+}
+
+// CooldownStatePath returns where cooldown state is persisted for a workspace.
+func CooldownStatePath(workspace string) string {
+	return filepath.Join(workspace, "state", "cooldowns.json")
 }
 
 // NewCooldownTracker creates a tracker with default 24h failure window.
@@ -37,6 +62,22 @@ func NewCooldownTracker() *CooldownTracker {
 	}
 }
 
+// NewPersistentCooldownTracker creates a tracker that restores its state
+// from path and writes it back after every change, so cooldowns (notably
+// the long billing disables) survive restarts. A missing or unreadable file
+// starts empty.
+func NewPersistentCooldownTracker(path string) *CooldownTracker {
+	ct := NewCooldownTracker()
+	ct.statePath = path
+	if err := ct.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.WarnCF("provider", "Failed to restore cooldown state", map[string]any{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+	return ct
+}
+
 // MarkFailure records a failure for a provider and sets appropriate cooldown.
 // Resets error counts if last failure was more than failureWindow ago.
 func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
@@ -63,6 +104,8 @@ func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
 	} else {
 		entry.CooldownEnd = now.Add(calculateStandardCooldown(entry.ErrorCount))
 	}
+
+	ct.saveLocked()
 }
 
 // MarkSuccess resets all counters and cooldowns for a provider.
@@ -71,7 +114,7 @@ func (ct *CooldownTracker) MarkSuccess(provider string) {
 	defer ct.mu.Unlock()
 
 	entry := ct.entries[provider]
-	if entry == nil {
+	if entry == nil || entry.isClear() {
 		return
 	}
 
@@ -80,6 +123,124 @@ func (ct *CooldownTracker) MarkSuccess(provider string) {
 	entry.CooldownEnd = time.Time{}
 	entry.DisabledUntil = time.Time{}
 	entry.DisabledReason = ""
+
+	ct.saveLocked()
+}
+
+// Clear removes all cooldown state for a provider, or for every provider
+// when provider is empty. It returns the number of providers cleared.
+func (ct *CooldownTracker) Clear(provider string) int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	cleared := 0
+	for name, entry := range ct.entries {
+		if provider != "" && name != provider {
+			continue
+		}
+		if !entry.isClear() {
+			cleared++
+		}
+		delete(ct.entries, name)
+	}
+	if cleared > 0 {
+		ct.saveLocked()
+	}
+	return cleared
+}
+
+// Statuses returns the state of every provider with recorded failures,
+// sorted by provider name.
+func (ct *CooldownTracker) Statuses() []CooldownStatus {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+
+	now := ct.nowFunc()
+	out := make([]CooldownStatus, 0, len(ct.entries))
+	for name, entry := range ct.entries {
+		if entry.isClear() {
+			continue
+		}
+		st := CooldownStatus{
+			Provider:      name,
+			ErrorCount:    entry.ErrorCount,
+			FailureCounts: make(map[FailoverReason]int, len(entry.FailureCounts)),
+			LastFailure:   entry.LastFailure,
+		}
+		best := 0
+		for reason, n := range entry.FailureCounts {
+			st.FailureCounts[reason] = n
+			if n > best || (n == best && reason < st.Reason) {
+				best, st.Reason = n, reason
+			}
+		}
+		if now.Before(entry.CooldownEnd) {
+			st.Until = entry.CooldownEnd
+		}
+		if now.Before(entry.DisabledUntil) && entry.DisabledUntil.After(st.Until) {
+			st.Until = entry.DisabledUntil
+			st.Reason = entry.DisabledReason
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Provider < out[j].Provider })
+	return out
+}
+
+func (e *cooldownEntry) isClear() bool {
+	return e.ErrorCount == 0 && e.CooldownEnd.IsZero() && e.DisabledUntil.IsZero()
+}
+
+// load restores entries from statePath, dropping providers whose failures
+// have aged out of the failure window and that are no longer disabled.
+func (ct *CooldownTracker) load() error {
+	data, err := os.ReadFile(ct.statePath)
+	if err != nil {
+		return err
+	}
+	var entries map[string]*cooldownEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	now := ct.nowFunc()
+	for name, entry := range entries {
+		if entry == nil || entry.isClear() {
+			continue
+		}
+		if now.Sub(entry.LastFailure) > ct.failureWindow && !now.Before(entry.DisabledUntil) {
+			continue
+		}
+		if entry.FailureCounts == nil {
+			entry.FailureCounts = make(map[FailoverReason]int)
+		}
+		ct.entries[name] = entry
+	}
+	return nil
+}
+
+// saveLocked persists entries when a state path is configured.
+// Caller must hold ct.mu.
+func (ct *CooldownTracker) saveLocked() {
+	if ct.statePath == "" {
+		return
+	}
+	active := make(map[string]*cooldownEntry, len(ct.entries))
+	for name, entry := range ct.entries {
+		if !entry.isClear() {
+			active[name] = entry
+		}
+	}
+	data, err := json.MarshalIndent(active, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(ct.statePath, data, 0o600)
+	}
+	if err != nil {
+		logger.WarnCF("provider", "Failed to save cooldown state", map[string]any{
+			"path":  ct.statePath,
+			"error": err.Error(),
+		})
+	}
 }
 
 // IsAvailable returns true if the provider is not in cooldown or disabled.
@@ -1,6 +1,8 @@
 package providers
 
 import (
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -267,3 +269,93 @@ func TestCooldown_MultipleProviders(t *testing.T) {
 		t.Error("groq should be available")
 	}
 }
+
+func TestCooldown_PersistAndRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "cooldowns.json")
+
+	ct := NewPersistentCooldownTracker(path)
+	ct.MarkFailure("anthropic", FailoverBilling)
+	ct.MarkFailure("openai", FailoverRateLimit)
+	ct.MarkFailure("openai", FailoverRateLimit)
+
+	restored := NewPersistentCooldownTracker(path)
+	if restored.IsAvailable("anthropic") {
+		t.Error("billing disable should survive restart")
+	}
+	if restored.IsAvailable("openai") {
+		t.Error("standard cooldown should survive restart")
+	}
+	if got := restored.FailureCount("openai", FailoverRateLimit); got != 2 {
+		t.Errorf("openai rate_limit count = %d, want 2", got)
+	}
+	if remaining := restored.CooldownRemaining("anthropic"); remaining < 4*time.Hour {
+		t.Errorf("anthropic remaining = %v, want ~5h", remaining)
+	}
+
+	// Success is persisted too.
+	restored.MarkSuccess("openai")
+	again := NewPersistentCooldownTracker(path)
+	if !again.IsAvailable("openai") || again.ErrorCount("openai") != 0 {
+		t.Error("success should clear persisted openai state")
+	}
+}
+
+func TestCooldown_RestoreDropsStaleEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cooldowns.json")
+	old := time.Now().Add(-48 * time.Hour)
+
+	ct := NewPersistentCooldownTracker(path)
+	ct.nowFunc = func() time.Time { return old }
+	ct.MarkFailure("openai", FailoverRateLimit)
+
+	restored := NewPersistentCooldownTracker(path)
+	if restored.ErrorCount("openai") != 0 {
+		t.Error("failures outside the failure window should not be restored")
+	}
+}
+
+func TestCooldown_CorruptStateStartsEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cooldowns.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ct := NewPersistentCooldownTracker(path)
+	if !ct.IsAvailable("openai") {
+		t.Error("corrupt state should be ignored")
+	}
+}
+
+func TestCooldown_ClearAndStatuses(t *testing.T) {
+	now := time.Now()
+	ct, _ := newTestTracker(now)
+	ct.MarkFailure("openai", FailoverRateLimit)
+	ct.MarkFailure("anthropic", FailoverBilling)
+	ct.MarkFailure("gemini", FailoverTimeout)
+
+	statuses := ct.Statuses()
+	if len(statuses) != 3 {
+		t.Fatalf("statuses = %d, want 3", len(statuses))
+	}
+	if statuses[0].Provider != "anthropic" || statuses[0].Reason != FailoverBilling {
+		t.Errorf("statuses[0] = %+v, want anthropic/billing", statuses[0])
+	}
+	if !statuses[0].Until.Equal(now.Add(5 * time.Hour)) {
+		t.Errorf("anthropic until = %v, want now+5h", statuses[0].Until)
+	}
+
+	if n := ct.Clear("openai"); n != 1 {
+		t.Errorf("Clear(openai) = %d, want 1", n)
+	}
+	if n := ct.Clear("openai"); n != 0 {
+		t.Errorf("second Clear(openai) = %d, want 0", n)
+	}
+	if !ct.IsAvailable("openai") {
+		t.Error("openai should be available after Clear")
+	}
+	if n := ct.Clear(""); n != 2 {
+		t.Errorf("Clear(all) = %d, want 2", n)
+	}
+	if len(ct.Statuses()) != 0 {
+		t.Error("no statuses expected after clearing all")
+	}
+}
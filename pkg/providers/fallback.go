@@ -10,6 +10,8 @@ import (
 // FallbackChain orchestrates model fallback across multiple candidates.
 type FallbackChain struct {
 	cooldown *CooldownTracker
+	strategy string
+	health   *HealthStats
 }
 
 // FallbackCandidate represents one model/provider to try.
@@ -38,7 +40,36 @@ type FallbackAttempt struct {
 
 // NewFallbackChain creates a new fallback chain with the given cooldown tracker.
 func NewFallbackChain(cooldown *CooldownTracker) *FallbackChain {
-	return &FallbackChain{cooldown: cooldown}
+	return &FallbackChain{cooldown: cooldown, strategy: SelectionOrdered}
+}
+
+// SetSelection configures how Execute orders candidates. With
+// SelectionLatency and non-nil stats, candidates are ranked by probed
+// latency and success rate; any other value keeps configured order.
+func (fc *FallbackChain) SetSelection(strategy string, stats *HealthStats) {
+	fc.strategy = strategy
+	fc.health = stats
+}
+
+// HealthSnapshot returns the probe results for all candidates, annotated
+// with their current cooldown state. Returns nil without health stats.
+func (fc *FallbackChain) HealthSnapshot() []ModelHealth {
+	if fc.health == nil {
+		return nil
+	}
+	snap := fc.health.Snapshot()
+	for i := range snap {
+		snap[i].InCooldown = !fc.cooldown.IsAvailable(snap[i].Provider)
+	}
+	return snap
+}
+
+// orderCandidates applies the selection strategy to the configured order.
+func (fc *FallbackChain) orderCandidates(candidates []FallbackCandidate) []FallbackCandidate {
+	if fc.strategy != SelectionLatency || fc.health == nil || len(candidates) < 2 {
+		return candidates
+	}
+	return rankByLatency(candidates, fc.health, fc.cooldown)
 }
 
 // ResolveCandidates parses model config into a deduplicated candidate list.
@@ -90,6 +121,7 @@ func ResolveCandidatesWithLookup(
 
 // Execute runs the fallback chain for text/chat requests.
 // It tries each candidate in order, respecting cooldowns and error classification.
+// With the latency strategy the order is first re-ranked by probe results.
 //
 // Behavior:
 //   - Candidates in cooldown are skipped (logged as skipped attempt).
@@ -107,6 +139,8 @@ func (fc *FallbackChain) Execute(
 		return nil, fmt.Errorf("fallback: no candidates configured")
 	}
 
+	candidates = fc.orderCandidates(candidates)
+
 	result := &FallbackResult{
 		Attempts: make([]FallbackAttempt, 0, len(candidates)),
 	}
@@ -0,0 +1,364 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Candidate selection strategies understood by FallbackChain.
+const (
+	SelectionOrdered = "ordered"
+	SelectionLatency = "latency"
+)
+
+// healthWindowSize is the number of recent probe samples kept per candidate.
+const healthWindowSize = 20
+
+// ModelHealth summarizes recent probe results for one candidate.
+type ModelHealth struct {
+	Provider    string    `json:"provider"`
+	Model       string    `json:"model"`
+	Samples     int       `json:"samples"`
+	SuccessRate float64   `json:"success_rate"`
+	P50Ms       int64     `json:"p50_ms"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastChecked time.Time `json:"last_checked"`
+	InCooldown  bool      `json:"in_cooldown,omitempty"`
+}
+
+type healthSample struct {
+	latency time.Duration
+	ok      bool
+}
+
+type healthEntry struct {
+	provider    string
+	model       string
+	samples     []healthSample
+	lastError   string
+	lastChecked time.Time
+}
+
+// HealthStats keeps a rolling window of probe samples per candidate.
+// Thread-safe. Only probe results are recorded: real requests vary too much
+// in size for their latency to be comparable across models.
+type HealthStats struct {
+	mu      sync.RWMutex
+	entries map[string]*healthEntry
+	nowFunc func() time.Time // for testing
+}
+
+// NewHealthStats creates an empty stats store.
+func NewHealthStats() *HealthStats {
+	return &HealthStats{
+		entries: make(map[string]*healthEntry),
+		nowFunc: time.Now,
+	}
+}
+
+// Record adds one probe sample. A nil err counts as success.
+func (hs *HealthStats) Record(provider, model string, latency time.Duration, err error) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	key := ModelKey(provider, model)
+	entry := hs.entries[key]
+	if entry == nil {
+		entry = &healthEntry{provider: provider, model: model}
+		hs.entries[key] = entry
+	}
+
+	entry.samples = append(entry.samples, healthSample{latency: latency, ok: err == nil})
+	if len(entry.samples) > healthWindowSize {
+		entry.samples = entry.samples[len(entry.samples)-healthWindowSize:]
+	}
+	entry.lastChecked = hs.nowFunc()
+	if err != nil {
+		entry.lastError = err.Error()
+	} else {
+		entry.lastError = ""
+	}
+}
+
+// Get returns the summary for one candidate, or false if it was never probed.
+func (hs *HealthStats) Get(provider, model string) (ModelHealth, bool) {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+
+	entry := hs.entries[ModelKey(provider, model)]
+	if entry == nil || len(entry.samples) == 0 {
+		return ModelHealth{}, false
+	}
+	return entry.summary(), true
+}
+
+// Snapshot returns summaries for all probed candidates, sorted by provider/model.
+func (hs *HealthStats) Snapshot() []ModelHealth {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+
+	out := make([]ModelHealth, 0, len(hs.entries))
+	for _, entry := range hs.entries {
+		if len(entry.samples) > 0 {
+			out = append(out, entry.summary())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return ModelKey(out[i].Provider, out[i].Model) < ModelKey(out[j].Provider, out[j].Model)
+	})
+	return out
+}
+
+func (e *healthEntry) summary() ModelHealth {
+	var latencies []time.Duration
+	for _, s := range e.samples {
+		if s.ok {
+			latencies = append(latencies, s.latency)
+		}
+	}
+
+	h := ModelHealth{
+		Provider:    e.provider,
+		Model:       e.model,
+		Samples:     len(e.samples),
+		SuccessRate: float64(len(latencies)) / float64(len(e.samples)),
+		LastError:   e.lastError,
+		LastChecked: e.lastChecked,
+	}
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		h.P50Ms = latencies[len(latencies)/2].Milliseconds()
+	}
+	return h
+}
+
+// rankByLatency reorders candidates for the latency strategy. Candidates in
+// cooldown always go last. Among the rest, probed candidates with at least
+// one success come first, ordered by p50 latency divided by success rate;
+// unprobed candidates follow, then candidates whose probes all failed.
+// Ties keep configured order.
+func rankByLatency(
+	candidates []FallbackCandidate,
+	stats *HealthStats,
+	cooldown *CooldownTracker,
+) []FallbackCandidate {
+	type ranked struct {
+		candidate FallbackCandidate
+		tier      int
+		score     float64
+	}
+
+	items := make([]ranked, len(candidates))
+	for i, c := range candidates {
+		item := ranked{candidate: c}
+		h, known := stats.Get(c.Provider, c.Model)
+		switch {
+		case cooldown != nil && !cooldown.IsAvailable(c.Provider):
+			item.tier = 3
+		case !known:
+			item.tier = 1
+		case h.SuccessRate == 0:
+			item.tier = 2
+		default:
+			item.score = float64(h.P50Ms) / h.SuccessRate
+		}
+		items[i] = item
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].tier != items[j].tier {
+			return items[i].tier < items[j].tier
+		}
+		return items[i].score < items[j].score
+	})
+
+	out := make([]FallbackCandidate, len(items))
+	for i, item := range items {
+		out[i] = item.candidate
+	}
+	return out
+}
+
+// HealthSnapshot is the on-disk form of the latest probe results, read by
+// `picoclaw status` while the gateway is running.
+type HealthSnapshot struct {
+	UpdatedAt time.Time     `json:"updated_at"`
+	Models    []ModelHealth `json:"models"`
+}
+
+// SaveHealthSnapshot writes probe results atomically to path.
+func SaveHealthSnapshot(path string, snap HealthSnapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(path, data, 0o600)
+}
+
+// LoadHealthSnapshot reads probe results written by SaveHealthSnapshot.
+func LoadHealthSnapshot(path string) (*HealthSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap HealthSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("parse health snapshot: %w", err)
+	}
+	return &snap, nil
+}
+
+// ProbeFunc sends a minimal request to one candidate.
+type ProbeFunc func(ctx context.Context, provider, model string) error
+
+// HealthProber periodically probes every candidate and records the results
+// in a HealthStats. Probes run sequentially so a round never bursts requests
+// at a shared provider.
+type HealthProber struct {
+	stats     *HealthStats
+	probe     ProbeFunc
+	interval  time.Duration
+	timeout   time.Duration
+	statePath string
+
+	mu         sync.Mutex
+	candidates []FallbackCandidate
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// NewHealthProber creates a prober. Call SetCandidates and Start to run it.
+func NewHealthProber(stats *HealthStats, probe ProbeFunc, interval, timeout time.Duration) *HealthProber {
+	return &HealthProber{
+		stats:    stats,
+		probe:    probe,
+		interval: interval,
+		timeout:  timeout,
+	}
+}
+
+// SetStatePath makes the prober persist a HealthSnapshot after each round.
+func (p *HealthProber) SetStatePath(path string) {
+	p.mu.Lock()
+	p.statePath = path
+	p.mu.Unlock()
+}
+
+// SetCandidates replaces the probed candidates, dropping duplicates.
+func (p *HealthProber) SetCandidates(candidates []FallbackCandidate) {
+	seen := make(map[string]bool, len(candidates))
+	deduped := make([]FallbackCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		key := ModelKey(c.Provider, c.Model)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, c)
+	}
+
+	p.mu.Lock()
+	p.candidates = deduped
+	p.mu.Unlock()
+}
+
+// Start launches the probe loop. The first round runs immediately.
+func (p *HealthProber) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			p.ProbeOnce(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}(p.done)
+}
+
+// Stop ends the probe loop and waits for an in-flight round to finish.
+func (p *HealthProber) Stop() {
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// ProbeOnce probes every candidate once and records the results.
+func (p *HealthProber) ProbeOnce(ctx context.Context) {
+	p.mu.Lock()
+	candidates := append([]FallbackCandidate(nil), p.candidates...)
+	statePath := p.statePath
+	p.mu.Unlock()
+
+	for _, c := range candidates {
+		if ctx.Err() != nil {
+			return
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, p.timeout)
+		start := time.Now()
+		err := p.probe(probeCtx, c.Provider, c.Model)
+		elapsed := time.Since(start)
+		cancel()
+
+		// Shutdown mid-probe says nothing about the candidate.
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.stats.Record(c.Provider, c.Model, elapsed, err)
+		if err != nil {
+			logger.DebugCF("provider", "Health probe failed", map[string]any{
+				"provider": c.Provider,
+				"model":    c.Model,
+				"error":    err.Error(),
+			})
+		}
+	}
+
+	if statePath == "" {
+		return
+	}
+	snap := HealthSnapshot{UpdatedAt: time.Now(), Models: p.stats.Snapshot()}
+	if err := SaveHealthSnapshot(statePath, snap); err != nil {
+		logger.WarnCF("provider", "Failed to save health snapshot", map[string]any{
+			"path":  statePath,
+			"error": err.Error(),
+		})
+	}
+}
+
+// HealthSnapshotPath returns where the prober stores its snapshot for a workspace.
+func HealthSnapshotPath(workspace string) string {
+	return filepath.Join(workspace, "state", "model_health.json")
+}
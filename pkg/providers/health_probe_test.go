@@ -0,0 +1,237 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthStats_Summary(t *testing.T) {
+	hs := NewHealthStats()
+	hs.Record("openai", "gpt-4", 300*time.Millisecond, nil)
+	hs.Record("openai", "gpt-4", 100*time.Millisecond, nil)
+	hs.Record("openai", "gpt-4", 200*time.Millisecond, nil)
+	hs.Record("openai", "gpt-4", 5*time.Second, errors.New("timeout"))
+
+	h, ok := hs.Get("openai", "gpt-4")
+	if !ok {
+		t.Fatal("expected stats for openai/gpt-4")
+	}
+	if h.Samples != 4 {
+		t.Errorf("Samples = %d, want 4", h.Samples)
+	}
+	if h.SuccessRate != 0.75 {
+		t.Errorf("SuccessRate = %v, want 0.75", h.SuccessRate)
+	}
+	// Failed probes do not count towards latency.
+	if h.P50Ms != 200 {
+		t.Errorf("P50Ms = %d, want 200", h.P50Ms)
+	}
+	if h.LastError != "timeout" {
+		t.Errorf("LastError = %q, want timeout", h.LastError)
+	}
+
+	if _, ok := hs.Get("anthropic", "claude"); ok {
+		t.Error("unprobed candidate should not have stats")
+	}
+}
+
+func TestHealthStats_WindowBounded(t *testing.T) {
+	hs := NewHealthStats()
+	for range healthWindowSize {
+		hs.Record("openai", "gpt-4", time.Second, errors.New("down"))
+	}
+	for range healthWindowSize {
+		hs.Record("openai", "gpt-4", 10*time.Millisecond, nil)
+	}
+
+	h, _ := hs.Get("openai", "gpt-4")
+	if h.Samples != healthWindowSize {
+		t.Errorf("Samples = %d, want %d", h.Samples, healthWindowSize)
+	}
+	if h.SuccessRate != 1 {
+		t.Errorf("old failures should have aged out, SuccessRate = %v", h.SuccessRate)
+	}
+}
+
+func TestRankByLatency(t *testing.T) {
+	hs := NewHealthStats()
+	hs.Record("slow", "m", 900*time.Millisecond, nil)
+	hs.Record("fast", "m", 100*time.Millisecond, nil)
+	hs.Record("dead", "m", time.Second, errors.New("down"))
+	hs.Record("cool", "m", 10*time.Millisecond, nil)
+
+	ct := NewCooldownTracker()
+	ct.MarkFailure("cool", FailoverRateLimit)
+
+	candidates := []FallbackCandidate{
+		makeCandidate("cool", "m"),
+		makeCandidate("dead", "m"),
+		makeCandidate("slow", "m"),
+		makeCandidate("unknown", "m"),
+		makeCandidate("fast", "m"),
+	}
+
+	got := rankByLatency(candidates, hs, ct)
+	want := []string{"fast", "slow", "unknown", "dead", "cool"}
+	for i, c := range got {
+		if c.Provider != want[i] {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRankByLatency_PenalizesFailures(t *testing.T) {
+	hs := NewHealthStats()
+	// 100ms but only 1 in 4 succeed → score 400; 250ms always ok → score 250.
+	hs.Record("flaky", "m", 100*time.Millisecond, nil)
+	for range 3 {
+		hs.Record("flaky", "m", time.Second, errors.New("503"))
+	}
+	hs.Record("steady", "m", 250*time.Millisecond, nil)
+
+	got := rankByLatency(
+		[]FallbackCandidate{makeCandidate("flaky", "m"), makeCandidate("steady", "m")},
+		hs, NewCooldownTracker(),
+	)
+	if got[0].Provider != "steady" {
+		t.Errorf("first = %s, want steady", got[0].Provider)
+	}
+}
+
+func TestFallback_LatencyStrategy(t *testing.T) {
+	hs := NewHealthStats()
+	hs.Record("openai", "gpt-4", 800*time.Millisecond, nil)
+	hs.Record("anthropic", "claude", 200*time.Millisecond, nil)
+
+	fc := NewFallbackChain(NewCooldownTracker())
+	fc.SetSelection(SelectionLatency, hs)
+
+	candidates := []FallbackCandidate{
+		makeCandidate("openai", "gpt-4"),
+		makeCandidate("anthropic", "claude"),
+	}
+	result, err := fc.Execute(context.Background(), candidates, successRun("ok"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Provider != "anthropic" {
+		t.Errorf("provider = %q, want anthropic (lower p50)", result.Provider)
+	}
+
+	// The ordered strategy ignores probe results.
+	fc.SetSelection(SelectionOrdered, hs)
+	result, err = fc.Execute(context.Background(), candidates, successRun("ok"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Provider != "openai" {
+		t.Errorf("provider = %q, want openai (configured order)", result.Provider)
+	}
+}
+
+func TestFallback_LatencyStrategyRespectsCooldown(t *testing.T) {
+	hs := NewHealthStats()
+	hs.Record("openai", "gpt-4", 50*time.Millisecond, nil)
+	hs.Record("anthropic", "claude", 500*time.Millisecond, nil)
+
+	ct := NewCooldownTracker()
+	ct.MarkFailure("openai", FailoverRateLimit)
+	fc := NewFallbackChain(ct)
+	fc.SetSelection(SelectionLatency, hs)
+
+	var called []string
+	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+		called = append(called, provider)
+		return &LLMResponse{Content: "ok"}, nil
+	}
+	result, err := fc.Execute(context.Background(), []FallbackCandidate{
+		makeCandidate("openai", "gpt-4"),
+		makeCandidate("anthropic", "claude"),
+	}, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Provider != "anthropic" || len(called) != 1 {
+		t.Errorf("provider = %q, calls = %v; fastest candidate is in cooldown", result.Provider, called)
+	}
+
+	snap := fc.HealthSnapshot()
+	if len(snap) != 2 {
+		t.Fatalf("snapshot len = %d, want 2", len(snap))
+	}
+	for _, h := range snap {
+		if want := h.Provider == "openai"; h.InCooldown != want {
+			t.Errorf("%s InCooldown = %v, want %v", h.Provider, h.InCooldown, want)
+		}
+	}
+}
+
+func TestHealthProber_ProbeOnce(t *testing.T) {
+	hs := NewHealthStats()
+	probe := func(ctx context.Context, provider, model string) error {
+		if provider == "down" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	path := filepath.Join(t.TempDir(), "state", "model_health.json")
+	p := NewHealthProber(hs, probe, time.Hour, time.Second)
+	p.SetStatePath(path)
+	p.SetCandidates([]FallbackCandidate{
+		makeCandidate("up", "m"),
+		makeCandidate("down", "m"),
+		makeCandidate("up", "m"),
+	})
+	p.ProbeOnce(context.Background())
+
+	up, _ := hs.Get("up", "m")
+	if up.Samples != 1 || up.SuccessRate != 1 {
+		t.Errorf("up = %+v, want one successful sample (duplicates dropped)", up)
+	}
+	down, _ := hs.Get("down", "m")
+	if down.SuccessRate != 0 || down.LastError != "connection refused" {
+		t.Errorf("down = %+v, want failure recorded", down)
+	}
+
+	snap, err := LoadHealthSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadHealthSnapshot: %v", err)
+	}
+	if len(snap.Models) != 2 || snap.UpdatedAt.IsZero() {
+		t.Errorf("snapshot = %+v, want 2 models with timestamp", snap)
+	}
+}
+
+func TestHealthProber_StartStop(t *testing.T) {
+	var probes atomic.Int32
+	probe := func(ctx context.Context, provider, model string) error {
+		probes.Add(1)
+		return nil
+	}
+
+	p := NewHealthProber(NewHealthStats(), probe, 10*time.Millisecond, time.Second)
+	p.SetCandidates([]FallbackCandidate{makeCandidate("openai", "gpt-4")})
+	p.Start()
+	p.Start() // second Start is a no-op
+
+	deadline := time.Now().Add(2 * time.Second)
+	for probes.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	p.Stop()
+	p.Stop() // idempotent
+
+	n := probes.Load()
+	if n < 2 {
+		t.Fatalf("probes = %d, want at least 2 rounds", n)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if probes.Load() != n {
+		t.Error("prober kept running after Stop")
+	}
+}
@@ -28,10 +28,11 @@ type (
 )
 
 type Provider struct {
-	apiKey         string
-	apiBase        string
-	maxTokensField string // Field name for max tokens (e.g., "max_completion_tokens" for o1/glm models)
-	httpClient     *http.Client
+	apiKey           string
+	apiBase          string
+	maxTokensField   string // Field name for max tokens (e.g., "max_completion_tokens" for o1/glm models)
+	structuredOutput *bool  // native response_format support; nil detects it from apiBase
+	httpClient       *http.Client
 }
 
 type Option func(*Provider)
@@ -44,6 +45,14 @@ func WithMaxTokensField(maxTokensField string) Option {
 	}
 }
 
+// WithStructuredOutput overrides whether response_format is sent natively.
+// nil keeps the detection by API base.
+func WithStructuredOutput(enabled *bool) Option {
+	return func(p *Provider) {
+		p.structuredOutput = enabled
+	}
+}
+
 func WithRequestTimeout(timeout time.Duration) Option {
 	return func(p *Provider) {
 		if timeout > 0 {
@@ -134,6 +143,10 @@ func (p *Provider) Chat(
 		}
 	}
 
+	if rf := protocoltypes.ResponseFormatFromOptions(options); rf != nil {
+		requestBody["response_format"] = common.SerializeResponseFormat(rf)
+	}
+
 	// Prompt caching: pass a stable cache key so OpenAI can bucket requests
 	// with the same key and reuse prefix KV cache across calls.
 	// The key is typically the agent ID — stable per agent, shared across requests.
@@ -175,6 +188,17 @@ func (p *Provider) Chat(
 	return common.ReadAndParseResponse(resp, p.apiBase)
 }
 
+// SupportsStructuredOutput reports whether response_format is sent natively:
+// as configured, otherwise only to OpenAI's own API. Many OpenAI-compatible
+// servers reject json_schema with a 400, so they get the schema in the
+// prompt and validation instead.
+func (p *Provider) SupportsStructuredOutput() bool {
+	if p.structuredOutput != nil {
+		return *p.structuredOutput
+	}
+	return isOpenAIAPI(p.apiBase)
+}
+
 func normalizeModel(model, apiBase string) string {
 	before, after, ok := strings.Cut(model, "/")
 	if !ok {
@@ -200,6 +224,11 @@ func normalizeModel(model, apiBase string) string {
 // API and Azure OpenAI support this. All other OpenAI-compatible providers
 // (Mistral, Gemini, DeepSeek, Groq, etc.) reject unknown fields with 422 errors.
 func supportsPromptCacheKey(apiBase string) bool {
+	return isOpenAIAPI(apiBase)
+}
+
+// isOpenAIAPI reports whether apiBase is OpenAI's API or Azure OpenAI.
+func isOpenAIAPI(apiBase string) bool {
 	u, err := url.Parse(apiBase)
 	if err != nil {
 		return false
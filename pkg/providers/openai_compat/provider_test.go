@@ -842,3 +842,73 @@ func TestSerializeMessages_StripsSystemParts(t *testing.T) {
 		t.Fatal("system_parts should not appear in serialized output")
 	}
 }
+
+func TestProviderChat_SendsResponseFormat(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]any{"content": `{"ok":true}`}, "finish_reason": "stop"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	_, err := p.Chat(
+		t.Context(),
+		[]Message{{Role: "user", Content: "hi"}},
+		nil,
+		"gpt-4o",
+		map[string]any{protocoltypes.ResponseFormatOptionKey: &protocoltypes.ResponseFormat{
+			Type:   protocoltypes.ResponseFormatJSONSchema,
+			Name:   "verdict",
+			Schema: map[string]any{"type": "object"},
+			Strict: true,
+		}},
+	)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	rf, ok := requestBody["response_format"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected response_format in request body, got %v", requestBody)
+	}
+	if rf["type"] != "json_schema" {
+		t.Errorf("type = %v, want json_schema", rf["type"])
+	}
+	js, _ := rf["json_schema"].(map[string]any)
+	if js["name"] != "verdict" || js["strict"] != true {
+		t.Errorf("json_schema = %v", js)
+	}
+}
+
+func TestProvider_SupportsStructuredOutput(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		apiBase string
+		opt     *bool
+		want    bool
+	}{
+		{"https://api.openai.com/v1", nil, true},
+		{"https://res.openai.azure.com/openai", nil, true},
+		{"http://localhost:11434/v1", nil, false},
+		{"https://api.deepseek.com/v1", nil, false},
+		{"https://openrouter.ai/api/v1", nil, false},
+		{"http://localhost:8000/v1", &on, true},
+		{"https://api.openai.com/v1", &off, false},
+	}
+	for _, tt := range tests {
+		p := NewProvider("key", tt.apiBase, "", WithStructuredOutput(tt.opt))
+		if got := p.SupportsStructuredOutput(); got != tt.want {
+			t.Errorf("SupportsStructuredOutput(%q, %v) = %v, want %v", tt.apiBase, tt.opt, got, tt.want)
+		}
+	}
+}
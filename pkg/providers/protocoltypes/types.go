@@ -1,5 +1,7 @@
 package protocoltypes
 
+import "encoding/json"
+
 type ToolCall struct {
 	ID               string         `json:"id"`
 	Type             string         `json:"type,omitempty"`
@@ -41,10 +43,23 @@ type ReasoningDetail struct {
 	Text   string `json:"text"`
 }
 
+// UsageInfo reports token usage for a single LLM call. PromptTokens always
+// counts the full prompt, including any tokens read from or written to the
+// provider's prompt cache.
 type UsageInfo struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`  // prompt tokens served from the cache
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"` // prompt tokens written to the cache
+}
+
+// CacheHitRate returns the fraction of prompt tokens served from the cache.
+func (u *UsageInfo) CacheHitRate() float64 {
+	if u == nil || u.PromptTokens <= 0 {
+		return 0
+	}
+	return float64(u.CacheReadTokens) / float64(u.PromptTokens)
 }
 
 // CacheControl marks a content block for LLM-side prefix caching.
@@ -70,6 +85,11 @@ type Message struct {
 	SystemParts      []ContentBlock `json:"system_parts,omitempty"` // structured system blocks for cache-aware adapters
 	ToolCalls        []ToolCall     `json:"tool_calls,omitempty"`
 	ToolCallID       string         `json:"tool_call_id,omitempty"`
+
+	// CacheControl marks the end of a stable prompt prefix. Cache-aware
+	// adapters place a cache breakpoint on this message's last content
+	// block. It is request-scoped and never persisted.
+	CacheControl *CacheControl `json:"-"`
 }
 
 type ToolDefinition struct {
@@ -82,3 +102,110 @@ type ToolFunctionDefinition struct {
 	Description string         `json:"description"`
 	Parameters  map[string]any `json:"parameters"`
 }
+
+// Response format types accepted by ResponseFormat.Type.
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// ResponseFormatOptionKey is the LLMProvider.Chat options key that carries a
+// *ResponseFormat. Adapters map it onto their native structured-output feature.
+const ResponseFormatOptionKey = "response_format"
+
+// ResponseFormat requests structured (JSON) output from the model.
+// For "json_schema" the Schema field holds a JSON Schema object that the
+// response must satisfy; "json_object" only requires a JSON object.
+type ResponseFormat struct {
+	Type   string         `json:"type"`
+	Name   string         `json:"name,omitempty"`
+	Schema map[string]any `json:"schema,omitempty"`
+	Strict bool           `json:"strict,omitempty"`
+}
+
+// IsJSON reports whether the format requests JSON output.
+func (rf *ResponseFormat) IsJSON() bool {
+	return rf != nil && (rf.Type == ResponseFormatJSONObject || rf.Type == ResponseFormatJSONSchema)
+}
+
+// SchemaName returns the configured name or a stable default.
+func (rf *ResponseFormat) SchemaName() string {
+	if rf == nil || rf.Name == "" {
+		return "structured_output"
+	}
+	return rf.Name
+}
+
+// ObjectSchema returns the JSON Schema describing the expected output.
+// "json_object" formats without a schema get a permissive object schema.
+func (rf *ResponseFormat) ObjectSchema() map[string]any {
+	if rf != nil && len(rf.Schema) > 0 {
+		return rf.Schema
+	}
+	return map[string]any{"type": "object"}
+}
+
+// ResponseFormatFromOptions extracts the response format from Chat options.
+// It returns nil when no JSON format was requested.
+func ResponseFormatFromOptions(options map[string]any) *ResponseFormat {
+	var rf *ResponseFormat
+	switch v := options[ResponseFormatOptionKey].(type) {
+	case *ResponseFormat:
+		rf = v
+	case ResponseFormat:
+		rf = &v
+	}
+	if !rf.IsJSON() {
+		return nil
+	}
+	return rf
+}
+
+// structuredOutputWrapKey is the property used to wrap non-object schemas
+// for backends that only accept object-shaped tool inputs.
+const structuredOutputWrapKey = "result"
+
+// ToolInputSchema returns the schema to use when the format is emulated by
+// forcing a tool call (Anthropic). Tool inputs must be objects, so any other
+// schema is wrapped in {"result": ...}; wrapped reports whether that happened.
+func (rf *ResponseFormat) ToolInputSchema() (schema map[string]any, wrapped bool) {
+	schema = rf.ObjectSchema()
+	if t, _ := schema["type"].(string); t == "object" {
+		return schema, false
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{structuredOutputWrapKey: schema},
+		"required":   []any{structuredOutputWrapKey},
+	}, true
+}
+
+// ExtractForcedToolOutput converts the synthetic structured-output tool call
+// produced by tool forcing back into JSON content. Other tool calls are left
+// in place so the caller's tool loop can continue; if the structured-output
+// tool was called it is treated as the final answer.
+func ExtractForcedToolOutput(resp *LLMResponse, rf *ResponseFormat) {
+	if resp == nil || rf == nil {
+		return
+	}
+	name := rf.SchemaName()
+	_, wrapped := rf.ToolInputSchema()
+	for _, tc := range resp.ToolCalls {
+		if tc.Name != name {
+			continue
+		}
+		var value any = tc.Arguments
+		if wrapped {
+			value = tc.Arguments[structuredOutputWrapKey]
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return
+		}
+		resp.Content = string(encoded)
+		resp.ToolCalls = nil
+		resp.FinishReason = "stop"
+		return
+	}
+}
@@ -0,0 +1,369 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+// maxStructuredOutputRetries bounds the validate-and-retry loop used when a
+// response does not satisfy the requested format.
+const maxStructuredOutputRetries = 2
+
+// ResponseFormatFromOptions extracts the requested JSON response format from
+// Chat options, or nil when plain text was requested.
+func ResponseFormatFromOptions(options map[string]any) *ResponseFormat {
+	return protocoltypes.ResponseFormatFromOptions(options)
+}
+
+// JSONSchemaFormat builds a json_schema ResponseFormat for the given schema.
+func JSONSchemaFormat(name string, schema map[string]any) *ResponseFormat {
+	return &ResponseFormat{
+		Type:   ResponseFormatJSONSchema,
+		Name:   name,
+		Schema: schema,
+	}
+}
+
+// ChatStructured calls provider.Chat and enforces the "response_format"
+// option. Providers implementing StructuredOutputCapable receive the option
+// unchanged; all others get the schema injected into the system prompt.
+// Final answers are validated against the schema and, when invalid, the model
+// is asked to correct itself up to maxStructuredOutputRetries times.
+//
+// Responses that contain tool calls are returned untouched so tool loops can
+// keep iterating before the final structured answer. The returned Usage
+// covers every attempt, retries included, so callers bill all of them.
+func ChatStructured(
+	ctx context.Context,
+	provider LLMProvider,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	rf := ResponseFormatFromOptions(options)
+	if rf == nil {
+		return provider.Chat(ctx, messages, tools, model, options)
+	}
+
+	native := false
+	if sc, ok := provider.(StructuredOutputCapable); ok && sc.SupportsStructuredOutput() {
+		native = true
+	}
+
+	callOpts := options
+	callMessages := messages
+	if !native {
+		callOpts = maps.Clone(options)
+		delete(callOpts, ResponseFormatOptionKey)
+		callMessages = injectFormatInstructions(messages, rf)
+	}
+
+	var spent *UsageInfo
+	for attempt := 0; ; attempt++ {
+		resp, err := provider.Chat(ctx, callMessages, tools, model, callOpts)
+		if resp != nil {
+			spent = addUsage(spent, resp.Usage)
+			resp.Usage = spent
+		}
+		if err != nil || resp == nil || len(resp.ToolCalls) > 0 {
+			return resp, err
+		}
+
+		normalized, verr := ValidateStructuredOutput(resp.Content, rf)
+		if verr == nil {
+			resp.Content = normalized
+			return resp, nil
+		}
+
+		if attempt >= maxStructuredOutputRetries {
+			return resp, fmt.Errorf("structured output invalid after %d attempts: %w", attempt+1, verr)
+		}
+
+		logger.WarnCF("provider", "Structured output failed validation, retrying", map[string]any{
+			"model":   model,
+			"attempt": attempt + 1,
+			"native":  native,
+			"error":   verr.Error(),
+		})
+
+		callMessages = append(append([]Message(nil), callMessages...),
+			Message{Role: "assistant", Content: resp.Content},
+			Message{Role: "user", Content: fmt.Sprintf(
+				"Your previous reply was not valid: %v. Reply again with only the corrected JSON, no prose or code fences.",
+				verr,
+			)},
+		)
+	}
+}
+
+// addUsage returns the sum of total and u; either may be nil.
+func addUsage(total, u *UsageInfo) *UsageInfo {
+	if u == nil {
+		return total
+	}
+	if total == nil {
+		sum := *u
+		return &sum
+	}
+	total.PromptTokens += u.PromptTokens
+	total.CompletionTokens += u.CompletionTokens
+	total.TotalTokens += u.TotalTokens
+	total.CacheReadTokens += u.CacheReadTokens
+	total.CacheWriteTokens += u.CacheWriteTokens
+	return total
+}
+
+// injectFormatInstructions appends output-format instructions to the system
+// prompt (or prepends a system message when there is none). SystemParts are
+// extended too so cache-aware adapters see the same instruction.
+func injectFormatInstructions(messages []Message, rf *ResponseFormat) []Message {
+	instruction := formatInstruction(rf)
+	out := make([]Message, len(messages))
+	copy(out, messages)
+
+	for i := range out {
+		if out[i].Role != "system" {
+			continue
+		}
+		out[i].Content = out[i].Content + "\n\n" + instruction
+		if len(out[i].SystemParts) > 0 {
+			parts := make([]ContentBlock, len(out[i].SystemParts), len(out[i].SystemParts)+1)
+			copy(parts, out[i].SystemParts)
+			out[i].SystemParts = append(parts, ContentBlock{Type: "text", Text: instruction})
+		}
+		return out
+	}
+
+	return append([]Message{{Role: "system", Content: instruction}}, out...)
+}
+
+func formatInstruction(rf *ResponseFormat) string {
+	var sb strings.Builder
+	sb.WriteString("## Output Format\n\n")
+	sb.WriteString("Your final answer MUST be a single JSON value and nothing else: ")
+	sb.WriteString("no prose, no explanations, no markdown code fences.")
+	if rf.Type == ResponseFormatJSONSchema && len(rf.Schema) > 0 {
+		schemaJSON, err := json.MarshalIndent(rf.Schema, "", "  ")
+		if err == nil {
+			sb.WriteString(" It must conform to this JSON Schema:\n\n")
+			sb.Write(schemaJSON)
+		}
+	} else {
+		sb.WriteString(" It must be a JSON object.")
+	}
+	return sb.String()
+}
+
+// ValidateStructuredOutput checks content against the response format and
+// returns the compact JSON encoding on success. Surrounding whitespace and
+// markdown code fences are tolerated because many models add them.
+func ValidateStructuredOutput(content string, rf *ResponseFormat) (string, error) {
+	raw := stripCodeFence(content)
+	if raw == "" {
+		return "", fmt.Errorf("empty response, expected JSON")
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return "", fmt.Errorf("response is not valid JSON: %w", err)
+	}
+
+	schema := rf.ObjectSchema()
+	if err := validateJSONSchema(value, schema, "$"); err != nil {
+		return "", err
+	}
+
+	compact, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("re-encoding JSON: %w", err)
+	}
+	return string(compact), nil
+}
+
+func stripCodeFence(content string) string {
+	s := strings.TrimSpace(content)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if nl := strings.IndexByte(s, '\n'); nl >= 0 {
+		s = s[nl+1:] // drop language tag such as ```json
+	}
+	s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	return strings.TrimSpace(s)
+}
+
+// validateJSONSchema validates value against the commonly used subset of
+// JSON Schema: type, properties, required, items, enum and
+// additionalProperties=false. Unknown keywords are ignored.
+func validateJSONSchema(value any, schema map[string]any, path string) error {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		found := false
+		for _, candidate := range enum {
+			if jsonEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value %v is not one of the allowed enum values", path, value)
+		}
+	}
+
+	if t, ok := schemaTypes(schema["type"]); ok {
+		matched := false
+		for _, typ := range t {
+			if jsonTypeMatches(value, typ) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(t, " or "), jsonTypeName(value))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for _, name := range schemaRequired(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub, ok := props[k].(map[string]any)
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("%s: unexpected property %q", path, k)
+				}
+				continue
+			}
+			if err := validateJSONSchema(v[k], sub, path+"."+k); err != nil {
+				return err
+			}
+		}
+	case []any:
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			return nil
+		}
+		for i, item := range v {
+			if err := validateJSONSchema(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func schemaTypes(raw any) ([]string, bool) {
+	switch t := raw.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out, len(out) > 0
+	case []string:
+		return t, len(t) > 0
+	default:
+		return nil, false
+	}
+}
+
+func schemaRequired(raw any) []string {
+	switch r := raw.(type) {
+	case []string:
+		return r
+	case []any:
+		out := make([]string, 0, len(r))
+		for _, item := range r {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func jsonTypeMatches(value any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return true
+	}
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func jsonEqual(a, b any) bool {
+	aj, errA := json.Marshal(a)
+	bj, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(aj) == string(bj)
+}
@@ -0,0 +1,214 @@
+package providers
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type scriptedProvider struct {
+	replies  []string
+	native   bool
+	calls    int
+	messages [][]Message
+	options  []map[string]any
+}
+
+func (p *scriptedProvider) Chat(
+	_ context.Context, messages []Message, _ []ToolDefinition, _ string, options map[string]any,
+) (*LLMResponse, error) {
+	p.messages = append(p.messages, messages)
+	p.options = append(p.options, options)
+	reply := p.replies[min(p.calls, len(p.replies)-1)]
+	p.calls++
+	return &LLMResponse{
+		Content:      reply,
+		FinishReason: "stop",
+		Usage:        &UsageInfo{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func (p *scriptedProvider) GetDefaultModel() string { return "scripted" }
+
+type nativeScriptedProvider struct{ scriptedProvider }
+
+func (p *nativeScriptedProvider) SupportsStructuredOutput() bool { return true }
+
+var personSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"name": map[string]any{"type": "string"},
+		"age":  map[string]any{"type": "integer"},
+	},
+	"required": []any{"name", "age"},
+}
+
+func TestValidateStructuredOutput(t *testing.T) {
+	rf := JSONSchemaFormat("person", personSchema)
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{name: "valid", content: `{"name":"Ada","age":36}`, want: `{"age":36,"name":"Ada"}`},
+		{name: "code fence", content: "```json\n{\"name\":\"Ada\",\"age\":36}\n```", want: `{"age":36,"name":"Ada"}`},
+		{name: "not json", content: "Ada is 36", wantErr: "not valid JSON"},
+		{name: "missing required", content: `{"name":"Ada"}`, wantErr: `missing required property "age"`},
+		{name: "wrong type", content: `{"name":"Ada","age":"old"}`, wantErr: "$.age: expected integer"},
+		{name: "non integer", content: `{"name":"Ada","age":36.5}`, wantErr: "expected integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateStructuredOutput(tt.content, rf)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStructuredOutput_JSONObjectRejectsArray(t *testing.T) {
+	rf := &ResponseFormat{Type: ResponseFormatJSONObject}
+	if _, err := ValidateStructuredOutput(`[1,2]`, rf); err == nil {
+		t.Fatal("expected array to be rejected for json_object")
+	}
+}
+
+func TestChatStructured_NoFormatPassesThrough(t *testing.T) {
+	p := &scriptedProvider{replies: []string{"plain text"}}
+	resp, err := ChatStructured(t.Context(), p, []Message{{Role: "user", Content: "hi"}}, nil, "m", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Content != "plain text" || p.calls != 1 {
+		t.Fatalf("got %q after %d calls", resp.Content, p.calls)
+	}
+}
+
+func TestChatStructured_FallbackInjectsSchemaAndRetries(t *testing.T) {
+	p := &scriptedProvider{replies: []string{"Sure! Ada is 36.", `{"name":"Ada","age":36}`}}
+	opts := map[string]any{
+		"max_tokens":            100,
+		ResponseFormatOptionKey: JSONSchemaFormat("person", personSchema),
+	}
+	messages := []Message{{Role: "system", Content: "base"}, {Role: "user", Content: "who?"}}
+
+	resp, err := ChatStructured(t.Context(), p, messages, nil, "m", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Content != `{"age":36,"name":"Ada"}` {
+		t.Fatalf("content = %q", resp.Content)
+	}
+	if p.calls != 2 {
+		t.Fatalf("calls = %d, want 2", p.calls)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 20 || resp.Usage.CompletionTokens != 10 {
+		t.Errorf("usage = %+v, want both attempts summed", resp.Usage)
+	}
+	if _, ok := p.options[0][ResponseFormatOptionKey]; ok {
+		t.Error("response_format should be stripped for non-native providers")
+	}
+	if _, ok := opts[ResponseFormatOptionKey]; !ok {
+		t.Error("caller options must not be mutated")
+	}
+	if !strings.Contains(p.messages[0][0].Content, `"required"`) {
+		t.Errorf("system prompt should contain schema, got %q", p.messages[0][0].Content)
+	}
+	if messages[0].Content != "base" {
+		t.Error("caller messages must not be mutated")
+	}
+	retry := p.messages[1]
+	if last := retry[len(retry)-1]; last.Role != "user" || !strings.Contains(last.Content, "not valid") {
+		t.Errorf("retry should ask for correction, got %+v", last)
+	}
+}
+
+func TestChatStructured_NativeKeepsOptionAndGivesUp(t *testing.T) {
+	p := &nativeScriptedProvider{scriptedProvider{replies: []string{"nope"}}}
+	opts := map[string]any{ResponseFormatOptionKey: &ResponseFormat{Type: ResponseFormatJSONObject}}
+
+	resp, err := ChatStructured(t.Context(), p, []Message{{Role: "user", Content: "x"}}, nil, "m", opts)
+	if err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	if resp == nil || resp.Content != "nope" {
+		t.Fatalf("last response should be returned, got %+v", resp)
+	}
+	if p.calls != maxStructuredOutputRetries+1 {
+		t.Errorf("calls = %d, want %d", p.calls, maxStructuredOutputRetries+1)
+	}
+	if _, ok := p.options[0][ResponseFormatOptionKey]; !ok {
+		t.Error("native providers should receive response_format")
+	}
+	if len(p.messages[0]) != 1 {
+		t.Error("native providers should not get prompt injection")
+	}
+}
+
+func TestAntigravityBuildRequest_ResponseSchema(t *testing.T) {
+	p := &AntigravityProvider{}
+	req := p.buildRequest(
+		[]Message{{Role: "user", Content: "hi"}},
+		nil,
+		"",
+		map[string]any{ResponseFormatOptionKey: JSONSchemaFormat("person", personSchema)},
+	)
+	if req.Config == nil {
+		t.Fatal("expected generation config")
+	}
+	if req.Config.ResponseMimeType != "application/json" {
+		t.Errorf("ResponseMimeType = %q", req.Config.ResponseMimeType)
+	}
+	if req.Config.ResponseSchema == nil {
+		t.Error("expected responseSchema to be set")
+	}
+}
+
+func TestAntigravityBuildRequest_ToolsUsePromptInsteadOfJSONMode(t *testing.T) {
+	p := &AntigravityProvider{}
+	tools := []ToolDefinition{{
+		Type:     "function",
+		Function: ToolFunctionDefinition{Name: "lookup", Parameters: map[string]any{"type": "object"}},
+	}}
+	req := p.buildRequest(
+		[]Message{{Role: "system", Content: "base"}, {Role: "user", Content: "hi"}},
+		tools,
+		"",
+		map[string]any{ResponseFormatOptionKey: JSONSchemaFormat("person", personSchema)},
+	)
+	if req.Config != nil && (req.Config.ResponseMimeType != "" || req.Config.ResponseSchema != nil) {
+		t.Errorf("JSON mode must not be combined with tools: %+v", req.Config)
+	}
+	if req.SystemPrompt == nil || !strings.Contains(req.SystemPrompt.Parts[0].Text, `"required"`) {
+		t.Error("the schema should be asked for in the system prompt")
+	}
+}
+
+func TestBuildCodexParams_ResponseFormat(t *testing.T) {
+	params := buildCodexParams(
+		[]Message{{Role: "user", Content: "hi"}},
+		nil,
+		"gpt-5.2",
+		map[string]any{ResponseFormatOptionKey: JSONSchemaFormat("person", personSchema)},
+		false,
+	)
+	if params.Text.Format.OfJSONSchema == nil {
+		t.Fatal("expected json_schema text format")
+	}
+	if params.Text.Format.OfJSONSchema.Name != "person" {
+		t.Errorf("Name = %q", params.Text.Format.OfJSONSchema.Name)
+	}
+}
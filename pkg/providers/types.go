@@ -19,6 +19,14 @@ type (
 	GoogleExtra            = protocoltypes.GoogleExtra
 	ContentBlock           = protocoltypes.ContentBlock
 	CacheControl           = protocoltypes.CacheControl
+	ResponseFormat         = protocoltypes.ResponseFormat
+)
+
+const (
+	ResponseFormatText       = protocoltypes.ResponseFormatText
+	ResponseFormatJSONObject = protocoltypes.ResponseFormatJSONObject
+	ResponseFormatJSONSchema = protocoltypes.ResponseFormatJSONSchema
+	ResponseFormatOptionKey  = protocoltypes.ResponseFormatOptionKey
 )
 
 type LLMProvider interface {
@@ -44,6 +52,15 @@ type ThinkingCapable interface {
 	SupportsThinking() bool
 }
 
+// StructuredOutputCapable is an optional interface for providers that map
+// the "response_format" option onto a native structured-output feature
+// (OpenAI response_format, Anthropic tool forcing, Gemini responseSchema).
+// Providers that do not implement it get prompt injection plus
+// validate-and-retry via ChatStructured.
+type StructuredOutputCapable interface {
+	SupportsStructuredOutput() bool
+}
+
 // FailoverReason classifies why an LLM request failed for fallback decisions.
 type FailoverReason string
 
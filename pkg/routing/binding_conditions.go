@@ -0,0 +1,250 @@
+package routing
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// conditionNames lists the conditions a binding sets, in MatchedBy order.
+// Content is excluded: content bindings have their own priority level.
+func conditionNames(m config.BindingMatch) []string {
+	var names []string
+	if len(m.Roles) > 0 {
+		names = append(names, "role")
+	}
+	if len(m.Media) > 0 {
+		names = append(names, "media")
+	}
+	if m.Schedule != nil {
+		names = append(names, "schedule")
+	}
+	return names
+}
+
+// conditionsMet reports whether the role, media and schedule conditions of
+// a binding hold. Unset conditions always hold.
+func (r *RouteResolver) conditionsMet(m config.BindingMatch, input RouteInput, roles []string) bool {
+	if len(m.Roles) > 0 && !slices.ContainsFunc(m.Roles, func(role string) bool {
+		return slices.Contains(roles, strings.ToLower(strings.TrimSpace(role)))
+	}) {
+		return false
+	}
+	if len(m.Media) > 0 {
+		media := input.MediaType
+		if media == "" {
+			media = MediaText
+		}
+		if !slices.ContainsFunc(m.Media, func(v string) bool {
+			return strings.EqualFold(strings.TrimSpace(v), media)
+		}) {
+			return false
+		}
+	}
+	if m.Schedule != nil && !r.matchers.inSchedule(m.Schedule, input.Time) {
+		return false
+	}
+	return true
+}
+
+// findContentMatch returns the first content binding whose scope, conditions
+// and content all match, plus the message with a matched command removed.
+func (r *RouteResolver) findContentMatch(
+	bindings []*config.AgentBinding,
+	input RouteInput,
+	roles []string,
+) (*config.AgentBinding, string) {
+	for _, b := range bindings {
+		if b.Match.Content == nil || !scopeMatches(b.Match, input) {
+			continue
+		}
+		if !r.conditionsMet(b.Match, input, roles) {
+			continue
+		}
+		if ok, content := r.matchers.matchContent(b.Match.Content, input.Content); ok {
+			return b, content
+		}
+	}
+	return nil, ""
+}
+
+// scopeMatches checks the peer, guild and team fields of a content binding.
+// Unlike the cascade, a content binding may combine them freely.
+func scopeMatches(m config.BindingMatch, input RouteInput) bool {
+	if m.Peer != nil {
+		matchPeer := func(p *RoutePeer) bool {
+			return p != nil && strings.EqualFold(strings.TrimSpace(m.Peer.Kind), p.Kind) &&
+				strings.TrimSpace(m.Peer.ID) == p.ID
+		}
+		if !matchPeer(input.Peer) && !matchPeer(input.ParentPeer) {
+			return false
+		}
+	}
+	if g := strings.TrimSpace(m.GuildID); g != "" && g != strings.TrimSpace(input.GuildID) {
+		return false
+	}
+	if t := strings.TrimSpace(m.TeamID); t != "" && t != strings.TrimSpace(input.TeamID) {
+		return false
+	}
+	return true
+}
+
+// senderRoles returns the lower-cased roles of the sender: those reported
+// by the channel plus those granted in the roles config.
+func (r *RouteResolver) senderRoles(input RouteInput) []string {
+	var roles []string
+	for _, role := range input.SenderRoles {
+		if role = strings.ToLower(strings.TrimSpace(role)); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	for role, members := range r.cfg.Roles {
+		for _, id := range input.SenderIDs {
+			if id != "" && slices.Contains(members, id) {
+				roles = append(roles, strings.ToLower(role))
+				break
+			}
+		}
+	}
+	return roles
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// matcherCache compiles regular expressions and loads time zones once per
+// resolver. Invalid values are logged once and never match.
+type matcherCache struct {
+	regexes   sync.Map // pattern -> *regexp.Regexp (nil when invalid)
+	locations sync.Map // name -> *time.Location (nil when invalid)
+}
+
+func (c *matcherCache) regex(pattern string) *regexp.Regexp {
+	if v, ok := c.regexes.Load(pattern); ok {
+		re, _ := v.(*regexp.Regexp)
+		return re
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		logger.WarnCF("routing", "Invalid binding content regex",
+			map[string]any{"regex": pattern, "error": err.Error()})
+		c.regexes.Store(pattern, (*regexp.Regexp)(nil))
+		return nil
+	}
+	c.regexes.Store(pattern, re)
+	return re
+}
+
+func (c *matcherCache) location(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	if v, ok := c.locations.Load(name); ok {
+		loc, _ := v.(*time.Location)
+		return loc
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.WarnCF("routing", "Invalid binding schedule timezone",
+			map[string]any{"timezone": name, "error": err.Error()})
+		c.locations.Store(name, (*time.Location)(nil))
+		return nil
+	}
+	c.locations.Store(name, loc)
+	return loc
+}
+
+// matchContent applies a ContentMatch. When Command matches, the returned
+// content is the message without the command token.
+func (c *matcherCache) matchContent(m *config.ContentMatch, msg string) (bool, string) {
+	text := strings.TrimSpace(msg)
+	var stripped string
+
+	if cmd := strings.TrimSpace(m.Command); cmd != "" {
+		head, tail := text, ""
+		if i := strings.IndexFunc(text, unicode.IsSpace); i >= 0 {
+			head, tail = text[:i], text[i:]
+		}
+		if !strings.EqualFold(head, cmd) {
+			return false, ""
+		}
+		stripped = strings.TrimSpace(tail)
+	}
+	if m.Prefix != "" && !strings.HasPrefix(strings.ToLower(text), strings.ToLower(m.Prefix)) {
+		return false, ""
+	}
+	if m.Regex != "" {
+		re := c.regex(m.Regex)
+		if re == nil || !re.MatchString(text) {
+			return false, ""
+		}
+	}
+	if m.Command == "" && m.Prefix == "" && m.Regex == "" {
+		return false, ""
+	}
+	return true, stripped
+}
+
+// inSchedule reports whether t falls on one of the schedule's days and
+// inside its From-To window in the schedule's time zone. A window that
+// wraps past midnight is checked against the day t falls on.
+func (c *matcherCache) inSchedule(s *config.ScheduleMatch, t time.Time) bool {
+	loc := c.location(s.Timezone)
+	if loc == nil {
+		return false
+	}
+	local := t.In(loc)
+
+	if len(s.Days) > 0 && !slices.ContainsFunc(s.Days, func(d string) bool {
+		wd, ok := parseWeekday(d)
+		return ok && wd == local.Weekday()
+	}) {
+		return false
+	}
+
+	if s.From == "" && s.To == "" {
+		return true
+	}
+	from, okFrom := parseClock(s.From, 0)
+	to, okTo := parseClock(s.To, 24*60)
+	if !okFrom || !okTo {
+		logger.WarnCF("routing", "Invalid binding schedule window",
+			map[string]any{"from": s.From, "to": s.To})
+		return false
+	}
+	now := local.Hour()*60 + local.Minute()
+	if from <= to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to
+}
+
+// parseWeekday accepts "mon", "Monday" and similar.
+func parseWeekday(d string) (time.Weekday, bool) {
+	d = strings.ToLower(strings.TrimSpace(d))
+	if len(d) > 3 {
+		d = d[:3]
+	}
+	wd, ok := weekdayNames[d]
+	return wd, ok
+}
+
+// parseClock converts "HH:MM" to minutes after midnight; empty yields def.
+func parseClock(v string, def int) (int, bool) {
+	if v = strings.TrimSpace(v); v == "" {
+		return def, true
+	}
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
@@ -0,0 +1,159 @@
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func conditionalConfig() *config.Config {
+	agents := []config.AgentConfig{
+		{ID: "main", Default: true},
+		{ID: "coder"},
+		{ID: "night"},
+		{ID: "voice"},
+		{ID: "admin"},
+		{ID: "support"},
+	}
+	bindings := []config.AgentBinding{
+		{AgentID: "support", Match: config.BindingMatch{
+			Channel: "telegram", AccountID: "*",
+			Peer: &config.PeerMatch{Kind: "direct", ID: "vip"},
+		}},
+		{AgentID: "main", Match: config.BindingMatch{Channel: "telegram", AccountID: "*"}},
+		{AgentID: "night", Match: config.BindingMatch{
+			Channel: "telegram", AccountID: "*",
+			Schedule: &config.ScheduleMatch{From: "22:00", To: "07:00", Timezone: "UTC"},
+		}},
+		{AgentID: "voice", Match: config.BindingMatch{
+			Channel: "telegram", AccountID: "*",
+			Media: []string{"voice"},
+		}},
+		{AgentID: "admin", Match: config.BindingMatch{
+			Channel: "telegram", AccountID: "*",
+			Roles: []string{"Ops"},
+		}},
+		{AgentID: "coder", Match: config.BindingMatch{
+			Channel: "telegram", AccountID: "*",
+			Content: &config.ContentMatch{Command: "@coder"},
+		}},
+		{AgentID: "coder", Match: config.BindingMatch{
+			Channel: "telegram", AccountID: "*",
+			Content: &config.ContentMatch{Regex: `(?i)\bstack trace\b`},
+		}},
+	}
+	cfg := testConfig(agents, bindings)
+	cfg.Roles = config.RolesConfig{"ops": {"telegram:7"}}
+	return cfg
+}
+
+var noon = time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC) // Wednesday
+
+func TestResolveRoute_ContentCommand(t *testing.T) {
+	r := NewRouteResolver(conditionalConfig())
+
+	// Content bindings win even over a peer binding.
+	route := r.ResolveRoute(RouteInput{
+		Channel: "telegram",
+		Peer:    &RoutePeer{Kind: "direct", ID: "vip"},
+		Content: "@Coder\nfix the build",
+		Time:    noon,
+	})
+	if route.AgentID != "coder" || route.MatchedBy != "binding.content" {
+		t.Fatalf("route = %s via %s, want coder via binding.content", route.AgentID, route.MatchedBy)
+	}
+	if route.Content != "fix the build" {
+		t.Errorf("Content = %q, want command removed", route.Content)
+	}
+	if route.Binding == nil || route.Binding.Match.Content.Command != "@coder" {
+		t.Errorf("Binding = %+v", route.Binding)
+	}
+
+	route = r.ResolveRoute(RouteInput{Channel: "telegram", Content: "here is the Stack Trace:", Time: noon})
+	if route.AgentID != "coder" || route.Content != "" {
+		t.Errorf("regex route = %s content %q, want coder with content unchanged", route.AgentID, route.Content)
+	}
+
+	route = r.ResolveRoute(RouteInput{Channel: "telegram", Content: "@coderx hi", Time: noon})
+	if route.AgentID != "main" {
+		t.Errorf("partial command matched %s", route.AgentID)
+	}
+}
+
+func TestResolveRoute_ScheduleWrapsMidnight(t *testing.T) {
+	r := NewRouteResolver(conditionalConfig())
+
+	cases := []struct {
+		at   time.Time
+		want string
+	}{
+		{noon, "main"},
+		{time.Date(2026, 3, 4, 23, 30, 0, 0, time.UTC), "night"},
+		{time.Date(2026, 3, 5, 6, 59, 0, 0, time.UTC), "night"},
+		{time.Date(2026, 3, 5, 7, 0, 0, 0, time.UTC), "main"},
+	}
+	for _, tc := range cases {
+		route := r.ResolveRoute(RouteInput{Channel: "telegram", Content: "hi", Time: tc.at})
+		if route.AgentID != tc.want {
+			t.Errorf("at %s: agent = %s, want %s", tc.at.Format("15:04"), route.AgentID, tc.want)
+		}
+	}
+
+	route := r.ResolveRoute(RouteInput{Channel: "telegram", Time: cases[1].at})
+	if route.MatchedBy != "binding.channel+schedule" || route.Binding == nil {
+		t.Errorf("MatchedBy = %q, want binding.channel+schedule", route.MatchedBy)
+	}
+
+	// A peer binding outranks a conditional channel binding.
+	route = r.ResolveRoute(RouteInput{
+		Channel: "telegram",
+		Peer:    &RoutePeer{Kind: "direct", ID: "vip"},
+		Time:    cases[1].at,
+	})
+	if route.AgentID != "support" {
+		t.Errorf("peer route = %s, want support", route.AgentID)
+	}
+}
+
+func TestResolveRoute_MediaAndRoles(t *testing.T) {
+	r := NewRouteResolver(conditionalConfig())
+
+	route := r.ResolveRoute(RouteInput{Channel: "telegram", MediaType: MediaVoice, Time: noon})
+	if route.AgentID != "voice" || route.MatchedBy != "binding.channel+media" {
+		t.Errorf("voice route = %s via %s", route.AgentID, route.MatchedBy)
+	}
+
+	route = r.ResolveRoute(RouteInput{Channel: "telegram", SenderIDs: []string{"7", "telegram:7"}, Time: noon})
+	if route.AgentID != "admin" || route.MatchedBy != "binding.channel+role" {
+		t.Errorf("role from config = %s via %s", route.AgentID, route.MatchedBy)
+	}
+
+	route = r.ResolveRoute(RouteInput{Channel: "telegram", SenderRoles: []string{"OPS"}, Time: noon})
+	if route.AgentID != "admin" {
+		t.Errorf("role from channel = %s, want admin", route.AgentID)
+	}
+}
+
+func TestScheduleDaysAndInvalidValues(t *testing.T) {
+	c := &matcherCache{}
+	weekdays := &config.ScheduleMatch{Days: []string{"Mon", "tuesday", "wed"}, Timezone: "UTC"}
+	if !c.inSchedule(weekdays, noon) {
+		t.Error("Wednesday should match")
+	}
+	if c.inSchedule(weekdays, noon.AddDate(0, 0, 3)) {
+		t.Error("Saturday should not match")
+	}
+	if c.inSchedule(&config.ScheduleMatch{Timezone: "Mars/Olympus"}, noon) {
+		t.Error("invalid timezone should never match")
+	}
+	if c.inSchedule(&config.ScheduleMatch{From: "25:00"}, noon) {
+		t.Error("invalid window should never match")
+	}
+	if ok, _ := c.matchContent(&config.ContentMatch{Regex: "("}, "("); ok {
+		t.Error("invalid regex should never match")
+	}
+	if ok, _ := c.matchContent(&config.ContentMatch{}, "anything"); ok {
+		t.Error("empty content match should never match")
+	}
+}
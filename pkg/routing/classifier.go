@@ -1,5 +1,10 @@
 package routing
 
+import (
+	"context"
+	"fmt"
+)
+
 // Classifier evaluates a feature set and returns a complexity score in [0, 1].
 // A higher score indicates a more complex task that benefits from a heavy model.
 // The score is compared against the configured threshold: score >= threshold selects
@@ -11,6 +16,28 @@ type Classifier interface {
 	Score(f Features) float64
 }
 
+// RequestClassifier is implemented by classifiers that need the raw message
+// and may fail or block, such as ModelClassifier. The Router calls
+// ClassifyRequest instead of Score and falls back to the RuleClassifier
+// when it returns an error.
+type RequestClassifier interface {
+	Classifier
+	ClassifyRequest(ctx context.Context, msg string, f Features) (float64, error)
+}
+
+// namedClassifier lets a classifier report a stable name for logs and
+// session metadata.
+type namedClassifier interface {
+	Name() string
+}
+
+func classifierName(c Classifier) string {
+	if n, ok := c.(namedClassifier); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", c)
+}
+
 // RuleClassifier is the v1 implementation.
 // It uses a weighted sum of structural signals with no external dependencies,
 // no API calls, and sub-microsecond latency. The raw sum is capped at 1.0 so
@@ -35,6 +62,9 @@ type Classifier interface {
 //   - Any message with an image/audio attachment:    1.00 → heavy  ✓
 type RuleClassifier struct{}
 
+// Name implements namedClassifier.
+func (c *RuleClassifier) Name() string { return "rules" }
+
 // Score computes the complexity score for the given feature set.
 // The returned value is in [0, 1]. Attachments short-circuit to 1.0.
 func (c *RuleClassifier) Score(f Features) float64 {
@@ -0,0 +1,268 @@
+package routing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of decision log entries. A feedback entry refers to an earlier
+// decision by ID, which keeps the log append-only: the outcome of a turn is
+// only known once the user's next message arrives.
+const (
+	EntryDecision = "decision"
+	EntryFeedback = "feedback"
+)
+
+// DecisionRecord is one line of the decision log.
+type DecisionRecord struct {
+	Kind string    `json:"kind"`
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+
+	// Decision fields.
+	AgentID          string   `json:"agent_id,omitempty"`
+	SessionKey       string   `json:"session_key,omitempty"`
+	Features         Features `json:"features"`
+	Score            float64  `json:"score"`
+	Classifier       string   `json:"classifier,omitempty"`
+	Tier             string   `json:"tier,omitempty"`
+	Model            string   `json:"model,omitempty"`
+	LightModel       string   `json:"light_model,omitempty"`
+	PrimaryModel     string   `json:"primary_model,omitempty"`
+	Required         []string `json:"required,omitempty"`
+	LatencyMs        int64    `json:"latency_ms"`
+	PromptTokens     int      `json:"prompt_tokens"`
+	CompletionTokens int      `json:"completion_tokens"`
+	Cost             float64  `json:"cost"`
+
+	// Feedback fields. On decisions returned by ReadDecisions they are
+	// merged in from the matching feedback entries.
+	Retried    bool `json:"retried,omitempty"`
+	Complained bool `json:"complained,omitempty"`
+}
+
+// Primary reports whether the turn stayed on the primary model.
+func (r DecisionRecord) Primary() bool {
+	return r.Tier == PrimaryTier || r.Tier == ""
+}
+
+// Unhappy reports whether the user retried or complained after the turn.
+func (r DecisionRecord) Unhappy() bool {
+	return r.Retried || r.Complained
+}
+
+type lastTurn struct {
+	id  string
+	msg string
+}
+
+// DecisionLog is an append-only JSONL log of routing decisions, written to
+// decisions.jsonl under dir. It remembers the last decision of every session
+// so the next user message can be checked for a retry or complaint.
+type DecisionLog struct {
+	path string
+
+	mu   sync.Mutex
+	last map[string]lastTurn
+	seq  uint64
+	now  func() time.Time
+}
+
+// NewDecisionLog opens (or creates) a decision log rooted at dir.
+func NewDecisionLog(dir string) *DecisionLog {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("routing: create decision log directory %s: %v", dir, err)
+	}
+	return &DecisionLog{
+		path: DecisionLogPath(dir),
+		last: make(map[string]lastTurn),
+		now:  time.Now,
+	}
+}
+
+// DecisionLogPath returns the log file inside dir.
+func DecisionLogPath(dir string) string {
+	return filepath.Join(dir, "decisions.jsonl")
+}
+
+// Record stamps and appends a decision. msg is the user message that was
+// routed; it is kept in memory only, to detect a verbatim retry. Failures are
+// logged; logging must never break a conversation.
+func (l *DecisionLog) Record(r DecisionRecord, msg string) DecisionRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Kind = EntryDecision
+	if r.Time.IsZero() {
+		r.Time = l.now()
+	}
+	l.seq++
+	r.ID = strconv.FormatInt(r.Time.UnixNano(), 36) + "-" + strconv.FormatUint(l.seq, 36)
+	if r.SessionKey != "" {
+		l.last[r.SessionKey] = lastTurn{id: r.ID, msg: msg}
+	}
+
+	if err := l.appendLocked(r); err != nil {
+		log.Printf("routing: append decision: %v", err)
+	}
+	return r
+}
+
+// Observe checks a new user message against the previous decision of the
+// same session and appends a feedback entry when it looks like a retry or a
+// complaint. Each decision receives feedback at most once.
+func (l *DecisionLog) Observe(sessionKey, msg string) {
+	if sessionKey == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	prev, ok := l.last[sessionKey]
+	if !ok {
+		return
+	}
+	delete(l.last, sessionKey)
+
+	retried, complained := DetectFeedback(prev.msg, msg)
+	if !retried && !complained {
+		return
+	}
+	fb := DecisionRecord{
+		Kind:       EntryFeedback,
+		ID:         prev.id,
+		Time:       l.now(),
+		SessionKey: sessionKey,
+		Retried:    retried,
+		Complained: complained,
+	}
+	if err := l.appendLocked(fb); err != nil {
+		log.Printf("routing: append feedback: %v", err)
+	}
+}
+
+func (l *DecisionLog) appendLocked(r DecisionRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// complaintPhrases are matched case-insensitively as substrings of the
+// message following a turn. The list is deliberately short: a false positive
+// only skews the replay report, but it should stay rare.
+var complaintPhrases = []string{
+	"that's wrong",
+	"this is wrong",
+	"wrong answer",
+	"incorrect",
+	"not what i asked",
+	"not what i meant",
+	"doesn't work",
+	"does not work",
+	"didn't work",
+	"that's not right",
+	"you misunderstood",
+	"useless",
+	"不对",
+	"错了",
+	"没用",
+}
+
+// retryPhrases are whole messages that ask for the previous turn again.
+var retryPhrases = []string{"retry", "again", "try again", "/retry", "重试", "再试一次"}
+
+// DetectFeedback classifies the message that followed a turn. A retry is the
+// same message sent again (ignoring case and whitespace) or a bare request to
+// try again; a complaint contains one of a few negative phrases.
+func DetectFeedback(prevMsg, msg string) (retried, complained bool) {
+	norm := normalizeMessage(msg)
+	if norm == "" {
+		return false, false
+	}
+	if norm == normalizeMessage(prevMsg) {
+		retried = true
+	}
+	for _, p := range retryPhrases {
+		if strings.TrimRight(norm, ".!?！？。") == p {
+			retried = true
+		}
+	}
+	for _, p := range complaintPhrases {
+		if strings.Contains(norm, p) {
+			complained = true
+			break
+		}
+	}
+	return retried, complained
+}
+
+func normalizeMessage(msg string) string {
+	return strings.ToLower(strings.Join(strings.Fields(msg), " "))
+}
+
+// ReadDecisions reads the decision log in dir without opening a
+// DecisionLog, so the CLI can evaluate a workspace the gateway is writing
+// to. Feedback entries are folded into their decisions; only decisions with
+// since <= Time are returned (zero means all).
+func ReadDecisions(dir string, since time.Time) ([]DecisionRecord, error) {
+	f, err := os.Open(DecisionLogPath(dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("routing: open decision log: %w", err)
+	}
+	defer f.Close()
+
+	var decisions []DecisionRecord
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var r DecisionRecord
+		// Skip malformed lines such as a partial write left by a crash.
+		if err := json.Unmarshal(line, &r); err != nil {
+			continue
+		}
+		switch r.Kind {
+		case EntryDecision:
+			if !since.IsZero() && r.Time.Before(since) {
+				continue
+			}
+			index[r.ID] = len(decisions)
+			decisions = append(decisions, r)
+		case EntryFeedback:
+			if i, ok := index[r.ID]; ok {
+				decisions[i].Retried = decisions[i].Retried || r.Retried
+				decisions[i].Complained = decisions[i].Complained || r.Complained
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("routing: read decision log: %w", err)
+	}
+	return decisions, nil
+}
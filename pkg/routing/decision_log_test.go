@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDecisionLog_RecordAndFeedback(t *testing.T) {
+	dir := t.TempDir()
+	l := NewDecisionLog(dir)
+
+	first := l.Record(DecisionRecord{SessionKey: "s1", Tier: "light", Model: "small", Score: 0.1}, "fix my code")
+	l.Observe("s1", "that's wrong, it still crashes")
+	l.Record(DecisionRecord{SessionKey: "s1", Tier: PrimaryTier, Model: "big", Score: 0.5}, "it still crashes")
+	l.Observe("s1", "thanks!")
+	// Feedback is only recorded once per decision.
+	l.Observe("s1", "retry")
+
+	records, err := ReadDecisions(dir, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadDecisions: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %d, want 2", len(records))
+	}
+	if records[0].ID != first.ID || !records[0].Complained || records[0].Retried {
+		t.Errorf("first = %+v, want complained", records[0])
+	}
+	if records[1].Unhappy() {
+		t.Errorf("second = %+v, want no feedback", records[1])
+	}
+	if !records[1].Primary() || records[0].Primary() {
+		t.Error("Primary() mismatch")
+	}
+}
+
+func TestReadDecisions_SinceAndCorruptLines(t *testing.T) {
+	dir := t.TempDir()
+	l := NewDecisionLog(dir)
+	old := time.Now().Add(-48 * time.Hour)
+	l.Record(DecisionRecord{Time: old, Tier: "light"}, "")
+	l.Record(DecisionRecord{Tier: PrimaryTier}, "")
+
+	f, err := os.OpenFile(DecisionLogPath(dir), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"kind":"decision","id":`)
+	f.Close()
+
+	records, err := ReadDecisions(dir, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("ReadDecisions: %v", err)
+	}
+	if len(records) != 1 || records[0].Tier != PrimaryTier {
+		t.Errorf("records = %+v, want only the recent decision", records)
+	}
+
+	none, err := ReadDecisions(t.TempDir(), time.Time{})
+	if err != nil || none != nil {
+		t.Errorf("missing log = %v, %v; want nil, nil", none, err)
+	}
+}
+
+func TestDetectFeedback(t *testing.T) {
+	cases := []struct {
+		prev, msg           string
+		retried, complained bool
+	}{
+		{"What is 2+2?", "what is  2+2?", true, false},
+		{"What is 2+2?", "Try again.", true, false},
+		{"What is 2+2?", "That's wrong", false, true},
+		{"summarize this", "不对", false, true},
+		{"What is 2+2?", "And 3+3?", false, false},
+		{"What is 2+2?", "what's wrong with my config?", false, false},
+		{"hi", "", false, false},
+	}
+	for _, tc := range cases {
+		retried, complained := DetectFeedback(tc.prev, tc.msg)
+		if retried != tc.retried || complained != tc.complained {
+			t.Errorf("DetectFeedback(%q, %q) = %v, %v; want %v, %v",
+				tc.prev, tc.msg, retried, complained, tc.retried, tc.complained)
+		}
+	}
+}
@@ -18,23 +18,27 @@ type Features struct {
 	// TokenEstimate is a proxy for token count.
 	// CJK runes count as 1 token each; non-CJK runes as 0.25 tokens each.
 	// This avoids API calls while giving accurate estimates for all scripts.
-	TokenEstimate int
+	TokenEstimate int `json:"token_estimate"`
 
 	// CodeBlockCount is the number of fenced code blocks (``` pairs) in the message.
 	// Coding tasks almost always require the heavy model.
-	CodeBlockCount int
+	CodeBlockCount int `json:"code_blocks"`
 
 	// RecentToolCalls is the count of tool_call messages in the last lookbackWindow
 	// history entries. A high density indicates an active agentic workflow.
-	RecentToolCalls int
+	RecentToolCalls int `json:"recent_tool_calls"`
 
 	// ConversationDepth is the total number of messages in the session history.
 	// Deep sessions tend to carry implicit complexity built up over many turns.
-	ConversationDepth int
+	ConversationDepth int `json:"conversation_depth"`
+
+	// HistoryTokens is the token estimate of all history message contents.
+	// Together with TokenEstimate it decides whether long context is needed.
+	HistoryTokens int `json:"history_tokens"`
 
 	// HasAttachments is true when the message appears to contain media (images,
 	// audio, video). Multi-modal inputs require vision-capable heavy models.
-	HasAttachments bool
+	HasAttachments bool `json:"has_attachments,omitempty"`
 }
 
 // ExtractFeatures computes the structural feature vector for a message.
@@ -46,6 +50,7 @@ func ExtractFeatures(msg string, history []providers.Message) Features {
 		CodeBlockCount:    countCodeBlocks(msg),
 		RecentToolCalls:   countRecentToolCalls(history),
 		ConversationDepth: len(history),
+		HistoryTokens:     estimateHistoryTokens(history),
 		HasAttachments:    hasAttachments(msg),
 	}
 }
@@ -69,6 +74,15 @@ func estimateTokens(msg string) int {
 	return cjk + (total-cjk)/4
 }
 
+// estimateHistoryTokens sums estimateTokens over the history contents.
+func estimateHistoryTokens(history []providers.Message) int {
+	total := 0
+	for _, msg := range history {
+		total += estimateTokens(msg.Content)
+	}
+	return total
+}
+
 // countCodeBlocks counts the number of complete fenced code blocks.
 // Each ``` delimiter increments a counter; pairs of delimiters form one block.
 // An unclosed opening fence (odd count) is treated as zero complete blocks
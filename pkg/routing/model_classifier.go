@@ -0,0 +1,103 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// defaultClassifierTimeout bounds a ModelClassifier call. Grading must be
+// much faster than answering or it defeats the point of routing.
+const defaultClassifierTimeout = 1500 * time.Millisecond
+
+// maxGradedRunes truncates long messages before they are sent for grading;
+// the rule signals already capture sheer length.
+const maxGradedRunes = 2000
+
+const gradingPrompt = "You grade how capable a model must be to answer a request. " +
+	"Reply with a single integer from 0 to 10 and nothing else. " +
+	"0 = greeting or trivial lookup, 5 = ordinary question needing some reasoning, " +
+	"10 = hard multi-step reasoning, coding or analysis."
+
+// ModelClassifier asks a small (typically local) model to grade each request
+// on a 0-10 scale. Its Score method, used when no message is available, and
+// the Router's fallback on timeout or unparsable output both use the
+// RuleClassifier.
+type ModelClassifier struct {
+	provider providers.LLMProvider
+	model    string
+	timeout  time.Duration
+	rules    RuleClassifier
+	onUsage  UsageHook
+}
+
+// UsageHook receives the token usage of an LLM call made while routing. ctx
+// is the one passed to Router.Route.
+type UsageHook func(ctx context.Context, model string, u *providers.UsageInfo)
+
+// NewModelClassifier creates a classifier that calls model through provider.
+// A zero timeout uses defaultClassifierTimeout.
+func NewModelClassifier(provider providers.LLMProvider, model string, timeout time.Duration) *ModelClassifier {
+	if timeout <= 0 {
+		timeout = defaultClassifierTimeout
+	}
+	return &ModelClassifier{provider: provider, model: model, timeout: timeout}
+}
+
+// SetUsageHook makes the classifier report the usage of its grading calls
+// to h. Call it before the classifier is used.
+func (c *ModelClassifier) SetUsageHook(h UsageHook) {
+	c.onUsage = h
+}
+
+// Name implements namedClassifier.
+func (c *ModelClassifier) Name() string { return "model" }
+
+// Score implements Classifier using the rule-based weights.
+func (c *ModelClassifier) Score(f Features) float64 {
+	return c.rules.Score(f)
+}
+
+// ClassifyRequest implements RequestClassifier. Attachments are not graded;
+// the Router's vision gate handles them.
+func (c *ModelClassifier) ClassifyRequest(ctx context.Context, msg string, _ Features) (float64, error) {
+	if utf8.RuneCountInString(msg) > maxGradedRunes {
+		msg = string([]rune(msg)[:maxGradedRunes])
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.provider.Chat(ctx, []providers.Message{
+		{Role: "system", Content: gradingPrompt},
+		{Role: "user", Content: msg},
+	}, nil, c.model, map[string]any{
+		"max_tokens":  8,
+		"temperature": 0.0,
+	})
+	if c.onUsage != nil && resp != nil && resp.Usage != nil {
+		c.onUsage(ctx, c.model, resp.Usage)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("grading with %s: %w", c.model, err)
+	}
+	return parseGrade(resp.Content)
+}
+
+// parseGrade extracts the first integer in content and maps 0-10 to [0, 1].
+func parseGrade(content string) (float64, error) {
+	field := strings.FieldsFunc(content, func(r rune) bool { return r < '0' || r > '9' })
+	if len(field) == 0 {
+		return 0, fmt.Errorf("no grade in classifier reply %q", content)
+	}
+	grade, err := strconv.Atoi(field[0])
+	if err != nil || grade > 10 {
+		return 0, fmt.Errorf("invalid grade in classifier reply %q", content)
+	}
+	return float64(grade) / 10, nil
+}
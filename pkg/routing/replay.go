@@ -0,0 +1,181 @@
+package routing
+
+import (
+	"fmt"
+)
+
+// ReplayRecorded replays with the scores stored in the log, so only the
+// threshold changes. Useful for traffic scored by the model classifier,
+// whose input (the raw message) is not logged.
+const ReplayRecorded = "recorded"
+
+// CostFunc prices a turn's tokens on a model.
+type CostFunc func(model string, promptTokens, completionTokens int) float64
+
+// ReplayOptions selects the routing setup to evaluate against logged traffic.
+type ReplayOptions struct {
+	// Threshold is the light/primary cutoff; <= 0 means defaultThreshold.
+	Threshold float64
+
+	// Classifier is "rules" (re-score the logged features) or
+	// ReplayRecorded. Empty means "rules".
+	Classifier string
+
+	// Cost prices turns that move to a different model. When nil, a moved
+	// turn keeps its logged cost.
+	Cost CostFunc
+}
+
+// ReplayOutcome summarizes one routing setup over the replayed turns.
+type ReplayOutcome struct {
+	LightTurns int
+	Cost       float64
+	// Unhappy is the number of turns followed by a retry or complaint. For
+	// the replay it is an estimate: a turn that changes model takes the
+	// observed rate of the side it moves to.
+	Unhappy float64
+}
+
+// ReplayReport compares the logged routing with a replayed setup.
+type ReplayReport struct {
+	Turns     int
+	Threshold float64
+	Baseline  ReplayOutcome
+	Replay    ReplayOutcome
+	Promoted  int // light in the log, primary in the replay
+	Demoted   int // primary in the log, light in the replay
+
+	// Observed retry/complaint rates in the log, per side.
+	LightUnhappyRate   float64
+	PrimaryUnhappyRate float64
+}
+
+// LightShare returns the fraction of turns sent to a light model.
+func (o ReplayOutcome) LightShare(turns int) float64 {
+	if turns == 0 {
+		return 0
+	}
+	return float64(o.LightTurns) / float64(turns)
+}
+
+// UnhappyRate returns the fraction of turns followed by a retry or complaint.
+func (o ReplayOutcome) UnhappyRate(turns int) float64 {
+	if turns == 0 {
+		return 0
+	}
+	return o.Unhappy / float64(turns)
+}
+
+// Replay re-routes logged decisions under opts. The replay is two-way: a
+// turn either goes to the record's light model (the cheapest tier when it
+// was logged) or to its primary model, with the light tier's capability gate
+// applied. Token counts are assumed unchanged when a turn changes model.
+func Replay(records []DecisionRecord, opts ReplayOptions) (ReplayReport, error) {
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+
+	var scorer Classifier
+	switch opts.Classifier {
+	case "", "rules":
+		scorer = &RuleClassifier{}
+	case ReplayRecorded:
+	case "model":
+		return ReplayReport{}, fmt.Errorf(
+			"the model classifier cannot be replayed because messages are not logged; use %q to re-threshold its scores",
+			ReplayRecorded)
+	default:
+		return ReplayReport{}, fmt.Errorf("unknown classifier %q (use rules or %s)", opts.Classifier, ReplayRecorded)
+	}
+
+	report := ReplayReport{Turns: len(records), Threshold: threshold}
+
+	var lightTurns, lightUnhappy, primaryTurns, primaryUnhappy int
+	for _, r := range records {
+		if r.Primary() {
+			primaryTurns++
+			if r.Unhappy() {
+				primaryUnhappy++
+			}
+		} else {
+			lightTurns++
+			if r.Unhappy() {
+				lightUnhappy++
+			}
+		}
+	}
+	overall := ratio(lightUnhappy+primaryUnhappy, len(records))
+	report.LightUnhappyRate = overall
+	if lightTurns > 0 {
+		report.LightUnhappyRate = ratio(lightUnhappy, lightTurns)
+	}
+	report.PrimaryUnhappyRate = overall
+	if primaryTurns > 0 {
+		report.PrimaryUnhappyRate = ratio(primaryUnhappy, primaryTurns)
+	}
+
+	for _, r := range records {
+		wasLight := !r.Primary()
+		unhappy := 0.0
+		if r.Unhappy() {
+			unhappy = 1
+		}
+
+		report.Baseline.Cost += r.Cost
+		report.Baseline.Unhappy += unhappy
+		if wasLight {
+			report.Baseline.LightTurns++
+		}
+
+		score := r.Score
+		if scorer != nil {
+			// Same masking as Route: attachments are handled by the gate.
+			f := r.Features
+			f.HasAttachments = false
+			score = scorer.Score(f)
+		}
+		toLight := r.LightModel != "" && score < threshold &&
+			LightTier(r.LightModel, threshold).supports(r.Required)
+
+		model := r.PrimaryModel
+		if toLight {
+			model = r.LightModel
+			report.Replay.LightTurns++
+		}
+
+		switch {
+		case toLight == wasLight && (model == r.Model || model == ""):
+			report.Replay.Cost += r.Cost
+			report.Replay.Unhappy += unhappy
+			continue
+		case toLight && !wasLight:
+			report.Demoted++
+		case !toLight && wasLight:
+			report.Promoted++
+		}
+
+		if opts.Cost != nil && model != "" {
+			report.Replay.Cost += opts.Cost(model, r.PromptTokens, r.CompletionTokens)
+		} else {
+			report.Replay.Cost += r.Cost
+		}
+		switch {
+		case toLight == wasLight:
+			// Same side, different tier model (multi-tier logs).
+			report.Replay.Unhappy += unhappy
+		case toLight:
+			report.Replay.Unhappy += report.LightUnhappyRate
+		default:
+			report.Replay.Unhappy += report.PrimaryUnhappyRate
+		}
+	}
+	return report, nil
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
@@ -0,0 +1,95 @@
+package routing
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func replayRecords() []DecisionRecord {
+	base := DecisionRecord{LightModel: "small", PrimaryModel: "big", PromptTokens: 1000, CompletionTokens: 100}
+	light := func(tokens int, unhappy bool) DecisionRecord {
+		r := base
+		r.Tier, r.Model, r.Cost, r.Retried = "light", "small", 0.001, unhappy
+		r.Features = Features{TokenEstimate: tokens}
+		r.Score = (&RuleClassifier{}).Score(r.Features)
+		return r
+	}
+	primary := func(tokens int, unhappy bool) DecisionRecord {
+		r := base
+		r.Tier, r.Model, r.Cost, r.Complained = PrimaryTier, "big", 0.01, unhappy
+		r.Features = Features{TokenEstimate: tokens}
+		r.Score = (&RuleClassifier{}).Score(r.Features)
+		return r
+	}
+	return []DecisionRecord{
+		light(10, false),    // score 0
+		light(100, true),    // score 0.15
+		primary(300, false), // score 0.35
+		primary(300, true),  // score 0.35
+	}
+}
+
+func testCost(model string, prompt, completion int) float64 {
+	if model == "big" {
+		return 0.01
+	}
+	return 0.001
+}
+
+func TestReplay_LowerThresholdPromotes(t *testing.T) {
+	report, err := Replay(replayRecords(), ReplayOptions{Threshold: 0.1, Cost: testCost})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if report.Turns != 4 || report.Baseline.LightTurns != 2 || report.Replay.LightTurns != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Promoted != 1 || report.Demoted != 0 {
+		t.Errorf("promoted/demoted = %d/%d, want 1/0", report.Promoted, report.Demoted)
+	}
+	if math.Abs(report.Baseline.Cost-0.022) > 1e-9 || math.Abs(report.Replay.Cost-0.031) > 1e-9 {
+		t.Errorf("cost = %v -> %v, want 0.022 -> 0.031", report.Baseline.Cost, report.Replay.Cost)
+	}
+	// The promoted turn was unhappy; on primary it takes primary's 50% rate.
+	if report.Baseline.Unhappy != 2 || report.Replay.Unhappy != 1.5 {
+		t.Errorf("unhappy = %v -> %v, want 2 -> 1.5", report.Baseline.Unhappy, report.Replay.Unhappy)
+	}
+}
+
+func TestReplay_HigherThresholdDemotesUnlessGated(t *testing.T) {
+	records := replayRecords()
+	records[3].Required = []string{CapVision}
+
+	report, err := Replay(records, ReplayOptions{Threshold: 0.5, Cost: testCost})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if report.Demoted != 1 || report.Replay.LightTurns != 3 {
+		t.Errorf("report = %+v, want one demotion (vision turn stays primary)", report)
+	}
+}
+
+func TestReplay_RecordedScores(t *testing.T) {
+	records := replayRecords()
+	for i := range records {
+		records[i].Score = 0.9 // e.g. scored by the model classifier
+	}
+	report, err := Replay(records, ReplayOptions{Threshold: 0.35, Classifier: ReplayRecorded})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if report.Replay.LightTurns != 0 || report.Promoted != 2 {
+		t.Errorf("report = %+v, want everything on primary", report)
+	}
+}
+
+func TestReplay_UnsupportedClassifier(t *testing.T) {
+	if _, err := Replay(nil, ReplayOptions{Classifier: "model"}); err == nil ||
+		!strings.Contains(err.Error(), ReplayRecorded) {
+		t.Errorf("model classifier: err = %v", err)
+	}
+	if _, err := Replay(nil, ReplayOptions{Classifier: "bogus"}); err == nil {
+		t.Error("expected error for unknown classifier")
+	}
+}
@@ -2,18 +2,40 @@ package routing
 
 import (
 	"strings"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 )
 
+// Media types understood by binding media conditions.
+const (
+	MediaText  = "text"
+	MediaVoice = "voice"
+	MediaImage = "image"
+	MediaFile  = "file"
+)
+
 // RouteInput contains the routing context from an inbound message.
 type RouteInput struct {
 	Channel    string
 	AccountID  string
 	Peer       *RoutePeer
 	ParentPeer *RoutePeer
+	ThreadID   string // thread or forum topic within Peer
 	GuildID    string
 	TeamID     string
+
+	// Inputs for binding conditions.
+	Content     string    // message text
+	SenderIDs   []string  // every known identity of the sender, matched against config roles
+	SenderRoles []string  // roles reported by the channel itself
+	MediaType   string    // MediaText, MediaVoice, MediaImage or MediaFile; empty means text
+	Time        time.Time // arrival time; zero means now
+
+	// HandoffAgentID is the agent the chat was handed off to, if any. It
+	// overrides every binding except content bindings, which address an
+	// agent explicitly.
+	HandoffAgentID string
 }
 
 // ResolvedRoute is the result of agent routing.
@@ -23,44 +45,75 @@ type ResolvedRoute struct {
 	AccountID      string
 	SessionKey     string
 	MainSessionKey string
-	MatchedBy      string // "binding.peer", "binding.peer.parent", "binding.guild", "binding.team", "binding.account", "binding.channel", "default"
+	// MatchedBy is "binding.content", "handoff", "binding.peer", "binding.peer.parent",
+	// "binding.guild", "binding.team", "binding.account", "binding.channel" or
+	// "default". Bindings matched through conditions append them, e.g.
+	// "binding.channel+schedule+media".
+	MatchedBy string
+	// Binding is the winning binding, nil for the default agent. It points
+	// into the config and must not be modified.
+	Binding *config.AgentBinding
+	// Content is the message with a matched content command removed. It is
+	// empty when the message is unchanged.
+	Content string
 }
 
 // RouteResolver determines which agent handles a message based on config bindings.
 type RouteResolver struct {
-	cfg *config.Config
+	cfg      *config.Config
+	matchers *matcherCache
 }
 
 // NewRouteResolver creates a new route resolver.
 func NewRouteResolver(cfg *config.Config) *RouteResolver {
-	return &RouteResolver{cfg: cfg}
+	return &RouteResolver{cfg: cfg, matchers: &matcherCache{}}
 }
 
 // ResolveRoute determines which agent handles the message and constructs session keys.
-// Implements the 7-level priority cascade:
-// peer > parent_peer > guild > team > account > channel_wildcard > default
+// Content bindings are checked first, then the 7-level priority cascade:
+// peer > parent_peer > guild > team > account > channel_wildcard > default.
+// Within a cascade level, bindings whose conditions hold win over
+// unconditional ones; bindings whose conditions fail are skipped.
 func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 	channel := strings.ToLower(strings.TrimSpace(input.Channel))
 	accountID := NormalizeAccountID(input.AccountID)
 	peer := input.Peer
+	if input.Time.IsZero() {
+		input.Time = time.Now()
+	}
 
 	dmScope := DMScope(r.cfg.Session.DMScope)
 	if dmScope == "" {
 		dmScope = DMScopeMain
 	}
 	identityLinks := r.cfg.Session.IdentityLinks
+	threadScope := ThreadScope(r.cfg.Session.ThreadScope)
+
+	// A thread that is a channel of its own (Discord) arrives as its own peer
+	// with its channel as ParentPeer. Sessions and bindings keep using the
+	// thread's ID; a shared thread scope folds it into the parent's session.
+	keyPeer, threadID := peer, strings.TrimSpace(input.ThreadID)
+	if peer != nil && threadID != "" && strings.EqualFold(strings.TrimSpace(peer.ID), threadID) {
+		threadID = ""
+		if threadScope == ThreadScopeShared && input.ParentPeer != nil && strings.TrimSpace(input.ParentPeer.ID) != "" {
+			keyPeer = input.ParentPeer
+		}
+	}
 
 	bindings := r.filterBindings(channel, accountID)
+	roles := r.senderRoles(input)
 
-	choose := func(agentID string, matchedBy string) ResolvedRoute {
+	chooseAgent := func(agentID, matchedBy string, b *config.AgentBinding) ResolvedRoute {
 		resolvedAgentID := r.pickAgentID(agentID)
 		sessionKey := strings.ToLower(BuildAgentPeerSessionKey(SessionKeyParams{
 			AgentID:       resolvedAgentID,
 			Channel:       channel,
 			AccountID:     accountID,
-			Peer:          peer,
+			Peer:          keyPeer,
 			DMScope:       dmScope,
 			IdentityLinks: identityLinks,
+			ThreadID:      threadID,
+			ThreadScope:   threadScope,
 		}))
 		mainSessionKey := strings.ToLower(BuildAgentMainSessionKey(resolvedAgentID))
 		return ResolvedRoute{
@@ -70,13 +123,45 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 			SessionKey:     sessionKey,
 			MainSessionKey: mainSessionKey,
 			MatchedBy:      matchedBy,
+			Binding:        b,
+		}
+	}
+	choose := func(b *config.AgentBinding, matchedBy string) ResolvedRoute {
+		if b == nil {
+			return chooseAgent(r.resolveDefaultAgentID(), matchedBy, nil)
+		}
+		if conds := conditionNames(b.Match); len(conds) > 0 && matchedBy != "binding.content" {
+			matchedBy += "+" + strings.Join(conds, "+")
 		}
+		return chooseAgent(b.AgentID, matchedBy, b)
+	}
+
+	// Priority 0: Content binding (explicit addressing such as "@coder ...")
+	if match, content := r.findContentMatch(bindings, input, roles); match != nil {
+		route := choose(match, "binding.content")
+		route.Content = content
+		return route
+	}
+
+	// Handoff: the chat was passed to another agent.
+	if id := strings.TrimSpace(input.HandoffAgentID); id != "" {
+		return chooseAgent(id, "handoff", nil)
 	}
 
-	// Priority 1: Peer binding
+	// Content bindings take no part in the cascade.
+	bindings = r.applyConditions(bindings, input, roles)
+
+	// Priority 1: Peer binding; a binding on one thread ("<peer>/<thread>")
+	// beats one on the whole group.
 	if peer != nil && strings.TrimSpace(peer.ID) != "" {
+		if threadID != "" {
+			threadPeer := &RoutePeer{Kind: peer.Kind, ID: ThreadPeerID(peer.ID, threadID)}
+			if match := r.findPeerMatch(bindings, threadPeer); match != nil {
+				return choose(match, "binding.peer")
+			}
+		}
 		if match := r.findPeerMatch(bindings, peer); match != nil {
-			return choose(match.AgentID, "binding.peer")
+			return choose(match, "binding.peer")
 		}
 	}
 
@@ -84,7 +169,7 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 	parentPeer := input.ParentPeer
 	if parentPeer != nil && strings.TrimSpace(parentPeer.ID) != "" {
 		if match := r.findPeerMatch(bindings, parentPeer); match != nil {
-			return choose(match.AgentID, "binding.peer.parent")
+			return choose(match, "binding.peer.parent")
 		}
 	}
 
@@ -92,7 +177,7 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 	guildID := strings.TrimSpace(input.GuildID)
 	if guildID != "" {
 		if match := r.findGuildMatch(bindings, guildID); match != nil {
-			return choose(match.AgentID, "binding.guild")
+			return choose(match, "binding.guild")
 		}
 	}
 
@@ -100,27 +185,28 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 	teamID := strings.TrimSpace(input.TeamID)
 	if teamID != "" {
 		if match := r.findTeamMatch(bindings, teamID); match != nil {
-			return choose(match.AgentID, "binding.team")
+			return choose(match, "binding.team")
 		}
 	}
 
 	// Priority 5: Account binding
 	if match := r.findAccountMatch(bindings); match != nil {
-		return choose(match.AgentID, "binding.account")
+		return choose(match, "binding.account")
 	}
 
 	// Priority 6: Channel wildcard binding
 	if match := r.findChannelWildcardMatch(bindings); match != nil {
-		return choose(match.AgentID, "binding.channel")
+		return choose(match, "binding.channel")
 	}
 
 	// Priority 7: Default agent
-	return choose(r.resolveDefaultAgentID(), "default")
+	return choose(nil, "default")
 }
 
-func (r *RouteResolver) filterBindings(channel, accountID string) []config.AgentBinding {
-	var filtered []config.AgentBinding
-	for _, b := range r.cfg.Bindings {
+func (r *RouteResolver) filterBindings(channel, accountID string) []*config.AgentBinding {
+	var filtered []*config.AgentBinding
+	for i := range r.cfg.Bindings {
+		b := &r.cfg.Bindings[i]
 		matchChannel := strings.ToLower(strings.TrimSpace(b.Match.Channel))
 		if matchChannel == "" || matchChannel != channel {
 			continue
@@ -133,6 +219,27 @@ func (r *RouteResolver) filterBindings(channel, accountID string) []config.Agent
 	return filtered
 }
 
+// applyConditions drops content bindings and bindings whose conditions do
+// not hold, and moves conditional bindings ahead of unconditional ones so
+// each cascade level prefers them. Config order is otherwise kept.
+func (r *RouteResolver) applyConditions(
+	bindings []*config.AgentBinding,
+	input RouteInput,
+	roles []string,
+) []*config.AgentBinding {
+	var conditional, plain []*config.AgentBinding
+	for _, b := range bindings {
+		switch {
+		case b.Match.Content != nil:
+		case len(conditionNames(b.Match)) == 0:
+			plain = append(plain, b)
+		case r.conditionsMet(b.Match, input, roles):
+			conditional = append(conditional, b)
+		}
+	}
+	return append(conditional, plain...)
+}
+
 func matchesAccountID(matchAccountID, actual string) bool {
 	trimmed := strings.TrimSpace(matchAccountID)
 	if trimmed == "" {
@@ -144,9 +251,8 @@ func matchesAccountID(matchAccountID, actual string) bool {
 	return strings.ToLower(trimmed) == strings.ToLower(actual)
 }
 
-func (r *RouteResolver) findPeerMatch(bindings []config.AgentBinding, peer *RoutePeer) *config.AgentBinding {
-	for i := range bindings {
-		b := &bindings[i]
+func (r *RouteResolver) findPeerMatch(bindings []*config.AgentBinding, peer *RoutePeer) *config.AgentBinding {
+	for _, b := range bindings {
 		if b.Match.Peer == nil {
 			continue
 		}
@@ -162,31 +268,28 @@ func (r *RouteResolver) findPeerMatch(bindings []config.AgentBinding, peer *Rout
 	return nil
 }
 
-func (r *RouteResolver) findGuildMatch(bindings []config.AgentBinding, guildID string) *config.AgentBinding {
-	for i := range bindings {
-		b := &bindings[i]
+func (r *RouteResolver) findGuildMatch(bindings []*config.AgentBinding, guildID string) *config.AgentBinding {
+	for _, b := range bindings {
 		matchGuild := strings.TrimSpace(b.Match.GuildID)
 		if matchGuild != "" && matchGuild == guildID {
-			return &bindings[i]
+			return b
 		}
 	}
 	return nil
 }
 
-func (r *RouteResolver) findTeamMatch(bindings []config.AgentBinding, teamID string) *config.AgentBinding {
-	for i := range bindings {
-		b := &bindings[i]
+func (r *RouteResolver) findTeamMatch(bindings []*config.AgentBinding, teamID string) *config.AgentBinding {
+	for _, b := range bindings {
 		matchTeam := strings.TrimSpace(b.Match.TeamID)
 		if matchTeam != "" && matchTeam == teamID {
-			return &bindings[i]
+			return b
 		}
 	}
 	return nil
 }
 
-func (r *RouteResolver) findAccountMatch(bindings []config.AgentBinding) *config.AgentBinding {
-	for i := range bindings {
-		b := &bindings[i]
+func (r *RouteResolver) findAccountMatch(bindings []*config.AgentBinding) *config.AgentBinding {
+	for _, b := range bindings {
 		accountID := strings.TrimSpace(b.Match.AccountID)
 		if accountID == "*" {
 			continue
@@ -194,14 +297,13 @@ func (r *RouteResolver) findAccountMatch(bindings []config.AgentBinding) *config
 		if b.Match.Peer != nil || b.Match.GuildID != "" || b.Match.TeamID != "" {
 			continue
 		}
-		return &bindings[i]
+		return b
 	}
 	return nil
 }
 
-func (r *RouteResolver) findChannelWildcardMatch(bindings []config.AgentBinding) *config.AgentBinding {
-	for i := range bindings {
-		b := &bindings[i]
+func (r *RouteResolver) findChannelWildcardMatch(bindings []*config.AgentBinding) *config.AgentBinding {
+	for _, b := range bindings {
 		accountID := strings.TrimSpace(b.Match.AccountID)
 		if accountID != "*" {
 			continue
@@ -209,7 +311,7 @@ func (r *RouteResolver) findChannelWildcardMatch(bindings []config.AgentBinding)
 		if b.Match.Peer != nil || b.Match.GuildID != "" || b.Match.TeamID != "" {
 			continue
 		}
-		return &bindings[i]
+		return b
 	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package routing
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -70,6 +71,110 @@ func TestResolveRoute_PeerBinding(t *testing.T) {
 	}
 }
 
+func TestResolveRoute_ThreadPeerBinding(t *testing.T) {
+	agents := []config.AgentConfig{
+		{ID: "sales", Default: true},
+		{ID: "support"},
+		{ID: "ops"},
+	}
+	bindings := []config.AgentBinding{
+		{
+			AgentID: "ops",
+			Match: config.BindingMatch{
+				Channel: "telegram",
+				Peer:    &config.PeerMatch{Kind: "group", ID: "-100123"},
+			},
+		},
+		{
+			AgentID: "support",
+			Match: config.BindingMatch{
+				Channel: "telegram",
+				Peer:    &config.PeerMatch{Kind: "group", ID: "-100123/42"},
+			},
+		},
+	}
+	r := NewRouteResolver(testConfig(agents, bindings))
+
+	route := r.ResolveRoute(RouteInput{
+		Channel:  "telegram",
+		Peer:     &RoutePeer{Kind: "group", ID: "-100123"},
+		ThreadID: "42",
+	})
+	if route.AgentID != "support" || route.MatchedBy != "binding.peer" {
+		t.Errorf("topic 42 routed to %q by %q, want support by binding.peer", route.AgentID, route.MatchedBy)
+	}
+	if route.SessionKey != "agent:support:telegram:group:-100123/42" {
+		t.Errorf("SessionKey = %q", route.SessionKey)
+	}
+
+	route = r.ResolveRoute(RouteInput{
+		Channel:  "telegram",
+		Peer:     &RoutePeer{Kind: "group", ID: "-100123"},
+		ThreadID: "7",
+	})
+	if route.AgentID != "ops" {
+		t.Errorf("other topic routed to %q, want the group's agent ops", route.AgentID)
+	}
+}
+
+func TestResolveRoute_ThreadChannelBinding(t *testing.T) {
+	agents := []config.AgentConfig{
+		{ID: "sales", Default: true},
+		{ID: "support"},
+		{ID: "ops"},
+	}
+	bindings := []config.AgentBinding{
+		{
+			AgentID: "ops",
+			Match: config.BindingMatch{
+				Channel: "discord",
+				Peer:    &config.PeerMatch{Kind: "channel", ID: "c1"},
+			},
+		},
+		{
+			AgentID: "support",
+			Match: config.BindingMatch{
+				Channel: "discord",
+				Peer:    &config.PeerMatch{Kind: "channel", ID: "t1"},
+			},
+		},
+	}
+	cfg := testConfig(agents, bindings)
+	r := NewRouteResolver(cfg)
+	thread := func(id string) RouteInput {
+		return RouteInput{
+			Channel:    "discord",
+			Peer:       &RoutePeer{Kind: "channel", ID: id},
+			ParentPeer: &RoutePeer{Kind: "channel", ID: "c1"},
+			ThreadID:   id,
+		}
+	}
+
+	// A binding on the thread's own channel ID still applies, and the
+	// session keeps the thread's key.
+	route := r.ResolveRoute(thread("t1"))
+	if route.AgentID != "support" || route.MatchedBy != "binding.peer" {
+		t.Errorf("thread t1 routed to %q by %q, want support by binding.peer", route.AgentID, route.MatchedBy)
+	}
+	if route.SessionKey != "agent:support:discord:channel:t1" {
+		t.Errorf("SessionKey = %q", route.SessionKey)
+	}
+
+	route = r.ResolveRoute(thread("t2"))
+	if route.AgentID != "ops" || route.MatchedBy != "binding.peer.parent" {
+		t.Errorf("thread t2 routed to %q by %q, want ops by binding.peer.parent", route.AgentID, route.MatchedBy)
+	}
+	if route.SessionKey != "agent:ops:discord:channel:t2" {
+		t.Errorf("SessionKey = %q", route.SessionKey)
+	}
+
+	cfg.Session.ThreadScope = string(ThreadScopeShared)
+	route = NewRouteResolver(cfg).ResolveRoute(thread("t2"))
+	if route.SessionKey != "agent:ops:discord:channel:c1" {
+		t.Errorf("shared thread SessionKey = %q, want the parent channel's", route.SessionKey)
+	}
+}
+
 func TestResolveRoute_GuildBinding(t *testing.T) {
 	agents := []config.AgentConfig{
 		{ID: "general", Default: true},
@@ -295,3 +400,47 @@ func TestResolveRoute_NoDefaultUsesFirst(t *testing.T) {
 		t.Errorf("AgentID = %q, want 'alpha' (first in list)", route.AgentID)
 	}
 }
+
+func TestResolveRoute_Handoff(t *testing.T) {
+	agents := []config.AgentConfig{
+		{ID: "sales", Default: true},
+		{ID: "support"},
+		{ID: "coder"},
+	}
+	bindings := []config.AgentBinding{
+		{
+			AgentID: "sales",
+			Match: config.BindingMatch{
+				Channel: "telegram",
+				Peer:    &config.PeerMatch{Kind: "direct", ID: "user123"},
+			},
+		},
+		{
+			AgentID: "coder",
+			Match: config.BindingMatch{
+				Channel: "telegram",
+				Content: &config.ContentMatch{Command: "@coder"},
+			},
+		},
+	}
+	r := NewRouteResolver(testConfig(agents, bindings))
+	input := RouteInput{
+		Channel:        "telegram",
+		Peer:           &RoutePeer{Kind: "direct", ID: "user123"},
+		Content:        "hello",
+		HandoffAgentID: "support",
+	}
+
+	route := r.ResolveRoute(input)
+	if route.AgentID != "support" || route.MatchedBy != "handoff" {
+		t.Errorf("route = %s/%s, want support/handoff", route.AgentID, route.MatchedBy)
+	}
+	if !strings.Contains(route.SessionKey, "support") {
+		t.Errorf("SessionKey = %q, want the support agent's session", route.SessionKey)
+	}
+
+	input.Content = "@coder fix the build"
+	if route := r.ResolveRoute(input); route.AgentID != "coder" {
+		t.Errorf("content binding should win over a handoff, got %s", route.AgentID)
+	}
+}
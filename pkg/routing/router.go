@@ -1,6 +1,11 @@
 package routing
 
 import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -9,52 +14,205 @@ import (
 // or an attachment) before the heavy model is chosen.
 const defaultThreshold = 0.35
 
+// defaultLongContextTokens is the history+message token estimate above which
+// a request requires the long_context capability.
+const defaultLongContextTokens = 16000
+
+// Capabilities a tier can declare. Requests that need a capability are only
+// routed to tiers that declare it; the primary model is assumed to have all.
+const (
+	CapVision      = "vision"       // message carries images, audio or video
+	CapLongContext = "long_context" // history+message exceed LongContextTokens
+	CapTools       = "tools"        // dense recent tool usage (agentic workflow)
+)
+
+// Tier is one routing band below the primary model.
+type Tier struct {
+	// Name labels the tier in logs and session metadata.
+	Name string
+
+	// Model is the model_name (from model_list) used by this tier.
+	Model string
+
+	// MaxScore is the exclusive upper bound of the tier's score band.
+	MaxScore float64
+
+	// Capabilities lists the gated capabilities the tier's model supports.
+	Capabilities []string
+}
+
+func (t Tier) supports(required []string) bool {
+	for _, req := range required {
+		if !slices.Contains(t.Capabilities, req) {
+			return false
+		}
+	}
+	return true
+}
+
+// LightTier returns the single tier of the legacy two-tier setup: the light
+// model takes anything scoring below threshold (defaultThreshold if <= 0).
+// Attachments already force a score of 1.0, so only the tool and context
+// gates are opened to keep the original behavior.
+func LightTier(model string, threshold float64) Tier {
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	return Tier{
+		Name:         "light",
+		Model:        model,
+		MaxScore:     threshold,
+		Capabilities: []string{CapTools, CapLongContext},
+	}
+}
+
 // RouterConfig holds the validated model routing settings.
 // It mirrors config.RoutingConfig but lives in pkg/routing to keep the
 // dependency graph simple: pkg/agent resolves config → routing, not the reverse.
 type RouterConfig struct {
 	// LightModel is the model_name (from model_list) used for simple tasks.
+	// Ignored when Tiers is set.
 	LightModel string
 
 	// Threshold is the complexity score cutoff in [0, 1].
 	// score >= Threshold → primary (heavy) model.
 	// score <  Threshold → light model.
+	// Ignored when Tiers is set.
 	Threshold float64
+
+	// Tiers are the routing bands below the primary model, e.g. a local 3B
+	// model, then a mid-tier model. A request goes to the first tier (in
+	// ascending MaxScore order) whose band contains its score and which has
+	// every capability it requires; otherwise it stays on the primary model.
+	Tiers []Tier
+
+	// LongContextTokens overrides the long_context capability cutoff.
+	LongContextTokens int
 }
 
+// Decision describes one routing outcome.
+type Decision struct {
+	Tier       string   // tier name, or PrimaryTier
+	Model      string   // model_name to use
+	Score      float64  // complexity score in [0, 1]
+	Classifier string   // name of the classifier that produced Score
+	Required   []string // capabilities the request needed
+	Primary    bool     // true when the primary model was kept
+	Features   Features // signals the score was computed from
+}
+
+// PrimaryTier is the Decision.Tier value when no lighter tier was chosen.
+const PrimaryTier = "primary"
+
 // Router selects the appropriate model tier for each incoming message.
 // It is safe for concurrent use from multiple goroutines.
 type Router struct {
 	cfg        RouterConfig
 	classifier Classifier
+	fallback   Classifier
 }
 
 // New creates a Router with the given config and the default RuleClassifier.
 // If cfg.Threshold is zero or negative, defaultThreshold (0.35) is used.
 func New(cfg RouterConfig) *Router {
+	return NewWithClassifier(cfg, &RuleClassifier{})
+}
+
+// NewWithClassifier creates a Router with a custom Classifier. Classifiers
+// that also implement RequestClassifier are consulted with the raw message;
+// when they fail the RuleClassifier score is used instead.
+func NewWithClassifier(cfg RouterConfig, c Classifier) *Router {
 	if cfg.Threshold <= 0 {
 		cfg.Threshold = defaultThreshold
 	}
-	return &Router{
-		cfg:        cfg,
-		classifier: &RuleClassifier{},
+	if cfg.LongContextTokens <= 0 {
+		cfg.LongContextTokens = defaultLongContextTokens
+	}
+	if len(cfg.Tiers) == 0 && cfg.LightModel != "" {
+		cfg.Tiers = []Tier{LightTier(cfg.LightModel, cfg.Threshold)}
+	} else {
+		cfg.Tiers = slices.Clone(cfg.Tiers)
+		slices.SortStableFunc(cfg.Tiers, func(a, b Tier) int {
+			return cmp.Compare(a.MaxScore, b.MaxScore)
+		})
+	}
+	return &Router{cfg: cfg, classifier: c, fallback: &RuleClassifier{}}
+}
+
+// SetUsageHook passes h to the classifier when it makes LLM calls of its
+// own, such as the ModelClassifier. Call it before routing starts.
+func (r *Router) SetUsageHook(h UsageHook) {
+	if uc, ok := r.classifier.(interface{ SetUsageHook(UsageHook) }); ok {
+		uc.SetUsageHook(h)
 	}
 }
 
 // newWithClassifier creates a Router with a custom Classifier.
 // Intended for unit tests that need to inject a deterministic scorer.
 func newWithClassifier(cfg RouterConfig, c Classifier) *Router {
-	if cfg.Threshold <= 0 {
-		cfg.Threshold = defaultThreshold
+	return NewWithClassifier(cfg, c)
+}
+
+// Route scores the message and picks a tier. The context bounds classifiers
+// that call out to a model.
+func (r *Router) Route(
+	ctx context.Context,
+	msg string,
+	history []providers.Message,
+	primaryModel string,
+) Decision {
+	features := ExtractFeatures(msg, history)
+	required := RequiredCapabilities(features, r.cfg.LongContextTokens)
+
+	// Attachments are enforced by the vision gate rather than the score's
+	// hard gate, so a vision-capable tier can still take a simple image
+	// question while tiers without vision never see one.
+	scored := features
+	scored.HasAttachments = false
+	score, classifier := r.score(ctx, msg, scored)
+
+	for _, tier := range r.cfg.Tiers {
+		if score < tier.MaxScore && tier.supports(required) {
+			return Decision{
+				Tier:       tier.Name,
+				Model:      tier.Model,
+				Score:      score,
+				Classifier: classifier,
+				Required:   required,
+				Features:   features,
+			}
+		}
+	}
+	return Decision{
+		Tier:       PrimaryTier,
+		Model:      primaryModel,
+		Score:      score,
+		Classifier: classifier,
+		Required:   required,
+		Primary:    true,
+		Features:   features,
+	}
+}
+
+func (r *Router) score(ctx context.Context, msg string, f Features) (float64, string) {
+	rc, ok := r.classifier.(RequestClassifier)
+	if !ok {
+		return r.classifier.Score(f), classifierName(r.classifier)
+	}
+	score, err := rc.ClassifyRequest(ctx, msg, f)
+	if err != nil {
+		logger.WarnCF("routing", "Classifier failed, using rule classifier",
+			map[string]any{"classifier": classifierName(r.classifier), "error": err.Error()})
+		return r.fallback.Score(f), classifierName(r.fallback)
 	}
-	return &Router{cfg: cfg, classifier: c}
+	return score, classifierName(r.classifier)
 }
 
 // SelectModel returns the model to use for this conversation turn along with
 // the computed complexity score (for logging and debugging).
 //
-//   - If score < cfg.Threshold: returns (cfg.LightModel, true, score)
-//   - Otherwise:               returns (primaryModel, false, score)
+//   - If a lighter tier accepts the request: returns (tier model, true, score)
+//   - Otherwise:                             returns (primaryModel, false, score)
 //
 // The caller is responsible for resolving the returned model name into
 // provider candidates (see AgentInstance.LightCandidates).
@@ -63,20 +221,43 @@ func (r *Router) SelectModel(
 	history []providers.Message,
 	primaryModel string,
 ) (model string, usedLight bool, score float64) {
-	features := ExtractFeatures(msg, history)
-	score = r.classifier.Score(features)
-	if score < r.cfg.Threshold {
-		return r.cfg.LightModel, true, score
-	}
-	return primaryModel, false, score
+	d := r.Route(context.Background(), msg, history, primaryModel)
+	return d.Model, !d.Primary, d.Score
 }
 
-// LightModel returns the configured light model name.
+// Tiers returns the configured tiers in routing order.
+func (r *Router) Tiers() []Tier {
+	return slices.Clone(r.cfg.Tiers)
+}
+
+// LightModel returns the model of the cheapest tier.
 func (r *Router) LightModel() string {
-	return r.cfg.LightModel
+	if len(r.cfg.Tiers) == 0 {
+		return ""
+	}
+	return r.cfg.Tiers[0].Model
 }
 
-// Threshold returns the complexity threshold in use.
+// Threshold returns the score above which the primary model is used
+// regardless of capabilities, i.e. the highest tier bound.
 func (r *Router) Threshold() float64 {
-	return r.cfg.Threshold
+	if len(r.cfg.Tiers) == 0 {
+		return r.cfg.Threshold
+	}
+	return r.cfg.Tiers[len(r.cfg.Tiers)-1].MaxScore
+}
+
+// RequiredCapabilities returns the gated capabilities a request needs.
+func RequiredCapabilities(f Features, longContextTokens int) []string {
+	var required []string
+	if f.HasAttachments {
+		required = append(required, CapVision)
+	}
+	if f.TokenEstimate+f.HistoryTokens > longContextTokens {
+		required = append(required, CapLongContext)
+	}
+	if f.RecentToolCalls > 3 {
+		required = append(required, CapTools)
+	}
+	return required
 }
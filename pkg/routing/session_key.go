@@ -15,6 +15,14 @@ const (
 	DMScopePerAccountChannelPeer DMScope = "per-account-channel-peer"
 )
 
+// ThreadScope controls whether threads of a group get their own sessions.
+type ThreadScope string
+
+const (
+	ThreadScopePerThread ThreadScope = "per-thread"
+	ThreadScopeShared    ThreadScope = "shared"
+)
+
 // RoutePeer represents a chat peer with kind and ID.
 type RoutePeer struct {
 	Kind string // "direct", "group", "channel"
@@ -29,6 +37,10 @@ type SessionKeyParams struct {
 	Peer          *RoutePeer
 	DMScope       DMScope
 	IdentityLinks map[string][]string
+	// ThreadID is the thread or forum topic within a group peer. With
+	// ThreadScopePerThread (the default) it becomes part of the key.
+	ThreadID    string
+	ThreadScope ThreadScope
 }
 
 // ParsedSessionKey is the result of parsing an agent-scoped session key.
@@ -42,6 +54,11 @@ func BuildAgentMainSessionKey(agentID string) string {
 	return fmt.Sprintf("agent:%s:%s", NormalizeAgentID(agentID), DefaultMainKey)
 }
 
+// BuildSubagentSessionKey returns the session key of one subagent run.
+func BuildSubagentSessionKey(agentID, runID string) string {
+	return fmt.Sprintf("agent:%s:subagent:%s", NormalizeAgentID(agentID), strings.ToLower(strings.TrimSpace(runID)))
+}
+
 // BuildAgentPeerSessionKey constructs a session key based on agent, channel, peer, and DM scope.
 func BuildAgentPeerSessionKey(params SessionKeyParams) string {
 	agentID := NormalizeAgentID(params.AgentID)
@@ -96,9 +113,23 @@ func BuildAgentPeerSessionKey(params SessionKeyParams) string {
 	if peerID == "" {
 		peerID = "unknown"
 	}
+	if params.ThreadScope != ThreadScopeShared {
+		peerID = ThreadPeerID(peerID, strings.ToLower(params.ThreadID))
+	}
 	return fmt.Sprintf("agent:%s:%s:%s:%s", agentID, channel, peerKind, peerID)
 }
 
+// ThreadPeerID returns the ID of one thread of a group peer, "<peer>/<thread>",
+// the same shape Telegram and Slack use for threaded chat IDs. It returns
+// peerID unchanged when threadID is empty.
+func ThreadPeerID(peerID, threadID string) string {
+	threadID = strings.TrimSpace(threadID)
+	if threadID == "" {
+		return peerID
+	}
+	return peerID + "/" + threadID
+}
+
 // ParseAgentSessionKey extracts agentId and rest from "agent:<agentId>:<rest>".
 func ParseAgentSessionKey(sessionKey string) *ParsedSessionKey {
 	raw := strings.TrimSpace(sessionKey)
@@ -84,6 +84,57 @@ func TestBuildAgentPeerSessionKey_GroupPeer(t *testing.T) {
 	}
 }
 
+func TestBuildAgentPeerSessionKey_ThreadScope(t *testing.T) {
+	params := SessionKeyParams{
+		AgentID:  "main",
+		Channel:  "slack",
+		Peer:     &RoutePeer{Kind: "channel", ID: "C123"},
+		ThreadID: "1700000000.000100",
+	}
+
+	got := BuildAgentPeerSessionKey(params)
+	want := "agent:main:slack:channel:c123/1700000000.000100"
+	if got != want {
+		t.Errorf("default scope = %q, want %q", got, want)
+	}
+
+	params.ThreadScope = ThreadScopeShared
+	got = BuildAgentPeerSessionKey(params)
+	want = "agent:main:slack:channel:c123"
+	if got != want {
+		t.Errorf("shared scope = %q, want %q", got, want)
+	}
+}
+
+func TestBuildAgentPeerSessionKey_ThreadKeepsTelegramTopicKey(t *testing.T) {
+	// Forum topics used to be keyed by the composite "chat/topic" peer ID.
+	got := BuildAgentPeerSessionKey(SessionKeyParams{
+		AgentID:     "main",
+		Channel:     "telegram",
+		Peer:        &RoutePeer{Kind: "group", ID: "-1001234567890"},
+		ThreadID:    "42",
+		ThreadScope: ThreadScopePerThread,
+	})
+	want := "agent:main:telegram:group:-1001234567890/42"
+	if got != want {
+		t.Errorf("topic key = %q, want %q", got, want)
+	}
+}
+
+func TestBuildAgentPeerSessionKey_ThreadIgnoredForDirect(t *testing.T) {
+	got := BuildAgentPeerSessionKey(SessionKeyParams{
+		AgentID:  "main",
+		Channel:  "slack",
+		Peer:     &RoutePeer{Kind: "direct", ID: "U1"},
+		DMScope:  DMScopePerChannelPeer,
+		ThreadID: "1700000000.000100",
+	})
+	want := "agent:main:slack:direct:u1"
+	if got != want {
+		t.Errorf("direct with thread = %q, want %q", got, want)
+	}
+}
+
 func TestBuildAgentPeerSessionKey_NilPeer(t *testing.T) {
 	got := BuildAgentPeerSessionKey(SessionKeyParams{
 		AgentID: "main",
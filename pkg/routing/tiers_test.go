@@ -0,0 +1,164 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func threeTierConfig() RouterConfig {
+	return RouterConfig{
+		Tiers: []Tier{
+			// Deliberately out of order: the router sorts by MaxScore.
+			{Name: "mid", Model: "mid-model", MaxScore: 0.6, Capabilities: []string{CapVision, CapTools}},
+			{Name: "local", Model: "local-3b", MaxScore: 0.2},
+		},
+	}
+}
+
+func TestRouter_Tiers_ScoreBands(t *testing.T) {
+	cases := []struct {
+		score    float64
+		wantTier string
+		wantLLM  string
+	}{
+		{0.0, "local", "local-3b"},
+		{0.19, "local", "local-3b"},
+		{0.2, "mid", "mid-model"},
+		{0.59, "mid", "mid-model"},
+		{0.6, PrimaryTier, "frontier"},
+		{1.0, PrimaryTier, "frontier"},
+	}
+	for _, tc := range cases {
+		r := NewWithClassifier(threeTierConfig(), &fixedScoreClassifier{score: tc.score})
+		d := r.Route(context.Background(), "hi", nil, "frontier")
+		if d.Tier != tc.wantTier || d.Model != tc.wantLLM {
+			t.Errorf("score %.2f: got %s/%s, want %s/%s", tc.score, d.Tier, d.Model, tc.wantTier, tc.wantLLM)
+		}
+		if d.Primary != (tc.wantTier == PrimaryTier) {
+			t.Errorf("score %.2f: Primary = %v", tc.score, d.Primary)
+		}
+	}
+}
+
+func TestRouter_Tiers_CapabilityGates(t *testing.T) {
+	r := NewWithClassifier(threeTierConfig(), &fixedScoreClassifier{score: 0.1})
+
+	// Vision: local tier lacks it, mid tier has it.
+	d := r.Route(context.Background(), "what is in photo.png", nil, "frontier")
+	if d.Tier != "mid" || !slices.Contains(d.Required, CapVision) {
+		t.Errorf("vision request: got tier %q required %v, want mid with vision", d.Tier, d.Required)
+	}
+
+	// Long context: no tier declares it → primary.
+	history := []providers.Message{{Role: "user", Content: strings.Repeat("word ", 20000)}}
+	d = r.Route(context.Background(), "summarize", history, "frontier")
+	if !d.Primary || !slices.Contains(d.Required, CapLongContext) {
+		t.Errorf("long context: got tier %q required %v, want primary", d.Tier, d.Required)
+	}
+
+	// Tool-heavy: mid tier declares tools.
+	history = []providers.Message{{Role: "assistant", ToolCalls: []providers.ToolCall{
+		{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"},
+	}}}
+	d = r.Route(context.Background(), "ok", history, "frontier")
+	if d.Tier != "mid" {
+		t.Errorf("tool-heavy: got tier %q, want mid", d.Tier)
+	}
+}
+
+func TestRouter_LegacyConfigBecomesLightTier(t *testing.T) {
+	r := New(RouterConfig{LightModel: "flash", Threshold: 0.4})
+	tiers := r.Tiers()
+	if len(tiers) != 1 || tiers[0].Model != "flash" || tiers[0].MaxScore != 0.4 {
+		t.Fatalf("tiers = %+v", tiers)
+	}
+	if r.Threshold() != 0.4 || r.LightModel() != "flash" {
+		t.Errorf("Threshold/LightModel = %v/%q", r.Threshold(), r.LightModel())
+	}
+	d := r.Route(context.Background(), "hello", nil, "heavy")
+	if d.Tier != "light" || d.Classifier != "rules" {
+		t.Errorf("decision = %+v, want light tier by rules", d)
+	}
+}
+
+type failingRequestClassifier struct{ fixedScoreClassifier }
+
+func (f *failingRequestClassifier) ClassifyRequest(context.Context, string, Features) (float64, error) {
+	return 0, errors.New("timeout")
+}
+
+func TestRouter_RequestClassifierFallsBackToRules(t *testing.T) {
+	r := NewWithClassifier(
+		RouterConfig{LightModel: "light", Threshold: 0.35},
+		&failingRequestClassifier{fixedScoreClassifier{score: 0.0}},
+	)
+	d := r.Route(context.Background(), "```go\nx\n```", nil, "heavy")
+	if d.Classifier != "rules" || d.Score != 0.40 || !d.Primary {
+		t.Errorf("decision = %+v, want rule score 0.40 on primary", d)
+	}
+}
+
+// gradingProvider answers grading requests with a fixed reply or delay.
+type gradingProvider struct {
+	reply string
+	delay time.Duration
+}
+
+func (p *gradingProvider) Chat(
+	ctx context.Context, _ []providers.Message, _ []providers.ToolDefinition, _ string, _ map[string]any,
+) (*providers.LLMResponse, error) {
+	select {
+	case <-time.After(p.delay):
+		return &providers.LLMResponse{Content: p.reply}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *gradingProvider) GetDefaultModel() string { return "grader" }
+
+func TestModelClassifier_GradesRequest(t *testing.T) {
+	c := NewModelClassifier(&gradingProvider{reply: "7"}, "grader", time.Second)
+	r := NewWithClassifier(threeTierConfig(), c)
+
+	d := r.Route(context.Background(), "hi", nil, "frontier")
+	if d.Classifier != "model" || d.Score != 0.7 || !d.Primary {
+		t.Errorf("decision = %+v, want model score 0.7 on primary", d)
+	}
+}
+
+func TestModelClassifier_TimeoutFallsBackToRules(t *testing.T) {
+	c := NewModelClassifier(&gradingProvider{reply: "9", delay: time.Second}, "grader", 20*time.Millisecond)
+	r := NewWithClassifier(threeTierConfig(), c)
+
+	d := r.Route(context.Background(), "hi", nil, "frontier")
+	if d.Classifier != "rules" || d.Tier != "local" {
+		t.Errorf("decision = %+v, want rules fallback to local tier", d)
+	}
+}
+
+func TestParseGrade(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"3", 0.3, false},
+		{" 10\n", 1.0, false},
+		{"Grade: 5/10", 0.5, false},
+		{"none", 0, true},
+		{"42", 0, true},
+	}
+	for _, tc := range cases {
+		got, err := parseGrade(tc.in)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("parseGrade(%q) = %v, %v", tc.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package session
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// DefaultBranch is the branch every session starts on.
+const DefaultBranch = "main"
+
+var branchNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,32}$`)
+
+// Branch is a named alternative history of a session. The checked-out
+// branch lives in the SessionStore; the others are kept here.
+type Branch struct {
+	Created  time.Time           `json:"created"`
+	Updated  time.Time           `json:"updated"`
+	Summary  string              `json:"summary,omitempty"`
+	Messages []providers.Message `json:"messages,omitempty"`
+}
+
+// BranchInfo describes one branch for listings.
+type BranchInfo struct {
+	Name     string
+	Messages int
+	Updated  time.Time
+	Current  bool
+}
+
+type branchFile struct {
+	Current  string             `json:"current"`
+	Branches map[string]*Branch `json:"branches"`
+}
+
+// BranchStore keeps named branches of sessions in
+// {sanitized_key}.branches.json next to the session files in dir.
+type BranchStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewBranchStore(dir string) *BranchStore {
+	return &BranchStore{dir: dir}
+}
+
+func (b *BranchStore) path(key string) string {
+	return filepath.Join(b.dir, sanitizeFilename(key)+".branches.json")
+}
+
+func (b *BranchStore) load(key string) (*branchFile, error) {
+	f := &branchFile{Current: DefaultBranch, Branches: map[string]*Branch{}}
+	data, err := os.ReadFile(b.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read branches: %w", err)
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("decode branches: %w", err)
+	}
+	if f.Branches == nil {
+		f.Branches = map[string]*Branch{}
+	}
+	return f, nil
+}
+
+func (b *BranchStore) save(key string, f *branchFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode branches: %w", err)
+	}
+	return fileutil.WriteFileAtomic(b.path(key), data, 0o644)
+}
+
+// stash copies the session's live history into the current branch entry.
+func stash(f *branchFile, store SessionStore, key string, now time.Time) {
+	cur := f.Branches[f.Current]
+	if cur == nil {
+		cur = &Branch{Created: now}
+		f.Branches[f.Current] = cur
+	}
+	cur.Messages = store.GetHistory(key)
+	cur.Summary = store.GetSummary(key)
+	cur.Updated = now
+}
+
+// List returns the session's branches sorted by name. A session without
+// branches has only DefaultBranch.
+func (b *BranchStore) List(store SessionStore, key string) ([]BranchInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, err := b.load(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := f.Branches[f.Current]; !ok {
+		f.Branches[f.Current] = &Branch{}
+	}
+
+	infos := make([]BranchInfo, 0, len(f.Branches))
+	for name, br := range f.Branches {
+		info := BranchInfo{Name: name, Messages: len(br.Messages), Updated: br.Updated}
+		if name == f.Current {
+			info.Current = true
+			info.Messages = len(store.GetHistory(key))
+		}
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b BranchInfo) int { return cmp.Compare(a.Name, b.Name) })
+	return infos, nil
+}
+
+// Create starts a new branch from the session's current history and checks
+// it out. The history itself is unchanged.
+func (b *BranchStore) Create(store SessionStore, key, name string) error {
+	if !branchNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid branch name %q: use up to 32 letters, digits, '.', '_' or '-'", name)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, err := b.load(key)
+	if err != nil {
+		return err
+	}
+	if _, exists := f.Branches[name]; exists || name == f.Current {
+		return fmt.Errorf("branch %q already exists", name)
+	}
+
+	now := time.Now()
+	stash(f, store, key, now)
+	f.Branches[name] = &Branch{Created: now, Updated: now}
+	f.Current = name
+	return b.save(key, f)
+}
+
+// Checkout stores the session's history on the current branch and replaces
+// it with the history of branch name.
+func (b *BranchStore) Checkout(store SessionStore, key, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, err := b.load(key)
+	if err != nil {
+		return err
+	}
+	if name == f.Current {
+		return fmt.Errorf("already on branch %q", name)
+	}
+	target, ok := f.Branches[name]
+	if !ok {
+		return fmt.Errorf("branch %q not found", name)
+	}
+
+	stash(f, store, key, time.Now())
+	f.Current = name
+	if err := b.save(key, f); err != nil {
+		return err
+	}
+
+	history := target.Messages
+	if history == nil {
+		history = []providers.Message{}
+	}
+	store.SetHistory(key, history)
+	store.SetSummary(key, target.Summary)
+	return store.Save(key)
+}
@@ -0,0 +1,44 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBranchStore_CreateAndCheckout(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	branches := NewBranchStore(dir)
+	key := "agent:main:telegram:direct:1"
+
+	sm.AddMessage(key, "user", "hi")
+	sm.SetSummary(key, "main summary")
+	if err := branches.Create(sm, key, "../escape"); err == nil {
+		t.Error("branch names must not contain path separators")
+	}
+	if err := branches.Create(sm, key, "alt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "agent_main_telegram_direct_1.branches.json")); err != nil {
+		t.Fatalf("branch file not written next to the session: %v", err)
+	}
+
+	sm.AddMessage(key, "user", "alt only")
+	sm.SetSummary(key, "")
+	if err := branches.Checkout(sm, key, DefaultBranch); err != nil {
+		t.Fatal(err)
+	}
+	if h := sm.GetHistory(key); len(h) != 1 || sm.GetSummary(key) != "main summary" {
+		t.Fatalf("main branch = %+v, summary %q", h, sm.GetSummary(key))
+	}
+	if err := branches.Checkout(sm, key, DefaultBranch); err == nil {
+		t.Error("checking out the current branch should fail")
+	}
+	if err := branches.Checkout(sm, key, "alt"); err != nil {
+		t.Fatal(err)
+	}
+	if h := sm.GetHistory(key); len(h) != 2 || h[1].Content != "alt only" {
+		t.Fatalf("alt branch = %+v", h)
+	}
+}
@@ -68,6 +68,58 @@ func (b *JSONLBackend) TruncateHistory(key string, keepLast int) {
 	}
 }
 
+// RecordCacheUsage implements CacheStatsRecorder when the underlying store
+// supports it; otherwise it is a no-op.
+func (b *JSONLBackend) RecordCacheUsage(key string, usage *providers.UsageInfo) {
+	cs, ok := b.store.(memory.CacheStatsStore)
+	if !ok {
+		return
+	}
+	if err := cs.RecordCacheUsage(context.Background(), key, usage); err != nil {
+		log.Printf("session: record cache usage: %v", err)
+	}
+}
+
+// GetCacheStats implements CacheStatsRecorder.
+func (b *JSONLBackend) GetCacheStats(key string) CacheStats {
+	cs, ok := b.store.(memory.CacheStatsStore)
+	if !ok {
+		return CacheStats{}
+	}
+	stats, err := cs.GetCacheStats(context.Background(), key)
+	if err != nil {
+		log.Printf("session: get cache stats: %v", err)
+		return CacheStats{}
+	}
+	return stats
+}
+
+// RecordRouting implements RoutingRecorder when the underlying store
+// supports it; otherwise it is a no-op.
+func (b *JSONLBackend) RecordRouting(key string, d RoutingDecision) {
+	rs, ok := b.store.(memory.RoutingStatsStore)
+	if !ok {
+		return
+	}
+	if err := rs.RecordRouting(context.Background(), key, d); err != nil {
+		log.Printf("session: record routing: %v", err)
+	}
+}
+
+// GetRoutingStats implements RoutingRecorder.
+func (b *JSONLBackend) GetRoutingStats(key string) RoutingStats {
+	rs, ok := b.store.(memory.RoutingStatsStore)
+	if !ok {
+		return RoutingStats{}
+	}
+	stats, err := rs.GetRoutingStats(context.Background(), key)
+	if err != nil {
+		log.Printf("session: get routing stats: %v", err)
+		return RoutingStats{}
+	}
+	return stats
+}
+
 // Save persists session state. Since the JSONL store fsyncs every write
 // immediately, the data is already durable. Save runs compaction to reclaim
 // space from logically truncated messages (no-op when there are none).
@@ -13,6 +13,9 @@ import (
 var (
 	_ session.SessionStore = (*session.SessionManager)(nil)
 	_ session.SessionStore = (*session.JSONLBackend)(nil)
+
+	_ session.CacheStatsRecorder = (*session.SessionManager)(nil)
+	_ session.CacheStatsRecorder = (*session.JSONLBackend)(nil)
 )
 
 func newBackend(t *testing.T) *session.JSONLBackend {
@@ -177,3 +180,35 @@ func TestJSONLBackend_SummarizeFlow(t *testing.T) {
 		t.Errorf("first message = %q, want %q", history[0].Content, "msg 16")
 	}
 }
+
+func TestJSONLBackend_CacheStats(t *testing.T) {
+	b := newBackend(t)
+
+	b.AddMessage("s1", "user", "hi")
+	b.RecordCacheUsage("s1", &providers.UsageInfo{PromptTokens: 500, CacheWriteTokens: 400})
+	b.RecordCacheUsage("s1", &providers.UsageInfo{PromptTokens: 500, CacheReadTokens: 400})
+
+	stats := b.GetCacheStats("s1")
+	if stats.Requests != 2 || stats.CacheReadTokens != 400 || stats.CacheWriteTokens != 400 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if got := b.GetCacheStats("other"); got.Requests != 0 {
+		t.Errorf("unknown session stats = %+v, want zero", got)
+	}
+}
+
+func TestJSONLBackend_RoutingStats(t *testing.T) {
+	b := newBackend(t)
+
+	b.AddMessage("s1", "user", "hi")
+	b.RecordRouting("s1", session.RoutingDecision{Tier: "light", Model: "flash", Score: 0.1})
+	b.RecordRouting("s1", session.RoutingDecision{Tier: "primary", Model: "pro", Score: 0.9})
+
+	stats := b.GetRoutingStats("s1")
+	if stats.Tiers["light"] != 1 || stats.Tiers["primary"] != 1 || len(stats.Recent) != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if got := b.GetRoutingStats("other"); len(got.Recent) != 0 {
+		t.Errorf("unknown session stats = %+v, want zero", got)
+	}
+}
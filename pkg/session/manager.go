@@ -15,6 +15,8 @@ type Session struct {
 	Key      string              `json:"key"`
 	Messages []providers.Message `json:"messages"`
 	Summary  string              `json:"summary,omitempty"`
+	Cache    *CacheStats         `json:"cache,omitempty"`
+	Routing  *RoutingStats       `json:"routing,omitempty"`
 	Created  time.Time           `json:"created"`
 	Updated  time.Time           `json:"updated"`
 }
@@ -122,6 +124,77 @@ func (sm *SessionManager) SetSummary(key string, summary string) {
 	}
 }
 
+// RecordCacheUsage implements CacheStatsRecorder. Totals are persisted on
+// the next Save.
+func (sm *SessionManager) RecordCacheUsage(key string, usage *providers.UsageInfo) {
+	if usage == nil {
+		return
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return
+	}
+	if session.Cache == nil {
+		session.Cache = &CacheStats{}
+	}
+	session.Cache.Add(usage)
+}
+
+// GetCacheStats implements CacheStatsRecorder.
+func (sm *SessionManager) GetCacheStats(key string) CacheStats {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok || session.Cache == nil {
+		return CacheStats{}
+	}
+	return *session.Cache
+}
+
+// RecordRouting implements RoutingRecorder. Decisions are persisted on the
+// next Save.
+func (sm *SessionManager) RecordRouting(key string, d RoutingDecision) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return
+	}
+	if session.Routing == nil {
+		session.Routing = &RoutingStats{}
+	}
+	session.Routing.Add(d)
+}
+
+// GetRoutingStats implements RoutingRecorder.
+func (sm *SessionManager) GetRoutingStats(key string) RoutingStats {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok || session.Routing == nil {
+		return RoutingStats{}
+	}
+	return cloneRoutingStats(*session.Routing)
+}
+
+func cloneRoutingStats(r RoutingStats) RoutingStats {
+	out := RoutingStats{
+		Tiers:  make(map[string]int, len(r.Tiers)),
+		Recent: append([]RoutingDecision(nil), r.Recent...),
+	}
+	for tier, n := range r.Tiers {
+		out.Tiers[tier] = n
+	}
+	return out
+}
+
 func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -185,6 +258,14 @@ func (sm *SessionManager) Save(key string) error {
 		Created: stored.Created,
 		Updated: stored.Updated,
 	}
+	if stored.Cache != nil {
+		cache := *stored.Cache
+		snapshot.Cache = &cache
+	}
+	if stored.Routing != nil {
+		routing := cloneRoutingStats(*stored.Routing)
+		snapshot.Routing = &routing
+	}
 	if len(stored.Messages) > 0 {
 		snapshot.Messages = make([]providers.Message, len(stored.Messages))
 		copy(snapshot.Messages, stored.Messages)
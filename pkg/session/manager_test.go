@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 func TestSanitizeFilename(t *testing.T) {
@@ -83,3 +85,42 @@ func TestSave_RejectsPathTraversal(t *testing.T) {
 		t.Errorf("expected foo_bar.json in storage (sanitized from foo/bar)")
 	}
 }
+
+func TestCacheStats_PersistedOnSave(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+
+	key := "telegram:42"
+	sm.GetOrCreate(key)
+	sm.RecordCacheUsage(key, &providers.UsageInfo{PromptTokens: 100, CacheReadTokens: 75})
+	if err := sm.Save(key); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded := NewSessionManager(tmpDir)
+	stats := reloaded.GetCacheStats(key)
+	if stats.Requests != 1 || stats.CacheReadTokens != 75 {
+		t.Errorf("stats = %+v, want 1 request with 75 cache-read tokens", stats)
+	}
+	if got := stats.HitRate(); got != 0.75 {
+		t.Errorf("HitRate() = %v, want 0.75", got)
+	}
+}
+
+func TestRoutingStats_PersistedOnSave(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+
+	key := "telegram:42"
+	sm.GetOrCreate(key)
+	sm.RecordRouting(key, RoutingDecision{Tier: "light", Model: "flash", Score: 0.2, Classifier: "rules"})
+	if err := sm.Save(key); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded := NewSessionManager(tmpDir)
+	stats := reloaded.GetRoutingStats(key)
+	if stats.Tiers["light"] != 1 || len(stats.Recent) != 1 || stats.Recent[0].Model != "flash" {
+		t.Errorf("stats = %+v, want one light decision", stats)
+	}
+}
@@ -1,6 +1,9 @@
 package session
 
-import "github.com/sipeed/picoclaw/pkg/providers"
+import (
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
 
 // SessionStore defines the persistence operations used by the agent loop.
 // Both SessionManager (legacy JSON backend) and JSONLBackend satisfy this
@@ -30,3 +33,31 @@ type SessionStore interface {
 	// Close releases resources held by the store.
 	Close() error
 }
+
+// CacheStats holds per-session prompt-cache totals.
+type CacheStats = memory.CacheStats
+
+// CacheStatsRecorder is optionally implemented by a SessionStore to track
+// prompt-cache hits per session. Like the write methods of SessionStore,
+// RecordCacheUsage is fire-and-forget.
+type CacheStatsRecorder interface {
+	// RecordCacheUsage adds the usage of one LLM response to the session.
+	RecordCacheUsage(key string, usage *providers.UsageInfo)
+	// GetCacheStats returns the accumulated totals for the session.
+	GetCacheStats(key string) CacheStats
+}
+
+// RoutingDecision is one model-routing outcome for a conversation turn.
+type RoutingDecision = memory.RoutingDecision
+
+// RoutingStats holds per-session routing decisions.
+type RoutingStats = memory.RoutingStats
+
+// RoutingRecorder is optionally implemented by a SessionStore to keep the
+// model tier and score chosen for each turn. RecordRouting is fire-and-forget.
+type RoutingRecorder interface {
+	// RecordRouting adds one routing decision to the session.
+	RecordRouting(key string, d RoutingDecision)
+	// GetRoutingStats returns the session's routing stats.
+	GetRoutingStats(key string) RoutingStats
+}
@@ -148,30 +148,45 @@ func (sl *SkillsLoader) ListSkills() []SkillInfo {
 }
 
 func (sl *SkillsLoader) LoadSkill(name string) (string, bool) {
-	// 1. load from workspace skills first (project-level)
-	if sl.workspaceSkills != "" {
-		skillFile := filepath.Join(sl.workspaceSkills, name, "SKILL.md")
-		if content, err := os.ReadFile(skillFile); err == nil {
-			return sl.stripFrontmatter(string(content)), true
-		}
+	content, ok := sl.readSkill(name)
+	if !ok {
+		return "", false
 	}
+	return sl.stripFrontmatter(content), true
+}
 
-	// 2. then load from global skills (~/.picoclaw/skills)
-	if sl.globalSkills != "" {
-		skillFile := filepath.Join(sl.globalSkills, name, "SKILL.md")
-		if content, err := os.ReadFile(skillFile); err == nil {
-			return sl.stripFrontmatter(string(content)), true
-		}
+// LoadSkillOutputSchema returns the JSON Schema a skill declares for its
+// result in the output_schema frontmatter field, or nil when it declares none.
+func (sl *SkillsLoader) LoadSkillOutputSchema(name string) (map[string]any, error) {
+	content, ok := sl.readSkill(name)
+	if !ok {
+		return nil, fmt.Errorf("skill %q not found", name)
+	}
+	frontmatter := sl.extractFrontmatter(content)
+	if frontmatter == "" {
+		return nil, nil
+	}
+	var meta struct {
+		OutputSchema map[string]any `yaml:"output_schema"`
 	}
+	// JSON frontmatter is valid YAML, so one decoder covers both forms.
+	if err := yaml.Unmarshal([]byte(frontmatter), &meta); err != nil {
+		return nil, fmt.Errorf("skill %q: invalid frontmatter: %w", name, err)
+	}
+	return meta.OutputSchema, nil
+}
 
-	// 3. finally load from builtin skills
-	if sl.builtinSkills != "" {
-		skillFile := filepath.Join(sl.builtinSkills, name, "SKILL.md")
-		if content, err := os.ReadFile(skillFile); err == nil {
-			return sl.stripFrontmatter(string(content)), true
+// readSkill reads a skill's SKILL.md, preferring workspace skills, then
+// global skills (~/.picoclaw/skills), then builtin skills.
+func (sl *SkillsLoader) readSkill(name string) (string, bool) {
+	for _, dir := range []string{sl.workspaceSkills, sl.globalSkills, sl.builtinSkills} {
+		if dir == "" {
+			continue
+		}
+		if content, err := os.ReadFile(filepath.Join(dir, name, "SKILL.md")); err == nil {
+			return string(content), true
 		}
 	}
-
 	return "", false
 }
 
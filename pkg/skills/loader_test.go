@@ -417,3 +417,27 @@ func TestGetSkillMetadata_IgnoresHTMLCommentBlocks(t *testing.T) {
 	assert.Equal(t, "biomed-skill", meta.Name)
 	assert.Equal(t, "Summarize biomedical papers.", meta.Description)
 }
+
+func TestLoadSkillOutputSchema(t *testing.T) {
+	tmp := t.TempDir()
+	ws := filepath.Join(tmp, "workspace")
+	skillDir := filepath.Join(ws, "skills", "triage")
+	require.NoError(t, os.MkdirAll(skillDir, 0o755))
+	content := "---\nname: triage\ndescription: Triage an issue\noutput_schema:\n  type: object\n" +
+		"  properties:\n    severity:\n      type: string\n  required: [severity]\n---\n\n# Triage\n"
+	require.NoError(t, os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte(content), 0o644))
+	createSkillDir(t, filepath.Join(ws, "skills"), "plain", "plain", "No schema")
+
+	sl := NewSkillsLoader(ws, "", "")
+	schema, err := sl.LoadSkillOutputSchema("triage")
+	require.NoError(t, err)
+	assert.Equal(t, "object", schema["type"])
+	assert.Equal(t, []any{"severity"}, schema["required"])
+
+	schema, err = sl.LoadSkillOutputSchema("plain")
+	require.NoError(t, err)
+	assert.Nil(t, schema)
+
+	_, err = sl.LoadSkillOutputSchema("missing")
+	assert.Error(t, err)
+}
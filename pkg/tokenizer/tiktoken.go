@@ -0,0 +1,58 @@
+//go:build tiktoken
+
+package tokenizer
+
+import (
+	"sync"
+
+	tiktoken "github.com/tiktoken-go/tokenizer"
+)
+
+// BPE counts tokens exactly with one of OpenAI's BPE vocabularies. The
+// vocabulary is loaded on first use; until then, and if encoding fails, the
+// family's estimator is used.
+type BPE struct {
+	name     string
+	encoding tiktoken.Encoding
+	fallback Tokenizer
+
+	once  sync.Once
+	codec tiktoken.Codec
+}
+
+// NewBPE returns an exact tokenizer for encoding that falls back to fallback.
+func NewBPE(name string, encoding tiktoken.Encoding, fallback Tokenizer) *BPE {
+	return &BPE{name: name, encoding: encoding, fallback: fallback}
+}
+
+func (b *BPE) Name() string {
+	return b.name
+}
+
+func (b *BPE) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	b.once.Do(func() {
+		b.codec, _ = tiktoken.Get(b.encoding)
+	})
+	if b.codec != nil {
+		if n, err := b.codec.Count(text); err == nil {
+			return n
+		}
+	}
+	return b.fallback.Count(text)
+}
+
+// Builds with -tags tiktoken count OpenAI models exactly; other families
+// keep their estimators.
+func init() {
+	o200k := NewBPE("o200k", tiktoken.O200kBase, ForModel("gpt-4o"))
+	cl100k := NewBPE("cl100k", tiktoken.Cl100kBase, ForModel("gpt-4"))
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-5", "o1", "o3", "o4"} {
+		Register(prefix, o200k)
+	}
+	for _, prefix := range []string{"gpt-4", "gpt-3.5"} {
+		Register(prefix, cl100k)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build tiktoken
+
+package tokenizer
+
+import "testing"
+
+func TestForModel_ExactBPE(t *testing.T) {
+	tests := []struct {
+		model string
+		name  string
+		text  string
+		want  int
+	}{
+		{"openai/gpt-4o", "o200k", "hello world", 2},
+		{"gpt-5-mini", "o200k", "Hello, world!", 4},
+		{"gpt-4-turbo", "cl100k", "hello world", 2},
+		{"gpt-3.5-turbo", "cl100k", "tiktoken is great!", 6},
+	}
+	for _, tt := range tests {
+		tok := ForModel(tt.model)
+		if _, ok := tok.(*BPE); !ok || tok.Name() != tt.name {
+			t.Fatalf("ForModel(%q) = %T %s, want the exact %s tokenizer", tt.model, tok, tok.Name(), tt.name)
+		}
+		if got := tok.Count(tt.text); got != tt.want {
+			t.Errorf("%s Count(%q) = %d, want %d", tt.name, tt.text, got, tt.want)
+		}
+	}
+	if _, ok := ForModel("claude-sonnet-4").(*BPE); ok {
+		t.Error("non-OpenAI families keep their estimators")
+	}
+}
@@ -0,0 +1,231 @@
+// Package tokenizer counts prompt tokens for context window management.
+//
+// Builds with -tags tiktoken count OpenAI models (o200k and cl100k families)
+// exactly with their BPE vocabularies; the tag is optional because the
+// vocabularies add about 11 MB to the binary. Every other family, and
+// default builds, use an estimator tuned to the family's tokenizer (Latin
+// text per token, cost of CJK runes). A Counter corrects either with the
+// prompt token counts providers report, so counts converge on the model's
+// real tokenizer during a session. Register plugs in further tokenizers.
+package tokenizer
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Tokenizer counts the tokens of a piece of text.
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// Names accepted by the tokenizer setting.
+const (
+	Auto      = "auto"      // model-specific tokenizer (default)
+	Heuristic = "heuristic" // model-independent 2.5 chars per token
+)
+
+// messageOverhead is the per-message framing (role, separators) most chat
+// formats add on top of the content.
+const messageOverhead = 4
+
+// Estimator approximates a BPE tokenizer from character classes.
+type Estimator struct {
+	name         string
+	charsPerTok  float64 // non-CJK characters per token
+	tokensPerCJK float64 // tokens per CJK rune
+}
+
+// NewEstimator returns an estimator for a tokenizer that encodes charsPerTok
+// Latin characters and tokensPerCJK tokens per CJK rune on average.
+func NewEstimator(name string, charsPerTok, tokensPerCJK float64) *Estimator {
+	return &Estimator{name: name, charsPerTok: charsPerTok, tokensPerCJK: tokensPerCJK}
+}
+
+func (e *Estimator) Name() string {
+	return e.name
+}
+
+func (e *Estimator) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	total, cjk := utf8.RuneCountInString(text), 0
+	for _, r := range text {
+		if isCJK(r) {
+			cjk++
+		}
+	}
+	n := float64(total-cjk)/e.charsPerTok + float64(cjk)*e.tokensPerCJK
+	return int(n + 0.5)
+}
+
+func isCJK(r rune) bool {
+	return r >= 0x2E80 && r <= 0x9FFF || r >= 0xF900 && r <= 0xFAFF || r >= 0xAC00 && r <= 0xD7AF
+}
+
+// legacy is the model-independent estimate used before per-model counting:
+// 2.5 characters per token for every rune.
+var legacy = NewEstimator(Heuristic, 2.5, 0.4)
+
+var (
+	mu       sync.RWMutex
+	families = map[string]Tokenizer{
+		"gpt-4o":   NewEstimator("o200k", 4.0, 0.9),
+		"gpt-4.1":  NewEstimator("o200k", 4.0, 0.9),
+		"gpt-5":    NewEstimator("o200k", 4.0, 0.9),
+		"o1":       NewEstimator("o200k", 4.0, 0.9),
+		"o3":       NewEstimator("o200k", 4.0, 0.9),
+		"o4":       NewEstimator("o200k", 4.0, 0.9),
+		"gpt-4":    NewEstimator("cl100k", 3.7, 1.2),
+		"gpt-3.5":  NewEstimator("cl100k", 3.7, 1.2),
+		"claude":   NewEstimator("claude", 3.5, 1.3),
+		"gemini":   NewEstimator("gemini", 4.0, 0.8),
+		"qwen":     NewEstimator("qwen", 3.8, 0.7),
+		"deepseek": NewEstimator("deepseek", 3.8, 0.7),
+		"glm":      NewEstimator("glm", 3.8, 0.7),
+		"kimi":     NewEstimator("kimi", 3.8, 0.7),
+		"moonshot": NewEstimator("kimi", 3.8, 0.7),
+		"llama":    NewEstimator("llama3", 3.8, 1.1),
+		"mistral":  NewEstimator("mistral", 3.5, 1.2),
+	}
+)
+
+// Register sets the tokenizer for model IDs starting with prefix (without
+// the protocol, e.g. "gpt-4o"). The longest matching prefix wins.
+func Register(prefix string, t Tokenizer) {
+	mu.Lock()
+	defer mu.Unlock()
+	families[strings.ToLower(prefix)] = t
+}
+
+// ForModel returns the tokenizer for a model such as "openai/gpt-4o" or
+// "claude-sonnet-4". Unknown models get the conservative legacy estimate.
+func ForModel(model string) Tokenizer {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	prefixes := make([]string, 0, len(families))
+	for p := range families {
+		if strings.HasPrefix(model, p) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	if len(prefixes) == 0 {
+		return legacy
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	return families[prefixes[0]]
+}
+
+// New returns the tokenizer for a tokenizer setting and model: Heuristic
+// selects the legacy estimate, anything else the model's tokenizer.
+func New(setting, model string) Tokenizer {
+	if strings.EqualFold(strings.TrimSpace(setting), Heuristic) {
+		return legacy
+	}
+	return ForModel(model)
+}
+
+// CountMessages counts the tokens of a message list including tool calls and
+// per-message framing.
+func CountMessages(t Tokenizer, messages []providers.Message) int {
+	total := 0
+	for _, m := range messages {
+		total += messageOverhead + t.Count(m.Content) + t.Count(m.ReasoningContent)
+		for _, tc := range m.ToolCalls {
+			if tc.Function != nil {
+				total += t.Count(tc.Function.Name) + t.Count(tc.Function.Arguments)
+			} else {
+				total += t.Count(tc.Name)
+				if args, err := json.Marshal(tc.Arguments); err == nil {
+					total += t.Count(string(args))
+				}
+			}
+		}
+	}
+	return total
+}
+
+// CountTools counts the tokens of the tool definitions sent with a request.
+func CountTools(t Tokenizer, defs []providers.ToolDefinition) int {
+	if len(defs) == 0 {
+		return 0
+	}
+	data, err := json.Marshal(defs)
+	if err != nil {
+		return 0
+	}
+	return t.Count(string(data))
+}
+
+// Calibration bounds: a single odd response cannot skew counts by more than
+// this, and each observation moves the correction by calibrationWeight.
+const (
+	minCorrection     = 0.5
+	maxCorrection     = 2.0
+	calibrationWeight = 0.3
+)
+
+// Counter is a Tokenizer whose counts are corrected by the prompt token
+// counts the provider reported for earlier requests. It is safe for
+// concurrent use.
+type Counter struct {
+	base       Tokenizer
+	mu         sync.Mutex
+	correction float64
+}
+
+// NewCounter wraps base with calibration.
+func NewCounter(base Tokenizer) *Counter {
+	return &Counter{base: base, correction: 1}
+}
+
+func (c *Counter) Name() string {
+	return c.base.Name()
+}
+
+func (c *Counter) Count(text string) int {
+	return c.scale(c.base.Count(text))
+}
+
+// CountMessages counts a message list like the package-level CountMessages,
+// with calibration applied.
+func (c *Counter) CountMessages(messages []providers.Message) int {
+	return c.scale(CountMessages(c.base, messages))
+}
+
+// Correction returns the current calibration factor.
+func (c *Counter) Correction() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.correction
+}
+
+// Observe calibrates the counter with the prompt tokens the provider
+// reported for a request made of messages and tool definitions.
+func (c *Counter) Observe(messages []providers.Message, defs []providers.ToolDefinition, promptTokens int) {
+	estimated := CountMessages(c.base, messages) + CountTools(c.base, defs)
+	if promptTokens <= 0 || estimated <= 0 {
+		return
+	}
+	ratio := min(max(float64(promptTokens)/float64(estimated), minCorrection), maxCorrection)
+	c.mu.Lock()
+	c.correction += (ratio - c.correction) * calibrationWeight
+	c.mu.Unlock()
+}
+
+func (c *Counter) scale(n int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(float64(n)*c.correction + 0.5)
+}
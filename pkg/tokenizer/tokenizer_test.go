@@ -0,0 +1,68 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"openai/gpt-4o-mini", "o200k"},
+		{"gpt-4-turbo", "cl100k"},
+		{"gpt-4.1", "o200k"}, // longest prefix wins over gpt-4
+		{"anthropic/claude-sonnet-4.6", "claude"},
+		{"openrouter/qwen/qwen3-coder", "qwen"},
+		{"ollama/llama3.2:3b", "llama3"},
+		{"some-local-model", Heuristic},
+	}
+	for _, tt := range tests {
+		if got := ForModel(tt.model).Name(); got != tt.want {
+			t.Errorf("ForModel(%q) = %s, want %s", tt.model, got, tt.want)
+		}
+	}
+	if got := New(Heuristic, "gpt-4o").Name(); got != Heuristic {
+		t.Errorf("heuristic setting should ignore the model, got %s", got)
+	}
+}
+
+func TestEstimator_CJKAndLatin(t *testing.T) {
+	e := NewEstimator("test", 4, 1)
+	if got := e.Count(strings.Repeat("a", 40)); got != 10 {
+		t.Errorf("latin count = %d, want 10", got)
+	}
+	if got := e.Count("你好世界"); got != 4 {
+		t.Errorf("CJK count = %d, want 4", got)
+	}
+	if got := e.Count(""); got != 0 {
+		t.Errorf("empty count = %d", got)
+	}
+}
+
+func TestCounter_Calibrates(t *testing.T) {
+	c := NewCounter(NewEstimator("test", 4, 1))
+	msgs := []providers.Message{{Role: "user", Content: strings.Repeat("word ", 80)}}
+	before := c.CountMessages(msgs) // 100 + framing
+
+	// The provider consistently reports twice the estimate.
+	for range 20 {
+		c.Observe(msgs, nil, 2*before)
+	}
+	after := c.CountMessages(msgs)
+	if after < before*19/10 || after > before*2 {
+		t.Errorf("calibrated count = %d, want close to %d", after, 2*before)
+	}
+
+	// A wildly off report is clamped.
+	c = NewCounter(NewEstimator("test", 4, 1))
+	c.Observe(msgs, nil, 100*before)
+	if got := c.Correction(); got > 1+(maxCorrection-1)*calibrationWeight+1e-9 {
+		t.Errorf("correction = %v, not clamped", got)
+	}
+	c.Observe(msgs, nil, 0)
+	c.Observe(nil, nil, 50)
+}
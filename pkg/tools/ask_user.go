@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+)
+
+const (
+	askUserMinOptions = 2
+	askUserMaxOptions = 10
+)
+
+// AskUserTool asks the user a question with a fixed set of options and
+// blocks until they answer, either by pressing a button on channels that
+// support them or by replying to the numbered menu shown elsewhere.
+type AskUserTool struct {
+	msgBus  *bus.MessageBus
+	timeout time.Duration
+}
+
+// NewAskUserTool creates an AskUserTool. timeout is how long to wait for an
+// answer, and the most a call's timeout_seconds may ask for; 0 waits until
+// the turn ends.
+func NewAskUserTool(msgBus *bus.MessageBus, timeout time.Duration) *AskUserTool {
+	return &AskUserTool{msgBus: msgBus, timeout: timeout}
+}
+
+func (t *AskUserTool) Name() string {
+	return "ask_user"
+}
+
+func (t *AskUserTool) Description() string {
+	return "Ask the user a question with a few options and wait for their answer. " +
+		"Options are shown as buttons where the chat supports them, otherwise as a numbered list. " +
+		"Use this when you need a decision or confirmation before continuing."
+}
+
+func (t *AskUserTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"question": map[string]any{
+				"type":        "string",
+				"description": "The question to ask",
+			},
+			"options": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"minItems":    askUserMinOptions,
+				"maxItems":    askUserMaxOptions,
+				"description": "Short labels for the possible answers",
+			},
+			"timeout_seconds": map[string]any{
+				"type":        "integer",
+				"description": t.timeoutDescription(),
+			},
+		},
+		"required": []string{"question", "options"},
+	}
+}
+
+func (t *AskUserTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	question, _ := args["question"].(string)
+	if strings.TrimSpace(question) == "" {
+		return ErrorResult("question is required")
+	}
+
+	rawOptions, _ := args["options"].([]any)
+	options := make([]string, 0, len(rawOptions))
+	for _, raw := range rawOptions {
+		if s, ok := raw.(string); ok && strings.TrimSpace(s) != "" {
+			options = append(options, strings.TrimSpace(s))
+		}
+	}
+	if len(options) < askUserMinOptions || len(options) > askUserMaxOptions {
+		return ErrorResult(fmt.Sprintf("options must contain %d to %d labels", askUserMinOptions, askUserMaxOptions))
+	}
+
+	timeout := t.timeout
+	if secs, ok := args["timeout_seconds"].(float64); ok && secs > 0 {
+		timeout = time.Duration(secs * float64(time.Second))
+		if t.timeout > 0 {
+			timeout = min(timeout, t.timeout)
+		}
+	}
+
+	channel := ToolChannel(ctx)
+	chatID := ToolChatID(ctx)
+	if channel == "" || chatID == "" || channel == "cli" {
+		return ErrorResult("ask_user needs an interactive chat channel")
+	}
+
+	promptID, err := newPromptID()
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("creating prompt: %v", err)).WithError(err)
+	}
+
+	// Register before publishing so a fast answer cannot slip past. Only
+	// the asking user answers: in a group, other members' messages reach
+	// the agent as usual. Anything the asking user sends ends the wait, so
+	// a command such as /clear is not stuck behind this tool.
+	senderID := ToolSenderID(ctx)
+	replies, cancel := t.msgBus.InterceptInbound(func(msg bus.InboundMessage) bool {
+		if msg.Channel != channel || msg.ChatID != chatID {
+			return false
+		}
+		return senderID == "" || msg.SenderID == senderID
+	})
+	defer cancel()
+
+	err = t.msgBus.PublishOutbound(ctx, bus.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Content: question,
+		Prompt:  &bus.ChoicePrompt{ID: promptID, Options: options},
+	})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("sending question: %v", err)).WithError(err)
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case reply := <-replies:
+		if !answers(reply, promptID) {
+			// Put it back for the agent loop to handle once this turn ends.
+			cancel()
+			if err := t.msgBus.PublishInbound(ctx, reply); err != nil {
+				return ErrorResult(fmt.Sprintf("requeueing message: %v", err)).WithError(err)
+			}
+			return SilentResult(fmt.Sprintf(
+				"The user did not choose; they sent %q instead, which is handled after this turn.", reply.Content))
+		}
+		return SilentResult(describeAnswer(reply, options))
+	case <-expired:
+		return SilentResult(fmt.Sprintf("The user did not answer within %s.", timeout))
+	case <-ctx.Done():
+		return ErrorResult("waiting for answer canceled").WithError(ctx.Err())
+	}
+}
+
+func (t *AskUserTool) timeoutDescription() string {
+	if t.timeout <= 0 {
+		return "Optional: how long to wait for an answer, in seconds"
+	}
+	return fmt.Sprintf("Optional: how long to wait for an answer, in seconds (at most %d)", int(t.timeout.Seconds()))
+}
+
+// answers reports whether reply answers the prompt rather than being a
+// command or a press on another prompt's buttons.
+func answers(reply bus.InboundMessage, promptID string) bool {
+	if reply.Choice != nil {
+		return reply.Choice.PromptID == promptID
+	}
+	return !commands.HasCommandPrefix(reply.Content)
+}
+
+// describeAnswer maps a reply to one of the options: a button press, the
+// number of an option, or its label. Anything else is passed on verbatim.
+func describeAnswer(reply bus.InboundMessage, options []string) string {
+	option := 0
+	if reply.Choice != nil {
+		option = reply.Choice.Option
+	} else {
+		text := strings.TrimSpace(reply.Content)
+		if n, err := strconv.Atoi(strings.TrimSuffix(text, ".")); err == nil {
+			option = n
+		} else {
+			for i, label := range options {
+				if strings.EqualFold(text, label) {
+					option = i + 1
+					break
+				}
+			}
+		}
+	}
+	if option >= 1 && option <= len(options) {
+		return fmt.Sprintf("The user chose %q (option %d).", options[option-1], option)
+	}
+	return fmt.Sprintf("The user replied: %q", reply.Content)
+}
+
+func newPromptID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,197 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// askAndAnswer runs the tool, waits for its prompt and answers it with the
+// message built by answer.
+func askAndAnswer(
+	t *testing.T,
+	args map[string]any,
+	answer func(prompt *bus.ChoicePrompt) bus.InboundMessage,
+) *ToolResult {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	tool := NewAskUserTool(msgBus, time.Second)
+
+	ctx := WithToolContext(context.Background(), "telegram", "chat1")
+	results := make(chan *ToolResult, 1)
+	go func() { results <- tool.Execute(ctx, args) }()
+
+	subCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(subCtx)
+	if !ok {
+		t.Fatal("expected the question to be published")
+	}
+	if out.Prompt == nil || out.ChatID != "chat1" || out.Content != args["question"] {
+		t.Fatalf("unexpected outbound message: %+v", out)
+	}
+
+	if answer != nil {
+		if err := msgBus.PublishInbound(context.Background(), answer(out.Prompt)); err != nil {
+			t.Fatalf("PublishInbound: %v", err)
+		}
+	}
+	return <-results
+}
+
+func yesNoArgs() map[string]any {
+	return map[string]any{
+		"question": "Deploy now?",
+		"options":  []any{"Yes", "No"},
+	}
+}
+
+func TestAskUserTool_ButtonPress(t *testing.T) {
+	result := askAndAnswer(t, yesNoArgs(), func(prompt *bus.ChoicePrompt) bus.InboundMessage {
+		return bus.InboundMessage{
+			Channel: "telegram",
+			ChatID:  "chat1",
+			Content: "No",
+			Choice:  &bus.ChoiceReply{PromptID: prompt.ID, Option: 2},
+		}
+	})
+	if result.IsError || !strings.Contains(result.ForLLM, `"No" (option 2)`) {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestAskUserTool_TextReplies(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  string
+	}{
+		{"1", `"Yes" (option 1)`},
+		{"no", `"No" (option 2)`},
+		{"maybe later", `replied: "maybe later"`},
+	}
+	for _, tt := range tests {
+		result := askAndAnswer(t, yesNoArgs(), func(*bus.ChoicePrompt) bus.InboundMessage {
+			return bus.InboundMessage{Channel: "telegram", ChatID: "chat1", Content: tt.reply}
+		})
+		if !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("reply %q: got %q, want it to contain %q", tt.reply, result.ForLLM, tt.want)
+		}
+	}
+}
+
+func TestAskUserTool_Timeout(t *testing.T) {
+	args := yesNoArgs()
+	args["timeout_seconds"] = 0.05
+	result := askAndAnswer(t, args, nil)
+	if result.IsError || !strings.Contains(result.ForLLM, "did not answer") {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestAskUserTool_InvalidArgs(t *testing.T) {
+	tool := NewAskUserTool(bus.NewMessageBus(), time.Second)
+	ctx := WithToolContext(context.Background(), "telegram", "chat1")
+
+	if r := tool.Execute(ctx, map[string]any{"question": "Q", "options": []any{"only"}}); !r.IsError {
+		t.Error("a single option should be rejected")
+	}
+	if r := tool.Execute(ctx, map[string]any{"options": []any{"a", "b"}}); !r.IsError {
+		t.Error("a missing question should be rejected")
+	}
+	cliCtx := WithToolContext(context.Background(), "cli", "direct")
+	if r := tool.Execute(cliCtx, yesNoArgs()); !r.IsError {
+		t.Error("the cli channel should be rejected")
+	}
+}
+
+func TestAskUserTool_OnlyAskingSenderAnswers(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	tool := NewAskUserTool(msgBus, time.Second)
+
+	ctx := WithToolSender(WithToolContext(context.Background(), "telegram", "group1"), "alice")
+	results := make(chan *ToolResult, 1)
+	go func() { results <- tool.Execute(ctx, yesNoArgs()) }()
+
+	subCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, ok := msgBus.SubscribeOutbound(subCtx); !ok {
+		t.Fatal("expected the question to be published")
+	}
+
+	for _, msg := range []bus.InboundMessage{
+		{Channel: "telegram", ChatID: "group1", SenderID: "bob", Content: "1"},
+		{Channel: "telegram", ChatID: "group1", SenderID: "alice", Content: "2"},
+	} {
+		if err := msgBus.PublishInbound(context.Background(), msg); err != nil {
+			t.Fatalf("PublishInbound: %v", err)
+		}
+	}
+
+	result := <-results
+	if !strings.Contains(result.ForLLM, `"No" (option 2)`) {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	// Bob's message went to the agent as usual.
+	if msg, ok := msgBus.ConsumeInbound(subCtx); !ok || msg.Content != "1" {
+		t.Errorf("inbound = %+v, want bob's %q", msg, "1")
+	}
+}
+
+func TestAskUserTool_CommandEndsWait(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	tool := NewAskUserTool(msgBus, time.Minute)
+
+	ctx := WithToolSender(WithToolContext(context.Background(), "telegram", "chat1"), "alice")
+	results := make(chan *ToolResult, 1)
+	go func() { results <- tool.Execute(ctx, yesNoArgs()) }()
+
+	subCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, ok := msgBus.SubscribeOutbound(subCtx); !ok {
+		t.Fatal("expected the question to be published")
+	}
+	command := bus.InboundMessage{Channel: "telegram", ChatID: "chat1", SenderID: "alice", Content: "/clear"}
+	if err := msgBus.PublishInbound(context.Background(), command); err != nil {
+		t.Fatalf("PublishInbound: %v", err)
+	}
+
+	select {
+	case result := <-results:
+		if result.IsError || !strings.Contains(result.ForLLM, "did not choose") {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	case <-subCtx.Done():
+		t.Fatal("a command from the asking user should end the wait")
+	}
+	// The command is back in the queue for the agent loop.
+	if msg, ok := msgBus.ConsumeInbound(subCtx); !ok || msg.Content != "/clear" {
+		t.Errorf("inbound = %+v, want the requeued /clear", msg)
+	}
+}
+
+func TestAskUserTool_TimeoutClampedToConfig(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	tool := NewAskUserTool(msgBus, 50*time.Millisecond)
+	ctx := WithToolContext(context.Background(), "telegram", "chat1")
+
+	args := yesNoArgs()
+	args["timeout_seconds"] = float64(7 * 24 * 3600)
+	go func() {
+		subCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		msgBus.SubscribeOutbound(subCtx)
+	}()
+
+	start := time.Now()
+	result := tool.Execute(ctx, args)
+	if !strings.Contains(result.ForLLM, "did not answer within 50ms") || time.Since(start) > time.Second {
+		t.Fatalf("unexpected result after %s: %+v", time.Since(start), result)
+	}
+}
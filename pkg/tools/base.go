@@ -21,8 +21,9 @@ type Tool interface {
 type toolCtxKey struct{ name string }
 
 var (
-	ctxKeyChannel = &toolCtxKey{"channel"}
-	ctxKeyChatID  = &toolCtxKey{"chatID"}
+	ctxKeyChannel  = &toolCtxKey{"channel"}
+	ctxKeyChatID   = &toolCtxKey{"chatID"}
+	ctxKeySenderID = &toolCtxKey{"senderID"}
 )
 
 // WithToolContext returns a child context carrying channel and chatID.
@@ -44,6 +45,18 @@ func ToolChatID(ctx context.Context) string {
 	return v
 }
 
+// WithToolSender returns a child context carrying the ID of the user whose
+// message started the turn.
+func WithToolSender(ctx context.Context, senderID string) context.Context {
+	return context.WithValue(ctx, ctxKeySenderID, senderID)
+}
+
+// ToolSenderID extracts the sender ID from ctx, or "" if unset.
+func ToolSenderID(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKeySenderID).(string)
+	return v
+}
+
 // AsyncCallback is a function type that async tools use to notify completion.
 // When an async tool finishes its work, it calls this callback with the result.
 //
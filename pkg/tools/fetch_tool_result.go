@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// elidedIDPattern matches the IDs handed out for elided tool results; it
+// also keeps the ID from escaping the results directory.
+var elidedIDPattern = regexp.MustCompile(`^[a-z0-9-]{1,64}$`)
+
+// ElidedResultPath is where the full text of an elided tool result with the
+// given ID is kept in an agent's workspace.
+func ElidedResultPath(workspace, id string) string {
+	return filepath.Join(workspace, "state", "tool_results", id+".txt")
+}
+
+// FetchToolResultTool returns a tool result that was replaced by a stub to
+// save context space.
+type FetchToolResultTool struct {
+	workspace string
+}
+
+func NewFetchToolResultTool(workspace string) *FetchToolResultTool {
+	return &FetchToolResultTool{workspace: workspace}
+}
+
+func (t *FetchToolResultTool) Name() string {
+	return "fetch_tool_result"
+}
+
+func (t *FetchToolResultTool) Description() string {
+	return "Fetch the full text of an earlier tool result that was elided from the conversation " +
+		"to save space. Only use it when you need the details again."
+}
+
+func (t *FetchToolResultTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id": map[string]any{
+				"type":        "string",
+				"description": "The ID given in the elided result's placeholder",
+			},
+		},
+		"required": []string{"id"},
+	}
+}
+
+func (t *FetchToolResultTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	id, _ := args["id"].(string)
+	if !elidedIDPattern.MatchString(id) {
+		return ErrorResult("id is required and must be the ID from an elided result placeholder")
+	}
+	data, err := os.ReadFile(ElidedResultPath(t.workspace, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrorResult(fmt.Sprintf("no elided tool result with id %q", id))
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read elided tool result: %v", err)).WithError(err)
+	}
+	return NewToolResult(string(data))
+}
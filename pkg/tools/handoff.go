@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// HandoffBack is the agent_id that returns a handed-off conversation to the
+// agent the chat is normally routed to.
+const HandoffBack = "back"
+
+// HandoffFunc transfers the conversation in channel/chatID to agentID (or
+// back, for HandoffBack) and returns the name of the receiving agent.
+type HandoffFunc func(ctx context.Context, channel, chatID, agentID, summary string) (string, error)
+
+// HandoffTool lets an agent pass the current conversation to another agent.
+// Unlike spawn, the other agent takes over the chat: later user messages are
+// routed to it until it hands back or the user sends /back.
+type HandoffTool struct {
+	handoff        HandoffFunc
+	allowlistCheck func(targetAgentID string) bool
+}
+
+func NewHandoffTool(handoff HandoffFunc) *HandoffTool {
+	return &HandoffTool{handoff: handoff}
+}
+
+func (t *HandoffTool) Name() string {
+	return "handoff"
+}
+
+func (t *HandoffTool) Description() string {
+	return "Hand the current conversation over to another agent that is better suited to continue it. " +
+		"The other agent receives your summary and answers the user's following messages. " +
+		"Use agent_id \"back\" to return a conversation that was handed to you."
+}
+
+func (t *HandoffTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"agent_id": map[string]any{
+				"type":        "string",
+				"description": "ID of the agent to hand the conversation to, or \"back\" to return it",
+			},
+			"summary": map[string]any{
+				"type": "string",
+				"description": "Summary of the conversation for the receiving agent: " +
+					"what the user wants, what has been done and what is still open",
+			},
+		},
+		"required": []string{"agent_id", "summary"},
+	}
+}
+
+func (t *HandoffTool) SetAllowlistChecker(check func(targetAgentID string) bool) {
+	t.allowlistCheck = check
+}
+
+func (t *HandoffTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	agentID, _ := args["agent_id"].(string)
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return ErrorResult("agent_id is required")
+	}
+	summary, _ := args["summary"].(string)
+	if strings.TrimSpace(summary) == "" {
+		return ErrorResult("summary is required and must be a non-empty string")
+	}
+
+	// Handing back is always allowed; the allowlist governs new handoffs.
+	back := strings.EqualFold(agentID, HandoffBack)
+	if !back && t.allowlistCheck != nil && !t.allowlistCheck(agentID) {
+		return ErrorResult(fmt.Sprintf("not allowed to hand off to agent '%s'", agentID))
+	}
+
+	if t.handoff == nil {
+		return ErrorResult("handoff not configured")
+	}
+	channel, chatID := ToolChannel(ctx), ToolChatID(ctx)
+	if channel == "" || chatID == "" {
+		return ErrorResult("handoff is only available in a conversation")
+	}
+	if back {
+		agentID = HandoffBack
+	}
+
+	target, err := t.handoff(ctx, channel, chatID, agentID, summary)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("handoff failed: %v", err)).WithError(err)
+	}
+	return NewToolResult(fmt.Sprintf(
+		"The conversation is now handled by %s, starting with the user's next message. "+
+			"Briefly tell the user who will continue and stop working on the request yourself.", target))
+}
@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHandoffTool(t *testing.T) {
+	var gotTarget string
+	tool := NewHandoffTool(func(_ context.Context, channel, chatID, agentID, summary string) (string, error) {
+		gotTarget = agentID
+		return "agent " + agentID, nil
+	})
+	tool.SetAllowlistChecker(func(target string) bool { return target == "billing" })
+	ctx := WithToolContext(context.Background(), "telegram", "chat-1")
+
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		args    map[string]any
+		wantErr string
+	}{
+		{"missing summary", ctx, map[string]any{"agent_id": "billing"}, "summary is required"},
+		{"not allowed", ctx, map[string]any{"agent_id": "ops", "summary": "s"}, "not allowed"},
+		{"no conversation", context.Background(), map[string]any{"agent_id": "billing", "summary": "s"}, "only available"},
+		{"allowed", ctx, map[string]any{"agent_id": "billing", "summary": "s"}, ""},
+		{"back bypasses allowlist", ctx, map[string]any{"agent_id": "Back", "summary": "s"}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tool.Execute(tc.ctx, tc.args)
+			if tc.wantErr == "" {
+				if res.IsError {
+					t.Fatalf("unexpected error: %s", res.ForLLM)
+				}
+				return
+			}
+			if !res.IsError || !strings.Contains(res.ForLLM, tc.wantErr) {
+				t.Fatalf("result = %q, want error containing %q", res.ForLLM, tc.wantErr)
+			}
+		})
+	}
+	if gotTarget != HandoffBack {
+		t.Errorf("last target = %q, want %q", gotTarget, HandoffBack)
+	}
+}
@@ -124,6 +124,16 @@ func (t *MCPTool) Name() string {
 	return base + "_" + suffix
 }
 
+// ServerName returns the MCP server the tool belongs to.
+func (t *MCPTool) ServerName() string {
+	return t.serverName
+}
+
+// OriginalName returns the tool name as reported by the MCP server.
+func (t *MCPTool) OriginalName() string {
+	return t.tool.Name
+}
+
 // Description returns the tool description
 func (t *MCPTool) Description() string {
 	desc := t.tool.Description
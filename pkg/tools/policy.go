@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// mcpTool is implemented by tools bridged from an MCP server, so policies
+// can name them by server and original tool name.
+type mcpTool interface {
+	ServerName() string
+	OriginalName() string
+}
+
+// ToolPolicy decides which tools an agent may call and with which
+// arguments. A nil *ToolPolicy allows everything.
+type ToolPolicy struct {
+	allow []string
+	deny  []string
+	args  []argRule
+}
+
+type argRule struct {
+	tool  string
+	arg   string
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+// NewToolPolicy compiles an agent's tools config. It returns nil when cfg
+// sets no allow, deny or argument rules.
+func NewToolPolicy(cfg *config.AgentToolsConfig) (*ToolPolicy, error) {
+	if cfg == nil || len(cfg.Allow) == 0 && len(cfg.Deny) == 0 && len(cfg.Policies) == 0 {
+		return nil, nil
+	}
+
+	p := &ToolPolicy{allow: cfg.Allow, deny: cfg.Deny}
+	for _, pattern := range append(append([]string{}, cfg.Allow...), cfg.Deny...) {
+		if _, err := path.Match(globPart(pattern), ""); err != nil {
+			return nil, fmt.Errorf("invalid tool pattern %q: %w", pattern, err)
+		}
+	}
+	for _, pol := range cfg.Policies {
+		if _, err := path.Match(globPart(pol.Tool), ""); err != nil {
+			return nil, fmt.Errorf("invalid tool pattern %q: %w", pol.Tool, err)
+		}
+		for arg, ap := range pol.Args {
+			rule := argRule{tool: pol.Tool, arg: arg}
+			var err error
+			if rule.allow, err = compileArgPatterns(ap.Allow); err != nil {
+				return nil, fmt.Errorf("tool %q argument %q: %w", pol.Tool, arg, err)
+			}
+			if rule.deny, err = compileArgPatterns(ap.Deny); err != nil {
+				return nil, fmt.Errorf("tool %q argument %q: %w", pol.Tool, arg, err)
+			}
+			p.args = append(p.args, rule)
+		}
+	}
+	return p, nil
+}
+
+// DenyAllPolicy blocks every tool. Used when an agent's policy is invalid,
+// so a typo never silently grants access.
+func DenyAllPolicy() *ToolPolicy {
+	return &ToolPolicy{deny: []string{"*"}}
+}
+
+func compileArgPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// globPart strips the "mcp:" prefix so the remaining glob can be validated.
+func globPart(pattern string) string {
+	return strings.TrimPrefix(pattern, "mcp:")
+}
+
+// matches reports whether pattern names tool.
+func matches(pattern string, tool Tool) bool {
+	if rest, ok := strings.CutPrefix(pattern, "mcp:"); ok {
+		mt, isMCP := tool.(mcpTool)
+		if !isMCP {
+			return false
+		}
+		server, name, found := strings.Cut(rest, "/")
+		if !found {
+			name = "*"
+		}
+		okServer, _ := path.Match(server, mt.ServerName())
+		okName, _ := path.Match(name, mt.OriginalName())
+		return okServer && okName
+	}
+	ok, _ := path.Match(pattern, tool.Name())
+	return ok
+}
+
+func matchesAny(patterns []string, tool Tool) bool {
+	for _, pattern := range patterns {
+		if matches(pattern, tool) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether the agent may see and call tool at all.
+func (p *ToolPolicy) Allowed(tool Tool) bool {
+	if p == nil {
+		return true
+	}
+	if matchesAny(p.deny, tool) {
+		return false
+	}
+	return len(p.allow) == 0 || matchesAny(p.allow, tool)
+}
+
+// Check returns an error when the call is not permitted.
+func (p *ToolPolicy) Check(tool Tool, args map[string]any) error {
+	if p == nil {
+		return nil
+	}
+	if !p.Allowed(tool) {
+		return fmt.Errorf("tool %q is not allowed for this agent", tool.Name())
+	}
+	for _, rule := range p.args {
+		if !matches(rule.tool, tool) {
+			continue
+		}
+		v, ok := args[rule.arg]
+		if !ok || v == nil {
+			continue
+		}
+		value := argString(v)
+		if len(rule.allow) > 0 && !matchesRegexps(rule.allow, value) {
+			return fmt.Errorf("argument %q of tool %q is not in the allowed set", rule.arg, tool.Name())
+		}
+		if matchesRegexps(rule.deny, value) {
+			return fmt.Errorf("argument %q of tool %q matches a denied pattern", rule.arg, tool.Name())
+		}
+	}
+	return nil
+}
+
+func argString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func matchesRegexps(res []*regexp.Regexp, value string) bool {
+	for _, re := range res {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func policyRegistry(t *testing.T, cfg *config.AgentToolsConfig) *ToolRegistry {
+	t.Helper()
+	r := NewToolRegistry()
+	r.Register(newMockTool("exec", "run commands"))
+	r.Register(newMockTool("read_file", "read"))
+	r.Register(newMockTool("web_fetch", "fetch"))
+	r.Register(NewMCPTool(&MockMCPManager{}, "github", &mcp.Tool{Name: "create_issue"}))
+	r.Register(NewMCPTool(&MockMCPManager{}, "github", &mcp.Tool{Name: "delete_repo"}))
+
+	policy, err := NewToolPolicy(cfg)
+	if err != nil {
+		t.Fatalf("NewToolPolicy: %v", err)
+	}
+	r.SetPolicy(policy)
+	return r
+}
+
+func providerDefNames(r *ToolRegistry) []string {
+	var names []string
+	for _, d := range r.ToProviderDefs() {
+		names = append(names, d.Function.Name)
+	}
+	return names
+}
+
+func TestToolPolicy_DenyHidesAndBlocks(t *testing.T) {
+	r := policyRegistry(t, &config.AgentToolsConfig{Deny: []string{"exec", "mcp:github/delete_*"}})
+
+	got := strings.Join(providerDefNames(r), ",")
+	if got != "mcp_github_create_issue,read_file,web_fetch" {
+		t.Errorf("visible tools = %s", got)
+	}
+
+	res := r.ExecuteWithContext(context.Background(), "exec", map[string]any{"command": "ls"}, "", "", nil)
+	if !res.IsError || !strings.Contains(res.ForLLM, "permission denied") {
+		t.Errorf("exec result = %+v, want permission denied", res)
+	}
+	res = r.Execute(context.Background(), "mcp_github_delete_repo", nil)
+	if !res.IsError {
+		t.Error("denied MCP tool executed")
+	}
+	if res := r.Execute(context.Background(), "read_file", nil); res.IsError {
+		t.Errorf("read_file denied: %s", res.ForLLM)
+	}
+}
+
+func TestToolPolicy_AllowList(t *testing.T) {
+	r := policyRegistry(t, &config.AgentToolsConfig{
+		Allow: []string{"read_*", "mcp:github/*"},
+		Deny:  []string{"mcp:*/delete_repo"},
+	})
+
+	got := strings.Join(providerDefNames(r), ",")
+	if got != "mcp_github_create_issue,read_file" {
+		t.Errorf("visible tools = %s", got)
+	}
+	if res := r.Execute(context.Background(), "web_fetch", nil); !res.IsError {
+		t.Error("web_fetch is not in the allow list but executed")
+	}
+
+	// Tools registered after the policy are filtered too.
+	r.Register(newMockTool("write_file", "write"))
+	if res := r.Execute(context.Background(), "write_file", nil); !res.IsError {
+		t.Error("late-registered tool bypassed the policy")
+	}
+}
+
+func TestToolPolicy_ArgumentRules(t *testing.T) {
+	r := policyRegistry(t, &config.AgentToolsConfig{
+		Policies: []config.ToolArgPolicy{
+			{Tool: "exec", Args: map[string]config.ArgPolicy{
+				"command": {Deny: []string{`\brm\s+-rf\b`, `\bsudo\b`}},
+			}},
+			{Tool: "web_fetch", Args: map[string]config.ArgPolicy{
+				"url": {Allow: []string{`^https://docs\.example\.com/`}},
+			}},
+		},
+	})
+
+	cases := []struct {
+		tool    string
+		args    map[string]any
+		allowed bool
+	}{
+		{"exec", map[string]any{"command": "ls -la"}, true},
+		{"exec", map[string]any{"command": "sudo reboot"}, false},
+		{"exec", map[string]any{"command": "rm -rf /"}, false},
+		{"web_fetch", map[string]any{"url": "https://docs.example.com/api"}, true},
+		{"web_fetch", map[string]any{"url": "https://evil.example.net/"}, false},
+		{"web_fetch", map[string]any{}, true}, // absent arguments are left to the tool
+	}
+	for _, tc := range cases {
+		res := r.Execute(context.Background(), tc.tool, tc.args)
+		if res.IsError == tc.allowed {
+			t.Errorf("%s(%v): error = %v, want allowed = %v (%s)", tc.tool, tc.args, res.IsError, tc.allowed, res.ForLLM)
+		}
+	}
+}
+
+func TestNewToolPolicy_InvalidAndEmpty(t *testing.T) {
+	if p, err := NewToolPolicy(&config.AgentToolsConfig{Unrestricted: []string{"exec"}}); p != nil || err != nil {
+		t.Errorf("config without rules = %v, %v; want nil policy", p, err)
+	}
+	if _, err := NewToolPolicy(&config.AgentToolsConfig{Deny: []string{"[exec"}}); err == nil {
+		t.Error("expected error for invalid glob")
+	}
+	_, err := NewToolPolicy(&config.AgentToolsConfig{Policies: []config.ToolArgPolicy{
+		{Tool: "exec", Args: map[string]config.ArgPolicy{"command": {Deny: []string{"("}}}},
+	}})
+	if err == nil {
+		t.Error("expected error for invalid argument regex")
+	}
+
+	r := NewToolRegistry()
+	r.Register(newMockTool("exec", "run"))
+	r.SetPolicy(DenyAllPolicy())
+	if len(r.ToProviderDefs()) != 0 || !r.Execute(context.Background(), "exec", nil).IsError {
+		t.Error("DenyAllPolicy should hide and block every tool")
+	}
+}
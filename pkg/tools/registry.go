@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -20,8 +21,9 @@ type ToolEntry struct {
 
 type ToolRegistry struct {
 	tools   map[string]*ToolEntry
+	policy  *ToolPolicy
 	mu      sync.RWMutex
-	version atomic.Uint64 // incremented on Register/RegisterHidden for cache invalidation
+	version atomic.Uint64 // incremented on Register/RegisterHidden/SetPolicy for cache invalidation
 }
 
 func NewToolRegistry() *ToolRegistry {
@@ -65,6 +67,44 @@ func (r *ToolRegistry) RegisterHidden(tool Tool) {
 	logger.DebugCF("tools", "Registered hidden tool", map[string]any{"name": name})
 }
 
+// CloneWithout returns a registry with the same tools and policy, minus the
+// named tools. Subagents use it to run with an agent's tools but without the
+// tools that would delegate again.
+func (r *ToolRegistry) CloneWithout(names ...string) *ToolRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clone := &ToolRegistry{
+		tools:  make(map[string]*ToolEntry, len(r.tools)),
+		policy: r.policy,
+	}
+	for name, entry := range r.tools {
+		if slices.Contains(names, name) {
+			continue
+		}
+		e := *entry
+		clone.tools[name] = &e
+	}
+	return clone
+}
+
+// SetPolicy restricts which tools are offered to the model and enforces
+// the policy on every execution. A nil policy allows everything.
+func (r *ToolRegistry) SetPolicy(p *ToolPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policy = p
+	r.version.Add(1)
+}
+
+// visible reports whether an entry is offered to the model: hidden tools
+// need a live TTL and every tool must pass the policy. Caller holds r.mu.
+func (r *ToolRegistry) visible(entry *ToolEntry) bool {
+	if !entry.IsCore && entry.TTL <= 0 {
+		return false
+	}
+	return r.policy.Allowed(entry.Tool)
+}
+
 // PromoteTools atomically sets the TTL for multiple non-core tools.
 // This prevents a concurrent TickTTL from decrementing between promotions.
 func (r *ToolRegistry) PromoteTools(names []string, ttl int) {
@@ -123,7 +163,7 @@ func (r *ToolRegistry) SnapshotHiddenTools() HiddenToolSnapshot {
 	defer r.mu.RUnlock()
 	docs := make([]HiddenToolDoc, 0, len(r.tools))
 	for name, entry := range r.tools {
-		if !entry.IsCore {
+		if !entry.IsCore && r.policy.Allowed(entry.Tool) {
 			docs = append(docs, HiddenToolDoc{
 				Name:        name,
 				Description: entry.Tool.Description(),
@@ -180,6 +220,18 @@ func (r *ToolRegistry) ExecuteWithContext(
 		return ErrorResult(fmt.Sprintf("tool %q not found", name)).WithError(fmt.Errorf("tool not found"))
 	}
 
+	r.mu.RLock()
+	policy := r.policy
+	r.mu.RUnlock()
+	if err := policy.Check(tool, args); err != nil {
+		logger.WarnCF("tool", "Tool call denied by agent policy",
+			map[string]any{
+				"tool":  name,
+				"error": err.Error(),
+			})
+		return ErrorResult(fmt.Sprintf("permission denied: %v", err)).WithError(err)
+	}
+
 	// Inject channel/chatID into ctx so tools read them via ToolChannel(ctx)/ToolChatID(ctx).
 	// Always inject — tools validate what they require.
 	ctx = WithToolContext(ctx, channel, chatID)
@@ -247,7 +299,7 @@ func (r *ToolRegistry) GetDefinitions() []map[string]any {
 	for _, name := range sorted {
 		entry := r.tools[name]
 
-		if !entry.IsCore && entry.TTL <= 0 {
+		if !r.visible(entry) {
 			continue
 		}
 
@@ -267,7 +319,7 @@ func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
 	for _, name := range sorted {
 		entry := r.tools[name]
 
-		if !entry.IsCore && entry.TTL <= 0 {
+		if !r.visible(entry) {
 			continue
 		}
 
@@ -321,7 +373,7 @@ func (r *ToolRegistry) GetSummaries() []string {
 	for _, name := range sorted {
 		entry := r.tools[name]
 
-		if !entry.IsCore && entry.TTL <= 0 {
+		if !r.visible(entry) {
 			continue
 		}
 
@@ -169,7 +169,8 @@ func (r *ToolRegistry) SearchRegex(pattern string, maxSearchResults int) ([]Tool
 	for _, name := range r.sortedToolNames() {
 		entry := r.tools[name]
 		// Search only among the hidden tools (Core tools are already visible)
-		if !entry.IsCore {
+		// that the agent's policy allows.
+		if !entry.IsCore && r.policy.Allowed(entry.Tool) {
 			// Directly call interface methods! No reflection/unmarshalling needed.
 			desc := entry.Tool.Description()
 
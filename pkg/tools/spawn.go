@@ -44,6 +44,8 @@ func (t *SpawnTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional target agent ID to delegate the task to",
 			},
+			"skill":         skillParameter(),
+			"output_schema": outputSchemaParameter(),
 		},
 		"required": []string{"task"},
 	}
@@ -72,6 +74,11 @@ func (t *SpawnTool) execute(ctx context.Context, args map[string]any, cb AsyncCa
 	label, _ := args["label"].(string)
 	agentID, _ := args["agent_id"].(string)
 
+	responseFormat, err := parseOutputSchema(args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
 	// Check allowlist if targeting a specific agent
 	if agentID != "" && t.allowlistCheck != nil {
 		if !t.allowlistCheck(agentID) {
@@ -83,6 +90,12 @@ func (t *SpawnTool) execute(ctx context.Context, args map[string]any, cb AsyncCa
 		return ErrorResult("Subagent manager not configured")
 	}
 
+	skill, _ := args["skill"].(string)
+	task, responseFormat, err = t.manager.applySkill(skill, task, responseFormat)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
 	// Read channel/chatID from context (injected by registry).
 	// Fall back to "cli"/"direct" for non-conversation callers (e.g., CLI, tests)
 	// to preserve the same defaults as the original NewSpawnTool constructor.
@@ -96,7 +109,7 @@ func (t *SpawnTool) execute(ctx context.Context, args map[string]any, cb AsyncCa
 	}
 
 	// Pass callback to manager for async completion notification
-	result, err := t.manager.Spawn(ctx, task, label, agentID, channel, chatID, cb)
+	result, err := t.manager.SpawnWithFormat(ctx, task, label, agentID, channel, chatID, responseFormat, cb)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to spawn subagent: %v", err))
 	}
@@ -0,0 +1,266 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for SpawnParallelTool limits.
+const (
+	defaultParallelConcurrency = 4
+	defaultParallelMaxTasks    = 10
+	defaultParallelTimeout     = 5 * time.Minute
+)
+
+// SpawnParallelTool runs several subagent tasks at once and waits for all of
+// them (fan-out/fan-in), so the calling agent can combine their results in
+// the same turn. Cancelling the turn cancels every task.
+type SpawnParallelTool struct {
+	manager        *SubagentManager
+	allowlistCheck func(targetAgentID string) bool
+	maxConcurrency int
+	maxTasks       int
+	timeout        time.Duration
+}
+
+// SpawnParallelOptions limits a SpawnParallelTool; zero values use defaults.
+type SpawnParallelOptions struct {
+	MaxConcurrency int
+	MaxTasks       int
+	Timeout        time.Duration // upper bound for the whole batch
+}
+
+func NewSpawnParallelTool(manager *SubagentManager, opts SpawnParallelOptions) *SpawnParallelTool {
+	t := &SpawnParallelTool{
+		manager:        manager,
+		maxConcurrency: opts.MaxConcurrency,
+		maxTasks:       opts.MaxTasks,
+		timeout:        opts.Timeout,
+	}
+	if t.maxConcurrency <= 0 {
+		t.maxConcurrency = defaultParallelConcurrency
+	}
+	if t.maxTasks <= 0 {
+		t.maxTasks = defaultParallelMaxTasks
+	}
+	if t.timeout <= 0 {
+		t.timeout = defaultParallelTimeout
+	}
+	return t
+}
+
+func (t *SpawnParallelTool) Name() string {
+	return "spawn_parallel"
+}
+
+func (t *SpawnParallelTool) Description() string {
+	return fmt.Sprintf("Run up to %d subagent tasks in parallel and wait for all of them. "+
+		"Use this when a request splits into independent parts whose results you need to combine. "+
+		"Returns every task's result, including failures, as one JSON report.", t.maxTasks)
+}
+
+func (t *SpawnParallelTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"tasks": map[string]any{
+				"type":        "array",
+				"description": "The tasks to run in parallel",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"task": map[string]any{
+							"type":        "string",
+							"description": "The task for the subagent to complete",
+						},
+						"label": map[string]any{
+							"type":        "string",
+							"description": "Optional short label for the task",
+						},
+						"agent_id": map[string]any{
+							"type":        "string",
+							"description": "Optional target agent ID to run the task as",
+						},
+					},
+					"required": []string{"task"},
+				},
+			},
+			"timeout_seconds": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Optional overall deadline in seconds (at most %d)", int(t.timeout.Seconds())),
+			},
+			"skill":         skillParameter(),
+			"output_schema": outputSchemaParameter(),
+		},
+		"required": []string{"tasks"},
+	}
+}
+
+func (t *SpawnParallelTool) SetAllowlistChecker(check func(targetAgentID string) bool) {
+	t.allowlistCheck = check
+}
+
+// parallelTask is one entry of the tasks argument.
+type parallelTask struct {
+	Task    string
+	Label   string
+	AgentID string
+}
+
+// ParallelTaskResult is one task's outcome in the spawn_parallel report.
+type ParallelTaskResult struct {
+	Index      int    `json:"index"`
+	TaskID     string `json:"task_id,omitempty"`
+	Label      string `json:"label,omitempty"`
+	AgentID    string `json:"agent_id,omitempty"`
+	Status     string `json:"status"` // completed, failed, canceled or timed_out
+	Result     string `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+	SessionKey string `json:"session_key,omitempty"`
+}
+
+// ParallelReport is the spawn_parallel tool result.
+type ParallelReport struct {
+	Completed int                  `json:"completed"`
+	Failed    int                  `json:"failed"`
+	Results   []ParallelTaskResult `json:"results"`
+}
+
+func (t *SpawnParallelTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	if t.manager == nil {
+		return ErrorResult("Subagent manager not configured")
+	}
+	tasks, err := parseParallelTasks(args["tasks"])
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if len(tasks) > t.maxTasks {
+		return ErrorResult(fmt.Sprintf("too many tasks: %d (at most %d)", len(tasks), t.maxTasks))
+	}
+	for _, task := range tasks {
+		if task.AgentID != "" && t.allowlistCheck != nil && !t.allowlistCheck(task.AgentID) {
+			return ErrorResult(fmt.Sprintf("not allowed to spawn agent '%s'", task.AgentID))
+		}
+	}
+	responseFormat, err := parseOutputSchema(args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if skill, _ := args["skill"].(string); skill != "" {
+		format := responseFormat
+		for i := range tasks {
+			tasks[i].Task, format, err = t.manager.applySkill(skill, tasks[i].Task, responseFormat)
+			if err != nil {
+				return ErrorResult(err.Error())
+			}
+		}
+		responseFormat = format
+	}
+
+	timeout := t.timeout
+	if secs, ok := args["timeout_seconds"].(float64); ok && secs > 0 {
+		timeout = min(timeout, time.Duration(secs*float64(time.Second)))
+	}
+	// The turn's context is the parent: cancelling the turn cancels all tasks.
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	channel := ToolChannel(ctx)
+	if channel == "" {
+		channel = "cli"
+	}
+	chatID := ToolChatID(ctx)
+	if chatID == "" {
+		chatID = "direct"
+	}
+
+	results := make([]ParallelTaskResult, len(tasks))
+	sem := make(chan struct{}, t.maxConcurrency)
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := ParallelTaskResult{Index: i, Label: task.Label, AgentID: task.AgentID}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-runCtx.Done():
+				res.Status, res.Error = parallelCancelStatus(runCtx)
+				results[i] = res
+				return
+			}
+
+			run := t.manager.RunSync(runCtx, task.Task, task.Label, task.AgentID, channel, chatID, responseFormat)
+			res.TaskID = run.ID
+			res.SessionKey = run.SessionKey
+			switch run.Status {
+			case "completed":
+				res.Status = "completed"
+				res.Result = run.Result
+			case "canceled":
+				res.Status, res.Error = parallelCancelStatus(runCtx)
+			default:
+				res.Status = "failed"
+				res.Error = run.Result
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+
+	report := ParallelReport{Results: results}
+	for _, r := range results {
+		if r.Status == "completed" {
+			report.Completed++
+		} else {
+			report.Failed++
+		}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to encode results: %v", err)).WithError(err)
+	}
+
+	// Partial failures are reported per task; only a batch where nothing
+	// completed is an error.
+	if report.Completed == 0 {
+		return ErrorResult(string(data))
+	}
+	return NewToolResult(string(data))
+}
+
+func parallelCancelStatus(ctx context.Context) (status, msg string) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "timed_out", "deadline exceeded before the task finished"
+	}
+	return "canceled", "canceled with the parent turn"
+}
+
+func parseParallelTasks(raw any) ([]parallelTask, error) {
+	items, ok := raw.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("tasks is required and must be a non-empty array")
+	}
+	tasks := make([]parallelTask, 0, len(items))
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("tasks[%d] must be an object", i)
+		}
+		task, _ := obj["task"].(string)
+		if strings.TrimSpace(task) == "" {
+			return nil, fmt.Errorf("tasks[%d].task is required and must be a non-empty string", i)
+		}
+		label, _ := obj["label"].(string)
+		agentID, _ := obj["agent_id"].(string)
+		tasks = append(tasks, parallelTask{Task: task, Label: label, AgentID: strings.TrimSpace(agentID)})
+	}
+	return tasks, nil
+}
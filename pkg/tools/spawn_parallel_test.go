@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// parallelMockProvider fails tasks mentioning "fail", blocks tasks mentioning
+// "hang" until cancelled and tracks how many calls run at once.
+type parallelMockProvider struct {
+	MockLLMProvider
+	mu      sync.Mutex
+	running int
+	peak    int
+	calls   atomic.Int32
+}
+
+func (m *parallelMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	m.calls.Add(1)
+	m.mu.Lock()
+	m.running++
+	m.peak = max(m.peak, m.running)
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.running--
+		m.mu.Unlock()
+	}()
+
+	task := messages[len(messages)-1].Content
+	switch {
+	case strings.Contains(task, "fail"):
+		return nil, errors.New("provider exploded")
+	case strings.Contains(task, "hang"):
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	time.Sleep(20 * time.Millisecond)
+	return &providers.LLMResponse{Content: "done: " + task}, nil
+}
+
+func parallelArgs(tasks ...string) map[string]any {
+	items := make([]any, 0, len(tasks))
+	for _, task := range tasks {
+		items = append(items, map[string]any{"task": task, "label": task})
+	}
+	return map[string]any{"tasks": items}
+}
+
+func decodeReport(t *testing.T, res *ToolResult) ParallelReport {
+	t.Helper()
+	var report ParallelReport
+	if err := json.Unmarshal([]byte(res.ForLLM), &report); err != nil {
+		t.Fatalf("result is not a report: %v\n%s", err, res.ForLLM)
+	}
+	return report
+}
+
+func TestSpawnParallel_PartialFailureAndConcurrencyCap(t *testing.T) {
+	provider := &parallelMockProvider{}
+	tool := NewSpawnParallelTool(NewSubagentManager(provider, "m", "/tmp/test"),
+		SpawnParallelOptions{MaxConcurrency: 2})
+
+	res := tool.Execute(context.Background(), parallelArgs("a", "b", "fail c", "d", "e"))
+	if res.IsError {
+		t.Fatalf("partial failure should not fail the tool: %s", res.ForLLM)
+	}
+	report := decodeReport(t, res)
+	if report.Completed != 4 || report.Failed != 1 || len(report.Results) != 5 {
+		t.Fatalf("report = %+v", report)
+	}
+	for i, r := range report.Results {
+		if r.Index != i || r.TaskID == "" {
+			t.Errorf("result %d out of order or without task ID: %+v", i, r)
+		}
+	}
+	if r := report.Results[2]; r.Status != "failed" || !strings.Contains(r.Error, "provider exploded") {
+		t.Errorf("failed task = %+v", r)
+	}
+	if r := report.Results[0]; r.Status != "completed" || r.Result != "done: a" {
+		t.Errorf("completed task = %+v", r)
+	}
+	if provider.peak > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", provider.peak)
+	}
+}
+
+func TestSpawnParallel_DeadlineAndCancellation(t *testing.T) {
+	provider := &parallelMockProvider{}
+	tool := NewSpawnParallelTool(NewSubagentManager(provider, "m", "/tmp/test"),
+		SpawnParallelOptions{Timeout: 100 * time.Millisecond})
+
+	report := decodeReport(t, tool.Execute(context.Background(), parallelArgs("a", "hang")))
+	if report.Results[0].Status != "completed" || report.Results[1].Status != "timed_out" {
+		t.Errorf("deadline report = %+v", report.Results)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	res := tool.Execute(ctx, parallelArgs("hang", "hang too"))
+	if !res.IsError {
+		t.Fatal("a batch where nothing completed should be an error")
+	}
+	for _, r := range decodeReport(t, res).Results {
+		if r.Status != "canceled" {
+			t.Errorf("task %d status = %s, want canceled", r.Index, r.Status)
+		}
+	}
+}
+
+func TestSpawnParallel_Validation(t *testing.T) {
+	provider := &parallelMockProvider{}
+	tool := NewSpawnParallelTool(NewSubagentManager(provider, "m", "/tmp/test"),
+		SpawnParallelOptions{MaxTasks: 2})
+	tool.SetAllowlistChecker(func(id string) bool { return id == "coder" })
+
+	cases := map[string]map[string]any{
+		"no tasks":    {"tasks": []any{}},
+		"empty task":  {"tasks": []any{map[string]any{"task": " "}}},
+		"too many":    parallelArgs("a", "b", "c"),
+		"not allowed": {"tasks": []any{map[string]any{"task": "x", "agent_id": "ops"}}},
+	}
+	for name, args := range cases {
+		if res := tool.Execute(context.Background(), args); !res.IsError {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if n := provider.calls.Load(); n != 0 {
+		t.Errorf("invalid batches must not start tasks, %d calls made", n)
+	}
+}
@@ -173,6 +173,9 @@ func spawnStatusFormatTask(task *SubagentTask) string {
 		}
 		sb.WriteString(fmt.Sprintf("\n  result: %s", result))
 	}
+	if task.SessionKey != "" {
+		sb.WriteString(fmt.Sprintf("\n  session: %s", task.SessionKey))
+	}
 
 	return sb.String()
 }
@@ -3,10 +3,12 @@ package tools
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/skills"
 )
 
 type SubagentTask struct {
@@ -19,8 +21,43 @@ type SubagentTask struct {
 	Status        string
 	Result        string
 	Created       int64
+	// ResponseFormat requests a structured final answer (see output_schema).
+	ResponseFormat *providers.ResponseFormat
+	// SessionKey is where the run was saved, when its agent keeps sessions.
+	SessionKey string
 }
 
+// SubagentTarget is what a subagent task runs as: an agent's system prompt,
+// tools and models, plus where to save the run.
+type SubagentTarget struct {
+	AgentID       string
+	SystemPrompt  string
+	Provider      providers.LLMProvider
+	Model         string
+	Candidates    []providers.FallbackCandidate
+	Fallback      *providers.FallbackChain
+	Tools         *ToolRegistry
+	MaxIterations int
+	LLMOptions    map[string]any
+	// Save persists a finished run under runID and returns its session key.
+	// Nil means runs are not saved.
+	Save func(runID string, messages []providers.Message) string
+	// OnUsage, when set, accounts for the token usage of the run's LLM
+	// calls; channel is the chat the task was started from.
+	OnUsage func(channel, model string, u *providers.UsageInfo)
+}
+
+// SubagentResolver returns the target for agentID; an empty agentID means
+// the agent that owns the manager.
+type SubagentResolver func(agentID string) (*SubagentTarget, error)
+
+// subagentInstructions is appended to the target agent's own system prompt.
+const subagentInstructions = `# Subagent Task
+
+You are running as a subagent. Complete the given task independently and report the result.
+You have access to tools - use them as needed to complete your task.
+After completing the task, provide a clear summary of what was done.`
+
 type SubagentManager struct {
 	tasks          map[string]*SubagentTask
 	mu             sync.RWMutex
@@ -34,6 +71,8 @@ type SubagentManager struct {
 	hasMaxTokens   bool
 	hasTemperature bool
 	nextID         int
+	resolve        SubagentResolver
+	skills         *skills.SkillsLoader
 }
 
 func NewSubagentManager(
@@ -69,6 +108,149 @@ func (sm *SubagentManager) SetTools(tools *ToolRegistry) {
 	sm.tools = tools
 }
 
+// SetTargetResolver makes subagents run as the agent they name (or the
+// owning agent) instead of the manager's generic prompt, model and tools.
+func (sm *SubagentManager) SetTargetResolver(resolve SubagentResolver) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resolve = resolve
+}
+
+// SetSkillsLoader lets tasks name a skill whose instructions and
+// output_schema frontmatter they run with.
+func (sm *SubagentManager) SetSkillsLoader(loader *skills.SkillsLoader) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.skills = loader
+}
+
+// applySkill prefixes task with the named skill's instructions. The skill's
+// output_schema becomes the response format unless the caller gave one.
+func (sm *SubagentManager) applySkill(
+	name, task string,
+	format *providers.ResponseFormat,
+) (string, *providers.ResponseFormat, error) {
+	if name == "" {
+		return task, format, nil
+	}
+	sm.mu.RLock()
+	loader := sm.skills
+	sm.mu.RUnlock()
+	if loader == nil {
+		return "", nil, fmt.Errorf("skills are not available to subagents")
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", nil, fmt.Errorf("invalid skill name %q", name)
+	}
+	content, ok := loader.LoadSkill(name)
+	if !ok {
+		return "", nil, fmt.Errorf("skill %q not found", name)
+	}
+	if format == nil {
+		schema, err := loader.LoadSkillOutputSchema(name)
+		if err != nil {
+			return "", nil, err
+		}
+		if schema != nil {
+			format = schemaFormat(schema)
+		}
+	}
+	return fmt.Sprintf("### Skill: %s\n\n%s\n\n---\n\n%s", name, content, task), format, nil
+}
+
+// target resolves the agent a task runs as. Without a resolver it is the
+// manager's own provider, model and tools with a generic subagent prompt.
+func (sm *SubagentManager) target(agentID, genericPrompt string) (*SubagentTarget, error) {
+	sm.mu.RLock()
+	resolve := sm.resolve
+	maxIterations := sm.maxIterations
+	sm.mu.RUnlock()
+
+	if resolve != nil {
+		t, err := resolve(agentID)
+		if err != nil {
+			return nil, err
+		}
+		if t.SystemPrompt != "" {
+			t.SystemPrompt += "\n\n---\n\n" + subagentInstructions
+		} else {
+			t.SystemPrompt = genericPrompt
+		}
+		if t.MaxIterations <= 0 {
+			t.MaxIterations = maxIterations
+		}
+		return t, nil
+	}
+
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var llmOptions map[string]any
+	if sm.hasMaxTokens || sm.hasTemperature {
+		llmOptions = map[string]any{}
+		if sm.hasMaxTokens {
+			llmOptions["max_tokens"] = sm.maxTokens
+		}
+		if sm.hasTemperature {
+			llmOptions["temperature"] = sm.temperature
+		}
+	}
+	return &SubagentTarget{
+		AgentID:       agentID,
+		SystemPrompt:  genericPrompt,
+		Provider:      sm.provider,
+		Model:         sm.defaultModel,
+		Tools:         sm.tools,
+		MaxIterations: sm.maxIterations,
+		LLMOptions:    llmOptions,
+	}, nil
+}
+
+// runSubagent executes task as target and saves the transcript under runID.
+func runSubagent(
+	ctx context.Context,
+	target *SubagentTarget,
+	task, runID string,
+	responseFormat *providers.ResponseFormat,
+	channel, chatID string,
+) (*ToolLoopResult, string, error) {
+	messages := []providers.Message{
+		{Role: "system", Content: target.SystemPrompt},
+		{Role: "user", Content: task},
+	}
+	var onUsage func(model string, u *providers.UsageInfo)
+	if target.OnUsage != nil {
+		onUsage = func(model string, u *providers.UsageInfo) { target.OnUsage(channel, model, u) }
+	}
+	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
+		Provider:       target.Provider,
+		Model:          target.Model,
+		Tools:          target.Tools,
+		MaxIterations:  target.MaxIterations,
+		LLMOptions:     target.LLMOptions,
+		ResponseFormat: responseFormat,
+		Fallback:       target.Fallback,
+		Candidates:     target.Candidates,
+		OnUsage:        onUsage,
+	}, messages, channel, chatID)
+
+	sessionKey := ""
+	if target.Save != nil {
+		transcript := messages
+		if loopResult != nil {
+			transcript = loopResult.Messages
+		}
+		if err != nil {
+			transcript = append(transcript, providers.Message{
+				Role: "assistant", Content: fmt.Sprintf("Error: %v", err),
+			})
+		}
+		// The system prompt is rebuilt on every run; sessions keep the dialog.
+		sessionKey = target.Save(runID, transcript[1:])
+	}
+	return loopResult, sessionKey, err
+}
+
 // RegisterTool registers a tool for subagent execution.
 func (sm *SubagentManager) RegisterTool(tool Tool) {
 	sm.mu.Lock()
@@ -81,23 +263,18 @@ func (sm *SubagentManager) Spawn(
 	task, label, agentID, originChannel, originChatID string,
 	callback AsyncCallback,
 ) (string, error) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
-	taskID := fmt.Sprintf("subagent-%d", sm.nextID)
-	sm.nextID++
+	return sm.SpawnWithFormat(ctx, task, label, agentID, originChannel, originChatID, nil, callback)
+}
 
-	subagentTask := &SubagentTask{
-		ID:            taskID,
-		Task:          task,
-		Label:         label,
-		AgentID:       agentID,
-		OriginChannel: originChannel,
-		OriginChatID:  originChatID,
-		Status:        "running",
-		Created:       time.Now().UnixMilli(),
-	}
-	sm.tasks[taskID] = subagentTask
+// SpawnWithFormat is like Spawn but asks the subagent for a structured
+// final answer matching responseFormat (nil means free-form text).
+func (sm *SubagentManager) SpawnWithFormat(
+	ctx context.Context,
+	task, label, agentID, originChannel, originChatID string,
+	responseFormat *providers.ResponseFormat,
+	callback AsyncCallback,
+) (string, error) {
+	subagentTask := sm.newTask(task, label, agentID, originChannel, originChatID, responseFormat)
 
 	// Start task in background with context cancellation support
 	go sm.runTask(ctx, subagentTask, callback)
@@ -108,23 +285,48 @@ func (sm *SubagentManager) Spawn(
 	return fmt.Sprintf("Spawned subagent for task: %s", task), nil
 }
 
-func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
-	// Build system prompt for subagent
-	systemPrompt := `You are a subagent. Complete the given task independently and report the result.
-You have access to tools - use them as needed to complete your task.
-After completing the task, provide a clear summary of what was done.`
+// RunSync runs a task in the calling goroutine and returns a snapshot of it
+// once it has finished. The task is listed like a spawned one, so
+// spawn_status can report on it.
+func (sm *SubagentManager) RunSync(
+	ctx context.Context,
+	task, label, agentID, originChannel, originChatID string,
+	responseFormat *providers.ResponseFormat,
+) SubagentTask {
+	subagentTask := sm.newTask(task, label, agentID, originChannel, originChatID, responseFormat)
+	sm.runTask(ctx, subagentTask, nil)
 
-	messages := []providers.Message{
-		{
-			Role:    "system",
-			Content: systemPrompt,
-		},
-		{
-			Role:    "user",
-			Content: task.Task,
-		},
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return *subagentTask
+}
+
+func (sm *SubagentManager) newTask(
+	task, label, agentID, originChannel, originChatID string,
+	responseFormat *providers.ResponseFormat,
+) *SubagentTask {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	taskID := fmt.Sprintf("subagent-%d", sm.nextID)
+	sm.nextID++
+
+	subagentTask := &SubagentTask{
+		ID:             taskID,
+		Task:           task,
+		Label:          label,
+		AgentID:        agentID,
+		OriginChannel:  originChannel,
+		OriginChatID:   originChatID,
+		Status:         "running",
+		Created:        time.Now().UnixMilli(),
+		ResponseFormat: responseFormat,
 	}
+	sm.tasks[taskID] = subagentTask
+	return subagentTask
+}
 
+func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
 	// Check if context is already canceled before starting
 	select {
 	case <-ctx.Done():
@@ -136,36 +338,20 @@ After completing the task, provide a clear summary of what was done.`
 	default:
 	}
 
-	// Run tool loop with access to tools
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
-		}
+	// Run as the target agent, with its prompt, tools and models
+	var loopResult *ToolLoopResult
+	var sessionKey string
+	target, err := sm.target(task.AgentID, `You are a subagent. Complete the given task independently and report the result.
+You have access to tools - use them as needed to complete your task.
+After completing the task, provide a clear summary of what was done.`)
+	if err == nil {
+		runID := fmt.Sprintf("%s-%d", task.ID, task.Created)
+		loopResult, sessionKey, err = runSubagent(
+			ctx, target, task.Task, runID, task.ResponseFormat, task.OriginChannel, task.OriginChatID)
 	}
 
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, task.OriginChannel, task.OriginChatID)
-
 	sm.mu.Lock()
+	task.SessionKey = sessionKey
 	var result *ToolResult
 	defer func() {
 		sm.mu.Unlock()
@@ -285,6 +471,8 @@ func (t *SubagentTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional short label for the task (for display)",
 			},
+			"skill":         skillParameter(),
+			"output_schema": outputSchemaParameter(),
 		},
 		"required": []string{"task"},
 	}
@@ -298,42 +486,26 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 
 	label, _ := args["label"].(string)
 
+	responseFormat, err := parseOutputSchema(args)
+	if err != nil {
+		return ErrorResult(err.Error()).WithError(err)
+	}
+
 	if t.manager == nil {
 		return ErrorResult("Subagent manager not configured").WithError(fmt.Errorf("manager is nil"))
 	}
 
-	// Build messages for subagent
-	messages := []providers.Message{
-		{
-			Role:    "system",
-			Content: "You are a subagent. Complete the given task independently and provide a clear, concise result.",
-		},
-		{
-			Role:    "user",
-			Content: task,
-		},
+	skill, _ := args["skill"].(string)
+	task, responseFormat, err = t.manager.applySkill(skill, task, responseFormat)
+	if err != nil {
+		return ErrorResult(err.Error()).WithError(err)
 	}
 
-	// Use RunToolLoop to execute with tools (same as async SpawnTool)
-	sm := t.manager
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
-		}
+	// Run as the owning agent (same path as async SpawnTool)
+	target, err := t.manager.target("",
+		"You are a subagent. Complete the given task independently and provide a clear, concise result.")
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Subagent execution failed: %v", err)).WithError(err)
 	}
 
 	// Fall back to "cli"/"direct" for non-conversation callers (e.g., CLI, tests)
@@ -347,13 +519,8 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		chatID = "direct"
 	}
 
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, channel, chatID)
+	runID := fmt.Sprintf("sync-%d", time.Now().UnixMilli())
+	loopResult, _, err := runSubagent(ctx, target, task, runID, responseFormat, channel, chatID)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("Subagent execution failed: %v", err)).WithError(err)
 	}
@@ -381,3 +548,46 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		Async:   false,
 	}
 }
+
+// outputSchemaParameter describes the optional output_schema argument shared
+// by the spawn and subagent tools.
+func outputSchemaParameter() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"description": "Optional JSON Schema for the result. When set, the subagent's final answer " +
+			"is guaranteed to be JSON matching this schema. Overrides the skill's own output_schema.",
+	}
+}
+
+// skillParameter describes the optional skill argument shared by the spawn
+// and subagent tools.
+func skillParameter() map[string]any {
+	return map[string]any{
+		"type": "string",
+		"description": "Optional skill name to run the task with. The skill's instructions are given to " +
+			"the subagent, and its output_schema frontmatter (if any) makes the result structured JSON.",
+	}
+}
+
+// parseOutputSchema converts the output_schema argument into a response format.
+// It returns nil when the argument is absent.
+func parseOutputSchema(args map[string]any) (*providers.ResponseFormat, error) {
+	raw, ok := args["output_schema"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	schema, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("output_schema must be a JSON Schema object")
+	}
+	return schemaFormat(schema), nil
+}
+
+// schemaFormat turns a JSON Schema into a response format; an empty schema
+// only asks for a JSON object.
+func schemaFormat(schema map[string]any) *providers.ResponseFormat {
+	if len(schema) == 0 {
+		return &providers.ResponseFormat{Type: providers.ResponseFormatJSONObject}
+	}
+	return providers.JSONSchemaFormat("subagent_result", schema)
+}
@@ -2,10 +2,13 @@ package tools
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/skills"
 )
 
 // MockLLMProvider is a test implementation of LLMProvider
@@ -325,3 +328,169 @@ func TestSubagentTool_ForUserTruncation(t *testing.T) {
 		t.Error("ForLLM should contain reference to original task")
 	}
 }
+
+// jsonReplyProvider answers with a fixed JSON payload and records options.
+type jsonReplyProvider struct {
+	MockLLMProvider
+	reply string
+}
+
+func (m *jsonReplyProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	m.lastOptions = options
+	return &providers.LLMResponse{Content: m.reply}, nil
+}
+
+func TestSubagentTool_OutputSchema(t *testing.T) {
+	provider := &jsonReplyProvider{reply: "```json\n{\"count\": 3}\n```"}
+	manager := NewSubagentManager(provider, "test-model", "/tmp/test")
+	tool := NewSubagentTool(manager)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"task": "Count things",
+		"output_schema": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"count": map[string]any{"type": "integer"}},
+			"required":   []any{"count"},
+		},
+	})
+	if result.IsError {
+		t.Fatalf("expected success, got %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, `Result: {"count":3}`) {
+		t.Errorf("expected normalized JSON result, got %s", result.ForLLM)
+	}
+}
+
+func TestSubagentTool_OutputSchemaInvalid(t *testing.T) {
+	provider := &jsonReplyProvider{reply: "no json here"}
+	manager := NewSubagentManager(provider, "test-model", "/tmp/test")
+	tool := NewSubagentTool(manager)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"task":          "Count things",
+		"output_schema": "not a schema",
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "output_schema") {
+		t.Fatalf("expected output_schema error, got %+v", result)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"task":          "Count things",
+		"output_schema": map[string]any{"type": "object"},
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "structured output invalid") {
+		t.Fatalf("expected validation failure, got %+v", result)
+	}
+}
+
+// promptRecordingProvider records the system prompt and model of each call.
+type promptRecordingProvider struct {
+	MockLLMProvider
+	systemPrompt string
+	model        string
+}
+
+func (m *promptRecordingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	m.systemPrompt = messages[0].Content
+	m.model = model
+	return m.MockLLMProvider.Chat(ctx, messages, tools, model, options)
+}
+
+func TestSubagentManager_TargetResolver(t *testing.T) {
+	provider := &promptRecordingProvider{}
+	manager := NewSubagentManager(&MockLLMProvider{}, "generic-model", "/tmp/test")
+
+	var savedKey string
+	var saved []providers.Message
+	manager.SetTargetResolver(func(agentID string) (*SubagentTarget, error) {
+		if agentID != "coder" {
+			t.Errorf("resolver got agent %q, want coder", agentID)
+		}
+		return &SubagentTarget{
+			AgentID:      "coder",
+			SystemPrompt: "You are the coder agent.",
+			Provider:     provider,
+			Model:        "coder-model",
+			Tools:        NewToolRegistry(),
+			Save: func(runID string, messages []providers.Message) string {
+				saved = messages
+				savedKey = "agent:coder:subagent:" + runID
+				return savedKey
+			},
+		}, nil
+	})
+
+	done := make(chan *ToolResult, 1)
+	_, err := manager.Spawn(context.Background(), "fix the build", "", "coder", "cli", "direct",
+		func(_ context.Context, result *ToolResult) { done <- result })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := <-done; result.IsError {
+		t.Fatalf("subagent failed: %s", result.ForLLM)
+	}
+
+	if !strings.HasPrefix(provider.systemPrompt, "You are the coder agent.") ||
+		!strings.Contains(provider.systemPrompt, "# Subagent Task") {
+		t.Errorf("system prompt = %q", provider.systemPrompt)
+	}
+	if provider.model != "coder-model" {
+		t.Errorf("model = %q, want coder-model", provider.model)
+	}
+	if len(saved) != 2 || saved[0].Role != "user" || saved[1].Role != "assistant" {
+		t.Fatalf("saved transcript = %+v, want user and assistant messages", saved)
+	}
+	tasks := manager.ListTaskCopies()
+	if len(tasks) != 1 || tasks[0].SessionKey != savedKey {
+		t.Errorf("task session key = %+v, want %q", tasks, savedKey)
+	}
+}
+
+func TestSubagentTool_SkillOutputSchema(t *testing.T) {
+	ws := t.TempDir()
+	skillDir := filepath.Join(ws, "skills", "counter")
+	if err := os.MkdirAll(skillDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "---\nname: counter\ndescription: Count things\noutput_schema:\n  type: object\n" +
+		"  properties:\n    count:\n      type: integer\n  required: [count]\n---\n\nAlways count carefully.\n"
+	if err := os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &jsonReplyProvider{reply: `{"count": 3}`}
+	manager := NewSubagentManager(provider, "test-model", ws)
+	manager.SetSkillsLoader(skills.NewSkillsLoader(ws, "", ""))
+	tool := NewSubagentTool(manager)
+
+	result := tool.Execute(context.Background(), map[string]any{"task": "Count things", "skill": "counter"})
+	if result.IsError {
+		t.Fatalf("expected success, got %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, `Result: {"count":3}`) {
+		t.Errorf("expected structured result, got %s", result.ForLLM)
+	}
+
+	provider.reply = `{"total": 3}`
+	result = tool.Execute(context.Background(), map[string]any{"task": "Count things", "skill": "counter"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "structured output invalid") {
+		t.Fatalf("expected the skill's schema to be enforced, got %+v", result)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"task": "Count things", "skill": "missing"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not found") {
+		t.Fatalf("expected unknown skill error, got %+v", result)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"sync"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
@@ -24,12 +25,24 @@ type ToolLoopConfig struct {
 	Tools         *ToolRegistry
 	MaxIterations int
 	LLMOptions    map[string]any
+	// ResponseFormat, when set, requests a structured (JSON) final answer.
+	// It is enforced through providers.ChatStructured.
+	ResponseFormat *providers.ResponseFormat
+	// Fallback, when set with more than one candidate, runs every LLM call
+	// through the fallback chain instead of Model alone.
+	Fallback   *providers.FallbackChain
+	Candidates []providers.FallbackCandidate
+	// OnUsage, when set, receives the model and token usage of every LLM
+	// response so the caller can account for it.
+	OnUsage func(model string, u *providers.UsageInfo)
 }
 
 // ToolLoopResult contains the result of running the tool loop.
 type ToolLoopResult struct {
 	Content    string
 	Iterations int
+	// Messages is the full transcript, ending with the final answer.
+	Messages []providers.Message
 }
 
 // RunToolLoop executes the LLM + tool call iteration loop.
@@ -63,8 +76,29 @@ func RunToolLoop(
 		if llmOpts == nil {
 			llmOpts = map[string]any{}
 		}
+		if config.ResponseFormat != nil {
+			llmOpts = maps.Clone(llmOpts)
+			llmOpts[providers.ResponseFormatOptionKey] = config.ResponseFormat
+		}
 		// 3. Call LLM
-		response, err := config.Provider.Chat(ctx, messages, providerToolDefs, config.Model, llmOpts)
+		var response *providers.LLMResponse
+		var err error
+		usedModel := config.Model
+		if config.Fallback != nil && len(config.Candidates) > 1 {
+			var fbResult *providers.FallbackResult
+			fbResult, err = config.Fallback.Execute(ctx, config.Candidates,
+				func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
+					return providers.ChatStructured(ctx, config.Provider, messages, providerToolDefs, model, llmOpts)
+				})
+			if err == nil {
+				response, usedModel = fbResult.Response, fbResult.Model
+			}
+		} else {
+			response, err = providers.ChatStructured(ctx, config.Provider, messages, providerToolDefs, config.Model, llmOpts)
+		}
+		if config.OnUsage != nil && response != nil && response.Usage != nil {
+			config.OnUsage(usedModel, response.Usage)
+		}
 		if err != nil {
 			logger.ErrorCF("toolloop", "LLM call failed",
 				map[string]any{
@@ -77,6 +111,7 @@ func RunToolLoop(
 		// 4. If no tool calls, we're done
 		if len(response.ToolCalls) == 0 {
 			finalContent = response.Content
+			messages = append(messages, providers.Message{Role: "assistant", Content: finalContent})
 			logger.InfoCF("toolloop", "LLM response without tool calls (direct answer)",
 				map[string]any{
 					"iteration":     iteration,
@@ -175,5 +210,6 @@ func RunToolLoop(
 	return &ToolLoopResult{
 		Content:    finalContent,
 		Iterations: iteration,
+		Messages:   messages,
 	}, nil
 }
@@ -0,0 +1,280 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Kinds of LLM calls recorded in the ledger.
+const (
+	KindChat          = "chat"           // first LLM call of a turn
+	KindToolIteration = "tool_iteration" // follow-up call after tool results
+	KindSummarization = "summarization"  // history summarization
+	KindSubagent      = "subagent"       // call made by a spawned or synchronous subagent
+	KindClassifier    = "classifier"     // routing grade from the model classifier
+	KindHealthProbe   = "health_probe"   // background model health probe
+)
+
+// monthLayout names the per-month ledger files, e.g. "2026-03.jsonl".
+const monthLayout = "2006-01"
+
+// Record is one ledger entry: the usage of a single LLM response.
+type Record struct {
+	Time             time.Time `json:"time"`
+	AgentID          string    `json:"agent_id"`
+	SessionKey       string    `json:"session_key,omitempty"`
+	Channel          string    `json:"channel,omitempty"`
+	Model            string    `json:"model"`
+	Kind             string    `json:"kind"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	CacheReadTokens  int       `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int       `json:"cache_write_tokens,omitempty"`
+	Cost             float64   `json:"cost"`
+}
+
+// Totals aggregates a set of records.
+type Totals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CacheReadTokens  int     `json:"cache_read_tokens"`
+	CacheWriteTokens int     `json:"cache_write_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Add folds r into the totals.
+func (t *Totals) Add(r Record) {
+	t.Requests++
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.CacheReadTokens += r.CacheReadTokens
+	t.CacheWriteTokens += r.CacheWriteTokens
+	t.Cost += r.Cost
+}
+
+// TotalTokens returns prompt plus completion tokens.
+func (t Totals) TotalTokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+// Ledger is an append-only usage log stored as one JSONL file per month
+// under dir. It keeps the current month's totals in memory so budget checks
+// do not touch the disk.
+type Ledger struct {
+	dir string
+
+	mu          sync.Mutex
+	prices      *PriceTable
+	month       string
+	monthTotals Totals
+	now         func() time.Time
+}
+
+// NewLedger opens (or creates) a ledger rooted at dir.
+func NewLedger(dir string, prices *PriceTable) *Ledger {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("usage: create ledger directory %s: %v", dir, err)
+	}
+	l := &Ledger{dir: dir, prices: prices, now: time.Now}
+	l.loadMonth(l.now())
+	return l
+}
+
+// SetPrices replaces the price table, e.g. after a config reload.
+func (l *Ledger) SetPrices(prices *PriceTable) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prices = prices
+}
+
+// Record prices usage for r.Model, stamps the time and appends the entry.
+// Failures are logged; accounting must never break a conversation.
+func (l *Ledger) Record(r Record, u *providers.UsageInfo) Record {
+	if u != nil {
+		r.PromptTokens = u.PromptTokens
+		r.CompletionTokens = u.CompletionTokens
+		r.CacheReadTokens = u.CacheReadTokens
+		r.CacheWriteTokens = u.CacheWriteTokens
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r.Time.IsZero() {
+		r.Time = l.now()
+	}
+	if r.Cost == 0 {
+		r.Cost = l.prices.Cost(r.Model, u)
+	}
+
+	month := r.Time.Format(monthLayout)
+	if month != l.month {
+		l.month = month
+		l.monthTotals = Totals{}
+	}
+	l.monthTotals.Add(r)
+
+	if err := l.appendLocked(month, r); err != nil {
+		log.Printf("usage: append record: %v", err)
+	}
+	return r
+}
+
+func (l *Ledger) appendLocked(month string, r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.monthPath(month), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// MonthTotals returns the totals for the current calendar month.
+func (l *Ledger) MonthTotals() Totals {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if month := l.now().Format(monthLayout); month != l.month {
+		l.month = month
+		l.monthTotals = Totals{}
+	}
+	return l.monthTotals
+}
+
+// OverBudget reports whether this month's cost has reached limit.
+// A non-positive limit means no cap.
+func (l *Ledger) OverBudget(limit float64) bool {
+	if limit <= 0 {
+		return false
+	}
+	return l.MonthTotals().Cost >= limit
+}
+
+// SessionTotals sums all records for a session key across every month.
+func (l *Ledger) SessionTotals(sessionKey string) (Totals, error) {
+	var t Totals
+	records, err := l.Query(time.Time{}, time.Time{})
+	if err != nil {
+		return t, err
+	}
+	for _, r := range records {
+		if r.SessionKey == sessionKey {
+			t.Add(r)
+		}
+	}
+	return t, nil
+}
+
+// Query returns records with since <= Time < until, in file order. Zero
+// bounds are open.
+func (l *Ledger) Query(since, until time.Time) ([]Record, error) {
+	return ReadRecords(l.dir, since, until)
+}
+
+func (l *Ledger) monthPath(month string) string {
+	return filepath.Join(l.dir, month+".jsonl")
+}
+
+func (l *Ledger) loadMonth(now time.Time) {
+	month := now.Format(monthLayout)
+	var totals Totals
+	err := scanFile(l.monthPath(month), func(r Record) {
+		totals.Add(r)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("usage: load %s: %v", month, err)
+	}
+	l.month = month
+	l.monthTotals = totals
+}
+
+// ReadRecords reads ledger records from dir without opening a Ledger, so
+// the CLI can report on a workspace the gateway is writing to.
+func ReadRecords(dir string, since, until time.Time) ([]Record, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("usage: read ledger directory: %w", err)
+	}
+
+	var months []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		month := strings.TrimSuffix(name, ".jsonl")
+		start, err := time.ParseInLocation(monthLayout, month, time.Local)
+		if err != nil {
+			continue
+		}
+		if !until.IsZero() && !start.Before(until) {
+			continue
+		}
+		if !since.IsZero() && !start.AddDate(0, 1, 0).After(since) {
+			continue
+		}
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	var records []Record
+	for _, month := range months {
+		err := scanFile(filepath.Join(dir, month+".jsonl"), func(r Record) {
+			if !since.IsZero() && r.Time.Before(since) {
+				return
+			}
+			if !until.IsZero() && !r.Time.Before(until) {
+				return
+			}
+			records = append(records, r)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("usage: read %s: %w", month, err)
+		}
+	}
+	return records, nil
+}
+
+// scanFile decodes one record per line, skipping malformed lines such as a
+// partial write left by a crash.
+func scanFile(path string, fn func(Record)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(line, &r); err != nil {
+			continue
+		}
+		fn(r)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,103 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func newTestLedger(t *testing.T, now time.Time) *Ledger {
+	t.Helper()
+	prices := NewPriceTable([]config.ModelConfig{
+		{ModelName: "m", Model: "openai/m", Pricing: &config.ModelPricing{Input: 1, Output: 2}},
+	})
+	l := NewLedger(t.TempDir(), prices)
+	l.now = func() time.Time { return now }
+	l.loadMonth(now)
+	return l
+}
+
+func TestLedger_RecordPersistsAndPrices(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	l := newTestLedger(t, now)
+
+	rec := l.Record(Record{AgentID: "main", SessionKey: "s1", Channel: "telegram", Model: "m", Kind: KindChat},
+		&providers.UsageInfo{PromptTokens: 1_000_000, CompletionTokens: 500_000})
+	if !approxEqual(rec.Cost, 2.0) {
+		t.Errorf("Cost = %v, want 2.0", rec.Cost)
+	}
+	l.Record(Record{AgentID: "main", SessionKey: "s2", Model: "m", Kind: KindSummarization},
+		&providers.UsageInfo{PromptTokens: 1000})
+
+	month := l.MonthTotals()
+	if month.Requests != 2 || month.PromptTokens != 1_001_000 {
+		t.Errorf("MonthTotals() = %+v", month)
+	}
+
+	// A fresh ledger on the same directory reloads the month's totals.
+	reopened := NewLedger(l.dir, nil)
+	reopened.now = l.now
+	reopened.loadMonth(now)
+	if got := reopened.MonthTotals(); got.Requests != 2 || !approxEqual(got.Cost, month.Cost) {
+		t.Errorf("reloaded MonthTotals() = %+v, want %+v", got, month)
+	}
+
+	s1, err := reopened.SessionTotals("s1")
+	if err != nil {
+		t.Fatalf("SessionTotals: %v", err)
+	}
+	if s1.Requests != 1 || s1.CompletionTokens != 500_000 {
+		t.Errorf("SessionTotals(s1) = %+v", s1)
+	}
+}
+
+func TestLedger_OverBudget(t *testing.T) {
+	l := newTestLedger(t, time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local))
+	if l.OverBudget(0) {
+		t.Error("zero limit must disable the cap")
+	}
+	l.Record(Record{Model: "m", Kind: KindChat}, &providers.UsageInfo{PromptTokens: 1_000_000})
+	if l.OverBudget(5) {
+		t.Error("1.0 spent should be under a 5.0 budget")
+	}
+	if !l.OverBudget(1) {
+		t.Error("1.0 spent should reach a 1.0 budget")
+	}
+}
+
+func TestLedger_MonthRollover(t *testing.T) {
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.Local)
+	l := newTestLedger(t, now)
+	l.Record(Record{Model: "m", Kind: KindChat}, &providers.UsageInfo{PromptTokens: 1_000_000})
+
+	now = now.Add(2 * time.Hour)
+	l.now = func() time.Time { return now }
+	if got := l.MonthTotals(); got.Requests != 0 {
+		t.Errorf("MonthTotals() after rollover = %+v, want zero", got)
+	}
+
+	l.Record(Record{Model: "m", Kind: KindChat}, &providers.UsageInfo{PromptTokens: 10})
+	all, err := ReadRecords(l.dir, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ReadRecords: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("len(records) = %d, want 2", len(all))
+	}
+	april, err := ReadRecords(l.dir, time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local), time.Time{})
+	if err != nil {
+		t.Fatalf("ReadRecords: %v", err)
+	}
+	if len(april) != 1 || april[0].PromptTokens != 10 {
+		t.Errorf("April records = %+v", april)
+	}
+}
+
+func TestReadRecords_MissingDirectory(t *testing.T) {
+	records, err := ReadRecords(t.TempDir()+"/missing", time.Time{}, time.Time{})
+	if err != nil || len(records) != 0 {
+		t.Errorf("ReadRecords() = %v, %v; want empty, nil", records, err)
+	}
+}
@@ -0,0 +1,86 @@
+package usage
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// PriceTable maps model identifiers to their configured token prices.
+// Entries are indexed by model_name, by the full "protocol/model" string and
+// by the bare model ID, so lookups succeed whichever form the caller has.
+type PriceTable struct {
+	prices map[string]config.ModelPricing
+}
+
+// NewPriceTable builds a PriceTable from the model_list entries that carry
+// pricing. Models without pricing are costed at zero.
+func NewPriceTable(models []config.ModelConfig) *PriceTable {
+	pt := &PriceTable{prices: make(map[string]config.ModelPricing)}
+	for _, m := range models {
+		if m.Pricing == nil {
+			continue
+		}
+		for _, key := range []string{m.ModelName, m.Model, bareModelID(m.Model)} {
+			if key == "" {
+				continue
+			}
+			if _, exists := pt.prices[key]; !exists {
+				pt.prices[key] = *m.Pricing
+			}
+		}
+	}
+	return pt
+}
+
+// Lookup returns the pricing for model, if any.
+func (pt *PriceTable) Lookup(model string) (config.ModelPricing, bool) {
+	if pt == nil {
+		return config.ModelPricing{}, false
+	}
+	if p, ok := pt.prices[model]; ok {
+		return p, true
+	}
+	p, ok := pt.prices[bareModelID(model)]
+	return p, ok
+}
+
+// Cost returns the price of one response. Cache reads and writes are billed
+// at their own rates when configured and at the input rate otherwise.
+func (pt *PriceTable) Cost(model string, u *providers.UsageInfo) float64 {
+	if u == nil {
+		return 0
+	}
+	p, ok := pt.Lookup(model)
+	if !ok {
+		return 0
+	}
+
+	cacheRead, cacheWrite := p.CacheRead, p.CacheWrite
+	if cacheRead == 0 {
+		cacheRead = p.Input
+	}
+	if cacheWrite == 0 {
+		cacheWrite = p.Input
+	}
+
+	uncached := u.PromptTokens - u.CacheReadTokens - u.CacheWriteTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+
+	total := float64(uncached)*p.Input +
+		float64(u.CacheReadTokens)*cacheRead +
+		float64(u.CacheWriteTokens)*cacheWrite +
+		float64(u.CompletionTokens)*p.Output
+	return total / 1_000_000
+}
+
+// bareModelID strips the protocol prefix from "protocol/model".
+func bareModelID(model string) string {
+	if _, after, found := strings.Cut(model, "/"); found {
+		return after
+	}
+	return model
+}
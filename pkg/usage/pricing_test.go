@@ -0,0 +1,48 @@
+package usage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPriceTable_LookupByAnyModelForm(t *testing.T) {
+	pt := NewPriceTable([]config.ModelConfig{
+		{ModelName: "sonnet", Model: "anthropic/claude-sonnet-4.6", Pricing: &config.ModelPricing{Input: 3, Output: 15}},
+		{ModelName: "free", Model: "ollama/llama3"},
+	})
+
+	for _, name := range []string{"sonnet", "anthropic/claude-sonnet-4.6", "claude-sonnet-4.6"} {
+		if _, ok := pt.Lookup(name); !ok {
+			t.Errorf("Lookup(%q) not found", name)
+		}
+	}
+	if _, ok := pt.Lookup("free"); ok {
+		t.Error("model without pricing should not be found")
+	}
+}
+
+func TestPriceTable_Cost(t *testing.T) {
+	pt := NewPriceTable([]config.ModelConfig{
+		{ModelName: "m", Model: "openai/m", Pricing: &config.ModelPricing{Input: 2, Output: 10, CacheRead: 0.5}},
+	})
+
+	u := &providers.UsageInfo{PromptTokens: 1_000_000, CompletionTokens: 100_000, CacheReadTokens: 400_000, CacheWriteTokens: 100_000}
+	// 500k uncached * 2 + 400k read * 0.5 + 100k write * 2 (defaults to input) + 100k out * 10
+	want := 1.0 + 0.2 + 0.2 + 1.0
+	if got := pt.Cost("m", u); !approxEqual(got, want) {
+		t.Errorf("Cost() = %v, want %v", got, want)
+	}
+	if got := pt.Cost("unknown", u); got != 0 {
+		t.Errorf("Cost(unknown) = %v, want 0", got)
+	}
+	if got := pt.Cost("m", nil); got != 0 {
+		t.Errorf("Cost(nil usage) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,61 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Report periods.
+const (
+	PeriodDaily  = "daily"
+	PeriodWeekly = "weekly"
+)
+
+// Row is one line of an aggregated report.
+type Row struct {
+	Period string
+	Model  string
+	Totals Totals
+}
+
+// PeriodKey returns the bucket label for t: "2006-01-02" for daily and the
+// ISO week ("2006-W01") for weekly reports.
+func PeriodKey(t time.Time, period string) string {
+	if period == PeriodWeekly {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return t.Format("2006-01-02")
+}
+
+// Aggregate groups records by period and model. Rows are sorted by period
+// (newest first) and then by cost (highest first).
+func Aggregate(records []Record, period string) []Row {
+	type key struct{ period, model string }
+	buckets := make(map[key]*Totals)
+	for _, r := range records {
+		k := key{PeriodKey(r.Time.Local(), period), r.Model}
+		t, ok := buckets[k]
+		if !ok {
+			t = &Totals{}
+			buckets[k] = t
+		}
+		t.Add(r)
+	}
+
+	rows := make([]Row, 0, len(buckets))
+	for k, t := range buckets {
+		rows = append(rows, Row{Period: k.period, Model: k.model, Totals: *t})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Period != rows[j].Period {
+			return rows[i].Period > rows[j].Period
+		}
+		if rows[i].Totals.Cost != rows[j].Totals.Cost {
+			return rows[i].Totals.Cost > rows[j].Totals.Cost
+		}
+		return rows[i].Model < rows[j].Model
+	})
+	return rows
+}
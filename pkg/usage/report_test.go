@@ -0,0 +1,38 @@
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregate_DailyByModel(t *testing.T) {
+	day1 := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	records := []Record{
+		{Time: day1, Model: "a", PromptTokens: 10, Cost: 1},
+		{Time: day1, Model: "a", PromptTokens: 5, Cost: 1},
+		{Time: day1, Model: "b", PromptTokens: 1, Cost: 3},
+		{Time: day2, Model: "a", PromptTokens: 7, Cost: 0.5},
+	}
+
+	rows := Aggregate(records, PeriodDaily)
+	if len(rows) != 3 {
+		t.Fatalf("len(rows) = %d, want 3", len(rows))
+	}
+	if rows[0].Period != "2026-03-03" || rows[0].Model != "a" {
+		t.Errorf("rows[0] = %+v, want newest day first", rows[0])
+	}
+	if rows[1].Model != "b" || rows[2].Model != "a" || rows[2].Totals.Requests != 2 || rows[2].Totals.PromptTokens != 15 {
+		t.Errorf("day1 rows = %+v, %+v", rows[1], rows[2])
+	}
+}
+
+func TestPeriodKey_Weekly(t *testing.T) {
+	// 2026-01-01 is a Thursday, so it belongs to ISO week 1 of 2026.
+	if got := PeriodKey(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), PeriodWeekly); got != "2026-W01" {
+		t.Errorf("PeriodKey() = %q, want 2026-W01", got)
+	}
+	if got := PeriodKey(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), PeriodDaily); got != "2026-01-01" {
+		t.Errorf("PeriodKey() = %q, want 2026-01-01", got)
+	}
+}
@@ -28,10 +28,28 @@ type sessionFile struct {
 	Key      string              `json:"key"`
 	Messages []providers.Message `json:"messages"`
 	Summary  string              `json:"summary,omitempty"`
+	Cache    *sessionCacheStats  `json:"cache,omitempty"`
 	Created  time.Time           `json:"created"`
 	Updated  time.Time           `json:"updated"`
 }
 
+// sessionCacheStats mirrors the per-session prompt-cache totals persisted by
+// pkg/memory (meta file) and pkg/session (legacy JSON).
+type sessionCacheStats struct {
+	Requests         int `json:"requests"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens"`
+	CacheWriteTokens int `json:"cache_write_tokens"`
+}
+
+// hitRate returns the fraction of prompt tokens served from the cache.
+func (c *sessionCacheStats) hitRate() float64 {
+	if c == nil || c.PromptTokens <= 0 {
+		return 0
+	}
+	return float64(c.CacheReadTokens) / float64(c.PromptTokens)
+}
+
 // sessionListItem is a lightweight summary returned by GET /api/sessions.
 type sessionListItem struct {
 	ID           string `json:"id"`
@@ -40,6 +58,9 @@ type sessionListItem struct {
 	MessageCount int    `json:"message_count"`
 	Created      string `json:"created"`
 	Updated      string `json:"updated"`
+	// CacheHitRate is the fraction of prompt tokens served from the
+	// provider's prompt cache; omitted when no usage was recorded.
+	CacheHitRate *float64 `json:"cache_hit_rate,omitempty"`
 }
 
 type sessionMetaFile struct {
@@ -49,6 +70,8 @@ type sessionMetaFile struct {
 	Count     int       `json:"count"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	Cache *sessionCacheStats `json:"cache,omitempty"`
 }
 
 // picoSessionPrefix is the key prefix used by the gateway's routing for Pico
@@ -187,6 +210,7 @@ func (h *Handler) readJSONLSession(dir, sessionID string) (sessionFile, error) {
 		Key:      meta.Key,
 		Messages: messages,
 		Summary:  meta.Summary,
+		Cache:    meta.Cache,
 		Created:  created,
 		Updated:  updated,
 	}, nil
@@ -222,7 +246,7 @@ func buildSessionListItem(sessionID string, sess sessionFile) sessionListItem {
 		}
 	}
 
-	return sessionListItem{
+	item := sessionListItem{
 		ID:           sessionID,
 		Title:        title,
 		Preview:      preview,
@@ -230,6 +254,11 @@ func buildSessionListItem(sessionID string, sess sessionFile) sessionListItem {
 		Created:      sess.Created.Format(time.RFC3339),
 		Updated:      sess.Updated.Format(time.RFC3339),
 	}
+	if sess.Cache != nil && sess.Cache.Requests > 0 {
+		rate := sess.Cache.hitRate()
+		item.CacheHitRate = &rate
+	}
+	return item
 }
 
 func isEmptySession(sess sessionFile) bool {
@@ -454,14 +483,25 @@ func (h *Handler) handleGetSession(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
+	resp := map[string]any{
 		"id":       sessionID,
 		"messages": messages,
 		"summary":  sess.Summary,
 		"created":  sess.Created.Format(time.RFC3339),
 		"updated":  sess.Updated.Format(time.RFC3339),
-	})
+	}
+	if sess.Cache != nil && sess.Cache.Requests > 0 {
+		resp["cache"] = map[string]any{
+			"requests":           sess.Cache.Requests,
+			"prompt_tokens":      sess.Cache.PromptTokens,
+			"cache_read_tokens":  sess.Cache.CacheReadTokens,
+			"cache_write_tokens": sess.Cache.CacheWriteTokens,
+			"hit_rate":           sess.Cache.hitRate(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 // handleDeleteSession deletes a specific session.
@@ -320,3 +320,55 @@ func TestHandleSessions_FiltersEmptyJSONLFiles(t *testing.T) {
 		t.Fatalf("detail status = %d, want %d, body=%s", detailRec.Code, http.StatusNotFound, detailRec.Body.String())
 	}
 }
+
+func TestHandleSessions_ReportCacheStats(t *testing.T) {
+	configPath, cleanup := setupOAuthTestEnv(t)
+	defer cleanup()
+
+	dir := sessionsTestDir(t, configPath)
+	store, err := memory.NewJSONLStore(dir)
+	if err != nil {
+		t.Fatalf("NewJSONLStore() error = %v", err)
+	}
+
+	sessionKey := picoSessionPrefix + "cache-jsonl"
+	if err := store.AddFullMessage(nil, sessionKey, providers.Message{Role: "user", Content: "hi"}); err != nil {
+		t.Fatalf("AddFullMessage() error = %v", err)
+	}
+	if err := store.RecordCacheUsage(nil, sessionKey, &providers.UsageInfo{
+		PromptTokens:    200,
+		CacheReadTokens: 150,
+	}); err != nil {
+		t.Fatalf("RecordCacheUsage() error = %v", err)
+	}
+
+	h := NewHandler(configPath)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions", nil))
+	var items []sessionListItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(items) != 1 || items[0].CacheHitRate == nil || *items[0].CacheHitRate != 0.75 {
+		t.Fatalf("items = %+v, want one item with cache_hit_rate 0.75", items)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions/cache-jsonl", nil))
+	var resp struct {
+		Cache struct {
+			Requests        int     `json:"requests"`
+			CacheReadTokens int     `json:"cache_read_tokens"`
+			HitRate         float64 `json:"hit_rate"`
+		} `json:"cache"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if resp.Cache.Requests != 1 || resp.Cache.CacheReadTokens != 150 || resp.Cache.HitRate != 0.75 {
+		t.Fatalf("cache = %+v, want 1 request, 150 read tokens, 0.75 hit rate", resp.Cache)
+	}
+}
@@ -100,6 +100,12 @@ var toolCatalog = []toolCatalogEntry{
 		Category:    "communication",
 		ConfigKey:   "send_file",
 	},
+	{
+		Name:        "ask_user",
+		Description: "Ask the user a question with options and wait for their answer.",
+		Category:    "communication",
+		ConfigKey:   "ask_user",
+	},
 	{
 		Name:        "find_skills",
 		Description: "Search external skill registries for installable skills.",
@@ -291,6 +297,8 @@ func applyToolState(cfg *config.Config, toolName string, enabled bool) error {
 		cfg.Tools.Message.Enabled = enabled
 	case "send_file":
 		cfg.Tools.SendFile.Enabled = enabled
+	case "ask_user":
+		cfg.Tools.AskUser.Enabled = enabled
 	case "find_skills":
 		cfg.Tools.FindSkills.Enabled = enabled
 		if enabled {
@@ -7,6 +7,15 @@ export interface SessionSummary {
   message_count: number
   created: string
   updated: string
+  cache_hit_rate?: number
+}
+
+export interface SessionCacheStats {
+  requests: number
+  prompt_tokens: number
+  cache_read_tokens: number
+  cache_write_tokens: number
+  hit_rate: number
 }
 
 export interface SessionDetail {
@@ -15,6 +24,7 @@ export interface SessionDetail {
   summary: string
   created: string
   updated: string
+  cache?: SessionCacheStats
 }
 
 export async function getSessions(
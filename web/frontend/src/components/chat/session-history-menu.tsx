@@ -78,6 +78,15 @@ export function SessionHistoryMenu({
                     count: session.message_count,
                   })}{" "}
                   · {dayjs(session.updated).fromNow()}
+                  {session.cache_hit_rate !== undefined && (
+                    <>
+                      {" "}
+                      ·{" "}
+                      {t("chat.cacheHitRate", {
+                        rate: Math.round(session.cache_hit_rate * 100),
+                      })}
+                    </>
+                  )}
                 </span>
                 <Button
                   variant="ghost"